@@ -12,7 +12,7 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package client verifies responses from the Trillian log.
+// Package client verifies responses from Trillian logs and maps.
 package client
 
 import (
@@ -23,13 +23,16 @@ import (
 	"errors"
 	"time"
 
+	"github.com/golang/protobuf/ptypes"
 	"github.com/google/trillian"
 	"github.com/google/trillian/client/backoff"
 	"github.com/google/trillian/crypto"
 	"github.com/google/trillian/merkle"
 	"google.golang.org/genproto/googleapis/rpc/code"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // LogClient represents a client for a given Trillian log instance.
@@ -207,6 +210,8 @@ func (c *LogClient) buildLeaf(data []byte) *trillian.LogLeaf {
 	return leaf
 }
 
+// queueLeaf queues leaf, retrying while the server rejects it as over quota and says
+// when to retry, until ctx is done.
 func (c *LogClient) queueLeaf(ctx context.Context, leaf *trillian.LogLeaf) error {
 	// Queue Leaf
 	req := trillian.QueueLeafRequest{
@@ -214,8 +219,17 @@ func (c *LogClient) queueLeaf(ctx context.Context, leaf *trillian.LogLeaf) error
 		Leaf:  leaf,
 	}
 	rsp, err := c.client.QueueLeaf(ctx, &req)
-	if err != nil {
-		return err
+	for err != nil {
+		delay, ok := RetryDelay(err)
+		if !ok {
+			return err
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+		rsp, err = c.client.QueueLeaf(ctx, &req)
 	}
 	if rsp.QueuedLeaf.Status != nil && rsp.QueuedLeaf.Status.Code == int32(code.Code_ALREADY_EXISTS) {
 		// Convert this to AlreadyExists
@@ -223,3 +237,23 @@ func (c *LogClient) queueLeaf(ctx context.Context, leaf *trillian.LogLeaf) error
 	}
 	return nil
 }
+
+// RetryDelay returns how long to wait before retrying an RPC which failed with err, if
+// the server said with a RetryInfo detail, as it does when a ResourceExhausted RPC
+// should be retried later.
+func RetryDelay(err error) (time.Duration, bool) {
+	s, ok := status.FromError(err)
+	if !ok {
+		return 0, false
+	}
+	for _, d := range s.Details() {
+		if info, ok := d.(*errdetails.RetryInfo); ok {
+			delay, err := ptypes.Duration(info.RetryDelay)
+			if err != nil || delay < 0 {
+				return 0, false
+			}
+			return delay, true
+		}
+	}
+	return 0, false
+}
@@ -39,6 +39,21 @@ func (c *MockLogClient) QueueLeaves(ctx context.Context, in *trillian.QueueLeave
 	return c.c.QueueLeaves(ctx, in)
 }
 
+// AddSequencedLeaves forwards requests.
+func (c *MockLogClient) AddSequencedLeaves(ctx context.Context, in *trillian.AddSequencedLeavesRequest, opts ...grpc.CallOption) (*trillian.AddSequencedLeavesResponse, error) {
+	return c.c.AddSequencedLeaves(ctx, in)
+}
+
+// AddWitnessSignature forwards requests.
+func (c *MockLogClient) AddWitnessSignature(ctx context.Context, in *trillian.AddWitnessSignatureRequest, opts ...grpc.CallOption) (*trillian.AddWitnessSignatureResponse, error) {
+	return c.c.AddWitnessSignature(ctx, in)
+}
+
+// AddObservedRoot forwards requests.
+func (c *MockLogClient) AddObservedRoot(ctx context.Context, in *trillian.AddObservedRootRequest, opts ...grpc.CallOption) (*trillian.AddObservedRootResponse, error) {
+	return c.c.AddObservedRoot(ctx, in)
+}
+
 // GetInclusionProof forwards requests and modifies the response.
 func (c *MockLogClient) GetInclusionProof(ctx context.Context, in *trillian.GetInclusionProofRequest, opts ...grpc.CallOption) (*trillian.GetInclusionProofResponse, error) {
 	resp, err := c.c.GetInclusionProof(ctx, in)
@@ -73,6 +88,11 @@ func (c *MockLogClient) GetConsistencyProof(ctx context.Context, in *trillian.Ge
 	return resp, err
 }
 
+// GetConsistencyProofHistory forwards requests.
+func (c *MockLogClient) GetConsistencyProofHistory(ctx context.Context, in *trillian.GetConsistencyProofHistoryRequest, opts ...grpc.CallOption) (trillian.TrillianLog_GetConsistencyProofHistoryClient, error) {
+	return c.c.GetConsistencyProofHistory(ctx, in)
+}
+
 // GetLatestSignedLogRoot forwards requests.
 func (c *MockLogClient) GetLatestSignedLogRoot(ctx context.Context, in *trillian.GetLatestSignedLogRootRequest, opts ...grpc.CallOption) (*trillian.GetLatestSignedLogRootResponse, error) {
 	return c.c.GetLatestSignedLogRoot(ctx, in)
@@ -88,6 +108,11 @@ func (c *MockLogClient) GetLeavesByIndex(ctx context.Context, in *trillian.GetLe
 	return c.c.GetLeavesByIndex(ctx, in)
 }
 
+// GetLeavesByIndexStream forwards requests.
+func (c *MockLogClient) GetLeavesByIndexStream(ctx context.Context, in *trillian.GetLeavesByIndexRequest, opts ...grpc.CallOption) (trillian.TrillianLog_GetLeavesByIndexStreamClient, error) {
+	return c.c.GetLeavesByIndexStream(ctx, in)
+}
+
 // GetLeavesByHash forwards requests.
 func (c *MockLogClient) GetLeavesByHash(ctx context.Context, in *trillian.GetLeavesByHashRequest, opts ...grpc.CallOption) (*trillian.GetLeavesByHashResponse, error) {
 	return c.c.GetLeavesByHash(ctx, in)
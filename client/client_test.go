@@ -23,6 +23,7 @@ import (
 	"google.golang.org/grpc/codes"
 
 	"github.com/google/trillian"
+	"github.com/google/trillian/server/errors"
 	"github.com/google/trillian/testonly"
 	"github.com/google/trillian/testonly/integration"
 )
@@ -117,3 +118,21 @@ func TestUpdateRoot(t *testing.T) {
 		t.Errorf("Tree size after add Leaf: %v, want > %v", got, want)
 	}
 }
+
+func TestRetryDelay(t *testing.T) {
+	for _, test := range []struct {
+		desc      string
+		err       error
+		wantDelay time.Duration
+		wantOK    bool
+	}{
+		{desc: "no details", err: grpc.Errorf(codes.ResourceExhausted, "busy")},
+		{desc: "no delay", err: errors.ResourceExhausted("log:1", "max_unsequenced_leaves", 0, "busy")},
+		{desc: "delay", err: errors.ResourceExhausted("log:1", "max_unsequenced_leaves", 3*time.Second, "busy"), wantDelay: 3 * time.Second, wantOK: true},
+	} {
+		delay, ok := RetryDelay(test.err)
+		if delay != test.wantDelay || ok != test.wantOK {
+			t.Errorf("%v: RetryDelay()=%v, %v, want %v, %v", test.desc, delay, ok, test.wantDelay, test.wantOK)
+		}
+	}
+}
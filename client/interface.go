@@ -32,3 +32,22 @@ type VerifyingLogClient interface {
 	// Root provides the last root obtained by UpdateRoot.
 	Root() trillian.SignedLogRoot
 }
+
+// VerifyingMapClient is a client that verifies output from a Trillian map.
+type VerifyingMapClient interface {
+	// Get returns the value of the leaf at index in the revision of Root, after
+	// verifying its inclusion, or non-inclusion, in the map.
+	Get(ctx context.Context, index []byte) ([]byte, error)
+	// GetLeaves is like Get, for several leaves at once.
+	GetLeaves(ctx context.Context, indexes [][]byte) ([]*trillian.MapLeaf, error)
+	// Set sets the value of the leaf at index and verifies the root of the new
+	// revision of the map.
+	Set(ctx context.Context, index, value []byte) error
+	// SetLeaves is like Set, for several leaves at once.
+	SetLeaves(ctx context.Context, leaves []*trillian.MapLeaf) error
+	// UpdateRoot fetches and verifies the current SignedMapRoot.
+	// It checks its signature, and that the map's revision hasn't gone backwards.
+	UpdateRoot(ctx context.Context) error
+	// Root provides the last root obtained by UpdateRoot, GetLeaves or SetLeaves.
+	Root() trillian.SignedMapRoot
+}
@@ -0,0 +1,153 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"context"
+	gocrypto "crypto"
+	"errors"
+	"fmt"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/merkle"
+)
+
+// MapClient represents a client for a given Trillian map instance.
+type MapClient struct {
+	MapID  int64
+	client trillian.TrillianMapClient
+	hasher merkle.MapHasher
+	// root has a nil Signature until a root has been verified.
+	root   trillian.SignedMapRoot
+	pubKey gocrypto.PublicKey
+}
+
+// NewMapClient returns a new MapClient.
+func NewMapClient(mapID int64, client trillian.TrillianMapClient, hasher merkle.MapHasher, pubKey gocrypto.PublicKey) VerifyingMapClient {
+	return &MapClient{
+		MapID:  mapID,
+		client: client,
+		hasher: hasher,
+		pubKey: pubKey,
+	}
+}
+
+// Root returns the last valid root seen by UpdateRoot, GetLeaves or SetLeaves.
+// Returns an empty SignedMapRoot if none of them has been called.
+func (c *MapClient) Root() trillian.SignedMapRoot {
+	return c.root
+}
+
+// UpdateRoot retrieves the current SignedMapRoot.
+// Verifies the signature, and that the revision isn't older than the last one seen.
+func (c *MapClient) UpdateRoot(ctx context.Context) error {
+	resp, err := c.client.GetSignedMapRoot(ctx, &trillian.GetSignedMapRootRequest{MapId: c.MapID})
+	if err != nil {
+		return err
+	}
+	return c.updateRoot(resp.GetMapRoot())
+}
+
+// updateRoot verifies root and makes it the current root.
+func (c *MapClient) updateRoot(root *trillian.SignedMapRoot) error {
+	if root == nil {
+		return errors.New("no map root in response")
+	}
+	if root.MapId != c.MapID {
+		return fmt.Errorf("got root of map %d, want map %d", root.MapId, c.MapID)
+	}
+	if err := crypto.Verify(c.pubKey, crypto.HashMapRoot(*root), root.Signature); err != nil {
+		return err
+	}
+	if c.root.Signature != nil {
+		switch {
+		case root.MapRevision < c.root.MapRevision:
+			return fmt.Errorf("got root of revision %d, older than revision %d", root.MapRevision, c.root.MapRevision)
+		case root.MapRevision == c.root.MapRevision && !bytes.Equal(root.RootHash, c.root.RootHash):
+			return fmt.Errorf("got root hash %x for revision %d, want %x", root.RootHash, root.MapRevision, c.root.RootHash)
+		}
+	}
+	c.root = *root
+	return nil
+}
+
+// Get returns the value of the leaf at index, see GetLeaves. The value of a leaf
+// which isn't in the map is empty.
+func (c *MapClient) Get(ctx context.Context, index []byte) ([]byte, error) {
+	leaves, err := c.GetLeaves(ctx, [][]byte{index})
+	if err != nil {
+		return nil, err
+	}
+	return leaves[0].LeafValue, nil
+}
+
+// GetLeaves returns the leaves at indexes, in the same order, as of the revision of
+// Root, fetching the current root first if there isn't one yet. Call UpdateRoot to
+// read a newer revision. The inclusion proof of every leaf is verified, leaves which
+// aren't in the map are returned empty, with a verified proof of non-inclusion.
+func (c *MapClient) GetLeaves(ctx context.Context, indexes [][]byte) ([]*trillian.MapLeaf, error) {
+	if c.root.Signature == nil {
+		if err := c.UpdateRoot(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := c.client.GetLeaves(ctx, &trillian.GetMapLeavesRequest{
+		MapId:    c.MapID,
+		Index:    indexes,
+		Revision: c.root.MapRevision,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if got, want := len(resp.MapLeafInclusion), len(indexes); got != want {
+		return nil, fmt.Errorf("got %d leaves, want %d", got, want)
+	}
+
+	leaves := make([]*trillian.MapLeaf, len(indexes))
+	for i, incl := range resp.MapLeafInclusion {
+		leaf := incl.GetLeaf()
+		if leaf == nil || !bytes.Equal(leaf.Index, indexes[i]) {
+			return nil, fmt.Errorf("got leaf %v, want index %x", leaf, indexes[i])
+		}
+		// Leaves which aren't in the map hash like empty ones.
+		leafHash := c.hasher.HashLeaf(leaf.LeafValue)
+		if err := merkle.VerifyMapInclusionProof(leaf.Index, leafHash, c.root.RootHash, incl.Inclusion, c.hasher); err != nil {
+			return nil, fmt.Errorf("invalid inclusion proof for index %x at revision %d: %v", leaf.Index, c.root.MapRevision, err)
+		}
+		leaves[i] = leaf
+	}
+	return leaves, nil
+}
+
+// Set sets the value of the leaf at index, see SetLeaves.
+func (c *MapClient) Set(ctx context.Context, index, value []byte) error {
+	return c.SetLeaves(ctx, []*trillian.MapLeaf{{Index: index, LeafValue: value}})
+}
+
+// SetLeaves writes leaves to the map, creating a new revision, and verifies the
+// signed root of the new revision, which becomes Root.
+func (c *MapClient) SetLeaves(ctx context.Context, leaves []*trillian.MapLeaf) error {
+	resp, err := c.client.SetLeaves(ctx, &trillian.SetMapLeavesRequest{
+		MapId:  c.MapID,
+		Leaves: leaves,
+	})
+	if err != nil {
+		return err
+	}
+	return c.updateRoot(resp.GetMapRoot())
+}
@@ -0,0 +1,167 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"context"
+	gocrypto "crypto"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/crypto/keys"
+	"github.com/google/trillian/crypto/sigpb"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/mockclient"
+	"github.com/google/trillian/testonly"
+)
+
+const testMapID = 7
+
+// oneLeafMap returns the root hash of a map holding just value, at the all-zeroes
+// index, and the non-inclusion proof of the all-ones index. Both indexes only have
+// empty siblings, except for the all-ones index at the top level.
+func oneLeafMap(h merkle.MapHasher, value []byte) ([]byte, [][]byte) {
+	bits := h.Size() * 8
+	empty := h.HashLeaf(nil)
+	hash := h.HashLeaf(value)
+	for bit := 0; bit < bits-1; bit++ {
+		hash = h.HashChildren(hash, empty)
+		empty = h.HashChildren(empty, empty)
+	}
+	proof := make([][]byte, bits)
+	proof[bits-1] = hash
+	return h.HashChildren(hash, empty), proof
+}
+
+func TestMapClient(t *testing.T) {
+	h := merkle.NewMapHasher(testonly.Hasher)
+	key, err := keys.NewFromPrivatePEM(testonly.DemoPrivateKey, testonly.DemoPrivateKeyPass)
+	if err != nil {
+		t.Fatalf("Failed to load private key: %v", err)
+	}
+	otherKey, err := keys.GenerateKey(sigpb.DigitallySigned_ECDSA)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	sign := func(signer gocrypto.Signer, revision int64, hash []byte) *trillian.SignedMapRoot {
+		root := &trillian.SignedMapRoot{MapId: testMapID, MapRevision: revision, TimestampNanos: 1000 + revision, RootHash: hash}
+		sig, err := crypto.NewSigner(signer).Sign(crypto.HashMapRoot(*root))
+		if err != nil {
+			t.Fatalf("Failed to sign root: %v", err)
+		}
+		root.Signature = sig
+		return root
+	}
+
+	present, absent := make([]byte, h.Size()), bytes.Repeat([]byte{0xff}, h.Size())
+	value := []byte("value")
+	rootHash, absentProof := oneLeafMap(h, value)
+	root := sign(key, 2, rootHash)
+
+	for _, test := range []struct {
+		desc      string
+		root      *trillian.SignedMapRoot
+		index     []byte
+		leaf      *trillian.MapLeaf
+		proof     [][]byte
+		wantValue []byte
+		wantErr   bool
+	}{
+		{desc: "present", root: root, index: present, leaf: &trillian.MapLeaf{Index: present, LeafValue: value}, proof: make([][]byte, h.Size()*8), wantValue: value},
+		{desc: "absent", root: root, index: absent, leaf: &trillian.MapLeaf{Index: absent}, proof: absentProof},
+		{desc: "wrongValue", root: root, index: present, leaf: &trillian.MapLeaf{Index: present, LeafValue: []byte("other")}, proof: make([][]byte, h.Size()*8), wantErr: true},
+		{desc: "hiddenValue", root: root, index: present, leaf: &trillian.MapLeaf{Index: present}, proof: make([][]byte, h.Size()*8), wantErr: true},
+		{desc: "wrongIndex", root: root, index: absent, leaf: &trillian.MapLeaf{Index: present, LeafValue: value}, proof: make([][]byte, h.Size()*8), wantErr: true},
+		{desc: "wrongKey", root: sign(otherKey, 2, rootHash), index: present, wantErr: true},
+	} {
+		func() {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mock := mockclient.NewMockTrillianMapClient(ctrl)
+			mock.EXPECT().GetSignedMapRoot(gomock.Any(), &trillian.GetSignedMapRootRequest{MapId: testMapID}).Return(&trillian.GetSignedMapRootResponse{MapRoot: test.root}, nil)
+			if test.leaf != nil {
+				mock.EXPECT().GetLeaves(gomock.Any(), &trillian.GetMapLeavesRequest{MapId: testMapID, Index: [][]byte{test.index}, Revision: 2}).Return(&trillian.GetMapLeavesResponse{
+					MapLeafInclusion: []*trillian.MapLeafInclusion{{Leaf: test.leaf, Inclusion: test.proof}},
+				}, nil)
+			}
+
+			client := NewMapClient(testMapID, mock, h, key.Public())
+			got, err := client.Get(context.Background(), test.index)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Errorf("%v: Get()=(_, %v), want err? %v", test.desc, err, test.wantErr)
+				return
+			}
+			if !bytes.Equal(got, test.wantValue) {
+				t.Errorf("%v: Get()=%q, want %q", test.desc, got, test.wantValue)
+			}
+		}()
+	}
+}
+
+func TestMapClientRevisions(t *testing.T) {
+	h := merkle.NewMapHasher(testonly.Hasher)
+	key, err := keys.NewFromPrivatePEM(testonly.DemoPrivateKey, testonly.DemoPrivateKeyPass)
+	if err != nil {
+		t.Fatalf("Failed to load private key: %v", err)
+	}
+	sign := func(revision int64, hash []byte) *trillian.SignedMapRoot {
+		root := &trillian.SignedMapRoot{MapId: testMapID, MapRevision: revision, RootHash: hash}
+		sig, err := crypto.NewSigner(key).Sign(crypto.HashMapRoot(*root))
+		if err != nil {
+			t.Fatalf("Failed to sign root: %v", err)
+		}
+		root.Signature = sig
+		return root
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mock := mockclient.NewMockTrillianMapClient(ctrl)
+	client := NewMapClient(testMapID, mock, h, key.Public())
+	ctx := context.Background()
+
+	index := make([]byte, h.Size())
+	mock.EXPECT().SetLeaves(gomock.Any(), &trillian.SetMapLeavesRequest{MapId: testMapID, Leaves: []*trillian.MapLeaf{{Index: index, LeafValue: []byte("value")}}}).Return(&trillian.SetMapLeavesResponse{MapRoot: sign(3, []byte("root3"))}, nil)
+	if err := client.Set(ctx, index, []byte("value")); err != nil {
+		t.Fatalf("Set()=%v", err)
+	}
+	if got, want := client.Root().MapRevision, int64(3); got != want {
+		t.Errorf("Root().MapRevision=%d after Set(), want %d", got, want)
+	}
+
+	for _, test := range []struct {
+		desc    string
+		root    *trillian.SignedMapRoot
+		wantErr bool
+	}{
+		{desc: "same", root: sign(3, []byte("root3"))},
+		{desc: "newer", root: sign(4, []byte("root4"))},
+		{desc: "older", root: sign(3, []byte("root3")), wantErr: true},
+		{desc: "forked", root: sign(4, []byte("other")), wantErr: true},
+		{desc: "otherMap", root: &trillian.SignedMapRoot{MapId: testMapID + 1, MapRevision: 5}, wantErr: true},
+	} {
+		mock.EXPECT().GetSignedMapRoot(gomock.Any(), &trillian.GetSignedMapRootRequest{MapId: testMapID}).Return(&trillian.GetSignedMapRootResponse{MapRoot: test.root}, nil)
+		if err := client.UpdateRoot(ctx); (err != nil) != test.wantErr {
+			t.Errorf("%v: UpdateRoot()=%v, want err? %v", test.desc, err, test.wantErr)
+		}
+	}
+	if got, want := client.Root().MapRevision, int64(4); got != want {
+		t.Errorf("Root().MapRevision=%d, want %d", got, want)
+	}
+}
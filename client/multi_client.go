@@ -0,0 +1,392 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/trillian"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// Defaults for the LoadBalancingOptions left unset.
+const (
+	DefaultEjectAfter = 3
+	DefaultEjectFor   = 30 * time.Second
+)
+
+// Policy decides which endpoint a MultiLogClient sends each call to first.
+type Policy int
+
+const (
+	// PickFirst sends every call to the first endpoint which isn't ejected, so the
+	// others are only used while it's down.
+	PickFirst Policy = iota
+	// RoundRobin sends calls to the endpoints which aren't ejected in turn.
+	RoundRobin
+)
+
+// ParsePolicy returns the Policy named name, one of pick_first or round_robin.
+func ParsePolicy(name string) (Policy, error) {
+	switch name {
+	case "", "pick_first":
+		return PickFirst, nil
+	case "round_robin":
+		return RoundRobin, nil
+	}
+	return PickFirst, fmt.Errorf("unknown load balancing policy %q, want pick_first or round_robin", name)
+}
+
+// LoadBalancingOptions configures how a MultiLogClient spreads calls over its endpoints.
+type LoadBalancingOptions struct {
+	// Policy picks the endpoint each call is sent to first.
+	Policy Policy
+	// EjectAfter is the number of consecutive calls to an endpoint which may fail with
+	// UNAVAILABLE before it's ejected. Ejected endpoints are only tried once the others
+	// have failed. DefaultEjectAfter if 0.
+	EjectAfter int
+	// EjectFor is how long an endpoint stays ejected before calls are sent to it again,
+	// DefaultEjectFor if 0.
+	EjectFor time.Duration
+	// HedgeDelay, if greater than 0, is how long a read can go unanswered before it's
+	// also sent to the next endpoint. The first answer is used and the other call is
+	// cancelled. Writes are never hedged.
+	HedgeDelay time.Duration
+}
+
+// endpoint is one of the servers a MultiLogClient sends calls to.
+type endpoint struct {
+	client trillian.TrillianLogClient
+	// failures is the number of consecutive calls which found it unavailable.
+	failures     int
+	ejectedUntil time.Time
+}
+
+// MultiLogClient is a TrillianLogClient which sends calls to several log servers, e.g.
+// the connections to each of a set of frontends. A call which fails with UNAVAILABLE
+// is retried on the other endpoints in turn, and endpoints which keep failing are
+// ejected for a while. Other errors are returned straight away.
+type MultiLogClient struct {
+	opts LoadBalancingOptions
+	// now returns the current time, it's replaced in tests.
+	now func() time.Time
+
+	mu        sync.Mutex
+	endpoints []*endpoint
+	next      int
+}
+
+// NewMultiLogClient returns a MultiLogClient sending calls to clients.
+func NewMultiLogClient(clients []trillian.TrillianLogClient, opts LoadBalancingOptions) (*MultiLogClient, error) {
+	if len(clients) == 0 {
+		return nil, errors.New("no endpoints to send calls to")
+	}
+	if opts.EjectAfter <= 0 {
+		opts.EjectAfter = DefaultEjectAfter
+	}
+	if opts.EjectFor <= 0 {
+		opts.EjectFor = DefaultEjectFor
+	}
+	c := &MultiLogClient{opts: opts, now: time.Now}
+	for _, client := range clients {
+		c.endpoints = append(c.endpoints, &endpoint{client: client})
+	}
+	return c, nil
+}
+
+// order returns the endpoints in the order a call should try them: those which aren't
+// ejected as the policy picks them, then the ejected ones, soonest back first.
+func (c *MultiLogClient) order() []*endpoint {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	start := 0
+	if c.opts.Policy == RoundRobin {
+		start = c.next
+		c.next = (c.next + 1) % len(c.endpoints)
+	}
+	var healthy, ejected []*endpoint
+	for i := range c.endpoints {
+		e := c.endpoints[(start+i)%len(c.endpoints)]
+		if now.Before(e.ejectedUntil) {
+			ejected = append(ejected, e)
+			continue
+		}
+		healthy = append(healthy, e)
+	}
+	for i := 1; i < len(ejected); i++ {
+		for j := i; j > 0 && ejected[j].ejectedUntil.Before(ejected[j-1].ejectedUntil); j-- {
+			ejected[j], ejected[j-1] = ejected[j-1], ejected[j]
+		}
+	}
+	return append(healthy, ejected...)
+}
+
+// record updates the health of e after a call to it returned err.
+func (c *MultiLogClient) record(e *endpoint, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !unavailable(err) {
+		e.failures = 0
+		return
+	}
+	e.failures++
+	if e.failures >= c.opts.EjectAfter {
+		e.ejectedUntil = c.now().Add(c.opts.EjectFor)
+	}
+}
+
+func unavailable(err error) bool {
+	return grpc.Code(err) == codes.Unavailable
+}
+
+// call sends a call made by rpc to the endpoints in turn until one of them is available.
+// If hedge is set and HedgeDelay passes before an endpoint answers, the call is also
+// sent to the next one, and the first answer is returned.
+func (c *MultiLogClient) call(ctx context.Context, hedge bool, rpc func(context.Context, trillian.TrillianLogClient) (interface{}, error)) (interface{}, error) {
+	endpoints := c.order()
+	// Cancelling ctx stops the calls still in progress once there's an answer.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		e   *endpoint
+		rsp interface{}
+		err error
+	}
+	results := make(chan result, len(endpoints))
+	started, finished := 0, 0
+	start := func() {
+		e := endpoints[started]
+		started++
+		go func() {
+			rsp, err := rpc(ctx, e.client)
+			results <- result{e: e, rsp: rsp, err: err}
+		}()
+	}
+
+	start()
+	var hedgeTimer <-chan time.Time
+	if hedge && c.opts.HedgeDelay > 0 {
+		t := time.NewTimer(c.opts.HedgeDelay)
+		defer t.Stop()
+		hedgeTimer = t.C
+	}
+	for {
+		select {
+		case r := <-results:
+			finished++
+			c.record(r.e, r.err)
+			if !unavailable(r.err) {
+				return r.rsp, r.err
+			}
+			if started < len(endpoints) {
+				start()
+			} else if finished == started {
+				return nil, r.err
+			}
+		case <-hedgeTimer:
+			hedgeTimer = nil
+			if started < len(endpoints) {
+				start()
+			}
+		}
+	}
+}
+
+// stream opens a stream made by rpc on the endpoints in turn until one of them is
+// available. Streams aren't hedged.
+func (c *MultiLogClient) stream(rpc func(trillian.TrillianLogClient) (interface{}, error)) (interface{}, error) {
+	var err error
+	for _, e := range c.order() {
+		var s interface{}
+		s, err = rpc(e.client)
+		c.record(e, err)
+		if !unavailable(err) {
+			return s, err
+		}
+	}
+	return nil, err
+}
+
+// QueueLeaf implements trillian.TrillianLogClient.
+func (c *MultiLogClient) QueueLeaf(ctx context.Context, in *trillian.QueueLeafRequest, opts ...grpc.CallOption) (*trillian.QueueLeafResponse, error) {
+	rsp, err := c.call(ctx, false, func(ctx context.Context, client trillian.TrillianLogClient) (interface{}, error) {
+		return client.QueueLeaf(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rsp.(*trillian.QueueLeafResponse), nil
+}
+
+// QueueLeaves implements trillian.TrillianLogClient.
+func (c *MultiLogClient) QueueLeaves(ctx context.Context, in *trillian.QueueLeavesRequest, opts ...grpc.CallOption) (*trillian.QueueLeavesResponse, error) {
+	rsp, err := c.call(ctx, false, func(ctx context.Context, client trillian.TrillianLogClient) (interface{}, error) {
+		return client.QueueLeaves(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rsp.(*trillian.QueueLeavesResponse), nil
+}
+
+// AddSequencedLeaves implements trillian.TrillianLogClient.
+func (c *MultiLogClient) AddSequencedLeaves(ctx context.Context, in *trillian.AddSequencedLeavesRequest, opts ...grpc.CallOption) (*trillian.AddSequencedLeavesResponse, error) {
+	rsp, err := c.call(ctx, false, func(ctx context.Context, client trillian.TrillianLogClient) (interface{}, error) {
+		return client.AddSequencedLeaves(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rsp.(*trillian.AddSequencedLeavesResponse), nil
+}
+
+// AddWitnessSignature implements trillian.TrillianLogClient.
+func (c *MultiLogClient) AddWitnessSignature(ctx context.Context, in *trillian.AddWitnessSignatureRequest, opts ...grpc.CallOption) (*trillian.AddWitnessSignatureResponse, error) {
+	rsp, err := c.call(ctx, false, func(ctx context.Context, client trillian.TrillianLogClient) (interface{}, error) {
+		return client.AddWitnessSignature(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rsp.(*trillian.AddWitnessSignatureResponse), nil
+}
+
+// AddObservedRoot implements trillian.TrillianLogClient.
+func (c *MultiLogClient) AddObservedRoot(ctx context.Context, in *trillian.AddObservedRootRequest, opts ...grpc.CallOption) (*trillian.AddObservedRootResponse, error) {
+	rsp, err := c.call(ctx, false, func(ctx context.Context, client trillian.TrillianLogClient) (interface{}, error) {
+		return client.AddObservedRoot(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rsp.(*trillian.AddObservedRootResponse), nil
+}
+
+// GetInclusionProof implements trillian.TrillianLogClient.
+func (c *MultiLogClient) GetInclusionProof(ctx context.Context, in *trillian.GetInclusionProofRequest, opts ...grpc.CallOption) (*trillian.GetInclusionProofResponse, error) {
+	rsp, err := c.call(ctx, true, func(ctx context.Context, client trillian.TrillianLogClient) (interface{}, error) {
+		return client.GetInclusionProof(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rsp.(*trillian.GetInclusionProofResponse), nil
+}
+
+// GetInclusionProofByHash implements trillian.TrillianLogClient.
+func (c *MultiLogClient) GetInclusionProofByHash(ctx context.Context, in *trillian.GetInclusionProofByHashRequest, opts ...grpc.CallOption) (*trillian.GetInclusionProofByHashResponse, error) {
+	rsp, err := c.call(ctx, true, func(ctx context.Context, client trillian.TrillianLogClient) (interface{}, error) {
+		return client.GetInclusionProofByHash(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rsp.(*trillian.GetInclusionProofByHashResponse), nil
+}
+
+// GetConsistencyProof implements trillian.TrillianLogClient.
+func (c *MultiLogClient) GetConsistencyProof(ctx context.Context, in *trillian.GetConsistencyProofRequest, opts ...grpc.CallOption) (*trillian.GetConsistencyProofResponse, error) {
+	rsp, err := c.call(ctx, true, func(ctx context.Context, client trillian.TrillianLogClient) (interface{}, error) {
+		return client.GetConsistencyProof(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rsp.(*trillian.GetConsistencyProofResponse), nil
+}
+
+// GetConsistencyProofHistory implements trillian.TrillianLogClient.
+func (c *MultiLogClient) GetConsistencyProofHistory(ctx context.Context, in *trillian.GetConsistencyProofHistoryRequest, opts ...grpc.CallOption) (trillian.TrillianLog_GetConsistencyProofHistoryClient, error) {
+	s, err := c.stream(func(client trillian.TrillianLogClient) (interface{}, error) {
+		return client.GetConsistencyProofHistory(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.(trillian.TrillianLog_GetConsistencyProofHistoryClient), nil
+}
+
+// GetLatestSignedLogRoot implements trillian.TrillianLogClient.
+func (c *MultiLogClient) GetLatestSignedLogRoot(ctx context.Context, in *trillian.GetLatestSignedLogRootRequest, opts ...grpc.CallOption) (*trillian.GetLatestSignedLogRootResponse, error) {
+	rsp, err := c.call(ctx, true, func(ctx context.Context, client trillian.TrillianLogClient) (interface{}, error) {
+		return client.GetLatestSignedLogRoot(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rsp.(*trillian.GetLatestSignedLogRootResponse), nil
+}
+
+// GetSequencedLeafCount implements trillian.TrillianLogClient.
+func (c *MultiLogClient) GetSequencedLeafCount(ctx context.Context, in *trillian.GetSequencedLeafCountRequest, opts ...grpc.CallOption) (*trillian.GetSequencedLeafCountResponse, error) {
+	rsp, err := c.call(ctx, true, func(ctx context.Context, client trillian.TrillianLogClient) (interface{}, error) {
+		return client.GetSequencedLeafCount(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rsp.(*trillian.GetSequencedLeafCountResponse), nil
+}
+
+// GetLeavesByIndex implements trillian.TrillianLogClient.
+func (c *MultiLogClient) GetLeavesByIndex(ctx context.Context, in *trillian.GetLeavesByIndexRequest, opts ...grpc.CallOption) (*trillian.GetLeavesByIndexResponse, error) {
+	rsp, err := c.call(ctx, true, func(ctx context.Context, client trillian.TrillianLogClient) (interface{}, error) {
+		return client.GetLeavesByIndex(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rsp.(*trillian.GetLeavesByIndexResponse), nil
+}
+
+// GetLeavesByIndexStream implements trillian.TrillianLogClient.
+func (c *MultiLogClient) GetLeavesByIndexStream(ctx context.Context, in *trillian.GetLeavesByIndexRequest, opts ...grpc.CallOption) (trillian.TrillianLog_GetLeavesByIndexStreamClient, error) {
+	s, err := c.stream(func(client trillian.TrillianLogClient) (interface{}, error) {
+		return client.GetLeavesByIndexStream(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.(trillian.TrillianLog_GetLeavesByIndexStreamClient), nil
+}
+
+// GetLeavesByHash implements trillian.TrillianLogClient.
+func (c *MultiLogClient) GetLeavesByHash(ctx context.Context, in *trillian.GetLeavesByHashRequest, opts ...grpc.CallOption) (*trillian.GetLeavesByHashResponse, error) {
+	rsp, err := c.call(ctx, true, func(ctx context.Context, client trillian.TrillianLogClient) (interface{}, error) {
+		return client.GetLeavesByHash(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rsp.(*trillian.GetLeavesByHashResponse), nil
+}
+
+// GetEntryAndProof implements trillian.TrillianLogClient.
+func (c *MultiLogClient) GetEntryAndProof(ctx context.Context, in *trillian.GetEntryAndProofRequest, opts ...grpc.CallOption) (*trillian.GetEntryAndProofResponse, error) {
+	rsp, err := c.call(ctx, true, func(ctx context.Context, client trillian.TrillianLogClient) (interface{}, error) {
+		return client.GetEntryAndProof(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rsp.(*trillian.GetEntryAndProofResponse), nil
+}
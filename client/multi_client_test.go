@@ -0,0 +1,181 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// fakeEndpoint answers GetLatestSignedLogRoot and QueueLeaf with its root's tree size,
+// or err if set, after delay or once the call is cancelled, whichever is first.
+type fakeEndpoint struct {
+	trillian.TrillianLogClient
+	size  int64
+	err   error
+	delay time.Duration
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (f *fakeEndpoint) answer(ctx context.Context) (trillian.SignedLogRoot, error) {
+	f.mu.Lock()
+	f.calls++
+	f.mu.Unlock()
+	select {
+	case <-time.After(f.delay):
+	case <-ctx.Done():
+		return trillian.SignedLogRoot{}, grpc.Errorf(codes.Canceled, "%v", ctx.Err())
+	}
+	return trillian.SignedLogRoot{TreeSize: f.size}, f.err
+}
+
+func (f *fakeEndpoint) numCalls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+func (f *fakeEndpoint) GetLatestSignedLogRoot(ctx context.Context, in *trillian.GetLatestSignedLogRootRequest, opts ...grpc.CallOption) (*trillian.GetLatestSignedLogRootResponse, error) {
+	root, err := f.answer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &trillian.GetLatestSignedLogRootResponse{SignedLogRoot: &root}, nil
+}
+
+func (f *fakeEndpoint) QueueLeaf(ctx context.Context, in *trillian.QueueLeafRequest, opts ...grpc.CallOption) (*trillian.QueueLeafResponse, error) {
+	if _, err := f.answer(ctx); err != nil {
+		return nil, err
+	}
+	return &trillian.QueueLeafResponse{}, nil
+}
+
+func newTestMultiLogClient(t *testing.T, opts LoadBalancingOptions, endpoints ...*fakeEndpoint) *MultiLogClient {
+	var clients []trillian.TrillianLogClient
+	for _, e := range endpoints {
+		clients = append(clients, e)
+	}
+	c, err := NewMultiLogClient(clients, opts)
+	if err != nil {
+		t.Fatalf("NewMultiLogClient()=(_, %v), want (_, nil)", err)
+	}
+	return c
+}
+
+// getSize returns the tree size of the root c's GetLatestSignedLogRoot returns.
+func getSize(c *MultiLogClient) (int64, error) {
+	rsp, err := c.GetLatestSignedLogRoot(context.Background(), &trillian.GetLatestSignedLogRootRequest{})
+	if err != nil {
+		return 0, err
+	}
+	return rsp.SignedLogRoot.TreeSize, nil
+}
+
+func TestNewMultiLogClientNoEndpoints(t *testing.T) {
+	if _, err := NewMultiLogClient(nil, LoadBalancingOptions{}); err == nil {
+		t.Error("NewMultiLogClient(nil)=(_, nil), want error")
+	}
+}
+
+func TestMultiLogClientPolicies(t *testing.T) {
+	for _, test := range []struct {
+		policy Policy
+		want   []int64
+	}{
+		{policy: PickFirst, want: []int64{1, 1, 1, 1}},
+		{policy: RoundRobin, want: []int64{1, 2, 3, 1}},
+	} {
+		c := newTestMultiLogClient(t, LoadBalancingOptions{Policy: test.policy}, &fakeEndpoint{size: 1}, &fakeEndpoint{size: 2}, &fakeEndpoint{size: 3})
+		for i, want := range test.want {
+			if got, err := getSize(c); err != nil || got != want {
+				t.Errorf("%v: call %d went to endpoint %d (err=%v), want %d", test.policy, i, got, err, want)
+			}
+		}
+	}
+}
+
+func TestMultiLogClientFailover(t *testing.T) {
+	down := &fakeEndpoint{size: 1, err: grpc.Errorf(codes.Unavailable, "down")}
+	up := &fakeEndpoint{size: 2}
+	now := time.Unix(1500000000, 0)
+	c := newTestMultiLogClient(t, LoadBalancingOptions{EjectAfter: 2, EjectFor: time.Minute}, down, up)
+	c.now = func() time.Time { return now }
+
+	// The down endpoint is tried until it's ejected, the call failing over each time.
+	for i := 0; i < 4; i++ {
+		if got, err := getSize(c); err != nil || got != 2 {
+			t.Fatalf("call %d: getSize()=(%d, %v), want (2, nil)", i, got, err)
+		}
+	}
+	if got := down.numCalls(); got != 2 {
+		t.Errorf("down endpoint got %d calls, want 2 before it's ejected", got)
+	}
+
+	// Once the ejection is over it's tried again.
+	now = now.Add(time.Minute)
+	if _, err := getSize(c); err != nil {
+		t.Fatalf("getSize()=(_, %v), want (_, nil)", err)
+	}
+	if got := down.numCalls(); got != 3 {
+		t.Errorf("down endpoint got %d calls, want 3 after its ejection", got)
+	}
+
+	// If every endpoint is down the last error is returned.
+	up.err = grpc.Errorf(codes.Unavailable, "down too")
+	if _, err := getSize(c); grpc.Code(err) != codes.Unavailable {
+		t.Errorf("getSize()=(_, %v), want Unavailable", err)
+	}
+}
+
+func TestMultiLogClientNoFailoverOnOtherErrors(t *testing.T) {
+	first := &fakeEndpoint{err: grpc.Errorf(codes.NotFound, "no log")}
+	second := &fakeEndpoint{size: 2}
+	c := newTestMultiLogClient(t, LoadBalancingOptions{}, first, second)
+	if _, err := getSize(c); grpc.Code(err) != codes.NotFound {
+		t.Errorf("getSize()=(_, %v), want NotFound", err)
+	}
+	if got := second.numCalls(); got != 0 {
+		t.Errorf("second endpoint got %d calls, want 0", got)
+	}
+}
+
+func TestMultiLogClientHedging(t *testing.T) {
+	slow := &fakeEndpoint{size: 1, delay: time.Minute}
+	fast := &fakeEndpoint{size: 2}
+	c := newTestMultiLogClient(t, LoadBalancingOptions{HedgeDelay: 10 * time.Millisecond}, slow, fast)
+
+	// Reads are hedged.
+	if got, err := getSize(c); err != nil || got != 2 {
+		t.Errorf("getSize()=(%d, %v), want (2, nil) from the hedged call", got, err)
+	}
+
+	// Writes aren't.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := c.QueueLeaf(ctx, &trillian.QueueLeafRequest{}); err == nil {
+		t.Error("QueueLeaf()=(_, nil), want error from the slow endpoint")
+	}
+	if got := fast.numCalls(); got != 1 {
+		t.Errorf("fast endpoint got %d calls, want 1, QueueLeaf shouldn't be hedged", got)
+	}
+}
@@ -29,24 +29,48 @@
 // assume reasonable defaults. Multiple types of private keys may be supported;
 // one has only to set the appropriate --private_key_format value and supply the
 // corresponding flags for the chosen key type.
+//
+// With --generate_key a new key matching --signature_algorithm is created and
+// written to --pem_key_path, encrypted with --pem_key_password, instead of
+// using an existing key file. Its public key is written next to it, to
+// <pem_key_path>.pub.
+//
+// With --private_key_format=PrivateKey the key read from --pem_key_path is kept
+// with the tree in storage, so the servers don't need a copy of the file.
+//
+// With --private_key_format=None the tree is verification-only: it has just the
+// public key read from --public_key_path, so it's never sequenced or signed for.
+// It keeps and serves the roots of a log run elsewhere, imported with
+// AddObservedRoot. --public_key_path can be given with the other formats too, for
+// the public key to be returned with the tree.
 package main
 
 import (
 	"context"
+	"crypto/x509"
 	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"time"
 
 	"github.com/golang/protobuf/ptypes"
 	"github.com/golang/protobuf/ptypes/any"
 	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/keys"
 	"github.com/google/trillian/crypto/sigpb"
+	"github.com/google/trillian/server/interceptor"
+	"github.com/google/trillian/util/spiffe"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 )
 
 var (
 	adminServerAddr = flag.String("admin_server", "", "Address of the gRPC Trillian Admin Server (host:port)")
+	spiffeSocket    = flag.String("spiffe_socket", "", "If set, connect to the Admin Server over mutual TLS with SVIDs from the SPIFFE Workload API at this address")
+	spiffeServerID  = flag.String("spiffe_server_id", "", "SPIFFE ID the Admin Server must have with --spiffe_socket")
+	tenant          = flag.String("tenant", "", "If set, the tenant the tree is created for, on servers keeping tenants' trees apart")
 
 	treeState          = flag.String("tree_state", trillian.TreeState_ACTIVE.String(), "State of the new tree")
 	treeType           = flag.String("tree_type", trillian.TreeType_LOG.String(), "Type of the new tree")
@@ -54,12 +78,21 @@ var (
 	hashAlgorithm      = flag.String("hash_algorithm", sigpb.DigitallySigned_SHA256.String(), "Hash algorithm of the new tree")
 	signatureAlgorithm = flag.String("signature_algorithm", sigpb.DigitallySigned_RSA.String(), "Signature algorithm of the new tree")
 	duplicatePolicy    = flag.String("duplicate_policy", trillian.DuplicatePolicy_DUPLICATES_NOT_ALLOWED.String(), "Duplicate policy of the new tree")
+	leafCompression    = flag.String("leaf_compression", trillian.LeafCompression_UNCOMPRESSED.String(), "Compression of the new tree's leaf data at rest")
+	identityHash       = flag.String("leaf_identity_hash_strategy", trillian.LeafIdentityHashStrategy_CLIENT_SUPPLIED.String(), "How the new log computes the identity hashes of its leaves")
+	identityHashKey    = flag.String("leaf_identity_hash_key_path", "", "Path to the file holding the key of the HMAC_SHA256_LEAF_VALUE leaf identity hash strategy, at least 32 bytes")
+	leafRetention      = flag.Int("leaf_retention_seconds", 0, "If greater than 0, how long, in seconds, leaf values and extra data of the new log are kept for after they're integrated")
 	displayName        = flag.String("display_name", "", "Display name of the new tree")
 	description        = flag.String("description", "", "Description of the new tree")
+	shardSetID         = flag.Int64("shard_set_id", 0, "If not 0, the shard set the new log is a shard of, see --shard_start and --shard_end")
+	shardStart         = flag.String("shard_start", "", "RFC 3339 time the new shard's window starts at, e.g. 2017-01-01T00:00:00Z")
+	shardEnd           = flag.String("shard_end", "", "RFC 3339 time the new shard's window ends at, exclusive")
 
-	privateKeyFormat = flag.String("private_key_format", "PEMKeyFile", "Type of private key to be used")
-	pemKeyPath       = flag.String("pem_key_path", "", "Path to the private key PEM file")
+	privateKeyFormat = flag.String("private_key_format", "PEMKeyFile", "Type of private key to be used: PEMKeyFile, for a key file the servers read, PrivateKey, for a key kept with the tree in storage, or None, for a verification-only tree")
+	pemKeyPath       = flag.String("pem_key_path", "", "Path to the private key PEM file. With PrivateKey the key is read from it when creating the tree")
 	pemKeyPassword   = flag.String("pem_key_password", "", "Password of the private key PEM file")
+	generateKey      = flag.Bool("generate_key", false, "Generate a new PEMKeyFile private key and write it to --pem_key_path, which must not exist yet")
+	publicKeyPath    = flag.String("public_key_path", "", "Path to the public key PEM file. Required with --private_key_format=None")
 )
 
 // createOpts contains all user-supplied options required to run the program.
@@ -67,7 +100,15 @@ var (
 type createOpts struct {
 	addr                                                                                                      string
 	treeState, treeType, hashStrategy, hashAlgorithm, sigAlgorithm, duplicatePolicy, displayName, description string
-	privateKeyType, pemKeyPath, pemKeyPass                                                                    string
+	leafCompression                                                                                           string
+	identityHash, identityHashKeyPath                                                                         string
+	leafRetention                                                                                             int
+	shardSetID                                                                                                int64
+	shardStart, shardEnd                                                                                      string
+	privateKeyType, pemKeyPath, pemKeyPass, publicKeyPath                                                     string
+	generateKey                                                                                               bool
+	spiffeSocket, spiffeServerID                                                                              string
+	tenant                                                                                                    string
 }
 
 func createTree(ctx context.Context, opts *createOpts) (*trillian.Tree, error) {
@@ -80,12 +121,29 @@ func createTree(ctx context.Context, opts *createOpts) (*trillian.Tree, error) {
 		return nil, err
 	}
 
-	conn, err := grpc.Dial(opts.addr, grpc.WithInsecure())
+	dialOpt := grpc.WithInsecure()
+	if opts.spiffeSocket != "" {
+		source, err := spiffe.NewSource(ctx, opts.spiffeSocket)
+		if err != nil {
+			return nil, err
+		}
+		defer source.Close()
+		creds, err := spiffe.ClientCredentials(source, opts.spiffeServerID)
+		if err != nil {
+			return nil, err
+		}
+		dialOpt = grpc.WithTransportCredentials(creds)
+	}
+
+	conn, err := grpc.Dial(opts.addr, dialOpt)
 	if err != nil {
 		return nil, err
 	}
 	defer conn.Close()
 
+	if opts.tenant != "" {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.Pairs(interceptor.TenantHeader, opts.tenant))
+	}
 	tree, err := trillian.NewTrillianAdminClient(conn).CreateTree(ctx, req)
 	if err != nil {
 		return nil, err
@@ -124,7 +182,42 @@ func newRequest(opts *createOpts) (*trillian.CreateTreeRequest, error) {
 		return nil, fmt.Errorf("unknown DuplicatePolicy: %v", opts.duplicatePolicy)
 	}
 
-	pk, err := newPK(opts)
+	lc, ok := trillian.LeafCompression_value[opts.leafCompression]
+	if !ok {
+		return nil, fmt.Errorf("unknown LeafCompression: %v", opts.leafCompression)
+	}
+
+	ihs, ok := trillian.LeafIdentityHashStrategy_value[opts.identityHash]
+	if !ok {
+		return nil, fmt.Errorf("unknown LeafIdentityHashStrategy: %v", opts.identityHash)
+	}
+	var identityHashKey []byte
+	if opts.identityHashKeyPath != "" {
+		var err error
+		if identityHashKey, err = ioutil.ReadFile(opts.identityHashKeyPath); err != nil {
+			return nil, fmt.Errorf("failed to read leaf identity hash key: %v", err)
+		}
+	}
+
+	var shardStartMillis, shardEndMillis int64
+	if opts.shardStart != "" || opts.shardEnd != "" {
+		start, err := time.Parse(time.RFC3339, opts.shardStart)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --shard_start: %v", err)
+		}
+		end, err := time.Parse(time.RFC3339, opts.shardEnd)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --shard_end: %v", err)
+		}
+		shardStartMillis = start.UnixNano() / int64(time.Millisecond)
+		shardEndMillis = end.UnixNano() / int64(time.Millisecond)
+	}
+
+	pk, err := newPK(opts, sigpb.DigitallySigned_SignatureAlgorithm(sa))
+	if err != nil {
+		return nil, err
+	}
+	pub, err := newPublicKey(opts)
 	if err != nil {
 		return nil, err
 	}
@@ -136,51 +229,145 @@ func newRequest(opts *createOpts) (*trillian.CreateTreeRequest, error) {
 		HashAlgorithm:      sigpb.DigitallySigned_HashAlgorithm(ha),
 		SignatureAlgorithm: sigpb.DigitallySigned_SignatureAlgorithm(sa),
 		DuplicatePolicy:    trillian.DuplicatePolicy(dp),
+		LeafCompression:    trillian.LeafCompression(lc),
 		DisplayName:        opts.displayName,
 		Description:        opts.description,
 		PrivateKey:         pk,
+		PublicKey:          pub,
+
+		LeafRetentionSeconds:       int32(opts.leafRetention),
+		LeafIdentityHashStrategy:   trillian.LeafIdentityHashStrategy(ihs),
+		LeafIdentityHashKey:        identityHashKey,
+		ShardSetId:                 opts.shardSetID,
+		ShardStartMillisSinceEpoch: shardStartMillis,
+		ShardEndMillisSinceEpoch:   shardEndMillis,
 	}
 	return &trillian.CreateTreeRequest{Tree: tree}, nil
 }
 
-func newPK(opts *createOpts) (*any.Any, error) {
+func newPK(opts *createOpts, sigAlgorithm sigpb.DigitallySigned_SignatureAlgorithm) (*any.Any, error) {
 	switch opts.privateKeyType {
 	case "PEMKeyFile":
 		path := opts.pemKeyPath
 		if path == "" {
 			return nil, errors.New("empty PEM path")
 		}
-		if _, err := os.Stat(path); err != nil {
-			return nil, fmt.Errorf("error reading PEM key file at %v: %v", path, err)
-		}
 		pass := opts.pemKeyPass
 		if pass == "" {
 			return nil, errors.New("empty PEM key password")
 		}
+		if opts.generateKey {
+			if err := writeNewPEMKey(path, pass, sigAlgorithm); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("error reading PEM key file at %v: %v", path, err)
+		}
 		pemKey := &trillian.PEMKeyFile{
 			Path:     path,
 			Password: pass,
 		}
 		return ptypes.MarshalAny(pemKey)
+	case "PrivateKey":
+		if opts.generateKey {
+			return nil, errors.New("keys can only be generated for PEMKeyFile")
+		}
+		if opts.pemKeyPath == "" {
+			return nil, errors.New("empty PEM path")
+		}
+		key, err := keys.NewFromPrivatePEMFile(opts.pemKeyPath, opts.pemKeyPass)
+		if err != nil {
+			return nil, err
+		}
+		der, err := keys.MarshalPrivateKeyDER(key)
+		if err != nil {
+			return nil, err
+		}
+		return ptypes.MarshalAny(&trillian.PrivateKey{Der: der})
+	case "None":
+		if opts.generateKey {
+			return nil, errors.New("keys can only be generated for PEMKeyFile")
+		}
+		if opts.publicKeyPath == "" {
+			return nil, errors.New("empty public key path, verification-only trees need a public key")
+		}
+		return nil, nil
 	default:
 		return nil, fmt.Errorf("unknown private key type: %v", opts.privateKeyType)
 	}
 }
 
+// newPublicKey returns the public key read from opts.publicKeyPath, if there is one.
+func newPublicKey(opts *createOpts) (*trillian.PublicKey, error) {
+	if opts.publicKeyPath == "" {
+		return nil, nil
+	}
+	key, err := keys.NewFromPublicPEMFile(opts.publicKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return &trillian.PublicKey{Der: der}, nil
+}
+
+// writeNewPEMKey generates a key for sigAlgorithm and writes it, encrypted with pass,
+// to path. The public key is written to path + ".pub". Existing files are never
+// overwritten, as they may hold the key of another tree.
+func writeNewPEMKey(path, pass string, sigAlgorithm sigpb.DigitallySigned_SignatureAlgorithm) error {
+	key, err := keys.GenerateKey(sigAlgorithm)
+	if err != nil {
+		return err
+	}
+	privPEM, err := keys.MarshalPrivateKeyPEM(key, pass)
+	if err != nil {
+		return err
+	}
+	pubPEM, err := keys.MarshalPublicKeyPEM(key.Public())
+	if err != nil {
+		return err
+	}
+
+	for _, p := range []string{path, path + ".pub"} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			return fmt.Errorf("refusing to generate key: %v already exists", p)
+		}
+	}
+	if err := ioutil.WriteFile(path, privPEM, 0600); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path+".pub", pubPEM, 0644)
+}
+
 func newOptsFromFlags() *createOpts {
 	return &createOpts{
-		addr:            *adminServerAddr,
-		treeState:       *treeState,
-		treeType:        *treeType,
-		hashStrategy:    *hashStrategy,
-		hashAlgorithm:   *hashAlgorithm,
-		sigAlgorithm:    *signatureAlgorithm,
-		duplicatePolicy: *duplicatePolicy,
-		displayName:     *displayName,
-		description:     *description,
-		privateKeyType:  *privateKeyFormat,
-		pemKeyPath:      *pemKeyPath,
-		pemKeyPass:      *pemKeyPassword,
+		addr:                *adminServerAddr,
+		treeState:           *treeState,
+		treeType:            *treeType,
+		hashStrategy:        *hashStrategy,
+		hashAlgorithm:       *hashAlgorithm,
+		sigAlgorithm:        *signatureAlgorithm,
+		duplicatePolicy:     *duplicatePolicy,
+		leafCompression:     *leafCompression,
+		identityHash:        *identityHash,
+		identityHashKeyPath: *identityHashKey,
+		leafRetention:       *leafRetention,
+		displayName:         *displayName,
+		description:         *description,
+		shardSetID:          *shardSetID,
+		shardStart:          *shardStart,
+		shardEnd:            *shardEnd,
+		privateKeyType:      *privateKeyFormat,
+		pemKeyPath:          *pemKeyPath,
+		pemKeyPass:          *pemKeyPassword,
+		generateKey:         *generateKey,
+		publicKeyPath:       *publicKeyPath,
+		spiffeSocket:        *spiffeSocket,
+		spiffeServerID:      *spiffeServerID,
+		tenant:              *tenant,
 	}
 }
 
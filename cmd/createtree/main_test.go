@@ -15,17 +15,24 @@
 package main
 
 import (
+	"crypto/x509"
 	"errors"
+	"io/ioutil"
 	"net"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/golang/protobuf/ptypes"
 	"github.com/golang/protobuf/ptypes/empty"
 	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/keys"
 	"github.com/google/trillian/crypto/sigpb"
+	"github.com/google/trillian/server/interceptor"
 	"github.com/kylelemons/godebug/pretty"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 )
 
 func TestRun(t *testing.T) {
@@ -64,6 +71,8 @@ func TestRun(t *testing.T) {
 	nonDefaultTree.TreeType = trillian.TreeType_MAP
 	nonDefaultTree.SignatureAlgorithm = sigpb.DigitallySigned_ECDSA
 	nonDefaultTree.DuplicatePolicy = trillian.DuplicatePolicy_DUPLICATES_ALLOWED
+	nonDefaultTree.LeafCompression = trillian.LeafCompression_SNAPPY
+	nonDefaultTree.LeafRetentionSeconds = 3600
 	nonDefaultTree.DisplayName = "Llamas Map"
 	nonDefaultTree.Description = "For all your digital llama needs!"
 
@@ -71,9 +80,100 @@ func TestRun(t *testing.T) {
 	nonDefaultOpts.treeType = nonDefaultTree.TreeType.String()
 	nonDefaultOpts.sigAlgorithm = nonDefaultTree.SignatureAlgorithm.String()
 	nonDefaultOpts.duplicatePolicy = nonDefaultTree.DuplicatePolicy.String()
+	nonDefaultOpts.leafCompression = nonDefaultTree.LeafCompression.String()
+	nonDefaultOpts.leafRetention = int(nonDefaultTree.LeafRetentionSeconds)
 	nonDefaultOpts.displayName = nonDefaultTree.DisplayName
 	nonDefaultOpts.description = nonDefaultTree.Description
 
+	dir, err := ioutil.TempDir("", "createtree")
+	if err != nil {
+		t.Fatalf("TempDir() = %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	generateKeyOpts := *validOpts
+	generateKeyOpts.sigAlgorithm = sigpb.DigitallySigned_ECDSA.String()
+	generateKeyOpts.pemKeyPath = filepath.Join(dir, "new.pem")
+	generateKeyOpts.generateKey = true
+	generatedKey, err := ptypes.MarshalAny(&trillian.PEMKeyFile{Path: generateKeyOpts.pemKeyPath, Password: pemKey.Password})
+	if err != nil {
+		t.Fatalf("Can't marshall generated pemKey: %v", err)
+	}
+	generatedKeyTree := *defaultTree
+	generatedKeyTree.SignatureAlgorithm = sigpb.DigitallySigned_ECDSA
+	generatedKeyTree.PrivateKey = generatedKey
+
+	// Generating a key must not overwrite the one used by validOpts.
+	existingKeyOpts := *validOpts
+	existingKeyOpts.generateKey = true
+
+	privateKeyOpts := *validOpts
+	privateKeyOpts.privateKeyType = "PrivateKey"
+	signer, err := keys.NewFromPrivatePEMFile(pemKey.Path, pemKey.Password)
+	if err != nil {
+		t.Fatalf("Can't load pemKey: %v", err)
+	}
+	der, err := keys.MarshalPrivateKeyDER(signer)
+	if err != nil {
+		t.Fatalf("Can't marshal pemKey as DER: %v", err)
+	}
+	derKey, err := ptypes.MarshalAny(&trillian.PrivateKey{Der: der})
+	if err != nil {
+		t.Fatalf("Can't marshal DER key: %v", err)
+	}
+	privateKeyTree := *defaultTree
+	privateKeyTree.PrivateKey = derKey
+
+	generatePrivateKeyOpts := privateKeyOpts
+	generatePrivateKeyOpts.generateKey = true
+
+	verificationOnlyOpts := *validOpts
+	verificationOnlyOpts.privateKeyType = "None"
+	verificationOnlyOpts.publicKeyPath = "../../testdata/log-rpc-server.pubkey.pem"
+	publicKey, err := keys.NewFromPublicPEMFile(verificationOnlyOpts.publicKeyPath)
+	if err != nil {
+		t.Fatalf("Can't load public key: %v", err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		t.Fatalf("Can't marshal public key as DER: %v", err)
+	}
+	verificationOnlyTree := *defaultTree
+	verificationOnlyTree.PrivateKey = nil
+	verificationOnlyTree.PublicKey = &trillian.PublicKey{Der: publicDER}
+
+	noPublicKeyOpts := verificationOnlyOpts
+	noPublicKeyOpts.publicKeyPath = ""
+
+	shardOpts := *validOpts
+	shardOpts.shardSetID = 7
+	shardOpts.shardStart = "2017-01-01T00:00:00Z"
+	shardOpts.shardEnd = "2017-02-01T00:00:00Z"
+	shardTree := *defaultTree
+	shardTree.ShardSetId = 7
+	shardTree.ShardStartMillisSinceEpoch = 1483228800000
+	shardTree.ShardEndMillisSinceEpoch = 1485907200000
+
+	identityHashKey := []byte("0123456789abcdef0123456789abcdef")
+	identityHashOpts := *validOpts
+	identityHashOpts.identityHash = trillian.LeafIdentityHashStrategy_HMAC_SHA256_LEAF_VALUE.String()
+	identityHashOpts.identityHashKeyPath = filepath.Join(dir, "identity.key")
+	if err := ioutil.WriteFile(identityHashOpts.identityHashKeyPath, identityHashKey, 0600); err != nil {
+		t.Fatalf("WriteFile() = %v", err)
+	}
+	identityHashTree := *defaultTree
+	identityHashTree.LeafIdentityHashStrategy = trillian.LeafIdentityHashStrategy_HMAC_SHA256_LEAF_VALUE
+	identityHashTree.LeafIdentityHashKey = identityHashKey
+
+	invalidIdentityHashKeyOpts := identityHashOpts
+	invalidIdentityHashKeyOpts.identityHashKeyPath = "/not/a/file"
+
+	tenantOpts := *validOpts
+	tenantOpts.tenant = "acme"
+
+	invalidShardOpts := shardOpts
+	invalidShardOpts.shardEnd = "next month"
+
 	emptyAddr := *validOpts
 	emptyAddr.addr = ""
 
@@ -93,11 +193,12 @@ func TestRun(t *testing.T) {
 	emptyPEMPass.pemKeyPass = ""
 
 	tests := []struct {
-		desc      string
-		opts      *createOpts
-		createErr error
-		wantErr   bool
-		wantTree  *trillian.Tree
+		desc       string
+		opts       *createOpts
+		createErr  error
+		wantErr    bool
+		wantTree   *trillian.Tree
+		wantTenant string
 	}{
 		{
 			desc:     "validOpts",
@@ -109,6 +210,62 @@ func TestRun(t *testing.T) {
 			opts:     &nonDefaultOpts,
 			wantTree: &nonDefaultTree,
 		},
+		{
+			desc:     "identityHash",
+			opts:     &identityHashOpts,
+			wantTree: &identityHashTree,
+		},
+		{
+			desc:     "generateKey",
+			opts:     &generateKeyOpts,
+			wantTree: &generatedKeyTree,
+		},
+		{
+			desc:     "privateKey",
+			opts:     &privateKeyOpts,
+			wantTree: &privateKeyTree,
+		},
+		{
+			desc:    "generatePrivateKey",
+			opts:    &generatePrivateKeyOpts,
+			wantErr: true,
+		},
+		{
+			desc:     "verificationOnly",
+			opts:     &verificationOnlyOpts,
+			wantTree: &verificationOnlyTree,
+		},
+		{
+			desc:    "verificationOnlyWithoutPublicKey",
+			opts:    &noPublicKeyOpts,
+			wantErr: true,
+		},
+		{
+			desc:     "shard",
+			opts:     &shardOpts,
+			wantTree: &shardTree,
+		},
+		{
+			desc:       "tenant",
+			opts:       &tenantOpts,
+			wantTree:   defaultTree,
+			wantTenant: "acme",
+		},
+		{
+			desc:    "invalidShardWindow",
+			opts:    &invalidShardOpts,
+			wantErr: true,
+		},
+		{
+			desc:    "invalidIdentityHashKeyPath",
+			opts:    &invalidIdentityHashKeyOpts,
+			wantErr: true,
+		},
+		{
+			desc:    "generateKeyExists",
+			opts:    &existingKeyOpts,
+			wantErr: true,
+		},
 		{
 			// No mandatory opts provided
 			desc:    "defaultOptsOnly",
@@ -169,14 +326,19 @@ func TestRun(t *testing.T) {
 		if diff := pretty.Compare(tree, test.wantTree); diff != "" {
 			t.Errorf("%v: post-createTree diff:\n%v", test.desc, diff)
 		}
+		if server.tenant != test.wantTenant {
+			t.Errorf("%v: createTree() sent tenant %q, want %q", test.desc, server.tenant, test.wantTenant)
+		}
 	}
 }
 
 // fakeAdminServer that implements CreateTree. If err is nil, the CreateTree
 // input is echoed as the output, otherwise err is returned instead.
+// The tenant named by the last CreateTree request is kept in tenant.
 // The remaining methods are not implemented.
 type fakeAdminServer struct {
-	err error
+	err    error
+	tenant string
 }
 
 // startFakeServer starts a fakeAdminServer on a random port.
@@ -202,6 +364,11 @@ func startFakeServer() (*fakeAdminServer, net.Listener, func(), error) {
 }
 
 func (s *fakeAdminServer) CreateTree(ctx context.Context, req *trillian.CreateTreeRequest) (*trillian.Tree, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.tenant = ""
+	if tenants := md[interceptor.TenantHeader]; len(tenants) == 1 {
+		s.tenant = tenants[0]
+	}
 	if s.err != nil {
 		return nil, s.err
 	}
@@ -226,3 +393,11 @@ func (s *fakeAdminServer) UpdateTree(context.Context, *trillian.UpdateTreeReques
 func (s *fakeAdminServer) DeleteTree(context.Context, *trillian.DeleteTreeRequest) (*empty.Empty, error) {
 	return nil, errUnimplemented
 }
+
+func (s *fakeAdminServer) SequenceLog(context.Context, *trillian.SequenceLogRequest) (*trillian.SequenceLogResponse, error) {
+	return nil, errUnimplemented
+}
+
+func (s *fakeAdminServer) GetTreeStats(context.Context, *trillian.GetTreeStatsRequest) (*trillian.GetTreeStatsResponse, error) {
+	return nil, errUnimplemented
+}
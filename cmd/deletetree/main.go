@@ -0,0 +1,102 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package main contains the implementation and entry point for the deletetree
+// command.
+//
+// Example usage:
+// $ ./deletetree \
+//     --admin_server=host:port \
+//     --tree_id=123
+//
+// Trees are soft-deleted. A soft-deleted tree can be brought back with the
+// --undelete flag, which returns it to the ACTIVE state. The command outputs
+// nothing on success, or an error to stderr in case of failure.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/google/trillian"
+	"google.golang.org/genproto/protobuf/field_mask"
+	"google.golang.org/grpc"
+)
+
+var (
+	adminServerAddr = flag.String("admin_server", "", "Address of the gRPC Trillian Admin Server (host:port)")
+	treeID          = flag.Int64("tree_id", 0, "ID of the tree to delete")
+	undelete        = flag.Bool("undelete", false, "Undelete a soft-deleted tree instead of deleting it")
+)
+
+// deleteOpts contains all user-supplied options required to run the program.
+type deleteOpts struct {
+	addr     string
+	treeID   int64
+	undelete bool
+}
+
+func run(ctx context.Context, opts *deleteOpts) error {
+	if opts.addr == "" {
+		return errors.New("empty --admin_server, please provide the Admin server host:port")
+	}
+	if opts.treeID == 0 {
+		return errors.New("empty --tree_id, please provide the ID of the tree to delete")
+	}
+
+	conn, err := grpc.Dial(opts.addr, grpc.WithInsecure())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	client := trillian.NewTrillianAdminClient(conn)
+
+	if !opts.undelete {
+		_, err := client.DeleteTree(ctx, &trillian.DeleteTreeRequest{TreeId: opts.treeID})
+		return err
+	}
+
+	// Only undelete trees that are actually soft-deleted, so a frozen tree isn't
+	// accidentally made writable again.
+	tree, err := client.GetTree(ctx, &trillian.GetTreeRequest{TreeId: opts.treeID})
+	if err != nil {
+		return err
+	}
+	if tree.TreeState != trillian.TreeState_SOFT_DELETED {
+		return fmt.Errorf("tree %d is %v, only %v trees can be undeleted", opts.treeID, tree.TreeState, trillian.TreeState_SOFT_DELETED)
+	}
+	_, err = client.UpdateTree(ctx, &trillian.UpdateTreeRequest{
+		Tree:       &trillian.Tree{TreeId: opts.treeID, TreeState: trillian.TreeState_ACTIVE},
+		UpdateMask: &field_mask.FieldMask{Paths: []string{"tree_state"}},
+	})
+	return err
+}
+
+func main() {
+	flag.Parse()
+
+	ctx := context.Background()
+	opts := &deleteOpts{addr: *adminServerAddr, treeID: *treeID, undelete: *undelete}
+	if err := run(ctx, opts); err != nil {
+		verb := "delete"
+		if opts.undelete {
+			verb = "undelete"
+		}
+		fmt.Fprintf(os.Stderr, "Failed to %v tree: %v\n", verb, err)
+		os.Exit(1)
+	}
+}
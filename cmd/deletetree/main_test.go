@@ -0,0 +1,176 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/google/trillian"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func TestRun(t *testing.T) {
+	server, lis, stopFn, err := startFakeServer()
+	if err != nil {
+		t.Fatalf("Error starting fake server: %v", err)
+	}
+	defer stopFn()
+	addr := lis.Addr().String()
+
+	tests := []struct {
+		desc      string
+		opts      *deleteOpts
+		state     trillian.TreeState
+		wantErr   bool
+		wantState trillian.TreeState
+	}{
+		{
+			desc:      "delete",
+			opts:      &deleteOpts{addr: addr, treeID: 12},
+			state:     trillian.TreeState_ACTIVE,
+			wantState: trillian.TreeState_SOFT_DELETED,
+		},
+		{
+			desc:      "undelete",
+			opts:      &deleteOpts{addr: addr, treeID: 12, undelete: true},
+			state:     trillian.TreeState_SOFT_DELETED,
+			wantState: trillian.TreeState_ACTIVE,
+		},
+		{
+			desc:      "undeleteFrozen",
+			opts:      &deleteOpts{addr: addr, treeID: 12, undelete: true},
+			state:     trillian.TreeState_FROZEN,
+			wantErr:   true,
+			wantState: trillian.TreeState_FROZEN,
+		},
+		{
+			desc:      "unknownTree",
+			opts:      &deleteOpts{addr: addr, treeID: 13},
+			state:     trillian.TreeState_ACTIVE,
+			wantErr:   true,
+			wantState: trillian.TreeState_ACTIVE,
+		},
+		{
+			desc:      "emptyAddr",
+			opts:      &deleteOpts{treeID: 12},
+			state:     trillian.TreeState_ACTIVE,
+			wantErr:   true,
+			wantState: trillian.TreeState_ACTIVE,
+		},
+		{
+			desc:      "emptyTreeID",
+			opts:      &deleteOpts{addr: addr},
+			state:     trillian.TreeState_ACTIVE,
+			wantErr:   true,
+			wantState: trillian.TreeState_ACTIVE,
+		},
+	}
+
+	ctx := context.Background()
+	for _, test := range tests {
+		server.tree = &trillian.Tree{TreeId: 12, TreeState: test.state}
+
+		err := run(ctx, test.opts)
+		if hasErr := err != nil; hasErr != test.wantErr {
+			t.Errorf("%v: run() returned err = '%v', wantErr = %v", test.desc, err, test.wantErr)
+		}
+		if got := server.tree.TreeState; got != test.wantState {
+			t.Errorf("%v: tree state = %v, want %v", test.desc, got, test.wantState)
+		}
+	}
+}
+
+// fakeAdminServer holds a single tree and implements GetTree, DeleteTree and
+// UpdateTree of its state. The remaining methods are not implemented.
+type fakeAdminServer struct {
+	tree *trillian.Tree
+}
+
+// startFakeServer starts a fakeAdminServer on a random port.
+// Returns the started server, the listener it's using for connection and a
+// close function that must be defer-called on the scope the server is meant to
+// stop.
+func startFakeServer() (*fakeAdminServer, net.Listener, func(), error) {
+	grpcServer := grpc.NewServer()
+	fakeServer := &fakeAdminServer{}
+	trillian.RegisterTrillianAdminServer(grpcServer, fakeServer)
+
+	lis, err := net.Listen("tcp", "")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	go grpcServer.Serve(lis)
+
+	stopFn := func() {
+		grpcServer.Stop()
+		lis.Close()
+	}
+	return fakeServer, lis, stopFn, nil
+}
+
+func (s *fakeAdminServer) getTree(treeID int64) (*trillian.Tree, error) {
+	if treeID != s.tree.TreeId {
+		return nil, grpc.Errorf(codes.NotFound, "tree %d not found", treeID)
+	}
+	return s.tree, nil
+}
+
+func (s *fakeAdminServer) GetTree(ctx context.Context, req *trillian.GetTreeRequest) (*trillian.Tree, error) {
+	return s.getTree(req.TreeId)
+}
+
+func (s *fakeAdminServer) UpdateTree(ctx context.Context, req *trillian.UpdateTreeRequest) (*trillian.Tree, error) {
+	tree, err := s.getTree(req.GetTree().GetTreeId())
+	if err != nil {
+		return nil, err
+	}
+	if paths := req.GetUpdateMask().GetPaths(); len(paths) != 1 || paths[0] != "tree_state" {
+		return nil, grpc.Errorf(codes.InvalidArgument, "unexpected update_mask: %v", paths)
+	}
+	tree.TreeState = req.Tree.TreeState
+	return tree, nil
+}
+
+func (s *fakeAdminServer) DeleteTree(ctx context.Context, req *trillian.DeleteTreeRequest) (*empty.Empty, error) {
+	tree, err := s.getTree(req.TreeId)
+	if err != nil {
+		return nil, err
+	}
+	tree.TreeState = trillian.TreeState_SOFT_DELETED
+	return &empty.Empty{}, nil
+}
+
+var errUnimplemented = errors.New("unimplemented")
+
+func (s *fakeAdminServer) ListTrees(context.Context, *trillian.ListTreesRequest) (*trillian.ListTreesResponse, error) {
+	return nil, errUnimplemented
+}
+
+func (s *fakeAdminServer) CreateTree(context.Context, *trillian.CreateTreeRequest) (*trillian.Tree, error) {
+	return nil, errUnimplemented
+}
+
+func (s *fakeAdminServer) SequenceLog(context.Context, *trillian.SequenceLogRequest) (*trillian.SequenceLogResponse, error) {
+	return nil, errUnimplemented
+}
+
+func (s *fakeAdminServer) GetTreeStats(context.Context, *trillian.GetTreeStatsRequest) (*trillian.GetTreeStatsResponse, error) {
+	return nil, errUnimplemented
+}
@@ -0,0 +1,270 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package main contains the implementation and entry point for the importlog
+// command, which copies the entries of an existing RFC6962 log into a
+// PREORDERED_LOG Trillian tree.
+//
+// Example usage:
+// $ ./importlog \
+//     --source_url=https://ct.example.com/logs/old \
+//     --log_server=host:port \
+//     --log_id=123 \
+//     --checkpoint=/var/tmp/import-123.checkpoint
+//
+// Entries are fetched with get-entries and written with AddSequencedLeaves,
+// keeping their original indices. After each batch the index of the next
+// entry to import is written to the checkpoint file, so an interrupted import
+// resumes where it left off when run again with the same flags. Once all
+// entries covered by the source STH are written the command waits for the
+// target tree to integrate them and checks that its root hash matches the STH.
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	ct "github.com/google/certificate-transparency/go"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"google.golang.org/grpc"
+)
+
+var (
+	sourceURL      = flag.String("source_url", "", "Base URL of the source RFC6962 log, without the /ct/v1/ suffix")
+	logServerAddr  = flag.String("log_server", "", "Address of the gRPC Trillian Log Server (host:port)")
+	logID          = flag.Int64("log_id", 0, "Tree ID of the PREORDERED_LOG tree to import into")
+	batchSize      = flag.Int("batch_size", 256, "Max number of entries to request from the source per get-entries call")
+	checkpointPath = flag.String("checkpoint", "", "File used to record progress, so an interrupted import can be resumed")
+	pollInterval   = flag.Duration("poll_interval", time.Second*5, "How often to check whether the target tree has integrated all imported entries")
+	verifyTimeout  = flag.Duration("verify_timeout", time.Minute*30, "How long to wait for the target tree to reach the size of the source STH")
+)
+
+// importer copies the entries of an RFC6962 log into a Trillian log.
+type importer struct {
+	source       string
+	httpClient   *http.Client
+	client       trillian.TrillianLogClient
+	logID        int64
+	hasher       merkle.TreeHasher
+	batchSize    int
+	checkpoint   string
+	pollInterval time.Duration
+}
+
+// run imports all entries covered by the current source STH that haven't been
+// imported yet, then verifies the target root against that STH.
+func (i *importer) run(ctx context.Context, verifyTimeout time.Duration) error {
+	var sth ct.GetSTHResponse
+	if err := i.getJSON(ctx, ct.GetSTHPath, &sth); err != nil {
+		return fmt.Errorf("failed to get source STH: %v", err)
+	}
+	glog.Infof("Source STH: size=%d root=%x", sth.TreeSize, sth.SHA256RootHash)
+
+	next, err := readCheckpoint(i.checkpoint)
+	if err != nil {
+		return err
+	}
+	size := int64(sth.TreeSize)
+	if next > size {
+		return fmt.Errorf("checkpoint at %d is beyond source size %d", next, size)
+	}
+
+	for next < size {
+		end := next + int64(i.batchSize)
+		if end > size {
+			end = size
+		}
+		count, err := i.importRange(ctx, next, end)
+		if err != nil {
+			return fmt.Errorf("failed to import entries from %d: %v", next, err)
+		}
+		next += count
+		if err := writeCheckpoint(i.checkpoint, next); err != nil {
+			return err
+		}
+		glog.Infof("Imported %d/%d entries", next, size)
+	}
+
+	vctx, cancel := context.WithTimeout(ctx, verifyTimeout)
+	defer cancel()
+	return i.verifyRoot(vctx, size, sth.SHA256RootHash)
+}
+
+// importRange fetches entries [start, end) from the source and adds them to the
+// target log. Sources may return fewer entries than requested, so the number of
+// entries actually imported is returned.
+func (i *importer) importRange(ctx context.Context, start, end int64) (int64, error) {
+	var rsp ct.GetEntriesResponse
+	path := fmt.Sprintf("%s?start=%d&end=%d", ct.GetEntriesPath, start, end-1)
+	if err := i.getJSON(ctx, path, &rsp); err != nil {
+		return 0, err
+	}
+	if len(rsp.Entries) == 0 {
+		return 0, errors.New("source returned no entries")
+	}
+	if int64(len(rsp.Entries)) > end-start {
+		return 0, fmt.Errorf("source returned %d entries, want at most %d", len(rsp.Entries), end-start)
+	}
+
+	leaves := make([]*trillian.LogLeaf, 0, len(rsp.Entries))
+	for j, entry := range rsp.Entries {
+		identityHash := sha256.Sum256(entry.LeafInput)
+		leaves = append(leaves, &trillian.LogLeaf{
+			LeafIndex:        start + int64(j),
+			LeafValue:        entry.LeafInput,
+			ExtraData:        entry.ExtraData,
+			MerkleLeafHash:   i.hasher.HashLeaf(entry.LeafInput),
+			LeafIdentityHash: identityHash[:],
+		})
+	}
+
+	req := &trillian.AddSequencedLeavesRequest{LogId: i.logID, Leaves: leaves}
+	if _, err := i.client.AddSequencedLeaves(ctx, req); err != nil {
+		return 0, err
+	}
+	return int64(len(leaves)), nil
+}
+
+// verifyRoot waits for the target log to reach size and checks its root hash.
+func (i *importer) verifyRoot(ctx context.Context, size int64, rootHash []byte) error {
+	for {
+		rsp, err := i.client.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{LogId: i.logID})
+		if err != nil {
+			return err
+		}
+		root := rsp.GetSignedLogRoot()
+		switch {
+		case root.GetTreeSize() > size:
+			return fmt.Errorf("target tree has size %d, larger than source size %d", root.GetTreeSize(), size)
+		case root.GetTreeSize() == size:
+			if !bytes.Equal(root.GetRootHash(), rootHash) {
+				return fmt.Errorf("target root hash %x does not match source STH root hash %x at size %d", root.GetRootHash(), rootHash, size)
+			}
+			glog.Infof("Target root matches source STH at size %d", size)
+			return nil
+		}
+
+		glog.Infof("Waiting for target tree to integrate entries: size=%d, want %d", root.GetTreeSize(), size)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("gave up waiting for tree size %d: %v", size, ctx.Err())
+		case <-time.After(i.pollInterval):
+		}
+	}
+}
+
+func (i *importer) getJSON(ctx context.Context, path string, rsp interface{}) error {
+	url := strings.TrimRight(i.source, "/") + path
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	httpRsp, err := i.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer httpRsp.Body.Close()
+
+	body, err := ioutil.ReadAll(httpRsp.Body)
+	if err != nil {
+		return err
+	}
+	if httpRsp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %v: %v: %s", url, httpRsp.Status, body)
+	}
+	return json.Unmarshal(body, rsp)
+}
+
+// readCheckpoint returns the index of the next entry to import. A missing
+// checkpoint file means the import hasn't started yet.
+func readCheckpoint(path string) (int64, error) {
+	if path == "" {
+		return 0, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	next, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("malformed checkpoint file %v: %v", path, err)
+	}
+	return next, nil
+}
+
+// writeCheckpoint records next as the index of the next entry to import. The
+// file is replaced atomically so an interruption can't leave it truncated.
+func writeCheckpoint(path string, next int64) error {
+	if path == "" {
+		return nil
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(strconv.FormatInt(next, 10)+"\n"), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func main() {
+	flag.Parse()
+
+	if *sourceURL == "" {
+		glog.Exitf("Empty --source_url, please provide the base URL of the source log")
+	}
+	if *logServerAddr == "" {
+		glog.Exitf("Empty --log_server, please provide the Log server host:port")
+	}
+
+	hasher, err := merkle.Factory(merkle.RFC6962SHA256Type)
+	if err != nil {
+		glog.Exitf("Failed to create hasher: %v", err)
+	}
+
+	conn, err := grpc.Dial(*logServerAddr, grpc.WithInsecure())
+	if err != nil {
+		glog.Exitf("Failed to dial log server %v: %v", *logServerAddr, err)
+	}
+	defer conn.Close()
+
+	i := &importer{
+		source:       *sourceURL,
+		httpClient:   http.DefaultClient,
+		client:       trillian.NewTrillianLogClient(conn),
+		logID:        *logID,
+		hasher:       hasher,
+		batchSize:    *batchSize,
+		checkpoint:   *checkpointPath,
+		pollInterval: *pollInterval,
+	}
+	if err := i.run(context.Background(), *verifyTimeout); err != nil {
+		glog.Exitf("Import failed: %v", err)
+	}
+	glog.Flush()
+}
@@ -0,0 +1,174 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	ct "github.com/google/certificate-transparency/go"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/mockclient"
+	"github.com/google/trillian/testonly"
+	"golang.org/x/net/context"
+)
+
+const testLogID = 42
+
+// fakeCTLog serves get-sth and get-entries for a fixed set of entries,
+// returning at most maxEntries per get-entries call.
+type fakeCTLog struct {
+	entries    []ct.LeafEntry
+	maxEntries int
+	root       []byte
+}
+
+func newFakeCTLog(size, maxEntries int) *fakeCTLog {
+	l := &fakeCTLog{maxEntries: maxEntries}
+	mt := merkle.NewCompactMerkleTree(testonly.Hasher)
+	for i := 0; i < size; i++ {
+		entry := ct.LeafEntry{LeafInput: []byte(fmt.Sprintf("leaf %d", i)), ExtraData: []byte(fmt.Sprintf("extra %d", i))}
+		l.entries = append(l.entries, entry)
+		mt.AddLeaf(entry.LeafInput, func(int, int64, []byte) {})
+	}
+	l.root = mt.CurrentRoot()
+	return l
+}
+
+func (l *fakeCTLog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var rsp interface{}
+	switch r.URL.Path {
+	case ct.GetSTHPath:
+		rsp = ct.GetSTHResponse{TreeSize: uint64(len(l.entries)), SHA256RootHash: l.root}
+	case ct.GetEntriesPath:
+		start, err := strconv.Atoi(r.URL.Query().Get("start"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		end, err := strconv.Atoi(r.URL.Query().Get("end"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if end >= start+l.maxEntries {
+			end = start + l.maxEntries - 1
+		}
+		rsp = ct.GetEntriesResponse{Entries: l.entries[start : end+1]}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	json.NewEncoder(w).Encode(rsp)
+}
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "importlog")
+	if err != nil {
+		t.Fatalf("TempDir() = %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := newFakeCTLog(10, 3)
+	server := httptest.NewServer(source)
+	defer server.Close()
+
+	tests := []struct {
+		desc       string
+		checkpoint int64
+		targetRoot []byte
+		wantStart  int64
+		wantErr    string
+	}{
+		{desc: "fresh", targetRoot: source.root},
+		{desc: "resume", checkpoint: 7, targetRoot: source.root, wantStart: 7},
+		{desc: "rootMismatch", targetRoot: []byte("bogus"), wantErr: "does not match"},
+	}
+
+	for _, test := range tests {
+		ctrl := gomock.NewController(t)
+		client := mockclient.NewMockTrillianLogClient(ctrl)
+
+		checkpoint := filepath.Join(dir, test.desc)
+		if test.checkpoint > 0 {
+			if err := writeCheckpoint(checkpoint, test.checkpoint); err != nil {
+				t.Fatalf("%v: writeCheckpoint() = %v", test.desc, err)
+			}
+		}
+
+		var added []*trillian.LogLeaf
+		client.EXPECT().AddSequencedLeaves(gomock.Any(), gomock.Any()).AnyTimes().Do(func(_ context.Context, req *trillian.AddSequencedLeavesRequest) {
+			if req.LogId != testLogID {
+				t.Errorf("%v: AddSequencedLeaves() for log %d, want %d", test.desc, req.LogId, testLogID)
+			}
+			added = append(added, req.Leaves...)
+		}).Return(&trillian.AddSequencedLeavesResponse{}, nil)
+		client.EXPECT().GetLatestSignedLogRoot(gomock.Any(), &trillian.GetLatestSignedLogRootRequest{LogId: testLogID}).Return(
+			&trillian.GetLatestSignedLogRootResponse{
+				SignedLogRoot: &trillian.SignedLogRoot{TreeSize: int64(len(source.entries)), RootHash: test.targetRoot},
+			}, nil)
+
+		i := &importer{
+			source:       server.URL,
+			httpClient:   http.DefaultClient,
+			client:       client,
+			logID:        testLogID,
+			hasher:       testonly.Hasher,
+			batchSize:    4,
+			checkpoint:   checkpoint,
+			pollInterval: time.Millisecond,
+		}
+		err := i.run(context.Background(), time.Second)
+		ctrl.Finish()
+
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%v: run() = %v, want err containing %q", test.desc, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: run() = %v, want nil", test.desc, err)
+			continue
+		}
+
+		if got, want := int64(len(added)), int64(len(source.entries))-test.wantStart; got != want {
+			t.Errorf("%v: imported %d leaves, want %d", test.desc, got, want)
+		}
+		for j, leaf := range added {
+			idx := test.wantStart + int64(j)
+			if leaf.LeafIndex != idx {
+				t.Errorf("%v: leaf %d has LeafIndex %d, want %d", test.desc, j, leaf.LeafIndex, idx)
+			}
+			if got, want := string(leaf.LeafValue), string(source.entries[idx].LeafInput); got != want {
+				t.Errorf("%v: leaf %d has LeafValue %q, want %q", test.desc, idx, got, want)
+			}
+		}
+		if next, err := readCheckpoint(checkpoint); err != nil || next != int64(len(source.entries)) {
+			t.Errorf("%v: readCheckpoint() = (%v, %v), want (%v, nil)", test.desc, next, err, len(source.entries))
+		}
+	}
+}
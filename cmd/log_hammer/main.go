@@ -0,0 +1,548 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package main contains the implementation and entry point for the
+// log_hammer command, which is a load test for a Trillian log.
+//
+// Example usage:
+// $ ./log_hammer \
+//     --log_server=host:port \
+//     --log_id=123 \
+//     --qps=200 \
+//     --duration=10m
+//
+// Operations are picked at random according to their biases and issued at the
+// requested rate by a pool of workers. Every response is verified: leaves must
+// hash to their Merkle leaf hash, inclusion proofs must match the latest root
+// and roots must never shrink. The latency of each operation is reported
+// periodically, and the command exits non-zero if any response failed
+// verification.
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+var (
+	logServerAddr  = flag.String("log_server", "", "Address of the gRPC Trillian Log Server (host:port)")
+	logID          = flag.Int64("log_id", 0, "Tree ID of the log to hammer")
+	qps            = flag.Float64("qps", 10, "Number of operations to start per second")
+	workers        = flag.Int("workers", 10, "Max number of operations in flight")
+	duration       = flag.Duration("duration", 0, "How long to run for, 0 means until interrupted")
+	operations     = flag.Uint64("operations", 0, "Number of operations to perform, 0 means no limit")
+	batchSize      = flag.Int("batch_size", 10, "Number of leaves per QueueLeaves call")
+	maxLeaves      = flag.Int("max_leaves", 10, "Max number of leaves per GetLeavesByIndex call")
+	reportInterval = flag.Duration("report_interval", 10*time.Second, "Interval between latency reports")
+	seed           = flag.Int64("seed", -1, "Seed for random number generation")
+)
+
+var (
+	queueLeavesBias       = flag.Int("queue_leaves", 10, "Bias for QueueLeaves operations")
+	getRootBias           = flag.Int("get_latest_signed_log_root", 2, "Bias for GetLatestSignedLogRoot operations")
+	getInclusionProofBias = flag.Int("get_inclusion_proof", 5, "Bias for GetInclusionProof operations")
+	getLeavesByIndexBias  = flag.Int("get_leaves_by_index", 5, "Bias for GetLeavesByIndex operations")
+)
+
+// opName identifies an operation performed by the hammer.
+type opName string
+
+const (
+	queueLeavesOp       = opName("QueueLeaves")
+	getRootOp           = opName("GetLatestSignedLogRoot")
+	getInclusionProofOp = opName("GetInclusionProof")
+	getLeavesByIndexOp  = opName("GetLeavesByIndex")
+)
+
+// ops lists every operation, in the order they're reported.
+var ops = []opName{queueLeavesOp, getRootOp, getInclusionProofOp, getLeavesByIndexOp}
+
+// knownLeafCount is the number of leaf hashes remembered as targets for
+// inclusion proofs.
+const knownLeafCount = 1000
+
+// bias holds the relative frequency of each operation.
+type bias map[opName]int
+
+// validate checks that no bias is negative and that at least one operation
+// is enabled.
+func (b bias) validate() error {
+	total := 0
+	for _, op := range ops {
+		if b[op] < 0 {
+			return fmt.Errorf("negative bias %d for %v", b[op], op)
+		}
+		total += b[op]
+	}
+	if total == 0 {
+		return fmt.Errorf("no operations enabled")
+	}
+	return nil
+}
+
+// choose randomly picks an operation according to the biases.
+func (b bias) choose(r *rand.Rand) opName {
+	total := 0
+	for _, op := range ops {
+		total += b[op]
+	}
+	which := r.Intn(total)
+	for _, op := range ops {
+		which -= b[op]
+		if which < 0 {
+			return op
+		}
+	}
+	panic("random choice out of range")
+}
+
+// errSkip indicates that an operation was skipped because the hammer doesn't
+// know enough about the log yet, e.g. there's no root to request leaves under.
+type errSkip struct{}
+
+func (e errSkip) Error() string {
+	return "test operation skipped"
+}
+
+// verifyError indicates that the log returned a response which failed
+// verification.
+type verifyError struct {
+	msg string
+}
+
+func (e verifyError) Error() string {
+	return e.msg
+}
+
+func verifyErrorf(format string, args ...interface{}) error {
+	return verifyError{msg: fmt.Sprintf(format, args...)}
+}
+
+// opStats records the outcomes of an operation. Latencies are kept for the
+// current report interval only.
+type opStats struct {
+	count, errors, skipped, invalid int
+	latencies                       []time.Duration
+}
+
+// percentile returns the latency below which p percent of the sorted
+// latencies fall.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := (len(sorted)*p+99)/100 - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+// knownLeaf is a leaf the hammer has seen in the log.
+type knownLeaf struct {
+	index    int64
+	leafHash []byte
+}
+
+// hammer issues operations against a log and verifies the responses.
+type hammer struct {
+	client    trillian.TrillianLogClient
+	logID     int64
+	hasher    merkle.TreeHasher
+	verifier  merkle.LogVerifier
+	bias      bias
+	batchSize int
+	maxLeaves int
+
+	mu      sync.Mutex
+	rand    *rand.Rand
+	root    *trillian.SignedLogRoot
+	known   []knownLeaf
+	queued  int64
+	stats   map[opName]*opStats
+	dropped int
+}
+
+func newHammer(client trillian.TrillianLogClient, logID int64, hasher merkle.TreeHasher, b bias, batchSize, maxLeaves int, seed int64) *hammer {
+	stats := make(map[opName]*opStats)
+	for _, op := range ops {
+		stats[op] = &opStats{}
+	}
+	return &hammer{
+		client:    client,
+		logID:     logID,
+		hasher:    hasher,
+		verifier:  merkle.NewLogVerifier(hasher),
+		bias:      b,
+		batchSize: batchSize,
+		maxLeaves: maxLeaves,
+		rand:      rand.New(rand.NewSource(seed)),
+		stats:     stats,
+	}
+}
+
+// run starts operations at the rate of qps on up to workers goroutines, until
+// ctx is done or count operations have been started. A count of 0 means no
+// limit. Ticks arriving while every worker is busy are dropped, and counted so
+// the achieved rate can be told apart from the requested one.
+func (h *hammer) run(ctx context.Context, qps float64, workers int, count uint64) {
+	ticks := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range ticks {
+				h.perform(ctx)
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / qps))
+	defer ticker.Stop()
+loop:
+	for started := uint64(0); count == 0 || started < count; {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-ticker.C:
+		}
+		select {
+		case ticks <- struct{}{}:
+			started++
+		default:
+			h.mu.Lock()
+			h.dropped++
+			h.mu.Unlock()
+		}
+	}
+	close(ticks)
+	wg.Wait()
+}
+
+// perform runs a randomly chosen operation and records its outcome.
+func (h *hammer) perform(ctx context.Context) {
+	h.mu.Lock()
+	op := h.bias.choose(h.rand)
+	h.mu.Unlock()
+
+	var latency time.Duration
+	var err error
+	switch op {
+	case queueLeavesOp:
+		latency, err = h.queueLeaves(ctx)
+	case getRootOp:
+		latency, err = h.getRoot(ctx)
+	case getInclusionProofOp:
+		latency, err = h.getInclusionProof(ctx)
+	case getLeavesByIndexOp:
+		latency, err = h.getLeavesByIndex(ctx)
+	}
+
+	if _, ok := err.(verifyError); !ok && err != nil && ctx.Err() != nil {
+		// Operations cut short by the end of the run don't count as failures.
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.stats[op]
+	switch err.(type) {
+	case nil:
+		s.count++
+		s.latencies = append(s.latencies, latency)
+	case errSkip:
+		s.skipped++
+	case verifyError:
+		s.count++
+		s.invalid++
+		s.latencies = append(s.latencies, latency)
+		glog.Errorf("%v: %v", op, err)
+	default:
+		s.errors++
+		glog.Warningf("%v: %v", op, err)
+	}
+}
+
+func (h *hammer) queueLeaves(ctx context.Context) (time.Duration, error) {
+	h.mu.Lock()
+	first := h.queued
+	h.queued += int64(h.batchSize)
+	nonce := h.rand.Int63()
+	h.mu.Unlock()
+
+	leaves := make([]*trillian.LogLeaf, 0, h.batchSize)
+	for i := 0; i < h.batchSize; i++ {
+		value := []byte(fmt.Sprintf("log_hammer %x %d", nonce, first+int64(i)))
+		identityHash := sha256.Sum256(value)
+		leaves = append(leaves, &trillian.LogLeaf{
+			LeafValue:        value,
+			MerkleLeafHash:   h.hasher.HashLeaf(value),
+			LeafIdentityHash: identityHash[:],
+		})
+	}
+
+	start := time.Now()
+	rsp, err := h.client.QueueLeaves(ctx, &trillian.QueueLeavesRequest{LogId: h.logID, Leaves: leaves})
+	latency := time.Since(start)
+	if err != nil {
+		return 0, err
+	}
+	if got, want := len(rsp.QueuedLeaves), len(leaves); got != want {
+		return latency, verifyErrorf("got %d queued leaves, want %d", got, want)
+	}
+	for i, l := range rsp.QueuedLeaves {
+		if c := codes.Code(l.GetStatus().GetCode()); c != codes.OK {
+			return latency, fmt.Errorf("leaf %d not queued: %v: %v", i, c, l.GetStatus().GetMessage())
+		}
+		if !bytes.Equal(l.GetLeaf().GetLeafValue(), leaves[i].LeafValue) {
+			return latency, verifyErrorf("queued leaf %d has value %q, want %q", i, l.GetLeaf().GetLeafValue(), leaves[i].LeafValue)
+		}
+	}
+	return latency, nil
+}
+
+func (h *hammer) getRoot(ctx context.Context) (time.Duration, error) {
+	// Responses to concurrent requests can arrive out of order, so this one is
+	// only checked against the roots seen before it was sent.
+	h.mu.Lock()
+	prev := h.root
+	h.mu.Unlock()
+
+	start := time.Now()
+	rsp, err := h.client.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{LogId: h.logID})
+	latency := time.Since(start)
+	if err != nil {
+		return 0, err
+	}
+	root := rsp.GetSignedLogRoot()
+	if root == nil {
+		return latency, verifyErrorf("no root returned")
+	}
+
+	if prev != nil && root.TreeSize < prev.TreeSize {
+		return latency, verifyErrorf("tree size went down from %d to %d", prev.TreeSize, root.TreeSize)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.root == nil || root.TreeSize >= h.root.TreeSize {
+		h.root = root
+	}
+	return latency, nil
+}
+
+func (h *hammer) getInclusionProof(ctx context.Context) (time.Duration, error) {
+	h.mu.Lock()
+	root := h.root
+	var leaf knownLeaf
+	if len(h.known) > 0 {
+		leaf = h.known[h.rand.Intn(len(h.known))]
+	}
+	h.mu.Unlock()
+	if root == nil || leaf.leafHash == nil {
+		return 0, errSkip{}
+	}
+
+	start := time.Now()
+	rsp, err := h.client.GetInclusionProof(ctx, &trillian.GetInclusionProofRequest{
+		LogId:     h.logID,
+		LeafIndex: leaf.index,
+		TreeSize:  root.TreeSize,
+	})
+	latency := time.Since(start)
+	if err != nil {
+		return 0, err
+	}
+	var proof [][]byte
+	for _, n := range rsp.GetProof().GetProofNode() {
+		proof = append(proof, n.NodeHash)
+	}
+	if err := h.verifier.VerifyInclusionProof(leaf.index, root.TreeSize, proof, root.RootHash, leaf.leafHash); err != nil {
+		return latency, verifyErrorf("inclusion proof for leaf %d at size %d: %v", leaf.index, root.TreeSize, err)
+	}
+	return latency, nil
+}
+
+func (h *hammer) getLeavesByIndex(ctx context.Context) (time.Duration, error) {
+	h.mu.Lock()
+	root := h.root
+	var indices []int64
+	if root != nil && root.TreeSize > 0 {
+		count := 1 + h.rand.Intn(h.maxLeaves)
+		if int64(count) > root.TreeSize {
+			count = int(root.TreeSize)
+		}
+		first := h.rand.Int63n(root.TreeSize - int64(count) + 1)
+		for i := 0; i < count; i++ {
+			indices = append(indices, first+int64(i))
+		}
+	}
+	h.mu.Unlock()
+	if len(indices) == 0 {
+		return 0, errSkip{}
+	}
+
+	start := time.Now()
+	rsp, err := h.client.GetLeavesByIndex(ctx, &trillian.GetLeavesByIndexRequest{LogId: h.logID, LeafIndex: indices})
+	latency := time.Since(start)
+	if err != nil {
+		return 0, err
+	}
+	if got, want := len(rsp.Leaves), len(indices); got != want {
+		return latency, verifyErrorf("got %d leaves for indices %v, want %d", got, indices, want)
+	}
+	for i, l := range rsp.Leaves {
+		if l.LeafIndex != indices[i] {
+			return latency, verifyErrorf("got leaf %d for index %d", l.LeafIndex, indices[i])
+		}
+		// The values of expired leaves have been pruned, so only their hashes remain.
+		if !l.Expired {
+			if want := h.hasher.HashLeaf(l.LeafValue); !bytes.Equal(l.MerkleLeafHash, want) {
+				return latency, verifyErrorf("leaf %d has Merkle leaf hash %x, want %x", l.LeafIndex, l.MerkleLeafHash, want)
+			}
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, l := range rsp.Leaves {
+		leaf := knownLeaf{index: l.LeafIndex, leafHash: l.MerkleLeafHash}
+		if len(h.known) < knownLeafCount {
+			h.known = append(h.known, leaf)
+		} else {
+			h.known[h.rand.Intn(knownLeafCount)] = leaf
+		}
+	}
+	return latency, nil
+}
+
+// report prints the outcomes of each operation since the last report, and
+// returns the number of responses which failed verification.
+func (h *hammer) report(interval time.Duration) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	treeSize := int64(0)
+	if h.root != nil {
+		treeSize = h.root.TreeSize
+	}
+	fmt.Printf("%v: tree_size=%d dropped=%d\n", h.logID, treeSize, h.dropped)
+	invalid := 0
+	for _, op := range ops {
+		if h.bias[op] == 0 {
+			continue
+		}
+		s := h.stats[op]
+		sort.Slice(s.latencies, func(i, j int) bool { return s.latencies[i] < s.latencies[j] })
+		fmt.Printf("  %-24s qps=%.1f ok=%d errors=%d skipped=%d invalid=%d p50=%v p90=%v p99=%v max=%v\n",
+			op, float64(s.count)/interval.Seconds(), s.count-s.invalid, s.errors, s.skipped, s.invalid,
+			percentile(s.latencies, 50), percentile(s.latencies, 90), percentile(s.latencies, 99), percentile(s.latencies, 100))
+		invalid += s.invalid
+		h.stats[op] = &opStats{}
+	}
+	h.dropped = 0
+	return invalid
+}
+
+func main() {
+	flag.Parse()
+
+	if *logServerAddr == "" {
+		glog.Exitf("Empty --log_server, please provide the Log server host:port")
+	}
+	if *qps <= 0 {
+		glog.Exitf("Invalid --qps %v", *qps)
+	}
+	if *workers <= 0 {
+		glog.Exitf("Invalid --workers %d", *workers)
+	}
+	if *batchSize <= 0 {
+		glog.Exitf("Invalid --batch_size %d", *batchSize)
+	}
+	if *maxLeaves <= 0 {
+		glog.Exitf("Invalid --max_leaves %d", *maxLeaves)
+	}
+	b := bias{
+		queueLeavesOp:       *queueLeavesBias,
+		getRootOp:           *getRootBias,
+		getInclusionProofOp: *getInclusionProofBias,
+		getLeavesByIndexOp:  *getLeavesByIndexBias,
+	}
+	if err := b.validate(); err != nil {
+		glog.Exitf("Invalid biases: %v", err)
+	}
+	if *seed == -1 {
+		*seed = time.Now().UTC().UnixNano() & 0xFFFFFFFF
+	}
+	glog.Infof("Using seed %#x", *seed)
+
+	hasher, err := merkle.Factory(merkle.RFC6962SHA256Type)
+	if err != nil {
+		glog.Exitf("Failed to create hasher: %v", err)
+	}
+
+	conn, err := grpc.Dial(*logServerAddr, grpc.WithInsecure())
+	if err != nil {
+		glog.Exitf("Failed to dial log server %v: %v", *logServerAddr, err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go util.AwaitSignal(cancel)
+	if *duration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	h := newHammer(trillian.NewTrillianLogClient(conn), *logID, hasher, b, *batchSize, *maxLeaves, *seed)
+	done := make(chan struct{})
+	go func() {
+		h.run(ctx, *qps, *workers, *operations)
+		close(done)
+	}()
+
+	invalid := 0
+	ticker := time.NewTicker(*reportInterval)
+	defer ticker.Stop()
+	last := time.Now()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		case <-ticker.C:
+		}
+		now := time.Now()
+		invalid += h.report(now.Sub(last))
+		last = now
+	}
+	glog.Flush()
+	if invalid > 0 {
+		fmt.Printf("%d responses failed verification\n", invalid)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/testonly"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+const testLogID = 42
+
+// fakeLogClient serves a two leaf log, whose leaves can be replaced by tests.
+type fakeLogClient struct {
+	trillian.TrillianLogClient
+	leaves [][]byte
+	root   *trillian.SignedLogRoot
+}
+
+func newFakeLogClient() *fakeLogClient {
+	leaves := [][]byte{[]byte("leaf 0"), []byte("leaf 1")}
+	root := testonly.Hasher.HashChildren(testonly.Hasher.HashLeaf(leaves[0]), testonly.Hasher.HashLeaf(leaves[1]))
+	return &fakeLogClient{
+		leaves: leaves,
+		root:   &trillian.SignedLogRoot{LogId: testLogID, TreeSize: 2, RootHash: root},
+	}
+}
+
+func (c *fakeLogClient) GetLatestSignedLogRoot(ctx context.Context, req *trillian.GetLatestSignedLogRootRequest, opts ...grpc.CallOption) (*trillian.GetLatestSignedLogRootResponse, error) {
+	return &trillian.GetLatestSignedLogRootResponse{SignedLogRoot: c.root}, nil
+}
+
+func (c *fakeLogClient) GetLeavesByIndex(ctx context.Context, req *trillian.GetLeavesByIndexRequest, opts ...grpc.CallOption) (*trillian.GetLeavesByIndexResponse, error) {
+	rsp := &trillian.GetLeavesByIndexResponse{}
+	for _, i := range req.LeafIndex {
+		rsp.Leaves = append(rsp.Leaves, &trillian.LogLeaf{
+			LeafIndex:      i,
+			LeafValue:      c.leaves[i],
+			MerkleLeafHash: testonly.Hasher.HashLeaf(c.leaves[i]),
+		})
+	}
+	return rsp, nil
+}
+
+func (c *fakeLogClient) GetInclusionProof(ctx context.Context, req *trillian.GetInclusionProofRequest, opts ...grpc.CallOption) (*trillian.GetInclusionProofResponse, error) {
+	sibling := c.leaves[1-req.LeafIndex]
+	return &trillian.GetInclusionProofResponse{Proof: &trillian.Proof{
+		LeafIndex: req.LeafIndex,
+		ProofNode: []*trillian.Node{{NodeHash: testonly.Hasher.HashLeaf(sibling)}},
+	}}, nil
+}
+
+func TestBias(t *testing.T) {
+	tests := []struct {
+		desc    string
+		bias    bias
+		wantErr bool
+	}{
+		{desc: "single", bias: bias{getRootOp: 1}},
+		{desc: "mixed", bias: bias{queueLeavesOp: 3, getLeavesByIndexOp: 1}},
+		{desc: "none", bias: bias{}, wantErr: true},
+		{desc: "negative", bias: bias{queueLeavesOp: 2, getRootOp: -1}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		err := test.bias.validate()
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("%v: validate() = %v, want err %v", test.desc, err, test.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		r := rand.New(rand.NewSource(1))
+		for i := 0; i < 100; i++ {
+			if op := test.bias.choose(r); test.bias[op] == 0 {
+				t.Errorf("%v: choose() = %v, which has no bias", test.desc, op)
+			}
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var latencies []time.Duration
+	for i := 1; i <= 10; i++ {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+
+	tests := []struct {
+		latencies []time.Duration
+		p         int
+		want      time.Duration
+	}{
+		{latencies: nil, p: 50, want: 0},
+		{latencies: latencies, p: 0, want: time.Millisecond},
+		{latencies: latencies, p: 50, want: 5 * time.Millisecond},
+		{latencies: latencies, p: 99, want: 10 * time.Millisecond},
+		{latencies: latencies, p: 100, want: 10 * time.Millisecond},
+	}
+
+	for _, test := range tests {
+		if got := percentile(test.latencies, test.p); got != test.want {
+			t.Errorf("percentile(%v, %d) = %v, want %v", test.latencies, test.p, got, test.want)
+		}
+	}
+}
+
+func TestVerification(t *testing.T) {
+	tests := []struct {
+		desc string
+		// corrupt modifies the log after the hammer has learned its leaves.
+		corrupt   func(c *fakeLogClient)
+		wantValid bool
+	}{
+		{desc: "valid", corrupt: func(c *fakeLogClient) {}, wantValid: true},
+		{desc: "leafChanged", corrupt: func(c *fakeLogClient) { c.leaves[0], c.leaves[1] = []byte("other"), []byte("other") }},
+		{desc: "rootChanged", corrupt: func(c *fakeLogClient) { c.root = &trillian.SignedLogRoot{TreeSize: 2, RootHash: []byte("bad")} }},
+		{desc: "treeShrunk", corrupt: func(c *fakeLogClient) { c.root = &trillian.SignedLogRoot{TreeSize: 1} }},
+	}
+
+	ctx := context.Background()
+	for _, test := range tests {
+		client := newFakeLogClient()
+		h := newHammer(client, testLogID, testonly.Hasher, bias{getRootOp: 1}, 1, 2, 1)
+
+		if _, err := h.getInclusionProof(ctx); err != (errSkip{}) {
+			t.Errorf("%v: getInclusionProof() before any root = %v, want errSkip", test.desc, err)
+		}
+		if _, err := h.getRoot(ctx); err != nil {
+			t.Fatalf("%v: getRoot() = %v", test.desc, err)
+		}
+		for len(h.known) < 2 {
+			if _, err := h.getLeavesByIndex(ctx); err != nil {
+				t.Fatalf("%v: getLeavesByIndex() = %v", test.desc, err)
+			}
+		}
+
+		test.corrupt(client)
+		var invalid bool
+		for _, op := range []func(context.Context) (time.Duration, error){h.getRoot, h.getLeavesByIndex, h.getInclusionProof} {
+			_, err := op(ctx)
+			if _, ok := err.(verifyError); ok {
+				invalid = true
+			} else if err != nil {
+				t.Errorf("%v: got error %v, want nil or verifyError", test.desc, err)
+			}
+		}
+		if invalid == test.wantValid {
+			t.Errorf("%v: got invalid response %v, want %v", test.desc, invalid, !test.wantValid)
+		}
+	}
+}
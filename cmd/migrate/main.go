@@ -0,0 +1,86 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package main contains the implementation and entry point for the migrate
+// command, which brings a MySQL storage database up to a schema version.
+//
+// Example usage:
+// $ ./migrate \
+//     --mysql_uri=user:pass@tcp(127.0.0.1:3306)/trillian \
+//     --target_version=0
+//
+// A --target_version of 0 applies every pending migration. Pass --verify_only
+// to check the database against the current schema without changing it.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	_ "github.com/go-sql-driver/mysql" // Load MySQL driver
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/storage/mysql"
+)
+
+var (
+	mySQLURI      = flag.String("mysql_uri", "test:zaphod@tcp(127.0.0.1:3306)/test", "Connection URI for MySQL database")
+	targetVersion = flag.Int("target_version", 0, "Schema version to migrate to, or 0 for the latest")
+	verifyOnly    = flag.Bool("verify_only", false, "If true, only check that the database matches the latest schema")
+	list          = flag.Bool("list", false, "If true, list the known migrations and exit")
+)
+
+func main() {
+	flag.Parse()
+
+	if *list {
+		for _, m := range mysql.Migrations() {
+			fmt.Printf("%04d %v\n", m.Version, m.Name)
+		}
+		return
+	}
+
+	db, err := mysql.OpenDB(*mySQLURI)
+	if err != nil {
+		glog.Exitf("Failed to open MySQL database: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	version, err := mysql.SchemaVersion(ctx, db)
+	if err != nil {
+		glog.Exitf("Failed to read schema version: %v", err)
+	}
+	fmt.Printf("Schema version: %d (latest %d)\n", version, mysql.LatestSchemaVersion())
+
+	if !*verifyOnly {
+		applied, err := mysql.Migrate(ctx, db, *targetVersion)
+		if err != nil {
+			glog.Exitf("Failed to migrate schema: %v", err)
+		}
+		for _, m := range applied {
+			fmt.Printf("Applied migration %04d %v\n", m.Version, m.Name)
+		}
+		if *targetVersion != 0 && *targetVersion != mysql.LatestSchemaVersion() {
+			// An older schema won't match the one VerifySchema expects.
+			return
+		}
+	}
+
+	if err := mysql.VerifySchema(ctx, db); err != nil {
+		glog.Exitf("Schema doesn't match version %d: %v", mysql.LatestSchemaVersion(), err)
+	}
+	fmt.Println("Schema is up to date")
+}
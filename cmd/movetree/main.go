@@ -0,0 +1,78 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package main contains the implementation and entry point for the movetree
+// command, which moves a tree between the database shards of a Trillian
+// deployment to rebalance them.
+//
+// Example usage:
+// $ ./movetree \
+//     --mysql_uri=user:pass@tcp(127.0.0.1:3306)/trillian \
+//     --mysql_shards_file=shards.txt \
+//     --tree_id=123456 \
+//     --to_shard=shard2
+//
+// The tree must be FROZEN first, e.g. with updatetree, and a log must then be
+// left for the signer to finalize. The tree can be set back to ACTIVE once it
+// has been moved. --mysql_uri and --mysql_shards_file must be the ones the log
+// servers and signers use.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	_ "github.com/go-sql-driver/mysql" // Load MySQL driver
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/storage/mysql"
+)
+
+var (
+	mySQLURI        = flag.String("mysql_uri", "test:zaphod@tcp(127.0.0.1:3306)/test", "Connection URI for the primary shard's MySQL database")
+	mySQLShardsFile = flag.String("mysql_shards_file", "", "File of the other shards, one per line as: name mysql_uri")
+	treeID          = flag.Int64("tree_id", 0, "ID of the tree to move")
+	toShard         = flag.String("to_shard", "", "Name of the shard to move the tree to, "+mysql.PrimaryShardName+" for the primary shard")
+)
+
+func main() {
+	flag.Parse()
+
+	if *treeID == 0 || *toShard == "" {
+		glog.Exit("--tree_id and --to_shard are required")
+	}
+	if *mySQLShardsFile == "" {
+		glog.Exit("--mysql_shards_file is required")
+	}
+
+	db, err := mysql.OpenDB(*mySQLURI)
+	if err != nil {
+		glog.Exitf("Failed to open MySQL database: %v", err)
+	}
+	defer db.Close()
+	shards, err := mysql.OpenDatabaseShards(*mySQLShardsFile, mysql.DBOptions{})
+	if err != nil {
+		glog.Exitf("Failed to open shard databases: %v", err)
+	}
+	for _, s := range shards {
+		defer s.DB.Close()
+	}
+
+	copied, err := mysql.MoveTree(context.Background(), db, shards, *treeID, *toShard)
+	if err != nil {
+		glog.Exitf("Failed to move tree %v: %v", *treeID, err)
+	}
+	fmt.Printf("Moved tree %v to %v (%d rows)\n", *treeID, *toShard, copied)
+}
@@ -0,0 +1,281 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package main contains the implementation and entry point for the
+// queue_from_kafka command, which consumes the records of a Kafka topic and
+// queues them as leaves into a Trillian log.
+//
+// Example usage:
+// $ ./queue_from_kafka \
+//     --kafka_brokers=kafka-1:9092,kafka-2:9092 \
+//     --topic=entries \
+//     --log_server=host:port \
+//     --log_id=123 \
+//     --checkpoint=/var/tmp/kafka-123.checkpoint
+//
+// Each record's value becomes a leaf's LeafValue. The leaf identity hash is the
+// SHA-256 of the record's key if it has one, and of its value otherwise. After
+// each batch has been queued the next offset of every partition is written to
+// the checkpoint file, and consumption resumes from there when the command is
+// restarted. Records consumed after the last checkpoint are queued again, which
+// is harmless: the log recognizes them by their identity hash and doesn't add
+// them twice.
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+var (
+	kafkaBrokers   = flag.String("kafka_brokers", "localhost:9092", "Comma separated list of Kafka brokers")
+	topic          = flag.String("topic", "", "Kafka topic to consume records from")
+	logServerAddr  = flag.String("log_server", "", "Address of the gRPC Trillian Log Server (host:port)")
+	logID          = flag.Int64("log_id", 0, "Tree ID of the log to queue leaves into")
+	batchSize      = flag.Int("batch_size", 100, "Max number of records to queue per QueueLeaves call")
+	flushInterval  = flag.Duration("flush_interval", time.Second, "Max time a consumed record waits before being queued")
+	retryBackoff   = flag.Duration("retry_backoff", time.Second*5, "Time to wait before retrying a batch which couldn't be queued")
+	checkpointPath = flag.String("checkpoint", "", "File used to record the consumed offsets, so consumption resumes after a restart")
+)
+
+// checkpoint holds the offset of the next record to consume from each partition.
+type checkpoint map[int32]int64
+
+// bridge queues the records of a Kafka topic as leaves in a Trillian log.
+type bridge struct {
+	consumer      sarama.Consumer
+	topic         string
+	client        trillian.TrillianLogClient
+	logID         int64
+	hasher        merkle.TreeHasher
+	batchSize     int
+	flushInterval time.Duration
+	retryBackoff  time.Duration
+	checkpoint    string
+}
+
+// run consumes every partition of the topic, starting from the checkpointed
+// offsets, until ctx is done.
+func (b *bridge) run(ctx context.Context) error {
+	offsets, err := readCheckpoint(b.checkpoint)
+	if err != nil {
+		return err
+	}
+	partitions, err := b.consumer.Partitions(b.topic)
+	if err != nil {
+		return fmt.Errorf("failed to list partitions of %v: %v", b.topic, err)
+	}
+
+	msgs := make(chan *sarama.ConsumerMessage)
+	for _, p := range partitions {
+		offset, ok := offsets[p]
+		if !ok {
+			offset = sarama.OffsetOldest
+		}
+		pc, err := b.consumer.ConsumePartition(b.topic, p, offset)
+		if err != nil {
+			return fmt.Errorf("failed to consume partition %d from offset %d: %v", p, offset, err)
+		}
+		defer pc.Close()
+		go func() {
+			for m := range pc.Messages() {
+				select {
+				case msgs <- m:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(b.flushInterval)
+	defer ticker.Stop()
+	var batch []*sarama.ConsumerMessage
+	for {
+		select {
+		case <-ctx.Done():
+			// Unqueued records are consumed again on restart.
+			return nil
+		case m := <-msgs:
+			if batch = append(batch, m); len(batch) < b.batchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+
+		if err := b.queueWithRetry(ctx, batch); err != nil {
+			return nil
+		}
+		for _, m := range batch {
+			if m.Offset >= offsets[m.Partition] {
+				offsets[m.Partition] = m.Offset + 1
+			}
+		}
+		if err := writeCheckpoint(b.checkpoint, offsets); err != nil {
+			return err
+		}
+		glog.V(1).Infof("Queued %d records, offsets now %v", len(batch), offsets)
+		batch = nil
+	}
+}
+
+// queueWithRetry queues batch, retrying until it succeeds or ctx is done.
+func (b *bridge) queueWithRetry(ctx context.Context, batch []*sarama.ConsumerMessage) error {
+	for {
+		err := b.queue(ctx, batch)
+		if err == nil {
+			return nil
+		}
+		glog.Warningf("Failed to queue %d records, retrying in %v: %v", len(batch), b.retryBackoff, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(b.retryBackoff):
+		}
+	}
+}
+
+// queue adds the records in batch to the log. Leaves the log already holds
+// count as queued.
+func (b *bridge) queue(ctx context.Context, batch []*sarama.ConsumerMessage) error {
+	leaves := make([]*trillian.LogLeaf, 0, len(batch))
+	for _, m := range batch {
+		identity := m.Value
+		if len(m.Key) > 0 {
+			identity = m.Key
+		}
+		identityHash := sha256.Sum256(identity)
+		leaves = append(leaves, &trillian.LogLeaf{
+			LeafValue:        m.Value,
+			MerkleLeafHash:   b.hasher.HashLeaf(m.Value),
+			LeafIdentityHash: identityHash[:],
+		})
+	}
+
+	rsp, err := b.client.QueueLeaves(ctx, &trillian.QueueLeavesRequest{LogId: b.logID, Leaves: leaves})
+	if err != nil {
+		return err
+	}
+	for _, l := range rsp.QueuedLeaves {
+		if c := codes.Code(l.GetStatus().GetCode()); c != codes.OK && c != codes.AlreadyExists {
+			return fmt.Errorf("leaf not queued: %v: %v", c, l.GetStatus().GetMessage())
+		}
+	}
+	return nil
+}
+
+// readCheckpoint returns the checkpointed offsets. A missing checkpoint file
+// means nothing has been consumed yet.
+func readCheckpoint(path string) (checkpoint, error) {
+	offsets := make(checkpoint)
+	if path == "" {
+		return offsets, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return offsets, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &offsets); err != nil {
+		return nil, fmt.Errorf("malformed checkpoint file %v: %v", path, err)
+	}
+	return offsets, nil
+}
+
+// writeCheckpoint records offsets. The file is replaced atomically so an
+// interruption can't leave it truncated.
+func writeCheckpoint(path string, offsets checkpoint) error {
+	if path == "" {
+		return nil
+	}
+	data, err := json.Marshal(offsets)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func main() {
+	flag.Parse()
+
+	if *topic == "" {
+		glog.Exitf("Empty --topic, please provide the Kafka topic to consume")
+	}
+	if *logServerAddr == "" {
+		glog.Exitf("Empty --log_server, please provide the Log server host:port")
+	}
+	if *batchSize <= 0 {
+		glog.Exitf("Invalid --batch_size %d", *batchSize)
+	}
+
+	hasher, err := merkle.Factory(merkle.RFC6962SHA256Type)
+	if err != nil {
+		glog.Exitf("Failed to create hasher: %v", err)
+	}
+
+	consumer, err := sarama.NewConsumer(strings.Split(*kafkaBrokers, ","), sarama.NewConfig())
+	if err != nil {
+		glog.Exitf("Failed to connect to Kafka brokers %v: %v", *kafkaBrokers, err)
+	}
+	defer consumer.Close()
+
+	conn, err := grpc.Dial(*logServerAddr, grpc.WithInsecure())
+	if err != nil {
+		glog.Exitf("Failed to dial log server %v: %v", *logServerAddr, err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go util.AwaitSignal(cancel)
+
+	b := &bridge{
+		consumer:      consumer,
+		topic:         *topic,
+		client:        trillian.NewTrillianLogClient(conn),
+		logID:         *logID,
+		hasher:        hasher,
+		batchSize:     *batchSize,
+		flushInterval: *flushInterval,
+		retryBackoff:  *retryBackoff,
+		checkpoint:    *checkpointPath,
+	}
+	if err := b.run(ctx); err != nil {
+		glog.Exitf("Bridge failed: %v", err)
+	}
+	glog.Flush()
+}
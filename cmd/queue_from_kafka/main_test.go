@@ -0,0 +1,187 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/google/trillian"
+	"github.com/google/trillian/testonly"
+	"golang.org/x/net/context"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	testLogID = 42
+	testTopic = "entries"
+)
+
+// fakeLogClient passes QueueLeaves calls to queue.
+type fakeLogClient struct {
+	trillian.TrillianLogClient
+	queue func(req *trillian.QueueLeavesRequest) *trillian.QueueLeavesResponse
+}
+
+func (c *fakeLogClient) QueueLeaves(ctx context.Context, req *trillian.QueueLeavesRequest, opts ...grpc.CallOption) (*trillian.QueueLeavesResponse, error) {
+	return c.queue(req), nil
+}
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "queue_from_kafka")
+	if err != nil {
+		t.Fatalf("TempDir() = %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		desc        string
+		checkpoint  checkpoint
+		wantOffsets map[int32]int64
+	}{
+		{desc: "fresh", wantOffsets: map[int32]int64{0: sarama.OffsetOldest, 1: sarama.OffsetOldest}},
+		{desc: "resume", checkpoint: checkpoint{0: 5, 1: 7}, wantOffsets: map[int32]int64{0: 5, 1: 7}},
+	}
+
+	for _, test := range tests {
+		consumer := mocks.NewConsumer(t, nil)
+		consumer.SetTopicMetadata(map[string][]int32{testTopic: {0, 1}})
+
+		path := filepath.Join(dir, test.desc)
+		if test.checkpoint != nil {
+			if err := writeCheckpoint(path, test.checkpoint); err != nil {
+				t.Fatalf("%v: writeCheckpoint() = %v", test.desc, err)
+			}
+		}
+
+		// Each partition yields two records, the second being a redelivery of the first.
+		var msgs []*sarama.ConsumerMessage
+		for p := int32(0); p < 2; p++ {
+			pc := consumer.ExpectConsumePartition(testTopic, p, test.wantOffsets[p])
+			for i := int64(0); i < 2; i++ {
+				m := &sarama.ConsumerMessage{Topic: testTopic, Partition: p, Offset: test.checkpoint[p] + i, Value: []byte{byte(p)}}
+				pc.YieldMessage(m)
+				msgs = append(msgs, m)
+			}
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		var mu sync.Mutex
+		var queued []*trillian.LogLeaf
+		client := &fakeLogClient{queue: func(req *trillian.QueueLeavesRequest) *trillian.QueueLeavesResponse {
+			mu.Lock()
+			defer mu.Unlock()
+			if req.LogId != testLogID {
+				t.Errorf("%v: QueueLeaves() for log %d, want %d", test.desc, req.LogId, testLogID)
+			}
+			rsp := &trillian.QueueLeavesResponse{}
+			for _, l := range req.Leaves {
+				code := codes.OK
+				for _, q := range queued {
+					if bytes.Equal(q.LeafIdentityHash, l.LeafIdentityHash) {
+						code = codes.AlreadyExists
+					}
+				}
+				queued = append(queued, l)
+				rsp.QueuedLeaves = append(rsp.QueuedLeaves, &trillian.QueuedLogLeaf{Leaf: l, Status: &status.Status{Code: int32(code)}})
+			}
+			if len(queued) == len(msgs) {
+				cancel()
+			}
+			return rsp
+		}}
+
+		b := &bridge{
+			consumer:      consumer,
+			topic:         testTopic,
+			client:        client,
+			logID:         testLogID,
+			hasher:        testonly.Hasher,
+			batchSize:     len(msgs),
+			flushInterval: time.Hour,
+			retryBackoff:  time.Millisecond,
+			checkpoint:    path,
+		}
+		if err := b.run(ctx); err != nil {
+			t.Errorf("%v: run() = %v, want nil", test.desc, err)
+		}
+		if err := consumer.Close(); err != nil {
+			t.Errorf("%v: consumer.Close() = %v", test.desc, err)
+		}
+
+		if got, want := len(queued), len(msgs); got != want {
+			t.Errorf("%v: queued %d leaves, want %d", test.desc, got, want)
+		}
+		got, err := readCheckpoint(path)
+		if err != nil {
+			t.Fatalf("%v: readCheckpoint() = %v", test.desc, err)
+		}
+		want := checkpoint{0: test.checkpoint[0] + 2, 1: test.checkpoint[1] + 2}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: checkpoint = %v, want %v", test.desc, got, want)
+		}
+	}
+}
+
+func TestQueue(t *testing.T) {
+	tests := []struct {
+		desc    string
+		code    codes.Code
+		wantErr bool
+	}{
+		{desc: "queued", code: codes.OK},
+		{desc: "duplicate", code: codes.AlreadyExists},
+		{desc: "rejected", code: codes.ResourceExhausted, wantErr: true},
+	}
+
+	for _, test := range tests {
+		msgs := []*sarama.ConsumerMessage{
+			{Value: []byte("value")},
+			{Key: []byte("key"), Value: []byte("value")},
+		}
+		valueHash := sha256.Sum256([]byte("value"))
+		keyHash := sha256.Sum256([]byte("key"))
+		client := &fakeLogClient{queue: func(req *trillian.QueueLeavesRequest) *trillian.QueueLeavesResponse {
+			if got, want := req.Leaves[0].LeafIdentityHash, valueHash[:]; !bytes.Equal(got, want) {
+				t.Errorf("%v: unkeyed record identity hash %x, want %x", test.desc, got, want)
+			}
+			if got, want := req.Leaves[1].LeafIdentityHash, keyHash[:]; !bytes.Equal(got, want) {
+				t.Errorf("%v: keyed record identity hash %x, want %x", test.desc, got, want)
+			}
+			rsp := &trillian.QueueLeavesResponse{}
+			for _, l := range req.Leaves {
+				rsp.QueuedLeaves = append(rsp.QueuedLeaves, &trillian.QueuedLogLeaf{Leaf: l, Status: &status.Status{Code: int32(test.code)}})
+			}
+			return rsp
+		}}
+
+		b := &bridge{client: client, logID: testLogID, hasher: testonly.Hasher}
+		err := b.queue(context.Background(), msgs)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("%v: queue() = %v, want err %v", test.desc, err, test.wantErr)
+		}
+	}
+}
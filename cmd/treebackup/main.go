@@ -0,0 +1,84 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package main contains the implementation and entry point for the treebackup
+// command, which exports a log tree to an archive that treerestore can load.
+//
+// Example usage:
+// $ ./treebackup \
+//     --mysql_uri=user:pass@tcp(127.0.0.1:3306)/trillian \
+//     --tree_id=123 \
+//     --output=/backups/tree-123.bak
+//
+// The archive contains the tree's configuration, its latest signed root and
+// every leaf covered by that root.
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	_ "github.com/go-sql-driver/mysql" // Load MySQL driver
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/storage/backup"
+	"github.com/google/trillian/storage/mysql"
+)
+
+var (
+	mySQLURI  = flag.String("mysql_uri", "test:zaphod@tcp(127.0.0.1:3306)/test", "Connection URI for MySQL database")
+	treeID    = flag.Int64("tree_id", 0, "ID of the log tree to back up")
+	output    = flag.String("output", "", "File to write the archive to, or - for stdout")
+	batchSize = flag.Int("batch_size", 1000, "Number of leaves to read from storage at a time")
+)
+
+func main() {
+	flag.Parse()
+
+	if *treeID == 0 {
+		glog.Exitf("Empty --tree_id, please provide the ID of the tree to back up")
+	}
+	if *output == "" {
+		glog.Exitf("Empty --output, please provide a file to write the archive to")
+	}
+
+	db, err := mysql.OpenDB(*mySQLURI)
+	if err != nil {
+		glog.Exitf("Failed to open MySQL database: %v", err)
+	}
+	defer db.Close()
+
+	registry := extension.Registry{
+		AdminStorage: mysql.NewAdminStorage(db),
+		LogStorage:   mysql.NewLogStorage(db),
+	}
+
+	f := os.Stdout
+	if *output != "-" {
+		if f, err = os.Create(*output); err != nil {
+			glog.Exitf("Failed to create %v: %v", *output, err)
+		}
+	}
+
+	if err := backup.Backup(context.Background(), registry, *treeID, f, *batchSize); err != nil {
+		glog.Exitf("Failed to back up tree %d: %v", *treeID, err)
+	}
+	if err := f.Close(); err != nil {
+		glog.Exitf("Failed to write %v: %v", *output, err)
+	}
+	glog.Infof("Backed up tree %d", *treeID)
+	glog.Flush()
+}
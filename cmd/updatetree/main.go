@@ -0,0 +1,225 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package main contains the implementation and entry point for the updatetree
+// command.
+//
+// Example usage:
+// $ ./updatetree \
+//     --admin_server=host:port \
+//     --tree_id=123 \
+//     --tree_state=FROZEN
+//
+// Only the fields whose flags are set are updated, e.g. --display_name can be
+// changed without touching --description. The command outputs the updated
+// tree, in protobuf text format, to stdout, or an error to stderr in case of
+// failure.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"google.golang.org/genproto/protobuf/field_mask"
+	"google.golang.org/grpc"
+)
+
+var (
+	adminServerAddr = flag.String("admin_server", "", "Address of the gRPC Trillian Admin Server (host:port)")
+	treeID          = flag.Int64("tree_id", 0, "ID of the tree to update")
+
+	treeState   = flag.String("tree_state", "", "New state of the tree, e.g. FROZEN or ACTIVE")
+	displayName = flag.String("display_name", "", "New display name of the tree")
+	description = flag.String("description", "", "New description of the tree")
+
+	sequencingBatchSize       = flag.Int("sequencing_batch_size", 0, "New number of leaves the signer sequences per pass for the tree, 0 for the signer's default")
+	sequencingIntervalSeconds = flag.Int("sequencing_interval_seconds", 0, "New minimum time, in seconds, between sequencing passes for the tree, 0 to sequence on every signer pass")
+	sequencingGuardWindow     = flag.Int("sequencing_guard_window_seconds", 0, "New minimum time, in seconds, leaves are queued for before they're sequenced, 0 for the signer's default")
+	sequencingPriority        = flag.Int("sequencing_priority", 0, "New weight of the tree when the signer shares passes by priority, 0 for the default of 1")
+
+	maxMergeDelaySeconds = flag.Int("max_merge_delay_seconds", 0, "New longest time, in seconds, the log commits to taking to integrate a queued leaf, 0 for none")
+
+	leafCompression      = flag.String("leaf_compression", "", "New compression of leaf data added to the tree from now on, e.g. SNAPPY or ZSTD")
+	leafRetentionSeconds = flag.Int("leaf_retention_seconds", 0, "New time, in seconds, leaf values and extra data are kept for after they're integrated, 0 to keep them forever")
+	duplicatePolicy      = flag.String("duplicate_policy", "", "New duplicate policy of leaves queued to the tree from now on, DUPLICATES_ALLOWED or DUPLICATES_NOT_ALLOWED")
+
+	allowedWriters = flag.String("allowed_writers", "", "New comma separated list of the SPIFFE IDs allowed to add leaves to the log, IDs ending in / allow all IDs under them. Empty to allow any client")
+)
+
+// updateOpts contains all user-supplied options required to run the program.
+// Fields left nil are not updated.
+type updateOpts struct {
+	addr                                           string
+	treeID                                         int64
+	treeState, displayName, description            *string
+	sequencingBatchSize, sequencingIntervalSeconds *int
+	sequencingGuardWindow, sequencingPriority      *int
+	maxMergeDelaySeconds                           *int
+	leafCompression                                *string
+	leafRetentionSeconds                           *int
+	duplicatePolicy                                *string
+	allowedWriters                                 *string
+}
+
+func updateTree(ctx context.Context, opts *updateOpts) (*trillian.Tree, error) {
+	if opts.addr == "" {
+		return nil, errors.New("empty --admin_server, please provide the Admin server host:port")
+	}
+
+	req, err := newRequest(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := grpc.Dial(opts.addr, grpc.WithInsecure())
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return trillian.NewTrillianAdminClient(conn).UpdateTree(ctx, req)
+}
+
+func newRequest(opts *updateOpts) (*trillian.UpdateTreeRequest, error) {
+	if opts.treeID == 0 {
+		return nil, errors.New("empty --tree_id, please provide the ID of the tree to update")
+	}
+
+	tree := &trillian.Tree{TreeId: opts.treeID}
+	mask := &field_mask.FieldMask{}
+	if opts.treeState != nil {
+		ts, ok := trillian.TreeState_value[*opts.treeState]
+		if !ok {
+			return nil, fmt.Errorf("unknown TreeState: %v", *opts.treeState)
+		}
+		tree.TreeState = trillian.TreeState(ts)
+		mask.Paths = append(mask.Paths, "tree_state")
+	}
+	if opts.displayName != nil {
+		tree.DisplayName = *opts.displayName
+		mask.Paths = append(mask.Paths, "display_name")
+	}
+	if opts.description != nil {
+		tree.Description = *opts.description
+		mask.Paths = append(mask.Paths, "description")
+	}
+	if opts.sequencingBatchSize != nil {
+		tree.SequencingBatchSize = int32(*opts.sequencingBatchSize)
+		mask.Paths = append(mask.Paths, "sequencing_batch_size")
+	}
+	if opts.sequencingIntervalSeconds != nil {
+		tree.SequencingIntervalSeconds = int32(*opts.sequencingIntervalSeconds)
+		mask.Paths = append(mask.Paths, "sequencing_interval_seconds")
+	}
+	if opts.sequencingGuardWindow != nil {
+		tree.SequencingGuardWindowSeconds = int32(*opts.sequencingGuardWindow)
+		mask.Paths = append(mask.Paths, "sequencing_guard_window_seconds")
+	}
+	if opts.sequencingPriority != nil {
+		tree.SequencingPriority = int32(*opts.sequencingPriority)
+		mask.Paths = append(mask.Paths, "sequencing_priority")
+	}
+	if opts.maxMergeDelaySeconds != nil {
+		tree.MaxMergeDelaySeconds = int32(*opts.maxMergeDelaySeconds)
+		mask.Paths = append(mask.Paths, "max_merge_delay_seconds")
+	}
+	if opts.leafCompression != nil {
+		lc, ok := trillian.LeafCompression_value[*opts.leafCompression]
+		if !ok {
+			return nil, fmt.Errorf("unknown LeafCompression: %v", *opts.leafCompression)
+		}
+		tree.LeafCompression = trillian.LeafCompression(lc)
+		mask.Paths = append(mask.Paths, "leaf_compression")
+	}
+	if opts.leafRetentionSeconds != nil {
+		tree.LeafRetentionSeconds = int32(*opts.leafRetentionSeconds)
+		mask.Paths = append(mask.Paths, "leaf_retention_seconds")
+	}
+	if opts.duplicatePolicy != nil {
+		dp, ok := trillian.DuplicatePolicy_value[*opts.duplicatePolicy]
+		if !ok {
+			return nil, fmt.Errorf("unknown DuplicatePolicy: %v", *opts.duplicatePolicy)
+		}
+		tree.DuplicatePolicy = trillian.DuplicatePolicy(dp)
+		mask.Paths = append(mask.Paths, "duplicate_policy")
+	}
+	if opts.allowedWriters != nil {
+		for _, id := range strings.Split(*opts.allowedWriters, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				tree.AllowedWriters = append(tree.AllowedWriters, id)
+			}
+		}
+		mask.Paths = append(mask.Paths, "allowed_writers")
+	}
+	if len(mask.Paths) == 0 {
+		return nil, errors.New("nothing to update, please set at least one of --tree_state, --display_name, --description, --max_merge_delay_seconds, --leaf_compression, --leaf_retention_seconds, --duplicate_policy, --allowed_writers or the --sequencing_* flags")
+	}
+	return &trillian.UpdateTreeRequest{Tree: tree, UpdateMask: mask}, nil
+}
+
+func newOptsFromFlags() *updateOpts {
+	opts := &updateOpts{
+		addr:   *adminServerAddr,
+		treeID: *treeID,
+	}
+	// Only flags that were explicitly set are part of the update, so fields can be
+	// cleared by setting them to an empty string.
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "tree_state":
+			opts.treeState = treeState
+		case "display_name":
+			opts.displayName = displayName
+		case "description":
+			opts.description = description
+		case "sequencing_batch_size":
+			opts.sequencingBatchSize = sequencingBatchSize
+		case "sequencing_interval_seconds":
+			opts.sequencingIntervalSeconds = sequencingIntervalSeconds
+		case "sequencing_guard_window_seconds":
+			opts.sequencingGuardWindow = sequencingGuardWindow
+		case "sequencing_priority":
+			opts.sequencingPriority = sequencingPriority
+		case "max_merge_delay_seconds":
+			opts.maxMergeDelaySeconds = maxMergeDelaySeconds
+		case "leaf_compression":
+			opts.leafCompression = leafCompression
+		case "leaf_retention_seconds":
+			opts.leafRetentionSeconds = leafRetentionSeconds
+		case "duplicate_policy":
+			opts.duplicatePolicy = duplicatePolicy
+		case "allowed_writers":
+			opts.allowedWriters = allowedWriters
+		}
+	})
+	return opts
+}
+
+func main() {
+	flag.Parse()
+
+	ctx := context.Background()
+	tree, err := updateTree(ctx, newOptsFromFlags())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to update tree: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Print(proto.MarshalTextString(tree))
+}
@@ -0,0 +1,263 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/google/trillian"
+	"github.com/kylelemons/godebug/pretty"
+	"golang.org/x/net/context"
+	"google.golang.org/genproto/protobuf/field_mask"
+	"google.golang.org/grpc"
+)
+
+func TestUpdateTree(t *testing.T) {
+	server, lis, stopFn, err := startFakeServer()
+	if err != nil {
+		t.Fatalf("Error starting fake server: %v", err)
+	}
+	defer stopFn()
+	addr := lis.Addr().String()
+
+	frozen := trillian.TreeState_FROZEN.String()
+	invalidState := "LLAMA!"
+	name := "Llamas Log"
+	noDescription := ""
+	batchSize := 1000
+	interval := 30
+	guardWindow := 5
+	priority := 10
+	mmd := 3600
+	zstd := trillian.LeafCompression_ZSTD.String()
+	retention := 86400
+	allowDups := trillian.DuplicatePolicy_DUPLICATES_ALLOWED.String()
+	writers := "spiffe://example.org/ct/, spiffe://example.org/mirror"
+	noWriters := ""
+
+	tests := []struct {
+		desc      string
+		opts      *updateOpts
+		updateErr error
+		wantErr   bool
+		wantReq   *trillian.UpdateTreeRequest
+	}{
+		{
+			desc: "freeze",
+			opts: &updateOpts{addr: addr, treeID: 12, treeState: &frozen},
+			wantReq: &trillian.UpdateTreeRequest{
+				Tree:       &trillian.Tree{TreeId: 12, TreeState: trillian.TreeState_FROZEN},
+				UpdateMask: mask("tree_state"),
+			},
+		},
+		{
+			desc: "renameAndClearDescription",
+			opts: &updateOpts{addr: addr, treeID: 12, displayName: &name, description: &noDescription},
+			wantReq: &trillian.UpdateTreeRequest{
+				Tree:       &trillian.Tree{TreeId: 12, DisplayName: name},
+				UpdateMask: mask("display_name", "description"),
+			},
+		},
+		{
+			desc: "sequencingConfig",
+			opts: &updateOpts{addr: addr, treeID: 12, sequencingBatchSize: &batchSize, sequencingIntervalSeconds: &interval, sequencingGuardWindow: &guardWindow},
+			wantReq: &trillian.UpdateTreeRequest{
+				Tree:       &trillian.Tree{TreeId: 12, SequencingBatchSize: 1000, SequencingIntervalSeconds: 30, SequencingGuardWindowSeconds: 5},
+				UpdateMask: mask("sequencing_batch_size", "sequencing_interval_seconds", "sequencing_guard_window_seconds"),
+			},
+		},
+		{
+			desc: "sequencingPriority",
+			opts: &updateOpts{addr: addr, treeID: 12, sequencingPriority: &priority},
+			wantReq: &trillian.UpdateTreeRequest{
+				Tree:       &trillian.Tree{TreeId: 12, SequencingPriority: 10},
+				UpdateMask: mask("sequencing_priority"),
+			},
+		},
+		{
+			desc: "maxMergeDelay",
+			opts: &updateOpts{addr: addr, treeID: 12, maxMergeDelaySeconds: &mmd},
+			wantReq: &trillian.UpdateTreeRequest{
+				Tree:       &trillian.Tree{TreeId: 12, MaxMergeDelaySeconds: 3600},
+				UpdateMask: mask("max_merge_delay_seconds"),
+			},
+		},
+		{
+			desc: "leafCompression",
+			opts: &updateOpts{addr: addr, treeID: 12, leafCompression: &zstd},
+			wantReq: &trillian.UpdateTreeRequest{
+				Tree:       &trillian.Tree{TreeId: 12, LeafCompression: trillian.LeafCompression_ZSTD},
+				UpdateMask: mask("leaf_compression"),
+			},
+		},
+		{
+			desc: "leafRetention",
+			opts: &updateOpts{addr: addr, treeID: 12, leafRetentionSeconds: &retention},
+			wantReq: &trillian.UpdateTreeRequest{
+				Tree:       &trillian.Tree{TreeId: 12, LeafRetentionSeconds: 86400},
+				UpdateMask: mask("leaf_retention_seconds"),
+			},
+		},
+		{
+			desc: "duplicatePolicy",
+			opts: &updateOpts{addr: addr, treeID: 12, duplicatePolicy: &allowDups},
+			wantReq: &trillian.UpdateTreeRequest{
+				Tree:       &trillian.Tree{TreeId: 12, DuplicatePolicy: trillian.DuplicatePolicy_DUPLICATES_ALLOWED},
+				UpdateMask: mask("duplicate_policy"),
+			},
+		},
+		{
+			desc: "allowedWriters",
+			opts: &updateOpts{addr: addr, treeID: 12, allowedWriters: &writers},
+			wantReq: &trillian.UpdateTreeRequest{
+				Tree:       &trillian.Tree{TreeId: 12, AllowedWriters: []string{"spiffe://example.org/ct/", "spiffe://example.org/mirror"}},
+				UpdateMask: mask("allowed_writers"),
+			},
+		},
+		{
+			desc: "clearAllowedWriters",
+			opts: &updateOpts{addr: addr, treeID: 12, allowedWriters: &noWriters},
+			wantReq: &trillian.UpdateTreeRequest{
+				Tree:       &trillian.Tree{TreeId: 12},
+				UpdateMask: mask("allowed_writers"),
+			},
+		},
+		{
+			desc:    "emptyAddr",
+			opts:    &updateOpts{treeID: 12, treeState: &frozen},
+			wantErr: true,
+		},
+		{
+			desc:    "emptyTreeID",
+			opts:    &updateOpts{addr: addr, treeState: &frozen},
+			wantErr: true,
+		},
+		{
+			desc:    "nothingToUpdate",
+			opts:    &updateOpts{addr: addr, treeID: 12},
+			wantErr: true,
+		},
+		{
+			desc:    "invalidState",
+			opts:    &updateOpts{addr: addr, treeID: 12, treeState: &invalidState},
+			wantErr: true,
+		},
+		{
+			desc:    "invalidCompression",
+			opts:    &updateOpts{addr: addr, treeID: 12, leafCompression: &invalidState},
+			wantErr: true,
+		},
+		{
+			desc:    "invalidDuplicatePolicy",
+			opts:    &updateOpts{addr: addr, treeID: 12, duplicatePolicy: &invalidState},
+			wantErr: true,
+		},
+		{
+			desc:      "updateErr",
+			opts:      &updateOpts{addr: addr, treeID: 12, treeState: &frozen},
+			updateErr: errors.New("update tree failed"),
+			wantErr:   true,
+		},
+	}
+
+	ctx := context.Background()
+	for _, test := range tests {
+		server.err = test.updateErr
+		server.req = nil
+
+		_, err := updateTree(ctx, test.opts)
+		if hasErr := err != nil; hasErr != test.wantErr {
+			t.Errorf("%v: updateTree() returned err = '%v', wantErr = %v", test.desc, err, test.wantErr)
+			continue
+		} else if hasErr {
+			continue
+		}
+
+		if diff := pretty.Compare(server.req, test.wantReq); diff != "" {
+			t.Errorf("%v: UpdateTree request diff:\n%v", test.desc, diff)
+		}
+	}
+}
+
+func mask(paths ...string) *field_mask.FieldMask {
+	return &field_mask.FieldMask{Paths: paths}
+}
+
+// fakeAdminServer that implements UpdateTree. If err is nil, the request is
+// recorded and its tree is echoed as the output, otherwise err is returned
+// instead. The remaining methods are not implemented.
+type fakeAdminServer struct {
+	err error
+	req *trillian.UpdateTreeRequest
+}
+
+// startFakeServer starts a fakeAdminServer on a random port.
+// Returns the started server, the listener it's using for connection and a
+// close function that must be defer-called on the scope the server is meant to
+// stop.
+func startFakeServer() (*fakeAdminServer, net.Listener, func(), error) {
+	grpcServer := grpc.NewServer()
+	fakeServer := &fakeAdminServer{}
+	trillian.RegisterTrillianAdminServer(grpcServer, fakeServer)
+
+	lis, err := net.Listen("tcp", "")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	go grpcServer.Serve(lis)
+
+	stopFn := func() {
+		grpcServer.Stop()
+		lis.Close()
+	}
+	return fakeServer, lis, stopFn, nil
+}
+
+func (s *fakeAdminServer) UpdateTree(ctx context.Context, req *trillian.UpdateTreeRequest) (*trillian.Tree, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.req = req
+	return req.Tree, nil
+}
+
+var errUnimplemented = errors.New("unimplemented")
+
+func (s *fakeAdminServer) ListTrees(context.Context, *trillian.ListTreesRequest) (*trillian.ListTreesResponse, error) {
+	return nil, errUnimplemented
+}
+
+func (s *fakeAdminServer) GetTree(context.Context, *trillian.GetTreeRequest) (*trillian.Tree, error) {
+	return nil, errUnimplemented
+}
+
+func (s *fakeAdminServer) CreateTree(context.Context, *trillian.CreateTreeRequest) (*trillian.Tree, error) {
+	return nil, errUnimplemented
+}
+
+func (s *fakeAdminServer) DeleteTree(context.Context, *trillian.DeleteTreeRequest) (*empty.Empty, error) {
+	return nil, errUnimplemented
+}
+
+func (s *fakeAdminServer) SequenceLog(context.Context, *trillian.SequenceLogRequest) (*trillian.SequenceLogResponse, error) {
+	return nil, errUnimplemented
+}
+
+func (s *fakeAdminServer) GetTreeStats(context.Context, *trillian.GetTreeStatsRequest) (*trillian.GetTreeStatsResponse, error) {
+	return nil, errUnimplemented
+}
@@ -0,0 +1,230 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package main contains the implementation and entry point for the verify_root
+// command, which checks the latest root of a log against the log's public key
+// and the root seen by its previous run.
+//
+// Example usage:
+// $ ./verify_root \
+//     --log_server=host:port \
+//     --log_id=123 \
+//     --public_key=log.pubkey.pem \
+//     --checkpoint=/var/lib/verify_root/123.json
+//
+// The latest signed root of the log is fetched and its signature verified. If
+// the checkpoint file holds a root saved by a previous run, the new root must
+// also be consistent with it: no smaller, and proven to extend it. The new root
+// is then saved to the checkpoint file, so the command can be run periodically,
+// e.g. from cron, to monitor a log. It exits with status 2 if the log is found
+// to be inconsistent, and 1 on any other failure.
+package main
+
+import (
+	"bytes"
+	"context"
+	gocrypto "crypto"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/crypto/keys"
+	"github.com/google/trillian/merkle"
+	"google.golang.org/grpc"
+)
+
+var (
+	logServerAddr  = flag.String("log_server", "", "Address of the gRPC Trillian Log Server (host:port)")
+	logID          = flag.Int64("log_id", 0, "Tree ID of the log to verify")
+	publicKeyPath  = flag.String("public_key", "", "Path to the PEM file of the public key the log signs its roots with")
+	checkpointPath = flag.String("checkpoint", "", "File the last verified root is kept in, to check the next one is consistent with it. Optional")
+	timeout        = flag.Duration("timeout", time.Minute, "How long to wait for the log server")
+)
+
+// inconsistencyError is returned when the latest root of a log contradicts the one
+// saved in the checkpoint file.
+type inconsistencyError struct {
+	reason string
+}
+
+func (e inconsistencyError) Error() string {
+	return e.reason
+}
+
+// rootVerifier checks the latest root of a log.
+type rootVerifier struct {
+	client     trillian.TrillianLogClient
+	logID      int64
+	pubKey     gocrypto.PublicKey
+	hasher     merkle.TreeHasher
+	checkpoint string
+}
+
+// run fetches and verifies the latest root of the log, checks it's consistent with
+// the root in the checkpoint file, if there is one, and saves it there in its place.
+func (v *rootVerifier) run(ctx context.Context) (*trillian.SignedLogRoot, error) {
+	rsp, err := v.client.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{LogId: v.logID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest root: %v", err)
+	}
+	root := rsp.GetSignedLogRoot()
+	if root == nil {
+		return nil, fmt.Errorf("log %d returned no root", v.logID)
+	}
+	if err := crypto.Verify(v.pubKey, crypto.HashLogRoot(*root), root.Signature); err != nil {
+		return nil, fmt.Errorf("invalid signature on root at size %d: %v", root.TreeSize, err)
+	}
+
+	prev, err := readCheckpoint(v.checkpoint)
+	if err != nil {
+		return nil, err
+	}
+	if prev != nil {
+		if err := v.checkConsistency(ctx, prev, root); err != nil {
+			return nil, err
+		}
+	}
+	if err := writeCheckpoint(v.checkpoint, root); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// checkConsistency checks that root is prev, or extends it.
+func (v *rootVerifier) checkConsistency(ctx context.Context, prev, root *trillian.SignedLogRoot) error {
+	switch {
+	case prev.LogId != root.LogId:
+		return fmt.Errorf("checkpoint is a root of log %d, not %d", prev.LogId, root.LogId)
+	case root.TreeSize < prev.TreeSize:
+		return inconsistencyError{fmt.Sprintf("root at size %d is smaller than the checkpoint at size %d", root.TreeSize, prev.TreeSize)}
+	case root.TreeSize == prev.TreeSize:
+		if !bytes.Equal(root.RootHash, prev.RootHash) {
+			return inconsistencyError{fmt.Sprintf("root hash %x differs from the checkpoint's %x at size %d", root.RootHash, prev.RootHash, root.TreeSize)}
+		}
+		return nil
+	case prev.TreeSize == 0:
+		// Every tree extends the empty one.
+		return nil
+	}
+
+	rsp, err := v.client.GetConsistencyProof(ctx, &trillian.GetConsistencyProofRequest{
+		LogId:          v.logID,
+		FirstTreeSize:  prev.TreeSize,
+		SecondTreeSize: root.TreeSize,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get consistency proof from size %d to %d: %v", prev.TreeSize, root.TreeSize, err)
+	}
+	var proof [][]byte
+	for _, node := range rsp.GetProof().GetProofNode() {
+		proof = append(proof, node.GetNodeHash())
+	}
+	if err := merkle.NewLogVerifier(v.hasher).VerifyConsistencyProof(prev.TreeSize, root.TreeSize, prev.RootHash, root.RootHash, proof); err != nil {
+		return inconsistencyError{fmt.Sprintf("root at size %d doesn't extend the checkpoint at size %d: %v", root.TreeSize, prev.TreeSize, err)}
+	}
+	return nil
+}
+
+// readCheckpoint returns the root saved in the checkpoint file at path, or nil if
+// there isn't one yet.
+func readCheckpoint(path string) (*trillian.SignedLogRoot, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var root trillian.SignedLogRoot
+	if err := jsonpb.Unmarshal(bytes.NewReader(data), &root); err != nil {
+		return nil, fmt.Errorf("malformed checkpoint file %v: %v", path, err)
+	}
+	return &root, nil
+}
+
+// writeCheckpoint saves root to the checkpoint file at path, as JSON. The file is
+// replaced atomically so an interruption can't leave it truncated.
+func writeCheckpoint(path string, root *trillian.SignedLogRoot) error {
+	if path == "" {
+		return nil
+	}
+	data, err := (&jsonpb.Marshaler{Indent: "  "}).MarshalToString(root)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(data+"\n"), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func main() {
+	flag.Parse()
+	defer glog.Flush()
+
+	if *logServerAddr == "" {
+		glog.Exitf("Empty --log_server, please provide the Log server host:port")
+	}
+	if *publicKeyPath == "" {
+		glog.Exitf("Empty --public_key, please provide the log's public key")
+	}
+	if *logID <= 0 {
+		glog.Exitf("Invalid --log_id %d, please provide the log's tree ID", *logID)
+	}
+
+	pubKey, err := keys.NewFromPublicPEMFile(*publicKeyPath)
+	if err != nil {
+		glog.Exitf("Failed to load public key: %v", err)
+	}
+	hasher, err := merkle.Factory(merkle.RFC6962SHA256Type)
+	if err != nil {
+		glog.Exitf("Failed to create hasher: %v", err)
+	}
+
+	conn, err := grpc.Dial(*logServerAddr, grpc.WithInsecure())
+	if err != nil {
+		glog.Exitf("Failed to dial log server %v: %v", *logServerAddr, err)
+	}
+	defer conn.Close()
+
+	v := &rootVerifier{
+		client:     trillian.NewTrillianLogClient(conn),
+		logID:      *logID,
+		pubKey:     pubKey,
+		hasher:     hasher,
+		checkpoint: *checkpointPath,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	root, err := v.run(ctx)
+	if _, ok := err.(inconsistencyError); ok {
+		fmt.Printf("Log %d is inconsistent: %v\n", *logID, err)
+		glog.Flush()
+		os.Exit(2)
+	}
+	if err != nil {
+		glog.Exitf("Failed to verify root of log %d: %v", *logID, err)
+	}
+	fmt.Printf("Log %d root verified: size %d, hash %x, timestamp %d\n", *logID, root.TreeSize, root.RootHash, root.TimestampNanos)
+}
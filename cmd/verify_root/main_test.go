@@ -0,0 +1,141 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	gocrypto "crypto"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/crypto/keys"
+	"github.com/google/trillian/crypto/sigpb"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/mockclient"
+	"github.com/google/trillian/testonly"
+	"golang.org/x/net/context"
+)
+
+const testLogID = 42
+
+func TestRun(t *testing.T) {
+	key, err := keys.NewFromPrivatePEM(testonly.DemoPrivateKey, testonly.DemoPrivateKeyPass)
+	if err != nil {
+		t.Fatalf("Failed to load private key: %v", err)
+	}
+	otherKey, err := keys.GenerateKey(sigpb.DigitallySigned_ECDSA)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	tree := merkle.NewInMemoryMerkleTree(testonly.Hasher)
+	hashes := [][]byte{tree.CurrentRoot().Hash()}
+	for i := 0; i < 5; i++ {
+		tree.AddLeaf([]byte(fmt.Sprintf("leaf %d", i)))
+		hashes = append(hashes, tree.CurrentRoot().Hash())
+	}
+	root := func(signer gocrypto.Signer, size int64, hash []byte) *trillian.SignedLogRoot {
+		r := &trillian.SignedLogRoot{LogId: testLogID, TimestampNanos: 1000 + size, TreeSize: size, RootHash: hash}
+		sig, err := crypto.NewSigner(signer).Sign(crypto.HashLogRoot(*r))
+		if err != nil {
+			t.Fatalf("Failed to sign root: %v", err)
+		}
+		r.Signature = sig
+		return r
+	}
+	proof := &trillian.Proof{}
+	for _, n := range tree.SnapshotConsistency(3, 5) {
+		proof.ProofNode = append(proof.ProofNode, &trillian.Node{NodeHash: n.Value.Hash()})
+	}
+
+	tests := []struct {
+		desc             string
+		checkpoint       *trillian.SignedLogRoot
+		root             *trillian.SignedLogRoot
+		wantProof        bool
+		wantErr          bool
+		wantInconsistent bool
+	}{
+		{desc: "noCheckpoint", root: root(key, 3, hashes[3])},
+		{desc: "sameRoot", checkpoint: root(key, 3, hashes[3]), root: root(key, 3, hashes[3])},
+		{desc: "grown", checkpoint: root(key, 3, hashes[3]), root: root(key, 5, hashes[5]), wantProof: true},
+		{desc: "emptyCheckpoint", checkpoint: root(key, 0, hashes[0]), root: root(key, 5, hashes[5])},
+		{desc: "wrongKey", checkpoint: root(key, 3, hashes[3]), root: root(otherKey, 5, hashes[5]), wantErr: true},
+		{desc: "otherLog", checkpoint: &trillian.SignedLogRoot{LogId: 1, TreeSize: 3, RootHash: hashes[3]}, root: root(key, 3, hashes[3]), wantErr: true},
+		{desc: "smaller", checkpoint: root(key, 5, hashes[5]), root: root(key, 3, hashes[3]), wantErr: true, wantInconsistent: true},
+		{desc: "forked", checkpoint: root(key, 3, []byte("other")), root: root(key, 3, hashes[3]), wantErr: true, wantInconsistent: true},
+		{desc: "notExtended", checkpoint: root(key, 3, []byte("other")), root: root(key, 5, hashes[5]), wantProof: true, wantErr: true, wantInconsistent: true},
+	}
+
+	for _, test := range tests {
+		func() {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			dir, err := ioutil.TempDir("", "verify_root")
+			if err != nil {
+				t.Fatalf("TempDir()=%v", err)
+			}
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "checkpoint.json")
+			if test.checkpoint != nil {
+				if err := writeCheckpoint(path, test.checkpoint); err != nil {
+					t.Fatalf("%v: writeCheckpoint()=%v", test.desc, err)
+				}
+			}
+
+			client := mockclient.NewMockTrillianLogClient(ctrl)
+			client.EXPECT().GetLatestSignedLogRoot(gomock.Any(), &trillian.GetLatestSignedLogRootRequest{LogId: testLogID}).Return(&trillian.GetLatestSignedLogRootResponse{SignedLogRoot: test.root}, nil)
+			if test.wantProof {
+				client.EXPECT().GetConsistencyProof(gomock.Any(), &trillian.GetConsistencyProofRequest{LogId: testLogID, FirstTreeSize: 3, SecondTreeSize: 5}).Return(&trillian.GetConsistencyProofResponse{Proof: proof}, nil)
+			}
+
+			v := &rootVerifier{
+				client:     client,
+				logID:      testLogID,
+				pubKey:     key.Public(),
+				hasher:     testonly.Hasher,
+				checkpoint: path,
+			}
+			_, err = v.run(context.Background())
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Errorf("%v: run()=%v, want err? %v", test.desc, err, test.wantErr)
+				return
+			}
+			if _, got := err.(inconsistencyError); got != test.wantInconsistent {
+				t.Errorf("%v: run()=%v, want inconsistency? %v", test.desc, err, test.wantInconsistent)
+			}
+
+			// The checkpoint only moves on to roots which were verified.
+			want := test.root
+			if test.wantErr {
+				want = test.checkpoint
+			}
+			got, err := readCheckpoint(path)
+			if err != nil {
+				t.Fatalf("%v: readCheckpoint()=%v", test.desc, err)
+			}
+			if !proto.Equal(got, want) {
+				t.Errorf("%v: checkpoint=%v, want %v", test.desc, got, want)
+			}
+		}()
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package checkpoint converts log roots to and from the transparency "checkpoint"
+// signed note format, which is understood by witnesses and other tooling outside
+// Trillian.
+//
+// A checkpoint note is a text body followed by a blank line and one or more
+// signature lines:
+//
+//	example.com/log/123
+//	42
+//	<base64 root hash>
+//
+//	— example.com/log/123 <base64 signature>
+//
+// Each signature is prefixed by a 4 byte key hash, so verifiers can pick out the
+// signatures made with the keys they know. As in the note format, it's the first bytes
+// of the SHA-256 of the key name, a newline and the key, which is the signature
+// algorithm's identifier followed by the public key. Only ECDSA keys, identified by
+// 0x02 and given as DER encoded PKIX public keys, can sign notes.
+package checkpoint
+
+import (
+	gocrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/crypto/keys"
+	"github.com/google/trillian/crypto/sigpb"
+)
+
+// sigPrefix starts each signature line of a note.
+const sigPrefix = "— "
+
+// algECDSA is the note format's identifier of ECDSA signatures.
+const algECDSA = 0x02
+
+// Checkpoint is the body of a checkpoint note.
+type Checkpoint struct {
+	// Origin uniquely identifies the log, e.g. "example.com/log/123".
+	Origin string
+	// Size is the number of leaves in the tree.
+	Size uint64
+	// Hash is the tree's root hash.
+	Hash []byte
+}
+
+// FromLogRoot returns the checkpoint for root, a root of the log identified by origin.
+func FromLogRoot(origin string, root trillian.SignedLogRoot) Checkpoint {
+	return Checkpoint{Origin: origin, Size: uint64(root.TreeSize), Hash: root.RootHash}
+}
+
+// Marshal returns the text of the checkpoint body.
+func (c Checkpoint) Marshal() []byte {
+	return []byte(fmt.Sprintf("%s\n%d\n%s\n", c.Origin, c.Size, base64.StdEncoding.EncodeToString(c.Hash)))
+}
+
+// Unmarshal parses a checkpoint body. Extension lines after the root hash are
+// allowed but ignored.
+func Unmarshal(body []byte) (Checkpoint, error) {
+	lines := strings.Split(string(body), "\n")
+	if len(lines) < 4 || lines[len(lines)-1] != "" {
+		return Checkpoint{}, errors.New("checkpoint body must have at least 3 newline terminated lines")
+	}
+	origin := lines[0]
+	if origin == "" {
+		return Checkpoint{}, errors.New("empty checkpoint origin")
+	}
+	size, err := strconv.ParseUint(lines[1], 10, 64)
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("malformed checkpoint size: %v", err)
+	}
+	hash, err := base64.StdEncoding.DecodeString(lines[2])
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("malformed checkpoint root hash: %v", err)
+	}
+	return Checkpoint{Origin: origin, Size: size, Hash: hash}, nil
+}
+
+// noteKey returns pub as a key of the note format: its algorithm identifier followed
+// by the key.
+func noteKey(pub gocrypto.PublicKey) ([]byte, error) {
+	if _, ok := pub.(*ecdsa.PublicKey); !ok {
+		return nil, fmt.Errorf("notes can't be signed with %T keys, only ECDSA", pub)
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{algECDSA}, der...), nil
+}
+
+// KeyHash returns the hash identifying signatures made by the key name with public key pub.
+func KeyHash(name string, pub gocrypto.PublicKey) (uint32, error) {
+	key, err := noteKey(pub)
+	if err != nil {
+		return 0, err
+	}
+	h := sha256.New()
+	h.Write([]byte(name))
+	h.Write([]byte("\n"))
+	h.Write(key)
+	return binary.BigEndian.Uint32(h.Sum(nil)), nil
+}
+
+// VerifierKey returns the note format's verifier key for the key name with public key
+// pub, name+hash+key, by which other note tooling can check its signatures.
+func VerifierKey(name string, pub gocrypto.PublicKey) (string, error) {
+	if !validKeyName(name) {
+		return "", fmt.Errorf("invalid key name %q", name)
+	}
+	key, err := noteKey(pub)
+	if err != nil {
+		return "", err
+	}
+	keyHash, err := KeyHash(name, pub)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s+%08x+%s", name, keyHash, base64.StdEncoding.EncodeToString(key)), nil
+}
+
+// Sign returns a note holding c signed by signer, under the key name.
+func Sign(c Checkpoint, name string, signer *crypto.Signer) ([]byte, error) {
+	if !validKeyName(name) {
+		return nil, fmt.Errorf("invalid key name %q", name)
+	}
+	keyHash, err := KeyHash(name, signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	body := c.Marshal()
+	sig, err := signer.Sign(body)
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 4, 4+len(sig.Signature))
+	binary.BigEndian.PutUint32(b, keyHash)
+	b = append(b, sig.Signature...)
+	note := string(body) + "\n" + sigPrefix + name + " " + base64.StdEncoding.EncodeToString(b) + "\n"
+	return []byte(note), nil
+}
+
+// Open verifies that note carries a valid signature by the key name with public key
+// pub, and returns the checkpoint it holds. Signatures by other keys, such as witness
+// cosignatures, are ignored.
+func Open(note []byte, name string, pub gocrypto.PublicKey) (Checkpoint, error) {
+	text := string(note)
+	split := strings.LastIndex(text, "\n\n")
+	if split < 0 {
+		return Checkpoint{}, errors.New("note has no signatures")
+	}
+	body, sigs := text[:split+1], text[split+2:]
+	if !strings.HasSuffix(sigs, "\n") {
+		return Checkpoint{}, errors.New("note signatures are not newline terminated")
+	}
+	keyHash, err := KeyHash(name, pub)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(sigs, "\n"), "\n") {
+		if !strings.HasPrefix(line, sigPrefix) {
+			return Checkpoint{}, fmt.Errorf("malformed signature line %q", line)
+		}
+		fields := strings.Split(strings.TrimPrefix(line, sigPrefix), " ")
+		if len(fields) != 2 {
+			return Checkpoint{}, fmt.Errorf("malformed signature line %q", line)
+		}
+		if fields[0] != name {
+			continue
+		}
+		b, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil || len(b) < 4 {
+			return Checkpoint{}, fmt.Errorf("malformed signature by %v", name)
+		}
+		if binary.BigEndian.Uint32(b) != keyHash {
+			continue
+		}
+		sig := &sigpb.DigitallySigned{
+			SignatureAlgorithm: keys.SignatureAlgorithm(pub),
+			HashAlgorithm:      sigpb.DigitallySigned_SHA256,
+			Signature:          b[4:],
+		}
+		if err := crypto.Verify(pub, []byte(body), sig); err != nil {
+			return Checkpoint{}, fmt.Errorf("invalid signature by %v: %v", name, err)
+		}
+		return Unmarshal([]byte(body))
+	}
+	return Checkpoint{}, fmt.Errorf("note has no signature by %v", name)
+}
+
+// validKeyName reports whether name can be used in a signature line: it must be
+// non-empty and free of spaces, pluses and control characters.
+func validKeyName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if unicode.IsSpace(r) || unicode.IsControl(r) || r == '+' {
+			return false
+		}
+	}
+	return true
+}
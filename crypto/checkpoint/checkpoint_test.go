@@ -0,0 +1,166 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checkpoint
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/crypto/keys"
+	"github.com/google/trillian/crypto/sigpb"
+	"github.com/google/trillian/testonly"
+)
+
+const origin = "example.com/log/123"
+
+func TestMarshalUnmarshal(t *testing.T) {
+	c := FromLogRoot(origin, trillian.SignedLogRoot{TreeSize: 42, RootHash: []byte("0123456789abcdef0123456789abcdef")})
+	body := c.Marshal()
+	if want := "example.com/log/123\n42\nMDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=\n"; string(body) != want {
+		t.Errorf("Marshal()=%q, want %q", body, want)
+	}
+	got, err := Unmarshal(body)
+	if err != nil {
+		t.Fatalf("Unmarshal()=%v", err)
+	}
+	if !reflect.DeepEqual(got, c) {
+		t.Errorf("Unmarshal()=%v, want %v", got, c)
+	}
+
+	// Extension lines are allowed.
+	if _, err := Unmarshal(append(body, "extension\n"...)); err != nil {
+		t.Errorf("Unmarshal(with extension)=%v, want nil", err)
+	}
+
+	for _, body := range []string{
+		"",
+		"example.com/log/123\n42\n",
+		"example.com/log/123\n42\nMDEy",
+		"\n42\nMDEy\n",
+		"example.com/log/123\n-1\nMDEy\n",
+		"example.com/log/123\n42\n!!!\n",
+	} {
+		if _, err := Unmarshal([]byte(body)); err == nil {
+			t.Errorf("Unmarshal(%q)=nil, want error", body)
+		}
+	}
+}
+
+func TestSignOpen(t *testing.T) {
+	key, err := keys.NewFromPrivatePEM(testonly.DemoPrivateKey, testonly.DemoPrivateKeyPass)
+	if err != nil {
+		t.Fatalf("Failed to open test key: %v", err)
+	}
+	otherKey, err := keys.GenerateKey(sigpb.DigitallySigned_ECDSA)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	signer := crypto.NewSigner(key)
+	c := Checkpoint{Origin: origin, Size: 7, Hash: []byte("root")}
+
+	note, err := Sign(c, origin, signer)
+	if err != nil {
+		t.Fatalf("Sign()=%v", err)
+	}
+	if !bytes.HasPrefix(note, append(c.Marshal(), "\n— "+origin+" "...)) {
+		t.Errorf("Sign()=%q, want body followed by signature line", note)
+	}
+
+	got, err := Open(note, origin, key.Public())
+	if err != nil {
+		t.Fatalf("Open()=%v", err)
+	}
+	if !reflect.DeepEqual(got, c) {
+		t.Errorf("Open()=%v, want %v", got, c)
+	}
+
+	// A note cosigned by another key can still be opened with either key.
+	cosigned, err := Sign(c, "witness", crypto.NewSigner(otherKey))
+	if err != nil {
+		t.Fatalf("Sign(witness)=%v", err)
+	}
+	both := append(note, cosigned[len(c.Marshal())+1:]...)
+	if _, err := Open(both, origin, key.Public()); err != nil {
+		t.Errorf("Open(cosigned, log key)=%v", err)
+	}
+	if _, err := Open(both, "witness", otherKey.Public()); err != nil {
+		t.Errorf("Open(cosigned, witness key)=%v", err)
+	}
+
+	tampered := bytes.Replace(note, []byte("\n7\n"), []byte("\n8\n"), 1)
+	for _, test := range []struct {
+		desc    string
+		note    []byte
+		name    string
+		wantErr string
+	}{
+		{desc: "wrongName", note: note, name: "other", wantErr: "no signature by other"},
+		{desc: "tampered", note: tampered, name: origin, wantErr: "invalid signature"},
+		{desc: "unsigned", note: c.Marshal(), name: origin, wantErr: "no signatures"},
+	} {
+		if _, err := Open(test.note, test.name, key.Public()); err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("%v: Open()=%v, want err containing %q", test.desc, err, test.wantErr)
+		}
+	}
+	if _, err := Open(note, origin, otherKey.Public()); err == nil {
+		t.Error("Open(wrong key)=nil, want error")
+	}
+
+	if _, err := Sign(c, "bad name", signer); err == nil {
+		t.Error("Sign(bad name)=nil, want error")
+	}
+}
+
+func TestKeyHash(t *testing.T) {
+	key, err := keys.NewFromPrivatePEM(testonly.DemoPrivateKey, testonly.DemoPrivateKeyPass)
+	if err != nil {
+		t.Fatalf("Failed to open test key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey()=%v", err)
+	}
+	// The hash covers the name, a newline, the ECDSA identifier and the key.
+	sum := sha256.Sum256(append([]byte(origin+"\n\x02"), der...))
+	want := binary.BigEndian.Uint32(sum[:])
+	if got, err := KeyHash(origin, key.Public()); err != nil || got != want {
+		t.Errorf("KeyHash()=%08x, %v, want %08x, nil", got, err, want)
+	}
+
+	vkey, err := VerifierKey(origin, key.Public())
+	if err != nil {
+		t.Fatalf("VerifierKey()=%v", err)
+	}
+	if want := fmt.Sprintf("%s+%08x+%s", origin, want, base64.StdEncoding.EncodeToString(append([]byte{2}, der...))); vkey != want {
+		t.Errorf("VerifierKey()=%q, want %q", vkey, want)
+	}
+
+	rsaKey, err := keys.GenerateKey(sigpb.DigitallySigned_RSA)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	if _, err := KeyHash(origin, rsaKey.Public()); err == nil {
+		t.Error("KeyHash(RSA key)=nil, want error")
+	}
+}
@@ -31,6 +31,12 @@ const (
 	mapKeyRootHash       string = "RootHash"
 	mapKeyTimestampNanos string = "TimestampNanos"
 	mapKeyTreeSize       string = "TreeSize"
+	mapKeyMapID          string = "MapId"
+	mapKeyMapRevision    string = "MapRevision"
+
+	mapKeySourceLogID                  string = "MetadataSourceLogId"
+	mapKeyHighestFullyCompletedSeq     string = "MetadataHighestFullyCompletedSeq"
+	mapKeyHighestPartiallyCompletedSeq string = "MetadataHighestPartiallyCompletedSeq"
 )
 
 // HashLogRoot hashes SignedLogRoot objects using ObjectHash with
@@ -49,3 +55,24 @@ func HashLogRoot(root trillian.SignedLogRoot) []byte {
 	hash := objecthash.ObjectHash(rootMap)
 	return hash[:]
 }
+
+// HashMapRoot hashes SignedMapRoot objects using ObjectHash with "RootHash",
+// "TimestampNanos", "MapId" and "MapRevision", used as keys in a map. If the root has
+// mapper metadata, its fields are added as "MetadataSourceLogId",
+// "MetadataHighestFullyCompletedSeq" and "MetadataHighestPartiallyCompletedSeq".
+func HashMapRoot(root trillian.SignedMapRoot) []byte {
+	// As in HashLogRoot, int64 values are formatted as strings.
+	rootMap := map[string]string{
+		mapKeyRootHash:       base64.StdEncoding.EncodeToString(root.RootHash),
+		mapKeyTimestampNanos: strconv.FormatInt(root.TimestampNanos, 10),
+		mapKeyMapID:          strconv.FormatInt(root.MapId, 10),
+		mapKeyMapRevision:    strconv.FormatInt(root.MapRevision, 10)}
+	if m := root.Metadata; m != nil {
+		rootMap[mapKeySourceLogID] = base64.StdEncoding.EncodeToString(m.SourceLogId)
+		rootMap[mapKeyHighestFullyCompletedSeq] = strconv.FormatInt(m.HighestFullyCompletedSeq, 10)
+		rootMap[mapKeyHighestPartiallyCompletedSeq] = strconv.FormatInt(m.HighestPartiallyCompletedSeq, 10)
+	}
+
+	hash := objecthash.ObjectHash(rootMap)
+	return hash[:]
+}
@@ -64,3 +64,26 @@ func TestHashLogRoot(t *testing.T) {
 	}
 
 }
+
+func TestHashMapRoot(t *testing.T) {
+	unique := make(map[[20]byte]bool)
+	for _, root := range []trillian.SignedMapRoot{
+		{TimestampNanos: 2267709, RootHash: []byte("Islington"), MapId: 1, MapRevision: 2},
+		{TimestampNanos: 2267708, RootHash: []byte("Islington"), MapId: 1, MapRevision: 2},
+		{TimestampNanos: 2267709, RootHash: []byte("Oslington"), MapId: 1, MapRevision: 2},
+		{TimestampNanos: 2267709, RootHash: []byte("Islington"), MapId: 2, MapRevision: 2},
+		{TimestampNanos: 2267709, RootHash: []byte("Islington"), MapId: 1, MapRevision: 3},
+		{TimestampNanos: 2267709, RootHash: []byte("Islington"), MapId: 1, MapRevision: 2, Metadata: &trillian.MapperMetadata{}},
+		{TimestampNanos: 2267709, RootHash: []byte("Islington"), MapId: 1, MapRevision: 2, Metadata: &trillian.MapperMetadata{SourceLogId: []byte("log")}},
+		{TimestampNanos: 2267709, RootHash: []byte("Islington"), MapId: 1, MapRevision: 2, Metadata: &trillian.MapperMetadata{HighestFullyCompletedSeq: 1}},
+		{TimestampNanos: 2267709, RootHash: []byte("Islington"), MapId: 1, MapRevision: 2, Metadata: &trillian.MapperMetadata{HighestPartiallyCompletedSeq: 1}},
+	} {
+		hash := HashMapRoot(root)
+		var h [20]byte
+		copy(h[:], hash)
+		if _, ok := unique[h]; ok {
+			t.Errorf("Found duplicate hash from input %v", root)
+		}
+		unique[h] = true
+	}
+}
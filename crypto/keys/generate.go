@@ -0,0 +1,92 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/google/trillian/crypto/sigpb"
+)
+
+// rsaKeyBits is the size of the RSA keys created by GenerateKey.
+const rsaKeyBits = 2048
+
+// GenerateKey creates a new private key for the given signature algorithm.
+// ECDSA keys use the P-256 curve, RSA keys are 2048 bits.
+func GenerateKey(alg sigpb.DigitallySigned_SignatureAlgorithm) (crypto.Signer, error) {
+	switch alg {
+	case sigpb.DigitallySigned_ECDSA:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case sigpb.DigitallySigned_RSA:
+		return rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	}
+	return nil, fmt.Errorf("cannot generate a key for signature algorithm %v", alg)
+}
+
+// MarshalPrivateKeyPEM PEM-encodes a private key created by GenerateKey, so it can be
+// read back by NewFromPrivatePEM. If password is not empty the key is encrypted with it.
+func MarshalPrivateKeyPEM(key crypto.Signer, password string) ([]byte, error) {
+	var block *pem.Block
+	switch key := key.(type) {
+	case *ecdsa.PrivateKey:
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		block = &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}
+	case *rsa.PrivateKey:
+		block = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+	default:
+		return nil, fmt.Errorf("got %T, want *{ecdsa,rsa}.PrivateKey", key)
+	}
+
+	if password != "" {
+		var err error
+		block, err = x509.EncryptPEMBlock(rand.Reader, block.Type, block.Bytes, []byte(password), x509.PEMCipherAES256)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pem.EncodeToMemory(block), nil
+}
+
+// MarshalPrivateKeyDER DER-encodes a private key created by GenerateKey, so it can be
+// kept in a trillian.PrivateKey. ECDSA keys are encoded in SEC1 form, RSA keys in PKCS#1
+// form.
+func MarshalPrivateKeyDER(key crypto.Signer) ([]byte, error) {
+	switch key := key.(type) {
+	case *ecdsa.PrivateKey:
+		return x509.MarshalECPrivateKey(key)
+	case *rsa.PrivateKey:
+		return x509.MarshalPKCS1PrivateKey(key), nil
+	}
+	return nil, fmt.Errorf("got %T, want *{ecdsa,rsa}.PrivateKey", key)
+}
+
+// MarshalPublicKeyPEM PEM-encodes a public key, so it can be read back by NewFromPublicPEM.
+func MarshalPublicKeyPEM(key crypto.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
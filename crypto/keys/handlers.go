@@ -0,0 +1,102 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/google/trillian"
+)
+
+// ProtoHandler returns a signer for a private key specification, a protobuf message
+// of the type the handler was registered for. A specification could be the path of a
+// key file, a PKCS#11 slot or the resource name of a key held by a KMS, for example.
+type ProtoHandler func(context.Context, proto.Message) (crypto.Signer, error)
+
+var (
+	handlersMu sync.RWMutex
+	// handlers are keyed by the full name of the message type they handle.
+	handlers = make(map[string]ProtoHandler)
+)
+
+func init() {
+	RegisterHandler(&trillian.PEMKeyFile{}, func(ctx context.Context, pb proto.Message) (crypto.Signer, error) {
+		keyFile := pb.(*trillian.PEMKeyFile)
+		return NewFromPrivatePEMFile(keyFile.GetPath(), keyFile.GetPassword())
+	})
+	RegisterHandler(&trillian.PrivateKey{}, func(ctx context.Context, pb proto.Message) (crypto.Signer, error) {
+		return parsePrivateKey(pb.(*trillian.PrivateKey).GetDer())
+	})
+}
+
+// RegisterHandler makes handler the source of signers for private key specifications
+// of the same type as keyProto, replacing any handler already registered for it.
+// Handlers are typically registered by the init functions of the packages
+// implementing them, or by main.
+func RegisterHandler(keyProto proto.Message, handler ProtoHandler) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	handlers[proto.MessageName(keyProto)] = handler
+}
+
+// UnregisterHandler removes the handler for private key specifications of the same
+// type as keyProto, if there is one.
+func UnregisterHandler(keyProto proto.Message) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	delete(handlers, proto.MessageName(keyProto))
+}
+
+// NewSigner returns a signer for the private key specification keyProto, from the
+// handler registered for its type.
+func NewSigner(ctx context.Context, keyProto proto.Message) (crypto.Signer, error) {
+	name := proto.MessageName(keyProto)
+	handlersMu.RLock()
+	handler, ok := handlers[name]
+	handlersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no handler registered for private keys of type %q", name)
+	}
+	return handler(ctx, keyProto)
+}
+
+// ProtoSignerFactory creates signers for trees from the private key specification
+// in their PrivateKey field, using the handler registered for its type with
+// RegisterHandler. PEMKeyFile and PrivateKey specifications are handled by default.
+// It implements keys.SignerFactory.
+type ProtoSignerFactory struct{}
+
+// NewSigner returns a crypto.Signer for the given tree.
+func (f ProtoSignerFactory) NewSigner(ctx context.Context, tree *trillian.Tree) (crypto.Signer, error) {
+	if tree.GetPrivateKey() == nil {
+		return nil, fmt.Errorf("tree %d has no PrivateKey", tree.GetTreeId())
+	}
+
+	var privateKey ptypes.DynamicAny
+	if err := ptypes.UnmarshalAny(tree.GetPrivateKey(), &privateKey); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal private key for tree %d: %v", tree.GetTreeId(), err)
+	}
+
+	signer, err := NewSigner(ctx, privateKey.Message)
+	if err != nil {
+		return nil, fmt.Errorf("tree %d: %v", tree.GetTreeId(), err)
+	}
+	return signer, nil
+}
@@ -0,0 +1,123 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keys
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/sigpb"
+)
+
+func TestProtoSignerFactoryNewSigner(t *testing.T) {
+	key, err := GenerateKey(sigpb.DigitallySigned_ECDSA)
+	if err != nil {
+		t.Fatalf("GenerateKey()=%v", err)
+	}
+	der, err := x509.MarshalECPrivateKey(key.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey()=%v", err)
+	}
+
+	// Any message type can be a key specification once it has a handler.
+	RegisterHandler(&trillian.SignedLogRoot{}, func(ctx context.Context, pb proto.Message) (crypto.Signer, error) {
+		return key, nil
+	})
+	defer UnregisterHandler(&trillian.SignedLogRoot{})
+
+	for _, test := range []struct {
+		name    string
+		tree    *trillian.Tree
+		wantErr bool
+	}{
+		{
+			name: "PEMKeyFile",
+			tree: &trillian.Tree{
+				PrivateKey: marshalAny(&trillian.PEMKeyFile{
+					Path:     "../../testdata/log-rpc-server.privkey.pem",
+					Password: "towel",
+				}),
+			},
+		},
+		{
+			name: "PEMKeyFile with wrong password",
+			tree: &trillian.Tree{
+				PrivateKey: marshalAny(&trillian.PEMKeyFile{
+					Path:     "../../testdata/log-rpc-server.privkey.pem",
+					Password: "wrong-password",
+				}),
+			},
+			wantErr: true,
+		},
+		{
+			name: "PrivateKey",
+			tree: &trillian.Tree{PrivateKey: marshalAny(&trillian.PrivateKey{Der: der})},
+		},
+		{
+			name:    "PrivateKey with invalid DER",
+			tree:    &trillian.Tree{PrivateKey: marshalAny(&trillian.PrivateKey{Der: []byte("not a key")})},
+			wantErr: true,
+		},
+		{
+			name: "Registered handler",
+			tree: &trillian.Tree{PrivateKey: marshalAny(&trillian.SignedLogRoot{})},
+		},
+		{
+			name:    "Unregistered type",
+			tree:    &trillian.Tree{PrivateKey: marshalAny(&trillian.Tree{})},
+			wantErr: true,
+		},
+		{
+			name:    "No PrivateKey",
+			tree:    &trillian.Tree{},
+			wantErr: true,
+		},
+	} {
+		signer, err := ProtoSignerFactory{}.NewSigner(context.Background(), test.tree)
+		switch gotErr := err != nil; {
+		case gotErr != test.wantErr:
+			t.Errorf("%s: NewSigner(_, %v) = (%v, %v), want err? %t", test.name, test.tree, signer, err, test.wantErr)
+			continue
+		case gotErr:
+			continue
+		}
+
+		digest := sha256.Sum256([]byte("test"))
+		if _, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256); err != nil {
+			t.Errorf("%s: NewSigner(_, %v).Sign(_, _, _) = (_, %v), want err? false", test.name, test.tree, err)
+		}
+	}
+}
+
+func TestUnregisterHandler(t *testing.T) {
+	keyProto := &trillian.SignedMapRoot{}
+	RegisterHandler(keyProto, func(ctx context.Context, pb proto.Message) (crypto.Signer, error) {
+		return nil, nil
+	})
+	if _, err := NewSigner(context.Background(), keyProto); err != nil {
+		t.Errorf("NewSigner() with a registered handler = (_, %v), want nil", err)
+	}
+	UnregisterHandler(keyProto)
+	if _, err := NewSigner(context.Background(), keyProto); err == nil {
+		t.Error("NewSigner() after UnregisterHandler() succeeded, want error")
+	}
+}
@@ -219,3 +219,49 @@ func TestSignatureAlgorithm(t *testing.T) {
 		}
 	}
 }
+
+func TestGenerateKey(t *testing.T) {
+	for _, test := range []struct {
+		alg      sigpb.DigitallySigned_SignatureAlgorithm
+		password string
+		wantErr  bool
+	}{
+		{alg: sigpb.DigitallySigned_ECDSA},
+		{alg: sigpb.DigitallySigned_ECDSA, password: "towel"},
+		{alg: sigpb.DigitallySigned_RSA, password: "towel"},
+		{alg: sigpb.DigitallySigned_ANONYMOUS, wantErr: true},
+	} {
+		key, err := GenerateKey(test.alg)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("GenerateKey(%v) = (_, %v), want err? %v", test.alg, err, test.wantErr)
+			continue
+		} else if gotErr {
+			continue
+		}
+
+		privPEM, err := MarshalPrivateKeyPEM(key, test.password)
+		if err != nil {
+			t.Errorf("MarshalPrivateKeyPEM(%v key) = (_, %v), want nil", test.alg, err)
+			continue
+		}
+		parsed, err := NewFromPrivatePEM(string(privPEM), test.password)
+		if err != nil {
+			t.Errorf("NewFromPrivatePEM() of generated %v key = (_, %v), want nil", test.alg, err)
+			continue
+		}
+		if got := SignatureAlgorithm(parsed.Public()); got != test.alg {
+			t.Errorf("SignatureAlgorithm() of parsed key = %v, want %v", got, test.alg)
+		}
+
+		pubPEM, err := MarshalPublicKeyPEM(key.Public())
+		if err != nil {
+			t.Errorf("MarshalPublicKeyPEM(%v key) = (_, %v), want nil", test.alg, err)
+			continue
+		}
+		if pub, err := NewFromPublicPEM(string(pubPEM)); err != nil {
+			t.Errorf("NewFromPublicPEM() of generated %v key = (_, %v), want nil", test.alg, err)
+		} else if got := SignatureAlgorithm(pub); got != test.alg {
+			t.Errorf("SignatureAlgorithm() of parsed public key = %v, want %v", got, test.alg)
+		}
+	}
+}
@@ -24,7 +24,8 @@ import (
 )
 
 // PEMSignerFactory loads PEM-encoded private keys.
-// It only supports trees whose PrivateKey field is a trillian.PEMKeyFile.
+// It only supports trees whose PrivateKey field is a trillian.PEMKeyFile, see
+// ProtoSignerFactory for other types of keys.
 // It implements keys.SignerFactory.
 // TODO(robpercival): Should this cache loaded private keys? The SequenceManager will request a signer for each batch of leaves it sequences.
 type PEMSignerFactory struct{}
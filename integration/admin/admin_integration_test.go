@@ -24,6 +24,7 @@ import (
 	"github.com/google/trillian/storage/testonly"
 	"github.com/google/trillian/testonly/integration"
 	"github.com/kylelemons/godebug/pretty"
+	"google.golang.org/genproto/protobuf/field_mask"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 )
@@ -46,20 +47,6 @@ func TestAdminServer_Unimplemented(t *testing.T) {
 				return err
 			},
 		},
-		{
-			desc: "UpdateTree",
-			fn: func(ctx context.Context, c trillian.TrillianAdminClient) error {
-				_, err := c.UpdateTree(ctx, &trillian.UpdateTreeRequest{})
-				return err
-			},
-		},
-		{
-			desc: "DeleteTree",
-			fn: func(ctx context.Context, c trillian.TrillianAdminClient) error {
-				_, err := c.DeleteTree(ctx, &trillian.DeleteTreeRequest{})
-				return err
-			},
-		},
 	}
 
 	ctx := context.Background()
@@ -160,6 +147,57 @@ func TestAdminServer_GetTree(t *testing.T) {
 	}
 }
 
+func TestAdminServer_UpdateAndDeleteTree(t *testing.T) {
+	client, closeFn, err := setupAdminServer()
+	if err != nil {
+		t.Fatalf("setupAdminServer() failed: %v", err)
+	}
+	defer closeFn()
+
+	ctx := context.Background()
+	createdTree, err := client.CreateTree(ctx, &trillian.CreateTreeRequest{Tree: testonly.LogTree})
+	if err != nil {
+		t.Fatalf("CreateTree() = (_, %v), want = (_, nil)", err)
+	}
+
+	update := &trillian.Tree{TreeId: createdTree.TreeId, TreeState: trillian.TreeState_FROZEN, DisplayName: "Frozen Llamas"}
+	if _, err := client.UpdateTree(ctx, &trillian.UpdateTreeRequest{
+		Tree:       update,
+		UpdateMask: &field_mask.FieldMask{Paths: []string{"tree_state", "display_name"}},
+	}); err != nil {
+		t.Fatalf("UpdateTree() = (_, %v), want = (_, nil)", err)
+	}
+	if _, err := client.UpdateTree(ctx, &trillian.UpdateTreeRequest{
+		Tree:       update,
+		UpdateMask: &field_mask.FieldMask{Paths: []string{"tree_type"}},
+	}); grpc.Code(err) != codes.InvalidArgument {
+		t.Errorf("UpdateTree() of tree_type = (_, %v), wantCode = %v", err, codes.InvalidArgument)
+	}
+
+	storedTree, err := client.GetTree(ctx, &trillian.GetTreeRequest{TreeId: createdTree.TreeId})
+	if err != nil {
+		t.Fatalf("GetTree() = (_, %v), want = (_, nil)", err)
+	}
+	if storedTree.TreeState != update.TreeState || storedTree.DisplayName != update.DisplayName || storedTree.Description != createdTree.Description {
+		t.Errorf("GetTree() after UpdateTree() = %v, want state %v, display name %q and description %q", storedTree, update.TreeState, update.DisplayName, createdTree.Description)
+	}
+
+	if _, err := client.DeleteTree(ctx, &trillian.DeleteTreeRequest{TreeId: createdTree.TreeId}); err != nil {
+		t.Fatalf("DeleteTree() = (_, %v), want = (_, nil)", err)
+	}
+	storedTree, err = client.GetTree(ctx, &trillian.GetTreeRequest{TreeId: createdTree.TreeId})
+	if err != nil {
+		t.Fatalf("GetTree() = (_, %v), want = (_, nil)", err)
+	}
+	if got, want := storedTree.TreeState, trillian.TreeState_SOFT_DELETED; got != want {
+		t.Errorf("GetTree() after DeleteTree() has state %v, want %v", got, want)
+	}
+
+	if _, err := client.DeleteTree(ctx, &trillian.DeleteTreeRequest{TreeId: 12345}); grpc.Code(err) != codes.NotFound {
+		t.Errorf("DeleteTree() of unknown tree = (_, %v), wantCode = %v", err, codes.NotFound)
+	}
+}
+
 // setupAdminServer prepares and starts an Admin Server, returning a client and
 // a close function if successful.
 // The close function should be defer-called if error is not nil to ensure a
@@ -47,6 +47,15 @@ type Sequencer struct {
 	// sequencerGuardWindow is used to ensure entries newer than the guard window will not be
 	// sequenced until they fall outside it. By default there is no guard window.
 	sequencerGuardWindow time.Duration
+
+	// preordered is set for PREORDERED_LOG trees, whose leaves arrive with their LeafIndex
+	// already assigned.
+	preordered bool
+
+	// onRoot, if set, is called with each root signed by the sequencer once it's committed.
+	onRoot func(root trillian.SignedLogRoot)
+	// onLeaves, if set, is called with each batch of leaves once they've been integrated.
+	onLeaves func(logID int64, leaves []*trillian.LogLeaf)
 }
 
 // maxTreeDepth sets an upper limit on the size of Log trees.
@@ -71,6 +80,33 @@ func (s *Sequencer) SetGuardWindow(sequencerGuardWindow time.Duration) {
 	s.sequencerGuardWindow = sequencerGuardWindow
 }
 
+// SetPreordered sets whether the log's leaves already carry their LeafIndex when dequeued, as
+// is the case for PREORDERED_LOG trees. Pre-ordered leaves are integrated at the index they
+// carry and are not written back to storage by UpdateSequencedLeaves.
+func (s *Sequencer) SetPreordered(preordered bool) {
+	s.preordered = preordered
+}
+
+// SetRootObserver sets a function to be called with each new root once it has been signed
+// and committed to storage. It's called synchronously, so should not block for long.
+func (s *Sequencer) SetRootObserver(onRoot func(root trillian.SignedLogRoot)) {
+	s.onRoot = onRoot
+}
+
+// SetLeavesObserver sets a function to be called with the leaves integrated by each batch,
+// which carry their assigned LeafIndex, once the batch has been committed to storage. It's
+// called synchronously, so should not block for long.
+func (s *Sequencer) SetLeavesObserver(onLeaves func(logID int64, leaves []*trillian.LogLeaf)) {
+	s.onLeaves = onLeaves
+}
+
+// rootCommitted notifies the root observer, if there is one, of a new root.
+func (s Sequencer) rootCommitted(root trillian.SignedLogRoot) {
+	if s.onRoot != nil {
+		s.onRoot(root)
+	}
+}
+
 // TODO: This currently doesn't use the batch api for fetching the required nodes. This
 // would be more efficient but requires refactoring.
 func (s Sequencer) buildMerkleTreeFromStorageAtRoot(ctx context.Context, root trillian.SignedLogRoot, tx storage.TreeTX) (*merkle.CompactMerkleTree, error) {
@@ -123,6 +159,11 @@ func (s Sequencer) sequenceLeaves(mt *merkle.CompactMerkleTree, leaves []*trilli
 				Hash:   hash,
 			}
 		})
+		// Pre-ordered leaves must land exactly where their submitter put them, storage only
+		// hands them over without gaps so anything else means the tree state is inconsistent.
+		if s.preordered && leaf.LeafIndex != seq {
+			return nil, nil, fmt.Errorf("pre-ordered leaf has index %d but was integrated at %d", leaf.LeafIndex, seq)
+		}
 		// The leaf has now been sequenced.
 		leaves[i].LeafIndex = seq
 		// Store leaf hash in the Merkle tree too:
@@ -226,10 +267,13 @@ func (s Sequencer) SequenceBatch(ctx context.Context, logID int64, limit int) (i
 		return 0, fmt.Errorf("%v: wanted: %v leaves after sequencing but we got: %v", logID, want, got)
 	}
 
-	// Write the new sequence numbers to the leaves in the DB
-	if err := tx.UpdateSequencedLeaves(sequencedLeaves); err != nil {
-		glog.Warningf("%v: Sequencer failed to update sequenced leaves: %v", logID, err)
-		return 0, err
+	// Write the new sequence numbers to the leaves in the DB. Pre-ordered leaves were stored
+	// with their sequence numbers when they were added.
+	if !s.preordered {
+		if err := tx.UpdateSequencedLeaves(sequencedLeaves); err != nil {
+			glog.Warningf("%v: Sequencer failed to update sequenced leaves: %v", logID, err)
+			return 0, err
+		}
 	}
 
 	// Build objects for the nodes to be updated. Because we deduped via the map each
@@ -275,6 +319,10 @@ func (s Sequencer) SequenceBatch(ctx context.Context, logID int64, limit int) (i
 	if err := tx.Commit(); err != nil {
 		return 0, err
 	}
+	if s.onLeaves != nil {
+		s.onLeaves(logID, sequencedLeaves)
+	}
+	s.rootCommitted(newLogRoot)
 
 	glog.Infof("%v: sequenced %v leaves, size %v, tree-revision %v", logID, len(leaves), newLogRoot.TreeSize, newLogRoot.TreeRevision)
 	return len(leaves), nil
@@ -325,7 +373,10 @@ func (s Sequencer) SignRoot(ctx context.Context, logID int64) error {
 		glog.Warningf("%v: signer failed to write updated root: %v", logID, err)
 		return err
 	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
 	glog.V(2).Infof("%v: new signed root, size %v, tree-revision %v", logID, newLogRoot.TreeSize, newLogRoot.TreeRevision)
-
-	return tx.Commit()
+	s.rootCommitted(newLogRoot)
+	return nil
 }
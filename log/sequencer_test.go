@@ -23,6 +23,7 @@ import (
 	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/golang/protobuf/proto"
 	"github.com/google/trillian"
 	"github.com/google/trillian/crypto"
 	"github.com/google/trillian/crypto/keys"
@@ -278,6 +279,9 @@ func TestSequenceWithNothingQueued(t *testing.T) {
 		skipStoreSignedRoot: true,
 	}
 	c, ctx := createTestContext(ctrl, params)
+	c.sequencer.SetRootObserver(func(root trillian.SignedLogRoot) {
+		t.Errorf("Root observer unexpectedly called with %v", root)
+	})
 
 	leaves, err := c.sequencer.SequenceBatch(ctx, params.logID, 1)
 	if leaves != 0 {
@@ -539,6 +543,63 @@ func TestSequenceBatch(t *testing.T) {
 		signer:           signer,
 	}
 	c, ctx := createTestContext(ctrl, params)
+	var roots []trillian.SignedLogRoot
+	c.sequencer.SetRootObserver(func(root trillian.SignedLogRoot) { roots = append(roots, root) })
+	var integrated []*trillian.LogLeaf
+	c.sequencer.SetLeavesObserver(func(logID int64, leaves []*trillian.LogLeaf) {
+		if logID != params.logID {
+			t.Errorf("Leaves observer got log %d, expected %d", logID, params.logID)
+		}
+		integrated = append(integrated, leaves...)
+	})
+
+	leafCount, err := c.sequencer.SequenceBatch(ctx, params.logID, 1)
+	if err != nil {
+		t.Fatalf("Expected sequencing to succeed, but got err: %v", err)
+	}
+	if got, want := leafCount, 1; got != want {
+		t.Fatalf("Sequenced %d leaf, expected %d", got, want)
+	}
+	if got, want := len(roots), 1; got != want {
+		t.Fatalf("Root observer called %d times, expected %d", got, want)
+	}
+	if !proto.Equal(&roots[0], &expectedSignedRoot) {
+		t.Errorf("Root observer got %v, expected %v", roots[0], expectedSignedRoot)
+	}
+	if got, want := len(integrated), 1; got != want {
+		t.Fatalf("Leaves observer got %d leaves, expected %d", got, want)
+	}
+	if !proto.Equal(integrated[0], testLeaf16) {
+		t.Errorf("Leaves observer got %v, expected %v", integrated[0], testLeaf16)
+	}
+}
+
+func TestSequenceBatchPreordered(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	leaf := *testLeaf16
+	leaves := []*trillian.LogLeaf{&leaf}
+
+	signer, err := newSignerWithFixedSig(expectedSignedRoot.Signature)
+	if err != nil {
+		t.Fatalf("Failed to create test signer (%v)", err)
+	}
+
+	// No updatedLeaves: pre-ordered leaves must not be passed to UpdateSequencedLeaves.
+	params := testParameters{
+		logID:            154035,
+		writeRevision:    testRoot16.TreeRevision + 1,
+		dequeueLimit:     1,
+		shouldCommit:     true,
+		dequeuedLeaves:   leaves,
+		latestSignedRoot: &testRoot16,
+		merkleNodesSet:   &updatedNodes,
+		storeSignedRoot:  &expectedSignedRoot,
+		signer:           signer,
+	}
+	c, ctx := createTestContext(ctrl, params)
+	c.sequencer.SetPreordered(true)
 
 	leafCount, err := c.sequencer.SequenceBatch(ctx, params.logID, 1)
 	if err != nil {
@@ -549,6 +610,29 @@ func TestSequenceBatch(t *testing.T) {
 	}
 }
 
+func TestSequenceBatchPreorderedIndexMismatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	leaves := []*trillian.LogLeaf{getLeaf42()}
+	params := testParameters{
+		logID:               154035,
+		writeRevision:       testRoot16.TreeRevision + 1,
+		dequeueLimit:        1,
+		dequeuedLeaves:      leaves,
+		latestSignedRoot:    &testRoot16,
+		skipStoreSignedRoot: true,
+	}
+	c, ctx := createTestContext(ctrl, params)
+	c.sequencer.SetPreordered(true)
+
+	leafCount, err := c.sequencer.SequenceBatch(ctx, params.logID, 1)
+	if leafCount != 0 {
+		t.Fatalf("Unexpectedly sequenced %d leaves on error", leafCount)
+	}
+	testonly.EnsureErrorContains(t, err, "pre-ordered leaf has index 42")
+}
+
 func TestSignBeginTxFails(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -31,6 +31,54 @@ func (_m *MockTrillianLogClient) EXPECT() *_MockTrillianLogClientRecorder {
 	return _m.recorder
 }
 
+func (_m *MockTrillianLogClient) AddObservedRoot(_param0 context.Context, _param1 *trillian.AddObservedRootRequest, _param2 ...grpc.CallOption) (*trillian.AddObservedRootResponse, error) {
+	_s := []interface{}{_param0, _param1}
+	for _, _x := range _param2 {
+		_s = append(_s, _x)
+	}
+	ret := _m.ctrl.Call(_m, "AddObservedRoot", _s...)
+	ret0, _ := ret[0].(*trillian.AddObservedRootResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTrillianLogClientRecorder) AddObservedRoot(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	_s := append([]interface{}{arg0, arg1}, arg2...)
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "AddObservedRoot", _s...)
+}
+
+func (_m *MockTrillianLogClient) AddSequencedLeaves(_param0 context.Context, _param1 *trillian.AddSequencedLeavesRequest, _param2 ...grpc.CallOption) (*trillian.AddSequencedLeavesResponse, error) {
+	_s := []interface{}{_param0, _param1}
+	for _, _x := range _param2 {
+		_s = append(_s, _x)
+	}
+	ret := _m.ctrl.Call(_m, "AddSequencedLeaves", _s...)
+	ret0, _ := ret[0].(*trillian.AddSequencedLeavesResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTrillianLogClientRecorder) AddSequencedLeaves(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	_s := append([]interface{}{arg0, arg1}, arg2...)
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "AddSequencedLeaves", _s...)
+}
+
+func (_m *MockTrillianLogClient) AddWitnessSignature(_param0 context.Context, _param1 *trillian.AddWitnessSignatureRequest, _param2 ...grpc.CallOption) (*trillian.AddWitnessSignatureResponse, error) {
+	_s := []interface{}{_param0, _param1}
+	for _, _x := range _param2 {
+		_s = append(_s, _x)
+	}
+	ret := _m.ctrl.Call(_m, "AddWitnessSignature", _s...)
+	ret0, _ := ret[0].(*trillian.AddWitnessSignatureResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTrillianLogClientRecorder) AddWitnessSignature(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	_s := append([]interface{}{arg0, arg1}, arg2...)
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "AddWitnessSignature", _s...)
+}
+
 func (_m *MockTrillianLogClient) GetConsistencyProof(_param0 context.Context, _param1 *trillian.GetConsistencyProofRequest, _param2 ...grpc.CallOption) (*trillian.GetConsistencyProofResponse, error) {
 	_s := []interface{}{_param0, _param1}
 	for _, _x := range _param2 {
@@ -47,6 +95,22 @@ func (_mr *_MockTrillianLogClientRecorder) GetConsistencyProof(arg0, arg1 interf
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetConsistencyProof", _s...)
 }
 
+func (_m *MockTrillianLogClient) GetConsistencyProofHistory(_param0 context.Context, _param1 *trillian.GetConsistencyProofHistoryRequest, _param2 ...grpc.CallOption) (trillian.TrillianLog_GetConsistencyProofHistoryClient, error) {
+	_s := []interface{}{_param0, _param1}
+	for _, _x := range _param2 {
+		_s = append(_s, _x)
+	}
+	ret := _m.ctrl.Call(_m, "GetConsistencyProofHistory", _s...)
+	ret0, _ := ret[0].(trillian.TrillianLog_GetConsistencyProofHistoryClient)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTrillianLogClientRecorder) GetConsistencyProofHistory(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	_s := append([]interface{}{arg0, arg1}, arg2...)
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetConsistencyProofHistory", _s...)
+}
+
 func (_m *MockTrillianLogClient) GetEntryAndProof(_param0 context.Context, _param1 *trillian.GetEntryAndProofRequest, _param2 ...grpc.CallOption) (*trillian.GetEntryAndProofResponse, error) {
 	_s := []interface{}{_param0, _param1}
 	for _, _x := range _param2 {
@@ -143,6 +207,22 @@ func (_mr *_MockTrillianLogClientRecorder) GetLeavesByIndex(arg0, arg1 interface
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByIndex", _s...)
 }
 
+func (_m *MockTrillianLogClient) GetLeavesByIndexStream(_param0 context.Context, _param1 *trillian.GetLeavesByIndexRequest, _param2 ...grpc.CallOption) (trillian.TrillianLog_GetLeavesByIndexStreamClient, error) {
+	_s := []interface{}{_param0, _param1}
+	for _, _x := range _param2 {
+		_s = append(_s, _x)
+	}
+	ret := _m.ctrl.Call(_m, "GetLeavesByIndexStream", _s...)
+	ret0, _ := ret[0].(trillian.TrillianLog_GetLeavesByIndexStreamClient)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTrillianLogClientRecorder) GetLeavesByIndexStream(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	_s := append([]interface{}{arg0, arg1}, arg2...)
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByIndexStream", _s...)
+}
+
 func (_m *MockTrillianLogClient) GetSequencedLeafCount(_param0 context.Context, _param1 *trillian.GetSequencedLeafCountRequest, _param2 ...grpc.CallOption) (*trillian.GetSequencedLeafCountResponse, error) {
 	_s := []interface{}{_param0, _param1}
 	for _, _x := range _param2 {
@@ -212,6 +292,39 @@ func (_m *MockTrillianLogServer) EXPECT() *_MockTrillianLogServerRecorder {
 	return _m.recorder
 }
 
+func (_m *MockTrillianLogServer) AddObservedRoot(_param0 context.Context, _param1 *trillian.AddObservedRootRequest) (*trillian.AddObservedRootResponse, error) {
+	ret := _m.ctrl.Call(_m, "AddObservedRoot", _param0, _param1)
+	ret0, _ := ret[0].(*trillian.AddObservedRootResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTrillianLogServerRecorder) AddObservedRoot(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "AddObservedRoot", arg0, arg1)
+}
+
+func (_m *MockTrillianLogServer) AddSequencedLeaves(_param0 context.Context, _param1 *trillian.AddSequencedLeavesRequest) (*trillian.AddSequencedLeavesResponse, error) {
+	ret := _m.ctrl.Call(_m, "AddSequencedLeaves", _param0, _param1)
+	ret0, _ := ret[0].(*trillian.AddSequencedLeavesResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTrillianLogServerRecorder) AddSequencedLeaves(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "AddSequencedLeaves", arg0, arg1)
+}
+
+func (_m *MockTrillianLogServer) AddWitnessSignature(_param0 context.Context, _param1 *trillian.AddWitnessSignatureRequest) (*trillian.AddWitnessSignatureResponse, error) {
+	ret := _m.ctrl.Call(_m, "AddWitnessSignature", _param0, _param1)
+	ret0, _ := ret[0].(*trillian.AddWitnessSignatureResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTrillianLogServerRecorder) AddWitnessSignature(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "AddWitnessSignature", arg0, arg1)
+}
+
 func (_m *MockTrillianLogServer) GetConsistencyProof(_param0 context.Context, _param1 *trillian.GetConsistencyProofRequest) (*trillian.GetConsistencyProofResponse, error) {
 	ret := _m.ctrl.Call(_m, "GetConsistencyProof", _param0, _param1)
 	ret0, _ := ret[0].(*trillian.GetConsistencyProofResponse)
@@ -223,6 +336,16 @@ func (_mr *_MockTrillianLogServerRecorder) GetConsistencyProof(arg0, arg1 interf
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetConsistencyProof", arg0, arg1)
 }
 
+func (_m *MockTrillianLogServer) GetConsistencyProofHistory(_param0 *trillian.GetConsistencyProofHistoryRequest, _param1 trillian.TrillianLog_GetConsistencyProofHistoryServer) error {
+	ret := _m.ctrl.Call(_m, "GetConsistencyProofHistory", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockTrillianLogServerRecorder) GetConsistencyProofHistory(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetConsistencyProofHistory", arg0, arg1)
+}
+
 func (_m *MockTrillianLogServer) GetEntryAndProof(_param0 context.Context, _param1 *trillian.GetEntryAndProofRequest) (*trillian.GetEntryAndProofResponse, error) {
 	ret := _m.ctrl.Call(_m, "GetEntryAndProof", _param0, _param1)
 	ret0, _ := ret[0].(*trillian.GetEntryAndProofResponse)
@@ -289,6 +412,16 @@ func (_mr *_MockTrillianLogServerRecorder) GetLeavesByIndex(arg0, arg1 interface
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByIndex", arg0, arg1)
 }
 
+func (_m *MockTrillianLogServer) GetLeavesByIndexStream(_param0 *trillian.GetLeavesByIndexRequest, _param1 trillian.TrillianLog_GetLeavesByIndexStreamServer) error {
+	ret := _m.ctrl.Call(_m, "GetLeavesByIndexStream", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockTrillianLogServerRecorder) GetLeavesByIndexStream(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByIndexStream", arg0, arg1)
+}
+
 func (_m *MockTrillianLogServer) GetSequencedLeafCount(_param0 context.Context, _param1 *trillian.GetSequencedLeafCountRequest) (*trillian.GetSequencedLeafCountResponse, error) {
 	ret := _m.ctrl.Call(_m, "GetSequencedLeafCount", _param0, _param1)
 	ret0, _ := ret[0].(*trillian.GetSequencedLeafCountResponse)
@@ -0,0 +1,332 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package alert checks exported metrics against thresholds and notifies hooks when
+// they're crossed, so deployments without a monitoring system still hear about
+// e.g. a growing sequencing backlog, stale roots or failing RPCs.
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+var stats = expvar.NewMap("alerts")
+
+// Rule is a threshold on an expvar variable. For maps, such as the per log metrics,
+// each key is checked separately.
+type Rule struct {
+	// Metric is the name the variable is published under.
+	Metric string
+	// Rate, if true, checks the increase of Metric per second since the last check,
+	// rather than its value.
+	Rate bool
+	// Divisor, if set, is the name of a second variable with the same keys, and the
+	// increase of Metric since the last check is divided by the increase of Divisor,
+	// e.g. to check the fraction of requests which failed.
+	Divisor string
+	// Threshold is the value above which the rule fires.
+	Threshold float64
+}
+
+// String returns the rule in the form understood by ParseRules.
+func (r Rule) String() string {
+	t := strconv.FormatFloat(r.Threshold, 'g', -1, 64)
+	switch {
+	case r.Divisor != "":
+		return fmt.Sprintf("ratio:%s:%s>%s", r.Metric, r.Divisor, t)
+	case r.Rate:
+		return fmt.Sprintf("rate:%s>%s", r.Metric, t)
+	}
+	return fmt.Sprintf("%s>%s", r.Metric, t)
+}
+
+// ParseRules parses a comma separated list of rules. A rule is metric>threshold to
+// check the value of metric, rate:metric>threshold to check its increase per second,
+// or ratio:metric:total>threshold to check its increase over the increase of total,
+// e.g. "sequencer-unsequenced-leaves>10000,ratio:ct/example/errors-by-handler:ct/example/requests-by-handler>0.05".
+func ParseRules(spec string) ([]Rule, error) {
+	var rules []Rule
+	for _, s := range strings.Split(spec, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		i := strings.LastIndex(s, ">")
+		if i < 0 {
+			return nil, fmt.Errorf("alert rule %q has no >threshold", s)
+		}
+		threshold, err := strconv.ParseFloat(s[i+1:], 64)
+		if err != nil {
+			return nil, fmt.Errorf("alert rule %q has an invalid threshold: %v", s, err)
+		}
+		r := Rule{Metric: s[:i], Threshold: threshold}
+		switch {
+		case strings.HasPrefix(r.Metric, "rate:"):
+			r.Metric, r.Rate = strings.TrimPrefix(r.Metric, "rate:"), true
+		case strings.HasPrefix(r.Metric, "ratio:"):
+			parts := strings.Split(strings.TrimPrefix(r.Metric, "ratio:"), ":")
+			if len(parts) != 2 || parts[1] == "" {
+				return nil, fmt.Errorf("alert rule %q must be ratio:metric:total>threshold", s)
+			}
+			r.Metric, r.Divisor = parts[0], parts[1]
+		}
+		if r.Metric == "" {
+			return nil, fmt.Errorf("alert rule %q has no metric", s)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// Alert is sent to Notifiers when a rule starts or stops firing.
+type Alert struct {
+	// Rule is the rule's String.
+	Rule string `json:"rule"`
+	// Key is the key of the map entry that crossed the threshold, e.g. a log ID,
+	// or empty if the metric isn't a map.
+	Key       string  `json:"key,omitempty"`
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+	// Firing is true when the threshold has been crossed, and false when the value
+	// has gone back below it.
+	Firing bool      `json:"firing"`
+	Time   time.Time `json:"time"`
+}
+
+func (a Alert) String() string {
+	state := "resolved"
+	if a.Firing {
+		state = "firing"
+	}
+	if a.Key != "" {
+		return fmt.Sprintf("alert %s %s for %s: value %g", a.Rule, state, a.Key, a.Value)
+	}
+	return fmt.Sprintf("alert %s %s: value %g", a.Rule, state, a.Value)
+}
+
+// Notifier is told about alerts.
+type Notifier interface {
+	Notify(ctx context.Context, a Alert) error
+}
+
+// NotifierFunc adapts a function to a Notifier.
+type NotifierFunc func(ctx context.Context, a Alert) error
+
+// Notify calls f.
+func (f NotifierFunc) Notify(ctx context.Context, a Alert) error {
+	return f(ctx, a)
+}
+
+// LogNotifier logs alerts as warnings.
+var LogNotifier = NotifierFunc(func(ctx context.Context, a Alert) error {
+	glog.Warning(a)
+	return nil
+})
+
+// Webhook is a Notifier POSTing alerts to a URL, as JSON encoded Alerts.
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhook returns a Webhook for url, whose requests time out after timeout.
+func NewWebhook(url string, timeout time.Duration) *Webhook {
+	return &Webhook{URL: url, Client: &http.Client{Timeout: timeout}}
+}
+
+// Notify POSTs a to w.URL.
+func (w *Webhook) Notify(ctx context.Context, a Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	rsp, err := w.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode < 200 || rsp.StatusCode > 299 {
+		return fmt.Errorf("got HTTP status %v", rsp.Status)
+	}
+	return nil
+}
+
+// Monitor periodically checks metrics against its rules, and notifies its Notifiers
+// each time an entry crosses a threshold, and again when it goes back below it.
+type Monitor struct {
+	rules      []Rule
+	notifiers  []Notifier
+	timeSource util.TimeSource
+
+	// firing holds the rule and key of the entries over their threshold.
+	firing map[string]bool
+	// counters holds the value of each variable and key used by Rate and Divisor
+	// rules at the last check.
+	counters  map[string]float64
+	lastCheck time.Time
+}
+
+// NewMonitor returns a Monitor checking rules.
+func NewMonitor(rules []Rule, timeSource util.TimeSource, notifiers ...Notifier) *Monitor {
+	return &Monitor{
+		rules:      rules,
+		notifiers:  notifiers,
+		timeSource: timeSource,
+		firing:     make(map[string]bool),
+		counters:   make(map[string]float64),
+	}
+}
+
+// Run calls Check every interval until ctx is done.
+func (m *Monitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Check(ctx)
+		}
+	}
+}
+
+// Check evaluates the rules once and sends alerts for the entries which crossed
+// their threshold since the previous check. Rate and Divisor rules need a previous
+// check to compare against, so are only evaluated from the second check on.
+func (m *Monitor) Check(ctx context.Context) {
+	now := m.timeSource.Now()
+	elapsed := now.Sub(m.lastCheck).Seconds()
+	first := m.lastCheck.IsZero()
+	m.lastCheck = now
+
+	counters := make(map[string]float64)
+	for _, r := range m.rules {
+		values := m.values(r, counters, elapsed, first)
+		rule := r.String()
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value := values[key]
+			id := rule + "\x00" + key
+			if firing := value > r.Threshold; firing != m.firing[id] {
+				if firing {
+					m.firing[id] = true
+					stats.Add("fired", 1)
+				} else {
+					delete(m.firing, id)
+					stats.Add("resolved", 1)
+				}
+				m.notify(ctx, Alert{Rule: rule, Key: key, Value: value, Threshold: r.Threshold, Firing: firing, Time: now})
+			}
+		}
+	}
+	m.counters = counters
+}
+
+// values returns the values r checks, by key. The counters r reads are recorded in
+// counters for the next check.
+func (m *Monitor) values(r Rule, counters map[string]float64, elapsed float64, first bool) map[string]float64 {
+	current := readVar(r.Metric)
+	if !r.Rate && r.Divisor == "" {
+		return current
+	}
+	var totals map[string]float64
+	if r.Divisor != "" {
+		totals = readVar(r.Divisor)
+	}
+	for key, v := range current {
+		counters[r.Metric+"\x00"+key] = v
+	}
+	for key, v := range totals {
+		counters[r.Divisor+"\x00"+key] = v
+	}
+	if first || elapsed <= 0 {
+		return nil
+	}
+
+	values := make(map[string]float64)
+	for key, v := range current {
+		prev, ok := m.counters[r.Metric+"\x00"+key]
+		if !ok || v < prev {
+			// New entry, or the counter was reset.
+			continue
+		}
+		if r.Rate {
+			values[key] = (v - prev) / elapsed
+			continue
+		}
+		prevTotal, ok := m.counters[r.Divisor+"\x00"+key]
+		if !ok {
+			continue
+		}
+		total := totals[key] - prevTotal
+		if total <= 0 {
+			// Nothing happened since the last check.
+			values[key] = 0
+			continue
+		}
+		values[key] = (v - prev) / total
+	}
+	return values
+}
+
+func (m *Monitor) notify(ctx context.Context, a Alert) {
+	for _, n := range m.notifiers {
+		if err := n.Notify(ctx, a); err != nil {
+			stats.Add("notify-failures", 1)
+			glog.Warningf("Failed to send %v: %v", a, err)
+		}
+	}
+}
+
+// readVar returns the numeric values of the expvar variable published as name, by
+// key for maps, and under the empty key otherwise. Non-numeric values are skipped.
+func readVar(name string) map[string]float64 {
+	values := make(map[string]float64)
+	switch v := expvar.Get(name).(type) {
+	case nil:
+	case *expvar.Map:
+		v.Do(func(kv expvar.KeyValue) {
+			if f, err := strconv.ParseFloat(kv.Value.String(), 64); err == nil {
+				values[kv.Key] = f
+			}
+		})
+	default:
+		if f, err := strconv.ParseFloat(v.String(), 64); err == nil {
+			values[""] = f
+		}
+	}
+	return values
+}
@@ -0,0 +1,146 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alert
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+func TestParseRules(t *testing.T) {
+	for _, test := range []struct {
+		spec    string
+		want    []Rule
+		wantErr bool
+	}{
+		{spec: ""},
+		{spec: "backlog>100", want: []Rule{{Metric: "backlog", Threshold: 100}}},
+		{
+			spec: "backlog>100, rate:a/errors>0.5,ratio:a/errors:a/requests>0.05",
+			want: []Rule{
+				{Metric: "backlog", Threshold: 100},
+				{Metric: "a/errors", Rate: true, Threshold: 0.5},
+				{Metric: "a/errors", Divisor: "a/requests", Threshold: 0.05},
+			},
+		},
+		{spec: "backlog", wantErr: true},
+		{spec: "backlog>lots", wantErr: true},
+		{spec: ">100", wantErr: true},
+		{spec: "ratio:a/errors>0.05", wantErr: true},
+	} {
+		got, err := ParseRules(test.spec)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("ParseRules(%q)=(_, %v), want err? %v", test.spec, err, test.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseRules(%q)=%+v, want %+v", test.spec, got, test.want)
+		}
+		for _, r := range got {
+			if rt, err := ParseRules(r.String()); err != nil || !reflect.DeepEqual(rt, []Rule{r}) {
+				t.Errorf("ParseRules(%q)=(%+v, %v), want %+v", r.String(), rt, err, r)
+			}
+		}
+	}
+}
+
+func TestMonitor(t *testing.T) {
+	backlog := expvar.NewMap("test-alert-backlog")
+	errs := expvar.NewMap("test-alert-errors")
+	requests := expvar.NewMap("test-alert-requests")
+	rules := []Rule{
+		{Metric: "test-alert-backlog", Threshold: 100},
+		{Metric: "test-alert-errors", Divisor: "test-alert-requests", Threshold: 0.1},
+	}
+
+	var got []Alert
+	ts := &util.FakeTimeSource{FakeTime: time.Unix(1000, 0)}
+	m := NewMonitor(rules, ts, NotifierFunc(func(ctx context.Context, a Alert) error {
+		got = append(got, a)
+		return nil
+	}))
+
+	for _, step := range []struct {
+		desc       string
+		backlog    map[string]int64
+		errs       map[string]int64
+		requests   map[string]int64
+		wantFiring []string
+		wantKeys   []string
+	}{
+		{desc: "belowThresholds", backlog: map[string]int64{"1": 50}, errs: map[string]int64{"get": 1}, requests: map[string]int64{"get": 100}},
+		{desc: "backlogGrows", backlog: map[string]int64{"1": 150, "2": 10}, errs: map[string]int64{"get": 1}, requests: map[string]int64{"get": 100}, wantFiring: []string{"true"}, wantKeys: []string{"1"}},
+		{desc: "stillFiring", backlog: map[string]int64{"1": 200}, errs: map[string]int64{"get": 2}, requests: map[string]int64{"get": 100}},
+		{desc: "errorsAndResolved", backlog: map[string]int64{"1": -350}, errs: map[string]int64{"get": 5}, requests: map[string]int64{"get": 10}, wantFiring: []string{"false", "true"}, wantKeys: []string{"1", "get"}},
+		{desc: "noRequests", errs: map[string]int64{"get": 0}, requests: map[string]int64{"get": 0}, wantFiring: []string{"false"}, wantKeys: []string{"get"}},
+	} {
+		for key, n := range step.backlog {
+			backlog.Add(key, n)
+		}
+		for key, n := range step.errs {
+			errs.Add(key, n)
+		}
+		for key, n := range step.requests {
+			requests.Add(key, n)
+		}
+		got = nil
+		ts.FakeTime = ts.FakeTime.Add(time.Minute)
+		m.Check(context.Background())
+
+		var firing, keys []string
+		for _, a := range got {
+			firing = append(firing, strconv.FormatBool(a.Firing))
+			keys = append(keys, a.Key)
+		}
+		if !reflect.DeepEqual(firing, step.wantFiring) || !reflect.DeepEqual(keys, step.wantKeys) {
+			t.Errorf("%v: Check() sent %v, want firing %v for keys %v", step.desc, got, step.wantFiring, step.wantKeys)
+		}
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var got Alert
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("Decode()=%v", err)
+		}
+	}))
+	defer srv.Close()
+
+	want := Alert{Rule: "backlog>100", Key: "1", Value: 150, Threshold: 100, Firing: true, Time: time.Unix(1000, 0).UTC()}
+	if err := NewWebhook(srv.URL, time.Second).Notify(context.Background(), want); err != nil {
+		t.Fatalf("Notify()=%v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("webhook got %+v, want %+v", got, want)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	if err := NewWebhook(failing.URL, time.Second).Notify(context.Background(), want); err == nil {
+		t.Error("Notify() to a failing server succeeded, want error")
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package logging logs messages along with the tree and request IDs of the context
+// they're about, either through glog or as JSON objects, one per line.
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/util"
+)
+
+var output = struct {
+	mu   sync.Mutex
+	json bool
+	out  io.Writer
+}{out: os.Stderr}
+
+// SetJSON makes messages be written to out as JSON objects if it isn't nil, rather
+// than to glog. glog's own messages are unaffected.
+func SetJSON(out io.Writer) {
+	output.mu.Lock()
+	defer output.mu.Unlock()
+	output.json = out != nil
+	output.out = out
+}
+
+// entry is a message in JSON form.
+type entry struct {
+	Time      string `json:"time"`
+	Severity  string `json:"severity"`
+	Caller    string `json:"caller"`
+	TreeID    *int64 `json:"tree_id,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	Message   string `json:"message"`
+}
+
+// V reports whether verbose logging at level is enabled by glog's --v flag.
+func V(level glog.Level) bool {
+	return bool(glog.V(level))
+}
+
+// Infof logs a message about ctx at INFO severity.
+func Infof(ctx context.Context, format string, args ...interface{}) {
+	logf(ctx, "INFO", glog.InfoDepth, format, args...)
+}
+
+// Warningf logs a message about ctx at WARNING severity.
+func Warningf(ctx context.Context, format string, args ...interface{}) {
+	logf(ctx, "WARNING", glog.WarningDepth, format, args...)
+}
+
+// Errorf logs a message about ctx at ERROR severity.
+func Errorf(ctx context.Context, format string, args ...interface{}) {
+	logf(ctx, "ERROR", glog.ErrorDepth, format, args...)
+}
+
+// logf is called by the exported functions, so the message's caller is 2 frames up.
+func logf(ctx context.Context, severity string, glogDepth func(int, ...interface{}), f string, args ...interface{}) {
+	msg := fmt.Sprintf(f, args...)
+	output.mu.Lock()
+	defer output.mu.Unlock()
+	if !output.json {
+		glogDepth(2, util.TreeIDPrefix(ctx), ": ", msg)
+		return
+	}
+
+	e := entry{
+		Time:      time.Now().UTC().Format(time.RFC3339Nano),
+		Severity:  severity,
+		RequestID: util.RequestID(ctx),
+		Message:   msg,
+	}
+	if _, file, line, ok := runtime.Caller(2); ok {
+		e.Caller = fmt.Sprintf("%s:%d", filepath.Base(file), line)
+	}
+	if id, ok := util.TreeID(ctx); ok {
+		e.TreeID = &id
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		glog.Errorf("Failed to marshal log entry %+v: %v", e, err)
+		return
+	}
+	output.out.Write(append(b, '\n'))
+}
+
+// LevelHandler returns an HTTP handler for glog's verbosity, so it can be changed
+// without restarting. GET returns the current --v level, POST or PUT with a v
+// parameter sets it.
+func LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v := flag.Lookup("v")
+		if v == nil {
+			http.Error(w, "glog's --v flag isn't registered", http.StatusInternalServerError)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost, http.MethodPut:
+			level := r.FormValue("v")
+			if _, err := strconv.ParseInt(level, 10, 32); err != nil {
+				http.Error(w, fmt.Sprintf("invalid level %q", level), http.StatusBadRequest)
+				return
+			}
+			if err := v.Value.Set(level); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			glog.Infof("Logging verbosity set to %v", level)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		fmt.Fprintf(w, "v=%s\n", v.Value)
+	})
+}
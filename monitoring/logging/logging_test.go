@@ -0,0 +1,88 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/trillian/util"
+)
+
+func TestJSON(t *testing.T) {
+	var buf bytes.Buffer
+	SetJSON(&buf)
+	defer SetJSON(nil)
+
+	ctx := util.NewRequestIDContext(util.NewLogContext(context.Background(), 12), "abc")
+	Warningf(ctx, "queued %d leaves", 3)
+	Infof(context.Background(), "no IDs")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("logged %d lines, want 2: %q", len(lines), buf.String())
+	}
+	var e entry
+	if err := json.Unmarshal([]byte(lines[0]), &e); err != nil {
+		t.Fatalf("Unmarshal(%q)=%v", lines[0], err)
+	}
+	if e.Severity != "WARNING" || e.Message != "queued 3 leaves" || e.TreeID == nil || *e.TreeID != 12 || e.RequestID != "abc" {
+		t.Errorf("logged %q, want a WARNING about tree 12 and request abc", lines[0])
+	}
+	if !strings.HasPrefix(e.Caller, "logging_test.go:") {
+		t.Errorf("logged caller %q, want logging_test.go", e.Caller)
+	}
+	if strings.Contains(lines[1], "tree_id") || strings.Contains(lines[1], "request_id") {
+		t.Errorf("logged %q, want no tree or request ID", lines[1])
+	}
+}
+
+func TestLevelHandler(t *testing.T) {
+	defer LevelHandler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/?v=0", nil))
+
+	for _, test := range []struct {
+		method, level string
+		wantCode      int
+		wantBody      string
+	}{
+		{method: http.MethodPost, level: "3", wantCode: http.StatusOK, wantBody: "v=3\n"},
+		{method: http.MethodGet, wantCode: http.StatusOK, wantBody: "v=3\n"},
+		{method: http.MethodPut, level: "high", wantCode: http.StatusBadRequest},
+		{method: http.MethodDelete, wantCode: http.StatusMethodNotAllowed},
+		{method: http.MethodPut, level: "1", wantCode: http.StatusOK, wantBody: "v=1\n"},
+	} {
+		target := "/debug/loglevel"
+		if test.level != "" {
+			target += "?v=" + url.QueryEscape(test.level)
+		}
+		w := httptest.NewRecorder()
+		LevelHandler().ServeHTTP(w, httptest.NewRequest(test.method, target, nil))
+		if w.Code != test.wantCode {
+			t.Errorf("%v %v: status %v, want %v", test.method, target, w.Code, test.wantCode)
+		}
+		if test.wantBody != "" && w.Body.String() != test.wantBody {
+			t.Errorf("%v %v: body %q, want %q", test.method, target, w.Body.String(), test.wantBody)
+		}
+		if test.level == "1" && !V(1) {
+			t.Error("V(1)=false after setting level 1")
+		}
+	}
+}
@@ -88,6 +88,8 @@ func dump() {
 		qps := float64(delta) / duration.Seconds()
 		glog.Infof("%v: %v (%.1f qps)", key, current, qps)
 	}
+	dumpGauges()
+	dumpHistograms()
 }
 
 // DumpToLog arranges for all metrics to be logged at a regular
@@ -0,0 +1,73 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metric
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/golang/glog"
+)
+
+// A Gauge is a metric whose value can go up and down.
+type Gauge interface {
+	Set(n int64)
+}
+
+type gauge struct {
+	mu    sync.Mutex
+	value int64
+}
+
+func (g *gauge) Set(n int64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.value = n
+}
+
+var gauges = struct {
+	mu sync.Mutex
+	m  map[string]*gauge
+}{m: make(map[string]*gauge)}
+
+// NewGauge defines a metric holding the last value it was set to. The name should be
+// unique within a binary, including among counters.
+func NewGauge(name string) Gauge {
+	g := &gauge{}
+	gauges.mu.Lock()
+	defer gauges.mu.Unlock()
+	if dup := gauges.m[name]; dup != nil {
+		glog.Fatal("duplicate metric name registered: ", name)
+	}
+	gauges.m[name] = g
+	return g
+}
+
+func dumpGauges() {
+	gauges.mu.Lock()
+	defer gauges.mu.Unlock()
+	keys := make([]string, 0, len(gauges.m))
+	for k := range gauges.m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		g := gauges.m[key]
+		g.mu.Lock()
+		value := g.value
+		g.mu.Unlock()
+		glog.Infof("%v: %v", key, value)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metric
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/golang/glog"
+)
+
+// LatencyBuckets are histogram bucket bounds in milliseconds, suitable for the
+// latency of storage operations.
+var LatencyBuckets = []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000, 2000, 5000}
+
+// A Histogram is a metric counting the values observed into buckets.
+type Histogram interface {
+	Observe(v float64)
+}
+
+type histogram struct {
+	mu     sync.Mutex
+	bounds []float64
+	// counts[i] is the number of values <= bounds[i] and > bounds[i-1], the last
+	// entry counts those above every bound.
+	counts []int64
+	count  int64
+	sum    float64
+}
+
+func (h *histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.bounds, v)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.counts[i]++
+	h.count++
+	h.sum += v
+}
+
+// String formats the count and mean of the values, followed by the count in each
+// bucket.
+func (h *histogram) String() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var b bytes.Buffer
+	mean := 0.0
+	if h.count > 0 {
+		mean = h.sum / float64(h.count)
+	}
+	fmt.Fprintf(&b, "count=%d mean=%.1f", h.count, mean)
+	for i, bound := range h.bounds {
+		fmt.Fprintf(&b, " <=%v:%d", bound, h.counts[i])
+	}
+	fmt.Fprintf(&b, " >%v:%d", h.bounds[len(h.bounds)-1], h.counts[len(h.bounds)])
+	return b.String()
+}
+
+var histograms = struct {
+	mu sync.Mutex
+	m  map[string]*histogram
+}{m: make(map[string]*histogram)}
+
+// NewHistogram defines a metric counting values into buckets with the given upper
+// bounds, which must be increasing. The name should be unique within a binary,
+// including among counters and gauges.
+func NewHistogram(name string, bounds []float64) Histogram {
+	if len(bounds) == 0 || !sort.Float64sAreSorted(bounds) {
+		glog.Fatalf("invalid bounds for histogram %v: %v", name, bounds)
+	}
+	h := &histogram{bounds: bounds, counts: make([]int64, len(bounds)+1)}
+	histograms.mu.Lock()
+	defer histograms.mu.Unlock()
+	if dup := histograms.m[name]; dup != nil {
+		glog.Fatal("duplicate metric name registered: ", name)
+	}
+	histograms.m[name] = h
+	return h
+}
+
+func dumpHistograms() {
+	histograms.mu.Lock()
+	defer histograms.mu.Unlock()
+	keys := make([]string, 0, len(histograms.m))
+	for k := range histograms.m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		glog.Infof("%v: %v", key, histograms.m[key])
+	}
+}
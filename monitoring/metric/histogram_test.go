@@ -0,0 +1,46 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metric
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram("test_histogram", []float64{1, 10})
+	for _, v := range []float64{0.5, 1, 2, 10, 11, 40} {
+		h.Observe(v)
+	}
+	if got, want := fmt.Sprint(h), "count=6 mean=10.8 <=1:2 <=10:2 >10:2"; got != want {
+		t.Errorf("histogram=%q, want %q", got, want)
+	}
+}
+
+func TestHistogramSamples(t *testing.T) {
+	h := &histogram{bounds: []float64{1, 10}, counts: make([]int64, 3)}
+	for _, v := range []float64{0.5, 2, 11, 40} {
+		h.Observe(v)
+	}
+	var got []string
+	for _, s := range h.samples("h") {
+		got = append(got, fmt.Sprintf("%s%v=%v", s.Name, s.Labels, s.Value))
+	}
+	want := []string{"h_bucket map[le:1]=1", "h_bucket map[le:10]=2", "h_bucket map[le:+Inf]=4", "h_count map[]=4", "h_sum map[]=53.5"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("samples()=%q, want %q", got, want)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metric
+
+import (
+	"math"
+	"strconv"
+)
+
+// Sample is the value of one series of a metric when a Snapshot was taken. Series
+// are named as in OpenMetrics, so a histogram is reported as name_bucket series
+// with an le label holding each cumulative bucket's upper bound, plus name_count and
+// name_sum.
+type Sample struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+	// Counter is true if the value only ever increases.
+	Counter bool
+}
+
+// Snapshot returns the current values of every counter, gauge and histogram.
+func Snapshot() []Sample {
+	var samples []Sample
+
+	metrics.mu.Lock()
+	for name, c := range metrics.m {
+		c.mu.Lock()
+		samples = append(samples, Sample{Name: name, Value: float64(c.value), Counter: true})
+		c.mu.Unlock()
+	}
+	metrics.mu.Unlock()
+
+	gauges.mu.Lock()
+	for name, g := range gauges.m {
+		g.mu.Lock()
+		samples = append(samples, Sample{Name: name, Value: float64(g.value)})
+		g.mu.Unlock()
+	}
+	gauges.mu.Unlock()
+
+	histograms.mu.Lock()
+	for name, h := range histograms.m {
+		samples = append(samples, h.samples(name)...)
+	}
+	histograms.mu.Unlock()
+	return samples
+}
+
+func (h *histogram) samples(name string) []Sample {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	samples := make([]Sample, 0, len(h.counts)+2)
+	var total int64
+	for i, n := range h.counts {
+		total += n
+		le := math.Inf(1)
+		if i < len(h.bounds) {
+			le = h.bounds[i]
+		}
+		samples = append(samples, Sample{
+			Name:    name + "_bucket",
+			Labels:  map[string]string{"le": strconv.FormatFloat(le, 'g', -1, 64)},
+			Value:   float64(total),
+			Counter: true,
+		})
+	}
+	return append(samples,
+		Sample{Name: name + "_count", Value: float64(h.count), Counter: true},
+		Sample{Name: name + "_sum", Value: h.sum, Counter: true})
+}
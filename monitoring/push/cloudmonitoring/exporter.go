@@ -0,0 +1,111 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cloudmonitoring provides a push.Exporter writing to Google Cloud Monitoring.
+package cloudmonitoring
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/trillian/monitoring/push"
+	"golang.org/x/net/context"
+	"golang.org/x/oauth2/google"
+	monitoring "google.golang.org/api/monitoring/v3"
+)
+
+// maxTimeSeries is the most time series Cloud Monitoring accepts in one request.
+const maxTimeSeries = 200
+
+// Exporter writes samples as custom metrics named custom.googleapis.com/trillian/
+// followed by the sample's name. Counters are written as cumulative metrics, and
+// everything else as gauges.
+type Exporter struct {
+	timeSeries *monitoring.ProjectsTimeSeriesService
+	project    string
+	resource   *monitoring.MonitoredResource
+}
+
+// NewExporter returns an Exporter writing to the given project, for the monitored
+// resource of type resourceType with labels. The project_id label is set to project
+// unless given. Application default credentials are used.
+func NewExporter(ctx context.Context, project, resourceType string, labels map[string]string) (*Exporter, error) {
+	client, err := google.DefaultClient(ctx, monitoring.MonitoringWriteScope)
+	if err != nil {
+		return nil, err
+	}
+	svc, err := monitoring.New(client)
+	if err != nil {
+		return nil, err
+	}
+	resourceLabels := map[string]string{"project_id": project}
+	for k, v := range labels {
+		resourceLabels[k] = v
+	}
+	return &Exporter{
+		timeSeries: svc.Projects.TimeSeries,
+		project:    project,
+		resource:   &monitoring.MonitoredResource{Type: resourceType, Labels: resourceLabels},
+	}, nil
+}
+
+// Export implements push.Exporter.
+func (e *Exporter) Export(ctx context.Context, s *push.Snapshot) error {
+	start := s.Start.UTC().Format(time.RFC3339Nano)
+	end := s.Time.UTC().Format(time.RFC3339Nano)
+	series := make([]*monitoring.TimeSeries, 0, len(s.Samples))
+	for _, sample := range s.Samples {
+		value := sample.Value
+		ts := &monitoring.TimeSeries{
+			Metric:     &monitoring.Metric{Type: "custom.googleapis.com/trillian/" + metricName(sample.Name), Labels: sample.Labels},
+			Resource:   e.resource,
+			MetricKind: "GAUGE",
+			ValueType:  "DOUBLE",
+			Points: []*monitoring.Point{{
+				Interval: &monitoring.TimeInterval{EndTime: end},
+				Value:    &monitoring.TypedValue{DoubleValue: &value},
+			}},
+		}
+		if sample.Counter {
+			ts.MetricKind = "CUMULATIVE"
+			ts.Points[0].Interval.StartTime = start
+		}
+		series = append(series, ts)
+	}
+
+	for len(series) > 0 {
+		n := len(series)
+		if n > maxTimeSeries {
+			n = maxTimeSeries
+		}
+		req := &monitoring.CreateTimeSeriesRequest{TimeSeries: series[:n]}
+		if _, err := e.timeSeries.Create("projects/"+e.project, req).Context(ctx).Do(); err != nil {
+			return err
+		}
+		series = series[n:]
+	}
+	return nil
+}
+
+// metricName replaces the characters not allowed in a metric type, e.g. the dashes
+// in expvar names, with underscores.
+func metricName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '/':
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,92 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cloudwatch provides a push.Exporter writing to AWS CloudWatch.
+package cloudwatch
+
+import (
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/google/trillian/monitoring/push"
+	"golang.org/x/net/context"
+)
+
+// maxMetricData is the most data points CloudWatch accepts in one request.
+const maxMetricData = 20
+
+// Exporter writes samples as custom metrics in a namespace, with the resource labels
+// and the sample's own labels as dimensions. CloudWatch has no cumulative metrics, so
+// counters are written as their running total, which its RATE function turns into
+// a rate.
+type Exporter struct {
+	client     *cloudwatch.CloudWatch
+	namespace  string
+	dimensions []*cloudwatch.Dimension
+}
+
+// NewExporter returns an Exporter writing to namespace, e.g. Trillian, with labels
+// added to each metric's dimensions. Credentials and the region are taken from the
+// environment as usual for AWS.
+func NewExporter(namespace string, labels map[string]string) (*Exporter, error) {
+	sess, err := session.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	return &Exporter{client: cloudwatch.New(sess), namespace: namespace, dimensions: dimensions(labels)}, nil
+}
+
+// Export implements push.Exporter.
+func (e *Exporter) Export(ctx context.Context, s *push.Snapshot) error {
+	data := make([]*cloudwatch.MetricDatum, 0, len(s.Samples))
+	for _, sample := range s.Samples {
+		data = append(data, &cloudwatch.MetricDatum{
+			MetricName: aws.String(sample.Name),
+			Dimensions: append(dimensions(sample.Labels), e.dimensions...),
+			Timestamp:  aws.Time(s.Time),
+			Value:      aws.Float64(sample.Value),
+		})
+	}
+
+	for len(data) > 0 {
+		n := len(data)
+		if n > maxMetricData {
+			n = maxMetricData
+		}
+		if _, err := e.client.PutMetricDataWithContext(ctx, &cloudwatch.PutMetricDataInput{
+			Namespace:  aws.String(e.namespace),
+			MetricData: data[:n],
+		}); err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+// dimensions returns labels as dimensions, sorted by name.
+func dimensions(labels map[string]string) []*cloudwatch.Dimension {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	dims := make([]*cloudwatch.Dimension, 0, len(names))
+	for _, name := range names {
+		dims = append(dims, &cloudwatch.Dimension{Name: aws.String(name), Value: aws.String(labels[name])})
+	}
+	return dims
+}
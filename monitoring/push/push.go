@@ -0,0 +1,149 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package push periodically sends a snapshot of the exported metrics to a monitoring
+// system, for deployments whose HTTP endpoint can't be scraped. The backends are in
+// its subpackages.
+package push
+
+import (
+	"expvar"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/monitoring/metric"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+var stats = expvar.NewMap("metrics-push")
+
+// Snapshot is the set of samples sent in one push.
+type Snapshot struct {
+	// Start is when the Pusher was created, which counters are treated as having
+	// started counting from.
+	Start   time.Time
+	Time    time.Time
+	Samples []metric.Sample
+}
+
+// Exporter sends snapshots to a monitoring system.
+type Exporter interface {
+	Export(ctx context.Context, s *Snapshot) error
+}
+
+// Pusher periodically sends a snapshot of the metrics registered with the metric
+// package, and of the numeric expvar variables, to an Exporter.
+type Pusher struct {
+	exporter   Exporter
+	timeSource util.TimeSource
+	start      time.Time
+}
+
+// NewPusher returns a Pusher sending to exporter.
+func NewPusher(exporter Exporter, timeSource util.TimeSource) *Pusher {
+	return &Pusher{exporter: exporter, timeSource: timeSource, start: timeSource.Now()}
+}
+
+// Run calls Push every interval until ctx is done. Failed pushes are logged, and
+// aren't retried, as the next push sends more recent values.
+func (p *Pusher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.Push(ctx); err != nil {
+				glog.Warningf("Failed to push metrics: %v", err)
+			}
+		}
+	}
+}
+
+// Push sends the current values of the metrics to the Exporter.
+func (p *Pusher) Push(ctx context.Context) error {
+	s := &Snapshot{Start: p.start, Time: p.timeSource.Now(), Samples: Collect()}
+	if err := p.exporter.Export(ctx, s); err != nil {
+		stats.Add("failures", 1)
+		return err
+	}
+	stats.Add("pushes", 1)
+	stats.Add("samples", int64(len(s.Samples)))
+	return nil
+}
+
+// Collect returns the samples of the metric package's metrics and of the numeric
+// expvar variables, sorted by name. The entries of expvar maps, such as the per log
+// metrics, are reported with their key in a key label. Values which aren't finite
+// are skipped, as monitoring systems reject them.
+func Collect() []metric.Sample {
+	var samples []metric.Sample
+	for _, s := range metric.Snapshot() {
+		if !math.IsInf(s.Value, 0) && !math.IsNaN(s.Value) {
+			samples = append(samples, s)
+		}
+	}
+	expvar.Do(func(kv expvar.KeyValue) {
+		if m, ok := kv.Value.(*expvar.Map); ok {
+			m.Do(func(e expvar.KeyValue) {
+				if f, ok := parseValue(e.Value); ok {
+					samples = append(samples, metric.Sample{Name: kv.Key, Labels: map[string]string{"key": e.Key}, Value: f})
+				}
+			})
+			return
+		}
+		if f, ok := parseValue(kv.Value); ok {
+			samples = append(samples, metric.Sample{Name: kv.Key, Value: f})
+		}
+	})
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].Name < samples[j].Name })
+	return samples
+}
+
+func parseValue(v expvar.Var) (float64, bool) {
+	f, err := strconv.ParseFloat(v.String(), 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return 0, false
+	}
+	return f, true
+}
+
+// ParseLabels parses a comma separated list of name=value pairs, e.g. the labels of
+// the monitored resource metrics are reported for.
+func ParseLabels(spec string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, s := range strings.Split(spec, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		i := strings.Index(s, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("label %q must be name=value", s)
+		}
+		name := s[:i]
+		if _, ok := labels[name]; ok {
+			return nil, fmt.Errorf("label %q given more than once", name)
+		}
+		labels[name] = s[i+1:]
+	}
+	return labels, nil
+}
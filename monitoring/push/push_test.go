@@ -0,0 +1,111 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package push
+
+import (
+	"errors"
+	"expvar"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/trillian/monitoring/metric"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+type exporterFunc func(ctx context.Context, s *Snapshot) error
+
+func (f exporterFunc) Export(ctx context.Context, s *Snapshot) error {
+	return f(ctx, s)
+}
+
+func TestPush(t *testing.T) {
+	backlog := expvar.NewMap("test-push-backlog")
+	backlog.Add("1", 5)
+	backlog.Add("2", 7)
+	backlog.Set("3", new(expvar.String))
+	expvar.NewFloat("test-push-float").Set(1.5)
+	metric.NewCounter("test-push-counter").Add(3)
+
+	ts := &util.FakeTimeSource{FakeTime: time.Unix(1000, 0)}
+	var got *Snapshot
+	p := NewPusher(exporterFunc(func(ctx context.Context, s *Snapshot) error {
+		got = s
+		return nil
+	}), ts)
+	ts.FakeTime = time.Unix(1060, 0)
+	if err := p.Push(context.Background()); err != nil {
+		t.Fatalf("Push()=%v", err)
+	}
+	if got == nil {
+		t.Fatal("Push() didn't export a snapshot")
+	}
+	if !got.Start.Equal(time.Unix(1000, 0)) || !got.Time.Equal(time.Unix(1060, 0)) {
+		t.Errorf("Push() exported Start=%v, Time=%v, want %v, %v", got.Start, got.Time, time.Unix(1000, 0), time.Unix(1060, 0))
+	}
+
+	want := map[string]metric.Sample{
+		"test-push-backlog/1": {Name: "test-push-backlog", Labels: map[string]string{"key": "1"}, Value: 5},
+		"test-push-backlog/2": {Name: "test-push-backlog", Labels: map[string]string{"key": "2"}, Value: 7},
+		"test-push-float":     {Name: "test-push-float", Value: 1.5},
+		"test-push-counter":   {Name: "test-push-counter", Value: 3, Counter: true},
+	}
+	found := make(map[string]metric.Sample)
+	for _, s := range got.Samples {
+		id := s.Name
+		if key, ok := s.Labels["key"]; ok {
+			id += "/" + key
+		}
+		if _, ok := want[id]; ok {
+			found[id] = s
+		} else if s.Name == "test-push-backlog" {
+			t.Errorf("Push() exported unexpected sample %+v", s)
+		}
+	}
+	if !reflect.DeepEqual(found, want) {
+		t.Errorf("Push() exported %+v, want %+v", found, want)
+	}
+
+	p = NewPusher(exporterFunc(func(ctx context.Context, s *Snapshot) error {
+		return errors.New("unavailable")
+	}), ts)
+	if err := p.Push(context.Background()); err == nil {
+		t.Error("Push() with a failing exporter succeeded, want error")
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+	for _, test := range []struct {
+		spec    string
+		want    map[string]string
+		wantErr bool
+	}{
+		{spec: "", want: map[string]string{}},
+		{spec: "zone=us-east1-b, job=signer,empty=", want: map[string]string{"zone": "us-east1-b", "job": "signer", "empty": ""}},
+		{spec: "zone", wantErr: true},
+		{spec: "=us-east1-b", wantErr: true},
+		{spec: "job=a,job=b", wantErr: true},
+	} {
+		got, err := ParseLabels(test.spec)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("ParseLabels(%q)=(_, %v), want err? %v", test.spec, err, test.wantErr)
+			continue
+		}
+		if !test.wantErr && !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseLabels(%q)=%v, want %v", test.spec, got, test.want)
+		}
+	}
+}
@@ -15,10 +15,15 @@
 package monitoring
 
 import (
+	"errors"
 	"expvar"
 	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/google/trillian/monitoring/metric"
 	"github.com/google/trillian/util"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
@@ -32,8 +37,77 @@ const (
 	requestErrorCountMapName       string = "errors-by-handler"
 	requestSucceededLatencyMapName string = "succeeded-request-total-latency-by-handler-ms"
 	requestFailedLatencyMapName    string = "failed-request-total-latency-by-handler-ms"
+
+	treeRequestCountMapName string = "requests-by-handler-and-tree"
+	treeErrorCountMapName   string = "errors-by-handler-and-tree"
+	treeLatencyMapName      string = "request-total-latency-by-handler-and-tree-ms"
+
+	latencyHistogramName string = "request-latency-ms"
+
+	// otherTrees labels the RPCs for trees beyond the limit given to EnableTreeLabels.
+	otherTrees string = "other"
 )
 
+// treeLabels limits the number of trees RPC stats are labelled with.
+type treeLabels struct {
+	mu       sync.Mutex
+	maxTrees int
+	seen     map[int64]bool
+}
+
+// label returns the label for RPCs about the tree with the given ID.
+func (t *treeLabels) label(treeID int64) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.seen[treeID] {
+		if len(t.seen) >= t.maxTrees {
+			return otherTrees
+		}
+		t.seen[treeID] = true
+	}
+	return strconv.FormatInt(treeID, 10)
+}
+
+// requestTreeID returns the ID of the tree an RPC request is about, if it's about one.
+func requestTreeID(req interface{}) (int64, bool) {
+	switch r := req.(type) {
+	case interface {
+		GetLogId() int64
+	}:
+		return r.GetLogId(), true
+	case interface {
+		GetMapId() int64
+	}:
+		return r.GetMapId(), true
+	case interface {
+		GetTreeId() int64
+	}:
+		return r.GetTreeId(), true
+	}
+	return 0, false
+}
+
+// latencyHistograms holds the RPC latency histograms, created as methods are first seen.
+type latencyHistograms struct {
+	mu     sync.Mutex
+	prefix string
+	bounds []float64
+	m      map[string]metric.Histogram
+}
+
+// get returns the histogram of the latencies of RPCs to method with the given result.
+func (l *latencyHistograms) get(method, result string) metric.Histogram {
+	name := fmt.Sprintf("%s/%s/%s", l.prefix, strings.TrimPrefix(method, "/"), result)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	h, ok := l.m[name]
+	if !ok {
+		h = metric.NewHistogram(name, l.bounds)
+		l.m[name] = h
+	}
+	return h
+}
+
 // RPCStatsInterceptor provides a gRPC interceptor that records statistics about the RPCs passing through it.
 type RPCStatsInterceptor struct {
 	baseName                          string
@@ -43,6 +117,11 @@ type RPCStatsInterceptor struct {
 	handlerRequestErrorCountMap       *expvar.Map
 	handlerRequestSucceededLatencyMap *expvar.Map
 	handlerRequestFailedLatencyMap    *expvar.Map
+	trees                             *treeLabels
+	treeRequestCountMap               *expvar.Map
+	treeErrorCountMap                 *expvar.Map
+	treeLatencyMap                    *expvar.Map
+	latencyHistograms                 *latencyHistograms
 }
 
 // NewRPCStatsInterceptor creates a new RPCStatsInterceptor for the given application/component, with
@@ -53,7 +132,50 @@ func NewRPCStatsInterceptor(timeSource util.TimeSource, application, component s
 		handlerRequestSucceededCountMap:   new(expvar.Map).Init(),
 		handlerRequestErrorCountMap:       new(expvar.Map).Init(),
 		handlerRequestSucceededLatencyMap: new(expvar.Map).Init(),
-		handlerRequestFailedLatencyMap:    new(expvar.Map).Init()}
+		handlerRequestFailedLatencyMap:    new(expvar.Map).Init(),
+		treeRequestCountMap:               new(expvar.Map).Init(),
+		treeErrorCountMap:                 new(expvar.Map).Init(),
+		treeLatencyMap:                    new(expvar.Map).Init()}
+}
+
+// EnableLatencyHistograms makes the latencies of RPCs be counted into metric histograms
+// with the given bucket upper bounds in milliseconds, which must be increasing, one for
+// each method and whether its RPCs succeeded, named like
+// "<application>/<component>/request-latency-ms/trillian.TrillianLog/QueueLeaf/ok". It
+// must be called before the interceptor is used.
+func (r *RPCStatsInterceptor) EnableLatencyHistograms(bounds []float64) {
+	r.latencyHistograms = &latencyHistograms{prefix: r.nameForMap(latencyHistogramName), bounds: bounds, m: make(map[string]metric.Histogram)}
+}
+
+// ParseBuckets parses a comma separated list of increasing histogram bucket bounds,
+// e.g. "1,10,100".
+func ParseBuckets(spec string) ([]float64, error) {
+	var bounds []float64
+	for _, f := range strings.Split(spec, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		b, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return nil, err
+		}
+		if len(bounds) > 0 && b <= bounds[len(bounds)-1] {
+			return nil, fmt.Errorf("bucket bounds must be increasing, got %v after %v", b, bounds[len(bounds)-1])
+		}
+		bounds = append(bounds, b)
+	}
+	if len(bounds) == 0 {
+		return nil, errors.New("no bucket bounds")
+	}
+	return bounds, nil
+}
+
+// EnableTreeLabels makes the request counts, error counts and latencies also be recorded
+// by method and tree, keyed like "/trillian.TrillianLog/QueueLeaf/123". To bound the
+// number of keys, RPCs for trees after the first maxTrees seen are recorded under
+// "other". It must be called before Publish and the interceptor is used.
+func (r *RPCStatsInterceptor) EnableTreeLabels(maxTrees int) {
+	r.trees = &treeLabels{maxTrees: maxTrees, seen: make(map[int64]bool)}
 }
 
 func (r RPCStatsInterceptor) nameForMap(name string) string {
@@ -68,12 +190,53 @@ func (r RPCStatsInterceptor) Publish() {
 	expvar.Publish(r.nameForMap(requestErrorCountMapName), r.handlerRequestErrorCountMap)
 	expvar.Publish(r.nameForMap(requestSucceededLatencyMapName), r.handlerRequestSucceededLatencyMap)
 	expvar.Publish(r.nameForMap(requestFailedLatencyMapName), r.handlerRequestFailedLatencyMap)
+	if r.trees != nil {
+		expvar.Publish(r.nameForMap(treeRequestCountMapName), r.treeRequestCountMap)
+		expvar.Publish(r.nameForMap(treeErrorCountMapName), r.treeErrorCountMap)
+		expvar.Publish(r.nameForMap(treeLatencyMapName), r.treeLatencyMap)
+	}
 }
 
-func (r RPCStatsInterceptor) recordFailureLatency(method string, startTime time.Time) {
+// treeKey returns the key of the per-tree stats for an RPC, or "" if they aren't
+// enabled or the request isn't about a tree.
+func (r RPCStatsInterceptor) treeKey(method string, req interface{}) string {
+	if r.trees == nil {
+		return ""
+	}
+	treeID, ok := requestTreeID(req)
+	if !ok {
+		return ""
+	}
+	return method + "/" + r.trees.label(treeID)
+}
+
+func (r RPCStatsInterceptor) recordTreeLatency(treeKey string, latency time.Duration, failed bool) {
+	if treeKey == "" {
+		return
+	}
+	r.treeLatencyMap.Add(treeKey, latency.Nanoseconds()/nanosToMillisDivisor)
+	if failed {
+		r.treeErrorCountMap.Add(treeKey, 1)
+	}
+}
+
+func (r RPCStatsInterceptor) recordLatencyHistogram(method string, latency time.Duration, failed bool) {
+	if r.latencyHistograms == nil {
+		return
+	}
+	result := "ok"
+	if failed {
+		result = "error"
+	}
+	r.latencyHistograms.get(method, result).Observe(latency.Seconds() * 1000)
+}
+
+func (r RPCStatsInterceptor) recordFailureLatency(method, treeKey string, startTime time.Time) {
 	latency := r.timeSource.Now().Sub(startTime)
 	r.handlerRequestErrorCountMap.Add(method, 1)
 	r.handlerRequestFailedLatencyMap.Add(method, latency.Nanoseconds()/nanosToMillisDivisor)
+	r.recordLatencyHistogram(method, latency, true)
+	r.recordTreeLatency(treeKey, latency, true)
 }
 
 // Interceptor returns a UnaryServerInterceptor that can be registered with an RPC server and
@@ -81,15 +244,19 @@ func (r RPCStatsInterceptor) recordFailureLatency(method string, startTime time.
 func (r RPCStatsInterceptor) Interceptor() grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 		method := info.FullMethod
+		treeKey := r.treeKey(method, req)
 
 		// Increase the request count for the method and start the clock
 		r.handlerRequestCountMap.Add(method, 1)
+		if treeKey != "" {
+			r.treeRequestCountMap.Add(treeKey, 1)
+		}
 		startTime := r.timeSource.Now()
 
 		defer func() {
 			if rec := recover(); rec != nil {
 				// If we reach here then the handler exited via panic, count it as a server failure
-				r.recordFailureLatency(method, startTime)
+				r.recordFailureLatency(method, treeKey, startTime)
 				panic(rec)
 			}
 		}()
@@ -99,12 +266,14 @@ func (r RPCStatsInterceptor) Interceptor() grpc.UnaryServerInterceptor {
 
 		// Record success / failure and latency
 		if err != nil {
-			r.recordFailureLatency(method, startTime)
+			r.recordFailureLatency(method, treeKey, startTime)
 		} else {
 			latency := r.timeSource.Now().Sub(startTime)
 
 			r.handlerRequestSucceededCountMap.Add(method, 1)
 			r.handlerRequestSucceededLatencyMap.Add(method, latency.Nanoseconds()/nanosToMillisDivisor)
+			r.recordLatencyHistogram(method, latency, false)
+			r.recordTreeLatency(treeKey, latency, false)
 		}
 
 		// Pass the result of the handler invocation back
@@ -18,9 +18,11 @@ import (
 	"errors"
 	"expvar"
 	"fmt"
+	"reflect"
 	"testing"
 	"time"
 
+	"github.com/google/trillian/monitoring/metric"
 	"github.com/google/trillian/util"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
@@ -117,6 +119,107 @@ func TestMultipleErrorRequestsTotalLatency(t *testing.T) {
 	}
 }
 
+type logRequest struct{ logID int64 }
+
+func (r logRequest) GetLogId() int64 { return r.logID }
+
+type treeRequest struct{ treeID int64 }
+
+func (r treeRequest) GetTreeId() int64 { return r.treeID }
+
+func TestTreeLabels(t *testing.T) {
+	ts := util.IncrementingFakeTimeSource{BaseTime: fakeTime, Increments: []time.Duration{0, time.Millisecond * 100, 0, time.Millisecond * 200, 0, time.Millisecond * 300, 0, time.Millisecond * 400, 0, time.Millisecond * 500}}
+	stats := NewRPCStatsInterceptor(&ts, "test", "test")
+	stats.EnableTreeLabels(2)
+	i := stats.Interceptor()
+
+	for _, call := range []struct {
+		req interface{}
+		err error
+	}{
+		{req: logRequest{1}},
+		{req: treeRequest{2}, err: errors.New("bang")},
+		{req: logRequest{1}},
+		{req: logRequest{3}},
+		{req: "no tree"},
+	} {
+		handler := recordingUnaryHandler{resp: "OK", err: call.err}
+		i(context.Background(), call.req, &grpc.UnaryServerInfo{FullMethod: "/test/Method"}, handler.handler())
+	}
+
+	for _, test := range []struct {
+		m    *expvar.Map
+		key  string
+		want string
+	}{
+		{m: stats.treeRequestCountMap, key: "/test/Method/1", want: "2"},
+		{m: stats.treeLatencyMap, key: "/test/Method/1", want: "400"},
+		{m: stats.treeErrorCountMap, key: "/test/Method/2", want: "1"},
+		{m: stats.treeRequestCountMap, key: "/test/Method/other", want: "1"},
+		{m: stats.treeLatencyMap, key: "/test/Method/other", want: "400"},
+	} {
+		if got := test.m.Get(test.key); got == nil || got.String() != test.want {
+			t.Errorf("stats for %v=%v, want %v", test.key, got, test.want)
+		}
+	}
+	if !testMapSizeIs(stats.treeRequestCountMap, 3) {
+		t.Error("tree request counts not limited to 2 trees and other")
+	}
+}
+
+func TestLatencyHistograms(t *testing.T) {
+	ts := util.IncrementingFakeTimeSource{BaseTime: fakeTime, Increments: []time.Duration{0, time.Millisecond * 5, 0, time.Millisecond * 50, 0, time.Millisecond * 500, 0, time.Millisecond * 10}}
+	stats := NewRPCStatsInterceptor(&ts, "test", "test")
+	stats.EnableLatencyHistograms([]float64{10, 100})
+	i := stats.Interceptor()
+
+	for _, err := range []error{nil, nil, nil, errors.New("bang")} {
+		handler := recordingUnaryHandler{resp: "OK", err: err}
+		i(context.Background(), "wibble", &grpc.UnaryServerInfo{FullMethod: "testmethod"}, handler.handler())
+	}
+
+	got := make(map[string]float64)
+	for _, s := range metric.Snapshot() {
+		if s.Name == "test/test/request-latency-ms/testmethod/ok_bucket" || s.Name == "test/test/request-latency-ms/testmethod/error_bucket" {
+			got[s.Name+"/le="+s.Labels["le"]] = s.Value
+		}
+	}
+	want := map[string]float64{
+		"test/test/request-latency-ms/testmethod/ok_bucket/le=10":      1,
+		"test/test/request-latency-ms/testmethod/ok_bucket/le=100":     2,
+		"test/test/request-latency-ms/testmethod/ok_bucket/le=+Inf":    3,
+		"test/test/request-latency-ms/testmethod/error_bucket/le=10":   1,
+		"test/test/request-latency-ms/testmethod/error_bucket/le=100":  1,
+		"test/test/request-latency-ms/testmethod/error_bucket/le=+Inf": 1,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("histogram buckets=%v, want %v", got, want)
+	}
+}
+
+func TestParseBuckets(t *testing.T) {
+	for _, test := range []struct {
+		spec    string
+		want    []float64
+		wantErr bool
+	}{
+		{spec: "1, 2.5,10", want: []float64{1, 2.5, 10}},
+		{spec: "", wantErr: true},
+		{spec: "1,x", wantErr: true},
+		{spec: "10,1", wantErr: true},
+		{spec: "1,1", wantErr: true},
+	} {
+		got, err := ParseBuckets(test.spec)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("ParseBuckets(%q)=(_, %v), want error? %v", test.spec, err, test.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseBuckets(%q)=(%v, nil), want (%v, nil)", test.spec, got, test.want)
+		}
+	}
+}
+
 func (s singleRequestTestCase) execute(t *testing.T) {
 	stats := NewRPCStatsInterceptor(&s.timeSource, "test", "test")
 	i := stats.Interceptor()
@@ -15,25 +15,52 @@
 package admin
 
 import (
+	"bytes"
+	"crypto/x509"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes/empty"
 	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/crypto/keys"
+	te "github.com/google/trillian/errors"
 	"github.com/google/trillian/extension"
 	"github.com/google/trillian/server/errors"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/util"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var errNotImplemented = grpc.Errorf(codes.Unimplemented, "not implemented")
 
+// maxBatchSize is the most trees a BatchCreateTrees or BatchUpdateTrees request may hold.
+const maxBatchSize = 1000
+
+// LogSequencer sequences a log's queued leaves straight away, returning the number of
+// leaves sequenced and the resulting root.
+type LogSequencer func(ctx context.Context, logID int64) (*trillian.SequenceLogResponse, error)
+
 // Server is an implementation of trillian.TrillianAdminServer.
 type Server struct {
 	registry extension.Registry
+	// sequencer is nil unless set by SetLogSequencer.
+	sequencer  LogSequencer
+	timeSource util.TimeSource
 }
 
 // New returns a trillian.TrillianAdminServer implementation.
 func New(registry extension.Registry) *Server {
-	return &Server{registry}
+	return &Server{registry: registry, timeSource: util.SystemTimeSource{}}
+}
+
+// SetLogSequencer makes SequenceLog use seq, e.g. one reaching the log signer with
+// server.SequenceOverHTTP. Without one SequenceLog fails with FailedPrecondition.
+func (s *Server) SetLogSequencer(seq LogSequencer) {
+	s.sequencer = seq
 }
 
 // ListTrees implements trillian.TrillianAdminServer.ListTrees.
@@ -83,29 +110,403 @@ func (s *Server) createTreeImpl(ctx context.Context, request *trillian.CreateTre
 		return nil, err
 	}
 	defer tx.Close()
-	tree, err := tx.CreateTree(ctx, request.GetTree())
+	tree, err := s.createTree(ctx, tx, request)
 	if err != nil {
 		return nil, err
 	}
-	if err := tx.Commit(); err != nil {
-		return nil, err
+	if !request.GetValidateOnly() {
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
 	}
 	return redact(tree), nil
 }
 
+// createTree creates the tree of request in tx, or if the request is validate_only
+// returns the tree it would be created as. Both are validated in the same way.
+func (s *Server) createTree(ctx context.Context, tx storage.AdminTX, request *trillian.CreateTreeRequest) (*trillian.Tree, error) {
+	tree := request.GetTree()
+	if checker, ok := tx.(storage.NewTreeChecker); ok {
+		if err := checker.CheckNewTree(ctx, tree); err != nil {
+			return nil, err
+		}
+	} else if err := storage.ValidateTreeForCreation(tree); err != nil {
+		return nil, err
+	}
+	if err := s.validateKeys(ctx, tree); err != nil {
+		return nil, err
+	}
+	if request.GetValidateOnly() {
+		return proto.Clone(tree).(*trillian.Tree), nil
+	}
+	return tx.CreateTree(ctx, tree)
+}
+
 // UpdateTree implements trillian.TrillianAdminServer.UpdateTree.
-func (s *Server) UpdateTree(context.Context, *trillian.UpdateTreeRequest) (*trillian.Tree, error) {
-	// TODO(codingllama): Don't forget to redact tree
-	return nil, errNotImplemented
+func (s *Server) UpdateTree(ctx context.Context, request *trillian.UpdateTreeRequest) (*trillian.Tree, error) {
+	tree, err := s.updateTreeImpl(ctx, request)
+	if err != nil {
+		return nil, errors.WrapError(err)
+	}
+	return tree, nil
+}
+
+func (s *Server) updateTreeImpl(ctx context.Context, request *trillian.UpdateTreeRequest) (*trillian.Tree, error) {
+	updateFunc, err := newUpdateFunc(request)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := s.registry.AdminStorage.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	tree, err := s.applyUpdate(ctx, tx, request, updateFunc)
+	if err != nil {
+		return nil, err
+	}
+	if !request.GetValidateOnly() {
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+	}
+	return redact(tree), nil
+}
+
+// applyUpdate updates the tree of request in tx with updateFunc, or if the request is
+// validate_only returns the tree it would be updated to. Both are validated in the same
+// way, the keys of an updated tree before it's committed.
+func (s *Server) applyUpdate(ctx context.Context, tx storage.AdminTX, request *trillian.UpdateTreeRequest, updateFunc func(*trillian.Tree)) (*trillian.Tree, error) {
+	treeID := request.GetTree().GetTreeId()
+	var tree *trillian.Tree
+	var err error
+	if request.GetValidateOnly() {
+		stored, err := tx.GetTree(ctx, treeID)
+		if err != nil {
+			return nil, err
+		}
+		tree = proto.Clone(stored).(*trillian.Tree)
+		updateFunc(tree)
+		if err := storage.ValidateTreeForUpdate(stored, tree); err != nil {
+			return nil, err
+		}
+	} else if tree, err = tx.UpdateTree(ctx, treeID, updateFunc); err != nil {
+		return nil, err
+	}
+	if err := s.validateKeys(ctx, tree); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// validateKeys checks tree's private_key can be loaded by the registry's SignerFactory,
+// and signs with the tree's hash and signature algorithms, and that its public_key is the
+// private key's if it has both. Only the public key is checked without a SignerFactory,
+// as the private key may only be reachable from the log signer.
+func (s *Server) validateKeys(ctx context.Context, tree *trillian.Tree) error {
+	if tree.PrivateKey == nil || s.registry.SignerFactory == nil {
+		if tree.PublicKey == nil {
+			return nil
+		}
+		publicKey, err := x509.ParsePKIXPublicKey(tree.PublicKey.GetDer())
+		if err != nil {
+			return te.Errorf(te.InvalidArgument, "invalid public_key: %v", err)
+		}
+		if alg := keys.SignatureAlgorithm(publicKey); alg != tree.SignatureAlgorithm {
+			return te.Errorf(te.InvalidArgument, "public_key is for signature_algorithm %v, not %v", alg, tree.SignatureAlgorithm)
+		}
+		return nil
+	}
+
+	signer, err := s.registry.SignerFactory.NewSigner(ctx, tree)
+	if err != nil {
+		return te.Errorf(te.InvalidArgument, "failed to load private_key: %v", err)
+	}
+	sig, err := crypto.NewSigner(signer).Sign([]byte("validate_only"))
+	if err != nil {
+		return te.Errorf(te.InvalidArgument, "failed to sign with private_key: %v", err)
+	}
+	switch {
+	case sig.SignatureAlgorithm != tree.SignatureAlgorithm:
+		return te.Errorf(te.InvalidArgument, "private_key is for signature_algorithm %v, not %v", sig.SignatureAlgorithm, tree.SignatureAlgorithm)
+	case sig.HashAlgorithm != tree.HashAlgorithm:
+		return te.Errorf(te.InvalidArgument, "private_key signs with hash_algorithm %v, not %v", sig.HashAlgorithm, tree.HashAlgorithm)
+	}
+	if tree.PublicKey != nil {
+		der, err := x509.MarshalPKIXPublicKey(signer.Public())
+		if err != nil || !bytes.Equal(der, tree.PublicKey.GetDer()) {
+			return te.New(te.InvalidArgument, "public_key doesn't match private_key")
+		}
+	}
+	return nil
+}
+
+// newUpdateFunc returns a function applying the fields of request's update mask to a
+// tree, or an InvalidArgument error if request has no tree or mask, or the mask has a
+// path which can't be updated.
+func newUpdateFunc(request *trillian.UpdateTreeRequest) (func(*trillian.Tree), error) {
+	tree := request.GetTree()
+	if tree == nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "a tree is required")
+	}
+	paths := request.GetUpdateMask().GetPaths()
+	if len(paths) == 0 {
+		return nil, grpc.Errorf(codes.InvalidArgument, "update_mask is empty, nothing to update")
+	}
+	for _, path := range paths {
+		switch path {
+		case "tree_state", "display_name", "description", "sequencing_batch_size", "sequencing_interval_seconds", "sequencing_guard_window_seconds", "sequencing_priority", "max_merge_delay_seconds", "leaf_compression", "leaf_retention_seconds", "duplicate_policy", "allowed_writers":
+		default:
+			return nil, grpc.Errorf(codes.InvalidArgument, "unsupported path in update_mask: %q", path)
+		}
+	}
+
+	return func(t *trillian.Tree) {
+		for _, path := range paths {
+			switch path {
+			case "tree_state":
+				t.TreeState = tree.TreeState
+			case "display_name":
+				t.DisplayName = tree.DisplayName
+			case "description":
+				t.Description = tree.Description
+			case "sequencing_batch_size":
+				t.SequencingBatchSize = tree.SequencingBatchSize
+			case "sequencing_interval_seconds":
+				t.SequencingIntervalSeconds = tree.SequencingIntervalSeconds
+			case "sequencing_guard_window_seconds":
+				t.SequencingGuardWindowSeconds = tree.SequencingGuardWindowSeconds
+			case "sequencing_priority":
+				t.SequencingPriority = tree.SequencingPriority
+			case "max_merge_delay_seconds":
+				t.MaxMergeDelaySeconds = tree.MaxMergeDelaySeconds
+			case "leaf_compression":
+				t.LeafCompression = tree.LeafCompression
+			case "leaf_retention_seconds":
+				t.LeafRetentionSeconds = tree.LeafRetentionSeconds
+			case "duplicate_policy":
+				t.DuplicatePolicy = tree.DuplicatePolicy
+			case "allowed_writers":
+				t.AllowedWriters = tree.AllowedWriters
+			}
+		}
+	}, nil
 }
 
 // DeleteTree implements trillian.TrillianAdminServer.DeleteTree.
-func (s *Server) DeleteTree(context.Context, *trillian.DeleteTreeRequest) (*empty.Empty, error) {
-	return nil, errNotImplemented
+// Trees are soft-deleted, they may be undeleted by updating their state back to ACTIVE.
+func (s *Server) DeleteTree(ctx context.Context, request *trillian.DeleteTreeRequest) (*empty.Empty, error) {
+	if _, err := s.updateTree(ctx, request.GetTreeId(), func(t *trillian.Tree) {
+		t.TreeState = trillian.TreeState_SOFT_DELETED
+	}); err != nil {
+		return nil, errors.WrapError(err)
+	}
+	return &empty.Empty{}, nil
+}
+
+// SequenceLog implements trillian.TrillianAdminServer.SequenceLog.
+func (s *Server) SequenceLog(ctx context.Context, request *trillian.SequenceLogRequest) (*trillian.SequenceLogResponse, error) {
+	rsp, err := s.sequenceLogImpl(ctx, request)
+	if err != nil {
+		return nil, errors.WrapError(err)
+	}
+	return rsp, nil
+}
+
+func (s *Server) sequenceLogImpl(ctx context.Context, request *trillian.SequenceLogRequest) (*trillian.SequenceLogResponse, error) {
+	if s.sequencer == nil {
+		return nil, grpc.Errorf(codes.FailedPrecondition, "no log signer is configured to sequence logs on demand")
+	}
+	tree, err := s.getTreeImpl(ctx, &trillian.GetTreeRequest{TreeId: request.GetLogId()})
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case tree.TreeType != trillian.TreeType_LOG && tree.TreeType != trillian.TreeType_PREORDERED_LOG:
+		return nil, grpc.Errorf(codes.InvalidArgument, "tree %d is a %v, not a log", tree.TreeId, tree.TreeType)
+	// Frozen logs are sequenced until their queue is drained and they're finalized, doing
+	// so on demand lets callers wait for that.
+	case tree.TreeState == trillian.TreeState_FROZEN && tree.FinalizeTimeMillisSinceEpoch != 0:
+		return nil, grpc.Errorf(codes.FailedPrecondition, "log %d has been finalized", tree.TreeId)
+	case tree.TreeState != trillian.TreeState_ACTIVE && tree.TreeState != trillian.TreeState_FROZEN:
+		return nil, grpc.Errorf(codes.FailedPrecondition, "log %d is %v, not ACTIVE or FROZEN", tree.TreeId, tree.TreeState)
+	}
+	return s.sequencer(ctx, tree.TreeId)
+}
+
+// GetTreeStats implements trillian.TrillianAdminServer.GetTreeStats.
+func (s *Server) GetTreeStats(ctx context.Context, request *trillian.GetTreeStatsRequest) (*trillian.GetTreeStatsResponse, error) {
+	rsp, err := s.getTreeStatsImpl(ctx, request)
+	if err != nil {
+		return nil, errors.WrapError(err)
+	}
+	return rsp, nil
+}
+
+func (s *Server) getTreeStatsImpl(ctx context.Context, request *trillian.GetTreeStatsRequest) (*trillian.GetTreeStatsResponse, error) {
+	tree, err := s.getTreeImpl(ctx, &trillian.GetTreeRequest{TreeId: request.GetTreeId()})
+	if err != nil {
+		return nil, err
+	}
+	if tree.TreeType != trillian.TreeType_LOG && tree.TreeType != trillian.TreeType_PREORDERED_LOG {
+		return nil, grpc.Errorf(codes.InvalidArgument, "tree %d is a %v, not a log", tree.TreeId, tree.TreeType)
+	}
+	if s.registry.LogStorage == nil {
+		return nil, grpc.Errorf(codes.FailedPrecondition, "logs aren't served here")
+	}
+
+	tx, err := s.registry.LogStorage.SnapshotForTree(ctx, tree.TreeId)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	root, err := tx.LatestSignedLogRoot()
+	if err != nil {
+		return nil, err
+	}
+	leaves, oldest, err := tx.GetUnsequencedStats()
+	if err != nil {
+		return nil, err
+	}
+	// Usage is left out for storage which doesn't account for it.
+	usage, err := tx.GetStorageUsage()
+	if err != nil && te.ErrorCode(err) != te.Unimplemented {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	rsp := &trillian.GetTreeStatsResponse{
+		TreeSize:           root.TreeSize,
+		UnsequencedLeaves:  leaves,
+		RootTimestampNanos: root.TimestampNanos,
+		LeafBytes:          usage.LeafBytes,
+		NodeBytes:          usage.NodeBytes,
+	}
+	if leaves > 0 {
+		rsp.OldestUnsequencedAgeNanos = int64(s.timeSource.Now().Sub(oldest))
+	}
+	return rsp, nil
+}
+
+// BatchCreateTrees implements trillian.TrillianAdminServer.BatchCreateTrees.
+func (s *Server) BatchCreateTrees(ctx context.Context, request *trillian.BatchCreateTreesRequest) (*trillian.BatchCreateTreesResponse, error) {
+	requests := request.GetRequests()
+	writes := false
+	for _, r := range requests {
+		writes = writes || !r.GetValidateOnly()
+	}
+	results, err := s.runBatch(ctx, len(requests), writes, func(tx storage.AdminTX, i int) (*trillian.Tree, error) {
+		return s.createTree(ctx, tx, requests[i])
+	})
+	if err != nil {
+		return nil, errors.WrapError(err)
+	}
+	return &trillian.BatchCreateTreesResponse{Results: results}, nil
+}
+
+// BatchUpdateTrees implements trillian.TrillianAdminServer.BatchUpdateTrees.
+func (s *Server) BatchUpdateTrees(ctx context.Context, request *trillian.BatchUpdateTreesRequest) (*trillian.BatchUpdateTreesResponse, error) {
+	requests := request.GetRequests()
+	writes := false
+	for _, r := range requests {
+		writes = writes || !r.GetValidateOnly()
+	}
+	results, err := s.runBatch(ctx, len(requests), writes, func(tx storage.AdminTX, i int) (*trillian.Tree, error) {
+		updateFunc, err := newUpdateFunc(requests[i])
+		if err != nil {
+			return nil, err
+		}
+		return s.applyUpdate(ctx, tx, requests[i], updateFunc)
+	})
+	if err != nil {
+		return nil, errors.WrapError(err)
+	}
+	return &trillian.BatchUpdateTreesResponse{Results: results}, nil
+}
+
+// runBatch calls apply for each of the n items of a batch, in one transaction which is
+// only committed if all of them succeed. Otherwise the first item that fails is given
+// its error, and the others are reported as aborted. Batches which write, rather than
+// being all validate_only, are rejected with FailedPrecondition where storage can't
+// apply them atomically, e.g. because they span databases.
+func (s *Server) runBatch(ctx context.Context, n int, writes bool, apply func(tx storage.AdminTX, i int) (*trillian.Tree, error)) ([]*trillian.BatchTreeResult, error) {
+	if n == 0 {
+		return nil, grpc.Errorf(codes.InvalidArgument, "requests is empty, nothing to do")
+	}
+	if n > maxBatchSize {
+		return nil, grpc.Errorf(codes.InvalidArgument, "too many requests, got %d, max is %d", n, maxBatchSize)
+	}
+
+	tx, err := s.registry.AdminStorage.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	if writes && !atomic(tx) {
+		return nil, grpc.Errorf(codes.FailedPrecondition, "storage can't apply batches atomically")
+	}
+	results := make([]*trillian.BatchTreeResult, n)
+	for i := range results {
+		tree, err := apply(tx, i)
+		if err != nil {
+			aborted := fmt.Sprintf("not applied, request %d of the batch failed", i)
+			for j := range results {
+				results[j] = &trillian.BatchTreeResult{Status: status.New(codes.Aborted, aborted).Proto()}
+			}
+			results[i].Status = errorStatus(err).Proto()
+			return results, nil
+		}
+		results[i] = &trillian.BatchTreeResult{Tree: redact(tree)}
+	}
+	if writes && !atomic(tx) {
+		return nil, grpc.Errorf(codes.FailedPrecondition, "batch spans more than one database, so can't be applied atomically")
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	for _, r := range results {
+		r.Status = status.New(codes.OK, "").Proto()
+	}
+	return results, nil
+}
+
+// atomic returns whether the writes made so far by tx would be committed, or rolled
+// back, together.
+func atomic(tx storage.AdminTX) bool {
+	checker, ok := tx.(storage.AtomicityChecker)
+	return !ok || checker.Atomic()
+}
+
+// errorStatus returns the gRPC status err would be returned with by a single tree RPC.
+func errorStatus(err error) *status.Status {
+	err = errors.WrapError(err)
+	if s, ok := status.FromError(err); ok {
+		return s
+	}
+	return status.New(codes.Unknown, err.Error())
+}
+
+func (s *Server) updateTree(ctx context.Context, treeID int64, updateFunc func(*trillian.Tree)) (*trillian.Tree, error) {
+	tx, err := s.registry.AdminStorage.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	tree, err := tx.UpdateTree(ctx, treeID, updateFunc)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return redact(tree), nil
 }
 
 // redact removes sensitive information from t. Returns t for convenience.
 func redact(t *trillian.Tree) *trillian.Tree {
 	t.PrivateKey = nil
+	t.LeafIdentityHashKey = nil
 	return t
 }
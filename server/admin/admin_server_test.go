@@ -15,16 +15,24 @@
 package admin
 
 import (
+	"crypto/x509"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/golang/protobuf/ptypes"
 	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/keys"
+	"github.com/google/trillian/crypto/sigpb"
+	te "github.com/google/trillian/errors"
 	"github.com/google/trillian/extension"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/storage/testonly"
+	"github.com/google/trillian/util"
 	"github.com/kylelemons/godebug/pretty"
 	"golang.org/x/net/context"
+	"google.golang.org/genproto/protobuf/field_mask"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 )
@@ -41,20 +49,6 @@ func TestAdminServer_Unimplemented(t *testing.T) {
 				return err
 			},
 		},
-		{
-			desc: "UpdateTree",
-			fn: func(ctx context.Context, s *Server) error {
-				_, err := s.UpdateTree(ctx, &trillian.UpdateTreeRequest{})
-				return err
-			},
-		},
-		{
-			desc: "DeleteTree",
-			fn: func(ctx context.Context, s *Server) error {
-				_, err := s.DeleteTree(ctx, &trillian.DeleteTreeRequest{})
-				return err
-			},
-		},
 	}
 	ctx := context.Background()
 	s := &Server{}
@@ -89,6 +83,23 @@ func TestAdminServer_BeginError(t *testing.T) {
 				return err
 			},
 		},
+		{
+			desc: "UpdateTree",
+			fn: func(ctx context.Context, s *Server) error {
+				_, err := s.UpdateTree(ctx, &trillian.UpdateTreeRequest{
+					Tree:       testonly.LogTree,
+					UpdateMask: &field_mask.FieldMask{Paths: []string{"display_name"}},
+				})
+				return err
+			},
+		},
+		{
+			desc: "DeleteTree",
+			fn: func(ctx context.Context, s *Server) error {
+				_, err := s.DeleteTree(ctx, &trillian.DeleteTreeRequest{TreeId: 12345})
+				return err
+			},
+		},
 	}
 
 	ctx := context.Background()
@@ -143,6 +154,8 @@ func TestAdminServer_GetTree(t *testing.T) {
 
 		storedTree := *testonly.LogTree
 		storedTree.TreeId = 12345
+		storedTree.LeafIdentityHashStrategy = trillian.LeafIdentityHashStrategy_HMAC_SHA256_LEAF_VALUE
+		storedTree.LeafIdentityHashKey = []byte("0123456789abcdef0123456789abcdef")
 		if test.getErr {
 			tx.EXPECT().GetTree(ctx, storedTree.TreeId).Return(nil, errors.New("GetTree failed"))
 		} else {
@@ -157,9 +170,13 @@ func TestAdminServer_GetTree(t *testing.T) {
 		} else if hasErr {
 			continue
 		}
+		if tree.PrivateKey != nil || tree.LeafIdentityHashKey != nil {
+			t.Errorf("%v: GetTree() returned keys, want them redacted", test.desc)
+		}
 
 		wantTree := storedTree
-		wantTree.PrivateKey = nil // redacted
+		wantTree.PrivateKey = nil          // redacted
+		wantTree.LeafIdentityHashKey = nil // redacted
 		if diff := pretty.Compare(tree, &wantTree); diff != "" {
 			t.Errorf("%v: post-GetTree diff (-got +want):\n%v", test.desc, diff)
 		}
@@ -174,17 +191,22 @@ func TestAdminServer_CreateTree(t *testing.T) {
 	invalidTree.TreeState = trillian.TreeState_HARD_DELETED
 
 	tests := []struct {
-		desc                 string
-		req                  *trillian.CreateTreeRequest
-		createErr, commitErr bool
+		desc                          string
+		req                           *trillian.CreateTreeRequest
+		invalid, createErr, commitErr bool
 	}{
 		{
 			desc: "validTree",
 			req:  &trillian.CreateTreeRequest{Tree: testonly.LogTree},
 		},
 		{
-			desc:      "invalidTree",
-			req:       &trillian.CreateTreeRequest{Tree: &invalidTree},
+			desc:    "invalidTree",
+			req:     &trillian.CreateTreeRequest{Tree: &invalidTree},
+			invalid: true,
+		},
+		{
+			desc:      "createError",
+			req:       &trillian.CreateTreeRequest{Tree: testonly.LogTree},
 			createErr: true,
 		},
 		{
@@ -196,7 +218,7 @@ func TestAdminServer_CreateTree(t *testing.T) {
 
 	ctx := context.Background()
 	for _, test := range tests {
-		setup := setupAdminStorage(ctrl, false /* snapshot */, !test.createErr /* shouldCommit */, test.commitErr)
+		setup := setupAdminStorage(ctrl, false /* snapshot */, !test.invalid && !test.createErr /* shouldCommit */, test.commitErr)
 		tx := setup.tx
 		s := setup.server
 
@@ -204,12 +226,15 @@ func TestAdminServer_CreateTree(t *testing.T) {
 		newTree.TreeId = 12345
 		newTree.CreateTimeMillisSinceEpoch = 1
 		newTree.UpdateTimeMillisSinceEpoch = 1
-		if test.createErr {
+		// Invalid trees aren't passed to storage.
+		switch {
+		case test.invalid:
+		case test.createErr:
 			tx.EXPECT().CreateTree(ctx, test.req.Tree).Return(nil, errors.New("CreateTree failed"))
-		} else {
+		default:
 			tx.EXPECT().CreateTree(ctx, test.req.Tree).Return(&newTree, nil)
 		}
-		wantErr := test.createErr || test.commitErr
+		wantErr := test.invalid || test.createErr || test.commitErr
 
 		tree, err := s.CreateTree(ctx, test.req)
 		if hasErr := err != nil; hasErr != wantErr {
@@ -227,6 +252,758 @@ func TestAdminServer_CreateTree(t *testing.T) {
 	}
 }
 
+// checkerTX is an AdminTX whose CheckNewTree, having validated the tree, returns err.
+type checkerTX struct {
+	storage.AdminTX
+	err error
+}
+
+func (t *checkerTX) CheckNewTree(ctx context.Context, tree *trillian.Tree) error {
+	if err := storage.ValidateTreeForCreation(tree); err != nil {
+		return err
+	}
+	return t.err
+}
+
+func TestAdminServer_CreateTreeValidateOnly(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	invalidTree := *testonly.LogTree
+	invalidTree.TreeState = trillian.TreeState_HARD_DELETED
+
+	missingKeyFile, err := ptypes.MarshalAny(&trillian.PEMKeyFile{Path: "/nonexistent.pem"})
+	if err != nil {
+		t.Fatalf("MarshalAny()=%v, want nil", err)
+	}
+	missingKey := *testonly.LogTree
+	missingKey.PrivateKey = missingKeyFile
+
+	wrongAlgorithm := *testonly.LogTree
+	wrongAlgorithm.SignatureAlgorithm = sigpb.DigitallySigned_RSA
+
+	otherKey, err := keys.GenerateKey(sigpb.DigitallySigned_ECDSA)
+	if err != nil {
+		t.Fatalf("GenerateKey()=%v, want nil", err)
+	}
+	otherDER, err := x509.MarshalPKIXPublicKey(otherKey.Public())
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey()=%v, want nil", err)
+	}
+	wrongPublicKey := *testonly.LogTree
+	wrongPublicKey.PublicKey = &trillian.PublicKey{Der: otherDER}
+
+	publicKeyOnly := *testonly.LogTree
+	publicKeyOnly.PrivateKey = nil
+	publicKeyOnly.PublicKey = wrongPublicKey.PublicKey
+
+	tests := []struct {
+		desc       string
+		tree       *trillian.Tree
+		storageErr error
+		wantErr    bool
+	}{
+		{desc: "validTree", tree: testonly.LogTree},
+		{desc: "publicKeyOnly", tree: &publicKeyOnly},
+		{desc: "invalidTree", tree: &invalidTree, wantErr: true},
+		{desc: "missingKey", tree: &missingKey, wantErr: true},
+		{desc: "wrongAlgorithm", tree: &wrongAlgorithm, wantErr: true},
+		{desc: "wrongPublicKey", tree: &wrongPublicKey, wantErr: true},
+		{desc: "storageCheck", tree: testonly.LogTree, storageErr: te.New(te.InvalidArgument, "shard window overlaps"), wantErr: true},
+	}
+
+	ctx := context.Background()
+	for _, test := range tests {
+		// Storage checks the tree, but nothing is written or committed.
+		tx := storage.NewMockAdminTX(ctrl)
+		tx.EXPECT().Close().Return(nil)
+		as := storage.NewMockAdminStorage(ctrl)
+		as.EXPECT().Begin(gomock.Any()).Return(&checkerTX{AdminTX: tx, err: test.storageErr}, nil)
+		s := New(extension.Registry{AdminStorage: as, SignerFactory: keys.ProtoSignerFactory{}})
+
+		tree, err := s.CreateTree(ctx, &trillian.CreateTreeRequest{Tree: test.tree, ValidateOnly: true})
+		if test.wantErr {
+			if grpc.Code(err) != codes.InvalidArgument {
+				t.Errorf("%v: CreateTree() = (_, %v), want %s", test.desc, err, codes.InvalidArgument)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: CreateTree() = (_, %v), want nil", test.desc, err)
+			continue
+		}
+		wantTree := *test.tree
+		wantTree.PrivateKey = nil // redacted
+		if diff := pretty.Compare(tree, &wantTree); diff != "" {
+			t.Errorf("%v: post-CreateTree diff (-got +want):\n%v", test.desc, diff)
+		}
+	}
+}
+
+func TestAdminServer_UpdateTreeInvalidRequest(t *testing.T) {
+	tests := []struct {
+		desc string
+		req  *trillian.UpdateTreeRequest
+	}{
+		{
+			desc: "noTree",
+			req:  &trillian.UpdateTreeRequest{UpdateMask: &field_mask.FieldMask{Paths: []string{"display_name"}}},
+		},
+		{
+			desc: "noMask",
+			req:  &trillian.UpdateTreeRequest{Tree: testonly.LogTree},
+		},
+		{
+			desc: "readonlyPath",
+			req: &trillian.UpdateTreeRequest{
+				Tree:       testonly.LogTree,
+				UpdateMask: &field_mask.FieldMask{Paths: []string{"display_name", "tree_type"}},
+			},
+		},
+	}
+
+	ctx := context.Background()
+	s := &Server{}
+	for _, test := range tests {
+		if _, err := s.UpdateTree(ctx, test.req); grpc.Code(err) != codes.InvalidArgument {
+			t.Errorf("%v: UpdateTree() = (_, %v), want %s", test.desc, err, codes.InvalidArgument)
+		}
+	}
+}
+
+func TestAdminServer_UpdateTree(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	storedTree := *testonly.LogTree
+	storedTree.TreeId = 12345
+	storedTree.DisplayName = "Llamas Log"
+	storedTree.Description = "Old description"
+
+	tree := *testonly.LogTree
+	tree.TreeId = storedTree.TreeId
+	tree.TreeState = trillian.TreeState_FROZEN
+	tree.DisplayName = "Frozen Llamas Log"
+	tree.Description = "New description"
+	tree.SequencingBatchSize = 1000
+	tree.SequencingIntervalSeconds = 5
+	tree.SequencingGuardWindowSeconds = 2
+	tree.LeafCompression = trillian.LeafCompression_SNAPPY
+	tree.LeafRetentionSeconds = 7 * 24 * 3600
+	tree.DuplicatePolicy = trillian.DuplicatePolicy_DUPLICATES_ALLOWED
+	tree.AllowedWriters = []string{"spiffe://example.org/ct/"}
+
+	frozenTree := storedTree
+	frozenTree.TreeState = trillian.TreeState_FROZEN
+
+	renamedTree := storedTree
+	renamedTree.DisplayName = tree.DisplayName
+	renamedTree.Description = tree.Description
+
+	tunedTree := storedTree
+	tunedTree.SequencingBatchSize = tree.SequencingBatchSize
+	tunedTree.SequencingIntervalSeconds = tree.SequencingIntervalSeconds
+	tunedTree.SequencingGuardWindowSeconds = tree.SequencingGuardWindowSeconds
+
+	compressedTree := storedTree
+	compressedTree.LeafCompression = tree.LeafCompression
+
+	retainedTree := storedTree
+	retainedTree.LeafRetentionSeconds = tree.LeafRetentionSeconds
+
+	dupsTree := storedTree
+	dupsTree.DuplicatePolicy = tree.DuplicatePolicy
+
+	delegatedTree := storedTree
+	delegatedTree.AllowedWriters = tree.AllowedWriters
+
+	tests := []struct {
+		desc                 string
+		paths                []string
+		updateErr, commitErr bool
+		wantTree             *trillian.Tree
+	}{
+		{
+			desc:     "freeze",
+			paths:    []string{"tree_state"},
+			wantTree: &frozenTree,
+		},
+		{
+			desc:     "rename",
+			paths:    []string{"display_name", "description"},
+			wantTree: &renamedTree,
+		},
+		{
+			desc:     "sequencingConfig",
+			paths:    []string{"sequencing_batch_size", "sequencing_interval_seconds", "sequencing_guard_window_seconds"},
+			wantTree: &tunedTree,
+		},
+		{
+			desc:     "leafCompression",
+			paths:    []string{"leaf_compression"},
+			wantTree: &compressedTree,
+		},
+		{
+			desc:     "leafRetention",
+			paths:    []string{"leaf_retention_seconds"},
+			wantTree: &retainedTree,
+		},
+		{
+			desc:     "duplicatePolicy",
+			paths:    []string{"duplicate_policy"},
+			wantTree: &dupsTree,
+		},
+		{
+			desc:     "allowedWriters",
+			paths:    []string{"allowed_writers"},
+			wantTree: &delegatedTree,
+		},
+		{
+			desc:      "updateError",
+			paths:     []string{"tree_state"},
+			updateErr: true,
+		},
+		{
+			desc:      "commitError",
+			paths:     []string{"tree_state"},
+			commitErr: true,
+		},
+	}
+
+	ctx := context.Background()
+	for _, test := range tests {
+		setup := setupAdminStorage(ctrl, false /* snapshot */, !test.updateErr /* shouldCommit */, test.commitErr)
+		tx := setup.tx
+		s := setup.server
+
+		updated := storedTree
+		if test.updateErr {
+			tx.EXPECT().UpdateTree(ctx, storedTree.TreeId, gomock.Any()).Return(nil, errors.New("UpdateTree failed"))
+		} else {
+			tx.EXPECT().UpdateTree(ctx, storedTree.TreeId, gomock.Any()).Do(func(_ context.Context, _ int64, fn func(*trillian.Tree)) {
+				fn(&updated)
+			}).Return(&updated, nil)
+		}
+		wantErr := test.updateErr || test.commitErr
+
+		got, err := s.UpdateTree(ctx, &trillian.UpdateTreeRequest{Tree: &tree, UpdateMask: &field_mask.FieldMask{Paths: test.paths}})
+		if hasErr := err != nil; hasErr != wantErr {
+			t.Errorf("%v: UpdateTree() = (_, %v), wantErr = %v", test.desc, err, wantErr)
+			continue
+		} else if hasErr {
+			continue
+		}
+
+		wantTree := *test.wantTree
+		wantTree.PrivateKey = nil // redacted
+		if diff := pretty.Compare(got, &wantTree); diff != "" {
+			t.Errorf("%v: post-UpdateTree diff (-got +want):\n%v", test.desc, diff)
+		}
+	}
+}
+
+func TestAdminServer_UpdateTreeValidateOnly(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	storedTree := *testonly.LogTree
+	storedTree.TreeId = 12345
+
+	tree := storedTree
+	tree.TreeState = trillian.TreeState_FROZEN
+	tree.DisplayName = "A display name much too long"
+
+	frozenTree := storedTree
+	frozenTree.TreeState = trillian.TreeState_FROZEN
+
+	tests := []struct {
+		desc     string
+		paths    []string
+		wantTree *trillian.Tree
+	}{
+		{
+			desc:     "freeze",
+			paths:    []string{"tree_state"},
+			wantTree: &frozenTree,
+		},
+		{
+			desc:  "invalidDisplayName",
+			paths: []string{"display_name"},
+		},
+	}
+
+	ctx := context.Background()
+	for _, test := range tests {
+		// The tree is only read, and the transaction isn't committed.
+		setup := setupAdminStorage(ctrl, false /* snapshot */, false /* shouldCommit */, false /* commitErr */)
+		stored := storedTree
+		setup.tx.EXPECT().GetTree(ctx, storedTree.TreeId).Return(&stored, nil)
+		s := setup.server
+		s.registry.SignerFactory = keys.ProtoSignerFactory{}
+
+		got, err := s.UpdateTree(ctx, &trillian.UpdateTreeRequest{Tree: &tree, UpdateMask: &field_mask.FieldMask{Paths: test.paths}, ValidateOnly: true})
+		if test.wantTree == nil {
+			if grpc.Code(err) != codes.InvalidArgument {
+				t.Errorf("%v: UpdateTree() = (_, %v), want %s", test.desc, err, codes.InvalidArgument)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: UpdateTree() = (_, %v), want nil", test.desc, err)
+			continue
+		}
+		wantTree := *test.wantTree
+		wantTree.PrivateKey = nil // redacted
+		if diff := pretty.Compare(got, &wantTree); diff != "" {
+			t.Errorf("%v: post-UpdateTree diff (-got +want):\n%v", test.desc, diff)
+		}
+		if stored.TreeState != storedTree.TreeState || stored.PrivateKey == nil {
+			t.Errorf("%v: UpdateTree() modified the stored tree", test.desc)
+		}
+	}
+}
+
+func TestAdminServer_DeleteTree(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	setup := setupAdminStorage(ctrl, false /* snapshot */, true /* shouldCommit */, false /* commitErr */)
+
+	storedTree := *testonly.LogTree
+	storedTree.TreeId = 12345
+	setup.tx.EXPECT().UpdateTree(ctx, storedTree.TreeId, gomock.Any()).Do(func(_ context.Context, _ int64, fn func(*trillian.Tree)) {
+		fn(&storedTree)
+	}).Return(&storedTree, nil)
+
+	if _, err := setup.server.DeleteTree(ctx, &trillian.DeleteTreeRequest{TreeId: storedTree.TreeId}); err != nil {
+		t.Fatalf("DeleteTree() = (_, %v), want nil", err)
+	}
+	if got, want := storedTree.TreeState, trillian.TreeState_SOFT_DELETED; got != want {
+		t.Errorf("DeleteTree() left tree in state %v, want %v", got, want)
+	}
+}
+
+func TestAdminServer_BatchCreateTrees(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tests := []struct {
+		desc      string
+		createErr error
+		commitErr bool
+		wantCodes []codes.Code
+		wantErr   bool
+	}{
+		{
+			desc:      "success",
+			wantCodes: []codes.Code{codes.OK, codes.OK},
+		},
+		{
+			desc:      "secondFails",
+			createErr: grpc.Errorf(codes.InvalidArgument, "invalid tree"),
+			wantCodes: []codes.Code{codes.Aborted, codes.InvalidArgument},
+		},
+		{
+			desc:      "commitError",
+			commitErr: true,
+			wantErr:   true,
+		},
+	}
+
+	ctx := context.Background()
+	for _, test := range tests {
+		setup := setupAdminStorage(ctrl, false /* snapshot */, test.createErr == nil /* shouldCommit */, test.commitErr)
+		tx := setup.tx
+
+		first := *testonly.LogTree
+		first.TreeId = 12345
+		tx.EXPECT().CreateTree(ctx, testonly.LogTree).Return(&first, nil)
+		second := *testonly.MapTree
+		second.TreeId = 67890
+		if test.createErr != nil {
+			tx.EXPECT().CreateTree(ctx, testonly.MapTree).Return(nil, test.createErr)
+		} else {
+			tx.EXPECT().CreateTree(ctx, testonly.MapTree).Return(&second, nil)
+		}
+
+		rsp, err := setup.server.BatchCreateTrees(ctx, &trillian.BatchCreateTreesRequest{
+			Requests: []*trillian.CreateTreeRequest{{Tree: testonly.LogTree}, {Tree: testonly.MapTree}},
+		})
+		if hasErr := err != nil; hasErr != test.wantErr {
+			t.Errorf("%v: BatchCreateTrees() = (_, %v), wantErr = %v", test.desc, err, test.wantErr)
+			continue
+		} else if hasErr {
+			continue
+		}
+
+		if got, want := len(rsp.Results), len(test.wantCodes); got != want {
+			t.Errorf("%v: BatchCreateTrees() returned %d results, want %d", test.desc, got, want)
+			continue
+		}
+		for i, r := range rsp.Results {
+			if got, want := codes.Code(r.Status.Code), test.wantCodes[i]; got != want {
+				t.Errorf("%v: BatchCreateTrees() result %d has code %v, want %v", test.desc, i, got, want)
+			}
+			if r.Tree != nil && r.Tree.PrivateKey != nil {
+				t.Errorf("%v: BatchCreateTrees() result %d has a private key, want it redacted", test.desc, i)
+			}
+		}
+		if test.createErr == nil && rsp.Results[1].Tree.TreeId != second.TreeId {
+			t.Errorf("%v: BatchCreateTrees() result 1 is tree %d, want %d", test.desc, rsp.Results[1].Tree.TreeId, second.TreeId)
+		}
+	}
+}
+
+func TestAdminServer_BatchUpdateTrees(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	rename := func(treeID int64, name string, paths ...string) *trillian.UpdateTreeRequest {
+		return &trillian.UpdateTreeRequest{
+			Tree:       &trillian.Tree{TreeId: treeID, DisplayName: name},
+			UpdateMask: &field_mask.FieldMask{Paths: paths},
+		}
+	}
+
+	// Updates are applied in one transaction.
+	setup := setupAdminStorage(ctrl, false /* snapshot */, true /* shouldCommit */, false /* commitErr */)
+	trees := map[int64]*trillian.Tree{}
+	for _, id := range []int64{1, 2} {
+		tree := *testonly.LogTree
+		tree.TreeId = id
+		trees[id] = &tree
+		setup.tx.EXPECT().UpdateTree(ctx, id, gomock.Any()).Do(func(_ context.Context, id int64, fn func(*trillian.Tree)) {
+			fn(trees[id])
+		}).Return(&tree, nil)
+	}
+	rsp, err := setup.server.BatchUpdateTrees(ctx, &trillian.BatchUpdateTreesRequest{
+		Requests: []*trillian.UpdateTreeRequest{rename(1, "one", "display_name"), rename(2, "two", "display_name")},
+	})
+	if err != nil {
+		t.Fatalf("BatchUpdateTrees() = (_, %v), want nil", err)
+	}
+	for i, r := range rsp.Results {
+		if got, want := codes.Code(r.Status.Code), codes.OK; got != want {
+			t.Errorf("BatchUpdateTrees() result %d has code %v, want %v", i, got, want)
+		}
+	}
+	if got, want := trees[2].DisplayName, "two"; got != want {
+		t.Errorf("BatchUpdateTrees() renamed tree 2 to %q, want %q", got, want)
+	}
+
+	// An invalid request rolls the whole batch back.
+	setup = setupAdminStorage(ctrl, false /* snapshot */, false /* shouldCommit */, false /* commitErr */)
+	setup.tx.EXPECT().UpdateTree(ctx, int64(1), gomock.Any()).Return(trees[1], nil)
+	rsp, err = setup.server.BatchUpdateTrees(ctx, &trillian.BatchUpdateTreesRequest{
+		Requests: []*trillian.UpdateTreeRequest{rename(1, "one", "display_name"), rename(2, "two", "tree_type"), rename(3, "three", "display_name")},
+	})
+	if err != nil {
+		t.Fatalf("BatchUpdateTrees() = (_, %v), want nil", err)
+	}
+	for i, want := range []codes.Code{codes.Aborted, codes.InvalidArgument, codes.Aborted} {
+		if got := codes.Code(rsp.Results[i].Status.Code); got != want {
+			t.Errorf("BatchUpdateTrees() result %d has code %v, want %v", i, got, want)
+		}
+		if rsp.Results[i].Tree != nil {
+			t.Errorf("BatchUpdateTrees() result %d has a tree, want none as the batch failed", i)
+		}
+	}
+}
+
+// atomicityTX is an AdminTX whose writes stop being atomic once there are more than
+// maxAtomic of them.
+type atomicityTX struct {
+	storage.AdminTX
+	writes, maxAtomic int
+}
+
+func (t *atomicityTX) CreateTree(ctx context.Context, tree *trillian.Tree) (*trillian.Tree, error) {
+	t.writes++
+	return t.AdminTX.CreateTree(ctx, tree)
+}
+
+func (t *atomicityTX) Atomic() bool {
+	return t.writes <= t.maxAtomic
+}
+
+func TestAdminServer_BatchNotAtomic(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	req := &trillian.BatchCreateTreesRequest{
+		Requests: []*trillian.CreateTreeRequest{{Tree: testonly.LogTree}, {Tree: testonly.MapTree}},
+	}
+	for _, test := range []struct {
+		desc      string
+		maxAtomic int
+		creates   int
+	}{
+		// Storage without transactions is rejected before anything is written.
+		{desc: "noTransactions", maxAtomic: -1},
+		// A batch which turns out to span databases is rolled back.
+		{desc: "spansDatabases", maxAtomic: 1, creates: 2},
+	} {
+		tx := storage.NewMockAdminTX(ctrl)
+		tx.EXPECT().CreateTree(ctx, gomock.Any()).Times(test.creates).Return(testonly.LogTree, nil)
+		tx.EXPECT().Close().Return(nil)
+		as := storage.NewMockAdminStorage(ctrl)
+		as.EXPECT().Begin(gomock.Any()).Return(&atomicityTX{AdminTX: tx, maxAtomic: test.maxAtomic}, nil)
+		s := &Server{registry: extension.Registry{AdminStorage: as}}
+
+		if _, err := s.BatchCreateTrees(ctx, req); grpc.Code(err) != codes.FailedPrecondition {
+			t.Errorf("%v: BatchCreateTrees() = (_, %v), want %s", test.desc, err, codes.FailedPrecondition)
+		}
+	}
+}
+
+func TestAdminServer_BatchValidateOnly(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	invalidTree := *testonly.MapTree
+	invalidTree.TreeState = trillian.TreeState_HARD_DELETED
+
+	// Only the item which isn't validate_only is created.
+	setup := setupAdminStorage(ctrl, false /* snapshot */, true /* shouldCommit */, false /* commitErr */)
+	created := *testonly.LogTree
+	created.TreeId = 12345
+	setup.tx.EXPECT().CreateTree(ctx, testonly.LogTree).Return(&created, nil)
+	rsp, err := setup.server.BatchCreateTrees(ctx, &trillian.BatchCreateTreesRequest{
+		Requests: []*trillian.CreateTreeRequest{{Tree: testonly.LogTree}, {Tree: testonly.MapTree, ValidateOnly: true}},
+	})
+	if err != nil {
+		t.Fatalf("BatchCreateTrees() = (_, %v), want nil", err)
+	}
+	for i, r := range rsp.Results {
+		if got, want := codes.Code(r.Status.Code), codes.OK; got != want {
+			t.Errorf("BatchCreateTrees() result %d has code %v, want %v", i, got, want)
+		}
+	}
+
+	// A validate_only item fails the batch as it would if it were created.
+	setup = setupAdminStorage(ctrl, false /* snapshot */, false /* shouldCommit */, false /* commitErr */)
+	rsp, err = setup.server.BatchCreateTrees(ctx, &trillian.BatchCreateTreesRequest{
+		Requests: []*trillian.CreateTreeRequest{{Tree: &invalidTree, ValidateOnly: true}, {Tree: testonly.LogTree}},
+	})
+	if err != nil {
+		t.Fatalf("BatchCreateTrees() = (_, %v), want nil", err)
+	}
+	for i, want := range []codes.Code{codes.InvalidArgument, codes.Aborted} {
+		if got := codes.Code(rsp.Results[i].Status.Code); got != want {
+			t.Errorf("BatchCreateTrees() result %d has code %v, want %v", i, got, want)
+		}
+	}
+
+	// Batches which don't write are allowed on storage which can't write them atomically.
+	tx := storage.NewMockAdminTX(ctrl)
+	tx.EXPECT().Commit().Return(nil)
+	tx.EXPECT().Close().Return(nil)
+	as := storage.NewMockAdminStorage(ctrl)
+	as.EXPECT().Begin(gomock.Any()).Return(&atomicityTX{AdminTX: tx, maxAtomic: -1}, nil)
+	s := &Server{registry: extension.Registry{AdminStorage: as}}
+	if _, err := s.BatchCreateTrees(ctx, &trillian.BatchCreateTreesRequest{
+		Requests: []*trillian.CreateTreeRequest{{Tree: testonly.LogTree, ValidateOnly: true}, {Tree: testonly.MapTree, ValidateOnly: true}},
+	}); err != nil {
+		t.Errorf("BatchCreateTrees() of validate_only requests = (_, %v), want nil", err)
+	}
+}
+
+func TestAdminServer_BatchInvalidRequest(t *testing.T) {
+	ctx := context.Background()
+	s := &Server{}
+	tooMany := make([]*trillian.CreateTreeRequest, maxBatchSize+1)
+	for i := range tooMany {
+		tooMany[i] = &trillian.CreateTreeRequest{Tree: testonly.LogTree}
+	}
+	for _, test := range []struct {
+		desc string
+		req  *trillian.BatchCreateTreesRequest
+	}{
+		{desc: "empty", req: &trillian.BatchCreateTreesRequest{}},
+		{desc: "tooMany", req: &trillian.BatchCreateTreesRequest{Requests: tooMany}},
+	} {
+		if _, err := s.BatchCreateTrees(ctx, test.req); grpc.Code(err) != codes.InvalidArgument {
+			t.Errorf("%v: BatchCreateTrees() = (_, %v), want %s", test.desc, err, codes.InvalidArgument)
+		}
+	}
+	if _, err := s.BatchUpdateTrees(ctx, &trillian.BatchUpdateTreesRequest{}); grpc.Code(err) != codes.InvalidArgument {
+		t.Errorf("BatchUpdateTrees() = (_, %v), want %s", err, codes.InvalidArgument)
+	}
+}
+
+func TestAdminServer_SequenceLog(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mapTree := *testonly.MapTree
+	logTree := *testonly.LogTree
+	frozenTree := *testonly.LogTree
+	frozenTree.TreeState = trillian.TreeState_FROZEN
+	finalizedTree := frozenTree
+	finalizedTree.FinalizeTimeMillisSinceEpoch = 1000
+	deletedTree := *testonly.LogTree
+	deletedTree.TreeState = trillian.TreeState_SOFT_DELETED
+
+	tests := []struct {
+		desc         string
+		storedTree   *trillian.Tree
+		noSequencer  bool
+		wantCode     codes.Code
+		wantSequence bool
+	}{
+		{
+			desc:        "noSequencer",
+			noSequencer: true,
+			wantCode:    codes.FailedPrecondition,
+		},
+		{
+			desc:       "mapTree",
+			storedTree: &mapTree,
+			wantCode:   codes.InvalidArgument,
+		},
+		{
+			desc:         "frozenLog",
+			storedTree:   &frozenTree,
+			wantSequence: true,
+		},
+		{
+			desc:       "finalizedLog",
+			storedTree: &finalizedTree,
+			wantCode:   codes.FailedPrecondition,
+		},
+		{
+			desc:       "deletedLog",
+			storedTree: &deletedTree,
+			wantCode:   codes.FailedPrecondition,
+		},
+		{
+			desc:         "success",
+			storedTree:   &logTree,
+			wantSequence: true,
+		},
+	}
+
+	ctx := context.Background()
+	for _, test := range tests {
+		s := &Server{}
+		if test.storedTree != nil {
+			setup := setupAdminStorage(ctrl, true /* snapshot */, true /* shouldCommit */, false /* commitErr */)
+			setup.snapshotTX.EXPECT().GetTree(ctx, int64(12345)).Return(test.storedTree, nil)
+			s = setup.server
+		}
+		sequenced := false
+		if !test.noSequencer {
+			s.SetLogSequencer(func(_ context.Context, logID int64) (*trillian.SequenceLogResponse, error) {
+				sequenced = true
+				return &trillian.SequenceLogResponse{LeavesSequenced: 3}, nil
+			})
+		}
+
+		rsp, err := s.SequenceLog(ctx, &trillian.SequenceLogRequest{LogId: 12345})
+		if got := grpc.Code(err); got != test.wantCode {
+			t.Errorf("%v: SequenceLog() = (_, %v), want code %v", test.desc, err, test.wantCode)
+		}
+		if sequenced != test.wantSequence {
+			t.Errorf("%v: SequenceLog() called sequencer = %v, want %v", test.desc, sequenced, test.wantSequence)
+		}
+		if test.wantSequence && rsp.GetLeavesSequenced() != 3 {
+			t.Errorf("%v: SequenceLog() = (%v, _), want 3 leaves sequenced", test.desc, rsp)
+		}
+	}
+}
+
+func TestAdminServer_GetTreeStats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Unix(1500000000, 0)
+	mapTree := *testonly.MapTree
+
+	tests := []struct {
+		desc       string
+		storedTree *trillian.Tree
+		leaves     int64
+		oldest     time.Time
+		usage      storage.StorageUsage
+		usageErr   error
+		want       *trillian.GetTreeStatsResponse
+		wantCode   codes.Code
+	}{
+		{
+			desc:       "mapTree",
+			storedTree: &mapTree,
+			wantCode:   codes.InvalidArgument,
+		},
+		{
+			desc:       "emptyQueue",
+			storedTree: testonly.LogTree,
+			want:       &trillian.GetTreeStatsResponse{TreeSize: 10, RootTimestampNanos: 1000},
+		},
+		{
+			desc:       "queuedLeaves",
+			storedTree: testonly.LogTree,
+			leaves:     5,
+			oldest:     now.Add(-time.Minute),
+			want: &trillian.GetTreeStatsResponse{
+				TreeSize:                  10,
+				UnsequencedLeaves:         5,
+				OldestUnsequencedAgeNanos: int64(time.Minute),
+				RootTimestampNanos:        1000,
+			},
+		},
+		{
+			desc:       "storageUsage",
+			storedTree: testonly.LogTree,
+			usage:      storage.StorageUsage{LeafBytes: 4096, NodeBytes: 2048},
+			want: &trillian.GetTreeStatsResponse{
+				TreeSize:           10,
+				RootTimestampNanos: 1000,
+				LeafBytes:          4096,
+				NodeBytes:          2048,
+			},
+		},
+		{
+			desc:       "usageUnimplemented",
+			storedTree: testonly.LogTree,
+			usageErr:   te.Errorf(te.Unimplemented, "no usage"),
+			want:       &trillian.GetTreeStatsResponse{TreeSize: 10, RootTimestampNanos: 1000},
+		},
+	}
+
+	ctx := context.Background()
+	for _, test := range tests {
+		setup := setupAdminStorage(ctrl, true /* snapshot */, true /* shouldCommit */, false /* commitErr */)
+		storedTree := *test.storedTree
+		setup.snapshotTX.EXPECT().GetTree(ctx, int64(12345)).Return(&storedTree, nil)
+		ls := storage.NewMockLogStorage(ctrl)
+		if test.want != nil {
+			tx := storage.NewMockReadOnlyLogTreeTX(ctrl)
+			ls.EXPECT().SnapshotForTree(ctx, storedTree.TreeId).Return(tx, nil)
+			tx.EXPECT().LatestSignedLogRoot().Return(trillian.SignedLogRoot{TreeSize: 10, TimestampNanos: 1000}, nil)
+			tx.EXPECT().GetUnsequencedStats().Return(test.leaves, test.oldest, nil)
+			tx.EXPECT().GetStorageUsage().Return(test.usage, test.usageErr)
+			tx.EXPECT().Commit().Return(nil)
+			tx.EXPECT().Close().Return(nil)
+		}
+		s := setup.server
+		s.registry.LogStorage = ls
+		s.timeSource = util.FakeTimeSource{FakeTime: now}
+
+		got, err := s.GetTreeStats(ctx, &trillian.GetTreeStatsRequest{TreeId: 12345})
+		if code := grpc.Code(err); code != test.wantCode {
+			t.Errorf("%v: GetTreeStats() = (_, %v), want code %v", test.desc, err, test.wantCode)
+			continue
+		}
+		if diff := pretty.Compare(got, test.want); diff != "" {
+			t.Errorf("%v: GetTreeStats() diff (-got +want):\n%v", test.desc, diff)
+		}
+	}
+}
+
 // adminTestSetup contains an operational Server and required dependencies.
 // It's created via setupAdminServer.
 type adminTestSetup struct {
@@ -274,7 +1051,7 @@ func setupAdminStorage(ctrl *gomock.Controller, snapshot, shouldCommit, commitEr
 		AdminStorage: as,
 	}
 
-	s := &Server{registry}
+	s := &Server{registry: registry}
 
 	return adminTestSetup{registry, as, tx, snapshotTX, s}
 }
@@ -0,0 +1,89 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+// allowedWriters are the cached allowed writers of a tree.
+type allowedWriters struct {
+	ids     []string
+	checked time.Time
+}
+
+// WriterCache holds the allowed_writers of each log that leaves are added to, rereading
+// them from admin storage once they're older than the refresh interval. A change to a
+// tree's writers may take that long to be enforced.
+type WriterCache struct {
+	registry   extension.Registry
+	timeSource util.TimeSource
+	refresh    time.Duration
+	// shards is nil unless sharding is enabled.
+	shards *shardRouter
+
+	mu      sync.Mutex
+	writers map[int64]allowedWriters
+}
+
+// NewWriterCache creates a WriterCache reading trees from registry's admin storage.
+func NewWriterCache(registry extension.Registry, timeSource util.TimeSource, refresh time.Duration) *WriterCache {
+	return &WriterCache{
+		registry:   registry,
+		timeSource: timeSource,
+		refresh:    refresh,
+		writers:    make(map[int64]allowedWriters),
+	}
+}
+
+// EnableSharding makes the writers of a log which is a shard be those of the shard of
+// its set that's active, which leaves are queued to, as they are by
+// TrillianLogRPCServer.EnableSharding with the same refresh interval.
+func (c *WriterCache) EnableSharding(refresh time.Duration) {
+	c.shards = newShardRouter(c.registry.AdminStorage, c.timeSource, refresh)
+}
+
+// Writers returns the allowed_writers of logID's tree, or of the active shard of its
+// set if sharding is enabled. It's an interceptor.WritersFunc.
+func (c *WriterCache) Writers(ctx context.Context, logID int64) ([]string, error) {
+	if c.shards != nil {
+		var err error
+		if logID, err = c.shards.active(ctx, logID); err != nil {
+			return nil, err
+		}
+	}
+	now := c.timeSource.Now()
+	c.mu.Lock()
+	w, ok := c.writers[logID]
+	c.mu.Unlock()
+	if ok && now.Sub(w.checked) < c.refresh {
+		return w.ids, nil
+	}
+
+	tree, err := getTree(ctx, c.registry, logID)
+	if err != nil {
+		return nil, err
+	}
+	w = allowedWriters{ids: tree.AllowedWriters, checked: now}
+	c.mu.Lock()
+	c.writers[logID] = w
+	c.mu.Unlock()
+	return w.ids, nil
+}
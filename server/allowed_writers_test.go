@@ -0,0 +1,92 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/util"
+)
+
+func TestWriterCache(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	as := storage.NewMockAdminStorage(ctrl)
+	tx := storage.NewMockReadOnlyAdminTX(ctrl)
+	as.EXPECT().Snapshot(gomock.Any()).Times(2).Return(tx, nil)
+	gomock.InOrder(
+		tx.EXPECT().GetTree(gomock.Any(), int64(7)).Return(&trillian.Tree{TreeId: 7, AllowedWriters: []string{"spiffe://example.org/ct/"}}, nil),
+		tx.EXPECT().GetTree(gomock.Any(), int64(7)).Return(&trillian.Tree{TreeId: 7}, nil),
+	)
+	tx.EXPECT().Commit().Times(2).Return(nil)
+	tx.EXPECT().Close().Times(2).Return(nil)
+
+	ts := &util.FakeTimeSource{FakeTime: fakeTime}
+	c := NewWriterCache(extension.Registry{AdminStorage: as}, ts, time.Minute)
+
+	want := []string{"spiffe://example.org/ct/"}
+	for _, offset := range []time.Duration{0, 30 * time.Second} {
+		ts.FakeTime = fakeTime.Add(offset)
+		if got, err := c.Writers(ctx, 7); err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("Writers() after %v=%v, %v, want %v, nil", offset, got, err, want)
+		}
+	}
+	// The tree is reread once the refresh interval has passed.
+	ts.FakeTime = fakeTime.Add(time.Minute)
+	if got, err := c.Writers(ctx, 7); err != nil || len(got) != 0 {
+		t.Errorf("Writers() after refresh=%v, %v, want none, nil", got, err)
+	}
+}
+
+func TestWriterCacheSharded(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	trees := shardSet()
+	// Only the active shard, 102, allows the writer, while the frozen shard the request
+	// names allows anyone.
+	trees[2].AllowedWriters = []string{"spiffe://example.org/ct/"}
+	as := storage.NewMockAdminStorage(ctrl)
+	tx := storage.NewMockReadOnlyAdminTX(ctrl)
+	as.EXPECT().Snapshot(gomock.Any()).Times(3).Return(tx, nil)
+	tx.EXPECT().ListTrees(gomock.Any()).Return(trees, nil)
+	tx.EXPECT().GetTree(gomock.Any(), int64(102)).Return(trees[2], nil)
+	tx.EXPECT().GetTree(gomock.Any(), int64(200)).Return(trees[4], nil)
+	tx.EXPECT().Commit().Times(3).Return(nil)
+	tx.EXPECT().Close().Times(3).Return(nil)
+
+	c := NewWriterCache(extension.Registry{AdminStorage: as}, fakeTimeSource, time.Minute)
+	c.EnableSharding(time.Minute)
+	want := []string{"spiffe://example.org/ct/"}
+	for _, logID := range []int64{101, 102, 103} {
+		if got, err := c.Writers(ctx, logID); err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("Writers(%d)=%v, %v, want %v, nil", logID, got, err, want)
+		}
+	}
+	// Logs which aren't sharded have writers of their own.
+	if got, err := c.Writers(ctx, 200); err != nil || len(got) != 0 {
+		t.Errorf("Writers(200)=%v, %v, want none, nil", got, err)
+	}
+}
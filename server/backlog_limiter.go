@@ -0,0 +1,122 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/trillian/server/errors"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+// BacklogLimits configures when the log server stops accepting new leaves for a tree
+// because the signer has fallen too far behind integrating the ones already queued.
+type BacklogLimits struct {
+	// MaxLeaves is the number of queued leaves at which writes are rejected, zero for no limit.
+	MaxLeaves int64
+	// MaxAge is how long the oldest queued leaf may have been waiting before writes are
+	// rejected, zero for no limit.
+	MaxAge time.Duration
+	// RefreshInterval is how long the backlog of a tree is cached for between checks.
+	RefreshInterval time.Duration
+}
+
+// minBacklogRetryDelay is the shortest time clients are told to wait before retrying
+// writes rejected for a tree's backlog, which takes at least a sequencing pass to shrink.
+const minBacklogRetryDelay = time.Second
+
+// backlog is the cached state of a tree's queue.
+type backlog struct {
+	leaves  int64
+	oldest  time.Time
+	checked time.Time
+}
+
+// backlogLimiter enforces BacklogLimits. As backlogs are cached, the limits are soft: a
+// tree may go over them by however many leaves are queued within one refresh interval.
+type backlogLimiter struct {
+	limits     BacklogLimits
+	logStorage storage.ReadOnlyLogStorage
+	timeSource util.TimeSource
+
+	mu       sync.Mutex
+	backlogs map[int64]backlog
+}
+
+func newBacklogLimiter(limits BacklogLimits, logStorage storage.ReadOnlyLogStorage, timeSource util.TimeSource) *backlogLimiter {
+	return &backlogLimiter{
+		limits:     limits,
+		logStorage: logStorage,
+		timeSource: timeSource,
+		backlogs:   make(map[int64]backlog),
+	}
+}
+
+// check returns a ResourceExhausted error if logID's backlog is over the limits. Clients
+// are told to retry once the backlog has been checked again.
+func (b *backlogLimiter) check(ctx context.Context, logID int64) error {
+	now := b.timeSource.Now()
+	bl, err := b.get(ctx, logID, now)
+	if err != nil {
+		return err
+	}
+
+	subject := fmt.Sprintf("log:%d", logID)
+	retryDelay := bl.checked.Add(b.limits.RefreshInterval).Sub(now)
+	if retryDelay < minBacklogRetryDelay {
+		retryDelay = minBacklogRetryDelay
+	}
+	if b.limits.MaxLeaves > 0 && bl.leaves >= b.limits.MaxLeaves {
+		return errors.ResourceExhausted(subject, "max_unsequenced_leaves", retryDelay, "log %d has %d unsequenced leaves, limit is %d", logID, bl.leaves, b.limits.MaxLeaves)
+	}
+	if b.limits.MaxAge > 0 && bl.leaves > 0 {
+		if age := now.Sub(bl.oldest); age > b.limits.MaxAge {
+			return errors.ResourceExhausted(subject, "max_unsequenced_age", retryDelay, "log %d has had leaves waiting to be sequenced for %v, limit is %v", logID, age, b.limits.MaxAge)
+		}
+	}
+	return nil
+}
+
+func (b *backlogLimiter) get(ctx context.Context, logID int64, now time.Time) (backlog, error) {
+	b.mu.Lock()
+	bl, ok := b.backlogs[logID]
+	b.mu.Unlock()
+	if ok && now.Sub(bl.checked) < b.limits.RefreshInterval {
+		return bl, nil
+	}
+
+	tx, err := b.logStorage.SnapshotForTree(ctx, logID)
+	if err != nil {
+		return backlog{}, err
+	}
+	defer tx.Close()
+	leaves, oldest, err := tx.GetUnsequencedStats()
+	if err != nil {
+		return backlog{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return backlog{}, err
+	}
+
+	bl = backlog{leaves: leaves, oldest: oldest, checked: now}
+	b.mu.Lock()
+	b.backlogs[logID] = bl
+	b.mu.Unlock()
+	return bl, nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"expvar"
+	"sync"
+	"time"
+)
+
+// batchSizes exports the batch size currently chosen for each log, keyed by log ID.
+var batchSizes = expvar.NewMap("sequencer-batch-size")
+
+// batchSizer adapts the number of leaves sequenced per pass for each log. Batches
+// grow while a log has a backlog, i.e. fills whole batches, and sequencing keeps
+// within the target latency, and shrink when sequencing takes longer than that.
+type batchSizer struct {
+	minSize, maxSize int
+	target           time.Duration
+
+	mu    sync.Mutex
+	sizes map[int64]int
+}
+
+func newBatchSizer(minSize, maxSize int, target time.Duration) *batchSizer {
+	return &batchSizer{
+		minSize: minSize,
+		maxSize: maxSize,
+		target:  target,
+		sizes:   make(map[int64]int),
+	}
+}
+
+// size returns the batch size to use for logID. Logs start off at initial, kept
+// within the sizer's bounds.
+func (b *batchSizer) size(logID int64, initial int) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if size, ok := b.sizes[logID]; ok {
+		return size
+	}
+	return b.setLocked(logID, initial)
+}
+
+// update records that a batch of size for logID sequenced leaves in latency, and
+// adjusts the size of the next batch accordingly.
+func (b *batchSizer) update(logID int64, size, leaves int, latency time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case latency > b.target:
+		b.setLocked(logID, size/2)
+	case leaves >= size:
+		b.setLocked(logID, size*2)
+	}
+}
+
+func (b *batchSizer) setLocked(logID int64, size int) int {
+	if size < b.minSize {
+		size = b.minSize
+	}
+	if size > b.maxSize {
+		size = b.maxSize
+	}
+	b.sizes[logID] = size
+	setLogInt(batchSizes, logID, int64(size))
+	return size
+}
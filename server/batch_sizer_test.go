@@ -0,0 +1,60 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBatchSizer(t *testing.T) {
+	const logID = int64(1)
+	fast := 10 * time.Millisecond
+	slow := 2 * time.Second
+
+	tests := []struct {
+		desc    string
+		leaves  int
+		latency time.Duration
+		want    int
+	}{
+		{desc: "backlogGrows", leaves: 100, latency: fast, want: 200},
+		{desc: "backlogGrowsAgain", leaves: 200, latency: fast, want: 400},
+		{desc: "backlogCappedAtMax", leaves: 400, latency: fast, want: 500},
+		{desc: "noBacklogUnchanged", leaves: 7, latency: fast, want: 500},
+		{desc: "slowShrinks", leaves: 500, latency: slow, want: 250},
+		{desc: "slowShrinksAgain", leaves: 3, latency: slow, want: 125},
+		{desc: "slowShrinksAgain2", leaves: 3, latency: slow, want: 62},
+		{desc: "slowCappedAtMin", leaves: 3, latency: slow, want: 50},
+	}
+
+	b := newBatchSizer(50, 500, time.Second)
+	if got, want := b.size(logID, 100), 100; got != want {
+		t.Fatalf("size() = %v, want %v", got, want)
+	}
+	for _, test := range tests {
+		b.update(logID, b.size(logID, 100), test.leaves, test.latency)
+		if got := b.size(logID, 100); got != test.want {
+			t.Errorf("%v: size() = %v, want %v", test.desc, got, test.want)
+		}
+	}
+
+	if got, want := b.size(logID+1, 10), 50; got != want {
+		t.Errorf("size() for new log = %v, want %v", got, want)
+	}
+	if got, want := batchSizes.Get("1").String(), "50"; got != want {
+		t.Errorf("exported batch size = %v, want %v", got, want)
+	}
+}
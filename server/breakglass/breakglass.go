@@ -0,0 +1,211 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package breakglass serves the admin API on a Unix socket for emergency access when
+// the network authentication system is down. Access to the socket is controlled by
+// filesystem permissions instead of the usual interceptors, and no RPC made over it is
+// served unless it has been audited.
+package breakglass
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian/monitoring/logging"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+)
+
+var rpcs = expvar.NewMap("break-glass-rpcs")
+
+// Listen creates a Unix socket at path, replacing a stale socket left there, which only
+// the server's user may connect to. The directory holding it should be just as private,
+// as the socket's permissions are set after it's created.
+func Listen(path string) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and isn't a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	lis, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		lis.Close()
+		return nil, err
+	}
+	return &listener{lis}, nil
+}
+
+// listener tags each connection with the credentials of the process at the other end.
+type listener struct {
+	net.Listener
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	addr := &PeerAddr{Addr: c.RemoteAddr(), UID: -1, PID: -1}
+	if uid, pid, ok := peerCred(c); ok {
+		addr.UID, addr.PID = uid, pid
+	}
+	return &conn{Conn: c, addr: addr}, nil
+}
+
+type conn struct {
+	net.Conn
+	addr *PeerAddr
+}
+
+func (c *conn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+// PeerAddr is the address of a connection accepted by the listener returned by Listen,
+// which gRPC reports as the peer of its RPCs. UID and PID are those of the connecting
+// process, or -1 where the platform doesn't tell.
+type PeerAddr struct {
+	net.Addr
+	UID int
+	PID int
+}
+
+func (a *PeerAddr) String() string {
+	return fmt.Sprintf("uid=%d pid=%d", a.UID, a.PID)
+}
+
+// Entry is the audit record of an RPC, written as a line of JSON. Each RPC gets an
+// entry without a Code before it's served, and one with the Code it ended with.
+type Entry struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id,omitempty"`
+	UID       int       `json:"uid"`
+	PID       int       `json:"pid"`
+	Method    string    `json:"method"`
+	Request   string    `json:"request,omitempty"`
+	Code      string    `json:"code,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Auditor records the RPCs made over the socket, in the server log at warning level and
+// as JSON lines written to an optional audit trail. An RPC whose first entry can't be
+// written to the audit trail fails with Unavailable without being served.
+type Auditor struct {
+	timeSource util.TimeSource
+
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// NewAuditor returns an Auditor which also writes entries to out, if it isn't nil.
+func NewAuditor(out io.Writer, timeSource util.TimeSource) *Auditor {
+	return &Auditor{out: out, timeSource: timeSource}
+}
+
+// Interceptor returns an interceptor auditing each RPC. The request is recorded in full.
+func (a *Auditor) Interceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		e := a.newEntry(ctx, info.FullMethod, req)
+		if err := a.record(ctx, e); err != nil {
+			return nil, err
+		}
+		resp, err := handler(ctx, req)
+		a.recordResult(ctx, e, err)
+		return resp, err
+	}
+}
+
+// StreamInterceptor returns an interceptor auditing each streaming RPC. Stream messages
+// aren't recorded.
+func (a *Auditor) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		e := a.newEntry(ss.Context(), info.FullMethod, nil)
+		if err := a.record(ss.Context(), e); err != nil {
+			return err
+		}
+		err := handler(srv, ss)
+		a.recordResult(ss.Context(), e, err)
+		return err
+	}
+}
+
+func (a *Auditor) newEntry(ctx context.Context, method string, req interface{}) Entry {
+	e := Entry{
+		Time:      a.timeSource.Now(),
+		RequestID: util.RequestID(ctx),
+		UID:       -1,
+		PID:       -1,
+		Method:    method,
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if addr, ok := p.Addr.(*PeerAddr); ok {
+			e.UID, e.PID = addr.UID, addr.PID
+		}
+	}
+	if m, ok := req.(proto.Message); ok {
+		e.Request = proto.CompactTextString(m)
+	}
+	return e
+}
+
+// recordResult records the code RPC e ended with. The RPC has been served by now, so
+// failing to write the entry is only logged.
+func (a *Auditor) recordResult(ctx context.Context, e Entry, err error) {
+	e.Code = grpc.Code(err).String()
+	if err != nil {
+		e.Error = err.Error()
+	}
+	rpcs.Add(e.Code, 1)
+	if werr := a.record(ctx, e); werr != nil {
+		logging.Errorf(ctx, "Failed to record result of break-glass RPC %s: %v", e.Method, werr)
+	}
+}
+
+// record logs e and writes it to the audit trail, returning an Unavailable error if it
+// can't be written.
+func (a *Auditor) record(ctx context.Context, e Entry) error {
+	logging.Warningf(ctx, "break-glass RPC: uid=%d pid=%d method=%s code=%s request=%q", e.UID, e.PID, e.Method, e.Code, e.Request)
+
+	if a.out == nil {
+		return nil
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		logging.Errorf(ctx, "Failed to encode audit entry: %v", err)
+		return grpc.Errorf(codes.Unavailable, "failed to encode audit entry")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.out.Write(append(line, '\n')); err != nil {
+		logging.Errorf(ctx, "Failed to write audit entry: %v", err)
+		return grpc.Errorf(codes.Unavailable, "failed to write audit entry")
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package breakglass
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+)
+
+func TestListen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "breakglass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "admin.sock")
+
+	// A stale socket is replaced.
+	for i := 0; i < 2; i++ {
+		lis, err := Listen(path)
+		if err != nil {
+			t.Fatalf("Listen()=(_, %v), want (_, nil)", err)
+		}
+		if i == 0 {
+			// Leave the socket file behind, as a crashed server would.
+			lis.(*listener).Listener.(*net.UnixListener).SetUnlinkOnClose(false)
+			lis.Close()
+			continue
+		}
+		defer lis.Close()
+
+		fi, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fi.Mode().Perm(); got != 0600 {
+			t.Errorf("socket mode=%v, want 0600", got)
+		}
+
+		go func() {
+			if c, err := net.Dial("unix", path); err == nil {
+				defer c.Close()
+				time.Sleep(100 * time.Millisecond)
+			}
+		}()
+		c, err := lis.Accept()
+		if err != nil {
+			t.Fatalf("Accept()=(_, %v), want (_, nil)", err)
+		}
+		defer c.Close()
+		addr, ok := c.RemoteAddr().(*PeerAddr)
+		if !ok {
+			t.Fatalf("RemoteAddr()=%T, want *PeerAddr", c.RemoteAddr())
+		}
+		if runtime.GOOS == "linux" && (addr.UID != os.Getuid() || addr.PID != os.Getpid()) {
+			t.Errorf("RemoteAddr()=%v, want uid=%d pid=%d", addr, os.Getuid(), os.Getpid())
+		}
+	}
+}
+
+func TestListenNotSocket(t *testing.T) {
+	f, err := ioutil.TempFile("", "breakglass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	if lis, err := Listen(f.Name()); err == nil {
+		lis.Close()
+		t.Errorf("Listen(regular file)=(_, nil), want error")
+	}
+}
+
+func TestAuditor(t *testing.T) {
+	now := time.Date(2017, 7, 1, 12, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	a := NewAuditor(&buf, util.FakeTimeSource{FakeTime: now})
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &PeerAddr{UID: 1000, PID: 42}})
+	ctx = util.NewRequestIDContext(ctx, "req-1")
+	req := &trillian.DeleteTreeRequest{TreeId: 12345}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, grpc.Errorf(codes.NotFound, "no tree")
+	}
+	_, err := a.Interceptor()(ctx, req, &grpc.UnaryServerInfo{FullMethod: "/trillian.TrillianAdmin/DeleteTree"}, handler)
+	if grpc.Code(err) != codes.NotFound {
+		t.Fatalf("Interceptor()()=(_, %v), want NotFound", err)
+	}
+
+	// The RPC is recorded before it's served, then with its result.
+	started := Entry{
+		Time:      now,
+		RequestID: "req-1",
+		UID:       1000,
+		PID:       42,
+		Method:    "/trillian.TrillianAdmin/DeleteTree",
+		Request:   "tree_id:12345",
+	}
+	ended := started
+	ended.Code, ended.Error = "NotFound", err.Error()
+	dec := json.NewDecoder(&buf)
+	for _, want := range []Entry{started, ended} {
+		var got Entry
+		if err := dec.Decode(&got); err != nil {
+			t.Fatalf("audit trail isn't a list of entries: %v", err)
+		}
+		if got != want {
+			t.Errorf("audit entry=%+v, want %+v", got, want)
+		}
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestAuditorFailsClosed(t *testing.T) {
+	a := NewAuditor(failingWriter{}, util.SystemTimeSource{})
+	served := false
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		served = true
+		return nil, nil
+	}
+	req := &trillian.DeleteTreeRequest{TreeId: 12345}
+	_, err := a.Interceptor()(context.Background(), req, &grpc.UnaryServerInfo{FullMethod: "/trillian.TrillianAdmin/DeleteTree"}, handler)
+	if grpc.Code(err) != codes.Unavailable {
+		t.Errorf("Interceptor()()=(_, %v), want Unavailable", err)
+	}
+	if served {
+		t.Errorf("Interceptor()() served an RPC it couldn't audit")
+	}
+}
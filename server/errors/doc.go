@@ -12,5 +12,6 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package errors contains utilities to translate TrillianErrors to gRPC errors.
+// Package errors contains utilities to translate TrillianErrors and storage errors to
+// gRPC errors.
 package errors
@@ -16,15 +16,35 @@ package errors
 
 import (
 	"database/sql"
+	"fmt"
+	"time"
 
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
 	te "github.com/google/trillian/errors"
+	"github.com/google/trillian/storage"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-// WrapError wraps err as a gRPC error if err is a TrillianError or a well-known
-// error instance (such as canonical sql errors), else err is returned
-// unmodified.
+// storageErrors maps the types of storage.Error to the gRPC codes they're returned with
+// and the types of resource described by their ResourceInfo details.
+var storageErrors = map[int]struct {
+	code         codes.Code
+	resourceType string
+}{
+	storage.DuplicateLeaf:    {codes.AlreadyExists, "leaf"},
+	storage.TreeNotFound:     {codes.NotFound, "tree"},
+	storage.OutOfRange:       {codes.OutOfRange, "tree"},
+	storage.TransientFailure: {codes.Unavailable, "storage"},
+	storage.TreeNotWritable:  {codes.FailedPrecondition, "tree"},
+}
+
+// WrapError wraps err as a gRPC error if err is a TrillianError, a storage.Error or a
+// well-known error instance (such as canonical sql errors), else err is returned
+// unmodified. Storage errors are given a ResourceInfo detail saying what they're about.
 func WrapError(err error) error {
 	if err == sql.ErrNoRows {
 		return grpc.Errorf(codes.NotFound, err.Error())
@@ -33,9 +53,36 @@ func WrapError(err error) error {
 	switch err := err.(type) {
 	case te.TrillianError:
 		return grpc.Errorf(codes.Code(err.Code()), err.Error())
+	case storage.Error:
+		mapped, ok := storageErrors[err.ErrType]
+		if !ok {
+			return err
+		}
+		s := status.New(mapped.code, err.Detail)
+		if withDetails, detailErr := s.WithDetails(&errdetails.ResourceInfo{ResourceType: mapped.resourceType, Description: err.Detail}); detailErr == nil {
+			s = withDetails
+		}
+		return s.Err()
 	default:
 		// Nothing to do: if it's a gRPC error it's already correct, if not gRPC will assume
 		// codes.Unknown.
 		return err
 	}
 }
+
+// ResourceExhausted returns a ResourceExhausted gRPC error with a QuotaFailure detail
+// saying subject, e.g. "log:123", is over quota, and, if retryDelay is greater than 0, a
+// RetryInfo detail saying how long clients should wait before trying again.
+func ResourceExhausted(subject, quota string, retryDelay time.Duration, format string, args ...interface{}) error {
+	s := status.New(codes.ResourceExhausted, fmt.Sprintf(format, args...))
+	details := []proto.Message{&errdetails.QuotaFailure{
+		Violations: []*errdetails.QuotaFailure_Violation{{Subject: subject, Description: quota}},
+	}}
+	if retryDelay > 0 {
+		details = append(details, &errdetails.RetryInfo{RetryDelay: ptypes.DurationProto(retryDelay)})
+	}
+	if withDetails, err := s.WithDetails(details...); err == nil {
+		s = withDetails
+	}
+	return s.Err()
+}
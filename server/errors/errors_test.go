@@ -18,11 +18,16 @@ import (
 	"database/sql"
 	"errors"
 	"testing"
+	"time"
 
+	"github.com/golang/protobuf/ptypes"
 	te "github.com/google/trillian/errors"
+	"github.com/google/trillian/storage"
 	"github.com/kylelemons/godebug/pretty"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestWrapError(t *testing.T) {
@@ -58,3 +63,58 @@ func TestWrapError(t *testing.T) {
 		}
 	}
 }
+
+func TestResourceExhausted(t *testing.T) {
+	err := ResourceExhausted("log:12", "max_unsequenced_leaves", 3*time.Second, "log %d is full", 12)
+	s, ok := status.FromError(err)
+	if !ok || s.Code() != codes.ResourceExhausted || s.Message() != "log 12 is full" {
+		t.Fatalf("ResourceExhausted()=%v, want ResourceExhausted status %q", err, "log 12 is full")
+	}
+	var gotQuota, gotRetry bool
+	for _, d := range s.Details() {
+		switch d := d.(type) {
+		case *errdetails.QuotaFailure:
+			gotQuota = len(d.Violations) == 1 && d.Violations[0].Subject == "log:12" && d.Violations[0].Description == "max_unsequenced_leaves"
+		case *errdetails.RetryInfo:
+			delay, err := ptypes.Duration(d.RetryDelay)
+			gotRetry = err == nil && delay == 3*time.Second
+		}
+	}
+	if !gotQuota || !gotRetry {
+		t.Errorf("ResourceExhausted() details=%v, want QuotaFailure for log:12 and RetryInfo of 3s", s.Details())
+	}
+}
+
+func TestWrapStorageError(t *testing.T) {
+	for _, test := range []struct {
+		err              error
+		wantCode         codes.Code
+		wantResourceType string
+	}{
+		{err: storage.Error{ErrType: storage.DuplicateLeaf, Detail: "dup"}, wantCode: codes.AlreadyExists, wantResourceType: "leaf"},
+		{err: storage.Error{ErrType: storage.TreeNotFound, Detail: "no tree"}, wantCode: codes.NotFound, wantResourceType: "tree"},
+		{err: storage.Error{ErrType: storage.OutOfRange, Detail: "too big"}, wantCode: codes.OutOfRange, wantResourceType: "tree"},
+		{err: storage.Error{ErrType: storage.TransientFailure, Detail: "deadlocked"}, wantCode: codes.Unavailable, wantResourceType: "storage"},
+		{err: storage.Error{ErrType: storage.TreeNotWritable, Detail: "frozen"}, wantCode: codes.FailedPrecondition, wantResourceType: "tree"},
+		{err: storage.Error{ErrType: 999, Detail: "unknown"}, wantCode: codes.Unknown},
+	} {
+		err := WrapError(test.err)
+		if got := grpc.Code(err); got != test.wantCode {
+			t.Errorf("WrapError(%v)=%v, want code %v", test.err, err, test.wantCode)
+			continue
+		}
+		if test.wantResourceType == "" {
+			continue
+		}
+		s, _ := status.FromError(err)
+		var gotResourceType string
+		for _, d := range s.Details() {
+			if info, ok := d.(*errdetails.ResourceInfo); ok {
+				gotResourceType = info.ResourceType
+			}
+		}
+		if gotResourceType != test.wantResourceType {
+			t.Errorf("WrapError(%v) details=%v, want ResourceInfo for a %v", test.err, s.Details(), test.wantResourceType)
+		}
+	}
+}
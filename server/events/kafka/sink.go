@@ -0,0 +1,54 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kafka provides an events.Sink which sends events to Apache Kafka.
+package kafka
+
+import (
+	"github.com/Shopify/sarama"
+	"golang.org/x/net/context"
+)
+
+// Sink sends events to Kafka topics, using the log ID as the message key so each
+// log's events land in a single partition and stay in order.
+type Sink struct {
+	producer sarama.SyncProducer
+}
+
+// NewSink returns a Sink connected to the given Kafka brokers.
+func NewSink(brokers []string) (*Sink, error) {
+	cfg := sarama.NewConfig()
+	cfg.Producer.RequiredAcks = sarama.WaitForAll
+	cfg.Producer.Return.Successes = true
+	producer, err := sarama.NewSyncProducer(brokers, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Sink{producer: producer}, nil
+}
+
+// Send implements events.Sink. The producer applies its own timeouts, so ctx is unused.
+func (s *Sink) Send(ctx context.Context, topic, key string, data []byte) error {
+	_, _, err := s.producer.SendMessage(&sarama.ProducerMessage{
+		Topic: topic,
+		Key:   sarama.StringEncoder(key),
+		Value: sarama.ByteEncoder(data),
+	})
+	return err
+}
+
+// Close implements events.Sink.
+func (s *Sink) Close() error {
+	return s.producer.Close()
+}
@@ -0,0 +1,48 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package nats provides an events.Sink which sends events to a NATS server.
+package nats
+
+import (
+	gonats "github.com/nats-io/go-nats"
+	"golang.org/x/net/context"
+)
+
+// Sink publishes events to NATS subjects. NATS messages have no key, consumers can
+// find the log ID in the event itself.
+type Sink struct {
+	conn *gonats.Conn
+}
+
+// NewSink returns a Sink connected to the NATS server at url.
+func NewSink(url string) (*Sink, error) {
+	conn, err := gonats.Connect(url)
+	if err != nil {
+		return nil, err
+	}
+	return &Sink{conn: conn}, nil
+}
+
+// Send implements events.Sink. Publishing is buffered by the client, so ctx is unused.
+func (s *Sink) Send(ctx context.Context, topic, key string, data []byte) error {
+	return s.conn.Publish(topic, data)
+}
+
+// Close implements events.Sink.
+func (s *Sink) Close() error {
+	err := s.conn.Flush()
+	s.conn.Close()
+	return err
+}
@@ -0,0 +1,180 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package events publishes newly signed log roots, their checkpoints and newly
+// integrated leaves to a message bus, so downstream indexers can follow a log
+// without polling the API. The bus itself is pluggable, see the kafka, nats and
+// pubsub subpackages.
+package events
+
+import (
+	"encoding/json"
+	"expvar"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/google/trillian"
+	"golang.org/x/net/context"
+)
+
+var stats = expvar.NewMap("event-publisher")
+
+// Sink is a message bus which events can be sent to.
+type Sink interface {
+	// Send delivers data to topic. key identifies the log the event is about, sinks
+	// which support it should use it to keep each log's events in order.
+	Send(ctx context.Context, topic, key string, data []byte) error
+	// Close flushes any buffered messages and releases the sink's resources.
+	Close() error
+}
+
+// Options configures a Publisher.
+type Options struct {
+	// RootTopic is the topic new roots are sent to, as JSON encoded SignedLogRoot
+	// messages. Roots aren't published if it's empty.
+	RootTopic string
+	// LeafTopic is the topic integrated leaves are sent to, one event per sequenced
+	// batch. Leaves aren't published if it's empty.
+	LeafTopic string
+	// CheckpointTopic is the topic signed checkpoint notes are sent to, as text.
+	// Checkpoints aren't published if it's empty.
+	CheckpointTopic string
+	// Timeout bounds each send to the sink.
+	Timeout time.Duration
+	// QueueSize is the number of events which can be waiting to be sent, events
+	// published while the queue is full are dropped.
+	QueueSize int
+}
+
+// LeavesEvent is the JSON payload of the events sent to Options.LeafTopic.
+type LeavesEvent struct {
+	LogID int64 `json:"logId,string"`
+	// Leaves are JSON encoded LogLeaf messages, carrying their assigned LeafIndex.
+	Leaves []json.RawMessage `json:"leaves"`
+}
+
+type event struct {
+	topic string
+	key   string
+	data  []byte
+}
+
+// Publisher sends roots, leaves and checkpoints to a Sink in the order they're
+// published. Sending happens in the background so signing isn't held up by a slow bus.
+type Publisher struct {
+	sink   Sink
+	opts   Options
+	events chan event
+	done   chan struct{}
+}
+
+// New returns a Publisher sending to sink and starts its sending goroutine, which runs
+// until Close is called.
+func New(sink Sink, opts Options) *Publisher {
+	p := &Publisher{
+		sink:   sink,
+		opts:   opts,
+		events: make(chan event, opts.QueueSize),
+		done:   make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+// PublishRoot queues root to be sent to the root topic. It doesn't block, if the queue
+// is full the root is dropped.
+func (p *Publisher) PublishRoot(root trillian.SignedLogRoot) {
+	if p.opts.RootTopic == "" {
+		return
+	}
+	data, err := (&jsonpb.Marshaler{}).MarshalToString(&root)
+	if err != nil {
+		glog.Errorf("%v: failed to marshal root: %v", root.LogId, err)
+		return
+	}
+	p.enqueue(event{topic: p.opts.RootTopic, key: strconv.FormatInt(root.LogId, 10), data: []byte(data)})
+}
+
+// PublishLeaves queues a LeavesEvent holding leaves to be sent to the leaf topic. It
+// doesn't block, if the queue is full the event is dropped.
+func (p *Publisher) PublishLeaves(logID int64, leaves []*trillian.LogLeaf) {
+	if p.opts.LeafTopic == "" || len(leaves) == 0 {
+		return
+	}
+	ev := LeavesEvent{LogID: logID}
+	for _, leaf := range leaves {
+		l, err := (&jsonpb.Marshaler{}).MarshalToString(leaf)
+		if err != nil {
+			glog.Errorf("%v: failed to marshal leaf %d: %v", logID, leaf.LeafIndex, err)
+			return
+		}
+		ev.Leaves = append(ev.Leaves, json.RawMessage(l))
+	}
+	data, err := json.Marshal(ev)
+	if err != nil {
+		glog.Errorf("%v: failed to marshal leaves: %v", logID, err)
+		return
+	}
+	p.enqueue(event{topic: p.opts.LeafTopic, key: strconv.FormatInt(logID, 10), data: data})
+}
+
+// PublishCheckpoint queues note to be sent to the checkpoint topic. It doesn't block, if
+// the queue is full the note is dropped.
+func (p *Publisher) PublishCheckpoint(logID int64, note []byte) {
+	if p.opts.CheckpointTopic == "" {
+		return
+	}
+	p.enqueue(event{topic: p.opts.CheckpointTopic, key: strconv.FormatInt(logID, 10), data: note})
+}
+
+func (p *Publisher) enqueue(e event) {
+	select {
+	case p.events <- e:
+		stats.Add("queued", 1)
+	default:
+		stats.Add("dropped", 1)
+		glog.Warningf("%v: event queue full, dropping event for topic %v", e.key, e.topic)
+	}
+}
+
+// Close stops accepting events, waits for the queued ones to be sent and closes the sink.
+func (p *Publisher) Close() error {
+	close(p.events)
+	<-p.done
+	return p.sink.Close()
+}
+
+func (p *Publisher) run() {
+	defer close(p.done)
+	for e := range p.events {
+		if err := p.send(e); err != nil {
+			stats.Add("send-failures", 1)
+			glog.Warningf("%v: failed to send event to topic %v: %v", e.key, e.topic, err)
+			continue
+		}
+		stats.Add("sent", 1)
+	}
+}
+
+func (p *Publisher) send(e event) error {
+	ctx := context.Background()
+	if p.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.opts.Timeout)
+		defer cancel()
+	}
+	return p.sink.Send(ctx, e.topic, e.key, e.data)
+}
@@ -0,0 +1,148 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"golang.org/x/net/context"
+)
+
+type sent struct {
+	topic, key string
+	data       []byte
+}
+
+// recordingSink keeps the messages sent to it, failing those sent to failTopic.
+type recordingSink struct {
+	mu        sync.Mutex
+	failTopic string
+	sent      []sent
+	closed    bool
+}
+
+func (s *recordingSink) Send(ctx context.Context, topic, key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if topic == s.failTopic {
+		return errors.New("unavailable")
+	}
+	s.sent = append(s.sent, sent{topic: topic, key: key, data: data})
+	return nil
+}
+
+func (s *recordingSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestPublisher(t *testing.T) {
+	root := trillian.SignedLogRoot{LogId: 7, TreeSize: 2, TreeRevision: 1, RootHash: []byte("root2")}
+	leaves := []*trillian.LogLeaf{
+		{LeafIndex: 0, LeafValue: []byte("a")},
+		{LeafIndex: 1, LeafValue: []byte("b")},
+	}
+
+	tests := []struct {
+		desc      string
+		opts      Options
+		failTopic string
+		wantRoot  bool
+		wantLeaf  bool
+		wantNote  bool
+	}{
+		{desc: "all", opts: Options{RootTopic: "roots", LeafTopic: "leaves", CheckpointTopic: "notes"}, wantRoot: true, wantLeaf: true, wantNote: true},
+		{desc: "rootsOnly", opts: Options{RootTopic: "roots"}, wantRoot: true},
+		{desc: "leavesOnly", opts: Options{LeafTopic: "leaves"}, wantLeaf: true},
+		{desc: "sendFails", opts: Options{RootTopic: "roots", LeafTopic: "leaves"}, failTopic: "roots", wantLeaf: true},
+	}
+
+	for _, test := range tests {
+		sink := &recordingSink{failTopic: test.failTopic}
+		test.opts.QueueSize = 3
+		p := New(sink, test.opts)
+		p.PublishLeaves(7, leaves)
+		p.PublishRoot(root)
+		p.PublishCheckpoint(7, []byte("note"))
+		if err := p.Close(); err != nil {
+			t.Errorf("%v: Close()=%v", test.desc, err)
+		}
+		if !sink.closed {
+			t.Errorf("%v: sink not closed", test.desc)
+		}
+
+		var gotRoot, gotLeaf, gotNote bool
+		for _, s := range sink.sent {
+			if s.key != "7" {
+				t.Errorf("%v: sent key %q, want %q", test.desc, s.key, "7")
+			}
+			switch s.topic {
+			case "roots":
+				gotRoot = true
+				var r trillian.SignedLogRoot
+				if err := jsonpb.Unmarshal(bytes.NewReader(s.data), &r); err != nil {
+					t.Fatalf("%v: failed to unmarshal root: %v", test.desc, err)
+				}
+				if !proto.Equal(&r, &root) {
+					t.Errorf("%v: sent root %v, want %v", test.desc, r, root)
+				}
+			case "leaves":
+				gotLeaf = true
+				var ev LeavesEvent
+				if err := json.Unmarshal(s.data, &ev); err != nil {
+					t.Fatalf("%v: failed to unmarshal leaves: %v", test.desc, err)
+				}
+				if got, want := ev.LogID, int64(7); got != want {
+					t.Errorf("%v: sent leaves for log %d, want %d", test.desc, got, want)
+				}
+				if got, want := len(ev.Leaves), len(leaves); got != want {
+					t.Fatalf("%v: sent %d leaves, want %d", test.desc, got, want)
+				}
+				for i, raw := range ev.Leaves {
+					var l trillian.LogLeaf
+					if err := jsonpb.Unmarshal(bytes.NewReader(raw), &l); err != nil {
+						t.Fatalf("%v: failed to unmarshal leaf: %v", test.desc, err)
+					}
+					if !proto.Equal(&l, leaves[i]) {
+						t.Errorf("%v: sent leaf %v, want %v", test.desc, l, leaves[i])
+					}
+				}
+			case "notes":
+				gotNote = true
+				if got, want := string(s.data), "note"; got != want {
+					t.Errorf("%v: sent checkpoint %q, want %q", test.desc, got, want)
+				}
+			default:
+				t.Errorf("%v: sent to unexpected topic %q", test.desc, s.topic)
+			}
+		}
+		if gotRoot != test.wantRoot {
+			t.Errorf("%v: root sent=%v, want %v", test.desc, gotRoot, test.wantRoot)
+		}
+		if gotLeaf != test.wantLeaf {
+			t.Errorf("%v: leaves sent=%v, want %v", test.desc, gotLeaf, test.wantLeaf)
+		}
+		if gotNote != test.wantNote {
+			t.Errorf("%v: checkpoint sent=%v, want %v", test.desc, gotNote, test.wantNote)
+		}
+	}
+}
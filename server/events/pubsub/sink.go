@@ -0,0 +1,74 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pubsub provides an events.Sink which sends events to Google Cloud Pub/Sub.
+package pubsub
+
+import (
+	"sync"
+
+	"cloud.google.com/go/pubsub"
+	"golang.org/x/net/context"
+)
+
+// logIDAttribute is the message attribute holding the ID of the log an event is about.
+const logIDAttribute = "log_id"
+
+// Sink publishes events to Pub/Sub topics in a single project.
+type Sink struct {
+	client *pubsub.Client
+
+	mu     sync.Mutex
+	topics map[string]*pubsub.Topic
+}
+
+// NewSink returns a Sink publishing to topics in project.
+func NewSink(ctx context.Context, project string) (*Sink, error) {
+	client, err := pubsub.NewClient(ctx, project)
+	if err != nil {
+		return nil, err
+	}
+	return &Sink{client: client, topics: make(map[string]*pubsub.Topic)}, nil
+}
+
+// Send implements events.Sink. It waits for the message to be acknowledged by Pub/Sub.
+func (s *Sink) Send(ctx context.Context, topic, key string, data []byte) error {
+	res := s.topic(topic).Publish(ctx, &pubsub.Message{
+		Data:       data,
+		Attributes: map[string]string{logIDAttribute: key},
+	})
+	_, err := res.Get(ctx)
+	return err
+}
+
+func (s *Sink) topic(id string) *pubsub.Topic {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.topics[id]
+	if !ok {
+		t = s.client.Topic(id)
+		s.topics[id] = t
+	}
+	return t
+}
+
+// Close implements events.Sink.
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.topics {
+		t.Stop()
+	}
+	return s.client.Close()
+}
@@ -0,0 +1,128 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// FairnessPolicy decides the order logs are sequenced in during a pass, and how many
+// leaves each of them may sequence, so a log with a large backlog doesn't hold up the
+// others.
+type FairnessPolicy int
+
+const (
+	// FairnessNone sequences logs in the order they're listed, each with its whole
+	// batch size.
+	FairnessNone FairnessPolicy = iota
+	// FairnessRoundRobin caps the leaves each log sequences per pass at the per-tree
+	// budget, and rotates the order logs are sequenced in from one pass to the next.
+	FairnessRoundRobin
+	// FairnessWeighted is FairnessRoundRobin with each log's budget multiplied by its
+	// sequencing_priority, and logs with a higher priority sequenced first.
+	FairnessWeighted
+)
+
+// ParseFairnessPolicy returns the FairnessPolicy named name, one of none, round_robin
+// or weighted.
+func ParseFairnessPolicy(name string) (FairnessPolicy, error) {
+	switch name {
+	case "", "none":
+		return FairnessNone, nil
+	case "round_robin":
+		return FairnessRoundRobin, nil
+	case "weighted":
+		return FairnessWeighted, nil
+	}
+	return FairnessNone, fmt.Errorf("unknown fairness policy %q, want none, round_robin or weighted", name)
+}
+
+// fairScheduler shares sequencing passes between logs according to a FairnessPolicy.
+// Logs are ordered by the priorities their trees had when they were last sequenced,
+// as the trees are only read once a log's turn comes.
+type fairScheduler struct {
+	policy FairnessPolicy
+	budget int
+
+	mu         sync.Mutex
+	next       int
+	priorities map[int64]int32
+}
+
+func newFairScheduler(policy FairnessPolicy, budget int) *fairScheduler {
+	return &fairScheduler{
+		policy:     policy,
+		budget:     budget,
+		priorities: make(map[int64]int32),
+	}
+}
+
+// order returns logIDs in the order they should be sequenced in this pass.
+func (f *fairScheduler) order(logIDs []int64) []int64 {
+	if f.policy == FairnessNone || len(logIDs) == 0 {
+		return logIDs
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ordered := make([]int64, len(logIDs))
+	copy(ordered, logIDs)
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i] < ordered[j] })
+	// The log a pass starts with goes last in the next one.
+	start := f.next % len(ordered)
+	f.next = start + 1
+	ordered = append(ordered[start:], ordered[:start]...)
+
+	if f.policy == FairnessWeighted {
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return f.weightLocked(ordered[i]) > f.weightLocked(ordered[j])
+		})
+	}
+	return ordered
+}
+
+// limit records the priority of logID's tree, and returns how many of batchSize
+// leaves it may sequence in this pass.
+func (f *fairScheduler) limit(logID int64, batchSize int, priority int32) int {
+	if f.policy == FairnessNone {
+		return batchSize
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.priorities[logID] = priority
+	if f.budget <= 0 {
+		return batchSize
+	}
+
+	budget := f.budget
+	if f.policy == FairnessWeighted {
+		budget *= f.weightLocked(logID)
+	}
+	if budget < batchSize {
+		return budget
+	}
+	return batchSize
+}
+
+// weightLocked returns the weight of logID, which is its tree's priority, or 1 if it's
+// unset or unknown.
+func (f *fairScheduler) weightLocked(logID int64) int {
+	if p := f.priorities[logID]; p > 0 {
+		return int(p)
+	}
+	return 1
+}
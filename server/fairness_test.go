@@ -0,0 +1,84 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFairSchedulerOrder(t *testing.T) {
+	logIDs := []int64{3, 1, 2}
+
+	none := newFairScheduler(FairnessNone, 10)
+	if got := none.order(logIDs); !reflect.DeepEqual(got, logIDs) {
+		t.Errorf("FairnessNone order() = %v, want %v", got, logIDs)
+	}
+
+	rr := newFairScheduler(FairnessRoundRobin, 10)
+	for i, want := range [][]int64{{1, 2, 3}, {2, 3, 1}, {3, 1, 2}, {1, 2, 3}} {
+		if got := rr.order(logIDs); !reflect.DeepEqual(got, want) {
+			t.Errorf("FairnessRoundRobin pass %d order() = %v, want %v", i, got, want)
+		}
+	}
+	if want := []int64{3, 1, 2}; !reflect.DeepEqual(logIDs, want) {
+		t.Errorf("order() modified its argument to %v, want %v", logIDs, want)
+	}
+
+	// Priorities are learnt as logs are sequenced, logs of equal priority rotate.
+	weighted := newFairScheduler(FairnessWeighted, 10)
+	weighted.limit(3, 100, 5)
+	weighted.limit(1, 100, 0)
+	for i, want := range [][]int64{{3, 1, 2}, {3, 2, 1}, {3, 1, 2}} {
+		if got := weighted.order(logIDs); !reflect.DeepEqual(got, want) {
+			t.Errorf("FairnessWeighted pass %d order() = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestFairSchedulerLimit(t *testing.T) {
+	for _, test := range []struct {
+		desc      string
+		policy    FairnessPolicy
+		budget    int
+		batchSize int
+		priority  int32
+		want      int
+	}{
+		{desc: "none", policy: FairnessNone, budget: 10, batchSize: 100, want: 100},
+		{desc: "roundRobin", policy: FairnessRoundRobin, budget: 10, batchSize: 100, priority: 5, want: 10},
+		{desc: "roundRobinSmallBatch", policy: FairnessRoundRobin, budget: 10, batchSize: 4, want: 4},
+		{desc: "noBudget", policy: FairnessRoundRobin, batchSize: 100, want: 100},
+		{desc: "weighted", policy: FairnessWeighted, budget: 10, batchSize: 100, priority: 5, want: 50},
+		{desc: "weightedUnset", policy: FairnessWeighted, budget: 10, batchSize: 100, want: 10},
+		{desc: "weightedCappedAtBatch", policy: FairnessWeighted, budget: 10, batchSize: 30, priority: 5, want: 30},
+	} {
+		f := newFairScheduler(test.policy, test.budget)
+		if got := f.limit(1, test.batchSize, test.priority); got != test.want {
+			t.Errorf("%v: limit() = %v, want %v", test.desc, got, test.want)
+		}
+	}
+}
+
+func TestParseFairnessPolicy(t *testing.T) {
+	for name, want := range map[string]FairnessPolicy{"": FairnessNone, "none": FairnessNone, "round_robin": FairnessRoundRobin, "weighted": FairnessWeighted} {
+		if got, err := ParseFairnessPolicy(name); err != nil || got != want {
+			t.Errorf("ParseFairnessPolicy(%q) = %v, %v, want %v, nil", name, got, err, want)
+		}
+	}
+	if _, err := ParseFairnessPolicy("fifo"); err == nil {
+		t.Error("ParseFairnessPolicy(fifo) succeeded, want error")
+	}
+}
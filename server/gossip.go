@@ -0,0 +1,195 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	gocrypto "crypto"
+	"crypto/x509"
+	"expvar"
+	"strconv"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+var (
+	observedRoots = expvar.NewMap("log-observed-roots")
+	// splitViewRoots counts, per log, the observed roots which were signed by the log but
+	// conflict with its history. Anything other than zero needs investigating.
+	splitViewRoots = expvar.NewMap("log-split-view-roots")
+)
+
+// AddObservedRoot verifies the signature on a root a client has seen, checks that it's
+// consistent with the log's latest root and stores it. Inconsistent roots are evidence
+// of a split view: they're logged, counted in the log-split-view-roots metric and kept.
+// Roots of verification-only logs are imported, see importRoot.
+func (t *TrillianLogRPCServer) AddObservedRoot(ctx context.Context, req *trillian.AddObservedRootRequest) (*trillian.AddObservedRootResponse, error) {
+	ctx = util.NewLogContext(ctx, req.LogId)
+	if err := validateAddObservedRootRequest(req); err != nil {
+		return nil, err
+	}
+	root := *req.SignedLogRoot
+
+	tree, err := getTree(ctx, t.registry, req.LogId)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := t.logPublicKey(ctx, tree)
+	if err != nil {
+		return nil, err
+	}
+	if err := crypto.Verify(pub, crypto.HashLogRoot(root), root.Signature); err != nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "root isn't signed by the log: %v", err)
+	}
+
+	tx, err := t.prepareStorageTx(ctx, req.LogId)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+
+	latest, err := tx.LatestSignedLogRoot()
+	if err != nil {
+		return nil, err
+	}
+	var consistent bool
+	if tree.PrivateKey == nil {
+		if consistent, err = importRoot(tx, root, latest, req.ConsistencyProof); err != nil {
+			return nil, err
+		}
+	} else {
+		if root.TreeSize > latest.TreeSize {
+			// Most likely the root was signed after our snapshot, the client can retry.
+			return nil, grpc.Errorf(codes.FailedPrecondition, "root at size %d is ahead of the latest root at size %d", root.TreeSize, latest.TreeSize)
+		}
+		if consistent, err = isConsistent(tx, root, latest); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.StoreObservedRoot(root, consistent, t.timeSource.Now()); err != nil {
+		return nil, err
+	}
+
+	if err := t.commitAndLog(ctx, tx, "AddObservedRoot"); err != nil {
+		return nil, err
+	}
+
+	logID := strconv.FormatInt(req.LogId, 10)
+	observedRoots.Add(logID, 1)
+	if !consistent {
+		splitViewRoots.Add(logID, 1)
+		glog.Errorf("%s: observed root (size %d, hash %x, timestamp %d) is inconsistent with root (size %d, hash %x): split view", util.LogIDPrefix(ctx), root.TreeSize, root.RootHash, root.TimestampNanos, latest.TreeSize, latest.RootHash)
+	}
+	return &trillian.AddObservedRootResponse{Consistent: consistent}, nil
+}
+
+// logPublicKey returns the public half of the key tree signs its roots with, its
+// public_key if it has one, which verification-only logs always do.
+func (t *TrillianLogRPCServer) logPublicKey(ctx context.Context, tree *trillian.Tree) (gocrypto.PublicKey, error) {
+	if tree.PublicKey != nil {
+		return x509.ParsePKIXPublicKey(tree.PublicKey.GetDer())
+	}
+	signer, err := newSigner(ctx, t.registry, tree)
+	if err != nil {
+		return nil, err
+	}
+	return signer.Public(), nil
+}
+
+// importRoot checks root, signed for a verification-only log, against latest, the latest
+// root imported to the log. The log has no history of its own to prove the two roots
+// consistent with, so proof, from the smaller of them to the larger, must. A root of the
+// same size as latest with another hash is inconsistent, as both are signed by the log.
+// Consistent roots newer than latest are stored as the log's latest root, so they're
+// served like the roots of any other log.
+func importRoot(tx storage.LogTreeTX, root, latest trillian.SignedLogRoot, proof [][]byte) (bool, error) {
+	newer := true
+	// A log without a root yet takes any root.
+	if len(latest.RootHash) > 0 {
+		// TODO(Martin2112): Hasher must be selected based on log config.
+		hasher, err := merkle.Factory(merkle.RFC6962SHA256Type)
+		if err != nil {
+			return false, err
+		}
+		if root.TreeSize == latest.TreeSize {
+			if !bytes.Equal(root.RootHash, latest.RootHash) {
+				return false, nil
+			}
+		} else {
+			first, second := latest, root
+			if root.TreeSize < latest.TreeSize {
+				first, second = root, latest
+			}
+			if err := merkle.NewLogVerifier(hasher).VerifyConsistencyProof(first.TreeSize, second.TreeSize, first.RootHash, second.RootHash, proof); err != nil {
+				return false, grpc.Errorf(codes.InvalidArgument, "consistency_proof doesn't prove the root at size %d consistent with the latest root at size %d: %v", root.TreeSize, latest.TreeSize, err)
+			}
+		}
+		newer = root.TreeSize > latest.TreeSize || (root.TreeSize == latest.TreeSize && root.TimestampNanos > latest.TimestampNanos)
+	}
+	if newer {
+		root.TreeRevision = tx.WriteRevision()
+		if err := tx.StoreSignedLogRoot(root); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// isConsistent reports whether root, which must be no larger than latest, is part of the
+// history leading up to latest.
+func isConsistent(tx storage.ReadOnlyLogTreeTX, root, latest trillian.SignedLogRoot) (bool, error) {
+	// TODO(Martin2112): Hasher must be selected based on log config.
+	hasher, err := merkle.Factory(merkle.RFC6962SHA256Type)
+	if err != nil {
+		return false, err
+	}
+	if root.TreeSize == 0 {
+		// The verifier accepts any root for an empty tree, but only one is right.
+		return bytes.Equal(root.RootHash, hasher.HashEmpty()), nil
+	}
+
+	var proof [][]byte
+	if root.TreeSize < latest.TreeSize {
+		nodeFetches, err := merkle.CalcConsistencyProofNodeAddresses(root.TreeSize, latest.TreeSize, latest.TreeSize, proofMaxBitLen)
+		if err != nil {
+			return false, err
+		}
+		p, err := fetchNodesAndBuildProof(tx, tx.ReadRevision(), 0, nodeFetches)
+		if err != nil {
+			return false, err
+		}
+		for _, n := range p.ProofNode {
+			proof = append(proof, n.NodeHash)
+		}
+	}
+
+	err = merkle.NewLogVerifier(hasher).VerifyConsistencyProof(root.TreeSize, latest.TreeSize, root.RootHash, latest.RootHash, proof)
+	switch err.(type) {
+	case nil:
+		return true, nil
+	case merkle.RootMismatchError:
+		return false, nil
+	default:
+		return false, err
+	}
+}
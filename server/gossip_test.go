@@ -0,0 +1,229 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	gocrypto "crypto"
+	"crypto/x509"
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/crypto/keys"
+	"github.com/google/trillian/crypto/sigpb"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/testonly"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func TestAddObservedRoot(t *testing.T) {
+	key, err := keys.NewFromPrivatePEM(testonly.DemoPrivateKey, testonly.DemoPrivateKeyPass)
+	if err != nil {
+		t.Fatalf("Failed to open test key: %v", err)
+	}
+	otherKey, err := keys.GenerateKey(sigpb.DigitallySigned_ECDSA)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	sign := func(signer gocrypto.Signer, size int64, hash []byte) *trillian.SignedLogRoot {
+		root := &trillian.SignedLogRoot{TimestampNanos: 1000 + size, TreeSize: size, RootHash: hash}
+		sig, err := crypto.NewSigner(signer).Sign(crypto.HashLogRoot(*root))
+		if err != nil {
+			t.Fatalf("Failed to sign root: %v", err)
+		}
+		root.Signature = sig
+		return root
+	}
+
+	// The latest root is at size 7, made from the root at size 4 and the node covering
+	// leaves 4 to 6, so the size 4 root can be checked with a one node consistency proof.
+	root4Hash, node := []byte("root4"), []byte("nodehash")
+	latest := trillian.SignedLogRoot{TreeSize: 7, TreeRevision: revision1, RootHash: th.HashChildren(root4Hash, node)}
+
+	tests := []struct {
+		desc           string
+		root           *trillian.SignedLogRoot
+		wantProof      bool
+		wantStore      bool
+		wantCode       codes.Code
+		wantConsistent bool
+	}{
+		{desc: "latest", root: sign(key, 7, latest.RootHash), wantStore: true, wantConsistent: true},
+		{desc: "older", root: sign(key, 4, root4Hash), wantProof: true, wantStore: true, wantConsistent: true},
+		{desc: "empty", root: sign(key, 0, th.HashEmpty()), wantStore: true, wantConsistent: true},
+		{desc: "forkedLatest", root: sign(key, 7, []byte("other")), wantStore: true},
+		{desc: "forkedOlder", root: sign(key, 4, []byte("other")), wantProof: true, wantStore: true},
+		{desc: "forkedEmpty", root: sign(key, 0, []byte("other")), wantStore: true},
+		{desc: "ahead", root: sign(key, 8, []byte("root8")), wantCode: codes.FailedPrecondition},
+		{desc: "wrongKey", root: sign(otherKey, 7, latest.RootHash), wantCode: codes.InvalidArgument},
+		{desc: "unsigned", root: &trillian.SignedLogRoot{TreeSize: 7, RootHash: latest.RootHash}, wantCode: codes.InvalidArgument},
+	}
+
+	for _, test := range tests {
+		func() {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockAdmin := storage.NewMockAdminStorage(ctrl)
+			mockAdminTx := storage.NewMockReadOnlyAdminTX(ctrl)
+			mockStorage := storage.NewMockLogStorage(ctrl)
+			tree := &trillian.Tree{TreeId: logID1}
+			signers := map[int64]gocrypto.Signer{logID1: key}
+			if test.root.Signature != nil {
+				mockAdmin.EXPECT().Snapshot(gomock.Any()).Return(mockAdminTx, nil)
+				mockAdminTx.EXPECT().GetTree(gomock.Any(), logID1).Return(tree, nil)
+				mockAdminTx.EXPECT().Commit().Return(nil)
+				mockAdminTx.EXPECT().Close().Return(nil)
+			}
+			if test.wantCode != codes.InvalidArgument {
+				mockTx := storage.NewMockLogTreeTX(ctrl)
+				mockStorage.EXPECT().BeginForTree(gomock.Any(), logID1).Return(mockTx, nil)
+				mockTx.EXPECT().LatestSignedLogRoot().Return(latest, nil)
+				if test.wantProof {
+					mockTx.EXPECT().ReadRevision().Return(revision1)
+					mockTx.EXPECT().GetMerkleNodes(revision1, nodeIdsConsistencySize4ToSize7).Return([]storage.Node{{NodeID: nodeIdsConsistencySize4ToSize7[0], Hash: node}}, nil)
+				}
+				if test.wantStore {
+					mockTx.EXPECT().StoreObservedRoot(*test.root, test.wantConsistent, fakeTime).Return(nil)
+					mockTx.EXPECT().Commit().Return(nil)
+				}
+				mockTx.EXPECT().Close().Return(nil)
+			}
+
+			registry := extension.Registry{
+				AdminStorage:  mockAdmin,
+				LogStorage:    mockStorage,
+				SignerFactory: &signerFactory{signers: signers},
+			}
+			server := NewTrillianLogRPCServer(registry, fakeTimeSource)
+
+			resp, err := server.AddObservedRoot(context.Background(), &trillian.AddObservedRootRequest{LogId: logID1, SignedLogRoot: test.root})
+			if got := grpc.Code(err); got != test.wantCode {
+				t.Fatalf("%v: AddObservedRoot()=(_, %v), want %v", test.desc, err, test.wantCode)
+			}
+			if err != nil {
+				return
+			}
+			if got := resp.Consistent; got != test.wantConsistent {
+				t.Errorf("%v: AddObservedRoot().Consistent=%v, want %v", test.desc, got, test.wantConsistent)
+			}
+		}()
+	}
+}
+
+func TestAddObservedRootVerificationOnly(t *testing.T) {
+	key, err := keys.NewFromPrivatePEM(testonly.DemoPrivateKey, testonly.DemoPrivateKeyPass)
+	if err != nil {
+		t.Fatalf("Failed to open test key: %v", err)
+	}
+	publicKey, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatalf("Failed to marshal public key: %v", err)
+	}
+	mt := merkle.NewInMemoryMerkleTree(th)
+	for i := 0; i < 7; i++ {
+		mt.AddLeaf([]byte(fmt.Sprintf("leaf %d", i)))
+	}
+	sign := func(size int64, hash []byte) *trillian.SignedLogRoot {
+		root := &trillian.SignedLogRoot{TimestampNanos: 1000 + size, TreeSize: size, RootHash: hash}
+		sig, err := crypto.NewSigner(key).Sign(crypto.HashLogRoot(*root))
+		if err != nil {
+			t.Fatalf("Failed to sign root: %v", err)
+		}
+		root.Signature = sig
+		return root
+	}
+	proof := func(from, to int64) [][]byte {
+		var hashes [][]byte
+		for _, node := range mt.SnapshotConsistency(from, to) {
+			hashes = append(hashes, node.Value.Hash())
+		}
+		return hashes
+	}
+	latest := *sign(4, mt.RootAtSnapshot(4).Hash())
+
+	tests := []struct {
+		desc           string
+		latest         trillian.SignedLogRoot
+		root           *trillian.SignedLogRoot
+		proof          [][]byte
+		wantCode       codes.Code
+		wantConsistent bool
+		// wantLatest is set if the root should become the log's latest root.
+		wantLatest bool
+	}{
+		{desc: "first", root: sign(4, latest.RootHash), wantConsistent: true, wantLatest: true},
+		{desc: "newer", latest: latest, root: sign(7, mt.RootAtSnapshot(7).Hash()), proof: proof(4, 7), wantConsistent: true, wantLatest: true},
+		{desc: "older", latest: latest, root: sign(2, mt.RootAtSnapshot(2).Hash()), proof: proof(2, 4), wantConsistent: true},
+		{desc: "same", latest: latest, root: sign(4, latest.RootHash), wantConsistent: true},
+		{desc: "forked", latest: latest, root: sign(4, []byte("other"))},
+		{desc: "noProof", latest: latest, root: sign(7, mt.RootAtSnapshot(7).Hash()), wantCode: codes.InvalidArgument},
+		{desc: "badProof", latest: latest, root: sign(7, []byte("other")), proof: proof(4, 7), wantCode: codes.InvalidArgument},
+	}
+
+	for _, test := range tests {
+		func() {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockAdmin := storage.NewMockAdminStorage(ctrl)
+			mockAdminTx := storage.NewMockReadOnlyAdminTX(ctrl)
+			mockAdmin.EXPECT().Snapshot(gomock.Any()).Return(mockAdminTx, nil)
+			mockAdminTx.EXPECT().GetTree(gomock.Any(), logID1).Return(&trillian.Tree{TreeId: logID1, PublicKey: &trillian.PublicKey{Der: publicKey}}, nil)
+			mockAdminTx.EXPECT().Commit().Return(nil)
+			mockAdminTx.EXPECT().Close().Return(nil)
+
+			mockStorage := storage.NewMockLogStorage(ctrl)
+			mockTx := storage.NewMockLogTreeTX(ctrl)
+			mockStorage.EXPECT().BeginForTree(gomock.Any(), logID1).Return(mockTx, nil)
+			mockTx.EXPECT().LatestSignedLogRoot().Return(test.latest, nil)
+			if test.wantLatest {
+				stored := *test.root
+				stored.TreeRevision = revision1
+				mockTx.EXPECT().WriteRevision().Return(revision1)
+				mockTx.EXPECT().StoreSignedLogRoot(stored).Return(nil)
+			}
+			if test.wantCode == codes.OK {
+				mockTx.EXPECT().StoreObservedRoot(*test.root, test.wantConsistent, fakeTime).Return(nil)
+				mockTx.EXPECT().Commit().Return(nil)
+			}
+			mockTx.EXPECT().Close().Return(nil)
+
+			registry := extension.Registry{
+				AdminStorage:  mockAdmin,
+				LogStorage:    mockStorage,
+				SignerFactory: &signerFactory{},
+			}
+			server := NewTrillianLogRPCServer(registry, fakeTimeSource)
+
+			resp, err := server.AddObservedRoot(context.Background(), &trillian.AddObservedRootRequest{LogId: logID1, SignedLogRoot: test.root, ConsistencyProof: test.proof})
+			if got := grpc.Code(err); got != test.wantCode {
+				t.Fatalf("%v: AddObservedRoot()=(_, %v), want %v", test.desc, err, test.wantCode)
+			}
+			if err != nil {
+				return
+			}
+			if got := resp.Consistent; got != test.wantConsistent {
+				t.Errorf("%v: AddObservedRoot().Consistent=%v, want %v", test.desc, got, test.wantConsistent)
+			}
+		}()
+	}
+}
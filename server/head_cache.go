@@ -0,0 +1,265 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+)
+
+// headCacheStats counts the Merkle node reads answered by head caches, hits, and those
+// passed on to storage, misses, across all logs.
+var headCacheStats = expvar.NewMap("log-head-cache")
+
+// nodeCoords identifies a Merkle node of a log by its depth, leaves being at depth 0,
+// and its index at that depth.
+type nodeCoords struct {
+	depth int
+	index int64
+}
+
+// logHead holds the nodes of a log over its leaves [start, size) which are the roots of
+// complete subtrees, keyed by their coordinates.
+type logHead struct {
+	start, size int64
+	nodes       map[nodeCoords][]byte
+}
+
+// HeadCache holds the Merkle nodes over the most recently integrated leaves of logs, their
+// heads, so the proofs clients ask for at the latest tree size can mostly be built
+// without reading nodes from storage. The nodes are computed from the leaves' Merkle leaf
+// hashes, passed to the cache by the sequencer as a LeafPublisher, or read by the log
+// server when it finds the cache behind the tree it's serving.
+// Only the roots of complete subtrees are kept, as their hashes don't change as the tree
+// grows, so the cache can answer readers of any revision. The nodes on the right edge of
+// the tree are computed from them, for readers whose tree has the size the cache has
+// seen. Nodes it serves don't have a NodeRevision.
+type HeadCache struct {
+	// maxLeaves is the number of leaves of each log the cache holds the nodes over.
+	maxLeaves int64
+	hasher    merkle.TreeHasher
+
+	mu    sync.Mutex
+	heads map[int64]*logHead
+}
+
+// NewHeadCache creates a cache holding the nodes over the latest maxLeaves leaves of each
+// log.
+func NewHeadCache(maxLeaves int64) *HeadCache {
+	// TODO(Martin2112): Hasher must be selected based on log config.
+	hasher, err := merkle.Factory(merkle.RFC6962SHA256Type)
+	if err != nil {
+		panic("Unknown hash strategy")
+	}
+	return &HeadCache{
+		maxLeaves: maxLeaves,
+		hasher:    hasher,
+		heads:     make(map[int64]*logHead),
+	}
+}
+
+// PublishLeaves adds the nodes over leaves, which the sequencer has just integrated into
+// logID, implementing LeafPublisher.
+func (c *HeadCache) PublishLeaves(logID int64, leaves []*trillian.LogLeaf) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, leaf := range leaves {
+		c.add(logID, leaf.LeafIndex, leaf.MerkleLeafHash)
+	}
+}
+
+// catchUp reads the leaves of logID the cache hasn't seen from tx, whose tree has
+// treeSize leaves, and adds the nodes over them. At most maxLeaves are read: if the cache
+// is further behind it starts over from the latest ones. Read errors are only logged, as
+// proofs can still be built from storage.
+func (c *HeadCache) catchUp(tx storage.ReadOnlyLogTreeTX, logID, treeSize int64) {
+	c.mu.Lock()
+	from := treeSize - c.maxLeaves
+	if head, ok := c.heads[logID]; ok && head.size > from {
+		from = head.size
+	}
+	c.mu.Unlock()
+	if from < 0 {
+		from = 0
+	}
+	if from >= treeSize {
+		return
+	}
+
+	indices := make([]int64, 0, treeSize-from)
+	for i := from; i < treeSize; i++ {
+		indices = append(indices, i)
+	}
+	leaves, err := tx.GetLeavesByIndex(indices)
+	if err != nil {
+		glog.Warningf("Failed to read the leaves of log %d for the head cache: %v", logID, err)
+		return
+	}
+	c.PublishLeaves(logID, leaves)
+}
+
+// add adds the nodes over the leaf at index, which must be called with mu held. Leaves
+// the cache already has are ignored, and the log's head starts over at a leaf which
+// isn't next to it.
+func (c *HeadCache) add(logID, index int64, leafHash []byte) {
+	head, ok := c.heads[logID]
+	switch {
+	case ok && index < head.size:
+		return
+	case !ok || index > head.size:
+		head = &logHead{start: index, size: index, nodes: make(map[nodeCoords][]byte)}
+		c.heads[logID] = head
+	}
+
+	// Each leaf completes the subtrees it's the last leaf of, whose left halves the cache
+	// has if they're within the head.
+	head.nodes[nodeCoords{depth: 0, index: index}] = leafHash
+	hash := leafHash
+	for coords := (nodeCoords{depth: 0, index: index}); coords.index&1 == 1; {
+		left, ok := head.nodes[nodeCoords{depth: coords.depth, index: coords.index - 1}]
+		if !ok {
+			break
+		}
+		hash = c.hasher.HashChildren(left, hash)
+		coords = nodeCoords{depth: coords.depth + 1, index: coords.index >> 1}
+		head.nodes[coords] = hash
+	}
+	head.size++
+
+	// Nodes over leaves which have dropped out of the head are removed in batches, so
+	// it's only done every maxLeaves leaves.
+	if head.size-head.start >= 2*c.maxLeaves {
+		head.start = head.size - c.maxLeaves
+		for coords := range head.nodes {
+			if coords.index<<uint(coords.depth) < head.start {
+				delete(head.nodes, coords)
+			}
+		}
+	}
+}
+
+// get returns the nodes of logID with the given ids in its tree of size treeSize, leaving
+// those the cache doesn't have zero, and how many of them it had.
+func (c *HeadCache) get(logID, treeSize int64, ids []storage.NodeID) ([]storage.Node, int) {
+	nodes := make([]storage.Node, len(ids))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	head, ok := c.heads[logID]
+	if !ok {
+		return nodes, 0
+	}
+	found := 0
+	for i, id := range ids {
+		if id.PathLenBits != proofMaxBitLen {
+			continue
+		}
+		// The inverse of storage.NewNodeIDForTreeCoords.
+		depth := id.PathLenBits - id.PrefixLenBits
+		var path uint64
+		for _, b := range id.Path {
+			path = path<<8 | uint64(b)
+		}
+		coords := nodeCoords{depth: depth, index: int64(path >> uint(depth))}
+		// The head may be ahead of treeSize, so a cached node is only in the tree if
+		// all its leaves are.
+		hash, ok := head.nodes[coords]
+		if ok && (coords.index+1)<<uint(coords.depth) > treeSize {
+			ok = false
+		}
+		if !ok && treeSize <= head.size {
+			hash, ok = c.edgeHash(head, treeSize, coords)
+		}
+		if ok {
+			nodes[i] = storage.Node{NodeID: id, Hash: hash}
+			found++
+		}
+	}
+	return nodes, found
+}
+
+// edgeHash computes the hash of the node at coords over the last, incomplete, subtree of
+// the tree of size treeSize from the complete subtrees it's made of, or returns false if
+// it isn't one or the cache of head doesn't have them.
+func (c *HeadCache) edgeHash(head *logHead, treeSize int64, coords nodeCoords) ([]byte, bool) {
+	from := coords.index << uint(coords.depth)
+	if from < head.start || treeSize-from <= 0 || treeSize-from >= 1<<uint(coords.depth) {
+		return nil, false
+	}
+
+	// The leaves of the node are split into complete subtrees, largest first.
+	var subtrees [][]byte
+	for depth, next := coords.depth-1, from; depth >= 0; depth-- {
+		if (treeSize-from)&(1<<uint(depth)) == 0 {
+			continue
+		}
+		hash, ok := head.nodes[nodeCoords{depth: depth, index: next >> uint(depth)}]
+		if !ok {
+			return nil, false
+		}
+		subtrees = append(subtrees, hash)
+		next += 1 << uint(depth)
+	}
+	hash := subtrees[len(subtrees)-1]
+	for i := len(subtrees) - 2; i >= 0; i-- {
+		hash = c.hasher.HashChildren(subtrees[i], hash)
+	}
+	return hash, true
+}
+
+// headCacheTX reads the Merkle nodes of its log, whose tree in the transaction it wraps
+// has treeSize leaves, from a HeadCache, and those the cache doesn't have, and everything
+// else, from the transaction.
+type headCacheTX struct {
+	storage.ReadOnlyLogTreeTX
+	cache    *HeadCache
+	logID    int64
+	treeSize int64
+}
+
+// GetMerkleNodes implements storage.NodeReader.
+func (tx headCacheTX) GetMerkleNodes(treeRevision int64, ids []storage.NodeID) ([]storage.Node, error) {
+	nodes, found := tx.cache.get(tx.logID, tx.treeSize, ids)
+	headCacheStats.Add("hits", int64(found))
+	headCacheStats.Add("misses", int64(len(ids)-found))
+	if found == len(ids) {
+		return nodes, nil
+	}
+
+	var missing []storage.NodeID
+	for i, node := range nodes {
+		if node.Hash == nil {
+			missing = append(missing, ids[i])
+		}
+	}
+	read, err := tx.ReadOnlyLogTreeTX.GetMerkleNodes(treeRevision, missing)
+	if err != nil {
+		return nil, err
+	}
+	if len(read) != len(missing) {
+		return nil, fmt.Errorf("expected %d nodes from storage but got %d", len(missing), len(read))
+	}
+	for i := range nodes {
+		if nodes[i].Hash == nil {
+			nodes[i], read = read[0], read[1:]
+		}
+	}
+	return nodes, nil
+}
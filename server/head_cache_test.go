@@ -0,0 +1,230 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/testonly"
+)
+
+const headCacheLogID = int64(6)
+
+// headCacheLeaves returns n leaves with their indices and Merkle leaf hashes set, and
+// the in-memory tree of them.
+func headCacheLeaves(n int64) ([]*trillian.LogLeaf, *merkle.InMemoryMerkleTree) {
+	mt := merkle.NewInMemoryMerkleTree(testonly.Hasher)
+	leaves := make([]*trillian.LogLeaf, 0, n)
+	for i := int64(0); i < n; i++ {
+		data := []byte(fmt.Sprintf("leaf %d", i))
+		mt.AddLeaf(data)
+		leaves = append(leaves, &trillian.LogLeaf{LeafIndex: i, MerkleLeafHash: testonly.Hasher.HashLeaf(data)})
+	}
+	return leaves, mt
+}
+
+func proofHashes(proof trillian.Proof) [][]byte {
+	hashes := make([][]byte, 0, len(proof.ProofNode))
+	for _, node := range proof.ProofNode {
+		hashes = append(hashes, node.NodeHash)
+	}
+	return hashes
+}
+
+func TestHeadCacheProofs(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// All the leaves are in the head, so no nodes should be read from storage.
+	mockTx := storage.NewMockReadOnlyLogTreeTX(mockCtrl)
+	mockTx.EXPECT().ReadRevision().AnyTimes().Return(int64(1))
+
+	leaves, mt := headCacheLeaves(20)
+	verifier := merkle.NewLogVerifier(testonly.Hasher)
+	cache := NewHeadCache(64)
+	for size := int64(1); size <= int64(len(leaves)); size++ {
+		cache.PublishLeaves(headCacheLogID, leaves[size-1:size])
+		tx := headCacheTX{ReadOnlyLogTreeTX: mockTx, cache: cache, logID: headCacheLogID, treeSize: size}
+		root := mt.RootAtSnapshot(size).Hash()
+
+		for index := int64(0); index < size; index++ {
+			proof, err := getInclusionProofForLeafIndex(tx, size, index, size)
+			if err != nil {
+				t.Fatalf("getInclusionProofForLeafIndex(%d, %d)=%v, want nil", size, index, err)
+			}
+			if err := verifier.VerifyInclusionProof(index, size, proofHashes(proof), root, leaves[index].MerkleLeafHash); err != nil {
+				t.Errorf("VerifyInclusionProof(%d, %d)=%v, want nil", index, size, err)
+			}
+		}
+
+		for first := int64(1); first < size; first++ {
+			fetches, err := merkle.CalcConsistencyProofNodeAddresses(first, size, size, proofMaxBitLen)
+			if err != nil {
+				t.Fatalf("CalcConsistencyProofNodeAddresses(%d, %d)=%v, want nil", first, size, err)
+			}
+			proof, err := fetchNodesAndBuildProof(tx, 1, 0, fetches)
+			if err != nil {
+				t.Fatalf("fetchNodesAndBuildProof(%d, %d)=%v, want nil", first, size, err)
+			}
+			if err := verifier.VerifyConsistencyProof(first, size, mt.RootAtSnapshot(first).Hash(), root, proofHashes(proof)); err != nil {
+				t.Errorf("VerifyConsistencyProof(%d, %d)=%v, want nil", first, size, err)
+			}
+		}
+	}
+}
+
+func TestHeadCacheReadsMissingNodes(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	leaves, mt := headCacheLeaves(10)
+	// A cache holding every node stands in for storage.
+	all := NewHeadCache(64)
+	all.PublishLeaves(headCacheLogID, leaves)
+
+	// Once the cache of 4 leaves has seen 8 it drops the nodes over the first 4, so the
+	// proof of leaf 0 needs its sibling and the node over leaves 2 and 3 from storage.
+	cache := NewHeadCache(4)
+	cache.PublishLeaves(headCacheLogID, leaves)
+	var missing []storage.NodeID
+	for _, coords := range []nodeCoords{{depth: 0, index: 1}, {depth: 1, index: 1}} {
+		id, err := storage.NewNodeIDForTreeCoords(int64(coords.depth), coords.index, proofMaxBitLen)
+		if err != nil {
+			t.Fatalf("NewNodeIDForTreeCoords(%v)=%v, want nil", coords, err)
+		}
+		missing = append(missing, id)
+	}
+	stored, _ := all.get(headCacheLogID, 10, missing)
+
+	mockTx := storage.NewMockReadOnlyLogTreeTX(mockCtrl)
+	mockTx.EXPECT().ReadRevision().AnyTimes().Return(int64(1))
+	mockTx.EXPECT().GetMerkleNodes(int64(1), missing).Return(stored, nil)
+
+	tx := headCacheTX{ReadOnlyLogTreeTX: mockTx, cache: cache, logID: headCacheLogID, treeSize: 10}
+	proof, err := getInclusionProofForLeafIndex(tx, 10, 0, 10)
+	if err != nil {
+		t.Fatalf("getInclusionProofForLeafIndex()=%v, want nil", err)
+	}
+	verifier := merkle.NewLogVerifier(testonly.Hasher)
+	if err := verifier.VerifyInclusionProof(0, 10, proofHashes(proof), mt.CurrentRoot().Hash(), leaves[0].MerkleLeafHash); err != nil {
+		t.Errorf("VerifyInclusionProof()=%v, want nil", err)
+	}
+}
+
+func TestHeadCacheCatchUp(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	leaves, _ := headCacheLeaves(14)
+	leafNode := func(cache *HeadCache, index int64) bool {
+		id, err := storage.NewNodeIDForTreeCoords(0, index, proofMaxBitLen)
+		if err != nil {
+			t.Fatalf("NewNodeIDForTreeCoords(0, %d)=%v, want nil", index, err)
+		}
+		_, found := cache.get(headCacheLogID, 14, []storage.NodeID{id})
+		return found == 1
+	}
+
+	// Only the latest 4 leaves are read, and only once.
+	mockTx := storage.NewMockReadOnlyLogTreeTX(mockCtrl)
+	mockTx.EXPECT().GetLeavesByIndex([]int64{6, 7, 8, 9}).Return(leaves[6:10], nil)
+	cache := NewHeadCache(4)
+	cache.catchUp(mockTx, headCacheLogID, 10)
+	cache.catchUp(mockTx, headCacheLogID, 10)
+	if leafNode(cache, 5) || !leafNode(cache, 6) || !leafNode(cache, 9) {
+		t.Errorf("catchUp(10) didn't cache exactly leaves 6 to 9")
+	}
+
+	// Leaves the cache has already seen are read no more.
+	mockTx.EXPECT().GetLeavesByIndex([]int64{10, 11}).Return(leaves[10:12], nil)
+	cache.catchUp(mockTx, headCacheLogID, 12)
+	if !leafNode(cache, 11) {
+		t.Errorf("catchUp(12) didn't cache leaf 11")
+	}
+
+	// A leaf which isn't next to the head starts it over.
+	cache.PublishLeaves(headCacheLogID, leaves[13:])
+	if leafNode(cache, 11) || !leafNode(cache, 13) {
+		t.Errorf("PublishLeaves(13) after a gap didn't start the head over")
+	}
+}
+
+func TestHeadCacheEdgeNodes(t *testing.T) {
+	leaves, _ := headCacheLeaves(7)
+	cache := NewHeadCache(64)
+	cache.PublishLeaves(headCacheLogID, leaves)
+
+	// The node over leaves 4 to 6 is on the right edge of the tree of 7 leaves.
+	id, err := storage.NewNodeIDForTreeCoords(2, 1, proofMaxBitLen)
+	if err != nil {
+		t.Fatalf("NewNodeIDForTreeCoords(2, 1)=%v, want nil", err)
+	}
+	nodes, found := cache.get(headCacheLogID, 7, []storage.NodeID{id})
+	want := testonly.Hasher.HashChildren(testonly.Hasher.HashChildren(leaves[4].MerkleLeafHash, leaves[5].MerkleLeafHash), leaves[6].MerkleLeafHash)
+	if found != 1 || !bytes.Equal(nodes[0].Hash, want) {
+		t.Errorf("get(7)=%x, want %x", nodes[0].Hash, want)
+	}
+	// In the tree of 6 leaves, it's the node over leaves 4 and 5.
+	nodes, found = cache.get(headCacheLogID, 6, []storage.NodeID{id})
+	want = testonly.Hasher.HashChildren(leaves[4].MerkleLeafHash, leaves[5].MerkleLeafHash)
+	if found != 1 || !bytes.Equal(nodes[0].Hash, want) {
+		t.Errorf("get(6)=%x, want %x", nodes[0].Hash, want)
+	}
+}
+
+func TestHeadCacheBehindHead(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// The head has all 20 leaves, but proofs are for earlier trees, which mustn't be
+	// given nodes over leaves they don't have.
+	leaves, mt := headCacheLeaves(20)
+	cache := NewHeadCache(64)
+	cache.PublishLeaves(headCacheLogID, leaves)
+	mockTx := storage.NewMockReadOnlyLogTreeTX(mockCtrl)
+	mockTx.EXPECT().ReadRevision().AnyTimes().Return(int64(1))
+
+	verifier := merkle.NewLogVerifier(testonly.Hasher)
+	for size := int64(1); size < int64(len(leaves)); size++ {
+		tx := headCacheTX{ReadOnlyLogTreeTX: mockTx, cache: cache, logID: headCacheLogID, treeSize: size}
+		root := mt.RootAtSnapshot(size).Hash()
+		for index := int64(0); index < size; index++ {
+			proof, err := getInclusionProofForLeafIndex(tx, size, index, size)
+			if err != nil {
+				t.Fatalf("getInclusionProofForLeafIndex(%d, %d)=%v, want nil", size, index, err)
+			}
+			if err := verifier.VerifyInclusionProof(index, size, proofHashes(proof), root, leaves[index].MerkleLeafHash); err != nil {
+				t.Errorf("VerifyInclusionProof(%d, %d)=%v, want nil", index, size, err)
+			}
+		}
+	}
+
+	// The node over leaves 4 to 7 is complete in the head, but not in the tree of 7.
+	id, err := storage.NewNodeIDForTreeCoords(2, 1, proofMaxBitLen)
+	if err != nil {
+		t.Fatalf("NewNodeIDForTreeCoords(2, 1)=%v", err)
+	}
+	nodes, found := cache.get(headCacheLogID, 7, []storage.NodeID{id})
+	want := testonly.Hasher.HashChildren(testonly.Hasher.HashChildren(leaves[4].MerkleLeafHash, leaves[5].MerkleLeafHash), leaves[6].MerkleLeafHash)
+	if found != 1 || !bytes.Equal(nodes[0].Hash, want) {
+		t.Errorf("get(7) with a head of 20=%x, want %x", nodes[0].Hash, want)
+	}
+}
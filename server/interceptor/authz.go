@@ -0,0 +1,147 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interceptor
+
+import (
+	"strings"
+
+	"github.com/google/trillian/server/errors"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// SPIFFEPolicy maps gRPC service names, e.g. "trillian.TrillianAdmin", to the SPIFFE IDs
+// of the clients allowed to call them. An ID ending in "/" allows every ID it's a prefix
+// of, e.g. "spiffe://example.org/ct/" allows all CT front ends. Services which aren't in
+// the policy may be called by any client with a SPIFFE ID.
+type SPIFFEPolicy map[string][]string
+
+// allows reports whether the client with the given ID may call fullMethod.
+func (p SPIFFEPolicy) allows(fullMethod, id string) bool {
+	service := strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(service, "/"); i >= 0 {
+		service = service[:i]
+	}
+	allowed, ok := p[service]
+	if !ok {
+		return true
+	}
+	return allowsID(allowed, id)
+}
+
+// allowsID reports whether id is one of allowed, or under one of those ending in "/".
+func allowsID(allowed []string, id string) bool {
+	for _, a := range allowed {
+		if a == id || (strings.HasSuffix(a, "/") && strings.HasPrefix(id, a)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorize returns an interceptor which rejects RPCs from clients without a SPIFFE ID
+// with Unauthenticated, and those from clients the policy doesn't allow to call the
+// method with PermissionDenied. The server must use mutual TLS credentials, e.g. from
+// the util/spiffe package.
+func Authorize(policy SPIFFEPolicy) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := authorize(ctx, policy, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// AuthorizeStream is Authorize for streaming methods.
+func AuthorizeStream(policy SPIFFEPolicy) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := authorize(ss.Context(), policy, info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func authorize(ctx context.Context, policy SPIFFEPolicy, fullMethod string) error {
+	id, ok := peerSPIFFEID(ctx)
+	if !ok {
+		return grpc.Errorf(codes.Unauthenticated, "%v requires a client certificate with a SPIFFE ID", fullMethod)
+	}
+	if !policy.allows(fullMethod, id) {
+		return grpc.Errorf(codes.PermissionDenied, "%v is not allowed to call %v", id, fullMethod)
+	}
+	return nil
+}
+
+// writeMethods are the methods which add leaves to the log their request names.
+var writeMethods = map[string]bool{
+	"/trillian.TrillianLog/QueueLeaf":          true,
+	"/trillian.TrillianLog/QueueLeaves":        true,
+	"/trillian.TrillianLog/AddSequencedLeaves": true,
+}
+
+// WritersFunc returns the SPIFFE IDs allowed to add leaves to a log, which may end in "/"
+// as in a SPIFFEPolicy. Any client may if there are none.
+type WritersFunc func(ctx context.Context, logID int64) ([]string, error)
+
+// AuthorizeWriters returns an interceptor which rejects RPCs adding leaves to a log from
+// clients which aren't among its writers with PermissionDenied, or Unauthenticated if
+// they don't have a SPIFFE ID. Other RPCs are let through, so it's meant to run along
+// with Authorize.
+func AuthorizeWriters(writers WritersFunc) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		r, ok := req.(interface {
+			GetLogId() int64
+		})
+		if !ok || !writeMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+		allowed, err := writers(ctx, r.GetLogId())
+		if err != nil {
+			return nil, errors.WrapError(err)
+		}
+		if len(allowed) > 0 {
+			id, ok := peerSPIFFEID(ctx)
+			if !ok {
+				return nil, grpc.Errorf(codes.Unauthenticated, "%v requires a client certificate with a SPIFFE ID", info.FullMethod)
+			}
+			if !allowsID(allowed, id) {
+				return nil, grpc.Errorf(codes.PermissionDenied, "%v is not allowed to write to log %d", id, r.GetLogId())
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// peerSPIFFEID returns the SPIFFE ID of the client of ctx, the URI SAN of its TLS
+// certificate, if it has one. An SVID has exactly one URI SAN.
+func peerSPIFFEID(ctx context.Context) (string, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.PeerCertificates) == 0 {
+		return "", false
+	}
+	uris := info.State.PeerCertificates[0].URIs
+	if len(uris) != 1 || !strings.EqualFold(uris[0].Scheme, "spiffe") {
+		return "", false
+	}
+	return uris[0].String(), true
+}
@@ -0,0 +1,109 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interceptor
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/google/trillian"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// tlsPeerContext returns a context whose peer presented a certificate with the given
+// URI SANs.
+func tlsPeerContext(t *testing.T, uris ...string) context.Context {
+	cert := &x509.Certificate{}
+	for _, u := range uris {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			t.Fatalf("url.Parse(%q)=%v", u, err)
+		}
+		cert.URIs = append(cert.URIs, parsed)
+	}
+	info := credentials.TLSInfo{State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}}
+	return peer.NewContext(context.Background(), &peer.Peer{AuthInfo: info})
+}
+
+func TestAuthorize(t *testing.T) {
+	policy := SPIFFEPolicy{
+		"trillian.TrillianAdmin": {"spiffe://example.org/ops/admin"},
+		"trillian.TrillianLog":   {"spiffe://example.org/ct/", "spiffe://example.org/ops/admin"},
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+
+	for _, test := range []struct {
+		desc   string
+		ctx    context.Context
+		method string
+		want   codes.Code
+	}{
+		{desc: "no peer", ctx: context.Background(), method: "/trillian.TrillianLog/QueueLeaf", want: codes.Unauthenticated},
+		{desc: "no TLS", ctx: peerContext("192.0.2.1"), method: "/trillian.TrillianLog/QueueLeaf", want: codes.Unauthenticated},
+		{desc: "not SPIFFE", ctx: tlsPeerContext(t, "https://example.org/ct/1"), method: "/trillian.TrillianLog/QueueLeaf", want: codes.Unauthenticated},
+		{desc: "two IDs", ctx: tlsPeerContext(t, "spiffe://example.org/ct/1", "spiffe://example.org/ops/admin"), method: "/trillian.TrillianAdmin/CreateTree", want: codes.Unauthenticated},
+		{desc: "exact", ctx: tlsPeerContext(t, "spiffe://example.org/ops/admin"), method: "/trillian.TrillianAdmin/CreateTree", want: codes.OK},
+		{desc: "prefix", ctx: tlsPeerContext(t, "spiffe://example.org/ct/1"), method: "/trillian.TrillianLog/QueueLeaf", want: codes.OK},
+		{desc: "denied", ctx: tlsPeerContext(t, "spiffe://example.org/ct/1"), method: "/trillian.TrillianAdmin/CreateTree", want: codes.PermissionDenied},
+		{desc: "prefix isn't a path prefix", ctx: tlsPeerContext(t, "spiffe://example.org/ctx"), method: "/trillian.TrillianLog/QueueLeaf", want: codes.PermissionDenied},
+		{desc: "no policy", ctx: tlsPeerContext(t, "spiffe://example.org/other"), method: "/grpc.health.v1.Health/Check", want: codes.OK},
+	} {
+		_, err := Authorize(policy)(test.ctx, nil, &grpc.UnaryServerInfo{FullMethod: test.method}, handler)
+		if got := grpc.Code(err); got != test.want {
+			t.Errorf("%v: Authorize()(%v)=%v, want %v", test.desc, test.method, got, test.want)
+		}
+	}
+}
+
+func TestAuthorizeWriters(t *testing.T) {
+	writers := func(ctx context.Context, logID int64) ([]string, error) {
+		switch logID {
+		case 1:
+			return nil, nil
+		case 2:
+			return []string{"spiffe://example.org/ct/", "spiffe://example.org/mirror"}, nil
+		}
+		return nil, errors.New("no such log")
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+
+	for _, test := range []struct {
+		desc   string
+		ctx    context.Context
+		method string
+		req    interface{}
+		want   codes.Code
+	}{
+		{desc: "no writers", ctx: tlsPeerContext(t, "spiffe://example.org/other"), method: "/trillian.TrillianLog/QueueLeaves", req: &trillian.QueueLeavesRequest{LogId: 1}, want: codes.OK},
+		{desc: "exact", ctx: tlsPeerContext(t, "spiffe://example.org/mirror"), method: "/trillian.TrillianLog/AddSequencedLeaves", req: &trillian.AddSequencedLeavesRequest{LogId: 2}, want: codes.OK},
+		{desc: "prefix", ctx: tlsPeerContext(t, "spiffe://example.org/ct/1"), method: "/trillian.TrillianLog/QueueLeaf", req: &trillian.QueueLeafRequest{LogId: 2}, want: codes.OK},
+		{desc: "denied", ctx: tlsPeerContext(t, "spiffe://example.org/other"), method: "/trillian.TrillianLog/QueueLeaves", req: &trillian.QueueLeavesRequest{LogId: 2}, want: codes.PermissionDenied},
+		{desc: "no SPIFFE ID", ctx: peerContext("192.0.2.1"), method: "/trillian.TrillianLog/QueueLeaves", req: &trillian.QueueLeavesRequest{LogId: 2}, want: codes.Unauthenticated},
+		{desc: "read", ctx: tlsPeerContext(t, "spiffe://example.org/other"), method: "/trillian.TrillianLog/GetLeavesByIndex", req: &trillian.GetLeavesByIndexRequest{LogId: 2}, want: codes.OK},
+		{desc: "lookup error", ctx: tlsPeerContext(t, "spiffe://example.org/ct/1"), method: "/trillian.TrillianLog/QueueLeaves", req: &trillian.QueueLeavesRequest{LogId: 3}, want: codes.Unknown},
+	} {
+		_, err := AuthorizeWriters(writers)(test.ctx, test.req, &grpc.UnaryServerInfo{FullMethod: test.method}, handler)
+		if got := grpc.Code(err); got != test.want {
+			t.Errorf("%v: AuthorizeWriters()(%v)=%v, want %v", test.desc, test.method, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interceptor
+
+import (
+	"time"
+
+	te "github.com/google/trillian/errors"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// Deadline returns an interceptor which gives RPCs arriving without a deadline one
+// timeout from now, unless timeout is 0. RPCs which fail because their deadline passed,
+// or storage reported one passing, fail with DeadlineExceeded rather than whatever error
+// the expiry caused.
+func Deadline(timeout time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, cancel := withDefaultDeadline(ctx, timeout)
+		defer cancel()
+		resp, err := handler(ctx, req)
+		return resp, deadlineError(ctx, err)
+	}
+}
+
+// DeadlineStream is Deadline for streaming methods.
+func DeadlineStream(timeout time.Duration) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, cancel := withDefaultDeadline(ss.Context(), timeout)
+		defer cancel()
+		return deadlineError(ctx, handler(srv, contextStream{ss, ctx}))
+	}
+}
+
+func withDefaultDeadline(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func deadlineError(ctx context.Context, err error) error {
+	if err == nil || grpc.Code(err) == codes.DeadlineExceeded {
+		return err
+	}
+	if ctx.Err() == context.DeadlineExceeded || err == context.DeadlineExceeded || te.ErrorCode(err) == te.DeadlineExceeded {
+		return grpc.Errorf(codes.DeadlineExceeded, "%v", err)
+	}
+	return err
+}
@@ -0,0 +1,70 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interceptor
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	te "github.com/google/trillian/errors"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func TestDeadline(t *testing.T) {
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	clientDeadline, _ := expired.Deadline()
+	errFailed := errors.New("tx done")
+
+	for _, test := range []struct {
+		desc      string
+		ctx       context.Context
+		timeout   time.Duration
+		err       error
+		wantSet   bool
+		wantLimit time.Duration
+		wantCode  codes.Code
+	}{
+		{desc: "no default", ctx: context.Background()},
+		{desc: "default", ctx: context.Background(), timeout: time.Minute, wantSet: true, wantLimit: time.Minute},
+		{desc: "client deadline kept", ctx: expired, timeout: time.Minute, wantSet: true},
+		{desc: "other error", ctx: context.Background(), err: errFailed, wantCode: codes.Unknown},
+		{desc: "expired", ctx: expired, err: errFailed, wantSet: true, wantCode: codes.DeadlineExceeded},
+		{desc: "storage timeout", ctx: context.Background(), err: te.New(te.DeadlineExceeded, "timed out"), wantCode: codes.DeadlineExceeded},
+	} {
+		var deadline time.Time
+		var hasDeadline bool
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			deadline, hasDeadline = ctx.Deadline()
+			return nil, test.err
+		}
+		_, err := Deadline(test.timeout)(test.ctx, nil, &grpc.UnaryServerInfo{}, handler)
+		if got := grpc.Code(err); got != test.wantCode {
+			t.Errorf("%v: Deadline()()=%v, want code %v", test.desc, err, test.wantCode)
+		}
+		if hasDeadline != test.wantSet {
+			t.Errorf("%v: handler got deadline? %v, want %v", test.desc, hasDeadline, test.wantSet)
+		}
+		if test.wantLimit > 0 && time.Until(deadline) > test.wantLimit {
+			t.Errorf("%v: handler got deadline in %v, want at most %v", test.desc, time.Until(deadline), test.wantLimit)
+		}
+		if test.ctx == expired && !deadline.Equal(clientDeadline) {
+			t.Errorf("%v: handler got deadline %v, want client's", test.desc, deadline)
+		}
+	}
+}
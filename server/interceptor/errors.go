@@ -0,0 +1,45 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interceptor
+
+import (
+	"github.com/google/trillian/server/errors"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// WrapErrors returns an interceptor which translates the storage errors returned by
+// handlers into gRPC errors with precise codes, using errors.WrapError, rather than
+// leaving clients to see them as Unknown. It should run innermost, so the other
+// interceptors see the translated errors.
+func WrapErrors() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		rsp, err := handler(ctx, req)
+		if err != nil {
+			return nil, errors.WrapError(err)
+		}
+		return rsp, nil
+	}
+}
+
+// WrapErrorsStream is WrapErrors for streaming methods.
+func WrapErrorsStream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := handler(srv, ss); err != nil {
+			return errors.WrapError(err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interceptor
+
+import (
+	"testing"
+
+	"github.com/google/trillian/storage"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func TestWrapErrors(t *testing.T) {
+	for _, test := range []struct {
+		err  error
+		want codes.Code
+	}{
+		{err: nil, want: codes.OK},
+		{err: storage.Error{ErrType: storage.TreeNotFound, Detail: "tree 1 not found"}, want: codes.NotFound},
+		{err: grpc.Errorf(codes.InvalidArgument, "bad"), want: codes.InvalidArgument},
+	} {
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, test.err }
+		_, err := WrapErrors()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/trillian.TrillianLog/QueueLeaf"}, handler)
+		if got := grpc.Code(err); got != test.want {
+			t.Errorf("WrapErrors()(%v)=%v, want %v", test.err, got, test.want)
+		}
+	}
+}
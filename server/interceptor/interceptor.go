@@ -0,0 +1,129 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package interceptor holds gRPC interceptors for the Trillian servers.
+package interceptor
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// Combine returns an interceptor which runs interceptors in order, the first one being
+// outermost. gRPC only accepts a single unary interceptor per server.
+func Combine(interceptors ...grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			handler = wrap(interceptors[i], info, handler)
+		}
+		return handler(ctx, req)
+	}
+}
+
+func wrap(interceptor grpc.UnaryServerInterceptor, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) grpc.UnaryHandler {
+	return func(ctx context.Context, req interface{}) (interface{}, error) {
+		return interceptor(ctx, req, info, handler)
+	}
+}
+
+// CombineStream is Combine for stream interceptors.
+func CombineStream(interceptors ...grpc.StreamServerInterceptor) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			handler = wrapStream(interceptors[i], info, handler)
+		}
+		return handler(srv, ss)
+	}
+}
+
+func wrapStream(interceptor grpc.StreamServerInterceptor, info *grpc.StreamServerInfo, handler grpc.StreamHandler) grpc.StreamHandler {
+	return func(srv interface{}, ss grpc.ServerStream) error {
+		return interceptor(srv, ss, info, handler)
+	}
+}
+
+// Named is an interceptor which can be ordered by name, e.g. from a flag. Either of its
+// interceptors may be nil, if both are it isn't enabled.
+type Named struct {
+	Name   string
+	Unary  grpc.UnaryServerInterceptor
+	Stream grpc.StreamServerInterceptor
+}
+
+// Chain combines the enabled interceptors in the order their names appear in order,
+// the first one being outermost. order may name interceptors which aren't enabled, but
+// every enabled one must be in it, so none is left out by mistake.
+func Chain(order []string, interceptors []Named) (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor, error) {
+	byName := make(map[string]Named)
+	for _, n := range interceptors {
+		byName[n.Name] = n
+	}
+	var unary []grpc.UnaryServerInterceptor
+	var stream []grpc.StreamServerInterceptor
+	seen := make(map[string]bool)
+	for _, name := range order {
+		n, ok := byName[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown interceptor %q", name)
+		}
+		if seen[name] {
+			return nil, nil, fmt.Errorf("interceptor %q is ordered more than once", name)
+		}
+		seen[name] = true
+		if n.Unary != nil {
+			unary = append(unary, n.Unary)
+		}
+		if n.Stream != nil {
+			stream = append(stream, n.Stream)
+		}
+	}
+	for _, n := range interceptors {
+		if !seen[n.Name] && (n.Unary != nil || n.Stream != nil) {
+			return nil, nil, fmt.Errorf("interceptor %q is enabled but not ordered", n.Name)
+		}
+	}
+	return Combine(unary...), CombineStream(stream...), nil
+}
+
+// ReadOnly returns an interceptor which rejects calls to methods that may modify
+// storage with PermissionDenied. Only Get* and List* methods are let through.
+func ReadOnly() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !IsReadOnlyMethod(info.FullMethod) {
+			return nil, grpc.Errorf(codes.PermissionDenied, "%v is not allowed, server is read-only", info.FullMethod)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// ReadOnlyStream is ReadOnly for streaming methods.
+func ReadOnlyStream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !IsReadOnlyMethod(info.FullMethod) {
+			return grpc.Errorf(codes.PermissionDenied, "%v is not allowed, server is read-only", info.FullMethod)
+		}
+		return handler(srv, ss)
+	}
+}
+
+// IsReadOnlyMethod reports whether the gRPC method named fullMethod, e.g.
+// "/trillian.TrillianLog/GetLeavesByIndex", only reads from storage.
+func IsReadOnlyMethod(fullMethod string) bool {
+	method := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+	return strings.HasPrefix(method, "Get") || strings.HasPrefix(method, "List")
+}
@@ -0,0 +1,148 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interceptor
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func TestCombine(t *testing.T) {
+	var calls []string
+	record := func(name string) grpc.UnaryServerInterceptor {
+		return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			calls = append(calls, name)
+			return handler(ctx, req)
+		}
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls = append(calls, "handler")
+		return req, nil
+	}
+
+	resp, err := Combine(record("a"), record("b"))(context.Background(), "req", &grpc.UnaryServerInfo{}, handler)
+	if err != nil || resp != "req" {
+		t.Errorf("Combine()()=(%v, %v), want (req, nil)", resp, err)
+	}
+	if want := []string{"a", "b", "handler"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls=%v, want %v", calls, want)
+	}
+}
+
+func TestCombineStream(t *testing.T) {
+	var calls []string
+	record := func(name string) grpc.StreamServerInterceptor {
+		return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			calls = append(calls, name)
+			return handler(srv, ss)
+		}
+	}
+	handler := func(srv interface{}, ss grpc.ServerStream) error {
+		calls = append(calls, "handler")
+		return nil
+	}
+
+	if err := CombineStream(record("a"), record("b"))(nil, nil, &grpc.StreamServerInfo{}, handler); err != nil {
+		t.Errorf("CombineStream()()=%v, want nil", err)
+	}
+	if want := []string{"a", "b", "handler"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls=%v, want %v", calls, want)
+	}
+}
+
+func TestChain(t *testing.T) {
+	var calls []string
+	unary := func(name string) grpc.UnaryServerInterceptor {
+		return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			calls = append(calls, name)
+			return handler(ctx, req)
+		}
+	}
+	stream := func(name string) grpc.StreamServerInterceptor {
+		return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			calls = append(calls, name)
+			return handler(srv, ss)
+		}
+	}
+	interceptors := []Named{
+		{Name: "a", Unary: unary("a"), Stream: stream("a")},
+		{Name: "b", Unary: unary("b")},
+		{Name: "disabled"},
+	}
+
+	for _, test := range []struct {
+		order      []string
+		wantUnary  []string
+		wantStream []string
+		wantErr    bool
+	}{
+		{order: []string{"a", "b"}, wantUnary: []string{"a", "b"}, wantStream: []string{"a"}},
+		{order: []string{"b", "disabled", "a"}, wantUnary: []string{"b", "a"}, wantStream: []string{"a"}},
+		{order: []string{"a"}, wantErr: true},
+		{order: []string{"a", "b", "c"}, wantErr: true},
+		{order: []string{"a", "b", "a"}, wantErr: true},
+	} {
+		u, s, err := Chain(test.order, interceptors)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("Chain(%v)=(_, _, %v), want err? %v", test.order, err, test.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+
+		calls = nil
+		u(context.Background(), nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil })
+		if !reflect.DeepEqual(calls, test.wantUnary) {
+			t.Errorf("Chain(%v) unary calls=%v, want %v", test.order, calls, test.wantUnary)
+		}
+		calls = nil
+		s(nil, nil, &grpc.StreamServerInfo{}, func(srv interface{}, ss grpc.ServerStream) error { return nil })
+		if !reflect.DeepEqual(calls, test.wantStream) {
+			t.Errorf("Chain(%v) stream calls=%v, want %v", test.order, calls, test.wantStream)
+		}
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	for _, test := range []struct {
+		method   string
+		wantCode codes.Code
+	}{
+		{method: "/trillian.TrillianLog/GetLeavesByIndex", wantCode: codes.OK},
+		{method: "/trillian.TrillianLog/GetLatestSignedLogRoot", wantCode: codes.OK},
+		{method: "/trillian.TrillianAdmin/ListTrees", wantCode: codes.OK},
+		{method: "/trillian.TrillianLog/QueueLeaves", wantCode: codes.PermissionDenied},
+		{method: "/trillian.TrillianLog/AddSequencedLeaves", wantCode: codes.PermissionDenied},
+		{method: "/trillian.TrillianAdmin/CreateTree", wantCode: codes.PermissionDenied},
+		{method: "/trillian.TrillianAdmin/DeleteTree", wantCode: codes.PermissionDenied},
+	} {
+		called := false
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			called = true
+			return nil, nil
+		}
+		_, err := ReadOnly()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: test.method}, handler)
+		if got := grpc.Code(err); got != test.wantCode {
+			t.Errorf("ReadOnly()(%v)=%v, want %v", test.method, err, test.wantCode)
+		}
+		if want := test.wantCode == codes.OK; called != want {
+			t.Errorf("ReadOnly()(%v): handler called=%v, want %v", test.method, called, want)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interceptor
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/monitoring/metric"
+	"github.com/google/trillian/server/errors"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// sweepInterval is how often clients which are back to a full allowance, and so can be
+// forgotten, are removed from a RateLimiter.
+const sweepInterval = time.Minute
+
+var rateLimited = metric.NewCounter("rpc_rate_limited")
+
+// RateLimitOptions configures a RateLimiter.
+type RateLimitOptions struct {
+	// QPS is the rate of RPCs allowed from each client IP.
+	QPS float64
+	// Burst is the number of RPCs a client can make at once after being idle, QPS
+	// rounded up if it's less than 1.
+	Burst int
+	// BanDuration, if greater than 0, is how long all RPCs from a client are rejected
+	// for once it exceeds its rate.
+	BanDuration time.Duration
+	// Allowlist holds the networks of clients which aren't limited.
+	Allowlist []*net.IPNet
+	// TrustedProxies holds the networks of proxies whose ProxyHeader is trusted to
+	// give the IP of the client they're forwarding for.
+	TrustedProxies []*net.IPNet
+	// ProxyHeader is the metadata key holding the comma separated list of IPs a
+	// request was forwarded for, e.g. "x-forwarded-for".
+	ProxyHeader string
+	// TimeSource is used to time the rate, the system time if nil.
+	TimeSource util.TimeSource
+}
+
+// RateLimiter limits the rate of RPCs from each client IP, failing those over it with
+// ResourceExhausted. It's independent of the trees the RPCs are for.
+type RateLimiter struct {
+	opts RateLimitOptions
+
+	mu        sync.Mutex
+	clients   map[string]*clientAllowance
+	lastSweep time.Time
+}
+
+// clientAllowance is a token bucket for a client.
+type clientAllowance struct {
+	tokens      float64
+	updated     time.Time
+	bannedUntil time.Time
+}
+
+// NewRateLimiter returns a RateLimiter configured by opts.
+func NewRateLimiter(opts RateLimitOptions) *RateLimiter {
+	if opts.Burst < 1 {
+		opts.Burst = int(math.Max(1, math.Ceil(opts.QPS)))
+	}
+	if opts.TimeSource == nil {
+		opts.TimeSource = util.SystemTimeSource{}
+	}
+	return &RateLimiter{
+		opts:      opts,
+		clients:   make(map[string]*clientAllowance),
+		lastSweep: opts.TimeSource.Now(),
+	}
+}
+
+// Interceptor returns an interceptor enforcing the limits.
+func (r *RateLimiter) Interceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := r.check(ctx); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor returns a stream interceptor enforcing the limits, each stream
+// counts as a single RPC.
+func (r *RateLimiter) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := r.check(ss.Context()); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// check returns a ResourceExhausted error if the client of ctx is over its rate, telling
+// it when it may retry.
+func (r *RateLimiter) check(ctx context.Context) error {
+	ip := r.clientIP(ctx)
+	if ip == nil || contains(r.opts.Allowlist, ip) {
+		return nil
+	}
+	if ok, retryDelay := r.allow(ip.String()); !ok {
+		rateLimited.Add(1)
+		return errors.ResourceExhausted("client:"+ip.String(), "client_qps", retryDelay, "client %v is over its rate limit of %v QPS", ip, r.opts.QPS)
+	}
+	return nil
+}
+
+// allow reports whether the client with the given IP may make an RPC now, and if so
+// takes it out of its allowance. If not, it also returns how long until the client may.
+func (r *RateLimiter) allow(client string) (bool, time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.opts.TimeSource.Now()
+	if now.Sub(r.lastSweep) >= sweepInterval {
+		r.sweep(now)
+	}
+
+	c, ok := r.clients[client]
+	if !ok {
+		c = &clientAllowance{tokens: float64(r.opts.Burst), updated: now}
+		r.clients[client] = c
+	}
+	if now.Before(c.bannedUntil) {
+		return false, c.bannedUntil.Sub(now)
+	}
+	c.tokens = r.refill(c, now)
+	c.updated = now
+	if c.tokens >= 1 {
+		c.tokens--
+		return true, 0
+	}
+	if r.opts.BanDuration > 0 {
+		glog.Warningf("Client %v exceeded %v QPS, rejecting its RPCs for %v", client, r.opts.QPS, r.opts.BanDuration)
+		c.bannedUntil = now.Add(r.opts.BanDuration)
+		return false, r.opts.BanDuration
+	}
+	return false, time.Duration((1 - c.tokens) / r.opts.QPS * float64(time.Second))
+}
+
+// refill returns the tokens c has at now.
+func (r *RateLimiter) refill(c *clientAllowance, now time.Time) float64 {
+	return math.Min(float64(r.opts.Burst), c.tokens+now.Sub(c.updated).Seconds()*r.opts.QPS)
+}
+
+// sweep forgets the clients which aren't banned and have their full allowance.
+func (r *RateLimiter) sweep(now time.Time) {
+	for client, c := range r.clients {
+		if !now.Before(c.bannedUntil) && r.refill(c, now) >= float64(r.opts.Burst) {
+			delete(r.clients, client)
+		}
+	}
+	r.lastSweep = now
+}
+
+// clientIP returns the IP of the client of ctx, or nil if it isn't known. If the peer
+// is a trusted proxy the client is the last IP in the ProxyHeader which isn't one.
+func (r *RateLimiter) clientIP(ctx context.Context) net.IP {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		host = p.Addr.String()
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || r.opts.ProxyHeader == "" || !contains(r.opts.TrustedProxies, ip) {
+		return ip
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	var forwarded []string
+	for _, v := range md[r.opts.ProxyHeader] {
+		forwarded = append(forwarded, strings.Split(v, ",")...)
+	}
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		fip := net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if fip == nil {
+			// Whatever is further on can't be trusted.
+			break
+		}
+		ip = fip
+		if !contains(r.opts.TrustedProxies, ip) {
+			break
+		}
+	}
+	return ip
+}
+
+func contains(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseNetworks parses a comma separated list of CIDR networks and IPs, e.g.
+// "10.0.0.0/8,192.168.1.1", as used by the Allowlist and TrustedProxies options.
+func ParseNetworks(spec string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, s := range strings.Split(spec, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP %q", s)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
@@ -0,0 +1,152 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interceptor
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func peerContext(ip string) context.Context {
+	return peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 1234}})
+}
+
+func mustParseNetworks(t *testing.T, spec string) []*net.IPNet {
+	nets, err := ParseNetworks(spec)
+	if err != nil {
+		t.Fatalf("ParseNetworks(%q)=(_, %v), want (_, nil)", spec, err)
+	}
+	return nets
+}
+
+func TestRateLimiter(t *testing.T) {
+	timeSource := &util.FakeTimeSource{FakeTime: time.Unix(1000, 0)}
+	r := NewRateLimiter(RateLimitOptions{
+		QPS:         1,
+		Burst:       2,
+		BanDuration: time.Minute,
+		Allowlist:   mustParseNetworks(t, "10.0.0.0/8"),
+		TimeSource:  timeSource,
+	})
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+	call := func(ip string) codes.Code {
+		_, err := r.Interceptor()(peerContext(ip), nil, &grpc.UnaryServerInfo{}, handler)
+		return grpc.Code(err)
+	}
+
+	for i, want := range []codes.Code{codes.OK, codes.OK, codes.ResourceExhausted} {
+		if got := call("192.0.2.1"); got != want {
+			t.Errorf("call %d from 192.0.2.1: %v, want %v", i, got, want)
+		}
+	}
+	// Other clients have their own allowance, allowlisted ones are unlimited.
+	if got := call("192.0.2.2"); got != codes.OK {
+		t.Errorf("call from 192.0.2.2: %v, want OK", got)
+	}
+	for i := 0; i < 5; i++ {
+		if got := call("10.1.2.3"); got != codes.OK {
+			t.Errorf("call %d from allowlisted 10.1.2.3: %v, want OK", i, got)
+		}
+	}
+
+	// The client exceeding its rate is banned even once its allowance is back.
+	timeSource.FakeTime = timeSource.FakeTime.Add(30 * time.Second)
+	if got := call("192.0.2.1"); got != codes.ResourceExhausted {
+		t.Errorf("call from banned 192.0.2.1: %v, want ResourceExhausted", got)
+	}
+	timeSource.FakeTime = timeSource.FakeTime.Add(time.Minute)
+	if got := call("192.0.2.1"); got != codes.OK {
+		t.Errorf("call from 192.0.2.1 after ban: %v, want OK", got)
+	}
+	if got, want := len(r.clients), 1; got != want {
+		t.Errorf("%d clients remembered after sweep, want %d", got, want)
+	}
+}
+
+func TestRateLimiterRetryDelay(t *testing.T) {
+	timeSource := &util.FakeTimeSource{FakeTime: time.Unix(1000, 0)}
+	r := NewRateLimiter(RateLimitOptions{QPS: 2, Burst: 1, TimeSource: timeSource})
+	for i, want := range []time.Duration{0, 500 * time.Millisecond} {
+		if _, got := r.allow("192.0.2.1"); got != want {
+			t.Errorf("allow() %d: retry delay %v, want %v", i, got, want)
+		}
+	}
+
+	r = NewRateLimiter(RateLimitOptions{QPS: 1, BanDuration: time.Minute, TimeSource: timeSource})
+	r.allow("192.0.2.1")
+	r.allow("192.0.2.1")
+	timeSource.FakeTime = timeSource.FakeTime.Add(10 * time.Second)
+	if _, got := r.allow("192.0.2.1"); got != 50*time.Second {
+		t.Errorf("allow() while banned: retry delay %v, want 50s", got)
+	}
+}
+
+func TestRateLimiterClientIP(t *testing.T) {
+	r := NewRateLimiter(RateLimitOptions{
+		QPS:            1,
+		TrustedProxies: mustParseNetworks(t, "10.0.0.1,10.0.1.0/24"),
+		ProxyHeader:    "x-forwarded-for",
+	})
+	for _, test := range []struct {
+		peer      string
+		forwarded []string
+		want      string
+	}{
+		{peer: "192.0.2.1", want: "192.0.2.1"},
+		{peer: "192.0.2.1", forwarded: []string{"198.51.100.1"}, want: "192.0.2.1"},
+		{peer: "10.0.0.1", want: "10.0.0.1"},
+		{peer: "10.0.0.1", forwarded: []string{"198.51.100.1"}, want: "198.51.100.1"},
+		{peer: "10.0.0.1", forwarded: []string{"203.0.113.9, 198.51.100.1, 10.0.1.5"}, want: "198.51.100.1"},
+		{peer: "10.0.0.1", forwarded: []string{"203.0.113.9", "198.51.100.1"}, want: "198.51.100.1"},
+		{peer: "10.0.0.1", forwarded: []string{"garbage, 10.0.1.5"}, want: "10.0.1.5"},
+	} {
+		ctx := peerContext(test.peer)
+		if test.forwarded != nil {
+			ctx = metadata.NewIncomingContext(ctx, metadata.MD{"x-forwarded-for": test.forwarded})
+		}
+		if got := r.clientIP(ctx); got.String() != test.want {
+			t.Errorf("clientIP(peer %v, forwarded for %v)=%v, want %v", test.peer, test.forwarded, got, test.want)
+		}
+	}
+}
+
+func TestParseNetworks(t *testing.T) {
+	nets := mustParseNetworks(t, "192.0.2.0/24, 2001:db8::1,198.51.100.7")
+	for _, test := range []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "192.0.2.200", want: true},
+		{ip: "2001:db8::1", want: true},
+		{ip: "2001:db8::2", want: false},
+		{ip: "198.51.100.7", want: true},
+		{ip: "198.51.100.8", want: false},
+	} {
+		if got := contains(nets, net.ParseIP(test.ip)); got != test.want {
+			t.Errorf("contains(%v)=%v, want %v", test.ip, got, test.want)
+		}
+	}
+	if _, err := ParseNetworks("192.0.2.0/33"); err == nil {
+		t.Error("ParseNetworks(192.0.2.0/33)=(_, nil), want error")
+	}
+}
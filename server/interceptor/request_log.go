@@ -0,0 +1,182 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interceptor
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	mrand "math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian/monitoring/logging"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// RequestIDHeader is the metadata key a client may set the ID of its request in. The
+// ID used, given or generated, is returned to the client in the response headers.
+const RequestIDHeader = "x-request-id"
+
+// maxRequestIDLen is the longest request ID accepted from a client.
+const maxRequestIDLen = 128
+
+// RequestLogOptions configures RequestLog and RequestLogStream.
+type RequestLogOptions struct {
+	// LogRPCs logs a line for each RPC with its request ID, method, status and latency.
+	LogRPCs bool
+	// PayloadSampleRate is the fraction, between 0 and 1, of unary RPCs whose request
+	// and response are logged too.
+	PayloadSampleRate float64
+	// MaxPayloadBytes is the length logged payloads are truncated to, unlimited if 0.
+	MaxPayloadBytes int
+}
+
+// RequestLogger attaches request IDs to RPCs and logs them, with options which can be
+// changed while serving.
+type RequestLogger struct {
+	mu   sync.RWMutex
+	opts RequestLogOptions
+}
+
+// NewRequestLogger returns a RequestLogger configured by opts.
+func NewRequestLogger(opts RequestLogOptions) *RequestLogger {
+	return &RequestLogger{opts: opts}
+}
+
+// SetOptions changes the options of RPCs which start from now on.
+func (l *RequestLogger) SetOptions(opts RequestLogOptions) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.opts = opts
+}
+
+func (l *RequestLogger) options() RequestLogOptions {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.opts
+}
+
+// RequestLog returns an interceptor which attaches a request ID to the context of each
+// RPC, so it's included in the log ID prefixes of everything the RPC logs, and returns
+// it in the response headers. The client's ID from the RequestIDHeader is used if it's
+// valid, otherwise one is generated.
+func RequestLog(opts RequestLogOptions) grpc.UnaryServerInterceptor {
+	return NewRequestLogger(opts).Interceptor()
+}
+
+// RequestLogStream is RequestLog for streaming methods. Stream messages aren't logged.
+func RequestLogStream(opts RequestLogOptions) grpc.StreamServerInterceptor {
+	return NewRequestLogger(opts).StreamInterceptor()
+}
+
+// Interceptor returns an interceptor as described by RequestLog.
+func (l *RequestLogger) Interceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		opts := l.options()
+		id := requestID(ctx)
+		ctx = util.NewRequestIDContext(ctx, id)
+		// Fails if the RPC has already sent its headers, which it can't have yet.
+		grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, id))
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		if opts.LogRPCs {
+			logRPC(ctx, info.FullMethod, err, start)
+		}
+		if opts.PayloadSampleRate > 0 && mrand.Float64() < opts.PayloadSampleRate {
+			logging.Infof(ctx, "request_payload=%q response_payload=%q", payload(req, opts.MaxPayloadBytes), payload(resp, opts.MaxPayloadBytes))
+		}
+		return resp, err
+	}
+}
+
+// StreamInterceptor returns a stream interceptor as described by RequestLogStream.
+func (l *RequestLogger) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		opts := l.options()
+		id := requestID(ss.Context())
+		ss.SetHeader(metadata.Pairs(RequestIDHeader, id))
+
+		ctx := util.NewRequestIDContext(ss.Context(), id)
+		start := time.Now()
+		err := handler(srv, contextStream{ss, ctx})
+		if opts.LogRPCs {
+			logRPC(ctx, info.FullMethod, err, start)
+		}
+		return err
+	}
+}
+
+// contextStream is a stream with its context replaced by ctx.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// requestID returns the request ID the client sent with ctx, or a new one if it didn't
+// send a valid one.
+func requestID(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md[RequestIDHeader]; len(ids) == 1 && validRequestID(ids[0]) {
+			return ids[0]
+		}
+	}
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
+
+// validRequestID reports whether id is short and printable, so it's safe to log.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, c := range id {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func logRPC(ctx context.Context, method string, err error, start time.Time) {
+	logging.Infof(ctx, "method=%s code=%s latency=%v", method, grpc.Code(err), time.Since(start))
+}
+
+// payload formats an RPC's request or response for logging.
+func payload(msg interface{}, maxBytes int) string {
+	var s string
+	if m, ok := msg.(proto.Message); ok {
+		s = proto.CompactTextString(m)
+	} else {
+		s = fmt.Sprint(msg)
+	}
+	if maxBytes > 0 && len(s) > maxBytes {
+		s = s[:maxBytes] + "..."
+	}
+	return s
+}
@@ -0,0 +1,70 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interceptor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestRequestLog(t *testing.T) {
+	for _, test := range []struct {
+		desc     string
+		header   []string
+		wantSame bool
+	}{
+		{desc: "none"},
+		{desc: "valid", header: []string{"client-id-1"}, wantSame: true},
+		{desc: "space", header: []string{"client id"}},
+		{desc: "too long", header: []string{strings.Repeat("a", maxRequestIDLen+1)}},
+		{desc: "repeated", header: []string{"a", "b"}},
+	} {
+		ctx := context.Background()
+		if test.header != nil {
+			ctx = metadata.NewIncomingContext(ctx, metadata.MD{RequestIDHeader: test.header})
+		}
+		var got string
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			got = util.RequestID(ctx)
+			return req, nil
+		}
+		opts := RequestLogOptions{LogRPCs: true, PayloadSampleRate: 1}
+		if _, err := RequestLog(opts)(ctx, "req", &grpc.UnaryServerInfo{FullMethod: "/test/Method"}, handler); err != nil {
+			t.Errorf("%v: RequestLog()()=(_, %v), want (_, nil)", test.desc, err)
+		}
+		if got == "" {
+			t.Errorf("%v: handler got no request ID", test.desc)
+		}
+		if same := test.header != nil && got == test.header[0]; same != test.wantSame {
+			t.Errorf("%v: handler got request ID %q, want client's ID? %v", test.desc, got, test.wantSame)
+		}
+	}
+}
+
+func TestPayload(t *testing.T) {
+	req := &trillian.GetLeavesByIndexRequest{LogId: 12, LeafIndex: []int64{1, 2}}
+	if got, want := payload(req, 0), "log_id:12"; !strings.HasPrefix(got, want) {
+		t.Errorf("payload()=%q, want prefix %q", got, want)
+	}
+	if got, want := payload("abcdefgh", 4), "abcd..."; got != want {
+		t.Errorf("payload(max 4)=%q, want %q", got, want)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interceptor
+
+import (
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// TenantHeader is the metadata key a client sets the name of its tenant in, which
+// decides where storage keeping tenants apart creates its trees.
+const TenantHeader = "x-trillian-tenant"
+
+// TenantPolicy maps the names of tenants to the SPIFFE IDs of the clients allowed to act
+// for them. As in a SPIFFEPolicy, an ID ending in "/" allows every ID it's a prefix of.
+type TenantPolicy map[string][]string
+
+// Tenant returns an interceptor which associates each RPC's context with the tenant
+// named in its TenantHeader, if any. The header is set by the client, so RPCs naming a
+// tenant are rejected with Unauthenticated if the client has no SPIFFE ID, and with
+// PermissionDenied unless the policy allows its ID to act for the tenant. The server
+// must use mutual TLS credentials, e.g. from the util/spiffe package. RPCs naming more
+// than one tenant fail with InvalidArgument.
+func Tenant(policy TenantPolicy) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		switch tenants := md[TenantHeader]; len(tenants) {
+		case 0:
+		case 1:
+			id, ok := peerSPIFFEID(ctx)
+			if !ok {
+				return nil, grpc.Errorf(codes.Unauthenticated, "%v requires a client certificate with a SPIFFE ID", TenantHeader)
+			}
+			if !allowsID(policy[tenants[0]], id) {
+				return nil, grpc.Errorf(codes.PermissionDenied, "%v is not allowed to act for tenant %q", id, tenants[0])
+			}
+			ctx = util.NewTenantContext(ctx, tenants[0])
+		default:
+			return nil, grpc.Errorf(codes.InvalidArgument, "%v given %d times, want at most once", TenantHeader, len(tenants))
+		}
+		return handler(ctx, req)
+	}
+}
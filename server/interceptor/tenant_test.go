@@ -0,0 +1,61 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interceptor
+
+import (
+	"testing"
+
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestTenant(t *testing.T) {
+	policy := TenantPolicy{"acme": {"spiffe://example.org/acme/"}}
+	for _, test := range []struct {
+		desc       string
+		ctx        context.Context
+		header     []string
+		wantTenant string
+		wantCode   codes.Code
+	}{
+		{desc: "none", ctx: context.Background()},
+		{desc: "noneWithID", ctx: tlsPeerContext(t, "spiffe://example.org/other")},
+		{desc: "tenant", ctx: tlsPeerContext(t, "spiffe://example.org/acme/ct"), header: []string{"acme"}, wantTenant: "acme"},
+		{desc: "otherTenant", ctx: tlsPeerContext(t, "spiffe://example.org/other"), header: []string{"acme"}, wantCode: codes.PermissionDenied},
+		{desc: "unknownTenant", ctx: tlsPeerContext(t, "spiffe://example.org/acme/ct"), header: []string{"unknown"}, wantCode: codes.PermissionDenied},
+		{desc: "noID", ctx: context.Background(), header: []string{"acme"}, wantCode: codes.Unauthenticated},
+		{desc: "repeated", ctx: tlsPeerContext(t, "spiffe://example.org/acme/ct"), header: []string{"acme", "other"}, wantCode: codes.InvalidArgument},
+	} {
+		ctx := test.ctx
+		if test.header != nil {
+			ctx = metadata.NewIncomingContext(ctx, metadata.MD{TenantHeader: test.header})
+		}
+		var got string
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			got = util.Tenant(ctx)
+			return req, nil
+		}
+		_, err := Tenant(policy)(ctx, "req", &grpc.UnaryServerInfo{FullMethod: "/test/Method"}, handler)
+		if code := grpc.Code(err); code != test.wantCode {
+			t.Errorf("%v: Tenant()()=(_, %v), want code %v", test.desc, err, test.wantCode)
+		}
+		if got != test.wantTenant {
+			t.Errorf("%v: handler got tenant %q, want %q", test.desc, got, test.wantTenant)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package serverutil holds the flags shared by the server binaries, and what they set
+// up from them, so each binary gets the same flags with the same meaning. Each group
+// of flags is registered by its Add function, called while the binary declares its
+// own flags.
+package serverutil
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/google/trillian/monitoring/alert"
+	"github.com/google/trillian/monitoring/push"
+	"github.com/google/trillian/monitoring/push/cloudmonitoring"
+	"github.com/google/trillian/monitoring/push/cloudwatch"
+	"github.com/google/trillian/storage/blob"
+	"github.com/google/trillian/storage/blob/file"
+	"github.com/google/trillian/storage/blob/gcs"
+	"github.com/google/trillian/storage/blob/s3"
+	"github.com/google/trillian/storage/mysql"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+// MySQLFlags are the flags configuring connections to MySQL.
+type MySQLFlags struct {
+	tlsCA, tlsCert, tlsKey, tlsServerName *string
+	timeout, readTimeout, writeTimeout    *time.Duration
+	collation                             *string
+	connectRetries                        *int
+	connectTimeout                        *time.Duration
+}
+
+// AddMySQLFlags registers the MySQL connection flags with fs.
+func AddMySQLFlags(fs *flag.FlagSet) *MySQLFlags {
+	return &MySQLFlags{
+		tlsCA:          fs.String("mysql_tls_ca", "", "PEM file of the CA certificates the MySQL server's certificate is checked against, enables TLS"),
+		tlsCert:        fs.String("mysql_tls_cert", "", "PEM file of the client certificate presented to MySQL, enables TLS"),
+		tlsKey:         fs.String("mysql_tls_key", "", "PEM file of the private key of --mysql_tls_cert"),
+		tlsServerName:  fs.String("mysql_tls_server_name", "", "If set, the name the MySQL server's certificate is checked for instead of the host in --mysql_uri"),
+		timeout:        fs.Duration("mysql_timeout", 0, "If greater than 0, the timeout for connecting to MySQL"),
+		readTimeout:    fs.Duration("mysql_read_timeout", 0, "If greater than 0, the timeout for reads from MySQL connections"),
+		writeTimeout:   fs.Duration("mysql_write_timeout", 0, "If greater than 0, the timeout for writes to MySQL connections"),
+		collation:      fs.String("mysql_collation", "", "If set, the collation of MySQL connections, e.g. utf8mb4_general_ci"),
+		connectRetries: fs.Int("db_connect_retries", 0, "Number of times to retry connecting to MySQL at startup, with a backoff doubling from a second, e.g. while the database is still starting"),
+		connectTimeout: fs.Duration("db_connect_timeout", 0, "If greater than 0, how long to keep retrying the connection to MySQL at startup, within --db_connect_retries if it's set"),
+	}
+}
+
+// Options returns the connection options set by the flags.
+func (f *MySQLFlags) Options() mysql.DBOptions {
+	return mysql.DBOptions{
+		TLSCAFile:      *f.tlsCA,
+		TLSCertFile:    *f.tlsCert,
+		TLSKeyFile:     *f.tlsKey,
+		TLSServerName:  *f.tlsServerName,
+		Timeout:        *f.timeout,
+		ReadTimeout:    *f.readTimeout,
+		WriteTimeout:   *f.writeTimeout,
+		Collation:      *f.collation,
+		ConnectRetries: *f.connectRetries,
+		ConnectTimeout: *f.connectTimeout,
+	}
+}
+
+// AlertFlags are the flags configuring alerts on metric thresholds.
+type AlertFlags struct {
+	rules          *string
+	webhookURL     *string
+	webhookTimeout *time.Duration
+	interval       *time.Duration
+}
+
+// AddAlertFlags registers the alerting flags with fs. example is a value of
+// --alert_rules suited to the binary, for its help.
+func AddAlertFlags(fs *flag.FlagSet, example string) *AlertFlags {
+	return &AlertFlags{
+		rules:          fs.String("alert_rules", "", fmt.Sprintf("If set, comma separated list of metric thresholds to alert on, e.g. %s. Alerts are logged and sent to --alert_webhook_url, see the monitoring/alert package for the syntax", example)),
+		webhookURL:     fs.String("alert_webhook_url", "", "If set, the URL to POST alerts to, as JSON, when an --alert_rules threshold is crossed and when the value goes back below it"),
+		webhookTimeout: fs.Duration("alert_webhook_timeout", 10*time.Second, "Timeout for delivering each alert to --alert_webhook_url"),
+		interval:       fs.Duration("alert_check_interval", time.Minute, "How often the --alert_rules thresholds are checked"),
+	}
+}
+
+// Enabled returns whether --alert_rules is set.
+func (f *AlertFlags) Enabled() bool {
+	return *f.rules != ""
+}
+
+// StartMonitor starts checking the --alert_rules thresholds until ctx is done.
+func (f *AlertFlags) StartMonitor(ctx context.Context) error {
+	rules, err := alert.ParseRules(*f.rules)
+	if err != nil {
+		return fmt.Errorf("invalid --alert_rules: %v", err)
+	}
+	notifiers := []alert.Notifier{alert.LogNotifier}
+	if *f.webhookURL != "" {
+		notifiers = append(notifiers, alert.NewWebhook(*f.webhookURL, *f.webhookTimeout))
+	}
+	go alert.NewMonitor(rules, util.SystemTimeSource{}, notifiers...).Run(ctx, *f.interval)
+	return nil
+}
+
+// PushFlags are the flags configuring pushing metrics to a monitoring system.
+type PushFlags struct {
+	target       *string
+	interval     *time.Duration
+	labels       *string
+	project      *string
+	resourceType *string
+	namespace    *string
+}
+
+// AddPushFlags registers the metrics pushing flags with fs. job is the binary's name
+// in the example labels of its help, e.g. log-server.
+func AddPushFlags(fs *flag.FlagSet, job string) *PushFlags {
+	return &PushFlags{
+		target:       fs.String("metrics_push_target", "", "If set, the monitoring system to push metrics to every --metrics_push_interval, for deployments that can't be scraped: cloud_monitoring or cloudwatch"),
+		interval:     fs.Duration("metrics_push_interval", time.Minute, "How often metrics are pushed to --metrics_push_target"),
+		labels:       fs.String("metrics_resource_labels", "", fmt.Sprintf("Comma separated list of name=value labels identifying this process in pushed metrics, e.g. zone=us-east1-b,job=%s. They're the monitored resource's labels with cloud_monitoring, and extra dimensions with cloudwatch", job)),
+		project:      fs.String("metrics_push_project", "", "Google Cloud project to write metrics to with --metrics_push_target=cloud_monitoring"),
+		resourceType: fs.String("metrics_push_resource_type", "global", "Monitored resource type metrics are written for with --metrics_push_target=cloud_monitoring, e.g. generic_task, whose labels --metrics_resource_labels must give"),
+		namespace:    fs.String("metrics_push_namespace", "Trillian", "CloudWatch namespace to write metrics to with --metrics_push_target=cloudwatch"),
+	}
+}
+
+// Enabled returns whether --metrics_push_target is set.
+func (f *PushFlags) Enabled() bool {
+	return *f.target != ""
+}
+
+// StartPusher starts pushing metrics to --metrics_push_target until ctx is done.
+func (f *PushFlags) StartPusher(ctx context.Context) error {
+	labels, err := push.ParseLabels(*f.labels)
+	if err != nil {
+		return fmt.Errorf("invalid --metrics_resource_labels: %v", err)
+	}
+	var exporter push.Exporter
+	switch *f.target {
+	case "cloud_monitoring":
+		exporter, err = cloudmonitoring.NewExporter(ctx, *f.project, *f.resourceType, labels)
+	case "cloudwatch":
+		exporter, err = cloudwatch.NewExporter(*f.namespace, labels)
+	default:
+		err = fmt.Errorf("unknown target %q", *f.target)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to push metrics to --metrics_push_target: %v", err)
+	}
+	go push.NewPusher(exporter, util.SystemTimeSource{}).Run(ctx, *f.interval)
+	return nil
+}
+
+// BlobFlags are the flags configuring where large leaf values are kept.
+type BlobFlags struct {
+	store  *string
+	dir    *string
+	bucket *string
+	prefix *string
+}
+
+// AddBlobFlags registers the blob store flags with fs. storeUsage is the help of
+// --blob_store, which says what the binary uses the store for.
+func AddBlobFlags(fs *flag.FlagSet, storeUsage string) *BlobFlags {
+	return &BlobFlags{
+		store:  fs.String("blob_store", "", storeUsage),
+		dir:    fs.String("blob_dir", "", "Directory blobs are kept in with --blob_store=file, which must be shared by all servers using the database"),
+		bucket: fs.String("blob_bucket", "", "Bucket blobs are kept in with --blob_store=gcs or s3"),
+		prefix: fs.String("blob_prefix", "", "Prefix of the names of blobs kept in --blob_bucket"),
+	}
+}
+
+// Enabled returns whether --blob_store is set.
+func (f *BlobFlags) Enabled() bool {
+	return *f.store != ""
+}
+
+// NewStore returns the --blob_store.
+func (f *BlobFlags) NewStore(ctx context.Context) (blob.Store, error) {
+	var store blob.Store
+	var err error
+	switch *f.store {
+	case "file":
+		store, err = file.NewStore(*f.dir)
+	case "gcs":
+		store, err = gcs.NewStore(ctx, *f.bucket, *f.prefix)
+	case "s3":
+		store, err = s3.NewStore(*f.bucket, *f.prefix)
+	default:
+		err = fmt.Errorf("unknown blob store %q", *f.store)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create --blob_store: %v", err)
+	}
+	return store, nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serverutil
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/trillian/storage/mysql"
+	"golang.org/x/net/context"
+)
+
+func TestMySQLFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	f := AddMySQLFlags(fs)
+	if err := fs.Parse([]string{"--mysql_tls_ca=ca.pem", "--mysql_read_timeout=5s", "--db_connect_retries=3"}); err != nil {
+		t.Fatalf("Parse()=%v", err)
+	}
+	want := mysql.DBOptions{TLSCAFile: "ca.pem", ReadTimeout: 5 * time.Second, ConnectRetries: 3}
+	if got := f.Options(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Options()=%+v, want %+v", got, want)
+	}
+}
+
+func TestFlagErrors(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	alerts := AddAlertFlags(fs, "x>1")
+	pushes := AddPushFlags(fs, "test")
+	blobs := AddBlobFlags(fs, "Blob store")
+	if alerts.Enabled() || pushes.Enabled() || blobs.Enabled() {
+		t.Errorf("Enabled()=true without flags")
+	}
+	if err := fs.Parse([]string{"--alert_rules=x", "--metrics_push_target=unknown", "--blob_store=unknown"}); err != nil {
+		t.Fatalf("Parse()=%v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := alerts.StartMonitor(ctx); err == nil {
+		t.Errorf("StartMonitor() with invalid rules=nil, want error")
+	}
+	if err := pushes.StartPusher(ctx); err == nil {
+		t.Errorf("StartPusher() to an unknown target=nil, want error")
+	}
+	if _, err := blobs.NewStore(ctx); err == nil {
+		t.Errorf("NewStore() of an unknown store=nil, want error")
+	}
+}
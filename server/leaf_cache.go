@@ -0,0 +1,58 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"expvar"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+)
+
+// leafCacheStats counts leaf cache hits, misses and evictions, across all logs.
+var leafCacheStats = expvar.NewMap("log-leaf-cache")
+
+// leafKey identifies a leaf as read at a tree revision. Keying on the revision means an
+// entry is only served to readers of the same revision, so the cache can never return
+// a leaf a snapshot shouldn't see; entries for older revisions age out.
+type leafKey struct {
+	logID    int64
+	revision int64
+	index    int64
+}
+
+// leafCache is an LRU cache of leaves fetched by index, bounded by the total encoded
+// size of the leaves it holds. Cached leaves are shared, callers mustn't modify them.
+type leafCache struct {
+	lru *lruCache
+}
+
+func newLeafCache(maxBytes int64) *leafCache {
+	return &leafCache{lru: newLRUCache(maxBytes, leafCacheStats)}
+}
+
+func (c *leafCache) get(key leafKey) (*trillian.LogLeaf, bool) {
+	v, ok := c.lru.get(key)
+	if !ok {
+		return nil, false
+	}
+	return v.(*trillian.LogLeaf), true
+}
+
+// put adds leaf to the cache, evicting the least recently used leaves to make room.
+// Leaves larger than the whole cache aren't added.
+func (c *leafCache) put(key leafKey, leaf *trillian.LogLeaf) {
+	c.lru.put(key, leaf, int64(proto.Size(leaf)))
+}
@@ -0,0 +1,76 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+)
+
+func TestLeafCacheEviction(t *testing.T) {
+	leaf := func(index int64) *trillian.LogLeaf {
+		return &trillian.LogLeaf{LeafIndex: index, LeafValue: make([]byte, 100)}
+	}
+	size := int64(proto.Size(leaf(1)))
+	key := func(index int64) leafKey {
+		return leafKey{logID: 1, revision: 1, index: index}
+	}
+
+	// Room for three leaves.
+	c := newLeafCache(3*size + size/2)
+	for i := int64(1); i <= 3; i++ {
+		c.put(key(i), leaf(i))
+	}
+	// Using leaf 1 makes leaf 2 the least recently used.
+	if _, ok := c.get(key(1)); !ok {
+		t.Fatal("get(1) missed, want hit")
+	}
+	c.put(key(4), leaf(4))
+
+	for _, test := range []struct {
+		index   int64
+		wantHit bool
+	}{
+		{index: 1, wantHit: true},
+		{index: 2, wantHit: false},
+		{index: 3, wantHit: true},
+		{index: 4, wantHit: true},
+	} {
+		got, ok := c.get(key(test.index))
+		if ok != test.wantHit {
+			t.Errorf("get(%d) hit=%v, want %v", test.index, ok, test.wantHit)
+			continue
+		}
+		if ok && got.LeafIndex != test.index {
+			t.Errorf("get(%d)=leaf %d", test.index, got.LeafIndex)
+		}
+	}
+
+	// The same index at another revision is a different entry.
+	if _, ok := c.get(leafKey{logID: 1, revision: 2, index: 1}); ok {
+		t.Error("get(1) at another revision hit, want miss")
+	}
+	// Leaves which don't fit at all aren't cached.
+	big := &trillian.LogLeaf{LeafIndex: 5, LeafValue: make([]byte, 4*size)}
+	c.put(key(5), big)
+	if _, ok := c.get(key(5)); ok {
+		t.Error("get(5) of oversized leaf hit, want miss")
+	}
+	if _, ok := c.get(key(1)); !ok {
+		t.Error("get(1) missed after oversized put, want hit")
+	}
+}
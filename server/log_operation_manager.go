@@ -16,6 +16,7 @@ package server
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/golang/glog"
@@ -29,7 +30,19 @@ type LogOperation interface {
 	// Name returns the name of the task.
 	Name() string
 	// ExecutePass performs a single pass of processing on a set of logs.
-	ExecutePass(logIDs []int64, context LogOperationManagerContext)
+	ExecutePass(logIDs []int64, context LogOperationManagerContext) *PassResult
+}
+
+// PassResult reports the outcome of a LogOperation pass for each log it was run on.
+type PassResult struct {
+	// Processed holds, for each log the pass succeeded for, the number of items
+	// (e.g. leaves) that were processed.
+	Processed map[int64]int
+	// Failed holds the logs the pass failed for.
+	Failed []int64
+	// Backlogged holds the logs which processed a whole batch, and so may have more
+	// work pending.
+	Backlogged []int64
 }
 
 // LogOperationManagerContext bundles up the values so testing can be made easier
@@ -91,23 +104,29 @@ func NewLogOperationManagerForTest(ctx context.Context, registry extension.Regis
 	}
 }
 
-func (l LogOperationManager) getLogsAndExecutePass(ctx context.Context) bool {
+func (l LogOperationManager) getActiveLogIDs(ctx context.Context) ([]int64, error) {
 	tx, err := l.context.registry.LogStorage.Snapshot(ctx)
 	if err != nil {
-		glog.Warningf("Failed to get tx for run: %v", err)
-		return false
+		return nil, fmt.Errorf("failed to get tx for run: %v", err)
 	}
 	defer tx.Close()
 
-	// Inner loop is across all active logs, currently one at a time
 	logIDs, err := tx.GetActiveLogIDs()
 	if err != nil {
-		glog.Warningf("Failed to get log list for run: %v", err)
-		return false
+		return nil, fmt.Errorf("failed to get log list for run: %v", err)
 	}
 
 	if err := tx.Commit(); err != nil {
-		glog.Warningf("Failed to commit getting logs: %v", err)
+		return nil, fmt.Errorf("failed to commit getting logs: %v", err)
+	}
+	return logIDs, nil
+}
+
+func (l LogOperationManager) getLogsAndExecutePass(ctx context.Context) bool {
+	// Inner loop is across all active logs, currently one at a time
+	logIDs, err := l.getActiveLogIDs(ctx)
+	if err != nil {
+		glog.Warning(err)
 		return false
 	}
 
@@ -128,6 +147,45 @@ func (l LogOperationManager) OperationSingle() {
 	l.getLogsAndExecutePass(l.context.ctx)
 }
 
+// OperationRunOnce runs passes over logIDs, or over all active logs if logIDs is empty,
+// until none of them has any work left and then returns. A log has work left while the
+// operation reports it as backlogged. Logs the operation fails for are not retried, and an
+// error naming them is returned once the remaining logs are done.
+func (l LogOperationManager) OperationRunOnce(logIDs []int64) error {
+	ctx := l.context.ctx
+	if len(logIDs) == 0 {
+		var err error
+		if logIDs, err = l.getActiveLogIDs(ctx); err != nil {
+			return err
+		}
+	}
+	glog.Infof("Log operation manager running once over %d log(s)", len(logIDs))
+
+	var failed []int64
+	for pass := 1; len(logIDs) > 0; pass++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		result := l.logOperation.ExecutePass(logIDs, l.context)
+		if result == nil {
+			result = &PassResult{}
+		}
+		for _, logID := range logIDs {
+			if _, ok := result.Processed[logID]; !ok {
+				failed = append(failed, logID)
+			}
+		}
+		logIDs = result.Backlogged
+		glog.V(1).Infof("Log operation manager pass %d complete, %d log(s) have more work", pass, len(logIDs))
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%v failed for %d log(s): %v", l.logOperation.Name(), len(failed), failed)
+	}
+	return nil
+}
+
 // OperationLoop starts the manager working. It continues until told to exit.
 // TODO(Martin2112): No mechanism for error reporting etc., this is OK for v1 but needs work
 func (l LogOperationManager) OperationLoop() {
@@ -133,3 +133,58 @@ func TestLogOperationManagerPassesIDs(t *testing.T) {
 
 	lom.OperationLoop()
 }
+
+func TestLogOperationManagerRunOnceDrainsLogs(t *testing.T) {
+	logID1 := int64(451)
+	logID2 := int64(145)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockTx := storage.NewMockReadOnlyLogTX(ctrl)
+	mockTx.EXPECT().GetActiveLogIDs().Return([]int64{logID1, logID2}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+	mockTx.EXPECT().Close().Return(nil)
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockStorage.EXPECT().Snapshot(gomock.Any()).Return(mockTx, nil)
+
+	registry := extension.Registry{
+		LogStorage: mockStorage,
+	}
+
+	// logID1 is backlogged after the first pass, so it gets a second one.
+	mockLogOp := NewMockLogOperation(ctrl)
+	gomock.InOrder(
+		mockLogOp.EXPECT().ExecutePass([]int64{logID1, logID2}, logOpMgrContextMatcher{50}).Return(&PassResult{Processed: map[int64]int{logID1: 50, logID2: 3}, Backlogged: []int64{logID1}}),
+		mockLogOp.EXPECT().ExecutePass([]int64{logID1}, logOpMgrContextMatcher{50}).Return(&PassResult{Processed: map[int64]int{logID1: 10}}),
+	)
+
+	ctx := util.NewLogContext(context.Background(), -1)
+	lom := NewLogOperationManagerForTest(ctx, registry, 50, time.Second, fakeTimeSource, mockLogOp)
+
+	if err := lom.OperationRunOnce(nil); err != nil {
+		t.Errorf("OperationRunOnce() = %v, want nil", err)
+	}
+}
+
+func TestLogOperationManagerRunOnceReportsFailures(t *testing.T) {
+	logID1 := int64(451)
+	logID2 := int64(145)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Logs passed in explicitly don't require a storage lookup.
+	registry := extension.Registry{}
+
+	mockLogOp := NewMockLogOperation(ctrl)
+	mockLogOp.EXPECT().Name().AnyTimes().Return("Sequencer")
+	mockLogOp.EXPECT().ExecutePass([]int64{logID1, logID2}, logOpMgrContextMatcher{50}).Return(&PassResult{Processed: map[int64]int{logID1: 0}, Failed: []int64{logID2}})
+
+	ctx := util.NewLogContext(context.Background(), -1)
+	lom := NewLogOperationManagerForTest(ctx, registry, 50, time.Second, fakeTimeSource, mockLogOp)
+
+	if err := lom.OperationRunOnce([]int64{logID1, logID2}); err == nil {
+		t.Error("OperationRunOnce() = nil, want error")
+	}
+}
@@ -15,10 +15,17 @@
 package server
 
 import (
-	"github.com/golang/glog"
+	"bytes"
+	gocrypto "crypto"
+	"sort"
+	"time"
+
+	"github.com/golang/protobuf/proto"
 	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
 	"github.com/google/trillian/extension"
 	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/monitoring/logging"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/util"
 	"golang.org/x/net/context"
@@ -34,10 +41,47 @@ import (
 // Pass this as a fixed value to proof calculations. It's used as the max depth of the tree
 const proofMaxBitLen = 64
 
+// RequestLimits bounds the size of the requests the log server serves, so a single
+// request can't make it build a response too large to hold in memory. Requests over a
+// limit fail with InvalidArgument, and responses over MaxResponseBytes with OutOfRange.
+// Zero means no limit.
+type RequestLimits struct {
+	// MaxQueueLeaves is the most leaves a QueueLeaves or AddSequencedLeaves request may hold.
+	MaxQueueLeaves int
+	// MaxLeavesByIndex is the most indices a GetLeavesByIndex request may hold.
+	MaxLeavesByIndex int
+	// MaxProofs is the most inclusion proofs a GetInclusionProofByHash request may return,
+	// one per leaf with the hash, and the most consistency proofs a
+	// GetConsistencyProofHistory request may ask for.
+	MaxProofs int
+	// MaxResponseBytes is the most bytes of leaves a GetLeavesByIndex or GetLeavesByHash
+	// response may hold, and of proofs a GetInclusionProofByHash response may hold.
+	// GetLeavesByIndexStream splits its leaves between responses of up to this size
+	// instead, so clients can fall back to it.
+	MaxResponseBytes int64
+}
+
 // TrillianLogRPCServer implements the RPC API defined in the proto
 type TrillianLogRPCServer struct {
 	registry   extension.Registry
 	timeSource util.TimeSource
+	limits     RequestLimits
+	// backlog is nil unless backlog limits are set.
+	backlog *backlogLimiter
+	// witnesses holds the public keys of the registered witnesses by name, a root
+	// needs signatures from witnessQuorum of them to be returned as witnessed.
+	witnesses     map[string]gocrypto.PublicKey
+	witnessQuorum int
+	// leafCache is nil unless a leaf cache size is set.
+	leafCache *leafCache
+	// proofCache is nil unless a proof cache size is set.
+	proofCache *proofCache
+	// headCache is nil unless one is set.
+	headCache *HeadCache
+	// shards is nil unless sharding is enabled.
+	shards *shardRouter
+	// mergeDelays is nil unless merge delay reporting is enabled.
+	mergeDelays *mergeDelayCache
 }
 
 // NewTrillianLogRPCServer creates a new RPC server backed by a LogStorageProvider.
@@ -48,6 +92,67 @@ func NewTrillianLogRPCServer(registry extension.Registry, timeSource util.TimeSo
 	}
 }
 
+// SetBacklogLimits makes QueueLeaves fail with ResourceExhausted for trees whose queue of
+// leaves waiting to be sequenced is over limits.
+func (t *TrillianLogRPCServer) SetBacklogLimits(limits BacklogLimits) {
+	t.backlog = newBacklogLimiter(limits, t.registry.LogStorage, t.timeSource)
+}
+
+// SetRequestLimits makes requests over limits fail with InvalidArgument.
+func (t *TrillianLogRPCServer) SetRequestLimits(limits RequestLimits) {
+	t.limits = limits
+}
+
+// SetWitnesses registers the witnesses allowed to cosign log roots, keyed by name, and
+// the number of them which must cosign a root before it's returned as witnessed.
+func (t *TrillianLogRPCServer) SetWitnesses(keys map[string]gocrypto.PublicKey, quorum int) {
+	t.witnesses = keys
+	t.witnessQuorum = quorum
+}
+
+// SetLeafCacheSize makes GetLeavesByIndex cache the leaves it reads, in an LRU cache of
+// up to maxBytes. Zero disables the cache.
+func (t *TrillianLogRPCServer) SetLeafCacheSize(maxBytes int64) {
+	if maxBytes <= 0 {
+		t.leafCache = nil
+		return
+	}
+	t.leafCache = newLeafCache(maxBytes)
+}
+
+// SetProofCacheSize makes the server cache the inclusion proofs it builds, in an LRU
+// cache of up to maxBytes. Zero disables the cache.
+func (t *TrillianLogRPCServer) SetProofCacheSize(maxBytes int64) {
+	if maxBytes <= 0 {
+		t.proofCache = nil
+		return
+	}
+	t.proofCache = newProofCache(maxBytes)
+}
+
+// SetHeadCache makes the server build proofs from the Merkle nodes c holds where it can,
+// rather than reading them from storage. The cache may be shared with a SequencerManager
+// publishing leaves to it, otherwise the server reads the leaves the cache is missing as
+// proofs are asked for.
+func (t *TrillianLogRPCServer) SetHeadCache(c *HeadCache) {
+	t.headCache = c
+}
+
+// EnableSharding makes QueueLeaves requests for a log which is a shard go to the shard
+// of its set that's active, and GetLeavesByHash requests search all of the set's
+// shards. The shards are reread from admin storage every refresh interval, so shards
+// have to be created at least that long before their windows start.
+func (t *TrillianLogRPCServer) EnableSharding(refresh time.Duration) {
+	t.shards = newShardRouter(t.registry.AdminStorage, t.timeSource, refresh)
+}
+
+// EnableMergeDelays makes QueueLeaves responses report the max_merge_delay_seconds of
+// the log leaves are queued to. Trees are reread from admin storage every refresh
+// interval, so a changed delay may be reported that much later.
+func (t *TrillianLogRPCServer) EnableMergeDelays(refresh time.Duration) {
+	t.mergeDelays = newMergeDelayCache(t.registry, t.timeSource, refresh)
+}
+
 // IsHealthy returns nil if the server is healthy, error otherwise.
 func (t *TrillianLogRPCServer) IsHealthy() error {
 	return t.registry.LogStorage.CheckDatabaseAccessible(context.Background())
@@ -69,15 +174,46 @@ func (t *TrillianLogRPCServer) QueueLeaf(ctx context.Context, req *trillian.Queu
 	if len(queueRsp.QueuedLeaves) != 1 {
 		return nil, grpc.Errorf(codes.Internal, "unexpected count of leaves %d", len(queueRsp.QueuedLeaves))
 	}
-	return &trillian.QueueLeafResponse{QueuedLeaf: queueRsp.QueuedLeaves[0]}, nil
+	return &trillian.QueueLeafResponse{
+		QueuedLeaf:           queueRsp.QueuedLeaves[0],
+		LogId:                queueRsp.LogId,
+		QueueTimestampNanos:  queueRsp.QueueTimestampNanos,
+		MaxMergeDelaySeconds: queueRsp.MaxMergeDelaySeconds,
+	}, nil
 }
 
 // QueueLeaves submits a batch of leaves to the log for later integration into the underlying tree.
+// The response holds the time the leaves were queued at and, if merge delays are
+// enabled, the log's maximum merge delay, so callers can promise when the leaves will
+// be integrated by.
 func (t *TrillianLogRPCServer) QueueLeaves(ctx context.Context, req *trillian.QueueLeavesRequest) (*trillian.QueueLeavesResponse, error) {
 	ctx = util.NewLogContext(ctx, req.LogId)
 	if err := validateQueueLeavesRequest(req); err != nil {
 		return nil, err
 	}
+	if err := checkLimit("len(leaves)", len(req.Leaves), t.limits.MaxQueueLeaves); err != nil {
+		return nil, err
+	}
+	logID := req.LogId
+	if t.shards != nil {
+		var err error
+		if logID, err = t.shards.active(ctx, req.LogId); err != nil {
+			return nil, err
+		}
+		ctx = util.NewLogContext(ctx, logID)
+	}
+	if t.backlog != nil {
+		if err := t.backlog.check(ctx, logID); err != nil {
+			return nil, err
+		}
+	}
+	var mmd int32
+	if t.mergeDelays != nil {
+		var err error
+		if mmd, err = t.mergeDelays.get(ctx, logID); err != nil {
+			return nil, err
+		}
+	}
 
 	// TODO(al): Hasher must be selected based on log config.
 	th, _ := merkle.Factory(merkle.RFC6962SHA256Type)
@@ -85,21 +221,17 @@ func (t *TrillianLogRPCServer) QueueLeaves(ctx context.Context, req *trillian.Qu
 		req.Leaves[i].MerkleLeafHash = th.HashLeaf(req.Leaves[i].LeafValue)
 	}
 
-	tx, err := t.prepareStorageTx(ctx, req.LogId)
-	if err != nil {
-		return nil, err
-	}
-	defer tx.Close()
-
-	existingLeaves, err := tx.QueueLeaves(req.Leaves, t.timeSource.Now())
+	now := t.timeSource.Now()
+	var existingLeaves []*trillian.LogLeaf
+	err := t.runInStorageTx(ctx, logID, "QueueLeaves", func(tx storage.LogTreeTX) error {
+		var err error
+		existingLeaves, err = tx.QueueLeaves(req.Leaves, now)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	if err := t.commitAndLog(ctx, tx, "QueueLeaves"); err != nil {
-		return nil, err
-	}
-
 	var queuedLeaves []*trillian.QueuedLogLeaf
 	for i, existingLeaf := range existingLeaves {
 		if existingLeaf != nil {
@@ -115,7 +247,38 @@ func (t *TrillianLogRPCServer) QueueLeaves(ctx context.Context, req *trillian.Qu
 			queuedLeaves = append(queuedLeaves, &queuedLeaf)
 		}
 	}
-	return &trillian.QueueLeavesResponse{QueuedLeaves: queuedLeaves}, nil
+	return &trillian.QueueLeavesResponse{
+		QueuedLeaves:         queuedLeaves,
+		LogId:                logID,
+		QueueTimestampNanos:  now.UnixNano(),
+		MaxMergeDelaySeconds: mmd,
+	}, nil
+}
+
+// AddSequencedLeaves submits a batch of leaves whose indices are already assigned to a
+// PREORDERED_LOG tree. The leaves are integrated into the tree by the sequencer.
+func (t *TrillianLogRPCServer) AddSequencedLeaves(ctx context.Context, req *trillian.AddSequencedLeavesRequest) (*trillian.AddSequencedLeavesResponse, error) {
+	ctx = util.NewLogContext(ctx, req.LogId)
+	if err := validateAddSequencedLeavesRequest(req); err != nil {
+		return nil, err
+	}
+	if err := checkLimit("len(leaves)", len(req.Leaves), t.limits.MaxQueueLeaves); err != nil {
+		return nil, err
+	}
+
+	// TODO(al): Hasher must be selected based on log config.
+	th, _ := merkle.Factory(merkle.RFC6962SHA256Type)
+	for i := range req.Leaves {
+		req.Leaves[i].MerkleLeafHash = th.HashLeaf(req.Leaves[i].LeafValue)
+	}
+
+	err := t.runInStorageTx(ctx, req.LogId, "AddSequencedLeaves", func(tx storage.LogTreeTX) error {
+		return tx.AddSequencedLeaves(req.Leaves)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &trillian.AddSequencedLeavesResponse{}, nil
 }
 
 // GetInclusionProof obtains the proof of inclusion in the tree for a leaf that has been sequenced.
@@ -139,7 +302,7 @@ func (t *TrillianLogRPCServer) GetInclusionProof(ctx context.Context, req *trill
 		return nil, err
 	}
 
-	proof, err := getInclusionProofForLeafIndex(tx, req.TreeSize, req.LeafIndex, root.TreeSize)
+	proof, err := t.getInclusionProof(tx, req.LogId, req.TreeSize, req.LeafIndex, root.TreeSize)
 	if err != nil {
 		return nil, err
 	}
@@ -177,19 +340,25 @@ func (t *TrillianLogRPCServer) GetInclusionProofByHash(ctx context.Context, req
 	if len(leaves) < 1 {
 		return nil, grpc.Errorf(codes.NotFound, "No leaves for hash: %x", req.LeafHash)
 	}
+	if err := checkLimit("proofs", len(leaves), t.limits.MaxProofs); err != nil {
+		return nil, err
+	}
 
 	root, err := tx.LatestSignedLogRoot()
 	if err != nil {
 		return nil, err
 	}
 
-	// TODO(Martin2112): Need to define a limit on number of results or some form of paging etc.
+	budget := t.newResponseBudget("GetInclusionProofByHash", "ask for the proof of each leaf with GetInclusionProof")
 	proofs := make([]*trillian.Proof, 0, len(leaves))
 	for _, leaf := range leaves {
-		proof, err := getInclusionProofForLeafIndex(tx, req.TreeSize, leaf.LeafIndex, root.TreeSize)
+		proof, err := t.getInclusionProof(tx, req.LogId, req.TreeSize, leaf.LeafIndex, root.TreeSize)
 		if err != nil {
 			return nil, err
 		}
+		if err := budget.charge(&proof); err != nil {
+			return nil, err
+		}
 		proofs = append(proofs, &proof)
 	}
 
@@ -223,6 +392,9 @@ func (t *TrillianLogRPCServer) GetConsistencyProof(ctx context.Context, req *tri
 		return nil, err
 	}
 
+	if req.SecondTreeSize > root.TreeSize {
+		return nil, grpc.Errorf(codes.OutOfRange, "tree size %d is beyond the latest tree size %d", req.SecondTreeSize, root.TreeSize)
+	}
 	nodeFetches, err := merkle.CalcConsistencyProofNodeAddresses(req.FirstTreeSize, req.SecondTreeSize, root.TreeSize, proofMaxBitLen)
 	if err != nil {
 		return nil, err
@@ -230,7 +402,7 @@ func (t *TrillianLogRPCServer) GetConsistencyProof(ctx context.Context, req *tri
 
 	// Do all the node fetches at the second tree revision, which is what the node ids were calculated
 	// against.
-	proof, err := fetchNodesAndBuildProof(tx, tx.ReadRevision(), 0, nodeFetches)
+	proof, err := fetchNodesAndBuildProof(t.withHeadCache(tx, req.LogId, root.TreeSize), tx.ReadRevision(), 0, nodeFetches)
 	if err != nil {
 		return nil, err
 	}
@@ -243,11 +415,74 @@ func (t *TrillianLogRPCServer) GetConsistencyProof(ctx context.Context, req *tri
 	return &trillian.GetConsistencyProofResponse{Proof: &proof}, nil
 }
 
+// GetConsistencyProofHistory streams the consistency proofs between each neighbouring pair
+// of the requested tree sizes. The proofs are all built from one snapshot of the log, and
+// the nodes they share are only read from storage once.
+func (t *TrillianLogRPCServer) GetConsistencyProofHistory(req *trillian.GetConsistencyProofHistoryRequest, stream trillian.TrillianLog_GetConsistencyProofHistoryServer) error {
+	ctx := util.NewLogContext(stream.Context(), req.LogId)
+	if err := validateGetConsistencyProofHistoryRequest(req); err != nil {
+		return err
+	}
+	if err := checkLimit("proofs", len(req.TreeSizes)-1, t.limits.MaxProofs); err != nil {
+		return err
+	}
+
+	tx, err := t.prepareReadOnlyStorageTx(ctx, req.LogId)
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+
+	root, err := tx.LatestSignedLogRoot()
+	if err != nil {
+		return err
+	}
+
+	if last := req.TreeSizes[len(req.TreeSizes)-1]; last > root.TreeSize {
+		return grpc.Errorf(codes.OutOfRange, "tree size %d is beyond the latest tree size %d", last, root.TreeSize)
+	}
+	nodeFetches := make([][]merkle.NodeFetch, 0, len(req.TreeSizes)-1)
+	for i := 1; i < len(req.TreeSizes); i++ {
+		fetches, err := merkle.CalcConsistencyProofNodeAddresses(req.TreeSizes[i-1], req.TreeSizes[i], root.TreeSize, proofMaxBitLen)
+		if err != nil {
+			return err
+		}
+		nodeFetches = append(nodeFetches, fetches)
+	}
+
+	proofs, err := fetchNodesAndBuildProofs(t.withHeadCache(tx, req.LogId, root.TreeSize), tx.ReadRevision(), nodeFetches)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	for i := range proofs {
+		rsp := &trillian.GetConsistencyProofHistoryResponse{
+			FirstTreeSize:  req.TreeSizes[i],
+			SecondTreeSize: req.TreeSizes[i+1],
+			Proof:          &proofs[i],
+		}
+		if err := stream.Send(rsp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // GetLatestSignedLogRoot obtains the latest published tree root for the Merkle Tree that
-// underlies the log.
+// underlies the log, or its root of the requested tree size.
 func (t *TrillianLogRPCServer) GetLatestSignedLogRoot(ctx context.Context, req *trillian.GetLatestSignedLogRootRequest) (*trillian.GetLatestSignedLogRootResponse, error) {
 	ctx = util.NewLogContext(ctx, req.LogId)
-	tx, err := t.prepareReadOnlyStorageTx(ctx, req.LogId)
+	if req.Witnessed {
+		if req.TreeSize != 0 {
+			return nil, grpc.Errorf(codes.InvalidArgument, "TreeSize can't be set for witnessed requests")
+		}
+		return t.getLatestWitnessedSignedLogRoot(ctx, req)
+	}
+	tx, err := t.preparePinnedStorageTx(ctx, req.LogId, req.TreeSize)
 	if err != nil {
 		return nil, err
 	}
@@ -257,12 +492,91 @@ func (t *TrillianLogRPCServer) GetLatestSignedLogRoot(ctx context.Context, req *
 	if err != nil {
 		return nil, err
 	}
+	finalSize, finalized, err := tx.FinalizedTreeSize()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.commitAndLog(ctx, tx, "GetLatestSignedLogRoot"); err != nil {
+		return nil, err
+	}
+
+	return &trillian.GetLatestSignedLogRootResponse{
+		SignedLogRoot: &signedRoot,
+		Finalized:     finalized && signedRoot.TreeSize == finalSize,
+	}, nil
+}
+
+func (t *TrillianLogRPCServer) getLatestWitnessedSignedLogRoot(ctx context.Context, req *trillian.GetLatestSignedLogRootRequest) (*trillian.GetLatestSignedLogRootResponse, error) {
+	if len(t.witnesses) == 0 {
+		return nil, grpc.Errorf(codes.FailedPrecondition, "no witnesses are registered")
+	}
+	names := make([]string, 0, len(t.witnesses))
+	for name := range t.witnesses {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tx, err := t.prepareReadOnlyStorageTx(ctx, req.LogId)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+
+	signedRoot, sigs, err := tx.LatestWitnessedSignedLogRoot(names, t.witnessQuorum)
+	if err != nil {
+		return nil, err
+	}
 
 	if err := t.commitAndLog(ctx, tx, "GetLatestSignedLogRoot"); err != nil {
 		return nil, err
 	}
 
-	return &trillian.GetLatestSignedLogRootResponse{SignedLogRoot: &signedRoot}, nil
+	if len(sigs) == 0 {
+		return nil, grpc.Errorf(codes.NotFound, "no root has been cosigned by %d witnesses", t.witnessQuorum)
+	}
+	return &trillian.GetLatestSignedLogRootResponse{SignedLogRoot: &signedRoot, WitnessSignatures: sigs}, nil
+}
+
+// AddWitnessSignature stores a registered witness's countersignature over the latest
+// root of the log.
+func (t *TrillianLogRPCServer) AddWitnessSignature(ctx context.Context, req *trillian.AddWitnessSignatureRequest) (*trillian.AddWitnessSignatureResponse, error) {
+	ctx = util.NewLogContext(ctx, req.LogId)
+	if err := validateAddWitnessSignatureRequest(req); err != nil {
+		return nil, err
+	}
+	sig := req.WitnessSignature
+	pub, ok := t.witnesses[sig.WitnessName]
+	if !ok {
+		return nil, grpc.Errorf(codes.PermissionDenied, "unknown witness %q", sig.WitnessName)
+	}
+
+	tx, err := t.prepareStorageTx(ctx, req.LogId)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+
+	root, err := tx.LatestSignedLogRoot()
+	if err != nil {
+		return nil, err
+	}
+	if root.TreeSize != req.TreeSize || !bytes.Equal(root.RootHash, req.RootHash) {
+		return nil, grpc.Errorf(codes.FailedPrecondition, "root (%d, %x) isn't the latest root (%d, %x)", req.TreeSize, req.RootHash, root.TreeSize, root.RootHash)
+	}
+	if err := crypto.Verify(pub, crypto.HashLogRoot(root), sig.Signature); err != nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "invalid signature by witness %q: %v", sig.WitnessName, err)
+	}
+
+	if err := tx.StoreWitnessSignature(root.TreeRevision, sig); err != nil {
+		return nil, err
+	}
+
+	if err := t.commitAndLog(ctx, tx, "AddWitnessSignature"); err != nil {
+		return nil, err
+	}
+
+	return &trillian.AddWitnessSignatureResponse{}, nil
 }
 
 // GetSequencedLeafCount returns the number of leaves that have been integrated into the Merkle
@@ -293,17 +607,30 @@ func (t *TrillianLogRPCServer) GetSequencedLeafCount(ctx context.Context, req *t
 // can get ahead of this point. Not currently clear what component should own this state.
 func (t *TrillianLogRPCServer) GetLeavesByIndex(ctx context.Context, req *trillian.GetLeavesByIndexRequest) (*trillian.GetLeavesByIndexResponse, error) {
 	ctx = util.NewLogContext(ctx, req.LogId)
+	if err := checkLimit("len(leaf_index)", len(req.LeafIndex), t.limits.MaxLeavesByIndex); err != nil {
+		return nil, err
+	}
 	if !validateLeafIndices(req.LeafIndex) {
 		return &trillian.GetLeavesByIndexResponse{}, nil
 	}
 
-	tx, err := t.prepareReadOnlyStorageTx(ctx, req.LogId)
+	tx, err := t.preparePinnedStorageTx(ctx, req.LogId, req.TreeSize)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Close()
 
-	leaves, err := tx.GetLeavesByIndex(req.LeafIndex)
+	budget := t.newResponseBudget("GetLeavesByIndex", "request fewer leaves or use GetLeavesByIndexStream")
+	var leaves []*trillian.LogLeaf
+	err = t.readLeavesInChunks(tx, req.LogId, req.LeafIndex, func(chunk []*trillian.LogLeaf) error {
+		for _, leaf := range chunk {
+			if err := budget.charge(leaf); err != nil {
+				return err
+			}
+		}
+		leaves = append(leaves, chunk...)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -317,9 +644,58 @@ func (t *TrillianLogRPCServer) GetLeavesByIndex(ctx context.Context, req *trilli
 	}, nil
 }
 
+// GetLeavesByIndexStream returns the leaves GetLeavesByIndex would, split between as many
+// responses as it takes to keep each within the response size limit, so requests too
+// large for GetLeavesByIndex can still be served. The leaves are all read from one
+// snapshot of the log, and only a chunk of them is held in memory at a time.
+func (t *TrillianLogRPCServer) GetLeavesByIndexStream(req *trillian.GetLeavesByIndexRequest, stream trillian.TrillianLog_GetLeavesByIndexStreamServer) error {
+	ctx := util.NewLogContext(stream.Context(), req.LogId)
+	if err := checkLimit("len(leaf_index)", len(req.LeafIndex), t.limits.MaxLeavesByIndex); err != nil {
+		return err
+	}
+	if !validateLeafIndices(req.LeafIndex) {
+		return nil
+	}
+
+	tx, err := t.preparePinnedStorageTx(ctx, req.LogId, req.TreeSize)
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+
+	rsp := &trillian.GetLeavesByIndexResponse{}
+	var size int64
+	err = t.readLeavesInChunks(tx, req.LogId, req.LeafIndex, func(chunk []*trillian.LogLeaf) error {
+		for _, leaf := range chunk {
+			n := int64(proto.Size(leaf))
+			if limit := t.limits.MaxResponseBytes; limit > 0 && len(rsp.Leaves) > 0 && size+n > limit {
+				if err := stream.Send(rsp); err != nil {
+					return err
+				}
+				rsp, size = &trillian.GetLeavesByIndexResponse{}, 0
+			}
+			rsp.Leaves = append(rsp.Leaves, leaf)
+			size += n
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := t.commitAndLog(ctx, tx, "GetLeavesByIndexStream"); err != nil {
+		return err
+	}
+	if len(rsp.Leaves) == 0 {
+		return nil
+	}
+	return stream.Send(rsp)
+}
+
 // GetLeavesByHash obtains one or more leaves based on their tree hash. It is not possible
 // to fetch leaves that have been queued but not yet integrated. Logs may accept duplicate
-// entries so this may return more results than the number of hashes in the request.
+// entries so this may return more results than the number of hashes in the request, unless
+// the request asks for only the earliest leaf with each hash.
 func (t *TrillianLogRPCServer) GetLeavesByHash(ctx context.Context, req *trillian.GetLeavesByHashRequest) (*trillian.GetLeavesByHashResponse, error) {
 	return t.getLeavesByHashInternal(ctx, "GetLeavesByHash", req, func(tx storage.ReadOnlyLogTreeTX, hashes [][]byte, sequenceOrder bool) ([]*trillian.LogLeaf, error) {
 		return tx.GetLeavesByHash(hashes, sequenceOrder)
@@ -347,7 +723,7 @@ func (t *TrillianLogRPCServer) GetEntryAndProof(ctx context.Context, req *trilli
 		return nil, err
 	}
 
-	proof, err := getInclusionProofForLeafIndex(tx, req.TreeSize, req.LeafIndex, root.TreeSize)
+	proof, err := t.getInclusionProof(tx, req.LogId, req.TreeSize, req.LeafIndex, root.TreeSize)
 	if err != nil {
 		return nil, err
 	}
@@ -373,6 +749,76 @@ func (t *TrillianLogRPCServer) GetEntryAndProof(ctx context.Context, req *trilli
 	}, nil
 }
 
+// readLeavesInChunks passes the leaves at indices to f, in order. With a response size
+// limit they're read leafReadChunk at a time, so f can stop before they're all read.
+func (t *TrillianLogRPCServer) readLeavesInChunks(tx storage.ReadOnlyLogTreeTX, logID int64, indices []int64, f func([]*trillian.LogLeaf) error) error {
+	if t.limits.MaxResponseBytes <= 0 {
+		leaves, err := t.getLeavesByIndex(tx, logID, indices)
+		if err != nil {
+			return err
+		}
+		return f(leaves)
+	}
+	for len(indices) > 0 {
+		n := leafReadChunk
+		if n > len(indices) {
+			n = len(indices)
+		}
+		leaves, err := t.getLeavesByIndex(tx, logID, indices[:n])
+		if err != nil {
+			return err
+		}
+		if err := f(leaves); err != nil {
+			return err
+		}
+		indices = indices[n:]
+	}
+	return nil
+}
+
+// getLeavesByIndex reads the leaves at indices, in order, from the leaf cache if there
+// is one and otherwise from tx.
+func (t *TrillianLogRPCServer) getLeavesByIndex(tx storage.ReadOnlyLogTreeTX, logID int64, indices []int64) ([]*trillian.LogLeaf, error) {
+	if t.leafCache == nil {
+		return tx.GetLeavesByIndex(indices)
+	}
+
+	revision := tx.ReadRevision()
+	leaves := make([]*trillian.LogLeaf, len(indices))
+	var missing []int64
+	for i, index := range indices {
+		if leaf, ok := t.leafCache.get(leafKey{logID: logID, revision: revision, index: index}); ok {
+			leaves[i] = leaf
+			continue
+		}
+		missing = append(missing, index)
+	}
+	if len(missing) == 0 {
+		return leaves, nil
+	}
+
+	fetched, err := tx.GetLeavesByIndex(missing)
+	if err != nil {
+		return nil, err
+	}
+	byIndex := make(map[int64]*trillian.LogLeaf)
+	for _, leaf := range fetched {
+		byIndex[leaf.LeafIndex] = leaf
+		t.leafCache.put(leafKey{logID: logID, revision: revision, index: leaf.LeafIndex}, leaf)
+	}
+	for i, index := range indices {
+		if leaves[i] != nil {
+			continue
+		}
+		leaf, ok := byIndex[index]
+		if !ok {
+			return nil, grpc.Errorf(codes.Internal, "storage didn't return leaf %d", index)
+		}
+		leaves[i] = leaf
+	}
+	return leaves, nil
+}
+
 func (t *TrillianLogRPCServer) prepareStorageTx(ctx context.Context, treeID int64) (storage.LogTreeTX, error) {
 	tx, err := t.registry.LogStorage.BeginForTree(ctx, treeID)
 	if err != nil {
@@ -389,10 +835,39 @@ func (t *TrillianLogRPCServer) prepareReadOnlyStorageTx(ctx context.Context, tre
 	return tx, err
 }
 
+// preparePinnedStorageTx is prepareReadOnlyStorageTx for reads pinned to the log's root
+// of size treeSize, so they're consistent with other reads pinned to it. Zero reads from
+// the latest root as usual.
+func (t *TrillianLogRPCServer) preparePinnedStorageTx(ctx context.Context, treeID, treeSize int64) (storage.ReadOnlyLogTreeTX, error) {
+	if treeSize < 0 {
+		return nil, grpc.Errorf(codes.InvalidArgument, "TreeSize: %v, want >= 0", treeSize)
+	}
+	tx, err := t.prepareReadOnlyStorageTx(ctx, treeID)
+	if err != nil || treeSize == 0 {
+		return tx, err
+	}
+	pinned, err := storage.NewPinnedLogTreeTX(tx, treeSize)
+	if err != nil {
+		tx.Close()
+		return nil, err
+	}
+	return pinned, nil
+}
+
+// runInStorageTx runs f in a read-write transaction on treeID, retrying the whole
+// transaction if storage reports a transient failure such as a deadlock.
+func (t *TrillianLogRPCServer) runInStorageTx(ctx context.Context, treeID int64, op string, f func(tx storage.LogTreeTX) error) error {
+	err := storage.RunInLogTX(ctx, t.registry.LogStorage, treeID, f)
+	if err != nil {
+		logging.Warningf(ctx, "Transaction failed for %s: %v", op, err)
+	}
+	return err
+}
+
 func (t *TrillianLogRPCServer) commitAndLog(ctx context.Context, tx storage.ReadOnlyLogTreeTX, op string) error {
 	err := tx.Commit()
 	if err != nil {
-		glog.Warningf("%s: Commit failed for %s: %v", util.LogIDPrefix(ctx), op, err)
+		logging.Warningf(ctx, "Commit failed for %s: %v", op, err)
 	}
 	return err
 }
@@ -418,10 +893,66 @@ func validateLeafHashes(leafHashes [][]byte) bool {
 	return true
 }
 
+// earliestLeaves returns the leaf with the lowest index for each hash in leaves, in the
+// order the hashes first appear. If leaves are in index order they stay in index order.
+func earliestLeaves(leaves []*trillian.LogLeaf) []*trillian.LogLeaf {
+	earliest := make([]*trillian.LogLeaf, 0, len(leaves))
+	positions := make(map[string]int)
+	for _, leaf := range leaves {
+		i, ok := positions[string(leaf.MerkleLeafHash)]
+		if !ok {
+			positions[string(leaf.MerkleLeafHash)] = len(earliest)
+			earliest = append(earliest, leaf)
+			continue
+		}
+		if leaf.LeafIndex < earliest[i].LeafIndex {
+			earliest[i] = leaf
+		}
+	}
+	return earliest
+}
+
+// getInclusionProof returns the proof of leafIndex in the tree at size snapshot, from the
+// proof cache if there is one and otherwise built from the nodes in tx. treeSize is the
+// size of the tree tx reads from.
+func (t *TrillianLogRPCServer) getInclusionProof(tx storage.ReadOnlyLogTreeTX, logID, snapshot, leafIndex, treeSize int64) (trillian.Proof, error) {
+	if t.proofCache == nil {
+		return getInclusionProofForLeafIndex(t.withHeadCache(tx, logID, treeSize), snapshot, leafIndex, treeSize)
+	}
+
+	key := proofKey{logID: logID, treeSize: snapshot, leafIndex: leafIndex}
+	// The cache may hold proofs for sizes this tx's tree hasn't reached, if they were built
+	// from a snapshot of a replica which is further ahead. Those are refused as before.
+	if snapshot <= treeSize {
+		if proof, ok := t.proofCache.get(key); ok {
+			return proof, nil
+		}
+	}
+	proof, err := getInclusionProofForLeafIndex(t.withHeadCache(tx, logID, treeSize), snapshot, leafIndex, treeSize)
+	if err != nil {
+		return trillian.Proof{}, err
+	}
+	t.proofCache.put(key, proof)
+	return proof, nil
+}
+
+// withHeadCache returns tx reading Merkle nodes through the head cache, after catching it
+// up with the tree of size treeSize tx reads from, or just tx if there isn't one.
+func (t *TrillianLogRPCServer) withHeadCache(tx storage.ReadOnlyLogTreeTX, logID, treeSize int64) storage.ReadOnlyLogTreeTX {
+	if t.headCache == nil {
+		return tx
+	}
+	t.headCache.catchUp(tx, logID, treeSize)
+	return headCacheTX{ReadOnlyLogTreeTX: tx, cache: t.headCache, logID: logID, treeSize: treeSize}
+}
+
 // getInclusionProofForLeafIndex is used by multiple handlers. It does the storage fetching
 // and makes additional checks on the returned proof. Returns a Proof suitable for inclusion in
 // an RPC response
 func getInclusionProofForLeafIndex(tx storage.ReadOnlyLogTreeTX, snapshot, leafIndex, treeSize int64) (trillian.Proof, error) {
+	if snapshot > treeSize {
+		return trillian.Proof{}, grpc.Errorf(codes.OutOfRange, "tree size %d is beyond the latest tree size %d", snapshot, treeSize)
+	}
 	// We have the tree size and leaf index so we know the nodes that we need to serve the proof
 	proofNodeIDs, err := merkle.CalcInclusionProofNodeAddresses(snapshot, leafIndex, treeSize, proofMaxBitLen)
 	if err != nil {
@@ -439,22 +970,87 @@ func (t *TrillianLogRPCServer) getLeavesByHashInternal(ctx context.Context, desc
 		return nil, grpc.Errorf(codes.FailedPrecondition, "Invalid leaf hash")
 	}
 
-	tx, err := t.prepareReadOnlyStorageTx(ctx, req.LogId)
+	logIDs := []int64{req.LogId}
+	if t.shards != nil {
+		var err error
+		if logIDs, err = t.shards.newestFirst(ctx, req.LogId); err != nil {
+			return nil, err
+		}
+	}
+	// A tree size only identifies a root of one log, so reads of shard sets can't be pinned.
+	if req.TreeSize != 0 && (len(logIDs) != 1 || logIDs[0] != req.LogId) {
+		return nil, grpc.Errorf(codes.InvalidArgument, "TreeSize can't be set for sharded logs")
+	}
+	// Sharded logs are searched newest shard first, each for the hashes not found in a
+	// newer shard.
+	rsp := &trillian.GetLeavesByHashResponse{LogId: req.LogId}
+	var leafLogIDs []int64
+	hashes := req.LeafHash
+	budget := t.newResponseBudget(desc, "request fewer hashes")
+	for _, logID := range logIDs {
+		leaves, err := t.fetchLeavesByHash(util.NewLogContext(ctx, logID), desc, logID, req, hashes, budget, fetchFunc)
+		if err != nil {
+			return nil, err
+		}
+		if len(leaves) == 0 {
+			continue
+		}
+		if len(rsp.Leaves) == 0 {
+			rsp.LogId = logID
+		}
+		rsp.Leaves = append(rsp.Leaves, leaves...)
+		for range leaves {
+			leafLogIDs = append(leafLogIDs, logID)
+		}
+		if hashes = unfoundHashes(hashes, leaves); len(hashes) == 0 {
+			break
+		}
+	}
+	if len(leafLogIDs) > 0 && leafLogIDs[len(leafLogIDs)-1] != rsp.LogId {
+		rsp.LeafLogIds = leafLogIDs
+	}
+	return rsp, nil
+}
+
+// unfoundHashes returns those of hashes which aren't the Merkle leaf hash of any of leaves.
+func unfoundHashes(hashes [][]byte, leaves []*trillian.LogLeaf) [][]byte {
+	found := make(map[string]bool)
+	for _, leaf := range leaves {
+		found[string(leaf.MerkleLeafHash)] = true
+	}
+	var unfound [][]byte
+	for _, hash := range hashes {
+		if !found[string(hash)] {
+			unfound = append(unfound, hash)
+		}
+	}
+	return unfound
+}
+
+// fetchLeavesByHash fetches the leaves of logID with the given hashes, charging them to
+// budget.
+func (t *TrillianLogRPCServer) fetchLeavesByHash(ctx context.Context, desc string, logID int64, req *trillian.GetLeavesByHashRequest, hashes [][]byte, budget *responseBudget, fetchFunc func(storage.ReadOnlyLogTreeTX, [][]byte, bool) ([]*trillian.LogLeaf, error)) ([]*trillian.LogLeaf, error) {
+	tx, err := t.preparePinnedStorageTx(ctx, logID, req.TreeSize)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Close()
 
-	leaves, err := fetchFunc(tx, req.LeafHash, req.OrderBySequence)
+	leaves, err := fetchFunc(tx, hashes, req.OrderBySequence)
 	if err != nil {
 		return nil, err
 	}
+	if req.EarliestOnly {
+		leaves = earliestLeaves(leaves)
+	}
+	for _, leaf := range leaves {
+		if err := budget.charge(leaf); err != nil {
+			return nil, err
+		}
+	}
 
 	if err := t.commitAndLog(ctx, tx, desc); err != nil {
 		return nil, err
 	}
-
-	return &trillian.GetLeavesByHashResponse{
-		Leaves: leaves,
-	}, nil
+	return leaves, nil
 }
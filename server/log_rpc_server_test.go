@@ -16,14 +16,20 @@ package server
 
 import (
 	"context"
+	gocrypto "crypto"
 	"errors"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/golang/protobuf/proto"
 	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/crypto/keys"
+	"github.com/google/trillian/crypto/sigpb"
+	te "github.com/google/trillian/errors"
 	"github.com/google/trillian/extension"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/testonly"
@@ -49,6 +55,10 @@ var (
 	queueRequest0Log2 = trillian.QueueLeavesRequest{LogId: logID2, Leaves: []*trillian.LogLeaf{leaf1}}
 	queueRequestEmpty = trillian.QueueLeavesRequest{LogId: logID1, Leaves: []*trillian.LogLeaf{}}
 
+	addSequencedRequest0     = trillian.AddSequencedLeavesRequest{LogId: logID1, Leaves: []*trillian.LogLeaf{leaf1}}
+	addSequencedRequest0Log2 = trillian.AddSequencedLeavesRequest{LogId: logID2, Leaves: []*trillian.LogLeaf{leaf1}}
+	addSequencedRequestGap   = trillian.AddSequencedLeavesRequest{LogId: logID1, Leaves: []*trillian.LogLeaf{leaf1, leaf3}}
+
 	getLogRootRequest1 = trillian.GetLatestSignedLogRootRequest{LogId: logID1}
 	getLogRootRequest2 = trillian.GetLatestSignedLogRootRequest{LogId: logID2}
 	revision1          = int64(5)
@@ -214,6 +224,56 @@ func TestGetLeavesByIndexMultiple(t *testing.T) {
 	}
 }
 
+func TestGetLeavesByIndexCached(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	registry := extension.Registry{
+		LogStorage: mockStorage,
+	}
+	server := NewTrillianLogRPCServer(registry, fakeTimeSource)
+	server.SetLeafCacheSize(1 << 20)
+
+	tests := []struct {
+		desc      string
+		revision  int64
+		indices   []int64
+		wantFetch []int64
+		fetched   []*trillian.LogLeaf
+	}{
+		// Storage doesn't return leaves in any particular order.
+		{desc: "empty", revision: 5, indices: []int64{1, 3}, wantFetch: []int64{1, 3}, fetched: []*trillian.LogLeaf{leaf3, leaf1}},
+		{desc: "cached", revision: 5, indices: []int64{3, 1}},
+		{desc: "newRevision", revision: 6, indices: []int64{1, 3}, wantFetch: []int64{1, 3}, fetched: []*trillian.LogLeaf{leaf1, leaf3}},
+	}
+
+	for _, test := range tests {
+		mockTx := storage.NewMockLogTreeTX(ctrl)
+		mockStorage.EXPECT().SnapshotForTree(gomock.Any(), logID1).Return(mockTx, nil)
+		mockTx.EXPECT().ReadRevision().Return(test.revision)
+		if test.wantFetch != nil {
+			mockTx.EXPECT().GetLeavesByIndex(test.wantFetch).Return(test.fetched, nil)
+		}
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Close().Return(nil)
+		mockTx.EXPECT().IsOpen().AnyTimes().Return(false)
+
+		resp, err := server.GetLeavesByIndex(context.Background(), &trillian.GetLeavesByIndexRequest{LogId: logID1, LeafIndex: test.indices})
+		if err != nil {
+			t.Fatalf("%v: GetLeavesByIndex()=%v", test.desc, err)
+		}
+		if got, want := len(resp.Leaves), len(test.indices); got != want {
+			t.Fatalf("%v: GetLeavesByIndex() returned %d leaves, want %d", test.desc, got, want)
+		}
+		for i, leaf := range resp.Leaves {
+			if got, want := leaf.LeafIndex, test.indices[i]; got != want {
+				t.Errorf("%v: GetLeavesByIndex().Leaves[%d].LeafIndex=%d, want %d", test.desc, i, got, want)
+			}
+		}
+	}
+}
+
 func TestQueueLeavesStorageError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -396,6 +456,308 @@ func TestQueueLeavesBeginFailsCausesError(t *testing.T) {
 	test.executeBeginFailsTest(t, queueRequest0.LogId)
 }
 
+func TestQueueLeavesBacklogLimits(t *testing.T) {
+	tests := []struct {
+		desc         string
+		leaves       int64
+		oldest       time.Time
+		wantRejected bool
+	}{
+		{desc: "empty"},
+		{desc: "underLimits", leaves: 99, oldest: fakeTime.Add(-time.Minute)},
+		{desc: "tooManyLeaves", leaves: 100, oldest: fakeTime, wantRejected: true},
+		{desc: "tooOld", leaves: 1, oldest: fakeTime.Add(-time.Hour), wantRejected: true},
+	}
+
+	ctx := context.Background()
+	for _, test := range tests {
+		func() {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockStorage := storage.NewMockLogStorage(ctrl)
+			mockSnapshot := storage.NewMockReadOnlyLogTreeTX(ctrl)
+			mockStorage.EXPECT().SnapshotForTree(gomock.Any(), queueRequest0.LogId).Return(mockSnapshot, nil)
+			mockSnapshot.EXPECT().GetUnsequencedStats().Return(test.leaves, test.oldest, nil)
+			mockSnapshot.EXPECT().Commit().Return(nil)
+			mockSnapshot.EXPECT().Close().Return(nil)
+			if !test.wantRejected {
+				mockTx := storage.NewMockLogTreeTX(ctrl)
+				mockStorage.EXPECT().BeginForTree(gomock.Any(), queueRequest0.LogId).Return(mockTx, nil)
+				mockTx.EXPECT().QueueLeaves([]*trillian.LogLeaf{leaf1}, fakeTime).Return([]*trillian.LogLeaf{nil}, nil)
+				mockTx.EXPECT().Commit().Return(nil)
+				mockTx.EXPECT().Close().Return(nil)
+				mockTx.EXPECT().IsOpen().AnyTimes().Return(false)
+			}
+
+			registry := extension.Registry{
+				LogStorage: mockStorage,
+			}
+			server := NewTrillianLogRPCServer(registry, fakeTimeSource)
+			server.SetBacklogLimits(BacklogLimits{MaxLeaves: 100, MaxAge: 10 * time.Minute, RefreshInterval: time.Second})
+
+			_, err := server.QueueLeaves(ctx, &queueRequest0)
+			switch {
+			case test.wantRejected && grpc.Code(err) != codes.ResourceExhausted:
+				t.Errorf("%v: QueueLeaves() = (_, %v), want %v", test.desc, err, codes.ResourceExhausted)
+			case !test.wantRejected && err != nil:
+				t.Errorf("%v: QueueLeaves() = (_, %v), want no error", test.desc, err)
+			}
+		}()
+	}
+}
+
+func TestQueueLeavesSharded(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTreeTX(ctrl)
+	mockStorage.EXPECT().BeginForTree(gomock.Any(), int64(102)).Return(mockTx, nil)
+	mockTx.EXPECT().QueueLeaves([]*trillian.LogLeaf{leaf1}, fakeTime).Return([]*trillian.LogLeaf{nil}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+	mockTx.EXPECT().Close().Return(nil)
+	mockTx.EXPECT().IsOpen().AnyTimes().Return(false)
+
+	registry := extension.Registry{
+		AdminStorage: newShardAdminStorage(ctrl, shardSet(), 1),
+		LogStorage:   mockStorage,
+	}
+	server := NewTrillianLogRPCServer(registry, fakeTimeSource)
+	server.EnableSharding(time.Minute)
+
+	// Leaves queued to an old shard go to the active one.
+	rsp, err := server.QueueLeaf(context.Background(), &trillian.QueueLeafRequest{LogId: 101, Leaf: leaf1})
+	if err != nil {
+		t.Fatalf("QueueLeaf()=_, %v, want nil", err)
+	}
+	if got, want := rsp.LogId, int64(102); got != want {
+		t.Errorf("QueueLeaf().LogId=%d, want %d", got, want)
+	}
+}
+
+func TestQueueLeafMergeDelay(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTreeTX(ctrl)
+	mockStorage.EXPECT().BeginForTree(gomock.Any(), logID1).Times(2).Return(mockTx, nil)
+	mockTx.EXPECT().QueueLeaves([]*trillian.LogLeaf{leaf1}, fakeTime).Times(2).Return([]*trillian.LogLeaf{nil}, nil)
+	mockTx.EXPECT().Commit().Times(2).Return(nil)
+	mockTx.EXPECT().Close().Times(2).Return(nil)
+	mockTx.EXPECT().IsOpen().AnyTimes().Return(false)
+
+	// The tree is only read once, the second request is within the refresh interval.
+	mockAdmin := storage.NewMockAdminStorage(ctrl)
+	mockAdminTx := storage.NewMockReadOnlyAdminTX(ctrl)
+	mockAdmin.EXPECT().Snapshot(gomock.Any()).Return(mockAdminTx, nil)
+	mockAdminTx.EXPECT().GetTree(gomock.Any(), logID1).Return(&trillian.Tree{TreeId: logID1, MaxMergeDelaySeconds: 86400}, nil)
+	mockAdminTx.EXPECT().Commit().Return(nil)
+	mockAdminTx.EXPECT().Close().Return(nil)
+
+	registry := extension.Registry{
+		AdminStorage: mockAdmin,
+		LogStorage:   mockStorage,
+	}
+	server := NewTrillianLogRPCServer(registry, fakeTimeSource)
+	server.EnableMergeDelays(time.Minute)
+
+	for i := 0; i < 2; i++ {
+		rsp, err := server.QueueLeaf(context.Background(), &trillian.QueueLeafRequest{LogId: logID1, Leaf: leaf1})
+		if err != nil {
+			t.Fatalf("QueueLeaf()=_, %v, want nil", err)
+		}
+		if got, want := rsp.QueueTimestampNanos, fakeTime.UnixNano(); got != want {
+			t.Errorf("QueueLeaf().QueueTimestampNanos=%d, want %d", got, want)
+		}
+		if got, want := rsp.MaxMergeDelaySeconds, int32(86400); got != want {
+			t.Errorf("QueueLeaf().MaxMergeDelaySeconds=%d, want %d", got, want)
+		}
+	}
+}
+
+func TestRequestLimits(t *testing.T) {
+	limits := RequestLimits{MaxQueueLeaves: 1, MaxLeavesByIndex: 1, MaxProofs: 1}
+	ctx := context.Background()
+
+	tests := []struct {
+		desc string
+		// setupStorage sets up the expected storage calls, if the request reaches storage.
+		setupStorage func(s *storage.MockLogStorage, tx *storage.MockLogTreeTX)
+		call         func(s *TrillianLogRPCServer) error
+		wantCode     codes.Code
+	}{
+		{
+			desc: "queueLeavesUnderLimit",
+			setupStorage: func(s *storage.MockLogStorage, tx *storage.MockLogTreeTX) {
+				s.EXPECT().BeginForTree(gomock.Any(), logID1).Return(tx, nil)
+				tx.EXPECT().QueueLeaves([]*trillian.LogLeaf{leaf1}, fakeTime).Return([]*trillian.LogLeaf{nil}, nil)
+				tx.EXPECT().Commit().Return(nil)
+				tx.EXPECT().Close().Return(nil)
+				tx.EXPECT().IsOpen().AnyTimes().Return(false)
+			},
+			call: func(s *TrillianLogRPCServer) error {
+				_, err := s.QueueLeaves(ctx, &trillian.QueueLeavesRequest{LogId: logID1, Leaves: []*trillian.LogLeaf{leaf1}})
+				return err
+			},
+		},
+		{
+			desc: "queueLeavesOverLimit",
+			call: func(s *TrillianLogRPCServer) error {
+				_, err := s.QueueLeaves(ctx, &trillian.QueueLeavesRequest{LogId: logID1, Leaves: []*trillian.LogLeaf{leaf1, leaf3}})
+				return err
+			},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			desc: "addSequencedLeavesOverLimit",
+			call: func(s *TrillianLogRPCServer) error {
+				_, err := s.AddSequencedLeaves(ctx, &trillian.AddSequencedLeavesRequest{LogId: logID1, Leaves: []*trillian.LogLeaf{
+					{LeafIndex: 0, LeafValue: leaf1Data},
+					{LeafIndex: 1, LeafValue: leaf3Data},
+				}})
+				return err
+			},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			desc: "getLeavesByIndexOverLimit",
+			call: func(s *TrillianLogRPCServer) error {
+				_, err := s.GetLeavesByIndex(ctx, &leaf03Request)
+				return err
+			},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			desc: "getInclusionProofByHashOverLimit",
+			setupStorage: func(s *storage.MockLogStorage, tx *storage.MockLogTreeTX) {
+				s.EXPECT().SnapshotForTree(gomock.Any(), logID1).Return(tx, nil)
+				tx.EXPECT().GetLeavesByHash([][]byte{[]byte("ahash")}, false).Return([]*trillian.LogLeaf{{LeafIndex: 2}, {LeafIndex: 4}}, nil)
+				tx.EXPECT().Close().Return(nil)
+			},
+			call: func(s *TrillianLogRPCServer) error {
+				_, err := s.GetInclusionProofByHash(ctx, &getInclusionProofByHashRequest7)
+				return err
+			},
+			wantCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, test := range tests {
+		func() {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockStorage := storage.NewMockLogStorage(ctrl)
+			if test.setupStorage != nil {
+				test.setupStorage(mockStorage, storage.NewMockLogTreeTX(ctrl))
+			}
+			server := NewTrillianLogRPCServer(extension.Registry{LogStorage: mockStorage}, fakeTimeSource)
+			server.SetRequestLimits(limits)
+
+			if err := test.call(server); grpc.Code(err) != test.wantCode {
+				t.Errorf("%v: got error %v, want code %v", test.desc, err, test.wantCode)
+			}
+		}()
+	}
+}
+
+func TestAddSequencedLeavesStorageError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	test := newParameterizedTest(ctrl, "AddSequencedLeaves", readWrite,
+		func(t *storage.MockLogTreeTX) {
+			t.EXPECT().AddSequencedLeaves([]*trillian.LogLeaf{leaf1}).Return(errors.New("STORAGE"))
+		},
+		func(s *TrillianLogRPCServer) error {
+			_, err := s.AddSequencedLeaves(context.Background(), &addSequencedRequest0)
+			return err
+		})
+
+	test.executeStorageFailureTest(t, addSequencedRequest0.LogId)
+}
+
+func TestAddSequencedLeavesInvalidLogId(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	test := newParameterizedTest(ctrl, "AddSequencedLeaves", readWrite,
+		func(t *storage.MockLogTreeTX) {},
+		func(s *TrillianLogRPCServer) error {
+			_, err := s.AddSequencedLeaves(context.Background(), &addSequencedRequest0Log2)
+			return err
+		})
+
+	test.executeInvalidLogIDTest(t, false /* snapshot */)
+}
+
+func TestAddSequencedLeavesCommitFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	test := newParameterizedTest(ctrl, "AddSequencedLeaves", readWrite,
+		func(t *storage.MockLogTreeTX) {
+			t.EXPECT().AddSequencedLeaves([]*trillian.LogLeaf{leaf1}).Return(nil)
+		},
+		func(s *TrillianLogRPCServer) error {
+			_, err := s.AddSequencedLeaves(context.Background(), &addSequencedRequest0)
+			return err
+		})
+
+	test.executeCommitFailsTest(t, addSequencedRequest0.LogId)
+}
+
+func TestAddSequencedLeavesBeginFailsCausesError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	test := newParameterizedTest(ctrl, "AddSequencedLeaves", readWrite,
+		func(t *storage.MockLogTreeTX) {},
+		func(s *TrillianLogRPCServer) error {
+			_, err := s.AddSequencedLeaves(context.Background(), &addSequencedRequest0)
+			return err
+		})
+
+	test.executeBeginFailsTest(t, addSequencedRequest0.LogId)
+}
+
+func TestAddSequencedLeavesRejectsGaps(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	registry := extension.Registry{}
+	server := NewTrillianLogRPCServer(registry, fakeTimeSource)
+
+	if _, err := server.AddSequencedLeaves(context.Background(), &addSequencedRequestGap); grpc.Code(err) != codes.InvalidArgument {
+		t.Fatalf("AddSequencedLeaves() with non-contiguous indices = %v, want InvalidArgument", err)
+	}
+}
+
+func TestAddSequencedLeaves(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTreeTX(ctrl)
+	mockStorage.EXPECT().BeginForTree(gomock.Any(), addSequencedRequest0.LogId).Return(mockTx, nil)
+	mockTx.EXPECT().AddSequencedLeaves([]*trillian.LogLeaf{leaf1}).Return(nil)
+	mockTx.EXPECT().Commit().Return(nil)
+	mockTx.EXPECT().Close().Return(nil)
+	mockTx.EXPECT().IsOpen().AnyTimes().Return(false)
+
+	registry := extension.Registry{
+		LogStorage: mockStorage,
+	}
+	server := NewTrillianLogRPCServer(registry, fakeTimeSource)
+
+	if _, err := server.AddSequencedLeaves(ctx, &addSequencedRequest0); err != nil {
+		t.Fatalf("AddSequencedLeaves() = %v, want nil", err)
+	}
+}
+
 func TestGetLatestSignedLogRootBeginFails(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -437,6 +799,7 @@ func TestGetLatestSignedLogRootCommitFails(t *testing.T) {
 	test := newParameterizedTest(ctrl, "LatestSignedLogRoot", readOnly,
 		func(t *storage.MockLogTreeTX) {
 			t.EXPECT().LatestSignedLogRoot().Return(trillian.SignedLogRoot{}, nil)
+			t.EXPECT().FinalizedTreeSize().Return(int64(0), false, nil)
 		},
 		func(s *TrillianLogRPCServer) error {
 			_, err := s.GetLatestSignedLogRoot(context.Background(), &getLogRootRequest1)
@@ -461,28 +824,164 @@ func TestGetLatestSignedLogRootInvalidLogId(t *testing.T) {
 }
 
 func TestGetLatestSignedLogRoot(t *testing.T) {
-	ctrl := gomock.NewController(t)
-	defer ctrl.Finish()
+	tests := []struct {
+		desc          string
+		finalSize     int64
+		finalized     bool
+		wantFinalized bool
+	}{
+		{desc: "active"},
+		{desc: "finalized", finalSize: signedRoot1.TreeSize, finalized: true, wantFinalized: true},
+		// The final root hasn't been replicated yet.
+		{desc: "finalizedLater", finalSize: signedRoot1.TreeSize + 1, finalized: true},
+	}
+	for _, test := range tests {
+		ctrl := gomock.NewController(t)
 
-	mockStorage := storage.NewMockLogStorage(ctrl)
-	mockTx := storage.NewMockLogTreeTX(ctrl)
-	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), getLogRootRequest1.LogId).Return(mockTx, nil)
-	mockTx.EXPECT().LatestSignedLogRoot().Return(signedRoot1, nil)
-	mockTx.EXPECT().Commit().Return(nil)
-	mockTx.EXPECT().Close().Return(nil)
+		mockStorage := storage.NewMockLogStorage(ctrl)
+		mockTx := storage.NewMockLogTreeTX(ctrl)
+		mockStorage.EXPECT().SnapshotForTree(gomock.Any(), getLogRootRequest1.LogId).Return(mockTx, nil)
+		mockTx.EXPECT().LatestSignedLogRoot().Return(signedRoot1, nil)
+		mockTx.EXPECT().FinalizedTreeSize().Return(test.finalSize, test.finalized, nil)
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Close().Return(nil)
 
-	registry := extension.Registry{
-		LogStorage: mockStorage,
+		registry := extension.Registry{
+			LogStorage: mockStorage,
+		}
+		server := NewTrillianLogRPCServer(registry, fakeTimeSource)
+
+		resp, err := server.GetLatestSignedLogRoot(context.Background(), &getLogRootRequest1)
+		if err != nil {
+			t.Fatalf("%v: Failed to get log root: %v", test.desc, err)
+		}
+
+		if !proto.Equal(&signedRoot1, resp.SignedLogRoot) {
+			t.Fatalf("%v: Log root proto mismatch:\n%v\n%v", test.desc, signedRoot1, resp.SignedLogRoot)
+		}
+		if got, want := resp.Finalized, test.wantFinalized; got != want {
+			t.Errorf("%v: GetLatestSignedLogRoot().Finalized=%v, want %v", test.desc, got, want)
+		}
+		ctrl.Finish()
 	}
-	server := NewTrillianLogRPCServer(registry, fakeTimeSource)
+}
+
+func TestGetLatestSignedLogRootWitnessed(t *testing.T) {
+	witnessSig := &trillian.WitnessSignature{WitnessName: "a", Signature: &sigpb.DigitallySigned{Signature: []byte("sig")}}
+	tests := []struct {
+		desc      string
+		witnesses bool
+		sigs      []*trillian.WitnessSignature
+		wantCode  codes.Code
+	}{
+		{desc: "noWitnesses", wantCode: codes.FailedPrecondition},
+		{desc: "noQuorum", witnesses: true, wantCode: codes.NotFound},
+		{desc: "quorum", witnesses: true, sigs: []*trillian.WitnessSignature{witnessSig}, wantCode: codes.OK},
+	}
+
+	for _, test := range tests {
+		func() {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockStorage := storage.NewMockLogStorage(ctrl)
+			if test.witnesses {
+				mockTx := storage.NewMockReadOnlyLogTreeTX(ctrl)
+				mockStorage.EXPECT().SnapshotForTree(gomock.Any(), logID1).Return(mockTx, nil)
+				root := trillian.SignedLogRoot{}
+				if len(test.sigs) > 0 {
+					root = signedRoot1
+				}
+				mockTx.EXPECT().LatestWitnessedSignedLogRoot([]string{"a", "b"}, 1).Return(root, test.sigs, nil)
+				mockTx.EXPECT().Commit().Return(nil)
+				mockTx.EXPECT().Close().Return(nil)
+			}
+
+			server := NewTrillianLogRPCServer(extension.Registry{LogStorage: mockStorage}, fakeTimeSource)
+			if test.witnesses {
+				server.SetWitnesses(map[string]gocrypto.PublicKey{"b": nil, "a": nil}, 1)
+			}
 
-	resp, err := server.GetLatestSignedLogRoot(context.Background(), &getLogRootRequest1)
+			resp, err := server.GetLatestSignedLogRoot(context.Background(), &trillian.GetLatestSignedLogRootRequest{LogId: logID1, Witnessed: true})
+			if got := grpc.Code(err); got != test.wantCode {
+				t.Fatalf("%v: GetLatestSignedLogRoot()=(_, %v), want %v", test.desc, err, test.wantCode)
+			}
+			if err != nil {
+				return
+			}
+			if !proto.Equal(&signedRoot1, resp.SignedLogRoot) {
+				t.Errorf("%v: GetLatestSignedLogRoot().SignedLogRoot=%v, want %v", test.desc, resp.SignedLogRoot, signedRoot1)
+			}
+			if !reflect.DeepEqual(resp.WitnessSignatures, test.sigs) {
+				t.Errorf("%v: GetLatestSignedLogRoot().WitnessSignatures=%v, want %v", test.desc, resp.WitnessSignatures, test.sigs)
+			}
+		}()
+	}
+}
+
+func TestAddWitnessSignature(t *testing.T) {
+	key, err := keys.GenerateKey(sigpb.DigitallySigned_ECDSA)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	sig, err := crypto.NewSigner(key).Sign(crypto.HashLogRoot(signedRoot1))
 	if err != nil {
-		t.Fatalf("Failed to get log root: %v", err)
+		t.Fatalf("Failed to sign root: %v", err)
+	}
+	otherRoot := signedRoot1
+	otherRoot.TreeSize++
+	otherSig, err := crypto.NewSigner(key).Sign(crypto.HashLogRoot(otherRoot))
+	if err != nil {
+		t.Fatalf("Failed to sign root: %v", err)
 	}
 
-	if !proto.Equal(&signedRoot1, resp.SignedLogRoot) {
-		t.Fatalf("Log root proto mismatch:\n%v\n%v", signedRoot1, resp.SignedLogRoot)
+	req := func(name string, treeSize int64, sig *sigpb.DigitallySigned) *trillian.AddWitnessSignatureRequest {
+		return &trillian.AddWitnessSignatureRequest{
+			LogId:            logID1,
+			TreeSize:         treeSize,
+			RootHash:         signedRoot1.RootHash,
+			WitnessSignature: &trillian.WitnessSignature{WitnessName: name, Signature: sig},
+		}
+	}
+	tests := []struct {
+		desc      string
+		req       *trillian.AddWitnessSignatureRequest
+		wantTx    bool
+		wantStore bool
+		wantCode  codes.Code
+	}{
+		{desc: "valid", req: req("a", signedRoot1.TreeSize, sig), wantTx: true, wantStore: true, wantCode: codes.OK},
+		{desc: "noSignature", req: req("a", signedRoot1.TreeSize, nil), wantCode: codes.InvalidArgument},
+		{desc: "unknownWitness", req: req("b", signedRoot1.TreeSize, sig), wantCode: codes.PermissionDenied},
+		{desc: "notLatest", req: req("a", otherRoot.TreeSize, otherSig), wantTx: true, wantCode: codes.FailedPrecondition},
+		{desc: "badSignature", req: req("a", signedRoot1.TreeSize, otherSig), wantTx: true, wantCode: codes.InvalidArgument},
+	}
+
+	for _, test := range tests {
+		func() {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockStorage := storage.NewMockLogStorage(ctrl)
+			if test.wantTx {
+				mockTx := storage.NewMockLogTreeTX(ctrl)
+				mockStorage.EXPECT().BeginForTree(gomock.Any(), logID1).Return(mockTx, nil)
+				mockTx.EXPECT().LatestSignedLogRoot().Return(signedRoot1, nil)
+				if test.wantStore {
+					mockTx.EXPECT().StoreWitnessSignature(signedRoot1.TreeRevision, test.req.WitnessSignature).Return(nil)
+					mockTx.EXPECT().Commit().Return(nil)
+				}
+				mockTx.EXPECT().Close().Return(nil)
+			}
+
+			server := NewTrillianLogRPCServer(extension.Registry{LogStorage: mockStorage}, fakeTimeSource)
+			server.SetWitnesses(map[string]gocrypto.PublicKey{"a": key.Public()}, 1)
+
+			_, err := server.AddWitnessSignature(context.Background(), test.req)
+			if got := grpc.Code(err); got != test.wantCode {
+				t.Errorf("%v: AddWitnessSignature()=(_, %v), want %v", test.desc, err, test.wantCode)
+			}
+		}()
 	}
 }
 
@@ -602,6 +1101,118 @@ func TestGetLeavesByHash(t *testing.T) {
 	}
 }
 
+func TestGetLeavesByHashEarliestOnly(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	dup1 := &trillian.LogLeaf{LeafIndex: 9, MerkleLeafHash: leaf1.MerkleLeafHash, LeafValue: leaf1Data}
+	dup3 := &trillian.LogLeaf{LeafIndex: 2, MerkleLeafHash: leaf3.MerkleLeafHash, LeafValue: leaf3Data}
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTreeTX(ctrl)
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), logID1).Return(mockTx, nil)
+	mockTx.EXPECT().GetLeavesByHash([][]byte{leaf1.MerkleLeafHash, leaf3.MerkleLeafHash}, false).Return([]*trillian.LogLeaf{dup1, leaf3, leaf1, dup3}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+	mockTx.EXPECT().Close().Return(nil)
+
+	server := NewTrillianLogRPCServer(extension.Registry{LogStorage: mockStorage}, fakeTimeSource)
+
+	req := &trillian.GetLeavesByHashRequest{LogId: logID1, LeafHash: [][]byte{leaf1.MerkleLeafHash, leaf3.MerkleLeafHash}, EarliestOnly: true}
+	resp, err := server.GetLeavesByHash(context.Background(), req)
+	if err != nil {
+		t.Fatalf("GetLeavesByHash()=%v", err)
+	}
+	if len(resp.Leaves) != 2 || !proto.Equal(resp.Leaves[0], leaf1) || !proto.Equal(resp.Leaves[1], dup3) {
+		t.Errorf("GetLeavesByHash().Leaves=%v, want %v and %v", resp.Leaves, leaf1, dup3)
+	}
+}
+
+func TestGetLeavesByHashSharded(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	// The active shard doesn't have the leaves, the one before it does.
+	for _, shard := range []struct {
+		logID  int64
+		leaves []*trillian.LogLeaf
+	}{
+		{logID: 102},
+		{logID: 101, leaves: []*trillian.LogLeaf{leaf1}},
+	} {
+		mockTx := storage.NewMockLogTreeTX(ctrl)
+		mockStorage.EXPECT().SnapshotForTree(gomock.Any(), shard.logID).Return(mockTx, nil)
+		mockTx.EXPECT().GetLeavesByHash(getByHashRequest1.LeafHash, false).Return(shard.leaves, nil)
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Close().Return(nil)
+	}
+
+	registry := extension.Registry{
+		AdminStorage: newShardAdminStorage(ctrl, shardSet(), 1),
+		LogStorage:   mockStorage,
+	}
+	server := NewTrillianLogRPCServer(registry, fakeTimeSource)
+	server.EnableSharding(time.Minute)
+
+	req := getByHashRequest1
+	req.LogId = 103
+	rsp, err := server.GetLeavesByHash(context.Background(), &req)
+	if err != nil {
+		t.Fatalf("GetLeavesByHash()=_, %v, want nil", err)
+	}
+	if got, want := rsp.LogId, int64(101); got != want {
+		t.Errorf("GetLeavesByHash().LogId=%d, want %d", got, want)
+	}
+	if len(rsp.Leaves) != 1 || !proto.Equal(rsp.Leaves[0], leaf1) {
+		t.Errorf("GetLeavesByHash().Leaves=%v, want [%v]", rsp.Leaves, leaf1)
+	}
+}
+
+func TestGetLeavesByHashShardedMerged(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Each shard has one of the leaves, the older is only asked for the one the newer
+	// doesn't have.
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	for _, shard := range []struct {
+		logID  int64
+		hashes [][]byte
+		leaves []*trillian.LogLeaf
+	}{
+		{logID: 102, hashes: [][]byte{leaf1.MerkleLeafHash, leaf3.MerkleLeafHash}, leaves: []*trillian.LogLeaf{leaf3}},
+		{logID: 101, hashes: [][]byte{leaf1.MerkleLeafHash}, leaves: []*trillian.LogLeaf{leaf1}},
+	} {
+		mockTx := storage.NewMockLogTreeTX(ctrl)
+		mockStorage.EXPECT().SnapshotForTree(gomock.Any(), shard.logID).Return(mockTx, nil)
+		mockTx.EXPECT().GetLeavesByHash(shard.hashes, false).Return(shard.leaves, nil)
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Close().Return(nil)
+	}
+
+	registry := extension.Registry{
+		AdminStorage: newShardAdminStorage(ctrl, shardSet(), 1),
+		LogStorage:   mockStorage,
+	}
+	server := NewTrillianLogRPCServer(registry, fakeTimeSource)
+	server.EnableSharding(time.Minute)
+
+	req := &trillian.GetLeavesByHashRequest{LogId: 103, LeafHash: [][]byte{leaf1.MerkleLeafHash, leaf3.MerkleLeafHash}}
+	rsp, err := server.GetLeavesByHash(context.Background(), req)
+	if err != nil {
+		t.Fatalf("GetLeavesByHash()=_, %v, want nil", err)
+	}
+	if got, want := rsp.LogId, int64(102); got != want {
+		t.Errorf("GetLeavesByHash().LogId=%d, want %d", got, want)
+	}
+	if len(rsp.Leaves) != 2 || !proto.Equal(rsp.Leaves[0], leaf3) || !proto.Equal(rsp.Leaves[1], leaf1) {
+		t.Errorf("GetLeavesByHash().Leaves=%v, want [%v %v]", rsp.Leaves, leaf3, leaf1)
+	}
+	if got, want := rsp.LeafLogIds, []int64{102, 101}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetLeavesByHash().LeafLogIds=%v, want %v", got, want)
+	}
+}
+
 func TestGetProofByHashBeginTXFails(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -919,6 +1530,64 @@ func TestGetProofByIndex(t *testing.T) {
 	}
 }
 
+func TestGetProofByIndexCached(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	registry := extension.Registry{
+		LogStorage: mockStorage,
+	}
+	server := NewTrillianLogRPCServer(registry, fakeTimeSource)
+	server.SetProofCacheSize(1 << 20)
+
+	smallerRoot := signedRoot1
+	smallerRoot.TreeSize = 5
+
+	tests := []struct {
+		desc      string
+		root      trillian.SignedLogRoot
+		wantFetch bool
+		wantErr   bool
+	}{
+		{desc: "empty", root: signedRoot1, wantFetch: true},
+		{desc: "cached", root: signedRoot1},
+		// A snapshot whose tree hasn't reached the requested size mustn't be answered from the cache.
+		{desc: "behind", root: smallerRoot, wantErr: true},
+	}
+
+	var first *trillian.Proof
+	for _, test := range tests {
+		mockTx := storage.NewMockLogTreeTX(ctrl)
+		mockStorage.EXPECT().SnapshotForTree(gomock.Any(), getInclusionProofByIndexRequest7.LogId).Return(mockTx, nil)
+		mockTx.EXPECT().LatestSignedLogRoot().Return(test.root, nil)
+		if test.wantFetch {
+			mockTx.EXPECT().ReadRevision().Return(test.root.TreeRevision)
+			mockTx.EXPECT().GetMerkleNodes(revision1, nodeIdsInclusionSize7Index2).Return([]storage.Node{
+				{NodeID: nodeIdsInclusionSize7Index2[0], NodeRevision: 3, Hash: []byte("nodehash0")},
+				{NodeID: nodeIdsInclusionSize7Index2[1], NodeRevision: 2, Hash: []byte("nodehash1")},
+				{NodeID: nodeIdsInclusionSize7Index2[2], NodeRevision: 3, Hash: []byte("nodehash2")}}, nil)
+		}
+		if !test.wantErr {
+			mockTx.EXPECT().Commit().Return(nil)
+		}
+		mockTx.EXPECT().Close().Return(nil)
+
+		resp, err := server.GetInclusionProof(context.Background(), &getInclusionProofByIndexRequest7)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Fatalf("%v: GetInclusionProof()=(_, %v), want err? %v", test.desc, err, test.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		if first == nil {
+			first = resp.Proof
+		} else if !proto.Equal(resp.Proof, first) {
+			t.Errorf("%v: GetInclusionProof()=%v, want %v", test.desc, resp.Proof, first)
+		}
+	}
+}
+
 func TestGetEntryAndProofBeginTXFails(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -1311,6 +1980,248 @@ func TestGetConsistencyProof(t *testing.T) {
 	}
 }
 
+// proofHistoryStream collects the responses sent to it by GetConsistencyProofHistory.
+type proofHistoryStream struct {
+	grpc.ServerStream
+	rsps []*trillian.GetConsistencyProofHistoryResponse
+}
+
+func (s *proofHistoryStream) Context() context.Context {
+	return context.Background()
+}
+
+func (s *proofHistoryStream) Send(rsp *trillian.GetConsistencyProofHistoryResponse) error {
+	s.rsps = append(s.rsps, rsp)
+	return nil
+}
+
+func TestGetConsistencyProofHistory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// The proof from 2 to 3 is c, and from 3 to 4 is c, d, g, so c is only read once.
+	c := storage.Node{NodeID: testonly.MustCreateNodeIDForTreeCoords(0, 2, 64), NodeRevision: 2, Hash: []byte("c")}
+	d := storage.Node{NodeID: testonly.MustCreateNodeIDForTreeCoords(0, 3, 64), NodeRevision: 3, Hash: []byte("d")}
+	g := storage.Node{NodeID: testonly.MustCreateNodeIDForTreeCoords(1, 0, 64), NodeRevision: 1, Hash: []byte("g")}
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTreeTX(ctrl)
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), logID1).Return(mockTx, nil)
+	mockTx.EXPECT().LatestSignedLogRoot().Return(trillian.SignedLogRoot{TreeSize: 4, TreeRevision: revision1}, nil)
+	mockTx.EXPECT().ReadRevision().Return(revision1)
+	mockTx.EXPECT().GetMerkleNodes(revision1, []storage.NodeID{c.NodeID, d.NodeID, g.NodeID}).Return([]storage.Node{c, d, g}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+	mockTx.EXPECT().Close().Return(nil)
+
+	registry := extension.Registry{
+		LogStorage: mockStorage,
+	}
+	server := NewTrillianLogRPCServer(registry, fakeTimeSource)
+
+	stream := &proofHistoryStream{}
+	req := &trillian.GetConsistencyProofHistoryRequest{LogId: logID1, TreeSizes: []int64{2, 3, 4}}
+	if err := server.GetConsistencyProofHistory(req, stream); err != nil {
+		t.Fatalf("GetConsistencyProofHistory()=%v", err)
+	}
+
+	proofNode := func(n storage.Node) *trillian.Node {
+		id, err := proto.Marshal(n.NodeID.AsProto())
+		if err != nil {
+			t.Fatalf("failed to marshal node ID: %v", err)
+		}
+		return &trillian.Node{NodeId: id, NodeHash: n.Hash, NodeRevision: n.NodeRevision}
+	}
+	want := []*trillian.GetConsistencyProofHistoryResponse{
+		{
+			FirstTreeSize:  2,
+			SecondTreeSize: 3,
+			Proof:          &trillian.Proof{ProofNode: []*trillian.Node{proofNode(c)}},
+		},
+		{
+			FirstTreeSize:  3,
+			SecondTreeSize: 4,
+			Proof:          &trillian.Proof{ProofNode: []*trillian.Node{proofNode(c), proofNode(d), proofNode(g)}},
+		},
+	}
+	if len(stream.rsps) != len(want) {
+		t.Fatalf("GetConsistencyProofHistory() sent %d responses, want %d", len(stream.rsps), len(want))
+	}
+	for i, rsp := range stream.rsps {
+		if !proto.Equal(rsp, want[i]) {
+			t.Errorf("GetConsistencyProofHistory() response %d=%v, want %v", i, rsp, want[i])
+		}
+	}
+}
+
+func TestGetConsistencyProofHistoryErrors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, test := range []struct {
+		desc      string
+		sizes     []int64
+		maxProofs int
+		storage   bool
+		wantCode  codes.Code
+	}{
+		{desc: "oneSize", sizes: []int64{4}, wantCode: codes.InvalidArgument},
+		{desc: "notIncreasing", sizes: []int64{4, 2}, wantCode: codes.InvalidArgument},
+		{desc: "overLimit", sizes: []int64{1, 2, 3, 4}, maxProofs: 2, wantCode: codes.InvalidArgument},
+		{desc: "beyondRoot", sizes: []int64{4, 8}, storage: true, wantCode: codes.OutOfRange},
+	} {
+		mockStorage := storage.NewMockLogStorage(ctrl)
+		if test.storage {
+			mockTx := storage.NewMockLogTreeTX(ctrl)
+			mockStorage.EXPECT().SnapshotForTree(gomock.Any(), logID1).Return(mockTx, nil)
+			mockTx.EXPECT().LatestSignedLogRoot().Return(signedRoot1, nil)
+			mockTx.EXPECT().Close().Return(nil)
+		}
+		server := NewTrillianLogRPCServer(extension.Registry{LogStorage: mockStorage}, fakeTimeSource)
+		server.SetRequestLimits(RequestLimits{MaxProofs: test.maxProofs})
+
+		stream := &proofHistoryStream{}
+		req := &trillian.GetConsistencyProofHistoryRequest{LogId: logID1, TreeSizes: test.sizes}
+		if err := server.GetConsistencyProofHistory(req, stream); grpc.Code(err) != test.wantCode {
+			t.Errorf("%v: GetConsistencyProofHistory()=%v, want %v", test.desc, err, test.wantCode)
+		}
+		if len(stream.rsps) != 0 {
+			t.Errorf("%v: GetConsistencyProofHistory() sent %d responses, want none", test.desc, len(stream.rsps))
+		}
+	}
+}
+
+func TestGetLeavesByIndexResponseLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTreeTX(ctrl)
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), leaf03Request.LogId).Return(mockTx, nil)
+	mockTx.EXPECT().GetLeavesByIndex([]int64{0, 3}).Return([]*trillian.LogLeaf{leaf1, leaf3}, nil)
+	mockTx.EXPECT().Close().Return(nil)
+
+	server := NewTrillianLogRPCServer(extension.Registry{LogStorage: mockStorage}, fakeTimeSource)
+	server.SetRequestLimits(RequestLimits{MaxResponseBytes: int64(proto.Size(leaf1) + 1)})
+
+	if _, err := server.GetLeavesByIndex(context.Background(), &leaf03Request); grpc.Code(err) != codes.OutOfRange {
+		t.Errorf("GetLeavesByIndex()=%v, want %v", err, codes.OutOfRange)
+	}
+}
+
+// leavesStream collects the responses sent to it by GetLeavesByIndexStream.
+type leavesStream struct {
+	grpc.ServerStream
+	rsps []*trillian.GetLeavesByIndexResponse
+}
+
+func (s *leavesStream) Context() context.Context {
+	return context.Background()
+}
+
+func (s *leavesStream) Send(rsp *trillian.GetLeavesByIndexResponse) error {
+	s.rsps = append(s.rsps, rsp)
+	return nil
+}
+
+func TestGetLeavesByIndexStream(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, test := range []struct {
+		desc     string
+		maxBytes int64
+		want     [][]*trillian.LogLeaf
+	}{
+		{desc: "noLimit", want: [][]*trillian.LogLeaf{{leaf1, leaf3}}},
+		{desc: "bothFit", maxBytes: int64(proto.Size(leaf1) + proto.Size(leaf3)), want: [][]*trillian.LogLeaf{{leaf1, leaf3}}},
+		{desc: "split", maxBytes: int64(proto.Size(leaf1) + 1), want: [][]*trillian.LogLeaf{{leaf1}, {leaf3}}},
+		// A leaf larger than the limit is still sent, on its own.
+		{desc: "tooSmall", maxBytes: 1, want: [][]*trillian.LogLeaf{{leaf1}, {leaf3}}},
+	} {
+		mockStorage := storage.NewMockLogStorage(ctrl)
+		mockTx := storage.NewMockLogTreeTX(ctrl)
+		mockStorage.EXPECT().SnapshotForTree(gomock.Any(), leaf03Request.LogId).Return(mockTx, nil)
+		mockTx.EXPECT().GetLeavesByIndex([]int64{0, 3}).Return([]*trillian.LogLeaf{leaf1, leaf3}, nil)
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Close().Return(nil)
+		mockTx.EXPECT().IsOpen().AnyTimes().Return(false)
+
+		server := NewTrillianLogRPCServer(extension.Registry{LogStorage: mockStorage}, fakeTimeSource)
+		server.SetRequestLimits(RequestLimits{MaxResponseBytes: test.maxBytes})
+
+		stream := &leavesStream{}
+		if err := server.GetLeavesByIndexStream(&leaf03Request, stream); err != nil {
+			t.Errorf("%v: GetLeavesByIndexStream()=%v", test.desc, err)
+			continue
+		}
+		if len(stream.rsps) != len(test.want) {
+			t.Errorf("%v: GetLeavesByIndexStream() sent %d responses, want %d", test.desc, len(stream.rsps), len(test.want))
+			continue
+		}
+		for i, rsp := range stream.rsps {
+			if want := (&trillian.GetLeavesByIndexResponse{Leaves: test.want[i]}); !proto.Equal(rsp, want) {
+				t.Errorf("%v: GetLeavesByIndexStream() response %d=%v, want %v", test.desc, i, rsp, want)
+			}
+		}
+	}
+}
+
+func TestPinnedReads(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pinnedRoot := trillian.SignedLogRoot{TimestampNanos: 123456789, RootHash: []byte("AN OLDER HASH"), TreeSize: 2, TreeRevision: 3}
+	newSnapshot := func() *storage.MockLogTreeTX {
+		mockTx := storage.NewMockLogTreeTX(ctrl)
+		mockTx.EXPECT().SignedLogRootAtSize(int64(2)).Return(pinnedRoot, nil)
+		mockTx.EXPECT().Close().Return(nil)
+		return mockTx
+	}
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	server := NewTrillianLogRPCServer(extension.Registry{LogStorage: mockStorage}, fakeTimeSource)
+
+	mockTx := newSnapshot()
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), logID1).Return(mockTx, nil)
+	mockTx.EXPECT().FinalizedTreeSize().Return(int64(0), false, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+	rootReq := trillian.GetLatestSignedLogRootRequest{LogId: logID1, TreeSize: 2}
+	if rsp, err := server.GetLatestSignedLogRoot(context.Background(), &rootReq); err != nil || !proto.Equal(rsp.SignedLogRoot, &pinnedRoot) {
+		t.Errorf("GetLatestSignedLogRoot(%+v)=(%v, %v), want (%v, nil)", rootReq, rsp, err, pinnedRoot)
+	}
+
+	mockTx = newSnapshot()
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), logID1).Return(mockTx, nil)
+	mockTx.EXPECT().GetLeavesByHash(getByHashRequest1.LeafHash, false).Return([]*trillian.LogLeaf{leaf1, leaf3}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+	hashReq := getByHashRequest1
+	hashReq.TreeSize = 2
+	if rsp, err := server.GetLeavesByHash(context.Background(), &hashReq); err != nil || !reflect.DeepEqual(rsp.Leaves, []*trillian.LogLeaf{leaf1}) {
+		t.Errorf("GetLeavesByHash(%+v)=(%v, %v), want leaves [%v]", hashReq, rsp, err, leaf1)
+	}
+
+	mockTx = newSnapshot()
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), logID1).Return(mockTx, nil)
+	indexReq := leaf03Request
+	indexReq.TreeSize = 2
+	if _, err := server.GetLeavesByIndex(context.Background(), &indexReq); te.ErrorCode(err) != te.OutOfRange {
+		t.Errorf("GetLeavesByIndex(%+v)=(_, %v), want OutOfRange", indexReq, err)
+	}
+
+	mockTx = storage.NewMockLogTreeTX(ctrl)
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), logID1).Return(mockTx, nil)
+	mockTx.EXPECT().SignedLogRootAtSize(int64(4)).Return(trillian.SignedLogRoot{}, nil)
+	mockTx.EXPECT().Close().Return(nil)
+	rootReq.TreeSize = 4
+	if _, err := server.GetLatestSignedLogRoot(context.Background(), &rootReq); te.ErrorCode(err) != te.NotFound {
+		t.Errorf("GetLatestSignedLogRoot(%+v)=(_, %v), want NotFound", rootReq, err)
+	}
+
+	rootReq.Witnessed = true
+	if _, err := server.GetLatestSignedLogRoot(context.Background(), &rootReq); grpc.Code(err) != codes.InvalidArgument {
+		t.Errorf("GetLatestSignedLogRoot(%+v)=(_, %v), want InvalidArgument", rootReq, err)
+	}
+}
+
 type prepareMockTXFunc func(*storage.MockLogTreeTX)
 type makeRPCFunc func(*TrillianLogRPCServer) error
 
@@ -0,0 +1,85 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"container/list"
+	"expvar"
+	"sync"
+)
+
+type lruEntry struct {
+	key   interface{}
+	value interface{}
+	size  int64
+}
+
+// lruCache is a least recently used cache bounded by the total size of the values it
+// holds, as given when they're added. Hits, misses and evictions are counted in stats.
+type lruCache struct {
+	maxBytes int64
+	stats    *expvar.Map
+
+	mu      sync.Mutex
+	bytes   int64
+	order   *list.List // of *lruEntry, most recently used first
+	entries map[interface{}]*list.Element
+}
+
+func newLRUCache(maxBytes int64, stats *expvar.Map) *lruCache {
+	return &lruCache{
+		maxBytes: maxBytes,
+		stats:    stats,
+		order:    list.New(),
+		entries:  make(map[interface{}]*list.Element),
+	}
+}
+
+func (c *lruCache) get(key interface{}) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		c.stats.Add("misses", 1)
+		return nil, false
+	}
+	c.stats.Add("hits", 1)
+	c.order.MoveToFront(e)
+	return e.Value.(*lruEntry).value, true
+}
+
+// put adds value to the cache, evicting the least recently used values to make room.
+// Values larger than the whole cache aren't added.
+func (c *lruCache) put(key, value interface{}, size int64) {
+	if size > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; ok {
+		return
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, value: value, size: size})
+	c.bytes += size
+	for c.bytes > c.maxBytes {
+		e := c.order.Back()
+		entry := e.Value.(*lruEntry)
+		c.order.Remove(e)
+		delete(c.entries, entry.key)
+		c.bytes -= entry.size
+		c.stats.Add("evictions", 1)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+// mergeDelay is the cached maximum merge delay of a tree.
+type mergeDelay struct {
+	seconds int32
+	checked time.Time
+}
+
+// mergeDelayCache holds the maximum merge delay of each log that leaves are queued to,
+// rereading it from admin storage once it's older than the refresh interval. A change
+// to a tree's delay may take that long to be reported.
+type mergeDelayCache struct {
+	registry   extension.Registry
+	timeSource util.TimeSource
+	refresh    time.Duration
+
+	mu     sync.Mutex
+	delays map[int64]mergeDelay
+}
+
+func newMergeDelayCache(registry extension.Registry, timeSource util.TimeSource, refresh time.Duration) *mergeDelayCache {
+	return &mergeDelayCache{
+		registry:   registry,
+		timeSource: timeSource,
+		refresh:    refresh,
+		delays:     make(map[int64]mergeDelay),
+	}
+}
+
+// get returns the max_merge_delay_seconds of logID's tree.
+func (c *mergeDelayCache) get(ctx context.Context, logID int64) (int32, error) {
+	now := c.timeSource.Now()
+	c.mu.Lock()
+	d, ok := c.delays[logID]
+	c.mu.Unlock()
+	if ok && now.Sub(d.checked) < c.refresh {
+		return d.seconds, nil
+	}
+
+	tree, err := getTree(ctx, c.registry, logID)
+	if err != nil {
+		return 0, err
+	}
+	d = mergeDelay{seconds: tree.MaxMergeDelaySeconds, checked: now}
+	c.mu.Lock()
+	c.delays[logID] = d
+	c.mu.Unlock()
+	return d.seconds, nil
+}
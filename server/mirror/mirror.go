@@ -0,0 +1,215 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mirror copies the contents of a source Trillian log into a local
+// PREORDERED_LOG tree, so the local tree serves the same roots and proofs.
+package mirror
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/client"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+	"golang.org/x/net/context"
+)
+
+// Mirror follows a source log and writes its leaves into a local pre-ordered tree.
+// Source roots are verified (signature and consistency) before any leaf covered by
+// them is copied. Integration of the copied leaves is left to the log signer, and
+// each local root it produces is then proven consistent with the source root, so
+// copied leaves which differ from the source's are caught.
+type Mirror struct {
+	source    trillian.TrillianLogClient
+	verifier  client.VerifyingLogClient
+	sourceID  int64
+	treeID    int64
+	storage   storage.LogStorage
+	hasher    merkle.TreeHasher
+	batchSize int
+
+	// next is the index of the next leaf to copy, if nextKnown. It's read from
+	// storage on the first pass, and again after a failed write.
+	next      int64
+	nextKnown bool
+	// verifiedSize is the last local tree size proven consistent with the source.
+	verifiedSize int64
+	// diverged is set once the local tree is found to differ from the source, after
+	// which nothing more is copied.
+	diverged error
+}
+
+// New returns a Mirror that copies leaves from sourceID, read through source and verified by
+// verifier, into the local tree treeID. At most batchSize leaves are copied per RunOnce.
+func New(source trillian.TrillianLogClient, verifier client.VerifyingLogClient, sourceID, treeID int64, logStorage storage.LogStorage, hasher merkle.TreeHasher, batchSize int) *Mirror {
+	return &Mirror{
+		source:    source,
+		verifier:  verifier,
+		sourceID:  sourceID,
+		treeID:    treeID,
+		storage:   logStorage,
+		hasher:    hasher,
+		batchSize: batchSize,
+	}
+}
+
+// RunOnce fetches and verifies the latest source root, then copies the next batch of
+// leaves covered by it into the local tree. It returns the number of leaves copied.
+func (m *Mirror) RunOnce(ctx context.Context) (int, error) {
+	if m.diverged != nil {
+		return 0, m.diverged
+	}
+	if err := m.verifier.UpdateRoot(ctx); err != nil {
+		return 0, fmt.Errorf("failed to verify source root: %v", err)
+	}
+	sourceRoot := m.verifier.Root()
+
+	tx, err := m.storage.BeginForTree(ctx, m.treeID)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Close()
+
+	localRoot, err := tx.LatestSignedLogRoot()
+	if err != nil {
+		return 0, err
+	}
+	if localRoot.TreeSize > sourceRoot.TreeSize {
+		return 0, fmt.Errorf("mirror tree %d has size %d, larger than source size %d", m.treeID, localRoot.TreeSize, sourceRoot.TreeSize)
+	}
+	if localRoot.TreeSize == sourceRoot.TreeSize && localRoot.RootHash != nil && !bytes.Equal(localRoot.RootHash, sourceRoot.RootHash) {
+		m.diverged = fmt.Errorf("mirror diverged at size %d: got root %x, source has %x", localRoot.TreeSize, localRoot.RootHash, sourceRoot.RootHash)
+		return 0, m.diverged
+	}
+	if err := m.checkConsistency(ctx, localRoot, sourceRoot); err != nil {
+		return 0, err
+	}
+
+	if !m.nextKnown {
+		// Leaves may have been copied but not integrated yet, so continue from the
+		// stored leaves rather than from the local tree size.
+		if m.next, err = tx.GetSequencedLeafCount(); err != nil {
+			return 0, err
+		}
+		m.nextKnown = true
+	}
+	next := m.next
+	end := next + int64(m.batchSize)
+	if end > sourceRoot.TreeSize {
+		end = sourceRoot.TreeSize
+	}
+	if next >= end {
+		return 0, tx.Commit()
+	}
+
+	leaves, err := m.fetchLeaves(ctx, next, end)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.AddSequencedLeaves(leaves); err != nil {
+		m.nextKnown = false
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		// The leaves may have been written all the same.
+		m.nextKnown = false
+		return 0, err
+	}
+	m.next = end
+
+	glog.V(1).Infof("Mirrored leaves [%d, %d) of source log %d into tree %d", next, end, m.sourceID, m.treeID)
+	return len(leaves), nil
+}
+
+// checkConsistency checks the integrated local root is consistent with the verified
+// source root, with a proof from the source. Each local tree size is checked once.
+func (m *Mirror) checkConsistency(ctx context.Context, local, source trillian.SignedLogRoot) error {
+	if local.TreeSize == 0 || local.TreeSize == m.verifiedSize || local.TreeSize == source.TreeSize {
+		return nil
+	}
+	resp, err := m.source.GetConsistencyProof(ctx, &trillian.GetConsistencyProofRequest{
+		LogId:          m.sourceID,
+		FirstTreeSize:  local.TreeSize,
+		SecondTreeSize: source.TreeSize,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get consistency proof from size %d to %d: %v", local.TreeSize, source.TreeSize, err)
+	}
+	var proof [][]byte
+	for _, node := range resp.GetProof().GetProofNode() {
+		proof = append(proof, node.GetNodeHash())
+	}
+	if err := merkle.NewLogVerifier(m.hasher).VerifyConsistencyProof(local.TreeSize, source.TreeSize, local.RootHash, source.RootHash, proof); err != nil {
+		m.diverged = fmt.Errorf("mirror diverged: root %x at size %d isn't consistent with the source root at size %d: %v", local.RootHash, local.TreeSize, source.TreeSize, err)
+		return m.diverged
+	}
+	m.verifiedSize = local.TreeSize
+	return nil
+}
+
+// Run calls RunOnce every interval until ctx is done.
+func (m *Mirror) Run(ctx context.Context, interval time.Duration) {
+	for {
+		count, err := m.RunOnce(ctx)
+		if err != nil {
+			glog.Warningf("Mirror pass for tree %d failed: %v", m.treeID, err)
+		}
+
+		// Keep going without pausing while there's a backlog to copy.
+		if err != nil || count < m.batchSize {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+		} else if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// fetchLeaves returns the source leaves with indices in [start, end), checking that each
+// one is the leaf requested and that its hash matches its contents.
+func (m *Mirror) fetchLeaves(ctx context.Context, start, end int64) ([]*trillian.LogLeaf, error) {
+	req := &trillian.GetLeavesByIndexRequest{LogId: m.sourceID}
+	for i := start; i < end; i++ {
+		req.LeafIndex = append(req.LeafIndex, i)
+	}
+	resp, err := m.source.GetLeavesByIndex(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if got, want := len(resp.Leaves), len(req.LeafIndex); got != want {
+		return nil, fmt.Errorf("source returned %d leaves, want %d", got, want)
+	}
+
+	for i, leaf := range resp.Leaves {
+		if want := start + int64(i); leaf.LeafIndex != want {
+			return nil, fmt.Errorf("source returned leaf %d, want %d", leaf.LeafIndex, want)
+		}
+		if got, want := leaf.MerkleLeafHash, m.hasher.HashLeaf(leaf.LeafValue); !bytes.Equal(got, want) {
+			return nil, fmt.Errorf("source leaf %d has hash %x, but its value hashes to %x", leaf.LeafIndex, got, want)
+		}
+		if len(leaf.LeafIdentityHash) == 0 {
+			hash := sha256.Sum256(leaf.LeafValue)
+			leaf.LeafIdentityHash = hash[:]
+		}
+	}
+	return resp.Leaves, nil
+}
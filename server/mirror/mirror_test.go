@@ -0,0 +1,300 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirror
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/mockclient"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/testonly"
+	"golang.org/x/net/context"
+)
+
+const (
+	sourceID = 10
+	treeID   = 20
+)
+
+// fakeVerifier returns a fixed root, or an error, from UpdateRoot.
+type fakeVerifier struct {
+	root trillian.SignedLogRoot
+	err  error
+}
+
+func (f *fakeVerifier) AddLeaf(ctx context.Context, data []byte) error {
+	return errors.New("not implemented")
+}
+
+func (f *fakeVerifier) UpdateRoot(ctx context.Context) error {
+	return f.err
+}
+
+func (f *fakeVerifier) Root() trillian.SignedLogRoot {
+	return f.root
+}
+
+func sourceLeaf(index int64) *trillian.LogLeaf {
+	value := []byte(fmt.Sprintf("leaf %d", index))
+	return &trillian.LogLeaf{
+		MerkleLeafHash: testonly.Hasher.HashLeaf(value),
+		LeafValue:      value,
+		LeafIndex:      index,
+	}
+}
+
+// sourceTree returns the in-memory tree of the first n source leaves.
+func sourceTree(n int64) *merkle.InMemoryMerkleTree {
+	mt := merkle.NewInMemoryMerkleTree(testonly.Hasher)
+	for i := int64(0); i < n; i++ {
+		mt.AddLeaf(sourceLeaf(i).LeafValue)
+	}
+	return mt
+}
+
+// consistencyProof returns the source's proof that the tree of size first is a prefix
+// of the tree of size second.
+func consistencyProof(mt *merkle.InMemoryMerkleTree, first, second int64) *trillian.GetConsistencyProofResponse {
+	proof := &trillian.Proof{}
+	for _, node := range mt.SnapshotConsistency(first, second) {
+		proof.ProofNode = append(proof.ProofNode, &trillian.Node{NodeHash: node.Value.Hash()})
+	}
+	return &trillian.GetConsistencyProofResponse{Proof: proof}
+}
+
+func expectConsistencyProof(source *mockclient.MockTrillianLogClient, mt *merkle.InMemoryMerkleTree, first, second int64) {
+	req := &trillian.GetConsistencyProofRequest{LogId: sourceID, FirstTreeSize: first, SecondTreeSize: second}
+	source.EXPECT().GetConsistencyProof(gomock.Any(), req).Return(consistencyProof(mt, first, second), nil)
+}
+
+func TestRunOnce(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mt := sourceTree(10)
+
+	badHash := sourceLeaf(3)
+	badHash.MerkleLeafHash = []byte("bogus")
+	wrongIndex := sourceLeaf(4)
+
+	tests := []struct {
+		desc        string
+		verifyErr   error
+		sourceSize  int64
+		sourceHash  []byte
+		localSize   int64
+		localHash   []byte
+		stored      int64
+		fetched     []*trillian.LogLeaf
+		wantIndices []int64
+		wantProof   bool
+		wantCount   int
+		wantErr     string
+	}{
+		{
+			desc:      "verifyFails",
+			verifyErr: errors.New("bad signature"),
+			wantErr:   "bad signature",
+		},
+		{
+			desc:       "upToDate",
+			sourceSize: 5,
+			sourceHash: []byte("root"),
+			localSize:  5,
+			localHash:  []byte("root"),
+			stored:     5,
+		},
+		{
+			desc:       "diverged",
+			sourceSize: 5,
+			sourceHash: []byte("root"),
+			localSize:  5,
+			localHash:  []byte("other root"),
+			wantErr:    "mirror diverged",
+		},
+		{
+			desc:       "ahead",
+			sourceSize: 5,
+			localSize:  6,
+			localHash:  []byte("root"),
+			wantErr:    "larger than source",
+		},
+		{
+			desc:        "copiesBatch",
+			sourceSize:  10,
+			sourceHash:  mt.RootAtSnapshot(10).Hash(),
+			localSize:   2,
+			localHash:   mt.RootAtSnapshot(2).Hash(),
+			stored:      3,
+			fetched:     []*trillian.LogLeaf{sourceLeaf(3), sourceLeaf(4), sourceLeaf(5)},
+			wantIndices: []int64{3, 4, 5},
+			wantProof:   true,
+			wantCount:   3,
+		},
+		{
+			// The local tree of 2 leaves isn't a prefix of the source's.
+			desc:       "inconsistent",
+			sourceSize: 10,
+			sourceHash: mt.RootAtSnapshot(10).Hash(),
+			localSize:  2,
+			localHash:  mt.RootAtSnapshot(3).Hash(),
+			wantProof:  true,
+			wantErr:    "isn't consistent",
+		},
+		{
+			desc:        "copiesTail",
+			sourceSize:  5,
+			stored:      3,
+			fetched:     []*trillian.LogLeaf{sourceLeaf(3), sourceLeaf(4)},
+			wantIndices: []int64{3, 4},
+			wantCount:   2,
+		},
+		{
+			desc:        "badLeafHash",
+			sourceSize:  10,
+			stored:      3,
+			fetched:     []*trillian.LogLeaf{badHash, sourceLeaf(4), sourceLeaf(5)},
+			wantIndices: []int64{3, 4, 5},
+			wantErr:     "value hashes to",
+		},
+		{
+			desc:        "wrongIndex",
+			sourceSize:  10,
+			stored:      3,
+			fetched:     []*trillian.LogLeaf{wrongIndex, sourceLeaf(4), sourceLeaf(5)},
+			wantIndices: []int64{3, 4, 5},
+			wantErr:     "want 3",
+		},
+		{
+			desc:        "shortResponse",
+			sourceSize:  10,
+			stored:      3,
+			fetched:     []*trillian.LogLeaf{sourceLeaf(3)},
+			wantIndices: []int64{3, 4, 5},
+			wantErr:     "returned 1 leaves",
+		},
+	}
+
+	for _, test := range tests {
+		source := mockclient.NewMockTrillianLogClient(ctrl)
+		mockStorage := storage.NewMockLogStorage(ctrl)
+		mockTx := storage.NewMockLogTreeTX(ctrl)
+		verifier := &fakeVerifier{
+			root: trillian.SignedLogRoot{TreeSize: test.sourceSize, RootHash: test.sourceHash},
+			err:  test.verifyErr,
+		}
+
+		if test.verifyErr == nil {
+			mockStorage.EXPECT().BeginForTree(gomock.Any(), int64(treeID)).Return(mockTx, nil)
+			mockTx.EXPECT().Close().Return(nil)
+			mockTx.EXPECT().LatestSignedLogRoot().Return(trillian.SignedLogRoot{TreeSize: test.localSize, RootHash: test.localHash}, nil)
+			if test.wantErr == "" || test.fetched != nil {
+				mockTx.EXPECT().GetSequencedLeafCount().Return(test.stored, nil)
+			}
+		}
+		if test.wantProof {
+			expectConsistencyProof(source, mt, test.localSize, test.sourceSize)
+		}
+		if test.fetched != nil {
+			req := &trillian.GetLeavesByIndexRequest{LogId: sourceID, LeafIndex: test.wantIndices}
+			source.EXPECT().GetLeavesByIndex(gomock.Any(), req).Return(&trillian.GetLeavesByIndexResponse{Leaves: test.fetched}, nil)
+			if test.wantErr == "" {
+				mockTx.EXPECT().AddSequencedLeaves(test.fetched).Return(nil)
+			}
+		}
+		if test.wantErr == "" {
+			mockTx.EXPECT().Commit().Return(nil)
+		}
+
+		m := New(source, verifier, sourceID, treeID, mockStorage, testonly.Hasher, 3)
+		count, err := m.RunOnce(context.Background())
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%v: RunOnce() = (_, %v), want err containing %q", test.desc, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: RunOnce() = (_, %v), want nil", test.desc, err)
+			continue
+		}
+		if count != test.wantCount {
+			t.Errorf("%v: RunOnce() = %v, want %v", test.desc, count, test.wantCount)
+		}
+		for _, leaf := range test.fetched {
+			if len(leaf.LeafIdentityHash) == 0 {
+				t.Errorf("%v: leaf %d copied without an identity hash", test.desc, leaf.LeafIndex)
+			}
+		}
+	}
+}
+
+func TestRunOnceAcrossPasses(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mt := sourceTree(10)
+	source := mockclient.NewMockTrillianLogClient(ctrl)
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	verifier := &fakeVerifier{root: trillian.SignedLogRoot{TreeSize: 10, RootHash: mt.RootAtSnapshot(10).Hash()}}
+	m := New(source, verifier, sourceID, treeID, mockStorage, testonly.Hasher, 3)
+
+	// pass expects a pass over a local tree of localSize leaves to copy the leaves
+	// from start.
+	pass := func(localSize, start int64) {
+		mockTx := storage.NewMockLogTreeTX(ctrl)
+		mockStorage.EXPECT().BeginForTree(gomock.Any(), int64(treeID)).Return(mockTx, nil)
+		mockTx.EXPECT().Close().Return(nil)
+		mockTx.EXPECT().LatestSignedLogRoot().Return(trillian.SignedLogRoot{TreeSize: localSize, RootHash: mt.RootAtSnapshot(localSize).Hash()}, nil)
+		if start == 0 {
+			mockTx.EXPECT().GetSequencedLeafCount().Return(int64(0), nil)
+		}
+		leaves := []*trillian.LogLeaf{sourceLeaf(start), sourceLeaf(start + 1), sourceLeaf(start + 2)}
+		req := &trillian.GetLeavesByIndexRequest{LogId: sourceID, LeafIndex: []int64{start, start + 1, start + 2}}
+		source.EXPECT().GetLeavesByIndex(gomock.Any(), req).Return(&trillian.GetLeavesByIndexResponse{Leaves: leaves}, nil)
+		mockTx.EXPECT().AddSequencedLeaves(leaves).Return(nil)
+		mockTx.EXPECT().Commit().Return(nil)
+		if _, err := m.RunOnce(context.Background()); err != nil {
+			t.Fatalf("RunOnce() over %d leaves = (_, %v), want nil", localSize, err)
+		}
+	}
+
+	// The stored leaves are only counted on the first pass, and each integrated
+	// local root is only proven consistent once.
+	pass(0, 0)
+	expectConsistencyProof(source, mt, 3, 10)
+	pass(3, 3)
+	pass(3, 6)
+
+	// Once the local tree is found to have diverged nothing more is copied, even if
+	// the source is consistent with it again.
+	mockTx := storage.NewMockLogTreeTX(ctrl)
+	mockStorage.EXPECT().BeginForTree(gomock.Any(), int64(treeID)).Return(mockTx, nil)
+	mockTx.EXPECT().Close().Return(nil)
+	mockTx.EXPECT().LatestSignedLogRoot().Return(trillian.SignedLogRoot{TreeSize: 6, RootHash: []byte("bogus")}, nil)
+	expectConsistencyProof(source, mt, 6, 10)
+	if _, err := m.RunOnce(context.Background()); err == nil || !strings.Contains(err.Error(), "isn't consistent") {
+		t.Fatalf("RunOnce() over a divergent tree = (_, %v), want err containing %q", err, "isn't consistent")
+	}
+	if _, err := m.RunOnce(context.Background()); err == nil {
+		t.Errorf("RunOnce() after divergence = (_, nil), want err")
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/google/trillian/storage"
+	"golang.org/x/net/context"
+)
+
+// PreloadTopNodes reads the nodes in the top levels of a log's tree at its latest
+// revision. Every proof needs some of them, so when storage keeps subtrees between
+// transactions this saves the first proofs served from reading them.
+func PreloadTopNodes(ctx context.Context, logStorage storage.ReadOnlyLogStorage, logID int64, levels int) error {
+	tx, err := logStorage.SnapshotForTree(ctx, logID)
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+
+	root, err := tx.LatestSignedLogRoot()
+	if err != nil {
+		return err
+	}
+	ids, err := topNodeIDs(root.TreeSize, levels)
+	if err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		if _, err := tx.GetMerkleNodes(tx.ReadRevision(), ids); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// topNodeIDs returns the IDs of the stored nodes in the top levels of a log's tree of
+// size treeSize. Only complete subtrees have stored nodes, so the highest of these is
+// at level floor(log2(treeSize)).
+func topNodeIDs(treeSize int64, levels int) ([]storage.NodeID, error) {
+	if treeSize <= 0 {
+		return nil, nil
+	}
+	top := 0
+	for treeSize>>uint(top+1) > 0 {
+		top++
+	}
+
+	var ids []storage.NodeID
+	for level := top; level >= 0 && level > top-levels; level-- {
+		for index := int64(0); index < treeSize>>uint(level); index++ {
+			id, err := storage.NewNodeIDForTreeCoords(int64(level), index, proofMaxBitLen)
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/testonly"
+)
+
+func TestTopNodeIDs(t *testing.T) {
+	for _, test := range []struct {
+		treeSize int64
+		levels   int
+		want     []storage.NodeID
+	}{
+		{treeSize: 0, levels: 2},
+		{treeSize: 1, levels: 2, want: []storage.NodeID{testonly.MustCreateNodeIDForTreeCoords(0, 0, 64)}},
+		// Size 7 has complete nodes (2, 0), (1, 0..2) and (0, 0..6).
+		{treeSize: 7, levels: 2, want: []storage.NodeID{
+			testonly.MustCreateNodeIDForTreeCoords(2, 0, 64),
+			testonly.MustCreateNodeIDForTreeCoords(1, 0, 64),
+			testonly.MustCreateNodeIDForTreeCoords(1, 1, 64),
+			testonly.MustCreateNodeIDForTreeCoords(1, 2, 64),
+		}},
+		{treeSize: 8, levels: 1, want: []storage.NodeID{testonly.MustCreateNodeIDForTreeCoords(3, 0, 64)}},
+	} {
+		got, err := topNodeIDs(test.treeSize, test.levels)
+		if err != nil {
+			t.Fatalf("topNodeIDs(%d, %d)=%v", test.treeSize, test.levels, err)
+		}
+		if len(got) != len(test.want) {
+			t.Fatalf("topNodeIDs(%d, %d)=%v, want %v", test.treeSize, test.levels, got, test.want)
+		}
+		for i := range got {
+			if !got[i].Equivalent(test.want[i]) {
+				t.Errorf("topNodeIDs(%d, %d)[%d]=%v, want %v", test.treeSize, test.levels, i, got[i], test.want[i])
+			}
+		}
+	}
+}
+
+func TestPreloadTopNodes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	want, err := topNodeIDs(7, 2)
+	if err != nil {
+		t.Fatalf("topNodeIDs()=%v", err)
+	}
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTreeTX(ctrl)
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), logID1).Return(mockTx, nil)
+	mockTx.EXPECT().LatestSignedLogRoot().Return(trillian.SignedLogRoot{TreeSize: 7, TreeRevision: revision1}, nil)
+	mockTx.EXPECT().ReadRevision().Return(revision1)
+	mockTx.EXPECT().GetMerkleNodes(revision1, want).Return(nil, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+	mockTx.EXPECT().Close().Return(nil)
+
+	if err := PreloadTopNodes(context.Background(), mockStorage, logID1, 2); err != nil {
+		t.Errorf("PreloadTopNodes()=%v", err)
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"expvar"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+)
+
+// proofCacheStats counts inclusion proof cache hits, misses and evictions, across all logs.
+var proofCacheStats = expvar.NewMap("log-proof-cache")
+
+// proofKey identifies an inclusion proof. A log is append-only, so the proof of a leaf
+// in the tree of a given size never changes once that size has been reached: proofs for
+// a new size get new entries, and those for sizes nobody asks about any more age out.
+type proofKey struct {
+	logID     int64
+	treeSize  int64
+	leafIndex int64
+}
+
+// proofCache is an LRU cache of inclusion proofs, bounded by their total encoded size.
+// Cached proofs are shared, callers mustn't modify them.
+type proofCache struct {
+	lru *lruCache
+}
+
+func newProofCache(maxBytes int64) *proofCache {
+	return &proofCache{lru: newLRUCache(maxBytes, proofCacheStats)}
+}
+
+func (c *proofCache) get(key proofKey) (trillian.Proof, bool) {
+	v, ok := c.lru.get(key)
+	if !ok {
+		return trillian.Proof{}, false
+	}
+	return *v.(*trillian.Proof), true
+}
+
+func (c *proofCache) put(key proofKey, proof trillian.Proof) {
+	c.lru.put(key, &proof, int64(proto.Size(&proof)))
+}
@@ -42,6 +42,43 @@ func fetchNodesAndBuildProof(tx storage.NodeReader, treeRevision, leafIndex int6
 	return r.rehashedProof(leafIndex)
 }
 
+// fetchNodesAndBuildProofs builds a proof from each list of node fetches, like
+// fetchNodesAndBuildProof, but reads all the nodes in one call to storage. Nodes which
+// several of the proofs need, as neighbouring consistency proofs often do, are only
+// read once.
+func fetchNodesAndBuildProofs(tx storage.NodeReader, treeRevision int64, proofNodeFetches [][]merkle.NodeFetch) ([]trillian.Proof, error) {
+	var fetches []merkle.NodeFetch
+	positions := make(map[string]int)
+	for _, proofFetches := range proofNodeFetches {
+		for _, fetch := range proofFetches {
+			key := fetch.NodeID.String()
+			if _, ok := positions[key]; !ok {
+				positions[key] = len(fetches)
+				fetches = append(fetches, fetch)
+			}
+		}
+	}
+
+	nodes, err := fetchNodes(tx, treeRevision, fetches)
+	if err != nil {
+		return nil, err
+	}
+
+	proofs := make([]trillian.Proof, 0, len(proofNodeFetches))
+	for _, proofFetches := range proofNodeFetches {
+		r := newRehasher()
+		for _, fetch := range proofFetches {
+			r.process(nodes[positions[fetch.NodeID.String()]], fetch)
+		}
+		proof, err := r.rehashedProof(0)
+		if err != nil {
+			return nil, err
+		}
+		proofs = append(proofs, proof)
+	}
+	return proofs, nil
+}
+
 // rehasher bundles the rehashing logic into a simple state machine
 type rehasher struct {
 	th         merkle.TreeHasher
@@ -0,0 +1,204 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package replica checks that two Trillian log endpoints serving the same log,
+// e.g. a primary and a replica in another region, agree with each other.
+package replica
+
+import (
+	"bytes"
+	"expvar"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"golang.org/x/net/context"
+)
+
+var (
+	stats = expvar.NewMap("replica-checker")
+	// divergent is 1 while the last completed check found the endpoints diverged.
+	divergent = new(expvar.Int)
+)
+
+func init() {
+	stats.Set("divergent", divergent)
+}
+
+// Endpoint is one of the two copies of the log being compared.
+type Endpoint struct {
+	// Name identifies the endpoint in logs and errors.
+	Name   string
+	Client trillian.TrillianLogClient
+	LogID  int64
+}
+
+// DivergenceError is returned by CheckOnce when the endpoints disagree, as opposed
+// to a check that couldn't be completed.
+type DivergenceError struct {
+	Reason string
+}
+
+func (d DivergenceError) Error() string {
+	return "replicas diverged: " + d.Reason
+}
+
+// Checker compares the signed roots of two endpoints, checks that the smaller one is
+// consistent with the larger one and verifies a sample of inclusion proofs on both.
+type Checker struct {
+	a, b     Endpoint
+	hasher   merkle.TreeHasher
+	verifier merkle.LogVerifier
+	samples  int
+	rand     *rand.Rand
+}
+
+// New returns a Checker comparing a and b, verifying samples randomly chosen leaves per check.
+func New(a, b Endpoint, hasher merkle.TreeHasher, samples int) *Checker {
+	return &Checker{
+		a:        a,
+		b:        b,
+		hasher:   hasher,
+		verifier: merkle.NewLogVerifier(hasher),
+		samples:  samples,
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// CheckOnce runs a single comparison of the two endpoints. A DivergenceError is
+// returned if they disagree, any other error means the check couldn't be completed.
+func (c *Checker) CheckOnce(ctx context.Context) error {
+	rootA, err := c.root(ctx, c.a)
+	if err != nil {
+		return err
+	}
+	rootB, err := c.root(ctx, c.b)
+	if err != nil {
+		return err
+	}
+
+	// Replication lags, so the endpoints are only expected to agree up to the smaller size.
+	small, smallRoot, large, largeRoot := c.a, rootA, c.b, rootB
+	if rootA.TreeSize > rootB.TreeSize {
+		small, smallRoot, large, largeRoot = c.b, rootB, c.a, rootA
+	}
+	glog.V(1).Infof("%v has size %d, %v has size %d", small.Name, smallRoot.TreeSize, large.Name, largeRoot.TreeSize)
+
+	switch {
+	case smallRoot.TreeSize == largeRoot.TreeSize:
+		if !bytes.Equal(smallRoot.RootHash, largeRoot.RootHash) {
+			return DivergenceError{Reason: fmt.Sprintf("at size %d %v has root %x, %v has root %x", smallRoot.TreeSize, small.Name, smallRoot.RootHash, large.Name, largeRoot.RootHash)}
+		}
+	case smallRoot.TreeSize > 0:
+		rsp, err := large.Client.GetConsistencyProof(ctx, &trillian.GetConsistencyProofRequest{
+			LogId:          large.LogID,
+			FirstTreeSize:  smallRoot.TreeSize,
+			SecondTreeSize: largeRoot.TreeSize,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get consistency proof from %v: %v", large.Name, err)
+		}
+		if err := c.verifier.VerifyConsistencyProof(smallRoot.TreeSize, largeRoot.TreeSize, smallRoot.RootHash, largeRoot.RootHash, proofHashes(rsp.GetProof())); err != nil {
+			return DivergenceError{Reason: fmt.Sprintf("%v root at size %d is not consistent with %v root at size %d: %v", small.Name, smallRoot.TreeSize, large.Name, largeRoot.TreeSize, err)}
+		}
+	}
+
+	for i := 0; i < c.samples && smallRoot.TreeSize > 0; i++ {
+		index := c.rand.Int63n(smallRoot.TreeSize)
+		leafA, err := c.checkLeaf(ctx, c.a, rootA, index)
+		if err != nil {
+			return err
+		}
+		leafB, err := c.checkLeaf(ctx, c.b, rootB, index)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(leafA.MerkleLeafHash, leafB.MerkleLeafHash) {
+			return DivergenceError{Reason: fmt.Sprintf("leaf %d has hash %x on %v and %x on %v", index, leafA.MerkleLeafHash, c.a.Name, leafB.MerkleLeafHash, c.b.Name)}
+		}
+	}
+	return nil
+}
+
+// Run calls CheckOnce every interval until ctx is done, recording the outcome of each
+// check in the replica-checker expvar map.
+func (c *Checker) Run(ctx context.Context, interval time.Duration) {
+	for {
+		stats.Add("checks", 1)
+		err := c.CheckOnce(ctx)
+		switch err.(type) {
+		case nil:
+			divergent.Set(0)
+		case DivergenceError:
+			stats.Add("divergences", 1)
+			divergent.Set(1)
+			glog.Errorf("ALERT: %v and %v disagree: %v", c.a.Name, c.b.Name, err)
+		default:
+			stats.Add("errors", 1)
+			glog.Warningf("Replica check failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (c *Checker) root(ctx context.Context, e Endpoint) (*trillian.SignedLogRoot, error) {
+	rsp, err := e.Client.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{LogId: e.LogID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get root from %v: %v", e.Name, err)
+	}
+	if rsp.GetSignedLogRoot() == nil {
+		return nil, fmt.Errorf("%v returned no root", e.Name)
+	}
+	return rsp.GetSignedLogRoot(), nil
+}
+
+// checkLeaf fetches leaf index from e and verifies its inclusion in root.
+func (c *Checker) checkLeaf(ctx context.Context, e Endpoint, root *trillian.SignedLogRoot, index int64) (*trillian.LogLeaf, error) {
+	leaves, err := e.Client.GetLeavesByIndex(ctx, &trillian.GetLeavesByIndexRequest{LogId: e.LogID, LeafIndex: []int64{index}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get leaf %d from %v: %v", index, e.Name, err)
+	}
+	if got := len(leaves.GetLeaves()); got != 1 {
+		return nil, fmt.Errorf("%v returned %d leaves for index %d, want 1", e.Name, got, index)
+	}
+	leaf := leaves.Leaves[0]
+	if got := c.hasher.HashLeaf(leaf.LeafValue); !bytes.Equal(got, leaf.MerkleLeafHash) {
+		return nil, DivergenceError{Reason: fmt.Sprintf("leaf %d on %v has Merkle leaf hash %x, but its value hashes to %x", index, e.Name, leaf.MerkleLeafHash, got)}
+	}
+
+	rsp, err := e.Client.GetInclusionProof(ctx, &trillian.GetInclusionProofRequest{LogId: e.LogID, LeafIndex: index, TreeSize: root.TreeSize})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inclusion proof for leaf %d from %v: %v", index, e.Name, err)
+	}
+	if err := c.verifier.VerifyInclusionProof(index, root.TreeSize, proofHashes(rsp.GetProof()), root.RootHash, leaf.MerkleLeafHash); err != nil {
+		return nil, DivergenceError{Reason: fmt.Sprintf("inclusion proof for leaf %d on %v does not match its root at size %d: %v", index, e.Name, root.TreeSize, err)}
+	}
+	return leaf, nil
+}
+
+func proofHashes(proof *trillian.Proof) [][]byte {
+	hashes := make([][]byte, 0, len(proof.GetProofNode()))
+	for _, node := range proof.GetProofNode() {
+		hashes = append(hashes, node.NodeHash)
+	}
+	return hashes
+}
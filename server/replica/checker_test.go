@@ -0,0 +1,149 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replica
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/testonly"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// fakeLog serves roots, leaves and proofs from an in memory tree. Only the
+// methods used by Checker are implemented.
+type fakeLog struct {
+	trillian.TrillianLogClient
+	tree   *merkle.InMemoryMerkleTree
+	values [][]byte
+	root   *trillian.SignedLogRoot
+	err    error
+}
+
+// newFakeLog returns a log of the given size. Leaf i has value "leaf i" unless
+// it's in replaced.
+func newFakeLog(size int64, replaced map[int64]string) *fakeLog {
+	l := &fakeLog{tree: merkle.NewInMemoryMerkleTree(testonly.Hasher)}
+	for i := int64(0); i < size; i++ {
+		value := []byte(fmt.Sprintf("leaf %d", i))
+		if r, ok := replaced[i]; ok {
+			value = []byte(r)
+		}
+		l.values = append(l.values, value)
+		l.tree.AddLeaf(value)
+	}
+	l.root = &trillian.SignedLogRoot{TreeSize: size, RootHash: l.tree.CurrentRoot().Hash()}
+	return l
+}
+
+func toProof(path []merkle.TreeEntryDescriptor) *trillian.Proof {
+	proof := &trillian.Proof{}
+	for _, n := range path {
+		proof.ProofNode = append(proof.ProofNode, &trillian.Node{NodeHash: n.Value.Hash()})
+	}
+	return proof
+}
+
+func (l *fakeLog) GetLatestSignedLogRoot(ctx context.Context, req *trillian.GetLatestSignedLogRootRequest, opts ...grpc.CallOption) (*trillian.GetLatestSignedLogRootResponse, error) {
+	if l.err != nil {
+		return nil, l.err
+	}
+	return &trillian.GetLatestSignedLogRootResponse{SignedLogRoot: l.root}, nil
+}
+
+func (l *fakeLog) GetConsistencyProof(ctx context.Context, req *trillian.GetConsistencyProofRequest, opts ...grpc.CallOption) (*trillian.GetConsistencyProofResponse, error) {
+	return &trillian.GetConsistencyProofResponse{Proof: toProof(l.tree.SnapshotConsistency(req.FirstTreeSize, req.SecondTreeSize))}, nil
+}
+
+func (l *fakeLog) GetInclusionProof(ctx context.Context, req *trillian.GetInclusionProofRequest, opts ...grpc.CallOption) (*trillian.GetInclusionProofResponse, error) {
+	// The in memory tree uses 1 based leaf indices.
+	return &trillian.GetInclusionProofResponse{Proof: toProof(l.tree.PathToRootAtSnapshot(req.LeafIndex+1, req.TreeSize))}, nil
+}
+
+func (l *fakeLog) GetLeavesByIndex(ctx context.Context, req *trillian.GetLeavesByIndexRequest, opts ...grpc.CallOption) (*trillian.GetLeavesByIndexResponse, error) {
+	rsp := &trillian.GetLeavesByIndexResponse{}
+	for _, i := range req.LeafIndex {
+		rsp.Leaves = append(rsp.Leaves, &trillian.LogLeaf{
+			LeafIndex:      i,
+			LeafValue:      l.values[i],
+			MerkleLeafHash: testonly.Hasher.HashLeaf(l.values[i]),
+		})
+	}
+	return rsp, nil
+}
+
+func TestCheckOnce(t *testing.T) {
+	tests := []struct {
+		desc         string
+		a, b         *fakeLog
+		wantDiverged bool
+		wantOtherErr bool
+	}{
+		{desc: "empty", a: newFakeLog(0, nil), b: newFakeLog(0, nil)},
+		{desc: "sameSize", a: newFakeLog(17, nil), b: newFakeLog(17, nil)},
+		{desc: "replicaBehind", a: newFakeLog(17, nil), b: newFakeLog(9, nil)},
+		{desc: "primaryBehind", a: newFakeLog(3, nil), b: newFakeLog(17, nil)},
+		{desc: "replicaEmpty", a: newFakeLog(17, nil), b: newFakeLog(0, nil)},
+		{
+			desc:         "sameSizeDifferentRoots",
+			a:            newFakeLog(17, nil),
+			b:            newFakeLog(17, map[int64]string{4: "evil"}),
+			wantDiverged: true,
+		},
+		{
+			desc:         "inconsistent",
+			a:            newFakeLog(17, map[int64]string{4: "evil"}),
+			b:            newFakeLog(9, nil),
+			wantDiverged: true,
+		},
+		{
+			desc:         "rootUnavailable",
+			a:            newFakeLog(17, nil),
+			b:            &fakeLog{err: errors.New("unavailable")},
+			wantOtherErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		c := New(Endpoint{Name: "primary", Client: test.a, LogID: 1}, Endpoint{Name: "replica", Client: test.b, LogID: 2}, testonly.Hasher, 5)
+		err := c.CheckOnce(context.Background())
+		_, diverged := err.(DivergenceError)
+		if got, want := diverged, test.wantDiverged; got != want {
+			t.Errorf("%v: CheckOnce() = %v, want diverged: %v", test.desc, err, want)
+		}
+		if got, want := err != nil && !diverged, test.wantOtherErr; got != want {
+			t.Errorf("%v: CheckOnce() = %v, want other error: %v", test.desc, err, want)
+		}
+	}
+}
+
+func TestCheckOnceBadInclusionProof(t *testing.T) {
+	a := newFakeLog(8, nil)
+	b := newFakeLog(8, nil)
+	// Serve proofs from a different tree of the same size, so the roots agree but
+	// every sampled inclusion proof from b is wrong.
+	b.tree = newFakeLog(8, map[int64]string{0: "evil", 7: "evil"}).tree
+
+	c := New(Endpoint{Name: "primary", Client: a, LogID: 1}, Endpoint{Name: "replica", Client: b, LogID: 2}, testonly.Hasher, 1)
+	if err := c.CheckOnce(context.Background()); err == nil {
+		t.Error("CheckOnce() = nil, want DivergenceError")
+	} else if _, ok := err.(DivergenceError); !ok {
+		t.Errorf("CheckOnce() = %v, want DivergenceError", err)
+	}
+}
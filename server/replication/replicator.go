@@ -0,0 +1,298 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package replication copies a log from a primary Trillian deployment to a
+// PREORDERED_LOG tree of a secondary one, e.g. in another region, through the
+// secondary's AddSequencedLeaves RPC. The secondary's roots are continuously checked
+// against the primary's, so a failover can be shown to serve the same log.
+package replication
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"expvar"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/client"
+	"github.com/google/trillian/merkle"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// maxPendingRoots is the most primary roots kept for forwarding while the secondary
+// catches up with them, older ones are dropped first.
+const maxPendingRoots = 100
+
+var (
+	// The metrics are keyed by the primary log's ID.
+	replicatedLeaves = expvar.NewMap("replication-leaves")
+	forwardedRoots   = expvar.NewMap("replication-forwarded-roots")
+	replicationLag   = expvar.NewMap("replication-lag-leaves")
+	verifiedSize     = expvar.NewMap("replication-verified-size")
+	replicationErrs  = expvar.NewMap("replication-errors")
+	// divergences counts the passes which found the secondary disagreeing with the
+	// primary. Anything other than zero needs investigating.
+	divergences = expvar.NewMap("replication-divergences")
+)
+
+// Log is one of the two copies of the log being replicated.
+type Log struct {
+	// Name identifies the log in logs and errors.
+	Name   string
+	Client trillian.TrillianLogClient
+	LogID  int64
+	// Verifier checks the signatures on the log's roots and that each one is
+	// consistent with the one before.
+	Verifier client.VerifyingLogClient
+}
+
+// Options configures a Replicator.
+type Options struct {
+	// BatchSize is the most leaves copied per RunOnce.
+	BatchSize int
+	// ForwardRoots makes the primary's signed roots be sent to the secondary's
+	// AddObservedRoot RPC once it has caught up with them, where they're checked
+	// against its own history and kept. It requires the secondary to sign its roots
+	// with the primary's key.
+	ForwardRoots bool
+}
+
+// DivergenceError is returned by RunOnce when the secondary disagrees with the
+// primary, as opposed to a pass that couldn't be completed.
+type DivergenceError struct {
+	Reason string
+}
+
+func (d DivergenceError) Error() string {
+	return "secondary diverged from primary: " + d.Reason
+}
+
+// Replicator copies the leaves of a primary log into a secondary PREORDERED_LOG tree.
+// Only leaves covered by a verified primary root are copied, and the secondary
+// integrates them with its own log signer.
+type Replicator struct {
+	primary, secondary Log
+	hasher             merkle.TreeHasher
+	verifier           merkle.LogVerifier
+	opts               Options
+	metricKey          string
+
+	// pending holds the primary roots yet to be forwarded to the secondary, in order
+	// of size.
+	pending []trillian.SignedLogRoot
+}
+
+// New returns a Replicator copying primary into secondary.
+func New(primary, secondary Log, hasher merkle.TreeHasher, opts Options) *Replicator {
+	return &Replicator{
+		primary:   primary,
+		secondary: secondary,
+		hasher:    hasher,
+		verifier:  merkle.NewLogVerifier(hasher),
+		opts:      opts,
+		metricKey: strconv.FormatInt(primary.LogID, 10),
+	}
+}
+
+// RunOnce fetches and verifies the latest roots of both logs, checks that the
+// secondary's root is the primary's root at the same size, forwards any primary roots
+// the secondary has caught up with, then copies the next batch of leaves. It returns
+// the number of leaves copied. A DivergenceError is returned if the logs disagree.
+func (r *Replicator) RunOnce(ctx context.Context) (int, error) {
+	if err := r.primary.Verifier.UpdateRoot(ctx); err != nil {
+		return 0, fmt.Errorf("failed to verify %v root: %v", r.primary.Name, err)
+	}
+	primaryRoot := r.primary.Verifier.Root()
+	if err := r.secondary.Verifier.UpdateRoot(ctx); err != nil {
+		return 0, fmt.Errorf("failed to verify %v root: %v", r.secondary.Name, err)
+	}
+	secondaryRoot := r.secondary.Verifier.Root()
+
+	if err := r.verify(ctx, primaryRoot, secondaryRoot); err != nil {
+		return 0, err
+	}
+	verifiedSize.Set(r.metricKey, intVar(secondaryRoot.TreeSize))
+	replicationLag.Set(r.metricKey, intVar(primaryRoot.TreeSize-secondaryRoot.TreeSize))
+
+	if r.opts.ForwardRoots {
+		r.addPending(primaryRoot)
+		if err := r.forwardRoots(ctx, secondaryRoot.TreeSize); err != nil {
+			return 0, err
+		}
+	}
+
+	// Leaves may have been copied but not integrated yet, so continue from the
+	// stored leaves rather than from the secondary's tree size.
+	countResp, err := r.secondary.Client.GetSequencedLeafCount(ctx, &trillian.GetSequencedLeafCountRequest{LogId: r.secondary.LogID})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get leaf count from %v: %v", r.secondary.Name, err)
+	}
+	next := countResp.LeafCount
+	if next > primaryRoot.TreeSize {
+		return 0, DivergenceError{Reason: fmt.Sprintf("%v holds %d leaves, more than %v size %d", r.secondary.Name, next, r.primary.Name, primaryRoot.TreeSize)}
+	}
+	end := next + int64(r.opts.BatchSize)
+	if end > primaryRoot.TreeSize {
+		end = primaryRoot.TreeSize
+	}
+	if next >= end {
+		return 0, nil
+	}
+
+	leaves, err := r.fetchLeaves(ctx, next, end)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := r.secondary.Client.AddSequencedLeaves(ctx, &trillian.AddSequencedLeavesRequest{LogId: r.secondary.LogID, Leaves: leaves}); err != nil {
+		return 0, fmt.Errorf("failed to add leaves [%d, %d) to %v: %v", next, end, r.secondary.Name, err)
+	}
+	replicatedLeaves.Add(r.metricKey, int64(len(leaves)))
+
+	glog.V(1).Infof("Replicated leaves [%d, %d) of %v log %d into %v log %d", next, end, r.primary.Name, r.primary.LogID, r.secondary.Name, r.secondary.LogID)
+	return len(leaves), nil
+}
+
+// Run calls RunOnce every interval until ctx is done, without pausing while there's a
+// backlog to copy.
+func (r *Replicator) Run(ctx context.Context, interval time.Duration) {
+	for {
+		count, err := r.RunOnce(ctx)
+		switch err.(type) {
+		case nil:
+		case DivergenceError:
+			divergences.Add(r.metricKey, 1)
+			glog.Errorf("ALERT: %v log %d and %v log %d disagree: %v", r.primary.Name, r.primary.LogID, r.secondary.Name, r.secondary.LogID, err)
+		default:
+			replicationErrs.Add(r.metricKey, 1)
+			glog.Warningf("Replication pass for %v log %d failed: %v", r.primary.Name, r.primary.LogID, err)
+		}
+
+		if err != nil || count < r.opts.BatchSize {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+		} else if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// verify checks that secondary is primary's root at the same size, using a consistency
+// proof from the primary when the secondary is behind.
+func (r *Replicator) verify(ctx context.Context, primary, secondary trillian.SignedLogRoot) error {
+	switch {
+	case secondary.TreeSize > primary.TreeSize:
+		return DivergenceError{Reason: fmt.Sprintf("%v has size %d, larger than %v size %d", r.secondary.Name, secondary.TreeSize, r.primary.Name, primary.TreeSize)}
+	case secondary.TreeSize == primary.TreeSize:
+		if secondary.TreeSize > 0 && !bytes.Equal(secondary.RootHash, primary.RootHash) {
+			return DivergenceError{Reason: fmt.Sprintf("at size %d %v has root %x, %v has root %x", primary.TreeSize, r.secondary.Name, secondary.RootHash, r.primary.Name, primary.RootHash)}
+		}
+	case secondary.TreeSize > 0:
+		resp, err := r.primary.Client.GetConsistencyProof(ctx, &trillian.GetConsistencyProofRequest{
+			LogId:          r.primary.LogID,
+			FirstTreeSize:  secondary.TreeSize,
+			SecondTreeSize: primary.TreeSize,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get consistency proof from %v: %v", r.primary.Name, err)
+		}
+		if err := r.verifier.VerifyConsistencyProof(secondary.TreeSize, primary.TreeSize, secondary.RootHash, primary.RootHash, proofHashes(resp.GetProof())); err != nil {
+			return DivergenceError{Reason: fmt.Sprintf("%v root at size %d is not consistent with %v root at size %d: %v", r.secondary.Name, secondary.TreeSize, r.primary.Name, primary.TreeSize, err)}
+		}
+	}
+	return nil
+}
+
+// addPending queues root for forwarding, unless a root of the same size is queued.
+func (r *Replicator) addPending(root trillian.SignedLogRoot) {
+	if n := len(r.pending); n > 0 && r.pending[n-1].TreeSize >= root.TreeSize {
+		return
+	}
+	r.pending = append(r.pending, root)
+	if len(r.pending) > maxPendingRoots {
+		r.pending = r.pending[len(r.pending)-maxPendingRoots:]
+	}
+}
+
+// forwardRoots sends the pending roots no larger than size to the secondary.
+func (r *Replicator) forwardRoots(ctx context.Context, size int64) error {
+	for len(r.pending) > 0 && r.pending[0].TreeSize <= size {
+		root := r.pending[0]
+		resp, err := r.secondary.Client.AddObservedRoot(ctx, &trillian.AddObservedRootRequest{LogId: r.secondary.LogID, SignedLogRoot: &root})
+		switch {
+		case grpc.Code(err) == codes.FailedPrecondition:
+			// The secondary served the request from before it reached size, retry
+			// on the next pass.
+			return nil
+		case err != nil:
+			return fmt.Errorf("failed to forward root at size %d to %v: %v", root.TreeSize, r.secondary.Name, err)
+		case !resp.Consistent:
+			return DivergenceError{Reason: fmt.Sprintf("%v root at size %d, hash %x is inconsistent with %v history", r.primary.Name, root.TreeSize, root.RootHash, r.secondary.Name)}
+		}
+		forwardedRoots.Add(r.metricKey, 1)
+		r.pending = r.pending[1:]
+	}
+	return nil
+}
+
+// fetchLeaves returns the primary's leaves with indices in [start, end), checking that
+// each one is the leaf requested and that its hash matches its contents.
+func (r *Replicator) fetchLeaves(ctx context.Context, start, end int64) ([]*trillian.LogLeaf, error) {
+	req := &trillian.GetLeavesByIndexRequest{LogId: r.primary.LogID}
+	for i := start; i < end; i++ {
+		req.LeafIndex = append(req.LeafIndex, i)
+	}
+	resp, err := r.primary.Client.GetLeavesByIndex(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get leaves [%d, %d) from %v: %v", start, end, r.primary.Name, err)
+	}
+	if got, want := len(resp.Leaves), len(req.LeafIndex); got != want {
+		return nil, fmt.Errorf("%v returned %d leaves, want %d", r.primary.Name, got, want)
+	}
+
+	for i, leaf := range resp.Leaves {
+		if want := start + int64(i); leaf.LeafIndex != want {
+			return nil, fmt.Errorf("%v returned leaf %d, want %d", r.primary.Name, leaf.LeafIndex, want)
+		}
+		if got, want := leaf.MerkleLeafHash, r.hasher.HashLeaf(leaf.LeafValue); !bytes.Equal(got, want) {
+			return nil, fmt.Errorf("%v leaf %d has hash %x, but its value hashes to %x", r.primary.Name, leaf.LeafIndex, got, want)
+		}
+		if len(leaf.LeafIdentityHash) == 0 {
+			hash := sha256.Sum256(leaf.LeafValue)
+			leaf.LeafIdentityHash = hash[:]
+		}
+	}
+	return resp.Leaves, nil
+}
+
+func proofHashes(proof *trillian.Proof) [][]byte {
+	hashes := make([][]byte, 0, len(proof.GetProofNode()))
+	for _, node := range proof.GetProofNode() {
+		hashes = append(hashes, node.GetNodeHash())
+	}
+	return hashes
+}
+
+func intVar(v int64) *expvar.Int {
+	i := new(expvar.Int)
+	i.Set(v)
+	return i
+}
@@ -0,0 +1,314 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replication
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/mockclient"
+	"github.com/google/trillian/testonly"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	primaryID   = 10
+	secondaryID = 20
+)
+
+// fakeVerifier returns a fixed root, or an error, from UpdateRoot.
+type fakeVerifier struct {
+	root trillian.SignedLogRoot
+	err  error
+}
+
+func (f *fakeVerifier) AddLeaf(ctx context.Context, data []byte) error {
+	return errors.New("not implemented")
+}
+
+func (f *fakeVerifier) UpdateRoot(ctx context.Context) error {
+	return f.err
+}
+
+func (f *fakeVerifier) Root() trillian.SignedLogRoot {
+	return f.root
+}
+
+func leaf(index int64) *trillian.LogLeaf {
+	value := []byte(fmt.Sprintf("leaf %d", index))
+	return &trillian.LogLeaf{
+		MerkleLeafHash: testonly.Hasher.HashLeaf(value),
+		LeafValue:      value,
+		LeafIndex:      index,
+	}
+}
+
+// roots returns the roots of a log of the first n leaves, for each size up to n.
+func roots(n int64) ([][]byte, *merkle.InMemoryMerkleTree) {
+	tree := merkle.NewInMemoryMerkleTree(testonly.Hasher)
+	hashes := [][]byte{tree.CurrentRoot().Hash()}
+	for i := int64(0); i < n; i++ {
+		tree.AddLeaf(leaf(i).LeafValue)
+		hashes = append(hashes, tree.CurrentRoot().Hash())
+	}
+	return hashes, tree
+}
+
+func consistencyProof(tree *merkle.InMemoryMerkleTree, from, to int64) *trillian.Proof {
+	proof := &trillian.Proof{}
+	for _, n := range tree.SnapshotConsistency(from, to) {
+		proof.ProofNode = append(proof.ProofNode, &trillian.Node{NodeHash: n.Value.Hash()})
+	}
+	return proof
+}
+
+func TestRunOnce(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	hashes, tree := roots(10)
+	badHash := leaf(3)
+	badHash.MerkleLeafHash = []byte("bogus")
+
+	tests := []struct {
+		desc          string
+		primaryErr    error
+		secondaryErr  error
+		primarySize   int64
+		primaryHash   []byte
+		secondarySize int64
+		secondaryHash []byte
+		proof         *trillian.Proof
+		stored        int64
+		fetched       []*trillian.LogLeaf
+		wantIndices   []int64
+		wantCount     int
+		wantErr       string
+		wantDiverged  bool
+	}{
+		{
+			desc:       "primaryVerifyFails",
+			primaryErr: errors.New("bad signature"),
+			wantErr:    "bad signature",
+		},
+		{
+			desc:         "secondaryVerifyFails",
+			secondaryErr: errors.New("bad signature"),
+			wantErr:      "bad signature",
+		},
+		{
+			desc:          "upToDate",
+			primarySize:   5,
+			primaryHash:   hashes[5],
+			secondarySize: 5,
+			secondaryHash: hashes[5],
+			stored:        5,
+		},
+		{
+			desc:          "differentRoot",
+			primarySize:   5,
+			primaryHash:   hashes[5],
+			secondarySize: 5,
+			secondaryHash: hashes[4],
+			wantErr:       "has root",
+			wantDiverged:  true,
+		},
+		{
+			desc:          "secondaryAhead",
+			primarySize:   5,
+			primaryHash:   hashes[5],
+			secondarySize: 6,
+			secondaryHash: hashes[6],
+			wantErr:       "larger than",
+			wantDiverged:  true,
+		},
+		{
+			desc:          "inconsistent",
+			primarySize:   10,
+			primaryHash:   hashes[10],
+			secondarySize: 4,
+			secondaryHash: hashes[3],
+			proof:         consistencyProof(tree, 4, 10),
+			wantErr:       "not consistent",
+			wantDiverged:  true,
+		},
+		{
+			desc:          "storedAhead",
+			primarySize:   5,
+			primaryHash:   hashes[5],
+			secondarySize: 4,
+			secondaryHash: hashes[4],
+			proof:         consistencyProof(tree, 4, 5),
+			stored:        6,
+			wantErr:       "holds 6 leaves",
+			wantDiverged:  true,
+		},
+		{
+			desc:          "copiesBatch",
+			primarySize:   10,
+			primaryHash:   hashes[10],
+			secondarySize: 2,
+			secondaryHash: hashes[2],
+			proof:         consistencyProof(tree, 2, 10),
+			stored:        3,
+			fetched:       []*trillian.LogLeaf{leaf(3), leaf(4), leaf(5)},
+			wantIndices:   []int64{3, 4, 5},
+			wantCount:     3,
+		},
+		{
+			desc:        "copiesTail",
+			primarySize: 5,
+			primaryHash: hashes[5],
+			stored:      3,
+			fetched:     []*trillian.LogLeaf{leaf(3), leaf(4)},
+			wantIndices: []int64{3, 4},
+			wantCount:   2,
+		},
+		{
+			desc:        "badLeafHash",
+			primarySize: 10,
+			primaryHash: hashes[10],
+			stored:      3,
+			fetched:     []*trillian.LogLeaf{badHash, leaf(4), leaf(5)},
+			wantIndices: []int64{3, 4, 5},
+			wantErr:     "value hashes to",
+		},
+		{
+			desc:        "shortResponse",
+			primarySize: 10,
+			primaryHash: hashes[10],
+			stored:      3,
+			fetched:     []*trillian.LogLeaf{leaf(3)},
+			wantIndices: []int64{3, 4, 5},
+			wantErr:     "returned 1 leaves",
+		},
+	}
+
+	for _, test := range tests {
+		primary := mockclient.NewMockTrillianLogClient(ctrl)
+		secondary := mockclient.NewMockTrillianLogClient(ctrl)
+
+		if test.proof != nil {
+			req := &trillian.GetConsistencyProofRequest{LogId: primaryID, FirstTreeSize: test.secondarySize, SecondTreeSize: test.primarySize}
+			primary.EXPECT().GetConsistencyProof(gomock.Any(), req).Return(&trillian.GetConsistencyProofResponse{Proof: test.proof}, nil)
+		}
+		if test.stored > 0 {
+			secondary.EXPECT().GetSequencedLeafCount(gomock.Any(), &trillian.GetSequencedLeafCountRequest{LogId: secondaryID}).Return(&trillian.GetSequencedLeafCountResponse{LeafCount: test.stored}, nil)
+		}
+		if test.fetched != nil {
+			req := &trillian.GetLeavesByIndexRequest{LogId: primaryID, LeafIndex: test.wantIndices}
+			primary.EXPECT().GetLeavesByIndex(gomock.Any(), req).Return(&trillian.GetLeavesByIndexResponse{Leaves: test.fetched}, nil)
+			if test.wantErr == "" {
+				secondary.EXPECT().AddSequencedLeaves(gomock.Any(), &trillian.AddSequencedLeavesRequest{LogId: secondaryID, Leaves: test.fetched}).Return(&trillian.AddSequencedLeavesResponse{}, nil)
+			}
+		}
+
+		r := New(
+			Log{Name: "primary", Client: primary, LogID: primaryID, Verifier: &fakeVerifier{
+				root: trillian.SignedLogRoot{TreeSize: test.primarySize, RootHash: test.primaryHash},
+				err:  test.primaryErr,
+			}},
+			Log{Name: "secondary", Client: secondary, LogID: secondaryID, Verifier: &fakeVerifier{
+				root: trillian.SignedLogRoot{TreeSize: test.secondarySize, RootHash: test.secondaryHash},
+				err:  test.secondaryErr,
+			}},
+			testonly.Hasher, Options{BatchSize: 3})
+		count, err := r.RunOnce(context.Background())
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%v: RunOnce() = (_, %v), want err containing %q", test.desc, err, test.wantErr)
+			}
+			if _, diverged := err.(DivergenceError); diverged != test.wantDiverged {
+				t.Errorf("%v: RunOnce() = (_, %v), want DivergenceError? %v", test.desc, err, test.wantDiverged)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: RunOnce() = (_, %v), want nil", test.desc, err)
+			continue
+		}
+		if count != test.wantCount {
+			t.Errorf("%v: RunOnce() = %v, want %v", test.desc, count, test.wantCount)
+		}
+		for _, leaf := range test.fetched {
+			if len(leaf.LeafIdentityHash) == 0 {
+				t.Errorf("%v: leaf %d copied without an identity hash", test.desc, leaf.LeafIndex)
+			}
+		}
+	}
+}
+
+func TestForwardRoots(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	hashes, tree := roots(10)
+	primaryVerifier := &fakeVerifier{}
+	secondaryVerifier := &fakeVerifier{}
+	primary := mockclient.NewMockTrillianLogClient(ctrl)
+	secondary := mockclient.NewMockTrillianLogClient(ctrl)
+	r := New(
+		Log{Name: "primary", Client: primary, LogID: primaryID, Verifier: primaryVerifier},
+		Log{Name: "secondary", Client: secondary, LogID: secondaryID, Verifier: secondaryVerifier},
+		testonly.Hasher, Options{BatchSize: 10, ForwardRoots: true})
+	ctx := context.Background()
+
+	observed := func(size int64) *trillian.AddObservedRootRequest {
+		return &trillian.AddObservedRootRequest{LogId: secondaryID, SignedLogRoot: &trillian.SignedLogRoot{TreeSize: size, RootHash: hashes[size]}}
+	}
+	pass := func(primarySize, secondarySize int64) error {
+		primaryVerifier.root = trillian.SignedLogRoot{TreeSize: primarySize, RootHash: hashes[primarySize]}
+		secondaryVerifier.root = trillian.SignedLogRoot{TreeSize: secondarySize, RootHash: hashes[secondarySize]}
+		if secondarySize > 0 && secondarySize < primarySize {
+			req := &trillian.GetConsistencyProofRequest{LogId: primaryID, FirstTreeSize: secondarySize, SecondTreeSize: primarySize}
+			primary.EXPECT().GetConsistencyProof(gomock.Any(), req).Return(&trillian.GetConsistencyProofResponse{Proof: consistencyProof(tree, secondarySize, primarySize)}, nil)
+		}
+		// The secondary already holds all the leaves.
+		secondary.EXPECT().GetSequencedLeafCount(gomock.Any(), gomock.Any()).Return(&trillian.GetSequencedLeafCountResponse{LeafCount: primarySize}, nil).AnyTimes()
+		_, err := r.RunOnce(ctx)
+		return err
+	}
+
+	// Nothing is forwarded until the secondary has caught up with the roots.
+	if err := pass(4, 2); err != nil {
+		t.Fatalf("RunOnce()=%v", err)
+	}
+	if err := pass(6, 3); err != nil {
+		t.Fatalf("RunOnce()=%v", err)
+	}
+
+	// The secondary hasn't seen its latest root yet, so the next is kept for later.
+	gomock.InOrder(
+		secondary.EXPECT().AddObservedRoot(gomock.Any(), observed(4)).Return(&trillian.AddObservedRootResponse{Consistent: true}, nil),
+		secondary.EXPECT().AddObservedRoot(gomock.Any(), observed(6)).Return(nil, grpc.Errorf(codes.FailedPrecondition, "ahead")),
+	)
+	if err := pass(6, 6); err != nil {
+		t.Fatalf("RunOnce()=%v", err)
+	}
+
+	secondary.EXPECT().AddObservedRoot(gomock.Any(), observed(6)).Return(&trillian.AddObservedRootResponse{Consistent: false}, nil)
+	if err := pass(8, 7); err == nil {
+		t.Fatal("RunOnce() with an inconsistent root succeeded, want error")
+	} else if _, ok := err.(DivergenceError); !ok {
+		t.Errorf("RunOnce()=%v, want DivergenceError", err)
+	}
+}
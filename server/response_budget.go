@@ -0,0 +1,59 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"expvar"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// leafReadChunk is how many leaves are read from storage at a time when there's a
+// response size limit, so a response over the limit is noticed before all of its
+// leaves are in memory.
+const leafReadChunk = 256
+
+// overBudget counts the responses which failed for being over the size limit, by RPC.
+var overBudget = expvar.NewMap("log-responses-over-budget")
+
+// responseBudget tracks the size of the response an RPC is building against the
+// MaxResponseBytes limit.
+type responseBudget struct {
+	rpc   string
+	limit int64
+	used  int64
+	// hint tells clients what to do instead when the response is over the limit.
+	hint string
+}
+
+func (t *TrillianLogRPCServer) newResponseBudget(rpc, hint string) *responseBudget {
+	return &responseBudget{rpc: rpc, limit: t.limits.MaxResponseBytes, hint: hint}
+}
+
+// charge adds the encoded size of msg to the response, and fails with OutOfRange
+// once that's over the limit.
+func (b *responseBudget) charge(msg proto.Message) error {
+	if b.limit <= 0 {
+		return nil
+	}
+	b.used += int64(proto.Size(msg))
+	if b.used > b.limit {
+		overBudget.Add(b.rpc, 1)
+		return grpc.Errorf(codes.OutOfRange, "%s response is over the limit of %d bytes, %s", b.rpc, b.limit, b.hint)
+	}
+	return nil
+}
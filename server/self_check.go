@@ -0,0 +1,101 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/monitoring/logging"
+)
+
+// selfCheck verifies the inclusion proofs of up to s.selfCheckSamples leaves of logID,
+// picked at random, against its latest root, as well as the Merkle leaf hashes stored
+// for them, counting each leaf that fails in selfCheckFailures. Errors reading from
+// storage are returned, as they don't show storage and hashing disagree.
+func (s SequencerManager) selfCheck(ctx context.Context, logID int64, hasher merkle.TreeHasher) error {
+	tx, err := s.registry.LogStorage.SnapshotForTree(ctx, logID)
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+
+	root, err := tx.LatestSignedLogRoot()
+	if err != nil {
+		return err
+	}
+	if root.TreeSize == 0 {
+		return tx.Commit()
+	}
+	leaves, err := tx.GetLeavesByIndex(sampleLeafIndices(root.TreeSize, s.selfCheckSamples))
+	if err != nil {
+		return err
+	}
+
+	verifier := merkle.NewLogVerifier(hasher)
+	for _, leaf := range leaves {
+		proof, err := getInclusionProofForLeafIndex(tx, root.TreeSize, leaf.LeafIndex, root.TreeSize)
+		if err != nil {
+			return err
+		}
+		selfChecks.Add(logKey(logID), 1)
+		if err := checkLeaf(verifier, hasher, root, leaf, proof); err != nil {
+			selfCheckFailures.Add(logKey(logID), 1)
+			logging.Errorf(ctx, "self-check of leaf %d against root of size %d failed: %v", leaf.LeafIndex, root.TreeSize, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// checkLeaf returns an error unless proof proves leaf is included in root, and the leaf's
+// Merkle leaf hash is the hash of its value. The latter is only checked while the leaf
+// still has its value, as leaves which have expired don't.
+func checkLeaf(verifier merkle.LogVerifier, hasher merkle.TreeHasher, root trillian.SignedLogRoot, leaf *trillian.LogLeaf, proof trillian.Proof) error {
+	if !leaf.Expired {
+		if got := hasher.HashLeaf(leaf.LeafValue); !bytes.Equal(got, leaf.MerkleLeafHash) {
+			return fmt.Errorf("leaf value hashes to %x, stored Merkle leaf hash is %x", got, leaf.MerkleLeafHash)
+		}
+	}
+	hashes := make([][]byte, 0, len(proof.ProofNode))
+	for _, node := range proof.ProofNode {
+		hashes = append(hashes, node.NodeHash)
+	}
+	return verifier.VerifyInclusionProof(leaf.LeafIndex, root.TreeSize, hashes, root.RootHash, leaf.MerkleLeafHash)
+}
+
+// sampleLeafIndices returns n distinct leaf indices below treeSize picked at random, or
+// all of them if the tree has no more than n leaves.
+func sampleLeafIndices(treeSize int64, n int) []int64 {
+	if treeSize <= int64(n) {
+		indices := make([]int64, treeSize)
+		for i := range indices {
+			indices[i] = int64(i)
+		}
+		return indices
+	}
+	picked := make(map[int64]bool)
+	indices := make([]int64, 0, n)
+	for len(indices) < n {
+		if index := rand.Int63n(treeSize); !picked[index] {
+			picked[index] = true
+			indices = append(indices, index)
+		}
+	}
+	return indices
+}
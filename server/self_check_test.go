@@ -0,0 +1,114 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"expvar"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/testonly"
+)
+
+func TestSelfCheck(t *testing.T) {
+	value := []byte("value")
+	leafHash := testonly.Hasher.HashLeaf(value)
+
+	for _, test := range []struct {
+		desc         string
+		leaf         trillian.LogLeaf
+		rootHash     []byte
+		wantFailures int64
+	}{
+		{
+			desc:     "valid",
+			leaf:     trillian.LogLeaf{LeafValue: value, MerkleLeafHash: leafHash},
+			rootHash: leafHash,
+		},
+		{
+			desc:         "wrongRoot",
+			leaf:         trillian.LogLeaf{LeafValue: value, MerkleLeafHash: leafHash},
+			rootHash:     testonly.Hasher.HashLeaf([]byte("other")),
+			wantFailures: 1,
+		},
+		{
+			desc:         "wrongLeafHash",
+			leaf:         trillian.LogLeaf{LeafValue: []byte("other"), MerkleLeafHash: leafHash},
+			rootHash:     leafHash,
+			wantFailures: 1,
+		},
+		{
+			desc:     "expired",
+			leaf:     trillian.LogLeaf{MerkleLeafHash: leafHash, Expired: true},
+			rootHash: leafHash,
+		},
+	} {
+		func() {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			// A single leaf tree, whose root is the leaf's hash and has empty proofs.
+			logID := int64(7)
+			mockStorage := storage.NewMockLogStorage(mockCtrl)
+			mockTx := storage.NewMockReadOnlyLogTreeTX(mockCtrl)
+			mockStorage.EXPECT().SnapshotForTree(gomock.Any(), logID).Return(mockTx, nil)
+			mockTx.EXPECT().LatestSignedLogRoot().Return(trillian.SignedLogRoot{TreeSize: 1, TreeRevision: 3, RootHash: test.rootHash}, nil)
+			mockTx.EXPECT().GetLeavesByIndex([]int64{0}).Return([]*trillian.LogLeaf{&test.leaf}, nil)
+			mockTx.EXPECT().ReadRevision().AnyTimes().Return(int64(3))
+			mockTx.EXPECT().GetMerkleNodes(int64(3), gomock.Any()).AnyTimes().Return(nil, nil)
+			mockTx.EXPECT().Commit().Return(nil)
+			mockTx.EXPECT().Close().Return(nil)
+
+			failures := func() int64 {
+				if v, ok := selfCheckFailures.Get(logKey(logID)).(*expvar.Int); ok {
+					return v.Value()
+				}
+				return 0
+			}
+			before := failures()
+
+			sm := NewSequencerManager(extension.Registry{LogStorage: mockStorage}, zeroDuration)
+			sm.EnableSelfCheck(3)
+			if err := sm.selfCheck(context.Background(), logID, testonly.Hasher); err != nil {
+				t.Errorf("%v: selfCheck()=%v, want nil", test.desc, err)
+			}
+			if got := failures() - before; got != test.wantFailures {
+				t.Errorf("%v: selfCheck() counted %v failures, want %v", test.desc, got, test.wantFailures)
+			}
+		}()
+	}
+}
+
+func TestSampleLeafIndices(t *testing.T) {
+	if got := sampleLeafIndices(2, 5); len(got) != 2 || got[0] != 0 || got[1] != 1 {
+		t.Errorf("sampleLeafIndices(2, 5)=%v, want [0 1]", got)
+	}
+	got := sampleLeafIndices(100, 5)
+	seen := make(map[int64]bool)
+	for _, index := range got {
+		if index < 0 || index >= 100 || seen[index] {
+			t.Errorf("sampleLeafIndices(100, 5)=%v, want 5 distinct indices below 100", got)
+			break
+		}
+		seen[index] = true
+	}
+	if len(got) != 5 {
+		t.Errorf("sampleLeafIndices(100, 5) returned %d indices, want 5", len(got))
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/google/trillian"
+	"github.com/google/trillian/server/errors"
+	"github.com/google/trillian/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// SequencePath is the path NewSequenceHandler is served on by the log signer.
+const SequencePath = "/sequence"
+
+// httpStatusCodes maps the HTTP statuses of sequencing failures to gRPC codes, statuses
+// not listed are Internal.
+var httpStatusCodes = map[int]codes.Code{
+	http.StatusBadRequest:         codes.InvalidArgument,
+	http.StatusUnauthorized:       codes.Unauthenticated,
+	http.StatusNotFound:           codes.NotFound,
+	http.StatusPreconditionFailed: codes.FailedPrecondition,
+	http.StatusServiceUnavailable: codes.Unavailable,
+}
+
+// NewSequenceHandler returns an HTTP handler which sequences the log given by the log_id
+// query parameter of POST requests straight away, with SequencerManager.SequenceNow, and
+// responds with a JSON SequenceLogResponse. It lets log servers pass SequenceLog admin
+// RPCs on to the signer, see SequenceOverHTTP. Requests must present token as a bearer
+// token, all of them are refused if it's empty.
+func NewSequenceHandler(manager *SequencerManager, batchSize int, token string, timeSource util.TimeSource) http.Handler {
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			http.Error(w, "missing or wrong bearer token", http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+			return
+		}
+		logID, err := strconv.ParseInt(r.URL.Query().Get("log_id"), 10, 64)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid log_id: %v", err), http.StatusBadRequest)
+			return
+		}
+		leaves, root, err := manager.SequenceNow(r.Context(), logID, batchSize, timeSource)
+		if err != nil {
+			glog.Warningf("%v: failed to sequence on demand: %v", logID, err)
+			status, code := http.StatusInternalServerError, grpc.Code(errors.WrapError(err))
+			for s, c := range httpStatusCodes {
+				if c == code {
+					status = s
+				}
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		body, err := (&jsonpb.Marshaler{}).MarshalToString(&trillian.SequenceLogResponse{
+			LeavesSequenced: int64(leaves),
+			SignedLogRoot:   &root,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, body)
+	})
+}
+
+// SequenceOverHTTP returns a function which sequences logs by POSTing to the
+// NewSequenceHandler of the signer whose HTTP server is at baseURL, e.g.
+// http://localhost:8091, presenting token. Failures are returned as gRPC errors.
+func SequenceOverHTTP(baseURL, token string, client *http.Client) func(ctx context.Context, logID int64) (*trillian.SequenceLogResponse, error) {
+	endpoint := strings.TrimSuffix(baseURL, "/") + SequencePath
+	return func(ctx context.Context, logID int64) (*trillian.SequenceLogResponse, error) {
+		req, err := http.NewRequest(http.MethodPost, endpoint+"?"+url.Values{"log_id": {strconv.FormatInt(logID, 10)}}.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		rsp, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			return nil, grpc.Errorf(codes.Unavailable, "failed to reach signer: %v", err)
+		}
+		defer rsp.Body.Close()
+		if rsp.StatusCode != http.StatusOK {
+			body, _ := ioutil.ReadAll(rsp.Body)
+			code, ok := httpStatusCodes[rsp.StatusCode]
+			if !ok {
+				code = codes.Internal
+			}
+			return nil, grpc.Errorf(code, "signer failed to sequence log %d: %s", logID, strings.TrimSpace(string(body)))
+		}
+		var seqRsp trillian.SequenceLogResponse
+		if err := jsonpb.Unmarshal(rsp.Body, &seqRsp); err != nil {
+			return nil, grpc.Errorf(codes.Internal, "invalid response from signer: %v", err)
+		}
+		return &seqRsp, nil
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/storage"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func TestSequenceHandler(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// No storage calls are expected, the requests are either invalid or for a log which
+	// stays locked.
+	registry := extension.Registry{
+		AdminStorage: storage.NewMockAdminStorage(mockCtrl),
+		LogStorage:   storage.NewMockLogStorage(mockCtrl),
+	}
+	sm := NewSequencerManager(registry, zeroDuration)
+	sm.locks.tryLock(testLogID1)
+
+	mux := http.NewServeMux()
+	mux.Handle(SequencePath, NewSequenceHandler(sm, 50, "secret", fakeTimeSource))
+	s := httptest.NewServer(mux)
+	defer s.Close()
+
+	if rsp, err := http.Post(s.URL+SequencePath+"?log_id=1", "", nil); err != nil || rsp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("POST %v without a token=%v, %v, want status %v", SequencePath, rsp, err, http.StatusUnauthorized)
+	}
+	if _, err := SequenceOverHTTP(s.URL, "wrong", http.DefaultClient)(context.Background(), testLogID1); grpc.Code(err) != codes.Unauthenticated {
+		t.Errorf("sequence(%v) with the wrong token=%v, want Unauthenticated", testLogID1, err)
+	}
+	req, err := http.NewRequest(http.MethodGet, s.URL+SequencePath+"?log_id=1", nil)
+	if err != nil {
+		t.Fatalf("NewRequest()=%v", err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	if rsp, err := http.DefaultClient.Do(req); err != nil || rsp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET %v=%v, %v, want status %v", SequencePath, rsp, err, http.StatusMethodNotAllowed)
+	}
+
+	sequence := SequenceOverHTTP(s.URL, "secret", http.DefaultClient)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*lockRetryInterval)
+	defer cancel()
+	if _, err := sequence(ctx, testLogID1); grpc.Code(err) == codes.OK {
+		t.Errorf("sequence(%v)=%v, want an error while the log is locked", testLogID1, err)
+	}
+
+	if _, err := SequenceOverHTTP(s.URL+"/missing", "secret", http.DefaultClient)(context.Background(), testLogID1); grpc.Code(err) != codes.NotFound {
+		t.Errorf("sequence(%v) at the wrong URL=%v, want NotFound", testLogID1, err)
+	}
+
+	// No token is accepted if the handler has none.
+	noToken := httptest.NewServer(NewSequenceHandler(sm, 50, "", fakeTimeSource))
+	defer noToken.Close()
+	if _, err := SequenceOverHTTP(noToken.URL, "", http.DefaultClient)(context.Background(), testLogID1); grpc.Code(err) != codes.Unauthenticated {
+		t.Errorf("sequence(%v) without a token configured=%v, want Unauthenticated", testLogID1, err)
+	}
+}
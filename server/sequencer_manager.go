@@ -21,17 +21,144 @@ import (
 	"time"
 
 	"github.com/golang/glog"
+	"github.com/google/trillian"
 	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/crypto/checkpoint"
 	"github.com/google/trillian/extension"
 	"github.com/google/trillian/log"
 	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/monitoring/logging"
 	"github.com/google/trillian/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 )
 
 // SequencerManager provides sequencing operations for a collection of Logs.
 type SequencerManager struct {
 	guardWindow time.Duration
 	registry    extension.Registry
+	schedule    *sequencingSchedule
+	locks       *logLocks
+	// sizer is nil unless adaptive batching is enabled.
+	sizer *batchSizer
+	// fairness is nil unless a FairnessPolicy is enabled.
+	fairness     *fairScheduler
+	queueMetrics bool
+	// selfCheckSamples is the number of leaves whose inclusion is verified after each
+	// run which signs a new root, none if zero.
+	selfCheckSamples int
+	rootPublishers   []RootPublisher
+	leafPublishers   []LeafPublisher
+	// checkpointOrigin is the prefix of each log's checkpoint origin, checkpoints are
+	// only signed if it's set.
+	checkpointOrigin     string
+	checkpointPublishers []CheckpointPublisher
+}
+
+// RootPublisher is given each root signed by the SequencerManager, e.g. to push it to
+// monitors. Roots are published from the sequencing workers, so PublishRoot must be safe
+// for concurrent use and should not block.
+type RootPublisher interface {
+	PublishRoot(root trillian.SignedLogRoot)
+}
+
+// LeafPublisher is given each batch of leaves integrated by the SequencerManager, with
+// their assigned LeafIndex, e.g. to push them to downstream indexers. As with
+// RootPublisher, PublishLeaves must be safe for concurrent use and should not block.
+type LeafPublisher interface {
+	PublishLeaves(logID int64, leaves []*trillian.LogLeaf)
+}
+
+// CheckpointPublisher is given each new root as a signed checkpoint note, in the format
+// understood by witnesses (see the crypto/checkpoint package). As with RootPublisher,
+// PublishCheckpoint must be safe for concurrent use and should not block.
+type CheckpointPublisher interface {
+	PublishCheckpoint(logID int64, note []byte)
+}
+
+// mergeDelayCheckInterval is how often the merge delay of a log with a backlog is
+// checked, rather than after each of the full batches it's sequenced in.
+const mergeDelayCheckInterval = 10 * time.Second
+
+// sequencingSchedule tracks when each log is next due to be sequenced, so that
+// per-tree sequencing intervals can be honored.
+type sequencingSchedule struct {
+	mu  sync.Mutex
+	due map[int64]time.Time
+	// mergeChecked holds when the merge delay of each log was last checked.
+	mergeChecked map[int64]time.Time
+}
+
+// isDue returns true if logID should be sequenced at now.
+func (s *sequencingSchedule) isDue(logID int64, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !now.Before(s.due[logID])
+}
+
+// sequenced records that logID was sequenced at now. Logs that didn't fill a whole
+// batch aren't due again until interval has elapsed, logs with a backlog are due
+// straight away.
+func (s *sequencingSchedule) sequenced(logID int64, now time.Time, interval time.Duration, fullBatch bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if fullBatch || interval <= 0 {
+		delete(s.due, logID)
+		return
+	}
+	s.due[logID] = now.Add(interval)
+}
+
+// mergeDelayCheckDue returns whether logID's merge delay should be checked after a
+// pass at now. It's checked after each pass which doesn't fill a whole batch, but only
+// every mergeDelayCheckInterval while the log has a backlog, whose leaves are the most
+// likely to breach it.
+func (s *sequencingSchedule) mergeDelayCheckDue(logID int64, now time.Time, fullBatch bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if fullBatch && now.Before(s.mergeChecked[logID].Add(mergeDelayCheckInterval)) {
+		return false
+	}
+	s.mergeChecked[logID] = now
+	return true
+}
+
+// expedite makes logID due for sequencing straight away.
+func (s *sequencingSchedule) expedite(logID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.due, logID)
+}
+
+// errVerificationOnly is returned when sequencing a log which only has a public key, so
+// can't have its roots signed.
+var errVerificationOnly = grpc.Errorf(codes.FailedPrecondition, "log is verification-only, it has no private key to sign roots with")
+
+// lockRetryInterval is how often SequenceNow tries to lock a log being sequenced by
+// a pass.
+const lockRetryInterval = 10 * time.Millisecond
+
+// logLocks records the logs that are being sequenced.
+type logLocks struct {
+	mu   sync.Mutex
+	held map[int64]bool
+}
+
+// tryLock returns true if it acquired the lock for logID, and false if it's already held.
+func (l *logLocks) tryLock(logID int64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.held[logID] {
+		return false
+	}
+	l.held[logID] = true
+	return true
+}
+
+func (l *logLocks) unlock(logID int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.held, logID)
 }
 
 // NewSequencerManager creates a new SequencerManager instance based on the provided KeyManager instance
@@ -40,16 +167,73 @@ func NewSequencerManager(registry extension.Registry, gw time.Duration) *Sequenc
 	return &SequencerManager{
 		guardWindow: gw,
 		registry:    registry,
+		schedule:    &sequencingSchedule{due: make(map[int64]time.Time), mergeChecked: make(map[int64]time.Time)},
+		locks:       &logLocks{held: make(map[int64]bool)},
 	}
 }
 
+// EnableAdaptiveBatching makes the manager adapt the number of leaves sequenced per pass
+// for each log, between minSize and maxSize, based on whether the log has a backlog and
+// whether sequencing a batch takes longer than targetLatency. Trees which set their own
+// sequencing batch size always use that instead.
+func (s *SequencerManager) EnableAdaptiveBatching(minSize, maxSize int, targetLatency time.Duration) {
+	s.sizer = newBatchSizer(minSize, maxSize, targetLatency)
+}
+
+// EnableFairness makes the manager share each pass between logs according to policy,
+// limiting each log to budget leaves per pass, or a multiple of it for prioritised logs
+// with FairnessWeighted. Logs which use up their budget are sequenced again on the
+// next pass, as with any log that fills a whole batch.
+func (s *SequencerManager) EnableFairness(policy FairnessPolicy, budget int) {
+	s.fairness = newFairScheduler(policy, budget)
+}
+
+// EnableQueueMetrics makes the manager export the number of unsequenced leaves, the age
+// of the oldest of them, and the age and size of the latest root, for each log after
+// sequencing it. This costs extra storage queries per log per pass.
+func (s *SequencerManager) EnableQueueMetrics() {
+	s.queueMetrics = true
+}
+
+// EnableSelfCheck makes the manager verify the inclusion proofs of samples leaves of
+// each log, picked at random, against the new root after each run which signs one, and
+// the leaves' Merkle leaf hashes against their values. Leaves which fail are counted
+// in the sequencer-self-check-failures metric, as a cheap, continuous check that
+// storage and hashing agree. This costs extra storage reads per log per run.
+func (s *SequencerManager) EnableSelfCheck(samples int) {
+	s.selfCheckSamples = samples
+}
+
+// AddRootPublisher makes the manager pass each new root to p once it has been committed.
+func (s *SequencerManager) AddRootPublisher(p RootPublisher) {
+	s.rootPublishers = append(s.rootPublishers, p)
+}
+
+// AddLeafPublisher makes the manager pass each batch of integrated leaves to p once the
+// batch has been committed.
+func (s *SequencerManager) AddLeafPublisher(p LeafPublisher) {
+	s.leafPublishers = append(s.leafPublishers, p)
+}
+
+// AddCheckpointPublisher makes the manager sign a checkpoint note for each new root once
+// it has been committed, and pass it to p. Each log's checkpoint origin, which is also the
+// name of its signing key, is originPrefix followed by "/" and the log ID.
+func (s *SequencerManager) AddCheckpointPublisher(originPrefix string, p CheckpointPublisher) {
+	s.checkpointOrigin = originPrefix
+	s.checkpointPublishers = append(s.checkpointPublishers, p)
+}
+
 // Name returns the name of the object.
 func (s SequencerManager) Name() string {
 	return "Sequencer"
 }
 
-// ExecutePass performs sequencing for the specified set of Logs.
-func (s SequencerManager) ExecutePass(logIDs []int64, logctx LogOperationManagerContext) {
+// ExecutePass performs sequencing for the specified set of Logs, using up to numSequencers
+// workers from logctx to sequence them in parallel. The returned result holds the
+// number of leaves sequenced for each log. Trees may override the batch size in logctx and
+// set an interval between sequencing passes, logs that aren't due yet are skipped and
+// reported as having sequenced no leaves.
+func (s SequencerManager) ExecutePass(logIDs []int64, logctx LogOperationManagerContext) *PassResult {
 	if logctx.numSequencers == 0 {
 		glog.Warning("Called ExecutePass with numSequencers == 0, assuming 1")
 		logctx.numSequencers = 1
@@ -61,10 +245,19 @@ func (s SequencerManager) ExecutePass(logIDs []int64, logctx LogOperationManager
 	var mu sync.Mutex
 	successCount := 0
 	leavesAdded := 0
+	result := &PassResult{Processed: make(map[int64]int)}
+	fail := func(logID int64) {
+		mu.Lock()
+		defer mu.Unlock()
+		result.Failed = append(result.Failed, logID)
+	}
 
 	var wg sync.WaitGroup
 	toSeq := make(chan int64, len(logIDs))
 
+	if s.fairness != nil {
+		logIDs = s.fairness.order(logIDs)
+	}
 	for _, logID := range logIDs {
 		toSeq <- logID
 	}
@@ -80,39 +273,33 @@ func (s SequencerManager) ExecutePass(logIDs []int64, logctx LogOperationManager
 					return
 				}
 
-				start := time.Now()
-
-				// TODO(Martin2112): Honor the sequencing enabled in log parameters, needs an API change
-				// so deferring it
-				ctx := util.NewLogContext(logctx.ctx, logID)
-
-				// TODO(Martin2112): Allow for different tree hashers to be used by different logs
-				hasher, err := merkle.Factory(merkle.RFC6962SHA256Type)
-				if err != nil {
-					glog.Errorf("Unknown hash strategy for log %d: %v", logID, err)
+				// Overlapping passes, or a log listed more than once, mustn't have two
+				// workers sequencing the same log.
+				if !s.locks.tryLock(logID) {
+					glog.Warningf("%v: already being sequenced, skipping", logID)
+					mu.Lock()
+					result.Processed[logID] = 0
+					mu.Unlock()
 					continue
 				}
-
-				signer, err := newSigner(ctx, s.registry, logID)
-				if err != nil {
-					glog.Errorf("Could not get signer for log %d: %v", logID, err)
-					continue
+				leaves, fullBatch, err := s.sequenceLog(logctx, logID)
+				s.locks.unlock(logID)
+				if err == errVerificationOnly {
+					// Not a failure, there's just nothing for the signer to do.
+					err = nil
 				}
-
-				sequencer := log.NewSequencer(hasher, logctx.timeSource, s.registry.LogStorage, signer)
-				sequencer.SetGuardWindow(s.guardWindow)
-
-				leaves, err := sequencer.SequenceBatch(ctx, logID, logctx.batchSize)
 				if err != nil {
-					glog.Warningf("%v: Error trying to sequence batch for: %v", logID, err)
+					fail(logID)
 					continue
 				}
-				d := time.Now().Sub(start).Seconds()
-				glog.Infof("%v: sequenced %d leaves in %.2f seconds (%.2f qps)", logID, leaves, d, float64(leaves)/d)
 
 				mu.Lock()
 				successCount++
 				leavesAdded += leaves
+				result.Processed[logID] = leaves
+				if fullBatch {
+					result.Backlogged = append(result.Backlogged, logID)
+				}
 				mu.Unlock()
 			}
 		}()
@@ -124,15 +311,179 @@ func (s SequencerManager) ExecutePass(logIDs []int64, logctx LogOperationManager
 	mu.Lock()
 	defer mu.Unlock()
 	glog.V(1).Infof("Sequencing group run completed in %.2f seconds: %v succeeded, %v failed, %v leaves integrated", d, successCount, len(logIDs)-successCount, leavesAdded)
+	return result
+}
+
+// SequenceNow sequences logID straight away, whether or not it's due, in batches of up
+// to batchSize leaves until its queue is empty, e.g. after a bulk import. If a pass is
+// already sequencing the log it waits for it to finish. It returns the number of leaves
+// sequenced and the log's latest root. Verification-only logs can't be sequenced.
+func (s SequencerManager) SequenceNow(ctx context.Context, logID int64, batchSize int, timeSource util.TimeSource) (int, trillian.SignedLogRoot, error) {
+	for !s.locks.tryLock(logID) {
+		select {
+		case <-ctx.Done():
+			return 0, trillian.SignedLogRoot{}, ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+	}
+	defer s.locks.unlock(logID)
+
+	logctx := LogOperationManagerContext{
+		ctx:           ctx,
+		registry:      s.registry,
+		batchSize:     batchSize,
+		timeSource:    timeSource,
+		numSequencers: 1,
+	}
+	total := 0
+	for {
+		s.schedule.expedite(logID)
+		leaves, fullBatch, err := s.sequenceLog(logctx, logID)
+		if err != nil {
+			return total, trillian.SignedLogRoot{}, err
+		}
+		total += leaves
+		if !fullBatch || leaves == 0 {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return total, trillian.SignedLogRoot{}, err
+		}
+	}
+	root, err := latestRoot(ctx, s.registry.LogStorage, logID)
+	return total, root, err
+}
+
+// sequenceLog sequences a batch of leaves for logID, unless it isn't due yet. It returns
+// the number of leaves sequenced and whether they filled a whole batch, or
+// errVerificationOnly if the log has no private key.
+func (s SequencerManager) sequenceLog(logctx LogOperationManagerContext, logID int64) (int, bool, error) {
+	start := time.Now()
+
+	// TODO(Martin2112): Honor the sequencing enabled in log parameters, needs an API change
+	// so deferring it
+	ctx := util.NewLogContext(logctx.ctx, logID)
+
+	// TODO(Martin2112): Allow for different tree hashers to be used by different logs
+	hasher, err := merkle.Factory(merkle.RFC6962SHA256Type)
+	if err != nil {
+		logging.Errorf(ctx, "Unknown hash strategy: %v", err)
+		return 0, false, err
+	}
+
+	tree, err := getTree(ctx, s.registry, logID)
+	if err != nil {
+		logging.Errorf(ctx, "Could not get tree: %v", err)
+		return 0, false, err
+	}
+	if tree.PrivateKey == nil {
+		// Verification-only logs are only written to by importing roots, with AddObservedRoot.
+		if logging.V(1) {
+			logging.Infof(ctx, "verification-only, not sequencing")
+		}
+		return 0, false, errVerificationOnly
+	}
+
+	now := logctx.timeSource.Now()
+	if !s.schedule.isDue(logID, now) {
+		if logging.V(1) {
+			logging.Infof(ctx, "not due for sequencing yet")
+		}
+		return 0, false, nil
+	}
+
+	signer, err := newSigner(ctx, s.registry, tree)
+	if err != nil {
+		logging.Errorf(ctx, "Could not get signer: %v", err)
+		return 0, false, err
+	}
+
+	sequencer := log.NewSequencer(hasher, logctx.timeSource, s.registry.LogStorage, signer)
+	guardWindow := s.guardWindow
+	if tree.SequencingGuardWindowSeconds > 0 {
+		guardWindow = time.Duration(tree.SequencingGuardWindowSeconds) * time.Second
+	}
+	sequencer.SetGuardWindow(guardWindow)
+	sequencer.SetPreordered(tree.TreeType == trillian.TreeType_PREORDERED_LOG)
+	if len(s.rootPublishers) > 0 || len(s.checkpointPublishers) > 0 {
+		sequencer.SetRootObserver(func(root trillian.SignedLogRoot) {
+			for _, p := range s.rootPublishers {
+				p.PublishRoot(root)
+			}
+			if len(s.checkpointPublishers) > 0 {
+				s.publishCheckpoint(logID, root, signer)
+			}
+		})
+	}
+	if len(s.leafPublishers) > 0 {
+		sequencer.SetLeavesObserver(func(logID int64, leaves []*trillian.LogLeaf) {
+			for _, p := range s.leafPublishers {
+				p.PublishLeaves(logID, leaves)
+			}
+		})
+	}
+
+	batchSize := logctx.batchSize
+	adaptive := s.sizer != nil && tree.SequencingBatchSize == 0
+	switch {
+	case tree.SequencingBatchSize > 0:
+		batchSize = int(tree.SequencingBatchSize)
+	case adaptive:
+		batchSize = s.sizer.size(logID, logctx.batchSize)
+	}
+	// A log held back by its fairness budget doesn't count against its adaptive batch
+	// size, it just fills the whole (smaller) batch and is due again straight away.
+	limit := batchSize
+	if s.fairness != nil {
+		limit = s.fairness.limit(logID, batchSize, tree.SequencingPriority)
+	}
+	batchStart := time.Now()
+	leaves, err := sequencer.SequenceBatch(ctx, logID, limit)
+	if err != nil {
+		logging.Warningf(ctx, "Error trying to sequence batch: %v", err)
+		return 0, false, err
+	}
+	batchLatency := time.Now().Sub(batchStart)
+	recordRun(logID, leaves, batchLatency)
+	if adaptive {
+		s.sizer.update(logID, batchSize, leaves, batchLatency)
+	}
+	if s.selfCheckSamples > 0 && leaves > 0 {
+		if err := s.selfCheck(ctx, logID, hasher); err != nil {
+			logging.Warningf(ctx, "Failed to self-check the new root: %v", err)
+		}
+	}
+	if s.queueMetrics {
+		if err := s.recordQueueStats(ctx, logID, logctx.timeSource.Now()); err != nil {
+			logging.Warningf(ctx, "Failed to read queue stats: %v", err)
+		}
+	}
+	fullBatch := leaves >= limit
+	s.schedule.sequenced(logID, now, time.Duration(tree.SequencingIntervalSeconds)*time.Second, fullBatch)
+	if tree.MaxMergeDelaySeconds > 0 && s.schedule.mergeDelayCheckDue(logID, now, fullBatch) {
+		if err := s.checkMergeDelay(ctx, tree, logctx.timeSource.Now()); err != nil {
+			logging.Warningf(ctx, "Failed to check merge delay: %v", err)
+		}
+	}
+	if tree.ShardSetId != 0 && tree.TreeState == trillian.TreeState_ACTIVE && !fullBatch {
+		if err := s.freezeEndedShard(ctx, tree, now, guardWindow); err != nil {
+			logging.Warningf(ctx, "Failed to freeze shard: %v", err)
+		}
+	}
+	if tree.TreeState == trillian.TreeState_FROZEN && tree.FinalizeTimeMillisSinceEpoch == 0 && !fullBatch {
+		if err := s.finalizeFrozenLog(ctx, tree, sequencer, now); err != nil {
+			logging.Warningf(ctx, "Failed to finalize frozen log: %v", err)
+		}
+	}
+	d := time.Now().Sub(start).Seconds()
+	logging.Infof(ctx, "sequenced %d leaves in %.2f seconds (%.2f qps)", leaves, d, float64(leaves)/d)
+	return leaves, fullBatch, nil
 }
 
-func newSigner(ctx context.Context, registry extension.Registry, logID int64) (*crypto.Signer, error) {
+func getTree(ctx context.Context, registry extension.Registry, logID int64) (*trillian.Tree, error) {
 	if registry.AdminStorage == nil {
 		return nil, fmt.Errorf("no AdminStorage provided by registry")
 	}
-	if registry.SignerFactory == nil {
-		return nil, fmt.Errorf("no SignerFactory provided by registry")
-	}
 
 	snapshot, err := registry.AdminStorage.Snapshot(ctx)
 	if err != nil {
@@ -149,6 +500,151 @@ func newSigner(ctx context.Context, registry extension.Registry, logID int64) (*
 		return nil, err
 	}
 
+	return tree, nil
+}
+
+// checkMergeDelay counts a breach of tree's maximum merge delay if its oldest queued leaf
+// has been waiting for longer than it, and makes the log due straight away if the leaf
+// would otherwise be waiting for longer than it by the time the log is next due.
+func (s SequencerManager) checkMergeDelay(ctx context.Context, tree *trillian.Tree, now time.Time) error {
+	tx, err := s.registry.LogStorage.SnapshotForTree(ctx, tree.TreeId)
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	queued, oldest, err := tx.GetUnsequencedStats()
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if queued == 0 {
+		return nil
+	}
+
+	mmd := time.Duration(tree.MaxMergeDelaySeconds) * time.Second
+	age := now.Sub(oldest)
+	if age > mmd {
+		mergeDelayBreaches.Add(logKey(tree.TreeId), 1)
+		logging.Warningf(ctx, "oldest queued leaf has been waiting for %v, over the maximum merge delay of %v", age, mmd)
+	}
+	if age+time.Duration(tree.SequencingIntervalSeconds)*time.Second >= mmd {
+		s.schedule.expedite(tree.TreeId)
+	}
+	return nil
+}
+
+// freezeEndedShard freezes tree, which is a shard, once its window has ended and its
+// queue is empty. The guard window gives leaves queued just before the end, e.g. by a
+// log server whose clock is behind, time to arrive and be sequenced.
+func (s SequencerManager) freezeEndedShard(ctx context.Context, tree *trillian.Tree, now time.Time, guardWindow time.Duration) error {
+	end := time.Unix(0, tree.ShardEndMillisSinceEpoch*int64(time.Millisecond))
+	if now.Before(end.Add(guardWindow)) {
+		return nil
+	}
+
+	tx, err := s.registry.LogStorage.SnapshotForTree(ctx, tree.TreeId)
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	queued, _, err := tx.GetUnsequencedStats()
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if queued > 0 {
+		return nil
+	}
+
+	atx, err := s.registry.AdminStorage.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer atx.Close()
+	if _, err := atx.UpdateTree(ctx, tree.TreeId, func(t *trillian.Tree) {
+		t.TreeState = trillian.TreeState_FROZEN
+	}); err != nil {
+		return err
+	}
+	if err := atx.Commit(); err != nil {
+		return err
+	}
+	logging.Infof(ctx, "froze shard of set %d, its window ended at %v", tree.ShardSetId, end)
+	return nil
+}
+
+// finalizeFrozenLog finalizes tree, which is FROZEN, once the leaves queued before it was
+// frozen have all been sequenced: it signs a final root and records its size on the tree,
+// so readers can tell they've seen the whole log. Nothing can be queued to a frozen log,
+// so the final root stays the latest one for as long as the log is frozen.
+func (s SequencerManager) finalizeFrozenLog(ctx context.Context, tree *trillian.Tree, sequencer *log.Sequencer, now time.Time) error {
+	tx, err := s.registry.LogStorage.SnapshotForTree(ctx, tree.TreeId)
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	queued, _, err := tx.GetUnsequencedStats()
+	if err != nil {
+		return err
+	}
+	root, err := tx.LatestSignedLogRoot()
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if queued > 0 {
+		return nil
+	}
+
+	if err := sequencer.SignRoot(ctx, tree.TreeId); err != nil {
+		return err
+	}
+	atx, err := s.registry.AdminStorage.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer atx.Close()
+	if _, err := atx.UpdateTree(ctx, tree.TreeId, func(t *trillian.Tree) {
+		// The log may have been unfrozen, and leaves added to it, since it was read.
+		if t.TreeState == trillian.TreeState_FROZEN && t.UpdateTimeMillisSinceEpoch == tree.UpdateTimeMillisSinceEpoch {
+			t.FinalizeTimeMillisSinceEpoch = toMillisSinceEpoch(now)
+			t.FinalizedTreeSize = root.TreeSize
+		}
+	}); err != nil {
+		return err
+	}
+	if err := atx.Commit(); err != nil {
+		return err
+	}
+	logging.Infof(ctx, "finalized frozen log at size %d", root.TreeSize)
+	return nil
+}
+
+// publishCheckpoint signs root as a checkpoint note and passes it to the checkpoint
+// publishers. The root has already been committed, so failures are only logged.
+func (s SequencerManager) publishCheckpoint(logID int64, root trillian.SignedLogRoot, signer *crypto.Signer) {
+	origin := fmt.Sprintf("%s/%d", s.checkpointOrigin, logID)
+	note, err := checkpoint.Sign(checkpoint.FromLogRoot(origin, root), origin, signer)
+	if err != nil {
+		glog.Warningf("%v: failed to sign checkpoint at size %d: %v", logID, root.TreeSize, err)
+		return
+	}
+	for _, p := range s.checkpointPublishers {
+		p.PublishCheckpoint(logID, note)
+	}
+}
+
+func newSigner(ctx context.Context, registry extension.Registry, tree *trillian.Tree) (*crypto.Signer, error) {
+	if registry.SignerFactory == nil {
+		return nil, fmt.Errorf("no SignerFactory provided by registry")
+	}
+
 	signer, err := registry.SignerFactory.NewSigner(ctx, tree)
 	if err != nil {
 		return nil, err
@@ -15,14 +15,19 @@
 package server
 
 import (
+	"bytes"
 	"context"
 	"crypto"
+	"expvar"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/golang/protobuf/proto"
 	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/checkpoint"
 	"github.com/google/trillian/crypto/keys"
 	"github.com/google/trillian/crypto/sigpb"
 	"github.com/google/trillian/extension"
@@ -30,6 +35,8 @@ import (
 	stestonly "github.com/google/trillian/storage/testonly"
 	"github.com/google/trillian/testonly"
 	"github.com/google/trillian/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 )
 
 // Arbitrary time for use in tests
@@ -157,7 +164,32 @@ func TestSequencerManagerSingleLogNoLeaves(t *testing.T) {
 	sm.ExecutePass([]int64{logID}, createTestContext(registry))
 }
 
-func TestSequencerManagerSingleLogOneLeaf(t *testing.T) {
+func TestSequencerManagerSkipsLockedLog(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// No storage calls are expected, the log is already being sequenced.
+	registry := extension.Registry{
+		AdminStorage: storage.NewMockAdminStorage(mockCtrl),
+		LogStorage:   storage.NewMockLogStorage(mockCtrl),
+	}
+
+	sm := NewSequencerManager(registry, zeroDuration)
+	logID := stestonly.LogTree.GetTreeId()
+	if !sm.locks.tryLock(logID) {
+		t.Fatalf("tryLock(%v) = false, want true", logID)
+	}
+
+	result := sm.ExecutePass([]int64{logID}, createTestContext(registry))
+	if got, ok := result.Processed[logID]; !ok || got != 0 {
+		t.Errorf("ExecutePass() processed (%d, %v) leaves for log %d, want (0, true)", got, ok, logID)
+	}
+	if sm.locks.tryLock(logID) {
+		t.Errorf("tryLock(%v) = true after ExecutePass(), want lock to still be held", logID)
+	}
+}
+
+func TestSequencerManagerSequenceNow(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 
@@ -166,23 +198,24 @@ func TestSequencerManagerSingleLogOneLeaf(t *testing.T) {
 	mockAdminTx := storage.NewMockReadOnlyAdminTX(mockCtrl)
 	mockStorage := storage.NewMockLogStorage(mockCtrl)
 	mockTx := storage.NewMockLogTreeTX(mockCtrl)
+	mockSnapshot := storage.NewMockLogTreeTX(mockCtrl)
 
 	signer, err := newSignerWithFixedSig(updatedRoot.Signature)
 	if err != nil {
 		t.Fatalf("Failed to create test signer (%v)", err)
 	}
 
-	// Set up enough mockery to be able to sequence. We don't test all the error paths
-	// through sequencer as other tests cover this
+	mockStorage.EXPECT().BeginForTree(gomock.Any(), logID).Return(mockTx, nil)
 	mockTx.EXPECT().Commit().Return(nil)
 	mockTx.EXPECT().Close().Return(nil)
-	mockTx.EXPECT().WriteRevision().AnyTimes().Return(testRoot0.TreeRevision + 1)
-	mockTx.EXPECT().DequeueLeaves(50, fakeTime).Return([]*trillian.LogLeaf{testLeaf0}, nil)
+	mockTx.EXPECT().WriteRevision().AnyTimes().Return(writeRev)
 	mockTx.EXPECT().LatestSignedLogRoot().Return(testRoot0, nil)
-	mockTx.EXPECT().UpdateSequencedLeaves([]*trillian.LogLeaf{testLeaf0Updated}).Return(nil)
-	mockTx.EXPECT().SetMerkleNodes(updatedNodes0).Return(nil)
-	mockTx.EXPECT().StoreSignedLogRoot(updatedRoot).Return(nil)
-	mockStorage.EXPECT().BeginForTree(gomock.Any(), logID).Return(mockTx, nil)
+	mockTx.EXPECT().DequeueLeaves(50, fakeTime).Return([]*trillian.LogLeaf{}, nil)
+
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), logID).Return(mockSnapshot, nil)
+	mockSnapshot.EXPECT().LatestSignedLogRoot().Return(testRoot0, nil)
+	mockSnapshot.EXPECT().Commit().Return(nil)
+	mockSnapshot.EXPECT().Close().Return(nil)
 
 	mockAdmin.EXPECT().Snapshot(gomock.Any()).Return(mockAdminTx, nil)
 	mockAdminTx.EXPECT().GetTree(gomock.Any(), logID).Return(stestonly.LogTree, nil)
@@ -198,11 +231,298 @@ func TestSequencerManagerSingleLogOneLeaf(t *testing.T) {
 	}
 
 	sm := NewSequencerManager(registry, zeroDuration)
+	// The log isn't due for an hour, but is sequenced anyway.
+	sm.schedule.due[logID] = fakeTime.Add(time.Hour)
 
-	sm.ExecutePass([]int64{logID}, createTestContext(registry))
+	leaves, root, err := sm.SequenceNow(context.Background(), logID, 50, fakeTimeSource)
+	if err != nil {
+		t.Fatalf("SequenceNow()=%v", err)
+	}
+	if leaves != 0 || !proto.Equal(&root, &testRoot0) {
+		t.Errorf("SequenceNow()=%d, %v, want 0, %v", leaves, root, testRoot0)
+	}
+	if !sm.locks.tryLock(logID) {
+		t.Errorf("tryLock(%v) = false after SequenceNow(), want lock to be released", logID)
+	}
 }
 
-func TestSequencerManagerGuardWindow(t *testing.T) {
+func TestSequencerManagerSequenceNowWaitsForLock(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// No storage calls are expected, the log stays locked until the context is done.
+	registry := extension.Registry{
+		AdminStorage: storage.NewMockAdminStorage(mockCtrl),
+		LogStorage:   storage.NewMockLogStorage(mockCtrl),
+	}
+
+	sm := NewSequencerManager(registry, zeroDuration)
+	logID := stestonly.LogTree.GetTreeId()
+	sm.locks.tryLock(logID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*lockRetryInterval)
+	defer cancel()
+	if _, _, err := sm.SequenceNow(ctx, logID, 50, fakeTimeSource); err != context.DeadlineExceeded {
+		t.Errorf("SequenceNow()=%v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestSequencerManagerVerificationOnly(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	logID := stestonly.LogTree.GetTreeId()
+	tree := *stestonly.LogTree
+	tree.PrivateKey = nil
+	tree.PublicKey = &trillian.PublicKey{Der: []byte("public key")}
+
+	// The log is neither signed for nor sequenced, so only the tree is read.
+	mockAdmin := storage.NewMockAdminStorage(mockCtrl)
+	mockAdminTx := storage.NewMockReadOnlyAdminTX(mockCtrl)
+	mockAdmin.EXPECT().Snapshot(gomock.Any()).Times(2).Return(mockAdminTx, nil)
+	mockAdminTx.EXPECT().GetTree(gomock.Any(), logID).Times(2).Return(&tree, nil)
+	mockAdminTx.EXPECT().Commit().Times(2).Return(nil)
+	mockAdminTx.EXPECT().Close().Times(2).Return(nil)
+
+	registry := extension.Registry{
+		AdminStorage:  mockAdmin,
+		LogStorage:    storage.NewMockLogStorage(mockCtrl),
+		SignerFactory: &signerFactory{},
+	}
+	sm := NewSequencerManager(registry, zeroDuration)
+
+	result := sm.ExecutePass([]int64{logID}, createTestContext(registry))
+	if len(result.Failed) != 0 {
+		t.Errorf("ExecutePass() failed for logs %v, want none", result.Failed)
+	}
+	if got, ok := result.Processed[logID]; !ok || got != 0 {
+		t.Errorf("ExecutePass() processed (%d, %v) leaves for log %d, want (0, true)", got, ok, logID)
+	}
+
+	if _, _, err := sm.SequenceNow(context.Background(), logID, 50, fakeTimeSource); grpc.Code(err) != codes.FailedPrecondition {
+		t.Errorf("SequenceNow()=%v, want code %v", err, codes.FailedPrecondition)
+	}
+}
+
+func TestSequencerManagerPerTreeConfig(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	tree := *stestonly.LogTree
+	tree.SequencingBatchSize = 20
+	tree.SequencingIntervalSeconds = 60
+	logID := tree.GetTreeId()
+	mockAdmin := storage.NewMockAdminStorage(mockCtrl)
+	mockAdminTx := storage.NewMockReadOnlyAdminTX(mockCtrl)
+	mockStorage := storage.NewMockLogStorage(mockCtrl)
+	mockTx := storage.NewMockLogTreeTX(mockCtrl)
+
+	signer, err := newSignerWithFixedSig(updatedRoot.Signature)
+	if err != nil {
+		t.Fatalf("Failed to create test signer (%v)", err)
+	}
+
+	// Only the first pass sequences, the second one is within the tree's interval.
+	mockStorage.EXPECT().BeginForTree(gomock.Any(), logID).Return(mockTx, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+	mockTx.EXPECT().Close().Return(nil)
+	mockTx.EXPECT().WriteRevision().AnyTimes().Return(writeRev)
+	mockTx.EXPECT().LatestSignedLogRoot().Return(testRoot0, nil)
+	mockTx.EXPECT().DequeueLeaves(20, fakeTime).Return([]*trillian.LogLeaf{}, nil)
+
+	mockAdmin.EXPECT().Snapshot(gomock.Any()).Times(2).Return(mockAdminTx, nil)
+	mockAdminTx.EXPECT().GetTree(gomock.Any(), logID).Times(2).Return(&tree, nil)
+	mockAdminTx.EXPECT().Commit().Times(2).Return(nil)
+	mockAdminTx.EXPECT().Close().Times(2).Return(nil)
+
+	registry := extension.Registry{
+		AdminStorage: mockAdmin,
+		LogStorage:   mockStorage,
+		SignerFactory: &signerFactory{
+			signers: map[int64]crypto.Signer{logID: signer},
+		},
+	}
+
+	sm := NewSequencerManager(registry, zeroDuration)
+
+	for i := 0; i < 2; i++ {
+		result := sm.ExecutePass([]int64{logID}, createTestContext(registry))
+		if got, ok := result.Processed[logID]; !ok || got != 0 {
+			t.Errorf("ExecutePass() #%d processed (%d, %v) leaves for log %d, want (0, true)", i, got, ok, logID)
+		}
+	}
+}
+
+func TestSequencerManagerFreezesEndedShard(t *testing.T) {
+	for _, test := range []struct {
+		desc       string
+		end        time.Time
+		queued     int64
+		wantFrozen bool
+	}{
+		{desc: "windowOpen", end: fakeTime.Add(time.Hour)},
+		{desc: "leavesQueued", end: fakeTime.Add(-time.Hour), queued: 1},
+		{desc: "ended", end: fakeTime.Add(-time.Hour), wantFrozen: true},
+	} {
+		func() {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			tree := *stestonly.LogTree
+			tree.ShardSetId = 7
+			tree.ShardStartMillisSinceEpoch = toMillisSinceEpoch(fakeTime.Add(-2 * time.Hour))
+			tree.ShardEndMillisSinceEpoch = toMillisSinceEpoch(test.end)
+			logID := tree.GetTreeId()
+			mockAdmin := storage.NewMockAdminStorage(mockCtrl)
+			mockAdminTx := storage.NewMockReadOnlyAdminTX(mockCtrl)
+			mockStorage := storage.NewMockLogStorage(mockCtrl)
+			mockTx := storage.NewMockLogTreeTX(mockCtrl)
+
+			signer, err := newSignerWithFixedSig(updatedRoot.Signature)
+			if err != nil {
+				t.Fatalf("Failed to create test signer (%v)", err)
+			}
+
+			mockStorage.EXPECT().BeginForTree(gomock.Any(), logID).Return(mockTx, nil)
+			mockTx.EXPECT().Commit().Return(nil)
+			mockTx.EXPECT().Close().Return(nil)
+			mockTx.EXPECT().WriteRevision().AnyTimes().Return(writeRev)
+			mockTx.EXPECT().LatestSignedLogRoot().Return(testRoot0, nil)
+			mockTx.EXPECT().DequeueLeaves(50, fakeTime).Return([]*trillian.LogLeaf{}, nil)
+
+			mockAdmin.EXPECT().Snapshot(gomock.Any()).Return(mockAdminTx, nil)
+			mockAdminTx.EXPECT().GetTree(gomock.Any(), logID).Return(&tree, nil)
+			mockAdminTx.EXPECT().Commit().Return(nil)
+			mockAdminTx.EXPECT().Close().Return(nil)
+
+			if test.end.Before(fakeTime) {
+				mockSnapshot := storage.NewMockReadOnlyLogTreeTX(mockCtrl)
+				mockStorage.EXPECT().SnapshotForTree(gomock.Any(), logID).Return(mockSnapshot, nil)
+				mockSnapshot.EXPECT().GetUnsequencedStats().Return(test.queued, time.Time{}, nil)
+				mockSnapshot.EXPECT().Commit().Return(nil)
+				mockSnapshot.EXPECT().Close().Return(nil)
+			}
+			var frozen trillian.Tree
+			if test.wantFrozen {
+				mockAdminWriteTx := storage.NewMockAdminTX(mockCtrl)
+				mockAdmin.EXPECT().Begin(gomock.Any()).Return(mockAdminWriteTx, nil)
+				mockAdminWriteTx.EXPECT().UpdateTree(gomock.Any(), logID, gomock.Any()).Do(func(_ context.Context, _ int64, fn func(*trillian.Tree)) {
+					frozen = tree
+					fn(&frozen)
+				}).Return(&frozen, nil)
+				mockAdminWriteTx.EXPECT().Commit().Return(nil)
+				mockAdminWriteTx.EXPECT().Close().Return(nil)
+			}
+
+			registry := extension.Registry{
+				AdminStorage: mockAdmin,
+				LogStorage:   mockStorage,
+				SignerFactory: &signerFactory{
+					signers: map[int64]crypto.Signer{logID: signer},
+				},
+			}
+
+			sm := NewSequencerManager(registry, zeroDuration)
+			sm.ExecutePass([]int64{logID}, createTestContext(registry))
+			if test.wantFrozen && frozen.TreeState != trillian.TreeState_FROZEN {
+				t.Errorf("%v: ExecutePass() left shard in state %v, want %v", test.desc, frozen.TreeState, trillian.TreeState_FROZEN)
+			}
+		}()
+	}
+}
+
+func TestSequencerManagerFinalizesFrozenLog(t *testing.T) {
+	for _, test := range []struct {
+		desc          string
+		finalized     bool
+		queued        int64
+		wantFinalized bool
+	}{
+		{desc: "leavesQueued", queued: 1},
+		{desc: "drained", wantFinalized: true},
+		{desc: "alreadyFinalized", finalized: true},
+	} {
+		func() {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			tree := *stestonly.LogTree
+			tree.TreeState = trillian.TreeState_FROZEN
+			if test.finalized {
+				tree.FinalizeTimeMillisSinceEpoch = toMillisSinceEpoch(fakeTime.Add(-time.Hour))
+			}
+			logID := tree.GetTreeId()
+			mockAdmin := storage.NewMockAdminStorage(mockCtrl)
+			mockAdminTx := storage.NewMockReadOnlyAdminTX(mockCtrl)
+			mockStorage := storage.NewMockLogStorage(mockCtrl)
+			mockTx := storage.NewMockLogTreeTX(mockCtrl)
+
+			signer, err := newSignerWithFixedSig(updatedRoot.Signature)
+			if err != nil {
+				t.Fatalf("Failed to create test signer (%v)", err)
+			}
+
+			// The final root is signed in a second tx.
+			txs := 1
+			if test.wantFinalized {
+				txs = 2
+				mockTx.EXPECT().StoreSignedLogRoot(gomock.Any()).Return(nil)
+			}
+			mockStorage.EXPECT().BeginForTree(gomock.Any(), logID).Times(txs).Return(mockTx, nil)
+			mockTx.EXPECT().Commit().Times(txs).Return(nil)
+			mockTx.EXPECT().Close().Times(txs).Return(nil)
+			mockTx.EXPECT().WriteRevision().AnyTimes().Return(writeRev)
+			mockTx.EXPECT().LatestSignedLogRoot().Times(txs).Return(testRoot0, nil)
+			mockTx.EXPECT().DequeueLeaves(50, fakeTime).Return([]*trillian.LogLeaf{}, nil)
+
+			mockAdmin.EXPECT().Snapshot(gomock.Any()).Return(mockAdminTx, nil)
+			mockAdminTx.EXPECT().GetTree(gomock.Any(), logID).Return(&tree, nil)
+			mockAdminTx.EXPECT().Commit().Return(nil)
+			mockAdminTx.EXPECT().Close().Return(nil)
+
+			if !test.finalized {
+				mockSnapshot := storage.NewMockReadOnlyLogTreeTX(mockCtrl)
+				mockStorage.EXPECT().SnapshotForTree(gomock.Any(), logID).Return(mockSnapshot, nil)
+				mockSnapshot.EXPECT().GetUnsequencedStats().Return(test.queued, time.Time{}, nil)
+				mockSnapshot.EXPECT().LatestSignedLogRoot().Return(testRoot0, nil)
+				mockSnapshot.EXPECT().Commit().Return(nil)
+				mockSnapshot.EXPECT().Close().Return(nil)
+			}
+			var finalized trillian.Tree
+			if test.wantFinalized {
+				mockAdminWriteTx := storage.NewMockAdminTX(mockCtrl)
+				mockAdmin.EXPECT().Begin(gomock.Any()).Return(mockAdminWriteTx, nil)
+				mockAdminWriteTx.EXPECT().UpdateTree(gomock.Any(), logID, gomock.Any()).Do(func(_ context.Context, _ int64, fn func(*trillian.Tree)) {
+					finalized = tree
+					fn(&finalized)
+				}).Return(&finalized, nil)
+				mockAdminWriteTx.EXPECT().Commit().Return(nil)
+				mockAdminWriteTx.EXPECT().Close().Return(nil)
+			}
+
+			registry := extension.Registry{
+				AdminStorage: mockAdmin,
+				LogStorage:   mockStorage,
+				SignerFactory: &signerFactory{
+					signers: map[int64]crypto.Signer{logID: signer},
+				},
+			}
+
+			sm := NewSequencerManager(registry, zeroDuration)
+			sm.ExecutePass([]int64{logID}, createTestContext(registry))
+			if test.wantFinalized {
+				if got, want := finalized.FinalizeTimeMillisSinceEpoch, toMillisSinceEpoch(fakeTime); got != want {
+					t.Errorf("%v: ExecutePass() finalized log at %v, want %v", test.desc, got, want)
+				}
+				if got, want := finalized.FinalizedTreeSize, testRoot0.TreeSize; got != want {
+					t.Errorf("%v: ExecutePass() finalized log at size %v, want %v", test.desc, got, want)
+				}
+			}
+		}()
+	}
+}
+
+func TestSequencerManagerQueueMetrics(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 
@@ -211,6 +531,7 @@ func TestSequencerManagerGuardWindow(t *testing.T) {
 	mockAdminTx := storage.NewMockReadOnlyAdminTX(mockCtrl)
 	mockStorage := storage.NewMockLogStorage(mockCtrl)
 	mockTx := storage.NewMockLogTreeTX(mockCtrl)
+	mockSnapshot := storage.NewMockReadOnlyLogTreeTX(mockCtrl)
 
 	signer, err := newSignerWithFixedSig(updatedRoot.Signature)
 	if err != nil {
@@ -222,8 +543,14 @@ func TestSequencerManagerGuardWindow(t *testing.T) {
 	mockTx.EXPECT().Close().Return(nil)
 	mockTx.EXPECT().WriteRevision().AnyTimes().Return(writeRev)
 	mockTx.EXPECT().LatestSignedLogRoot().Return(testRoot0, nil)
-	// Expect a 5 second guard window to be passed from manager -> sequencer -> storage
-	mockTx.EXPECT().DequeueLeaves(50, fakeTime.Add(-time.Second*5)).Return([]*trillian.LogLeaf{}, nil)
+	mockTx.EXPECT().DequeueLeaves(50, fakeTime).Return([]*trillian.LogLeaf{}, nil)
+
+	// The guard window keeps 7 leaves queued, the oldest of them queued 3 seconds ago.
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), logID).Return(mockSnapshot, nil)
+	mockSnapshot.EXPECT().GetUnsequencedStats().Return(int64(7), fakeTime.Add(-3*time.Second), nil)
+	mockSnapshot.EXPECT().LatestSignedLogRoot().Return(trillian.SignedLogRoot{TimestampNanos: fakeTime.Add(-time.Minute).UnixNano(), TreeSize: 42}, nil)
+	mockSnapshot.EXPECT().Commit().Return(nil)
+	mockSnapshot.EXPECT().Close().Return(nil)
 
 	mockAdmin.EXPECT().Snapshot(gomock.Any()).Return(mockAdminTx, nil)
 	mockAdminTx.EXPECT().GetTree(gomock.Any(), logID).Return(stestonly.LogTree, nil)
@@ -238,9 +565,273 @@ func TestSequencerManagerGuardWindow(t *testing.T) {
 		},
 	}
 
-	sm := NewSequencerManager(registry, time.Second*5)
+	sm := NewSequencerManager(registry, zeroDuration)
+	sm.EnableQueueMetrics()
 
 	sm.ExecutePass([]int64{logID}, createTestContext(registry))
+
+	key := logKey(logID)
+	for _, test := range []struct {
+		name      string
+		got, want string
+	}{
+		{name: "unsequenced leaves", got: unsequencedLeaves.Get(key).String(), want: "7"},
+		{name: "oldest unsequenced age", got: oldestUnsequencedAge.Get(key).String(), want: "3"},
+		{name: "leaves integrated", got: leavesIntegrated.Get(key).String(), want: "0"},
+		{name: "latest root age", got: latestRootAge.Get(key).String(), want: "60"},
+		{name: "latest root size", got: latestRootSize.Get(key).String(), want: "42"},
+	} {
+		if test.got != test.want {
+			t.Errorf("%v = %v, want %v", test.name, test.got, test.want)
+		}
+	}
+}
+
+func TestSequencerManagerMergeDelay(t *testing.T) {
+	for _, test := range []struct {
+		desc          string
+		oldest        time.Duration
+		wantExpedited bool
+		wantBreaches  int64
+	}{
+		{desc: "withinDelay", oldest: 3 * time.Second},
+		{desc: "dueTooLate", oldest: 40 * time.Second, wantExpedited: true},
+		{desc: "breached", oldest: 90 * time.Second, wantExpedited: true, wantBreaches: 1},
+	} {
+		func() {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			tree := *stestonly.LogTree
+			tree.SequencingIntervalSeconds = 30
+			tree.MaxMergeDelaySeconds = 60
+			logID := tree.GetTreeId()
+			mockAdmin := storage.NewMockAdminStorage(mockCtrl)
+			mockAdminTx := storage.NewMockReadOnlyAdminTX(mockCtrl)
+			mockStorage := storage.NewMockLogStorage(mockCtrl)
+			mockTx := storage.NewMockLogTreeTX(mockCtrl)
+			mockSnapshot := storage.NewMockReadOnlyLogTreeTX(mockCtrl)
+
+			signer, err := newSignerWithFixedSig(updatedRoot.Signature)
+			if err != nil {
+				t.Fatalf("Failed to create test signer (%v)", err)
+			}
+
+			mockStorage.EXPECT().BeginForTree(gomock.Any(), logID).Return(mockTx, nil)
+			mockTx.EXPECT().Commit().Return(nil)
+			mockTx.EXPECT().Close().Return(nil)
+			mockTx.EXPECT().WriteRevision().AnyTimes().Return(writeRev)
+			mockTx.EXPECT().LatestSignedLogRoot().Return(testRoot0, nil)
+			mockTx.EXPECT().DequeueLeaves(50, fakeTime).Return([]*trillian.LogLeaf{}, nil)
+
+			mockStorage.EXPECT().SnapshotForTree(gomock.Any(), logID).Return(mockSnapshot, nil)
+			mockSnapshot.EXPECT().GetUnsequencedStats().Return(int64(1), fakeTime.Add(-test.oldest), nil)
+			mockSnapshot.EXPECT().Commit().Return(nil)
+			mockSnapshot.EXPECT().Close().Return(nil)
+
+			mockAdmin.EXPECT().Snapshot(gomock.Any()).Return(mockAdminTx, nil)
+			mockAdminTx.EXPECT().GetTree(gomock.Any(), logID).Return(&tree, nil)
+			mockAdminTx.EXPECT().Commit().Return(nil)
+			mockAdminTx.EXPECT().Close().Return(nil)
+
+			registry := extension.Registry{
+				AdminStorage: mockAdmin,
+				LogStorage:   mockStorage,
+				SignerFactory: &signerFactory{
+					signers: map[int64]crypto.Signer{logID: signer},
+				},
+			}
+
+			breaches := func() int64 {
+				if v, ok := mergeDelayBreaches.Get(logKey(logID)).(*expvar.Int); ok {
+					return v.Value()
+				}
+				return 0
+			}
+			before := breaches()
+
+			sm := NewSequencerManager(registry, zeroDuration)
+			sm.ExecutePass([]int64{logID}, createTestContext(registry))
+			if got := sm.schedule.isDue(logID, fakeTime); got != test.wantExpedited {
+				t.Errorf("%v: isDue() after ExecutePass() = %v, want %v", test.desc, got, test.wantExpedited)
+			}
+			if got := breaches() - before; got != test.wantBreaches {
+				t.Errorf("%v: ExecutePass() counted %v merge delay breaches, want %v", test.desc, got, test.wantBreaches)
+			}
+		}()
+	}
+}
+
+func TestSequencingScheduleMergeDelayChecks(t *testing.T) {
+	s := &sequencingSchedule{due: make(map[int64]time.Time), mergeChecked: make(map[int64]time.Time)}
+	for _, test := range []struct {
+		desc      string
+		at        time.Duration
+		fullBatch bool
+		want      bool
+	}{
+		{desc: "firstFullBatch", at: 0, fullBatch: true, want: true},
+		{desc: "backlog", at: time.Second, fullBatch: true, want: false},
+		{desc: "backlogDue", at: mergeDelayCheckInterval, fullBatch: true, want: true},
+		{desc: "drained", at: mergeDelayCheckInterval + time.Second, want: true},
+		{desc: "backlogAgain", at: mergeDelayCheckInterval + 2*time.Second, fullBatch: true, want: false},
+	} {
+		if got := s.mergeDelayCheckDue(1, fakeTime.Add(test.at), test.fullBatch); got != test.want {
+			t.Errorf("%v: mergeDelayCheckDue()=%v, want %v", test.desc, got, test.want)
+		}
+	}
+}
+
+func TestSequencerManagerSingleLogOneLeaf(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	logID := stestonly.LogTree.GetTreeId()
+	mockAdmin := storage.NewMockAdminStorage(mockCtrl)
+	mockAdminTx := storage.NewMockReadOnlyAdminTX(mockCtrl)
+	mockStorage := storage.NewMockLogStorage(mockCtrl)
+	mockTx := storage.NewMockLogTreeTX(mockCtrl)
+
+	signer, err := newSignerWithFixedSig(updatedRoot.Signature)
+	if err != nil {
+		t.Fatalf("Failed to create test signer (%v)", err)
+	}
+
+	// Set up enough mockery to be able to sequence. We don't test all the error paths
+	// through sequencer as other tests cover this
+	mockTx.EXPECT().Commit().Return(nil)
+	mockTx.EXPECT().Close().Return(nil)
+	mockTx.EXPECT().WriteRevision().AnyTimes().Return(testRoot0.TreeRevision + 1)
+	mockTx.EXPECT().DequeueLeaves(50, fakeTime).Return([]*trillian.LogLeaf{testLeaf0}, nil)
+	mockTx.EXPECT().LatestSignedLogRoot().Return(testRoot0, nil)
+	mockTx.EXPECT().UpdateSequencedLeaves([]*trillian.LogLeaf{testLeaf0Updated}).Return(nil)
+	mockTx.EXPECT().SetMerkleNodes(updatedNodes0).Return(nil)
+	mockTx.EXPECT().StoreSignedLogRoot(updatedRoot).Return(nil)
+	mockStorage.EXPECT().BeginForTree(gomock.Any(), logID).Return(mockTx, nil)
+
+	mockAdmin.EXPECT().Snapshot(gomock.Any()).Return(mockAdminTx, nil)
+	mockAdminTx.EXPECT().GetTree(gomock.Any(), logID).Return(stestonly.LogTree, nil)
+	mockAdminTx.EXPECT().Commit().Return(nil)
+	mockAdminTx.EXPECT().Close().Return(nil)
+
+	registry := extension.Registry{
+		AdminStorage: mockAdmin,
+		LogStorage:   mockStorage,
+		SignerFactory: &signerFactory{
+			signers: map[int64]crypto.Signer{logID: signer},
+		},
+	}
+
+	sm := NewSequencerManager(registry, zeroDuration)
+	publisher := &eventRecorder{}
+	sm.AddRootPublisher(publisher)
+	sm.AddLeafPublisher(publisher)
+	sm.AddCheckpointPublisher("example.com/log", publisher)
+
+	result := sm.ExecutePass([]int64{logID}, createTestContext(registry))
+	if got, want := result.Processed[logID], 1; got != want {
+		t.Errorf("ExecutePass() processed %d leaves for log %d, want %d", got, logID, want)
+	}
+	if got, want := len(publisher.roots), 1; got != want {
+		t.Fatalf("Published %d roots, want %d", got, want)
+	}
+	if got, want := publisher.roots[0], updatedRoot; !proto.Equal(&got, &want) {
+		t.Errorf("Published root %v, want %v", got, want)
+	}
+	if got, want := publisher.leaves, []*trillian.LogLeaf{testLeaf0Updated}; len(got) != len(want) || !proto.Equal(got[0], want[0]) {
+		t.Errorf("Published leaves %v, want %v", got, want)
+	}
+	if got, want := len(publisher.checkpoints), 1; got != want {
+		t.Fatalf("Published %d checkpoints, want %d", got, want)
+	}
+	origin := fmt.Sprintf("example.com/log/%d", logID)
+	if got, want := publisher.checkpoints[0], checkpoint.FromLogRoot(origin, updatedRoot).Marshal(); !bytes.HasPrefix(got, want) {
+		t.Errorf("Published checkpoint %q, want body %q", got, want)
+	}
+}
+
+// eventRecorder is a RootPublisher, LeafPublisher and CheckpointPublisher which keeps
+// what it's given.
+type eventRecorder struct {
+	mu          sync.Mutex
+	roots       []trillian.SignedLogRoot
+	leaves      []*trillian.LogLeaf
+	checkpoints [][]byte
+}
+
+func (r *eventRecorder) PublishRoot(root trillian.SignedLogRoot) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.roots = append(r.roots, root)
+}
+
+func (r *eventRecorder) PublishLeaves(logID int64, leaves []*trillian.LogLeaf) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.leaves = append(r.leaves, leaves...)
+}
+
+func (r *eventRecorder) PublishCheckpoint(logID int64, note []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checkpoints = append(r.checkpoints, note)
+}
+
+func TestSequencerManagerGuardWindow(t *testing.T) {
+	tests := []struct {
+		desc              string
+		managerWindow     time.Duration
+		treeWindowSeconds int32
+		wantCutoff        time.Time
+	}{
+		// Expect a 5 second guard window to be passed from manager -> sequencer -> storage
+		{desc: "manager", managerWindow: time.Second * 5, wantCutoff: fakeTime.Add(-time.Second * 5)},
+		{desc: "treeOverride", managerWindow: time.Second * 5, treeWindowSeconds: 30, wantCutoff: fakeTime.Add(-time.Second * 30)},
+	}
+
+	for _, test := range tests {
+		func() {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			tree := *stestonly.LogTree
+			tree.SequencingGuardWindowSeconds = test.treeWindowSeconds
+			logID := tree.GetTreeId()
+			mockAdmin := storage.NewMockAdminStorage(mockCtrl)
+			mockAdminTx := storage.NewMockReadOnlyAdminTX(mockCtrl)
+			mockStorage := storage.NewMockLogStorage(mockCtrl)
+			mockTx := storage.NewMockLogTreeTX(mockCtrl)
+
+			signer, err := newSignerWithFixedSig(updatedRoot.Signature)
+			if err != nil {
+				t.Fatalf("%v: Failed to create test signer (%v)", test.desc, err)
+			}
+
+			mockStorage.EXPECT().BeginForTree(gomock.Any(), logID).Return(mockTx, nil)
+			mockTx.EXPECT().Commit().Return(nil)
+			mockTx.EXPECT().Close().Return(nil)
+			mockTx.EXPECT().WriteRevision().AnyTimes().Return(writeRev)
+			mockTx.EXPECT().LatestSignedLogRoot().Return(testRoot0, nil)
+			mockTx.EXPECT().DequeueLeaves(50, test.wantCutoff).Return([]*trillian.LogLeaf{}, nil)
+
+			mockAdmin.EXPECT().Snapshot(gomock.Any()).Return(mockAdminTx, nil)
+			mockAdminTx.EXPECT().GetTree(gomock.Any(), logID).Return(&tree, nil)
+			mockAdminTx.EXPECT().Commit().Return(nil)
+			mockAdminTx.EXPECT().Close().Return(nil)
+
+			registry := extension.Registry{
+				AdminStorage: mockAdmin,
+				LogStorage:   mockStorage,
+				SignerFactory: &signerFactory{
+					signers: map[int64]crypto.Signer{logID: signer},
+				},
+			}
+
+			sm := NewSequencerManager(registry, test.managerWindow)
+
+			sm.ExecutePass([]int64{logID}, createTestContext(registry))
+		}()
+	}
 }
 
 func createTestContext(registry extension.Registry) LogOperationManagerContext {
@@ -0,0 +1,182 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"expvar"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/util"
+)
+
+// Per log sequencer metrics, each map is keyed by log ID.
+var (
+	// unsequencedLeaves holds the number of leaves waiting to be integrated.
+	unsequencedLeaves = expvar.NewMap("sequencer-unsequenced-leaves")
+	// oldestUnsequencedAge holds how long the oldest queued leaf has been waiting, in seconds.
+	oldestUnsequencedAge = expvar.NewMap("sequencer-oldest-unsequenced-age-seconds")
+	// leavesIntegrated holds the number of leaves integrated by the latest sequencing run.
+	leavesIntegrated = expvar.NewMap("sequencer-leaves-integrated")
+	// leavesIntegratedTotal holds the number of leaves integrated since the signer started.
+	leavesIntegratedTotal = expvar.NewMap("sequencer-leaves-integrated-total")
+	// signingLatency holds how long the latest run took to sequence a batch and sign the new
+	// root, in seconds.
+	signingLatency = expvar.NewMap("sequencer-signing-latency-seconds")
+	// latestRootAge holds how long ago the latest signed root was signed, in seconds.
+	latestRootAge = expvar.NewMap("log-latest-root-age-seconds")
+	// latestRootSize holds the tree size of the latest signed root.
+	latestRootSize = expvar.NewMap("log-latest-root-tree-size")
+	// mergeDelayBreaches holds the number of runs after which a log had a leaf that had
+	// been queued for longer than the log's maximum merge delay.
+	mergeDelayBreaches = expvar.NewMap("sequencer-merge-delay-breaches")
+	// selfChecks holds the number of leaves whose inclusion in a new root has been
+	// verified by a self-check.
+	selfChecks = expvar.NewMap("sequencer-self-checks")
+	// selfCheckFailures holds the number of those leaves whose inclusion proof didn't
+	// verify, or whose Merkle leaf hash didn't match their value.
+	selfCheckFailures = expvar.NewMap("sequencer-self-check-failures")
+)
+
+func logKey(logID int64) string {
+	return strconv.FormatInt(logID, 10)
+}
+
+func setLogInt(m *expvar.Map, logID, value int64) {
+	v := new(expvar.Int)
+	v.Set(value)
+	m.Set(logKey(logID), v)
+}
+
+func setLogFloat(m *expvar.Map, logID int64, value float64) {
+	v := new(expvar.Float)
+	v.Set(value)
+	m.Set(logKey(logID), v)
+}
+
+// recordRun updates the metrics for a successful sequencing run.
+func recordRun(logID int64, leaves int, latency time.Duration) {
+	setLogInt(leavesIntegrated, logID, int64(leaves))
+	leavesIntegratedTotal.Add(logKey(logID), int64(leaves))
+	setLogFloat(signingLatency, logID, latency.Seconds())
+}
+
+// recordRoot updates the freshness metrics of logID for its latest root, unless it
+// hasn't got one yet.
+func recordRoot(logID int64, root trillian.SignedLogRoot, now time.Time) {
+	if root.TimestampNanos == 0 {
+		return
+	}
+	age := now.Sub(time.Unix(0, root.TimestampNanos))
+	if age < 0 {
+		age = 0
+	}
+	setLogFloat(latestRootAge, logID, age.Seconds())
+	setLogInt(latestRootSize, logID, root.TreeSize)
+}
+
+// recordQueueStats reads the state of logID's queue of unsequenced leaves, and its latest
+// root, and updates the metrics for them.
+func (s SequencerManager) recordQueueStats(ctx context.Context, logID int64, now time.Time) error {
+	tx, err := s.registry.LogStorage.SnapshotForTree(ctx, logID)
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+
+	count, oldest, err := tx.GetUnsequencedStats()
+	if err != nil {
+		return err
+	}
+	root, err := tx.LatestSignedLogRoot()
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	recordRoot(logID, root, now)
+
+	setLogInt(unsequencedLeaves, logID, count)
+	age := time.Duration(0)
+	if count > 0 && now.After(oldest) {
+		age = now.Sub(oldest)
+	}
+	setLogFloat(oldestUnsequencedAge, logID, age.Seconds())
+	return nil
+}
+
+// ExportRootMetrics exports the age and tree size of the latest signed root of each active
+// log, refreshing them every interval until ctx is done, so a log server can be alerted on
+// when a log stops getting new roots. The signer exports them for the logs it sequences
+// when its SequencerManager has EnableQueueMetrics.
+func ExportRootMetrics(ctx context.Context, logStorage storage.LogStorage, interval time.Duration, timeSource util.TimeSource) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := recordRoots(ctx, logStorage, timeSource.Now()); err != nil {
+			glog.Warningf("Failed to read latest roots: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// recordRoots reads the latest root of each active log and updates their freshness
+// metrics. Logs whose root can't be read are skipped.
+func recordRoots(ctx context.Context, logStorage storage.LogStorage, now time.Time) error {
+	tx, err := logStorage.Snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	logIDs, err := tx.GetActiveLogIDs()
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	for _, logID := range logIDs {
+		root, err := latestRoot(ctx, logStorage, logID)
+		if err != nil {
+			glog.Warningf("%v: failed to read latest root: %v", logID, err)
+			continue
+		}
+		recordRoot(logID, root, now)
+	}
+	return nil
+}
+
+func latestRoot(ctx context.Context, logStorage storage.LogStorage, logID int64) (trillian.SignedLogRoot, error) {
+	tx, err := logStorage.SnapshotForTree(ctx, logID)
+	if err != nil {
+		return trillian.SignedLogRoot{}, err
+	}
+	defer tx.Close()
+	root, err := tx.LatestSignedLogRoot()
+	if err != nil {
+		return trillian.SignedLogRoot{}, err
+	}
+	return root, tx.Commit()
+}
@@ -0,0 +1,182 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/monitoring/logging"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// shard is the validity window of one log of a shard set, in milliseconds since the epoch.
+type shard struct {
+	logID      int64
+	start, end int64
+}
+
+// shardRouter finds the logs of the shard set a log belongs to, from a copy of the
+// sharded trees which is reread from admin storage every refresh interval. Shard
+// windows are readonly, so the copy is only stale about shards created since, and the
+// last copy is kept if rereading it fails.
+type shardRouter struct {
+	adminStorage storage.AdminStorage
+	timeSource   util.TimeSource
+	refresh      time.Duration
+
+	mu sync.Mutex
+	// sets holds the shards of each shard set by ID, ordered by window start.
+	sets map[int64][]shard
+	// setIDs maps the IDs of sharded logs to their shard set ID.
+	setIDs map[int64]int64
+	loaded time.Time
+}
+
+func newShardRouter(adminStorage storage.AdminStorage, timeSource util.TimeSource, refresh time.Duration) *shardRouter {
+	return &shardRouter{
+		adminStorage: adminStorage,
+		timeSource:   timeSource,
+		refresh:      refresh,
+	}
+}
+
+// active returns the shard of logID's shard set whose window contains the current time,
+// or logID itself if it isn't sharded.
+func (r *shardRouter) active(ctx context.Context, logID int64) (int64, error) {
+	setID, shards, err := r.shardsOf(ctx, logID)
+	if err != nil {
+		return 0, err
+	}
+	if shards == nil {
+		return logID, nil
+	}
+	now := toMillisSinceEpoch(r.timeSource.Now())
+	for _, s := range shards {
+		if s.start <= now && now < s.end {
+			return s.logID, nil
+		}
+	}
+	return 0, grpc.Errorf(codes.FailedPrecondition, "no shard of set %d is active, log %d is one of its shards", setID, logID)
+}
+
+// newestFirst returns the shards of logID's shard set whose windows have started, the
+// most recent first, or just logID if it isn't sharded.
+func (r *shardRouter) newestFirst(ctx context.Context, logID int64) ([]int64, error) {
+	_, shards, err := r.shardsOf(ctx, logID)
+	if err != nil {
+		return nil, err
+	}
+	if shards == nil {
+		return []int64{logID}, nil
+	}
+	now := toMillisSinceEpoch(r.timeSource.Now())
+	var logIDs []int64
+	for i := len(shards) - 1; i >= 0; i-- {
+		if shards[i].start <= now {
+			logIDs = append(logIDs, shards[i].logID)
+		}
+	}
+	return logIDs, nil
+}
+
+// shardsOf returns the shard set of logID and its shards, which are nil if logID isn't
+// sharded. If the sharded trees have never been read, only logs which are sharded fail.
+func (r *shardRouter) shardsOf(ctx context.Context, logID int64) (int64, []shard, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if now := r.timeSource.Now(); r.sets == nil || now.Sub(r.loaded) >= r.refresh {
+		if err := r.load(ctx); err != nil {
+			if r.sets == nil {
+				return 0, nil, r.loadFailed(ctx, logID, err)
+			}
+			logging.Warningf(ctx, "Failed to reread sharded logs, using those read at %v: %v", r.loaded, err)
+		}
+		r.loaded = now
+	}
+	setID, ok := r.setIDs[logID]
+	if !ok {
+		return 0, nil, nil
+	}
+	return setID, r.sets[setID], nil
+}
+
+// loadFailed returns err, the error reading the sharded trees, if logID is sharded, or
+// nil if it isn't.
+func (r *shardRouter) loadFailed(ctx context.Context, logID int64, err error) error {
+	tx, terr := r.adminStorage.Snapshot(ctx)
+	if terr != nil {
+		return err
+	}
+	defer tx.Close()
+	tree, terr := tx.GetTree(ctx, logID)
+	if terr != nil {
+		return err
+	}
+	if terr := tx.Commit(); terr != nil {
+		return err
+	}
+	if tree.ShardSetId != 0 {
+		return err
+	}
+	return nil
+}
+
+// load rereads the sharded trees, which must be called with mu held. Deleted shards are
+// left out.
+func (r *shardRouter) load(ctx context.Context) error {
+	tx, err := r.adminStorage.Snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	trees, err := tx.ListTrees(ctx)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	sets := make(map[int64][]shard)
+	setIDs := make(map[int64]int64)
+	for _, tree := range trees {
+		if tree.ShardSetId == 0 || (tree.TreeState != trillian.TreeState_ACTIVE && tree.TreeState != trillian.TreeState_FROZEN) {
+			continue
+		}
+		sets[tree.ShardSetId] = append(sets[tree.ShardSetId], shard{
+			logID: tree.TreeId,
+			start: tree.ShardStartMillisSinceEpoch,
+			end:   tree.ShardEndMillisSinceEpoch,
+		})
+		setIDs[tree.TreeId] = tree.ShardSetId
+	}
+	for _, shards := range sets {
+		sort.Slice(shards, func(i, j int) bool { return shards[i].start < shards[j].start })
+	}
+	r.sets = sets
+	r.setIDs = setIDs
+	return nil
+}
+
+func toMillisSinceEpoch(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
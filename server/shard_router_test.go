@@ -0,0 +1,165 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// shardSet returns the trees of a shard set whose second shard is active at fakeTime,
+// and whose third starts in an hour. There's also a deleted shard and an unsharded log.
+func shardSet() []*trillian.Tree {
+	now := toMillisSinceEpoch(fakeTime)
+	hour := int64(time.Hour / time.Millisecond)
+	newShard := func(id, start, end int64) *trillian.Tree {
+		return &trillian.Tree{
+			TreeId:                     id,
+			TreeState:                  trillian.TreeState_ACTIVE,
+			TreeType:                   trillian.TreeType_LOG,
+			ShardSetId:                 7,
+			ShardStartMillisSinceEpoch: start,
+			ShardEndMillisSinceEpoch:   end,
+		}
+	}
+	first := newShard(101, now-2*hour, now-hour)
+	first.TreeState = trillian.TreeState_FROZEN
+	deleted := newShard(104, now+2*hour, now+3*hour)
+	deleted.TreeState = trillian.TreeState_SOFT_DELETED
+	return []*trillian.Tree{
+		newShard(103, now+hour, now+2*hour),
+		first,
+		newShard(102, now-hour, now+hour),
+		deleted,
+		{TreeId: 200, TreeState: trillian.TreeState_ACTIVE, TreeType: trillian.TreeType_LOG},
+	}
+}
+
+// newShardAdminStorage returns admin storage which lists trees the given number of times.
+func newShardAdminStorage(ctrl *gomock.Controller, trees []*trillian.Tree, times int) storage.AdminStorage {
+	as := storage.NewMockAdminStorage(ctrl)
+	tx := storage.NewMockReadOnlyAdminTX(ctrl)
+	as.EXPECT().Snapshot(gomock.Any()).Times(times).Return(tx, nil)
+	tx.EXPECT().ListTrees(gomock.Any()).Times(times).Return(trees, nil)
+	tx.EXPECT().Commit().Times(times).Return(nil)
+	tx.EXPECT().Close().Times(times).Return(nil)
+	return as
+}
+
+func TestShardRouter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	r := newShardRouter(newShardAdminStorage(ctrl, shardSet(), 1), fakeTimeSource, time.Minute)
+
+	for _, test := range []struct {
+		logID, want int64
+	}{
+		{logID: 101, want: 102},
+		{logID: 102, want: 102},
+		{logID: 103, want: 102},
+		{logID: 200, want: 200},
+		// Deleted shards aren't routed.
+		{logID: 104, want: 104},
+	} {
+		if got, err := r.active(ctx, test.logID); err != nil || got != test.want {
+			t.Errorf("active(%d)=%d, %v, want %d, nil", test.logID, got, err, test.want)
+		}
+	}
+
+	for _, test := range []struct {
+		logID int64
+		want  []int64
+	}{
+		{logID: 103, want: []int64{102, 101}},
+		{logID: 200, want: []int64{200}},
+	} {
+		if got, err := r.newestFirst(ctx, test.logID); err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("newestFirst(%d)=%v, %v, want %v, nil", test.logID, got, err, test.want)
+		}
+	}
+}
+
+func TestShardRouterRefresh(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	ts := &util.FakeTimeSource{FakeTime: fakeTime}
+	r := newShardRouter(newShardAdminStorage(ctrl, shardSet(), 2), ts, time.Minute)
+
+	if _, err := r.active(ctx, 101); err != nil {
+		t.Fatalf("active()=_, %v, want nil", err)
+	}
+	// Served from the same copy of the trees.
+	ts.FakeTime = fakeTime.Add(30 * time.Second)
+	if _, err := r.active(ctx, 101); err != nil {
+		t.Fatalf("active()=_, %v, want nil", err)
+	}
+	// Reread, and past the end of the last live shard.
+	ts.FakeTime = fakeTime.Add(2 * time.Hour)
+	if got, err := r.active(ctx, 101); grpc.Code(err) != codes.FailedPrecondition {
+		t.Errorf("active()=%d, %v, want FailedPrecondition", got, err)
+	}
+}
+
+func TestShardRouterLoadFailure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	ts := &util.FakeTimeSource{FakeTime: fakeTime}
+	trees := shardSet()
+	as := storage.NewMockAdminStorage(ctrl)
+	tx := storage.NewMockReadOnlyAdminTX(ctrl)
+	as.EXPECT().Snapshot(gomock.Any()).Times(7).Return(tx, nil)
+	tx.EXPECT().Close().Times(7).Return(nil)
+	tx.EXPECT().Commit().Times(3).Return(nil)
+	gomock.InOrder(
+		tx.EXPECT().ListTrees(gomock.Any()).Times(2).Return(nil, errors.New("list failed")),
+		tx.EXPECT().ListTrees(gomock.Any()).Return(trees, nil),
+		tx.EXPECT().ListTrees(gomock.Any()).Return(nil, errors.New("list failed")),
+	)
+	tx.EXPECT().GetTree(gomock.Any(), int64(200)).Return(trees[4], nil)
+	tx.EXPECT().GetTree(gomock.Any(), int64(102)).Return(trees[2], nil)
+	r := newShardRouter(as, ts, time.Minute)
+
+	// Before the trees have been read, only sharded logs fail.
+	if got, err := r.active(ctx, 200); err != nil || got != 200 {
+		t.Errorf("active(200)=%d, %v, want 200, nil", got, err)
+	}
+	if got, err := r.active(ctx, 102); err == nil {
+		t.Errorf("active(102)=%d, nil, want error", got)
+	}
+	if got, err := r.active(ctx, 101); err != nil || got != 102 {
+		t.Errorf("active(101)=%d, %v, want 102, nil", got, err)
+	}
+	// Once they have, the last copy is used if rereading them fails.
+	ts.FakeTime = fakeTime.Add(2 * time.Minute)
+	if got, err := r.active(ctx, 101); err != nil || got != 102 {
+		t.Errorf("active(101) after a failed reread=%d, %v, want 102, nil", got, err)
+	}
+}
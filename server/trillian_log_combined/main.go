@@ -0,0 +1,207 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The trillian_log_combined binary runs the log RPC server and the log signer in one
+// process, sharing a MySQL connection pool, for small deployments where running them
+// separately isn't worth it. Only one signer may run against a database, so at most one
+// instance should have --log_signer set. The trillian_log_server and trillian_log_signer
+// binaries have many more options.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql" // Load MySQL driver
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/keys"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/monitoring/logging"
+	"github.com/google/trillian/server"
+	"github.com/google/trillian/server/admin"
+	"github.com/google/trillian/server/interceptor"
+	"github.com/google/trillian/server/internal/serverutil"
+	"github.com/google/trillian/storage/mysql"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+)
+
+var (
+	mySQLURI             = flag.String("mysql_uri", "test:zaphod@tcp(127.0.0.1:3306)/test", "Connection URI for MySQL database")
+	logServerFlag        = flag.Bool("log_server", true, "If true, serve log and admin RPCs on --port")
+	logSignerFlag        = flag.Bool("log_signer", true, "If true, sequence and sign the queued leaves of all active logs")
+	serverPortFlag       = flag.Int("port", 8090, "Port to serve log RPC requests on")
+	exportMetricsFlag    = flag.Bool("export_metrics", true, "If true starts HTTP server and exports stats")
+	httpPortFlag         = flag.Int("http_port", 8091, "Port to serve HTTP metrics on")
+	logFormat            = flag.String("log_format", "text", "Format of logs about trees and RPCs: text, through glog, or json, one object per line on stderr")
+	defaultRPCTimeout    = flag.Duration("rpc_default_timeout", 0, "If greater than 0, the deadline given to RPCs which arrive without one")
+	storageTxTimeout     = flag.Duration("storage_tx_timeout", 0, "If greater than 0, how long a storage transaction can be open before it's rolled back")
+	sequencerSleepFlag   = flag.Duration("sequencer_sleep_between_runs", time.Second*10, "Time to pause after each sequencing pass through all logs, trees with a longer sequencing_interval_seconds skip passes")
+	batchSizeFlag        = flag.Int("batch_size", 50, "Max number of leaves to process per batch, unless overridden by the tree's sequencing_batch_size")
+	numSeqFlag           = flag.Int("num_sequencers", 10, "Number of sequencers to run in parallel")
+	sequencerGuardWindow = flag.Duration("sequencer_guard_window", 0, "If set, the time elapsed before submitted leaves are eligible for sequencing, unless overridden by the tree's sequencing_guard_window_seconds")
+	mySQLMaxOpenConns    = flag.Int("mysql_max_open_conns", 0, "If greater than 0, the most connections the MySQL pool shared by the server and signer opens")
+	mySQLStatsInterval   = flag.Duration("mysql_stats_interval", 10*time.Second, "If greater than 0, how often to export MySQL connection pool statistics as metrics")
+	headCacheLeaves      = flag.Int64("head_cache_leaves", 0, "If greater than 0, the Merkle nodes over this many of the latest leaves of each log are kept in memory, published to by the signer, so proofs at the latest tree size are mostly built without reading nodes from storage")
+
+	mySQLFlags = serverutil.AddMySQLFlags(flag.CommandLine)
+)
+
+func startRPCServer(registry extension.Registry, sequencer admin.LogSequencer, headCache *server.HeadCache) (*grpc.Server, error) {
+	statsInterceptor := monitoring.NewRPCStatsInterceptor(util.SystemTimeSource{}, "ct", "example")
+	statsInterceptor.Publish()
+	logOpts := interceptor.RequestLogOptions{}
+	grpcServer := grpc.NewServer(
+		grpc.UnaryInterceptor(interceptor.Combine(interceptor.RequestLog(logOpts), statsInterceptor.Interceptor(), interceptor.Deadline(*defaultRPCTimeout), interceptor.WrapErrors())),
+		grpc.StreamInterceptor(interceptor.CombineStream(interceptor.RequestLogStream(logOpts), interceptor.DeadlineStream(*defaultRPCTimeout), interceptor.WrapErrorsStream())),
+	)
+
+	logServer := server.NewTrillianLogRPCServer(registry, new(util.SystemTimeSource))
+	if err := logServer.IsHealthy(); err != nil {
+		return nil, err
+	}
+	if headCache != nil {
+		logServer.SetHeadCache(headCache)
+	}
+	trillian.RegisterTrillianLogServer(grpcServer, logServer)
+	adminServer := admin.New(registry)
+	if sequencer != nil {
+		adminServer.SetLogSequencer(sequencer)
+	}
+	trillian.RegisterTrillianAdminServer(grpcServer, adminServer)
+	reflection.Register(grpcServer)
+	return grpcServer, nil
+}
+
+func main() {
+	flag.Parse()
+	glog.CopyStandardLogTo("WARNING")
+	switch *logFormat {
+	case "text":
+	case "json":
+		logging.SetJSON(os.Stderr)
+	default:
+		glog.Exitf("Unknown --log_format %q, want text or json", *logFormat)
+	}
+	if !*logServerFlag && !*logSignerFlag {
+		glog.Exit("At least one of --log_server and --log_signer must be set")
+	}
+	glog.Infof("**** Log Server (server=%v, signer=%v) Starting ****", *logServerFlag, *logSignerFlag)
+
+	db, err := mysql.OpenDBWithOptions(*mySQLURI, mySQLFlags.Options())
+	if err != nil {
+		glog.Exitf("Failed to open MySQL database: %v", err)
+	}
+	defer db.Close()
+	if *mySQLMaxOpenConns > 0 {
+		db.SetMaxOpenConns(*mySQLMaxOpenConns)
+	}
+	if *mySQLStatsInterval > 0 {
+		go mysql.ExportPoolStats(context.Background(), db, "primary", *mySQLStatsInterval)
+	}
+
+	registry := extension.Registry{
+		AdminStorage:  mysql.NewAdminStorage(db),
+		SignerFactory: keys.ProtoSignerFactory{},
+		LogStorage:    mysql.NewLogStorageWithOptions(db, mysql.StorageOptions{TxTimeout: *storageTxTimeout}),
+	}
+
+	if *exportMetricsFlag {
+		glog.Infof("Creating HTTP server starting on port: %d", *httpPortFlag)
+		http.Handle("/debug/loglevel", logging.LevelHandler())
+		if err := util.StartHTTPServer(*httpPortFlag); err != nil {
+			glog.Exitf("Failed to start http server on port %d: %v", *httpPortFlag, err)
+		}
+	}
+
+	// The head cache is shared, so the signer keeps it up to date for the server.
+	var headCache *server.HeadCache
+	if *headCacheLeaves > 0 {
+		headCache = server.NewHeadCache(*headCacheLeaves)
+	}
+
+	// The signer sequences logs on demand for the SequenceLog admin RPC in process.
+	var sequencerManager *server.SequencerManager
+	var sequencer admin.LogSequencer
+	if *logSignerFlag {
+		sequencerManager = server.NewSequencerManager(registry, *sequencerGuardWindow)
+		if *exportMetricsFlag {
+			sequencerManager.EnableQueueMetrics()
+		}
+		if headCache != nil {
+			sequencerManager.AddLeafPublisher(headCache)
+		}
+		sequencer = func(ctx context.Context, logID int64) (*trillian.SequenceLogResponse, error) {
+			leaves, root, err := sequencerManager.SequenceNow(ctx, logID, *batchSizeFlag, util.SystemTimeSource{})
+			if err != nil {
+				return nil, err
+			}
+			return &trillian.SequenceLogResponse{LeavesSequenced: int64(leaves), SignedLogRoot: &root}, nil
+		}
+	}
+
+	var rpcServer *grpc.Server
+	var lis net.Listener
+	if *logServerFlag {
+		glog.Infof("Creating RPC server starting on port: %d", *serverPortFlag)
+		if lis, err = net.Listen("tcp", fmt.Sprintf(":%d", *serverPortFlag)); err != nil {
+			glog.Exitf("Failed to listen on the server port: %d, because: %v", *serverPortFlag, err)
+		}
+		if rpcServer, err = startRPCServer(registry, sequencer, headCache); err != nil {
+			glog.Exitf("Failed to start RPC server: %v", err)
+		}
+	}
+
+	// Both roles run until a signal stops them.
+	ctx, cancel := context.WithCancel(context.Background())
+	go util.AwaitSignal(func() {
+		cancel()
+		if rpcServer != nil {
+			rpcServer.Stop()
+		}
+	})
+
+	var wg sync.WaitGroup
+	if *logSignerFlag {
+		sequencerTask := server.NewLogOperationManager(ctx, registry, *batchSizeFlag, *numSeqFlag, *sequencerSleepFlag, util.SystemTimeSource{}, sequencerManager)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sequencerTask.OperationLoop()
+		}()
+	}
+	if rpcServer != nil {
+		if err := rpcServer.Serve(lis); err != nil {
+			glog.Errorf("RPC server terminated on port %d: %v", *serverPortFlag, err)
+		}
+		// Serving failed or was stopped, either way the signer stops too.
+		cancel()
+	}
+	wg.Wait()
+
+	// Give things a few seconds to tidy up
+	glog.Infof("Stopping server, about to exit")
+	glog.Flush()
+	time.Sleep(time.Second * 5)
+}
@@ -0,0 +1,106 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The trillian_log_mirror binary follows a source Trillian log and copies its leaves into
+// a local PREORDERED_LOG tree. A trillian_log_signer running against the same database
+// integrates the copied leaves, after which the local tree serves the same roots and proofs
+// as the source.
+package main
+
+import (
+	"flag"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql" // Load MySQL driver
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/client"
+	"github.com/google/trillian/crypto/keys"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/server/internal/serverutil"
+	"github.com/google/trillian/server/mirror"
+	"github.com/google/trillian/storage/mysql"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+var (
+	mySQLURI          = flag.String("mysql_uri", "test:zaphod@tcp(127.0.0.1:3306)/test", "Connection URI for MySQL database")
+	sourceAddrFlag    = flag.String("source_addr", "", "Address of the source log's Trillian RPC server")
+	sourceLogIDFlag   = flag.Int64("source_log_id", 0, "Tree ID of the source log")
+	sourcePubKeyFlag  = flag.String("source_public_key", "", "PEM file containing the public key of the source log")
+	treeIDFlag        = flag.Int64("tree_id", 0, "Tree ID of the local PREORDERED_LOG tree to copy leaves into")
+	batchSizeFlag     = flag.Int("batch_size", 1000, "Max number of leaves to copy per pass")
+	pollIntervalFlag  = flag.Duration("poll_interval", time.Second*10, "Time to pause between passes once the mirror has caught up")
+	exportMetricsFlag = flag.Bool("export_metrics", true, "If true starts HTTP server and exports stats")
+	httpPortFlag      = flag.Int("http_port", 8093, "Port to serve HTTP metrics on")
+
+	mySQLFlags = serverutil.AddMySQLFlags(flag.CommandLine)
+)
+
+func main() {
+	flag.Parse()
+	glog.CopyStandardLogTo("WARNING")
+	glog.Info("**** Log Mirror Starting ****")
+
+	if *sourceAddrFlag == "" {
+		glog.Exitf("--source_addr must be set")
+	}
+	if *treeIDFlag == 0 || *sourceLogIDFlag == 0 {
+		glog.Exitf("--tree_id and --source_log_id must be set")
+	}
+
+	pubKey, err := keys.NewFromPublicPEMFile(*sourcePubKeyFlag)
+	if err != nil {
+		glog.Exitf("Failed to load source public key: %v", err)
+	}
+
+	hasher, err := merkle.Factory(merkle.RFC6962SHA256Type)
+	if err != nil {
+		glog.Exitf("Failed to create hasher: %v", err)
+	}
+
+	db, err := mysql.OpenDBWithOptions(*mySQLURI, mySQLFlags.Options())
+	if err != nil {
+		glog.Exitf("Failed to open MySQL database: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := grpc.Dial(*sourceAddrFlag, grpc.WithInsecure())
+	if err != nil {
+		glog.Exitf("Failed to dial source log %v: %v", *sourceAddrFlag, err)
+	}
+	defer conn.Close()
+
+	if *exportMetricsFlag {
+		glog.Infof("Creating HTTP server starting on port: %d", *httpPortFlag)
+		if err := util.StartHTTPServer(*httpPortFlag); err != nil {
+			glog.Exitf("Failed to start http server on port %d: %v", *httpPortFlag, err)
+		}
+	}
+
+	source := trillian.NewTrillianLogClient(conn)
+	verifier := client.New(*sourceLogIDFlag, source, hasher, pubKey)
+	m := mirror.New(source, verifier, *sourceLogIDFlag, *treeIDFlag, mysql.NewLogStorage(db), hasher, *batchSizeFlag)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go util.AwaitSignal(cancel)
+
+	m.Run(ctx, *pollIntervalFlag)
+
+	glog.Infof("Stopping mirror, about to exit")
+	glog.Flush()
+}
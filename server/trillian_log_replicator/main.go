@@ -0,0 +1,112 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The trillian_log_replicator binary copies a log from a primary Trillian deployment to
+// a PREORDERED_LOG tree of a secondary one, typically in another region for disaster
+// recovery, through the secondary's AddSequencedLeaves RPC. A trillian_log_signer of
+// the secondary deployment integrates the copied leaves. Each pass checks that the
+// secondary's root is the primary's root at the same size; divergence is logged as an
+// error and counted in the replication-divergences expvar map on the metrics HTTP server.
+package main
+
+import (
+	"flag"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/client"
+	"github.com/google/trillian/crypto/keys"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/server/replication"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+var (
+	primaryAddrFlag     = flag.String("primary_addr", "", "Address of the primary deployment's Trillian log server")
+	primaryLogIDFlag    = flag.Int64("primary_log_id", 0, "Tree ID of the log to replicate")
+	primaryPubKeyFlag   = flag.String("primary_public_key", "", "PEM file containing the public key of the primary log")
+	secondaryAddrFlag   = flag.String("secondary_addr", "", "Address of the secondary deployment's Trillian log server")
+	secondaryLogIDFlag  = flag.Int64("secondary_log_id", 0, "Tree ID of the PREORDERED_LOG tree to copy the log into")
+	secondaryPubKeyFlag = flag.String("secondary_public_key", "", "PEM file containing the public key of the secondary log, defaults to --primary_public_key")
+	forwardRootsFlag    = flag.Bool("forward_roots", false, "If true, the primary's signed roots are sent to the secondary's AddObservedRoot RPC once it has caught up with them. The secondary must sign with the primary's key")
+	batchSizeFlag       = flag.Int("batch_size", 1000, "Max number of leaves to copy per pass")
+	pollIntervalFlag    = flag.Duration("poll_interval", time.Second*10, "Time to pause between passes once the secondary has caught up")
+	httpPortFlag        = flag.Int("http_port", 8095, "Port to serve HTTP metrics on")
+)
+
+func dial(addr string) *grpc.ClientConn {
+	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	if err != nil {
+		glog.Exitf("Failed to dial %v: %v", addr, err)
+	}
+	return conn
+}
+
+func main() {
+	flag.Parse()
+	glog.CopyStandardLogTo("WARNING")
+	glog.Info("**** Log Replicator Starting ****")
+
+	if *primaryAddrFlag == "" || *secondaryAddrFlag == "" {
+		glog.Exitf("--primary_addr and --secondary_addr must be set")
+	}
+	if *primaryLogIDFlag == 0 || *secondaryLogIDFlag == 0 {
+		glog.Exitf("--primary_log_id and --secondary_log_id must be set")
+	}
+	if *secondaryPubKeyFlag == "" {
+		*secondaryPubKeyFlag = *primaryPubKeyFlag
+	}
+
+	primaryKey, err := keys.NewFromPublicPEMFile(*primaryPubKeyFlag)
+	if err != nil {
+		glog.Exitf("Failed to load primary public key: %v", err)
+	}
+	secondaryKey, err := keys.NewFromPublicPEMFile(*secondaryPubKeyFlag)
+	if err != nil {
+		glog.Exitf("Failed to load secondary public key: %v", err)
+	}
+
+	hasher, err := merkle.Factory(merkle.RFC6962SHA256Type)
+	if err != nil {
+		glog.Exitf("Failed to create hasher: %v", err)
+	}
+
+	primaryConn := dial(*primaryAddrFlag)
+	defer primaryConn.Close()
+	secondaryConn := dial(*secondaryAddrFlag)
+	defer secondaryConn.Close()
+
+	glog.Infof("Creating HTTP server starting on port: %d", *httpPortFlag)
+	if err := util.StartHTTPServer(*httpPortFlag); err != nil {
+		glog.Exitf("Failed to start http server on port %d: %v", *httpPortFlag, err)
+	}
+
+	primary := trillian.NewTrillianLogClient(primaryConn)
+	secondary := trillian.NewTrillianLogClient(secondaryConn)
+	r := replication.New(
+		replication.Log{Name: *primaryAddrFlag, Client: primary, LogID: *primaryLogIDFlag, Verifier: client.New(*primaryLogIDFlag, primary, hasher, primaryKey)},
+		replication.Log{Name: *secondaryAddrFlag, Client: secondary, LogID: *secondaryLogIDFlag, Verifier: client.New(*secondaryLogIDFlag, secondary, hasher, secondaryKey)},
+		hasher, replication.Options{BatchSize: *batchSizeFlag, ForwardRoots: *forwardRootsFlag})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go util.AwaitSignal(cancel)
+
+	r.Run(ctx, *pollIntervalFlag)
+
+	glog.Infof("Stopping replicator, about to exit")
+	glog.Flush()
+}
@@ -16,9 +16,18 @@ package main
 
 import (
 	"context"
+	gocrypto "crypto"
+	"database/sql"
 	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql" // Load MySQL driver
@@ -28,47 +37,643 @@ import (
 	"github.com/google/trillian/crypto/keys"
 	"github.com/google/trillian/extension"
 	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/monitoring/logging"
 	"github.com/google/trillian/monitoring/metric"
 	"github.com/google/trillian/server"
 	"github.com/google/trillian/server/admin"
+	"github.com/google/trillian/server/breakglass"
+	"github.com/google/trillian/server/interceptor"
+	"github.com/google/trillian/server/internal/serverutil"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/bigtable"
+	"github.com/google/trillian/storage/cache"
+	"github.com/google/trillian/storage/dynamodb"
+	"github.com/google/trillian/storage/envelope"
+	"github.com/google/trillian/storage/envelope/awskms"
+	"github.com/google/trillian/storage/envelope/gcpkms"
 	"github.com/google/trillian/storage/mysql"
 	"github.com/google/trillian/util"
+	"github.com/google/trillian/util/config"
+	"github.com/google/trillian/util/spiffe"
+	"github.com/soheilhy/cmux"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/reflection"
 )
 
+// liveFlags are the flags a --config file can change while serving, by sending the
+// server SIGHUP.
+var liveFlags = []string{"v", "vmodule", "log_rpcs", "log_rpc_payload_sample_rate", "log_rpc_payload_bytes"}
+
 var (
+	configFile          = flag.String("config", "", "If set, a YAML file of flag_name: value lines setting the flags not given on the command line. The logging flags are reloaded from it on SIGHUP")
+	storageSystem       = flag.String("storage_system", "mysql", "Storage to keep trees in, one of mysql, dynamodb or bigtable. DynamoDB tables are found through the usual AWS environment and only support logs")
+	dynamoDBTablePrefix = flag.String("dynamodb_table_prefix", "Trillian", "Prefix of the names of the DynamoDB tables used with --storage_system=dynamodb")
+	bigtableProject     = flag.String("bigtable_project", "", "Google Cloud project of the Bigtable instance used with --storage_system=bigtable")
+	bigtableInstance    = flag.String("bigtable_instance", "", "Bigtable instance used with --storage_system=bigtable")
+	bigtableTable       = flag.String("bigtable_table", "trillian", "Name of the table used with --storage_system=bigtable")
+	redisAddr           = flag.String("redis_addr", "localhost:6379", "Address of the Redis server queueing leaves with --storage_system=bigtable")
 	mySQLURI            = flag.String("mysql_uri", "test:zaphod@tcp(127.0.0.1:3306)/test", "Connection URI for MySQL database")
+	mySQLTenantsFile    = flag.String("mysql_tenants_file", "", "If set, a file of tenants whose trees are kept in databases of their own, one per line as: name first_tree_id last_tree_id mysql_uri. Trees are created for the tenant named in the "+interceptor.TenantHeader+" metadata of CreateTree RPCs, which --spiffe_tenant_ids must allow the client to act for. Requires --spiffe_socket")
+	mySQLShardsFile     = flag.String("mysql_shards_file", "", "If set, a file of further databases trees are spread across, one per line as: shard_name mysql_uri. Each new tree is created in the one holding the fewest trees, and where it's kept is recorded in the --mysql_uri database")
+	mySQLReadOnlyURI    = flag.String("mysql_readonly_uri", "", "Connection URI for a read replica of the --mysql_uri database. If set, log reads are served from it")
+	replicaMaxLag       = flag.Duration("replica_max_lag", 0, "If greater than 0, log reads are served from the primary while the --mysql_readonly_uri replica is further behind than this. Requires a log signer running with --replication_heartbeat_interval")
+	replicaLagInterval  = flag.Duration("replica_lag_check_interval", time.Second, "How long to cache the replica's lag for when enforcing --replica_max_lag")
 	serverPortFlag      = flag.Int("port", 8090, "Port to serve log RPC requests on")
 	exportRPCMetrics    = flag.Bool("export_metrics", true, "If true starts HTTP server and exports stats")
 	httpPortFlag        = flag.Int("http_port", 8091, "Port to serve HTTP metrics on")
+	httpDebug           = flag.Bool("http_debug", false, "If true the HTTP server also serves pprof profiles under /debug/pprof/ and goroutine stacks at /debug/goroutines")
+	shareRPCPort        = flag.Bool("share_rpc_port", false, "If true the HTTP server is served on the RPC port(s) alongside gRPC instead of on --http_port, so a single port need be exposed. Its handlers are then reachable by every RPC client. Not supported with --spiffe_socket")
 	dumpMetricsInterval = flag.Duration("dump_metrics_interval", 0, "If greater than 0, how often to dump metrics to the logs.")
+	rootMetricsInterval = flag.Duration("root_metrics_interval", 0, "If greater than 0, how often to export the age and tree size of each active log's latest signed root as metrics")
+	logFormat           = flag.String("log_format", "text", "Format of logs about trees and RPCs: text, through glog, or json, one object per line on stderr")
+	signerURL           = flag.String("signer_url", "", "If set, the base URL of a log signer's HTTP server running with --http_sequence, e.g. http://localhost:8091, which SequenceLog admin RPCs are passed on to")
+	signerTokenFile     = flag.String("signer_token_file", "", "File holding the signer's --http_sequence_token_file token, presented with the requests to --signer_url")
+	shardRefresh        = flag.Duration("shard_refresh_interval", 0, "If greater than 0, how often the windows of sharded logs are reread, so QueueLeaves RPCs for any shard of a set go to its active shard and GetLeavesByHash searches the whole set")
+	mergeDelayRefresh   = flag.Duration("merge_delay_refresh_interval", time.Minute, "If greater than 0, how often each log's max_merge_delay_seconds is reread, so QueueLeaves responses report it")
+	readOnly            = flag.Bool("readonly", false, "If true only read RPCs are served and storage is only read from, e.g. when serving proofs from a replica")
+
+	maxRecvMsgSize       = flag.Int("grpc_max_recv_msg_size", 0, "If greater than 0, the largest request in bytes the RPC server accepts, instead of gRPC's default of 4MB")
+	maxSendMsgSize       = flag.Int("grpc_max_send_msg_size", 0, "If greater than 0, the largest response in bytes the RPC server sends, e.g. to allow large GetLeavesByIndex responses")
+	maxConcurrentStreams = flag.Uint("grpc_max_concurrent_streams", 0, "If greater than 0, the most concurrent RPCs served on each client connection")
+	keepaliveTime        = flag.Duration("grpc_keepalive_time", 0, "If greater than 0, how long a client connection can be idle before the server pings it to keep it alive")
+	keepaliveTimeout     = flag.Duration("grpc_keepalive_timeout", 20*time.Second, "How long the server waits for a --grpc_keepalive_time ping to be answered before closing the connection")
+	keepaliveMinTime     = flag.Duration("grpc_keepalive_min_time", 5*time.Minute, "Clients pinging the server more often than this are disconnected")
+	keepaliveNoStreams   = flag.Bool("grpc_keepalive_permit_without_stream", false, "If true clients may ping the server while they have no RPCs in progress")
+	maxConnectionAge     = flag.Duration("grpc_max_connection_age", 0, "If greater than 0, how long a client connection may exist before the server sends it a GOAWAY, so clients reconnect and are spread over servers added since")
+	maxConnectionGrace   = flag.Duration("grpc_max_connection_age_grace", 0, "If greater than 0, how long RPCs in progress on a connection closed by --grpc_max_connection_age have to finish before it's forcibly closed")
+	gracefulStopTimeout  = flag.Duration("grpc_graceful_stop_timeout", 10*time.Second, "How long RPCs in progress have to finish when the server is signalled to stop, while clients are sent a GOAWAY. If 0, the server stops immediately")
+	interceptorOrder     = flag.String("rpc_interceptor_order", "requestlog,stats,authz,ratelimit,deadline,readonly,tenant", "Comma separated list of the order RPC interceptors run in, outermost first. Every enabled interceptor must be listed")
+	logRPCs              = flag.Bool("log_rpcs", false, "If true a line is logged for every RPC with its request ID, method, status and latency")
+	payloadSampleRate    = flag.Float64("log_rpc_payload_sample_rate", 0, "Fraction of RPCs, between 0 and 1, whose request and response are logged")
+	maxPayloadBytes      = flag.Int("log_rpc_payload_bytes", 1024, "If greater than 0, the length logged requests and responses are truncated to")
+	defaultRPCTimeout    = flag.Duration("rpc_default_timeout", 0, "If greater than 0, the deadline given to RPCs which arrive without one")
+	rpcMetricsMaxTrees   = flag.Int("rpc_metrics_max_trees", 0, "If greater than 0, RPC metrics are also recorded by tree for up to this many trees, RPCs for further trees are recorded under \"other\"")
+	rpcLatencyBuckets    = flag.String("rpc_latency_buckets", "", "If set, comma separated list of the upper bounds in milliseconds of the buckets RPC latencies are counted into by method and result, e.g. 10,50,100,500,1000")
+	storageTxTimeout     = flag.Duration("storage_tx_timeout", 0, "If greater than 0, how long a storage transaction can be open before it's rolled back and its RPC fails with DEADLINE_EXCEEDED")
+
+	clientQPS         = flag.Float64("client_qps", 0, "If greater than 0, the rate of RPCs allowed from each client IP, RPCs over it fail with RESOURCE_EXHAUSTED")
+	clientBurst       = flag.Int("client_burst", 0, "Number of RPCs a client IP can make at once after being idle, --client_qps rounded up if 0")
+	clientBanDuration = flag.Duration("client_ban_duration", 0, "If greater than 0, how long all RPCs from a client IP are rejected for once it exceeds --client_qps")
+	clientAllowlist   = flag.String("client_allowlist", "", "Comma separated list of CIDR networks and IPs of clients not limited by --client_qps")
+	trustedProxies    = flag.String("trusted_proxies", "", "Comma separated list of CIDR networks and IPs of proxies trusted to give the client IP in --client_ip_header")
+	clientIPHeader    = flag.String("client_ip_header", "x-forwarded-for", "Metadata key holding the IPs a request from one of --trusted_proxies was forwarded for")
+
+	spiffeSocket    = flag.String("spiffe_socket", "", "If set, RPCs are served over mutual TLS with SVIDs from the SPIFFE Workload API at this address, e.g. unix:///run/spire/sockets/agent.sock")
+	spiffeAdminIDs  = flag.String("spiffe_admin_ids", "", "Comma separated list of the SPIFFE IDs allowed to call the admin service with --spiffe_socket, IDs ending in / allow all IDs under them. Any client with a SPIFFE ID may if empty")
+	spiffeLogIDs    = flag.String("spiffe_log_ids", "", "Comma separated list of the SPIFFE IDs allowed to call the log service with --spiffe_socket, IDs ending in / allow all IDs under them. Any client with a SPIFFE ID may if empty")
+	spiffeTenantIDs = flag.String("spiffe_tenant_ids", "", "Comma separated list of tenant=SPIFFE ID pairs, the clients allowed to act for each tenant of --mysql_tenants_file, IDs ending in / allow all IDs under them")
+	writersRefresh  = flag.Duration("allowed_writers_refresh_interval", time.Minute, "If greater than 0, the allowed_writers of logs are enforced with --spiffe_socket, rereading each log's this often, so changes to them take up to this long to apply")
+
+	adminSocket         = flag.String("admin_socket", "", "If set, the path of a Unix socket the admin service is also served on for break-glass access when network authentication is down. Only the server's user may connect, no other authorization or rate limiting applies, and every RPC is logged")
+	adminSocketAuditLog = flag.String("admin_socket_audit_log", "", "If set, a file every --admin_socket RPC is appended to as a line of JSON, with the caller's uid and pid and the full request. RPCs which can't be written to it are refused")
+
+	createSchema       = flag.Bool("create_schema", false, "If true, create any missing storage tables and apply pending schema migrations at startup")
+	mySQLStatsInterval = flag.Duration("mysql_stats_interval", 10*time.Second, "If greater than 0, how often to export MySQL connection pool statistics as metrics")
+	slowOpThreshold    = flag.Duration("mysql_slow_operation_threshold", 0, "If greater than 0, storage operations taking at least this long are logged")
+	stmtCacheSize      = flag.Int("mysql_statement_cache_size", mysql.DefaultStatementCacheSize, "The number of prepared MySQL statements kept open, or unlimited if negative")
+
+	storageBreakerThreshold = flag.Int("storage_breaker_threshold", 0, "If greater than 0, the number of consecutive storage failures after which RPCs fail fast with UNAVAILABLE until storage recovers")
+	storageBreakerCooldown  = flag.Duration("storage_breaker_cooldown", 5*time.Second, "How long RPCs fail fast for after --storage_breaker_threshold failures before storage is probed again")
+
+	maxUnsequencedLeaves = flag.Int64("max_unsequenced_leaves", 0, "If greater than 0, QueueLeaves fails with RESOURCE_EXHAUSTED for trees with at least this many leaves waiting to be sequenced")
+	maxUnsequencedAge    = flag.Duration("max_unsequenced_age", 0, "If greater than 0, QueueLeaves fails with RESOURCE_EXHAUSTED for trees with leaves waiting to be sequenced for longer than this")
+	backlogCheckInterval = flag.Duration("backlog_check_interval", time.Second, "How long to cache the size of a tree's backlog for when enforcing --max_unsequenced_leaves and --max_unsequenced_age")
+
+	maxQueueLeaves   = flag.Int("max_queue_leaves", 0, "If greater than 0, the most leaves a QueueLeaves or AddSequencedLeaves request may hold, larger requests fail with INVALID_ARGUMENT")
+	maxLeavesByIndex = flag.Int("max_leaves_by_index", 0, "If greater than 0, the most indices a GetLeavesByIndex request may hold, larger requests fail with INVALID_ARGUMENT")
+	maxProofs        = flag.Int("max_inclusion_proofs", 0, "If greater than 0, the most inclusion proofs a GetInclusionProofByHash request may return, requests for hashes with more leaves fail with INVALID_ARGUMENT")
+	maxResponseBytes = flag.Int64("max_response_bytes", 0, "If greater than 0, the most bytes of leaves or proofs a GetLeavesByIndex, GetLeavesByHash or GetInclusionProofByHash response may hold, larger responses fail with OUT_OF_RANGE. GetLeavesByIndexStream splits its leaves between responses of up to this size")
+
+	subtreeCacheStrategy = flag.String("subtree_cache_strategy", cache.StrategyNone, "How subtrees read from storage are kept between requests, one of none or lru")
+	subtreeCacheSize     = flag.Int("subtree_cache_size", 10000, "Number of subtrees kept with --subtree_cache_strategy=lru")
+	preloadLogIDs        = flag.String("preload_log_ids", "", "Comma separated list of log IDs whose top tree levels are read at startup, requires --subtree_cache_strategy")
+	preloadLevels        = flag.Int("preload_levels", 8, "Number of tree levels read for each of --preload_log_ids")
+
+	blobThresholdBytes = flag.Int("blob_threshold_bytes", 1<<20, "Size in bytes, after compression, above which leaf values are kept in --blob_store")
+
+	leafEncryptionKMS = flag.String("leaf_encryption_kms", "", "If set, leaf data is encrypted at rest with per-tree keys wrapped by this KMS, one of gcp, aws or local")
+	leafEncryptionKey = flag.String("leaf_encryption_key", "", "Key data keys are wrapped with for --leaf_encryption_kms: a Cloud KMS CryptoKey name, an AWS KMS key ID or the path of a file holding a 32 byte key")
+
+	leafCacheBytes  = flag.Int64("leaf_cache_bytes", 0, "If greater than 0, the size in bytes of an in-memory LRU cache for leaves read by GetLeavesByIndex")
+	proofCacheBytes = flag.Int64("proof_cache_bytes", 0, "If greater than 0, the size in bytes of an in-memory LRU cache for inclusion proofs")
+	headCacheLeaves = flag.Int64("head_cache_leaves", 0, "If greater than 0, the Merkle nodes over this many of the latest leaves of each log are kept in memory, so proofs at the latest tree size are mostly built without reading nodes from storage")
+
+	witnessKeys   = flag.String("witness_keys", "", "Comma separated list of name=public_key_pem_file pairs for the witnesses allowed to cosign log roots")
+	witnessQuorum = flag.Int("witness_quorum", 1, "Number of witnesses which must cosign a root before it's returned by witnessed GetLatestSignedLogRoot requests")
+)
+
+// endpoints is a repeatable flag of addresses to listen on.
+type endpoints []string
+
+func (e *endpoints) String() string {
+	return strings.Join(*e, ",")
+}
+
+// Set adds the comma separated addresses in value, so a --config file can give several.
+func (e *endpoints) Set(value string) error {
+	for _, addr := range strings.Split(value, ",") {
+		if addr = strings.TrimSpace(addr); addr == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return err
+		}
+		*e = append(*e, addr)
+	}
+	return nil
+}
+
+var rpcEndpoints endpoints
+
+var (
+	mySQLFlags = serverutil.AddMySQLFlags(flag.CommandLine)
+	alertFlags = serverutil.AddAlertFlags(flag.CommandLine, "log-latest-root-age-seconds>3600,ratio:ct/example/errors-by-handler:ct/example/requests-by-handler>0.05")
+	pushFlags  = serverutil.AddPushFlags(flag.CommandLine, "log-server")
+	blobFlags  = serverutil.AddBlobFlags(flag.CommandLine, "If set, where leaf values larger than --blob_threshold_bytes are kept instead of MySQL, one of file, gcs or s3")
 )
 
-func startRPCServer(registry extension.Registry) (*grpc.Server, error) {
+func init() {
+	flag.Var(&rpcEndpoints, "rpc_endpoint", "Address to serve log RPC requests on, e.g. 10.0.0.1:8090 or [2001:db8::1]:8090, instead of all addresses on --port. May be repeated")
+}
+
+// loadWitnessKeys parses the --witness_keys flag.
+func loadWitnessKeys(spec string) (map[string]gocrypto.PublicKey, error) {
+	witnesses := make(map[string]gocrypto.PublicKey)
+	for _, w := range strings.Split(spec, ",") {
+		parts := strings.SplitN(w, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("malformed witness %q, want name=key_file", w)
+		}
+		key, err := keys.NewFromPublicPEMFile(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to load key for witness %v: %v", parts[0], err)
+		}
+		witnesses[parts[0]] = key
+	}
+	return witnesses, nil
+}
+
+// serverOptions returns the gRPC server options set by the --grpc_* flags.
+func serverOptions() []grpc.ServerOption {
+	opts := []grpc.ServerOption{
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             *keepaliveMinTime,
+			PermitWithoutStream: *keepaliveNoStreams,
+		}),
+	}
+	if *maxRecvMsgSize > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(*maxRecvMsgSize))
+	}
+	if *maxSendMsgSize > 0 {
+		opts = append(opts, grpc.MaxSendMsgSize(*maxSendMsgSize))
+	}
+	if *maxConcurrentStreams > 0 {
+		opts = append(opts, grpc.MaxConcurrentStreams(uint32(*maxConcurrentStreams)))
+	}
+	if *keepaliveTime > 0 || *maxConnectionAge > 0 {
+		// Zero fields take gRPC's defaults, which don't age connections out.
+		opts = append(opts, grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:                  *keepaliveTime,
+			Timeout:               *keepaliveTimeout,
+			MaxConnectionAge:      *maxConnectionAge,
+			MaxConnectionAgeGrace: *maxConnectionGrace,
+		}))
+	}
+	return opts
+}
+
+// stopRPCServer stops s gracefully, sending clients a GOAWAY so they move to other
+// servers and letting RPCs in progress finish, for up to timeout. After that, or if
+// timeout is 0, remaining connections are closed straight away.
+func stopRPCServer(s *grpc.Server, timeout time.Duration) {
+	if timeout <= 0 {
+		s.Stop()
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		glog.Warningf("RPCs still in progress after %v, stopping server", timeout)
+		s.Stop()
+	}
+}
+
+// requestLogOptions returns the request logging options set by the --log_rpc* flags.
+func requestLogOptions() interceptor.RequestLogOptions {
+	return interceptor.RequestLogOptions{
+		LogRPCs:           *logRPCs,
+		PayloadSampleRate: *payloadSampleRate,
+		MaxPayloadBytes:   *maxPayloadBytes,
+	}
+}
+
+// spiffePolicy returns the policy set by the --spiffe_*_ids flags.
+func spiffePolicy() interceptor.SPIFFEPolicy {
+	policy := make(interceptor.SPIFFEPolicy)
+	for service, ids := range map[string]string{"trillian.TrillianAdmin": *spiffeAdminIDs, "trillian.TrillianLog": *spiffeLogIDs} {
+		for _, id := range strings.Split(ids, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				policy[service] = append(policy[service], id)
+			}
+		}
+	}
+	return policy
+}
+
+// tenantPolicy returns the policy set by --spiffe_tenant_ids.
+func tenantPolicy() (interceptor.TenantPolicy, error) {
+	policy := make(interceptor.TenantPolicy)
+	for _, pair := range strings.Split(*spiffeTenantIDs, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid --spiffe_tenant_ids entry %q, want tenant=ID", pair)
+		}
+		policy[parts[0]] = append(policy[parts[0]], parts[1])
+	}
+	return policy, nil
+}
+
+// rpcInterceptors returns the RPC interceptors which may be ordered by
+// --rpc_interceptor_order, those not enabled by flags have neither interceptor set.
+func rpcInterceptors(registry extension.Registry, stats grpc.UnaryServerInterceptor, requestLogger *interceptor.RequestLogger) ([]interceptor.Named, error) {
+	readOnlyInterceptor := interceptor.Named{Name: "readonly"}
+	if *readOnly {
+		readOnlyInterceptor.Unary = interceptor.ReadOnly()
+		readOnlyInterceptor.Stream = interceptor.ReadOnlyStream()
+	}
+	authzInterceptor := interceptor.Named{Name: "authz"}
+	if *spiffeSocket != "" {
+		authzInterceptor.Unary = interceptor.Authorize(spiffePolicy())
+		if *writersRefresh > 0 {
+			writers := server.NewWriterCache(registry, util.SystemTimeSource{}, *writersRefresh)
+			if *shardRefresh > 0 {
+				writers.EnableSharding(*shardRefresh)
+			}
+			authzInterceptor.Unary = interceptor.Combine(authzInterceptor.Unary, interceptor.AuthorizeWriters(writers.Writers))
+		}
+		authzInterceptor.Stream = interceptor.AuthorizeStream(spiffePolicy())
+	}
+	rateLimitInterceptor := interceptor.Named{Name: "ratelimit"}
+	if *clientQPS > 0 {
+		allowlist, err := interceptor.ParseNetworks(*clientAllowlist)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --client_allowlist: %v", err)
+		}
+		proxies, err := interceptor.ParseNetworks(*trustedProxies)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --trusted_proxies: %v", err)
+		}
+		limiter := interceptor.NewRateLimiter(interceptor.RateLimitOptions{
+			QPS:            *clientQPS,
+			Burst:          *clientBurst,
+			BanDuration:    *clientBanDuration,
+			Allowlist:      allowlist,
+			TrustedProxies: proxies,
+			ProxyHeader:    *clientIPHeader,
+		})
+		rateLimitInterceptor.Unary = limiter.Interceptor()
+		rateLimitInterceptor.Stream = limiter.StreamInterceptor()
+	}
+	tenantInterceptor := interceptor.Named{Name: "tenant"}
+	if *mySQLTenantsFile != "" {
+		// The tenant is named by the client, so it has to be authenticated.
+		if *spiffeSocket == "" {
+			return nil, fmt.Errorf("--mysql_tenants_file requires --spiffe_socket")
+		}
+		policy, err := tenantPolicy()
+		if err != nil {
+			return nil, err
+		}
+		tenantInterceptor.Unary = interceptor.Tenant(policy)
+	}
+	return []interceptor.Named{
+		{Name: "requestlog", Unary: requestLogger.Interceptor(), Stream: requestLogger.StreamInterceptor()},
+		{Name: "stats", Unary: stats},
+		authzInterceptor,
+		rateLimitInterceptor,
+		{Name: "deadline", Unary: interceptor.Deadline(*defaultRPCTimeout), Stream: interceptor.DeadlineStream(*defaultRPCTimeout)},
+		readOnlyInterceptor,
+		tenantInterceptor,
+	}, nil
+}
+
+// newAdminServer creates the admin service, passing SequenceLog RPCs on to --signer_url.
+func newAdminServer(registry extension.Registry) (*admin.Server, error) {
+	adminServer := admin.New(registry)
+	if *signerURL != "" {
+		token, err := ioutil.ReadFile(*signerTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read --signer_token_file: %v", err)
+		}
+		adminServer.SetLogSequencer(server.SequenceOverHTTP(*signerURL, strings.TrimSpace(string(token)), http.DefaultClient))
+	}
+	return adminServer, nil
+}
+
+// startBreakGlassServer creates the server for --admin_socket, which serves only the
+// admin service and audits every RPC instead of authorizing it.
+func startBreakGlassServer(adminServer *admin.Server) (*grpc.Server, error) {
+	var auditLog io.Writer
+	if *adminSocketAuditLog != "" {
+		f, err := os.OpenFile(*adminSocketAuditLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open --admin_socket_audit_log: %v", err)
+		}
+		auditLog = f
+	}
+	auditor := breakglass.NewAuditor(auditLog, util.SystemTimeSource{})
+	unary := interceptor.Combine(auditor.Interceptor(), interceptor.WrapErrors())
+	stream := interceptor.CombineStream(auditor.StreamInterceptor(), interceptor.WrapErrorsStream())
+	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(unary), grpc.StreamInterceptor(stream))
+	trillian.RegisterTrillianAdminServer(grpcServer, adminServer)
+	return grpcServer, nil
+}
+
+// shareListener serves HTTP/1.1 requests on lis with handler, and returns a listener of
+// its other connections, for gRPC to be served on. HTTP/2 connections are taken to be
+// gRPC, as cmux would otherwise have to wait for their first request's headers.
+func shareListener(lis net.Listener, handler http.Handler) net.Listener {
+	m := cmux.New(lis)
+	httpLis := m.Match(cmux.HTTP1Fast())
+	rpcLis := m.Match(cmux.Any())
+	go func() {
+		if err := http.Serve(httpLis, handler); err != nil {
+			glog.Infof("HTTP server terminated on %v: %v", lis.Addr(), err)
+		}
+	}()
+	go func() {
+		if err := m.Serve(); err != nil {
+			glog.Infof("Connection multiplexer terminated on %v: %v", lis.Addr(), err)
+		}
+	}()
+	return rpcLis
+}
+
+// startRPCServer creates the RPC server, serving plaintext if creds is nil.
+func startRPCServer(registry extension.Registry, adminServer *admin.Server, requestLogger *interceptor.RequestLogger, creds credentials.TransportCredentials) (*grpc.Server, error) {
 	// Create and publish the RPC stats objects
 	statsInterceptor := monitoring.NewRPCStatsInterceptor(util.SystemTimeSource{}, "ct", "example")
+	if *rpcMetricsMaxTrees > 0 {
+		statsInterceptor.EnableTreeLabels(*rpcMetricsMaxTrees)
+	}
+	if *rpcLatencyBuckets != "" {
+		bounds, err := monitoring.ParseBuckets(*rpcLatencyBuckets)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --rpc_latency_buckets: %v", err)
+		}
+		statsInterceptor.EnableLatencyHistograms(bounds)
+	}
 	statsInterceptor.Publish()
 
-	// Create the server, using the interceptor to record stats on the requests
-	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(statsInterceptor.Interceptor()))
+	// Create the server, using the interceptors to record stats on the requests etc.
+	interceptors, err := rpcInterceptors(registry, statsInterceptor.Interceptor(), requestLogger)
+	if err != nil {
+		return nil, err
+	}
+	unary, stream, err := interceptor.Chain(strings.Split(*interceptorOrder, ","), interceptors)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --rpc_interceptor_order: %v", err)
+	}
+	// Storage errors are always translated, innermost, so every interceptor sees their codes.
+	unary = interceptor.Combine(unary, interceptor.WrapErrors())
+	stream = interceptor.CombineStream(stream, interceptor.WrapErrorsStream())
+	opts := append(serverOptions(), grpc.UnaryInterceptor(unary), grpc.StreamInterceptor(stream))
+	if creds != nil {
+		opts = append(opts, grpc.Creds(creds))
+	}
+	grpcServer := grpc.NewServer(opts...)
 
 	logServer := server.NewTrillianLogRPCServer(registry, new(util.SystemTimeSource))
 	if err := logServer.IsHealthy(); err != nil {
 		return nil, err
 	}
+	if *maxUnsequencedLeaves > 0 || *maxUnsequencedAge > 0 {
+		logServer.SetBacklogLimits(server.BacklogLimits{
+			MaxLeaves:       *maxUnsequencedLeaves,
+			MaxAge:          *maxUnsequencedAge,
+			RefreshInterval: *backlogCheckInterval,
+		})
+	}
+	logServer.SetRequestLimits(server.RequestLimits{
+		MaxQueueLeaves:   *maxQueueLeaves,
+		MaxLeavesByIndex: *maxLeavesByIndex,
+		MaxProofs:        *maxProofs,
+		MaxResponseBytes: *maxResponseBytes,
+	})
+	if *witnessKeys != "" {
+		witnesses, err := loadWitnessKeys(*witnessKeys)
+		if err != nil {
+			return nil, err
+		}
+		if *witnessQuorum < 1 || *witnessQuorum > len(witnesses) {
+			return nil, fmt.Errorf("--witness_quorum=%d, want between 1 and the number of witnesses (%d)", *witnessQuorum, len(witnesses))
+		}
+		logServer.SetWitnesses(witnesses, *witnessQuorum)
+	}
+	logServer.SetLeafCacheSize(*leafCacheBytes)
+	logServer.SetProofCacheSize(*proofCacheBytes)
+	if *headCacheLeaves > 0 {
+		logServer.SetHeadCache(server.NewHeadCache(*headCacheLeaves))
+	}
+	if *shardRefresh > 0 {
+		logServer.EnableSharding(*shardRefresh)
+	}
+	if *mergeDelayRefresh > 0 {
+		logServer.EnableMergeDelays(*mergeDelayRefresh)
+	}
 	trillian.RegisterTrillianLogServer(grpcServer, logServer)
 
-	adminServer := admin.New(registry)
 	trillian.RegisterTrillianAdminServer(grpcServer, adminServer)
 
 	reflection.Register(grpcServer)
 	return grpcServer, nil
 }
 
+func newKeyWrapper(ctx context.Context) (envelope.KeyWrapper, error) {
+	switch *leafEncryptionKMS {
+	case "gcp":
+		return gcpkms.NewKeyWrapper(ctx, *leafEncryptionKey)
+	case "aws":
+		return awskms.NewKeyWrapper(*leafEncryptionKey)
+	case "local":
+		key, err := ioutil.ReadFile(*leafEncryptionKey)
+		if err != nil {
+			return nil, err
+		}
+		return envelope.NewLocalKeyWrapper(key)
+	}
+	return nil, fmt.Errorf("unknown KMS %q", *leafEncryptionKMS)
+}
+
+// openMySQLStorage opens the --mysql_uri database, and any tenant, shard and replica
+// databases, and returns the registry with storage in them, the subtree cache shared by
+// that storage and the databases for main to close.
+func openMySQLStorage() (extension.Registry, *cache.SharedSubtreeCache, []*sql.DB) {
+	db, err := mysql.OpenDBWithOptions(*mySQLURI, mySQLFlags.Options())
+	if err != nil {
+		glog.Exitf("Failed to open MySQL database: %v", err)
+	}
+	dbs := []*sql.DB{db}
+	if *createSchema {
+		if err := mysql.CreateSchema(db); err != nil {
+			glog.Exitf("Failed to create MySQL schema: %v", err)
+		}
+	}
+	if *mySQLStatsInterval > 0 {
+		go mysql.ExportPoolStats(context.Background(), db, "primary", *mySQLStatsInterval)
+	}
+
+	subtreeCache, err := cache.NewSharedSubtreeCache(cache.SharedOptions{
+		Strategy: *subtreeCacheStrategy,
+		Size:     *subtreeCacheSize,
+	})
+	if err != nil {
+		glog.Exitf("Invalid subtree cache flags: %v", err)
+	}
+	storageOpts := mysql.StorageOptions{
+		SubtreeCache:           subtreeCache,
+		SlowOperationThreshold: *slowOpThreshold,
+		StatementCacheSize:     *stmtCacheSize,
+		TxTimeout:              *storageTxTimeout,
+	}
+	if blobFlags.Enabled() {
+		blobs, err := blobFlags.NewStore(context.Background())
+		if err != nil {
+			glog.Exit(err)
+		}
+		storageOpts.BlobStore = blobs
+		storageOpts.BlobThreshold = *blobThresholdBytes
+	}
+	if *leafEncryptionKMS != "" {
+		wrapper, err := newKeyWrapper(context.Background())
+		if err != nil {
+			glog.Exitf("Failed to create %v key wrapper: %v", *leafEncryptionKMS, err)
+		}
+		storageOpts.KeyWrapper = wrapper
+	}
+
+	registry := extension.Registry{
+		AdminStorage:  mysql.NewAdminStorage(db),
+		SignerFactory: keys.ProtoSignerFactory{},
+		LogStorage:    mysql.NewLogStorageWithOptions(db, storageOpts),
+	}
+	if *mySQLTenantsFile != "" {
+		if *mySQLReadOnlyURI != "" {
+			glog.Exit("--mysql_tenants_file can't be used with --mysql_readonly_uri")
+		}
+		tenants, err := mysql.OpenTenants(*mySQLTenantsFile, mySQLFlags.Options())
+		if err != nil {
+			glog.Exitf("Failed to open tenant databases: %v", err)
+		}
+		for _, t := range tenants {
+			dbs = append(dbs, t.DB)
+			if *createSchema {
+				if err := mysql.CreateSchema(t.DB); err != nil {
+					glog.Exitf("Failed to create MySQL schema of tenant %v: %v", t.Name, err)
+				}
+			}
+			if *mySQLStatsInterval > 0 {
+				go mysql.ExportPoolStats(context.Background(), t.DB, "tenant_"+t.Name, *mySQLStatsInterval)
+			}
+		}
+		if registry.AdminStorage, err = mysql.NewTenantAdminStorage(db, tenants); err != nil {
+			glog.Exitf("Invalid --mysql_tenants_file: %v", err)
+		}
+		if registry.LogStorage, err = mysql.NewTenantLogStorage(db, tenants, storageOpts); err != nil {
+			glog.Exitf("Invalid --mysql_tenants_file: %v", err)
+		}
+	}
+	if *mySQLShardsFile != "" {
+		if *mySQLReadOnlyURI != "" || *mySQLTenantsFile != "" {
+			glog.Exit("--mysql_shards_file can't be used with --mysql_readonly_uri or --mysql_tenants_file")
+		}
+		shards, err := mysql.OpenDatabaseShards(*mySQLShardsFile, mySQLFlags.Options())
+		if err != nil {
+			glog.Exitf("Failed to open shard databases: %v", err)
+		}
+		for _, s := range shards {
+			dbs = append(dbs, s.DB)
+			if *createSchema {
+				if err := mysql.CreateSchema(s.DB); err != nil {
+					glog.Exitf("Failed to create MySQL schema of shard %v: %v", s.Name, err)
+				}
+			}
+			if *mySQLStatsInterval > 0 {
+				go mysql.ExportPoolStats(context.Background(), s.DB, "shard_"+s.Name, *mySQLStatsInterval)
+			}
+		}
+		if registry.AdminStorage, err = mysql.NewShardedAdminStorage(db, shards); err != nil {
+			glog.Exitf("Invalid --mysql_shards_file: %v", err)
+		}
+		if registry.LogStorage, err = mysql.NewShardedLogStorage(db, shards, storageOpts); err != nil {
+			glog.Exitf("Invalid --mysql_shards_file: %v", err)
+		}
+	}
+	if *mySQLReadOnlyURI != "" {
+		replica, err := mysql.OpenDBWithOptions(*mySQLReadOnlyURI, mySQLFlags.Options())
+		if err != nil {
+			glog.Exitf("Failed to open MySQL read replica: %v", err)
+		}
+		dbs = append(dbs, replica)
+		if *mySQLStatsInterval > 0 {
+			go mysql.ExportPoolStats(context.Background(), replica, "replica", *mySQLStatsInterval)
+		}
+		registry.LogStorage = mysql.NewLogStorageWithReplica(db, replica, mysql.ReplicaOptions{
+			MaxLag:        *replicaMaxLag,
+			CheckInterval: *replicaLagInterval,
+			Storage:       storageOpts,
+		})
+	}
+	return registry, subtreeCache, dbs
+}
+
+// preloadTopNodes reads the top levels of each of the comma separated logIDs, so the
+// first proofs served for them don't have to. Failures are logged and otherwise ignored.
+func preloadTopNodes(logStorage storage.LogStorage, logIDs string) {
+	for _, f := range strings.Split(logIDs, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		logID, err := strconv.ParseInt(f, 10, 64)
+		if err != nil {
+			glog.Exitf("Invalid log ID in --preload_log_ids: %q", f)
+		}
+		start := time.Now()
+		if err := server.PreloadTopNodes(context.Background(), logStorage, logID, *preloadLevels); err != nil {
+			glog.Warningf("%d: failed to preload tree: %v", logID, err)
+			continue
+		}
+		glog.Infof("%d: preloaded top %d tree levels in %v", logID, *preloadLevels, time.Since(start))
+	}
+}
+
 func main() {
 	flag.Parse()
+	var cfg *config.File
+	if *configFile != "" {
+		var err error
+		if cfg, err = config.Load(flag.CommandLine, *configFile); err != nil {
+			glog.Exitf("Failed to load --config: %v", err)
+		}
+	}
 	glog.CopyStandardLogTo("WARNING")
+	switch *logFormat {
+	case "text":
+	case "json":
+		logging.SetJSON(os.Stderr)
+	default:
+		glog.Exitf("Unknown --log_format %q, want text or json", *logFormat)
+	}
 	glog.Info("**** Log RPC Server Starting ****")
 
 	// Enable dumping of metrics to the log at regular interval,
@@ -77,48 +682,190 @@ func main() {
 		go metric.DumpToLog(context.Background(), *dumpMetricsInterval)
 	}
 
-	// First make sure we can access the database, quit if not
-	db, err := mysql.OpenDB(*mySQLURI)
-	if err != nil {
-		glog.Exitf("Failed to open MySQL database: %v", err)
+	var registry extension.Registry
+	var subtreeCache *cache.SharedSubtreeCache
+	switch *storageSystem {
+	case "mysql":
+		var dbs []*sql.DB
+		registry, subtreeCache, dbs = openMySQLStorage()
+		for _, db := range dbs {
+			defer db.Close()
+		}
+	case "dynamodb":
+		client, err := dynamodb.NewClient()
+		if err != nil {
+			glog.Exitf("Failed to create DynamoDB client: %v", err)
+		}
+		opts := dynamodb.Options{TablePrefix: *dynamoDBTablePrefix}
+		if *createSchema {
+			if err := dynamodb.CreateTables(context.Background(), client, opts); err != nil {
+				glog.Exitf("Failed to create DynamoDB tables: %v", err)
+			}
+		}
+		registry = extension.Registry{
+			AdminStorage:  dynamodb.NewAdminStorage(client, opts),
+			SignerFactory: keys.ProtoSignerFactory{},
+			LogStorage:    dynamodb.NewLogStorage(client, opts),
+		}
+	case "bigtable":
+		ctx := context.Background()
+		if *createSchema {
+			if err := bigtable.CreateTable(ctx, *bigtableProject, *bigtableInstance, *bigtableTable); err != nil {
+				glog.Exitf("Failed to create Bigtable table: %v", err)
+			}
+		}
+		table, err := bigtable.OpenTable(ctx, *bigtableProject, *bigtableInstance, *bigtableTable)
+		if err != nil {
+			glog.Exitf("Failed to create Bigtable client: %v", err)
+		}
+		queue := bigtable.NewRedisQueue(bigtable.NewRedisPool(*redisAddr))
+		registry = extension.Registry{
+			AdminStorage:  bigtable.NewAdminStorage(table),
+			SignerFactory: keys.ProtoSignerFactory{},
+			LogStorage:    bigtable.NewLogStorage(table, queue),
+		}
+	default:
+		glog.Exitf("Unknown --storage_system %q, want mysql, dynamodb or bigtable", *storageSystem)
 	}
-	defer db.Close()
-
-	registry := extension.Registry{
-		AdminStorage:  mysql.NewAdminStorage(db),
-		SignerFactory: keys.PEMSignerFactory{},
-		LogStorage:    mysql.NewLogStorage(db),
+	if *preloadLogIDs != "" {
+		if subtreeCache == nil {
+			glog.Exit("--preload_log_ids requires --subtree_cache_strategy")
+		}
+		preloadTopNodes(registry.LogStorage, *preloadLogIDs)
+	}
+	if *storageBreakerThreshold > 0 {
+		breaker := storage.NewCircuitBreaker(storage.BreakerOptions{
+			Threshold: *storageBreakerThreshold,
+			Cooldown:  *storageBreakerCooldown,
+		})
+		registry.AdminStorage = breaker.AdminStorage(registry.AdminStorage)
+		registry.LogStorage = breaker.LogStorage(registry.LogStorage)
+	}
+	if *readOnly {
+		glog.Info("Serving in read-only mode")
+		registry.AdminStorage = storage.NewReadOnlyAdminStorage(registry.AdminStorage)
+		registry.LogStorage = storage.NewReadOnlyLogStorage(registry.LogStorage)
 	}
 
 	// Start HTTP server (optional)
+	var httpHandler http.Handler
 	if *exportRPCMetrics {
-		glog.Infof("Creating HTP server starting on port: %d", *httpPortFlag)
-		if err := util.StartHTTPServer(*httpPortFlag); err != nil {
-			glog.Exitf("Failed to start http server on port %d: %v", *httpPortFlag, err)
+		http.Handle("/debug/loglevel", logging.LevelHandler())
+		if *shareRPCPort {
+			if *spiffeSocket != "" {
+				glog.Exit("--share_rpc_port isn't supported with --spiffe_socket, as TLS connections can't be told apart")
+			}
+			httpHandler = util.NewHTTPHandler(util.HTTPOptions{Debug: *httpDebug})
+		} else {
+			glog.Infof("Creating HTP server starting on port: %d", *httpPortFlag)
+			if err := util.StartHTTPServerWithOptions(*httpPortFlag, util.HTTPOptions{Debug: *httpDebug}); err != nil {
+				glog.Exitf("Failed to start http server on port %d: %v", *httpPortFlag, err)
+			}
+		}
+		if *rootMetricsInterval > 0 {
+			go server.ExportRootMetrics(context.Background(), registry.LogStorage, *rootMetricsInterval, util.SystemTimeSource{})
+		}
+	}
+	if alertFlags.Enabled() {
+		if err := alertFlags.StartMonitor(context.Background()); err != nil {
+			glog.Exit(err)
+		}
+	}
+	if pushFlags.Enabled() {
+		if err := pushFlags.StartPusher(context.Background()); err != nil {
+			glog.Exit(err)
 		}
 	}
 
-	// Set up the listener for the server
-	// TODO(Martin2112): More flexible listen address configuration
-	glog.Infof("Creating RPC server starting on port: %d", *serverPortFlag)
-	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", *serverPortFlag))
-	if err != nil {
-		glog.Exitf("Failed to listen on the server port: %d, because: %v", *serverPortFlag, err)
+	// Set up the listeners for the server
+	addrs := []string(rpcEndpoints)
+	if len(addrs) == 0 {
+		addrs = []string{fmt.Sprintf(":%d", *serverPortFlag)}
+	}
+	var listeners []net.Listener
+	for _, addr := range addrs {
+		glog.Infof("Creating RPC server starting on: %v", addr)
+		lis, err := net.Listen("tcp", addr)
+		if err != nil {
+			glog.Exitf("Failed to listen on %v, because: %v", addr, err)
+		}
+		if httpHandler != nil {
+			lis = shareListener(lis, httpHandler)
+		}
+		listeners = append(listeners, lis)
 	}
 
 	// Bring up the RPC server and then block until we get a signal to stop
-	rpcServer, err := startRPCServer(registry)
+	requestLogger := interceptor.NewRequestLogger(requestLogOptions())
+	var creds credentials.TransportCredentials
+	if *spiffeSocket != "" {
+		source, err := spiffe.NewSource(context.Background(), *spiffeSocket)
+		if err != nil {
+			glog.Exitf("Failed to get SVIDs from --spiffe_socket: %v", err)
+		}
+		defer source.Close()
+		creds = spiffe.ServerCredentials(source)
+	}
+	adminServer, err := newAdminServer(registry)
+	if err != nil {
+		glog.Exit(err)
+	}
+	rpcServer, err := startRPCServer(registry, adminServer, requestLogger, creds)
 	if err != nil {
 		glog.Exitf("Failed to start RPC server: %v", err)
 	}
-	go util.AwaitSignal(func() {
-		// Bring down the RPC server, which will unblock main
-		rpcServer.Stop()
-	})
+	var breakGlassServer *grpc.Server
+	var breakGlassLis net.Listener
+	if *adminSocket != "" {
+		if breakGlassServer, err = startBreakGlassServer(adminServer); err != nil {
+			glog.Exitf("Failed to start break-glass server: %v", err)
+		}
+		if breakGlassLis, err = breakglass.Listen(*adminSocket); err != nil {
+			glog.Exitf("Failed to listen on --admin_socket: %v", err)
+		}
+		glog.Warningf("Serving the admin service without authorization on %v", *adminSocket)
+	}
+	if cfg != nil {
+		go cfg.ReloadOnSIGHUP(context.Background(), liveFlags, func() {
+			requestLogger.SetOptions(requestLogOptions())
+		})
+	}
+	// Bring down the RPC server, which will unblock main. Serve returns as soon as a
+	// graceful stop starts, so main also waits for stopped.
+	var stopOnce sync.Once
+	stopped := make(chan struct{})
+	stop := func() {
+		stopOnce.Do(func() {
+			if breakGlassServer != nil {
+				breakGlassServer.Stop()
+			}
+			stopRPCServer(rpcServer, *gracefulStopTimeout)
+			close(stopped)
+		})
+	}
+	go util.AwaitSignal(stop)
 
-	if err := rpcServer.Serve(lis); err != nil {
-		glog.Errorf("RPC server terminated on port %d: %v", *serverPortFlag, err)
+	// Serve on every listener, until the server is stopped or one fails.
+	var wg sync.WaitGroup
+	for _, lis := range listeners {
+		wg.Add(1)
+		go func(lis net.Listener) {
+			defer wg.Done()
+			if err := rpcServer.Serve(lis); err != nil {
+				glog.Errorf("RPC server terminated on %v: %v", lis.Addr(), err)
+				stop()
+			}
+		}(lis)
+	}
+	if breakGlassServer != nil {
+		go func() {
+			if err := breakGlassServer.Serve(breakGlassLis); err != nil {
+				glog.Errorf("Break-glass server terminated on %v: %v", *adminSocket, err)
+			}
+		}()
 	}
+	wg.Wait()
+	<-stopped
 
 	// Give things a few seconds to tidy up
 	glog.Infof("Stopping server, about to exit")
@@ -15,7 +15,16 @@
 package main
 
 import (
+	"bytes"
+	"database/sql"
+	"errors"
 	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql" // Load MySQL driver
@@ -23,53 +32,305 @@ import (
 	"github.com/golang/glog"
 	"github.com/google/trillian/crypto/keys"
 	"github.com/google/trillian/extension"
+	"github.com/google/trillian/monitoring/logging"
 	"github.com/google/trillian/monitoring/metric"
 	"github.com/google/trillian/server"
+	"github.com/google/trillian/server/events"
+	"github.com/google/trillian/server/events/kafka"
+	"github.com/google/trillian/server/events/nats"
+	"github.com/google/trillian/server/events/pubsub"
+	"github.com/google/trillian/server/internal/serverutil"
+	"github.com/google/trillian/server/webhook"
+	"github.com/google/trillian/storage/bigtable"
+	"github.com/google/trillian/storage/blob"
+	"github.com/google/trillian/storage/dynamodb"
 	"github.com/google/trillian/storage/mysql"
 	"github.com/google/trillian/util"
+	"github.com/google/trillian/util/config"
 	"golang.org/x/net/context"
 )
 
+// liveFlags are the flags a --config file can change while running, by sending the
+// signer SIGHUP.
+var liveFlags = []string{"v", "vmodule"}
+
 var (
+	configFileFlag                = flag.String("config", "", "If set, a YAML file of flag_name: value lines setting the flags not given on the command line. The logging verbosity flags are reloaded from it on SIGHUP")
+	storageSystemFlag             = flag.String("storage_system", "mysql", "Storage to keep trees in, one of mysql, dynamodb or bigtable. Must match the log servers' flag")
+	dynamoDBTablePrefixFlag       = flag.String("dynamodb_table_prefix", "Trillian", "Prefix of the names of the DynamoDB tables used with --storage_system=dynamodb")
+	bigtableProjectFlag           = flag.String("bigtable_project", "", "Google Cloud project of the Bigtable instance used with --storage_system=bigtable")
+	bigtableInstanceFlag          = flag.String("bigtable_instance", "", "Bigtable instance used with --storage_system=bigtable")
+	bigtableTableFlag             = flag.String("bigtable_table", "trillian", "Name of the table used with --storage_system=bigtable")
+	redisAddrFlag                 = flag.String("redis_addr", "localhost:6379", "Address of the Redis server queueing leaves with --storage_system=bigtable")
 	mySQLURI                      = flag.String("mysql_uri", "test:zaphod@tcp(127.0.0.1:3306)/test", "Connection URI for MySQL database")
+	mySQLTenantsFile              = flag.String("mysql_tenants_file", "", "If set, a file of tenants whose trees are kept in databases of their own, one per line as: name first_tree_id last_tree_id mysql_uri. Must match the log servers' file")
+	mySQLShardsFile               = flag.String("mysql_shards_file", "", "If set, a file of further databases trees are spread across, one per line as: shard_name mysql_uri. Must match the log servers' file")
 	exportRPCMetrics              = flag.Bool("export_metrics", true, "If true starts HTTP server and exports stats")
 	httpPortFlag                  = flag.Int("http_port", 8091, "Port to serve HTTP metrics on")
-	sequencerSleepBetweenRunsFlag = flag.Duration("sequencer_sleep_between_runs", time.Second*10, "Time to pause after each sequencing pass through all logs")
-	batchSizeFlag                 = flag.Int("batch_size", 50, "Max number of leaves to process per batch")
+	httpDebug                     = flag.Bool("http_debug", false, "If true the HTTP server also serves pprof profiles under /debug/pprof/ and goroutine stacks at /debug/goroutines")
+	httpSequenceFlag              = flag.Bool("http_sequence", false, "If true the HTTP server also accepts POST /sequence?log_id=N requests, which sequence and sign a log straight away for the log server's SequenceLog admin RPC. Requires --http_sequence_token_file")
+	httpSequenceTokenFile         = flag.String("http_sequence_token_file", "", "File holding the token --http_sequence requests must present as a bearer token, the log server's --signer_token_file")
+	sequencerSleepBetweenRunsFlag = flag.Duration("sequencer_sleep_between_runs", time.Second*10, "Time to pause after each sequencing pass through all logs, trees with a longer sequencing_interval_seconds skip passes")
+	batchSizeFlag                 = flag.Int("batch_size", 50, "Max number of leaves to process per batch, unless overridden by the tree's sequencing_batch_size")
 	numSeqFlag                    = flag.Int("num_sequencers", 10, "Number of sequencers to run in parallel")
-	sequencerGuardWindowFlag      = flag.Duration("sequencer_guard_window", 0, "If set, the time elapsed before submitted leaves are eligible for sequencing")
+	sequencerGuardWindowFlag      = flag.Duration("sequencer_guard_window", 0, "If set, the time elapsed before submitted leaves are eligible for sequencing, unless overridden by the tree's sequencing_guard_window_seconds")
 	dumpMetricsInterval           = flag.Duration("dump_metrics_interval", 0, "If greater than 0, how often to dump metrics to the logs.")
+	logFormat                     = flag.String("log_format", "text", "Format of logs about trees and RPCs: text, through glog, or json, one object per line on stderr")
+	adaptiveBatchingFlag          = flag.Bool("adaptive_batching", false, "If true, adapt each log's batch size between --min_batch_size and --max_batch_size, starting from --batch_size")
+	minBatchSizeFlag              = flag.Int("min_batch_size", 10, "Smallest batch size used with --adaptive_batching")
+	maxBatchSizeFlag              = flag.Int("max_batch_size", 5000, "Largest batch size used with --adaptive_batching")
+	batchLatencyTargetFlag        = flag.Duration("batch_latency_target", 2*time.Second, "Batches that take longer than this to sequence are shrunk when using --adaptive_batching")
+	sequencerFairnessFlag         = flag.String("sequencer_fairness", "none", "How each sequencing pass is shared between logs: none, round_robin, which rotates the order logs are sequenced in and caps each at --sequencer_tree_budget leaves, or weighted, which also sequences trees with a higher sequencing_priority first and multiplies their budget by it")
+	sequencerTreeBudgetFlag       = flag.Int("sequencer_tree_budget", 0, "Most leaves a log sequences per pass with --sequencer_fairness, below its batch size to have any effect. If 0, only the order logs are sequenced in changes")
+	selfCheckSamplesFlag          = flag.Int("self_check_samples", 0, "If greater than 0, how many leaves of a log to pick at random after each run which signs a new root, and verify the inclusion proofs and leaf hashes of, counting failures in the sequencer-self-check-failures metric")
+	runOnceFlag                   = flag.Bool("run_once", false, "If true, sequence all pending leaves once and exit, with a non-zero status if any log failed")
+	logIDsFlag                    = flag.String("log_ids", "", "Comma separated list of log IDs to sequence in --run_once mode, defaults to all active logs")
+	rootWebhookURLsFlag           = flag.String("root_webhook_urls", "", "Comma separated list of URLs to POST each newly signed root to, as a JSON SignedLogRoot")
+	rootWebhookRetriesFlag        = flag.Int("root_webhook_retries", 3, "Number of times to retry delivering a root to a webhook URL")
+	rootWebhookBackoffFlag        = flag.Duration("root_webhook_backoff", time.Second, "Time to wait before the first webhook retry, doubled for each further retry")
+	rootWebhookTimeoutFlag        = flag.Duration("root_webhook_timeout", 10*time.Second, "Timeout for each webhook delivery attempt")
+	eventSinkFlag                 = flag.String("event_sink", "", "If set, the message bus to publish new roots and integrated leaves to, one of kafka, nats or pubsub")
+	eventRootTopicFlag            = flag.String("event_root_topic", "trillian-roots", "Topic new roots are published to with --event_sink, roots aren't published if empty")
+	eventLeafTopicFlag            = flag.String("event_leaf_topic", "trillian-leaves", "Topic integrated leaves are published to with --event_sink, leaves aren't published if empty")
+	eventCheckpointTopicFlag      = flag.String("event_checkpoint_topic", "", "Topic signed checkpoint notes are published to with --event_sink, requires --checkpoint_origin_prefix")
+	checkpointOriginPrefixFlag    = flag.String("checkpoint_origin_prefix", "", "Prefix of each log's checkpoint origin, e.g. example.com/log, the log ID is appended to it. Checkpoints can only be signed with ECDSA keys")
+	eventTimeoutFlag              = flag.Duration("event_timeout", 10*time.Second, "Timeout for publishing each event with --event_sink")
+	kafkaBrokersFlag              = flag.String("kafka_brokers", "localhost:9092", "Comma separated list of Kafka brokers for --event_sink=kafka")
+	natsURLFlag                   = flag.String("nats_url", "nats://localhost:4222", "URL of the NATS server for --event_sink=nats")
+	pubsubProjectFlag             = flag.String("pubsub_project", "", "GCP project holding the topics for --event_sink=pubsub")
+	replicationHeartbeatFlag      = flag.Duration("replication_heartbeat_interval", 0, "If greater than 0, how often to write a heartbeat to the database, which log servers use to measure the lag of read replicas")
+	leafExpiryIntervalFlag        = flag.Duration("leaf_expiry_interval", time.Hour, "If greater than 0, how often to prune the values and extra data of leaves older than their log's leaf_retention_seconds")
+	leafExpiryBatchSizeFlag       = flag.Int64("leaf_expiry_batch_size", 1000, "Max number of leaves whose data is pruned per transaction by --leaf_expiry_interval")
+	unsequencedGCIntervalFlag     = flag.Duration("unsequenced_gc_interval", 0, "If greater than 0, how often to delete the queued leaves of logs which have been finalized or deleted for --unsequenced_gc_grace_period")
+	unsequencedGCGraceFlag        = flag.Duration("unsequenced_gc_grace_period", 24*time.Hour, "How long a log must have been finalized or deleted, without further updates, before --unsequenced_gc_interval deletes its queued leaves")
+	unsequencedGCBatchSizeFlag    = flag.Int("unsequenced_gc_batch_size", 1000, "Max number of queued leaves deleted per transaction by --unsequenced_gc_interval")
+	storageUsageIntervalFlag      = flag.Duration("storage_usage_interval", 0, "If greater than 0, how often to recount the bytes of leaf data and Merkle tree nodes stored for each tree, correcting the usage accounted as they're written and exporting it as metrics")
+
+	createSchema       = flag.Bool("create_schema", false, "If true, create any missing storage tables and apply pending schema migrations at startup")
+	mySQLStatsInterval = flag.Duration("mysql_stats_interval", 10*time.Second, "If greater than 0, how often to export MySQL connection pool statistics as metrics")
+	slowOpThreshold    = flag.Duration("mysql_slow_operation_threshold", 0, "If greater than 0, storage operations taking at least this long are logged")
+	stmtCacheSize      = flag.Int("mysql_statement_cache_size", mysql.DefaultStatementCacheSize, "The number of prepared MySQL statements kept open, or unlimited if negative")
+
+	mySQLFlags = serverutil.AddMySQLFlags(flag.CommandLine)
+	alertFlags = serverutil.AddAlertFlags(flag.CommandLine, "sequencer-unsequenced-leaves>10000,sequencer-oldest-unsequenced-age-seconds>600")
+	pushFlags  = serverutil.AddPushFlags(flag.CommandLine, "log-signer")
+	blobFlags  = serverutil.AddBlobFlags(flag.CommandLine, "If set, where the log servers keep large leaf values, one of file, gcs or s3, so --leaf_expiry_interval can delete those it prunes. Must match the log servers' flag")
 )
 
+func parseLogIDs(s string) ([]int64, error) {
+	var logIDs []int64
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		logID, err := strconv.ParseInt(f, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		logIDs = append(logIDs, logID)
+	}
+	return logIDs, nil
+}
+
+func parseURLs(s string) []string {
+	var urls []string
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			urls = append(urls, f)
+		}
+	}
+	return urls
+}
+
+func newEventSink(ctx context.Context) (events.Sink, error) {
+	switch *eventSinkFlag {
+	case "kafka":
+		return kafka.NewSink(parseURLs(*kafkaBrokersFlag))
+	case "nats":
+		return nats.NewSink(*natsURLFlag)
+	case "pubsub":
+		return pubsub.NewSink(ctx, *pubsubProjectFlag)
+	}
+	return nil, fmt.Errorf("unknown event sink %q", *eventSinkFlag)
+}
+
+// openMySQLStorage opens the --mysql_uri database, and any tenant and shard databases,
+// and returns the registry with storage in them and the databases, --mysql_uri first.
+// The databases opened before any error are returned with it, to be closed.
+func openMySQLStorage() (extension.Registry, []*sql.DB, error) {
+	db, err := mysql.OpenDBWithOptions(*mySQLURI, mySQLFlags.Options())
+	if err != nil {
+		return extension.Registry{}, nil, fmt.Errorf("failed to open MySQL database: %v", err)
+	}
+	dbs := []*sql.DB{db}
+	if *createSchema {
+		if err := mysql.CreateSchema(db); err != nil {
+			return extension.Registry{}, dbs, fmt.Errorf("failed to create MySQL schema: %v", err)
+		}
+	}
+	if *mySQLStatsInterval > 0 {
+		go mysql.ExportPoolStats(context.Background(), db, "primary", *mySQLStatsInterval)
+	}
+
+	storageOpts := mysql.StorageOptions{
+		SlowOperationThreshold: *slowOpThreshold,
+		StatementCacheSize:     *stmtCacheSize,
+	}
+	registry := extension.Registry{
+		AdminStorage:  mysql.NewAdminStorage(db),
+		SignerFactory: keys.ProtoSignerFactory{},
+		LogStorage:    mysql.NewLogStorageWithOptions(db, storageOpts),
+	}
+
+	if *mySQLTenantsFile != "" {
+		tenants, err := mysql.OpenTenants(*mySQLTenantsFile, mySQLFlags.Options())
+		if err != nil {
+			return extension.Registry{}, dbs, fmt.Errorf("failed to open tenant databases: %v", err)
+		}
+		for _, t := range tenants {
+			if *createSchema {
+				if err := mysql.CreateSchema(t.DB); err != nil {
+					return extension.Registry{}, dbs, fmt.Errorf("failed to create MySQL schema of tenant %v: %v", t.Name, err)
+				}
+			}
+			if *mySQLStatsInterval > 0 {
+				go mysql.ExportPoolStats(context.Background(), t.DB, "tenant_"+t.Name, *mySQLStatsInterval)
+			}
+			dbs = append(dbs, t.DB)
+		}
+		if registry.AdminStorage, err = mysql.NewTenantAdminStorage(db, tenants); err != nil {
+			return extension.Registry{}, dbs, fmt.Errorf("invalid --mysql_tenants_file: %v", err)
+		}
+		if registry.LogStorage, err = mysql.NewTenantLogStorage(db, tenants, storageOpts); err != nil {
+			return extension.Registry{}, dbs, fmt.Errorf("invalid --mysql_tenants_file: %v", err)
+		}
+	}
+	if *mySQLShardsFile != "" {
+		if *mySQLTenantsFile != "" {
+			return extension.Registry{}, dbs, errors.New("--mysql_shards_file can't be used with --mysql_tenants_file")
+		}
+		shards, err := mysql.OpenDatabaseShards(*mySQLShardsFile, mySQLFlags.Options())
+		if err != nil {
+			return extension.Registry{}, dbs, fmt.Errorf("failed to open shard databases: %v", err)
+		}
+		for _, s := range shards {
+			if *createSchema {
+				if err := mysql.CreateSchema(s.DB); err != nil {
+					return extension.Registry{}, dbs, fmt.Errorf("failed to create MySQL schema of shard %v: %v", s.Name, err)
+				}
+			}
+			if *mySQLStatsInterval > 0 {
+				go mysql.ExportPoolStats(context.Background(), s.DB, "shard_"+s.Name, *mySQLStatsInterval)
+			}
+			dbs = append(dbs, s.DB)
+		}
+		if registry.AdminStorage, err = mysql.NewShardedAdminStorage(db, shards); err != nil {
+			return extension.Registry{}, dbs, fmt.Errorf("invalid --mysql_shards_file: %v", err)
+		}
+		if registry.LogStorage, err = mysql.NewShardedLogStorage(db, shards, storageOpts); err != nil {
+			return extension.Registry{}, dbs, fmt.Errorf("invalid --mysql_shards_file: %v", err)
+		}
+	}
+	return registry, dbs, nil
+}
+
 func main() {
 	flag.Parse()
+	var cfg *config.File
+	if *configFileFlag != "" {
+		var err error
+		if cfg, err = config.Load(flag.CommandLine, *configFileFlag); err != nil {
+			glog.Exitf("Failed to load --config: %v", err)
+		}
+	}
 	glog.CopyStandardLogTo("WARNING")
+	switch *logFormat {
+	case "text":
+	case "json":
+		logging.SetJSON(os.Stderr)
+	default:
+		glog.Exitf("Unknown --log_format %q, want text or json", *logFormat)
+	}
 	glog.Info("**** Log Signer Starting ****")
 
+	logIDs, err := parseLogIDs(*logIDsFlag)
+	if err != nil {
+		glog.Exitf("Invalid --log_ids %q: %v", *logIDsFlag, err)
+	}
+	if len(logIDs) > 0 && !*runOnceFlag {
+		glog.Exit("--log_ids is only supported with --run_once")
+	}
+	if err := run(cfg, logIDs); err != nil {
+		glog.Exit(err)
+	}
+}
+
+// run runs the signer until it's stopped by a signal, or until it's sequenced once
+// with --run_once. Errors are returned rather than exiting, so that the deferred
+// closing of the databases and publishers runs.
+func run(cfg *config.File, logIDs []int64) error {
 	// Enable dumping of metrics to the log at regular interval,
 	// if requested.
 	if *dumpMetricsInterval > 0 {
 		go metric.DumpToLog(context.Background(), *dumpMetricsInterval)
 	}
 
-	// First make sure we can access the database, quit if not
-	db, err := mysql.OpenDB(*mySQLURI)
-	if err != nil {
-		glog.Exitf("Failed to open MySQL database: %v", err)
-	}
-	defer db.Close()
-
-	registry := extension.Registry{
-		AdminStorage:  mysql.NewAdminStorage(db),
-		SignerFactory: keys.PEMSignerFactory{},
-		LogStorage:    mysql.NewLogStorage(db),
+	var registry extension.Registry
+	// Maintenance tasks run against the database of every tenant or shard.
+	var dbs []*sql.DB
+	switch *storageSystemFlag {
+	case "mysql":
+		var err error
+		registry, dbs, err = openMySQLStorage()
+		for _, db := range dbs {
+			defer db.Close()
+		}
+		if err != nil {
+			return err
+		}
+	case "dynamodb":
+		client, err := dynamodb.NewClient()
+		if err != nil {
+			return fmt.Errorf("failed to create DynamoDB client: %v", err)
+		}
+		opts := dynamodb.Options{TablePrefix: *dynamoDBTablePrefixFlag}
+		if *createSchema {
+			if err := dynamodb.CreateTables(context.Background(), client, opts); err != nil {
+				return fmt.Errorf("failed to create DynamoDB tables: %v", err)
+			}
+		}
+		registry = extension.Registry{
+			AdminStorage:  dynamodb.NewAdminStorage(client, opts),
+			SignerFactory: keys.ProtoSignerFactory{},
+			LogStorage:    dynamodb.NewLogStorage(client, opts),
+		}
+	case "bigtable":
+		ctx := context.Background()
+		if *createSchema {
+			if err := bigtable.CreateTable(ctx, *bigtableProjectFlag, *bigtableInstanceFlag, *bigtableTableFlag); err != nil {
+				return fmt.Errorf("failed to create Bigtable table: %v", err)
+			}
+		}
+		table, err := bigtable.OpenTable(ctx, *bigtableProjectFlag, *bigtableInstanceFlag, *bigtableTableFlag)
+		if err != nil {
+			return fmt.Errorf("failed to create Bigtable client: %v", err)
+		}
+		queue := bigtable.NewRedisQueue(bigtable.NewRedisPool(*redisAddrFlag))
+		registry = extension.Registry{
+			AdminStorage:  bigtable.NewAdminStorage(table),
+			SignerFactory: keys.ProtoSignerFactory{},
+			LogStorage:    bigtable.NewLogStorage(table, queue),
+		}
+	default:
+		return fmt.Errorf("unknown --storage_system %q, want mysql, dynamodb or bigtable", *storageSystemFlag)
 	}
 
-	// Start HTTP server (optional)
-	if *exportRPCMetrics {
+	// Start HTTP server (optional), there's nothing to scrape when running once
+	if *exportRPCMetrics && !*runOnceFlag {
 		glog.Infof("Creating HTP server starting on port: %d", *httpPortFlag)
-		if err := util.StartHTTPServer(*httpPortFlag); err != nil {
-			glog.Exitf("Failed to start http server on port %d: %v", *httpPortFlag, err)
+		http.Handle("/debug/loglevel", logging.LevelHandler())
+		if err := util.StartHTTPServerWithOptions(*httpPortFlag, util.HTTPOptions{Debug: *httpDebug}); err != nil {
+			return fmt.Errorf("failed to start http server on port %d: %v", *httpPortFlag, err)
 		}
 	}
 
@@ -78,13 +339,126 @@ func main() {
 	// TODO(Martin2112): Should respect read only mode and the flags in tree control etc
 	ctx, cancel := context.WithCancel(context.Background())
 	go util.AwaitSignal(cancel)
+	if cfg != nil {
+		go cfg.ReloadOnSIGHUP(ctx, liveFlags, nil)
+	}
+
+	if *replicationHeartbeatFlag > 0 && !*runOnceFlag && len(dbs) > 0 {
+		go mysql.WriteHeartbeats(ctx, dbs[0], *replicationHeartbeatFlag, util.SystemTimeSource{})
+	}
+	var blobs blob.Store
+	if blobFlags.Enabled() && *leafExpiryIntervalFlag > 0 && !*runOnceFlag {
+		var err error
+		if blobs, err = blobFlags.NewStore(ctx); err != nil {
+			return err
+		}
+	}
+	for _, tdb := range dbs {
+		if *leafExpiryIntervalFlag > 0 && !*runOnceFlag {
+			go mysql.PruneExpiredLeaves(ctx, tdb, blobs, *leafExpiryIntervalFlag, *leafExpiryBatchSizeFlag, util.SystemTimeSource{})
+		}
+		if *unsequencedGCIntervalFlag > 0 && !*runOnceFlag {
+			go mysql.DeleteOrphanedUnsequencedLeaves(ctx, tdb, *unsequencedGCIntervalFlag, *unsequencedGCGraceFlag, *unsequencedGCBatchSizeFlag, util.SystemTimeSource{})
+		}
+		if *storageUsageIntervalFlag > 0 && !*runOnceFlag {
+			go mysql.ReconcileStorageUsage(ctx, tdb, *storageUsageIntervalFlag)
+		}
+	}
 
 	sequencerManager := server.NewSequencerManager(registry, *sequencerGuardWindowFlag)
+	if *adaptiveBatchingFlag {
+		if *minBatchSizeFlag <= 0 || *maxBatchSizeFlag < *minBatchSizeFlag {
+			return fmt.Errorf("invalid batch size bounds [%d, %d]", *minBatchSizeFlag, *maxBatchSizeFlag)
+		}
+		sequencerManager.EnableAdaptiveBatching(*minBatchSizeFlag, *maxBatchSizeFlag, *batchLatencyTargetFlag)
+	}
+	fairness, err := server.ParseFairnessPolicy(*sequencerFairnessFlag)
+	if err != nil {
+		return fmt.Errorf("invalid --sequencer_fairness: %v", err)
+	}
+	if fairness != server.FairnessNone {
+		sequencerManager.EnableFairness(fairness, *sequencerTreeBudgetFlag)
+	}
+	if *exportRPCMetrics && !*runOnceFlag {
+		sequencerManager.EnableQueueMetrics()
+	}
+	if *selfCheckSamplesFlag > 0 {
+		sequencerManager.EnableSelfCheck(*selfCheckSamplesFlag)
+	}
+	if alertFlags.Enabled() && !*runOnceFlag {
+		if err := alertFlags.StartMonitor(ctx); err != nil {
+			return err
+		}
+	}
+	if pushFlags.Enabled() && !*runOnceFlag {
+		if err := pushFlags.StartPusher(ctx); err != nil {
+			return err
+		}
+	}
+	if urls := parseURLs(*rootWebhookURLsFlag); len(urls) > 0 {
+		publisher := webhook.New(urls, webhook.Options{
+			Retries:   *rootWebhookRetriesFlag,
+			Backoff:   *rootWebhookBackoffFlag,
+			Timeout:   *rootWebhookTimeoutFlag,
+			QueueSize: 1000,
+		})
+		// Deliver any roots still queued before exiting.
+		defer publisher.Close()
+		sequencerManager.AddRootPublisher(publisher)
+	}
+	if *eventSinkFlag != "" {
+		sink, err := newEventSink(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to create %v event sink: %v", *eventSinkFlag, err)
+		}
+		publisher := events.New(sink, events.Options{
+			RootTopic:       *eventRootTopicFlag,
+			LeafTopic:       *eventLeafTopicFlag,
+			CheckpointTopic: *eventCheckpointTopicFlag,
+			Timeout:         *eventTimeoutFlag,
+			QueueSize:       1000,
+		})
+		// Send any events still queued before exiting.
+		defer publisher.Close()
+		sequencerManager.AddRootPublisher(publisher)
+		sequencerManager.AddLeafPublisher(publisher)
+		if *eventCheckpointTopicFlag != "" {
+			if *checkpointOriginPrefixFlag == "" {
+				return errors.New("--event_checkpoint_topic requires --checkpoint_origin_prefix")
+			}
+			sequencerManager.AddCheckpointPublisher(*checkpointOriginPrefixFlag, publisher)
+		}
+	}
 	sequencerTask := server.NewLogOperationManager(ctx, registry, *batchSizeFlag, *numSeqFlag, *sequencerSleepBetweenRunsFlag, util.SystemTimeSource{}, sequencerManager)
+	if *httpSequenceFlag && *exportRPCMetrics && !*runOnceFlag {
+		if *httpSequenceTokenFile == "" {
+			return errors.New("--http_sequence requires --http_sequence_token_file")
+		}
+		token, err := ioutil.ReadFile(*httpSequenceTokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read --http_sequence_token_file: %v", err)
+		}
+		if len(bytes.TrimSpace(token)) == 0 {
+			return errors.New("--http_sequence_token_file is empty")
+		}
+		http.Handle(server.SequencePath, server.NewSequenceHandler(sequencerManager, *batchSizeFlag, string(bytes.TrimSpace(token)), util.SystemTimeSource{}))
+	}
+
+	if *runOnceFlag {
+		err := sequencerTask.OperationRunOnce(logIDs)
+		glog.Flush()
+		if err != nil {
+			return fmt.Errorf("sequencing failed: %v", err)
+		}
+		glog.Info("Sequencing complete, exiting")
+		return nil
+	}
+
 	sequencerTask.OperationLoop()
 
 	// Give things a few seconds to tidy up
 	glog.Infof("Stopping server, about to exit")
 	glog.Flush()
 	time.Sleep(time.Second * 5)
+	return nil
 }
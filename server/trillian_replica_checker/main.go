@@ -0,0 +1,94 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The trillian_replica_checker binary periodically compares a log served by two Trillian
+// log servers, typically one reading the primary database and one reading a replica of it
+// in another region. Divergence is logged as an error and exported through the
+// replica-checker expvar map on the metrics HTTP server.
+package main
+
+import (
+	"flag"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/server/replica"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+var (
+	primaryAddrFlag   = flag.String("primary_addr", "", "Address of the Trillian log server reading the primary copy of the log")
+	primaryLogIDFlag  = flag.Int64("primary_log_id", 0, "Tree ID of the log on the primary server")
+	replicaAddrFlag   = flag.String("replica_addr", "", "Address of the Trillian log server reading the replica copy of the log")
+	replicaLogIDFlag  = flag.Int64("replica_log_id", 0, "Tree ID of the log on the replica server, defaults to --primary_log_id")
+	samplesFlag       = flag.Int("samples", 10, "Number of randomly chosen leaves whose inclusion proofs are checked on both servers per pass")
+	checkIntervalFlag = flag.Duration("check_interval", time.Minute, "Time to pause between checks")
+	httpPortFlag      = flag.Int("http_port", 8094, "Port to serve HTTP metrics on")
+)
+
+func dial(addr string) *grpc.ClientConn {
+	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	if err != nil {
+		glog.Exitf("Failed to dial %v: %v", addr, err)
+	}
+	return conn
+}
+
+func main() {
+	flag.Parse()
+	glog.CopyStandardLogTo("WARNING")
+	glog.Info("**** Replica Checker Starting ****")
+
+	if *primaryAddrFlag == "" || *replicaAddrFlag == "" {
+		glog.Exitf("--primary_addr and --replica_addr must be set")
+	}
+	if *primaryLogIDFlag == 0 {
+		glog.Exitf("--primary_log_id must be set")
+	}
+	if *replicaLogIDFlag == 0 {
+		*replicaLogIDFlag = *primaryLogIDFlag
+	}
+
+	hasher, err := merkle.Factory(merkle.RFC6962SHA256Type)
+	if err != nil {
+		glog.Exitf("Failed to create hasher: %v", err)
+	}
+
+	primaryConn := dial(*primaryAddrFlag)
+	defer primaryConn.Close()
+	replicaConn := dial(*replicaAddrFlag)
+	defer replicaConn.Close()
+
+	glog.Infof("Creating HTTP server starting on port: %d", *httpPortFlag)
+	if err := util.StartHTTPServer(*httpPortFlag); err != nil {
+		glog.Exitf("Failed to start http server on port %d: %v", *httpPortFlag, err)
+	}
+
+	c := replica.New(
+		replica.Endpoint{Name: *primaryAddrFlag, Client: trillian.NewTrillianLogClient(primaryConn), LogID: *primaryLogIDFlag},
+		replica.Endpoint{Name: *replicaAddrFlag, Client: trillian.NewTrillianLogClient(replicaConn), LogID: *replicaLogIDFlag},
+		hasher, *samplesFlag)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go util.AwaitSignal(cancel)
+
+	c.Run(ctx, *checkIntervalFlag)
+
+	glog.Infof("Stopping replica checker, about to exit")
+	glog.Flush()
+}
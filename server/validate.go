@@ -57,6 +57,21 @@ func validateGetConsistencyProofRequest(req *trillian.GetConsistencyProofRequest
 	return nil
 }
 
+func validateGetConsistencyProofHistoryRequest(req *trillian.GetConsistencyProofHistoryRequest) error {
+	if len(req.TreeSizes) < 2 {
+		return grpc.Errorf(codes.InvalidArgument, "len(TreeSizes)=%v, want >= 2", len(req.TreeSizes))
+	}
+	if req.TreeSizes[0] <= 0 {
+		return grpc.Errorf(codes.InvalidArgument, "TreeSizes[0]: %v, want > 0", req.TreeSizes[0])
+	}
+	for i := 1; i < len(req.TreeSizes); i++ {
+		if req.TreeSizes[i] <= req.TreeSizes[i-1] {
+			return grpc.Errorf(codes.InvalidArgument, "TreeSizes[%d]: %v <= TreeSizes[%d]: %v, want increasing sizes", i, req.TreeSizes[i], i-1, req.TreeSizes[i-1])
+		}
+	}
+	return nil
+}
+
 func validateGetEntryAndProofRequest(req *trillian.GetEntryAndProofRequest) error {
 	if req.TreeSize <= 0 {
 		return grpc.Errorf(codes.InvalidArgument, "TreeSize: %v, want > 0", req.TreeSize)
@@ -76,3 +91,59 @@ func validateQueueLeavesRequest(req *trillian.QueueLeavesRequest) error {
 	}
 	return nil
 }
+
+func validateAddSequencedLeavesRequest(req *trillian.AddSequencedLeavesRequest) error {
+	if len(req.Leaves) == 0 {
+		return grpc.Errorf(codes.InvalidArgument, "len(leaves)=0, want > 0")
+	}
+	first := req.Leaves[0].LeafIndex
+	if first < 0 {
+		return grpc.Errorf(codes.InvalidArgument, "LeafIndex: %v, want >= 0", first)
+	}
+	for i, leaf := range req.Leaves {
+		if want := first + int64(i); leaf.LeafIndex != want {
+			return grpc.Errorf(codes.InvalidArgument, "leaves[%v].LeafIndex: %v, want %v", i, leaf.LeafIndex, want)
+		}
+	}
+	return nil
+}
+
+func validateAddWitnessSignatureRequest(req *trillian.AddWitnessSignatureRequest) error {
+	if req.TreeSize < 0 {
+		return grpc.Errorf(codes.InvalidArgument, "TreeSize: %v, want >= 0", req.TreeSize)
+	}
+	if len(req.RootHash) == 0 {
+		return grpc.Errorf(codes.InvalidArgument, "Empty RootHash: %v", req.RootHash)
+	}
+	sig := req.WitnessSignature
+	if sig == nil || sig.WitnessName == "" {
+		return grpc.Errorf(codes.InvalidArgument, "WitnessSignature must have a WitnessName")
+	}
+	if sig.Signature == nil || len(sig.Signature.Signature) == 0 {
+		return grpc.Errorf(codes.InvalidArgument, "WitnessSignature has no signature")
+	}
+	return nil
+}
+
+func validateAddObservedRootRequest(req *trillian.AddObservedRootRequest) error {
+	root := req.SignedLogRoot
+	if root == nil {
+		return grpc.Errorf(codes.InvalidArgument, "SignedLogRoot is required")
+	}
+	if root.TreeSize < 0 {
+		return grpc.Errorf(codes.InvalidArgument, "TreeSize: %v, want >= 0", root.TreeSize)
+	}
+	if root.Signature == nil || len(root.Signature.Signature) == 0 {
+		return grpc.Errorf(codes.InvalidArgument, "SignedLogRoot has no signature")
+	}
+	return nil
+}
+
+// checkLimit returns an InvalidArgument error if count is over limit. A limit of zero
+// means no limit.
+func checkLimit(what string, count, limit int) error {
+	if limit > 0 && count > limit {
+		return grpc.Errorf(codes.InvalidArgument, "%s=%v, want <= %v", what, count, limit)
+	}
+	return nil
+}
@@ -52,6 +52,27 @@ func TestGetConsistencyProofInvalidRequest(t *testing.T) {
 	}
 }
 
+func TestGetConsistencyProofHistoryInvalidRequest(t *testing.T) {
+	for _, sizes := range [][]int64{
+		nil,
+		{10},
+		{0, 10},
+		{-5, 10},
+		{10, 10},
+		{10, 20, 15},
+	} {
+		req := &trillian.GetConsistencyProofHistoryRequest{LogId: logID1, TreeSizes: sizes}
+		if err := validateGetConsistencyProofHistoryRequest(req); err == nil {
+			t.Errorf("validateGetConsistencyProofHistoryRequest(%v): nil, want error", req)
+		}
+	}
+
+	req := &trillian.GetConsistencyProofHistoryRequest{LogId: logID1, TreeSizes: []int64{1, 2, 10}}
+	if err := validateGetConsistencyProofHistoryRequest(req); err != nil {
+		t.Errorf("validateGetConsistencyProofHistoryRequest(%v): %v, want nil", req, err)
+	}
+}
+
 func TestGetEntryAndProofInvalidRequest(t *testing.T) {
 	for _, test := range []struct {
 		pReq *trillian.GetEntryAndProofRequest
@@ -15,16 +15,19 @@
 package vmap
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/golang/glog"
 	"github.com/google/trillian"
-	spb "github.com/google/trillian/crypto/sigpb"
+	"github.com/google/trillian/crypto"
 	"github.com/google/trillian/extension"
 	"github.com/google/trillian/merkle"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/util"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 )
 
 // TODO: There is no access control in the server yet and clients could easily modify
@@ -54,6 +57,34 @@ func (t *TrillianMapServer) getHasherForMap(mapID int64) (merkle.MapHasher, erro
 	return merkle.NewMapHasher(h), nil
 }
 
+// newSigner returns a signer for the roots of mapID, from the private key of its tree.
+func (t *TrillianMapServer) newSigner(ctx context.Context, mapID int64) (*crypto.Signer, error) {
+	if t.registry.SignerFactory == nil {
+		return nil, fmt.Errorf("no SignerFactory provided by registry")
+	}
+	snapshot, err := t.registry.AdminStorage.Snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer snapshot.Close()
+	tree, err := snapshot.GetTree(ctx, mapID)
+	if err != nil {
+		return nil, err
+	}
+	if err := snapshot.Commit(); err != nil {
+		return nil, err
+	}
+	if tree.PrivateKey == nil {
+		return nil, grpc.Errorf(codes.FailedPrecondition, "map %d is verification-only, it has no private key to sign roots with", mapID)
+	}
+
+	signer, err := t.registry.SignerFactory.NewSigner(ctx, tree)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.NewSigner(signer), nil
+}
+
 // GetLeaves implements the GetLeaves RPC method.
 func (t *TrillianMapServer) GetLeaves(ctx context.Context, req *trillian.GetMapLeavesRequest) (*trillian.GetMapLeavesResponse, error) {
 	ctx = util.NewMapContext(ctx, req.MapId)
@@ -88,18 +119,27 @@ func (t *TrillianMapServer) GetLeaves(ctx context.Context, req *trillian.GetMapL
 	}
 	glog.Infof("%s: wanted %d leaves, found %d", util.MapIDPrefix(ctx), len(req.Index), len(leaves))
 
-	resp := &trillian.GetMapLeavesResponse{
-		MapLeafInclusion: make([]*trillian.MapLeafInclusion, len(leaves)),
+	found := make(map[string]*trillian.MapLeaf)
+	for i := range leaves {
+		found[string(leaves[i].Index)] = &leaves[i]
 	}
-	for i, leaf := range leaves {
-		proof, err := smtReader.InclusionProof(req.Revision, leaf.Index)
+	resp := &trillian.GetMapLeavesResponse{
+		MapLeafInclusion: make([]*trillian.MapLeafInclusion, len(req.Index)),
+		MapRoot:          root,
+	}
+	for i, index := range req.Index {
+		leaf, ok := found[string(index)]
+		if !ok {
+			// Leaves which aren't in the map are returned empty, their proof is one of
+			// non-inclusion.
+			leaf = &trillian.MapLeaf{Index: index}
+		}
+		proof, err := smtReader.InclusionProof(req.Revision, index)
 		if err != nil {
 			return nil, err
 		}
-		// Copy the leaf from the iterator, which gets overwritten
-		value := leaf
 		resp.MapLeafInclusion[i] = &trillian.MapLeafInclusion{
-			Leaf:      &value,
+			Leaf:      leaf,
 			Inclusion: proof,
 		}
 	}
@@ -124,6 +164,10 @@ func (t *TrillianMapServer) SetLeaves(ctx context.Context, req *trillian.SetMapL
 	if err != nil {
 		return nil, err
 	}
+	signer, err := t.newSigner(ctx, req.MapId)
+	if err != nil {
+		return nil, err
+	}
 
 	glog.Infof("%s: Writing at revision %d", util.MapIDPrefix(ctx), tx.WriteRevision())
 
@@ -159,8 +203,9 @@ func (t *TrillianMapServer) SetLeaves(ctx context.Context, req *trillian.SetMapL
 		MapId:          req.MapId,
 		MapRevision:    tx.WriteRevision(),
 		Metadata:       req.MapperData,
-		// TODO(al): Actually sign stuff, etc!
-		Signature: &spb.DigitallySigned{},
+	}
+	if newRoot.Signature, err = signer.Sign(crypto.HashMapRoot(newRoot)); err != nil {
+		return nil, err
 	}
 
 	// TODO(al): need an smtWriter.Rollback() or similar I think.
@@ -29,6 +29,7 @@ import (
 	"github.com/google/trillian/crypto/keys"
 	"github.com/google/trillian/extension"
 	"github.com/google/trillian/server/admin"
+	"github.com/google/trillian/server/internal/serverutil"
 	"github.com/google/trillian/server/vmap"
 	"github.com/google/trillian/storage/mysql"
 	"google.golang.org/grpc"
@@ -40,6 +41,9 @@ var (
 	serverPortFlag   = flag.Int("port", 8090, "Port to serve log RPC requests on")
 	exportRPCMetrics = flag.Bool("export_metrics", true, "If true starts HTTP server and exports stats")
 	httpPortFlag     = flag.Int("http_port", 8091, "Port to serve HTTP metrics on")
+
+	createSchema = flag.Bool("create_schema", false, "If true, create any missing storage tables and apply pending schema migrations at startup")
+	mySQLFlags   = serverutil.AddMySQLFlags(flag.CommandLine)
 )
 
 func startRPCServer(registry extension.Registry) (*grpc.Server, error) {
@@ -77,15 +81,20 @@ func main() {
 	glog.Info("**** Map RPC Server Starting ****")
 
 	// First make sure we can access the database, quit if not
-	db, err := mysql.OpenDB(*mySQLURI)
+	db, err := mysql.OpenDBWithOptions(*mySQLURI, mySQLFlags.Options())
 	if err != nil {
 		glog.Exitf("Failed to open MySQL database: %v", err)
 	}
 	defer db.Close()
+	if *createSchema {
+		if err := mysql.CreateSchema(db); err != nil {
+			glog.Exitf("Failed to create MySQL schema: %v", err)
+		}
+	}
 
 	registry := extension.Registry{
 		AdminStorage:  mysql.NewAdminStorage(db),
-		SignerFactory: keys.PEMSignerFactory{},
+		SignerFactory: keys.ProtoSignerFactory{},
 		MapStorage:    mysql.NewMapStorage(db),
 	}
 
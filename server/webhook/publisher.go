@@ -0,0 +1,142 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package webhook POSTs newly signed log roots to HTTP endpoints, so monitors can be
+// pushed new roots rather than polling for them.
+package webhook
+
+import (
+	"bytes"
+	"expvar"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/google/trillian"
+)
+
+var (
+	stats = expvar.NewMap("webhook-publisher")
+	// deliveryFailures counts the roots that couldn't be delivered after all retries,
+	// keyed by URL.
+	deliveryFailures = expvar.NewMap("webhook-delivery-failures")
+)
+
+// Options configures a Publisher.
+type Options struct {
+	// Retries is the number of times delivery to a URL is retried after the first
+	// attempt fails.
+	Retries int
+	// Backoff is the time waited before the first retry, it doubles on each retry after that.
+	Backoff time.Duration
+	// Timeout bounds each delivery attempt.
+	Timeout time.Duration
+	// QueueSize is the number of roots which can be waiting for delivery, roots
+	// published while the queue is full are dropped.
+	QueueSize int
+}
+
+// Publisher delivers roots to a set of URLs, as JSON encoded SignedLogRoot messages,
+// in the order they're published. Delivery happens in the background so signing isn't
+// held up by slow endpoints.
+type Publisher struct {
+	urls   []string
+	opts   Options
+	client *http.Client
+	roots  chan trillian.SignedLogRoot
+	done   chan struct{}
+}
+
+// New returns a Publisher delivering to urls and starts its delivery goroutine, which
+// runs until Close is called.
+func New(urls []string, opts Options) *Publisher {
+	p := &Publisher{
+		urls:   urls,
+		opts:   opts,
+		client: &http.Client{Timeout: opts.Timeout},
+		roots:  make(chan trillian.SignedLogRoot, opts.QueueSize),
+		done:   make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+// PublishRoot queues root for delivery. It doesn't block, if the queue is full the root
+// is dropped.
+func (p *Publisher) PublishRoot(root trillian.SignedLogRoot) {
+	select {
+	case p.roots <- root:
+		stats.Add("queued", 1)
+	default:
+		stats.Add("dropped", 1)
+		glog.Warningf("%v: webhook queue full, dropping root at size %d", root.LogId, root.TreeSize)
+	}
+}
+
+// Close stops accepting roots and waits for the queued ones to be delivered.
+func (p *Publisher) Close() {
+	close(p.roots)
+	<-p.done
+}
+
+func (p *Publisher) run() {
+	defer close(p.done)
+	for root := range p.roots {
+		body, err := (&jsonpb.Marshaler{}).MarshalToString(&root)
+		if err != nil {
+			glog.Errorf("%v: failed to marshal root: %v", root.LogId, err)
+			continue
+		}
+		for _, url := range p.urls {
+			if err := p.deliver(url, []byte(body)); err != nil {
+				stats.Add("delivery-failures", 1)
+				deliveryFailures.Add(url, 1)
+				glog.Warningf("%v: failed to deliver root at size %d to %v: %v", root.LogId, root.TreeSize, url, err)
+				continue
+			}
+			stats.Add("delivered", 1)
+		}
+	}
+}
+
+// deliver POSTs body to url, retrying on failure.
+func (p *Publisher) deliver(url string, body []byte) error {
+	backoff := p.opts.Backoff
+	var err error
+	for attempt := 0; attempt <= p.opts.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		if err = p.post(url, body); err == nil {
+			return nil
+		}
+		glog.V(1).Infof("Attempt %d to deliver to %v failed: %v", attempt+1, url, err)
+	}
+	return err
+}
+
+func (p *Publisher) post(url string, body []byte) error {
+	rsp, err := p.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode < 200 || rsp.StatusCode > 299 {
+		return fmt.Errorf("got HTTP status %v", rsp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+)
+
+// receiver records the roots POSTed to it, failing the first failures requests.
+type receiver struct {
+	mu       sync.Mutex
+	failures int
+	requests int
+	roots    []*trillian.SignedLogRoot
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests++
+	if r.requests <= r.failures {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	root := &trillian.SignedLogRoot{}
+	if err := jsonpb.Unmarshal(req.Body, root); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.roots = append(r.roots, root)
+}
+
+func TestPublisher(t *testing.T) {
+	roots := []trillian.SignedLogRoot{
+		{LogId: 1, TreeSize: 10, TreeRevision: 3, RootHash: []byte("root10")},
+		{LogId: 1, TreeSize: 12, TreeRevision: 4, RootHash: []byte("root12")},
+	}
+
+	tests := []struct {
+		desc         string
+		failures     int
+		retries      int
+		wantRequests int
+		wantRoots    int
+	}{
+		{desc: "delivered", wantRequests: 2, wantRoots: 2},
+		{desc: "retried", failures: 2, retries: 2, wantRequests: 4, wantRoots: 2},
+		{desc: "gaveUp", failures: 2, retries: 1, wantRequests: 3, wantRoots: 1},
+	}
+
+	for _, test := range tests {
+		r := &receiver{failures: test.failures}
+		s := httptest.NewServer(r)
+
+		p := New([]string{s.URL}, Options{Retries: test.retries, QueueSize: len(roots)})
+		for _, root := range roots {
+			p.PublishRoot(root)
+		}
+		p.Close()
+		s.Close()
+
+		if got, want := r.requests, test.wantRequests; got != want {
+			t.Errorf("%v: got %d requests, want %d", test.desc, got, want)
+		}
+		if got, want := len(r.roots), test.wantRoots; got != want {
+			t.Errorf("%v: received %d roots, want %d", test.desc, got, want)
+			continue
+		}
+		// Roots that couldn't be delivered are the earliest ones.
+		for i, got := range r.roots {
+			if want := &roots[len(roots)-len(r.roots)+i]; !proto.Equal(got, want) {
+				t.Errorf("%v: received root %v, want %v", test.desc, got, want)
+			}
+		}
+	}
+}
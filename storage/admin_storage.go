@@ -54,6 +54,19 @@ type AdminTX interface {
 	AdminWriter
 }
 
+// AtomicityChecker is implemented by AdminTXs which can't always commit or roll back
+// all their writes together. Atomic reports whether the writes made so far would be.
+type AtomicityChecker interface {
+	Atomic() bool
+}
+
+// NewTreeChecker is implemented by AdminTXs which check trees for creation beyond
+// ValidateTreeForCreation. CheckNewTree returns the error CreateTree would fail with
+// before writing tree, without writing it.
+type NewTreeChecker interface {
+	CheckNewTree(ctx context.Context, tree *trillian.Tree) error
+}
+
 // AdminStorage represents the persistent storage of tree data.
 type AdminStorage interface {
 	// Snapshot starts a read-only transaction.
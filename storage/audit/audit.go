@@ -0,0 +1,230 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package audit checks the integrity of stored log trees by recomputing them
+// from their leaves and comparing the result against the stored Merkle nodes
+// and signed root.
+package audit
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+	"golang.org/x/net/context"
+)
+
+// maxTreeDepth matches the depth the log sequencer uses when storing nodes.
+const maxTreeDepth = 64
+
+// DivergenceError is returned by Log when stored data disagrees with the tree
+// recomputed from the stored leaves.
+type DivergenceError struct {
+	// Index is the first leaf index affected by the divergence.
+	Index int64
+	// Reason describes what was found to differ.
+	Reason string
+}
+
+func (d DivergenceError) Error() string {
+	return fmt.Sprintf("tree diverges at leaf %d: %s", d.Index, d.Reason)
+}
+
+// Result summarizes a successful audit.
+type Result struct {
+	TreeSize      int64
+	TreeRevision  int64
+	RootHash      []byte
+	NodesVerified int
+}
+
+// Log recomputes the Merkle tree of treeID from its sequenced leaves and checks
+// every leaf hash, every stored node and the latest signed root against it.
+// Leaves are read batchSize at a time, all within a single snapshot. If stored
+// data disagrees with the recomputed tree a DivergenceError is returned for the
+// first affected leaf index.
+func Log(ctx context.Context, ls storage.LogStorage, hasher merkle.TreeHasher, treeID int64, batchSize int) (*Result, error) {
+	tx, err := ls.SnapshotForTree(ctx, treeID)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+
+	root, err := tx.LatestSignedLogRoot()
+	if err != nil {
+		return nil, err
+	}
+	glog.Infof("Auditing tree %d at size %d, revision %d", treeID, root.TreeSize, root.TreeRevision)
+
+	a := &auditor{
+		tx:       tx,
+		revision: root.TreeRevision,
+		mt:       merkle.NewCompactMerkleTree(hasher),
+		pending:  make(map[string]node),
+	}
+	for start := int64(0); start < root.TreeSize; start += int64(batchSize) {
+		end := start + int64(batchSize)
+		if end > root.TreeSize {
+			end = root.TreeSize
+		}
+		if err := a.addLeaves(hasher, start, end); err != nil {
+			return nil, err
+		}
+		// Nodes which are complete at this size can't change any more, check them now.
+		if err := a.checkNodes(func(n node) bool { return n.isComplete(end) }); err != nil {
+			return nil, err
+		}
+		glog.V(1).Infof("Audited %d/%d leaves of tree %d", end, root.TreeSize, treeID)
+	}
+	// What's left are the nodes along the right edge of the tree, which were written
+	// for the tree at its final size.
+	if err := a.checkNodes(func(node) bool { return true }); err != nil {
+		return nil, err
+	}
+
+	if got := a.mt.CurrentRoot(); !bytes.Equal(got, root.RootHash) {
+		return nil, DivergenceError{
+			Index:  root.TreeSize - 1,
+			Reason: fmt.Sprintf("recomputed root %x does not match signed root %x", got, root.RootHash),
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &Result{
+		TreeSize:      root.TreeSize,
+		TreeRevision:  root.TreeRevision,
+		RootHash:      root.RootHash,
+		NodesVerified: a.verified,
+	}, nil
+}
+
+type auditor struct {
+	tx       storage.ReadOnlyLogTreeTX
+	revision int64
+	mt       *merkle.CompactMerkleTree
+	// pending holds recomputed nodes that haven't been compared with storage yet.
+	pending  map[string]node
+	verified int
+}
+
+// node is a recomputed Merkle node along with its tree coordinates.
+type node struct {
+	id    storage.NodeID
+	depth int
+	index int64
+	hash  []byte
+}
+
+// addLeaves reads leaves [start, end) from storage, checks their leaf hashes and
+// adds them to the recomputed tree.
+func (a *auditor) addLeaves(hasher merkle.TreeHasher, start, end int64) error {
+	indices := make([]int64, 0, end-start)
+	for i := start; i < end; i++ {
+		indices = append(indices, i)
+	}
+	leaves, err := a.tx.GetLeavesByIndex(indices)
+	if err != nil {
+		return err
+	}
+
+	var setErr error
+	for i, want := range indices {
+		if i >= len(leaves) {
+			return DivergenceError{Index: want, Reason: "leaf is missing from storage"}
+		}
+		leaf := leaves[i]
+		if leaf.LeafIndex != want {
+			return DivergenceError{Index: want, Reason: fmt.Sprintf("storage returned leaf %d instead", leaf.LeafIndex)}
+		}
+		if got := hasher.HashLeaf(leaf.LeafValue); !bytes.Equal(got, leaf.MerkleLeafHash) {
+			return DivergenceError{Index: want, Reason: fmt.Sprintf("stored Merkle leaf hash %x does not match leaf value hash %x", leaf.MerkleLeafHash, got)}
+		}
+		a.mt.AddLeafHash(leaf.MerkleLeafHash, func(depth int, index int64, hash []byte) {
+			nodeID, err := storage.NewNodeIDForTreeCoords(int64(depth), index, maxTreeDepth)
+			if err != nil {
+				setErr = err
+				return
+			}
+			a.pending[nodeID.String()] = node{id: nodeID, depth: depth, index: index, hash: hash}
+		})
+		if setErr != nil {
+			return setErr
+		}
+	}
+	return nil
+}
+
+// checkNodes compares the pending nodes selected by include with the nodes in storage.
+func (a *auditor) checkNodes(include func(node) bool) error {
+	var ids []storage.NodeID
+	for _, n := range a.pending {
+		if include(n) {
+			ids = append(ids, n.id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	stored, err := a.tx.GetMerkleNodes(a.revision, ids)
+	if err != nil {
+		return err
+	}
+	got := make(map[string][]byte)
+	for _, n := range stored {
+		got[n.NodeID.String()] = n.Hash
+	}
+
+	// Report the lowest divergent node covering the lowest leaf index.
+	var first *DivergenceError
+	var firstDepth int
+	for _, id := range ids {
+		key := id.String()
+		want := a.pending[key]
+		delete(a.pending, key)
+
+		var reason string
+		if h, ok := got[key]; !ok {
+			reason = fmt.Sprintf("node %s is missing from storage", id.CoordString())
+		} else if !bytes.Equal(h, want.hash) {
+			reason = fmt.Sprintf("stored node %s has hash %x, recomputed %x", id.CoordString(), h, want.hash)
+		} else {
+			a.verified++
+			continue
+		}
+		index := want.firstLeaf()
+		if first == nil || index < first.Index || (index == first.Index && want.depth < firstDepth) {
+			first = &DivergenceError{Index: index, Reason: reason}
+			firstDepth = want.depth
+		}
+	}
+	if first != nil {
+		return *first
+	}
+	return nil
+}
+
+// firstLeaf returns the index of the leftmost leaf under n.
+func (n node) firstLeaf() int64 {
+	return n.index << uint(n.depth)
+}
+
+// isComplete returns whether all leaves under n are present in a tree of the given size.
+func (n node) isComplete(size int64) bool {
+	return (n.index+1)<<uint(n.depth) <= size
+}
@@ -0,0 +1,186 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/testonly"
+	"golang.org/x/net/context"
+)
+
+const testTreeID = 7
+
+// fakeSnapshot serves a log built the same way the sequencer builds it. Only the
+// methods used by Log are implemented.
+type fakeSnapshot struct {
+	storage.ReadOnlyLogTreeTX
+	root   trillian.SignedLogRoot
+	leaves []*trillian.LogLeaf
+	nodes  map[string]storage.Node
+}
+
+func newFakeSnapshot(t *testing.T, size int) *fakeSnapshot {
+	f := &fakeSnapshot{nodes: make(map[string]storage.Node)}
+	mt := merkle.NewCompactMerkleTree(testonly.Hasher)
+	for i := 0; i < size; i++ {
+		value := []byte(fmt.Sprintf("leaf %d", i))
+		leaf := &trillian.LogLeaf{LeafIndex: int64(i), LeafValue: value, MerkleLeafHash: testonly.Hasher.HashLeaf(value)}
+		f.leaves = append(f.leaves, leaf)
+		mt.AddLeafHash(leaf.MerkleLeafHash, func(depth int, index int64, hash []byte) {
+			id, err := storage.NewNodeIDForTreeCoords(int64(depth), index, maxTreeDepth)
+			if err != nil {
+				t.Fatalf("NewNodeIDForTreeCoords() = %v", err)
+			}
+			f.nodes[id.String()] = storage.Node{NodeID: id, Hash: hash}
+		})
+	}
+	f.root = trillian.SignedLogRoot{TreeSize: int64(size), TreeRevision: 3, RootHash: mt.CurrentRoot()}
+	return f
+}
+
+func (f *fakeSnapshot) setNode(t *testing.T, depth, index int64, hash []byte) {
+	id, err := storage.NewNodeIDForTreeCoords(depth, index, maxTreeDepth)
+	if err != nil {
+		t.Fatalf("NewNodeIDForTreeCoords() = %v", err)
+	}
+	if hash == nil {
+		delete(f.nodes, id.String())
+		return
+	}
+	f.nodes[id.String()] = storage.Node{NodeID: id, Hash: hash}
+}
+
+func (f *fakeSnapshot) LatestSignedLogRoot() (trillian.SignedLogRoot, error) {
+	return f.root, nil
+}
+
+func (f *fakeSnapshot) GetLeavesByIndex(indices []int64) ([]*trillian.LogLeaf, error) {
+	var leaves []*trillian.LogLeaf
+	for _, i := range indices {
+		if i < int64(len(f.leaves)) && f.leaves[i] != nil {
+			leaves = append(leaves, f.leaves[i])
+		}
+	}
+	return leaves, nil
+}
+
+func (f *fakeSnapshot) GetMerkleNodes(treeRevision int64, ids []storage.NodeID) ([]storage.Node, error) {
+	if treeRevision != f.root.TreeRevision {
+		return nil, fmt.Errorf("GetMerkleNodes() at revision %d, want %d", treeRevision, f.root.TreeRevision)
+	}
+	var nodes []storage.Node
+	for _, id := range ids {
+		if n, ok := f.nodes[id.String()]; ok {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes, nil
+}
+
+func (f *fakeSnapshot) Commit() error { return nil }
+
+func (f *fakeSnapshot) Close() error { return nil }
+
+func TestLog(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tests := []struct {
+		desc      string
+		size      int
+		corrupt   func(*fakeSnapshot)
+		wantIndex int64
+		wantErr   string
+	}{
+		{desc: "empty", size: 0},
+		{desc: "ok", size: 13},
+		{desc: "perfect", size: 16},
+		{
+			desc:      "leafValue",
+			size:      13,
+			corrupt:   func(f *fakeSnapshot) { f.leaves[5].LeafValue = []byte("evil") },
+			wantIndex: 5,
+			wantErr:   "does not match leaf value hash",
+		},
+		{
+			desc:      "missingLeaf",
+			size:      13,
+			corrupt:   func(f *fakeSnapshot) { f.leaves = f.leaves[:10] },
+			wantIndex: 10,
+			wantErr:   "missing from storage",
+		},
+		{
+			desc:      "internalNode",
+			size:      13,
+			corrupt:   func(f *fakeSnapshot) { f.setNode(t, 2, 1, []byte("evil")) },
+			wantIndex: 4,
+			wantErr:   "stored node",
+		},
+		{
+			desc:      "missingNode",
+			size:      13,
+			corrupt:   func(f *fakeSnapshot) { f.setNode(t, 1, 5, nil) },
+			wantIndex: 10,
+			wantErr:   "missing from storage",
+		},
+		{
+			desc:      "root",
+			size:      13,
+			corrupt:   func(f *fakeSnapshot) { f.root.RootHash = []byte("evil") },
+			wantIndex: 12,
+			wantErr:   "does not match signed root",
+		},
+	}
+
+	for _, test := range tests {
+		f := newFakeSnapshot(t, test.size)
+		if test.corrupt != nil {
+			test.corrupt(f)
+		}
+		ls := storage.NewMockLogStorage(ctrl)
+		ls.EXPECT().SnapshotForTree(gomock.Any(), int64(testTreeID)).Return(f, nil)
+
+		result, err := Log(context.Background(), ls, testonly.Hasher, testTreeID, 4)
+		if test.wantErr != "" {
+			d, ok := err.(DivergenceError)
+			if !ok {
+				t.Errorf("%v: Log() = (_, %v), want DivergenceError", test.desc, err)
+				continue
+			}
+			if d.Index != test.wantIndex || !strings.Contains(d.Reason, test.wantErr) {
+				t.Errorf("%v: Log() = (_, %v), want divergence at %d containing %q", test.desc, err, test.wantIndex, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: Log() = (_, %v), want nil", test.desc, err)
+			continue
+		}
+		if result.TreeSize != int64(test.size) || !bytes.Equal(result.RootHash, f.root.RootHash) {
+			t.Errorf("%v: Log() = %+v, want size %d and root %x", test.desc, result, test.size, f.root.RootHash)
+		}
+		if test.size > 0 && result.NodesVerified == 0 {
+			t.Errorf("%v: Log() verified no nodes", test.desc)
+		}
+	}
+}
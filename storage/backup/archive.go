@@ -0,0 +1,194 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package backup exports log trees to a portable archive and restores them
+// into a storage backend.
+//
+// An archive is a gzip stream starting with a magic header, followed by a
+// sequence of records. Each record is a one byte record kind, the uvarint
+// encoded length of the payload and the payload, a serialized proto. An
+// archive holds exactly one trillian.Tree record, then one
+// trillian.SignedLogRoot record, then one trillian.LogLeaf record for each
+// leaf covered by the root, in index order.
+package backup
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+)
+
+const (
+	magic = "TRILLIAN BACKUP 1\n"
+
+	// maxRecordSize guards against allocating huge buffers for corrupt archives.
+	maxRecordSize = 64 << 20
+)
+
+type recordKind byte
+
+const (
+	treeRecord recordKind = iota + 1
+	rootRecord
+	leafRecord
+)
+
+func (k recordKind) String() string {
+	switch k {
+	case treeRecord:
+		return "tree"
+	case rootRecord:
+		return "root"
+	case leafRecord:
+		return "leaf"
+	default:
+		return fmt.Sprintf("unknown(%d)", byte(k))
+	}
+}
+
+// Writer writes an archive. Records must be written in the order described in the
+// package documentation, and Close must be called to flush the archive.
+type Writer struct {
+	gz  *gzip.Writer
+	buf [binary.MaxVarintLen64 + 1]byte
+}
+
+// NewWriter starts a new archive on w.
+func NewWriter(w io.Writer) (*Writer, error) {
+	gz := gzip.NewWriter(w)
+	if _, err := io.WriteString(gz, magic); err != nil {
+		return nil, err
+	}
+	return &Writer{gz: gz}, nil
+}
+
+// WriteTree writes the tree record.
+func (w *Writer) WriteTree(tree *trillian.Tree) error {
+	return w.write(treeRecord, tree)
+}
+
+// WriteRoot writes the signed root record.
+func (w *Writer) WriteRoot(root *trillian.SignedLogRoot) error {
+	return w.write(rootRecord, root)
+}
+
+// WriteLeaf writes a leaf record.
+func (w *Writer) WriteLeaf(leaf *trillian.LogLeaf) error {
+	return w.write(leafRecord, leaf)
+}
+
+// Close flushes the archive. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	return w.gz.Close()
+}
+
+func (w *Writer) write(kind recordKind, msg proto.Message) error {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	w.buf[0] = byte(kind)
+	n := binary.PutUvarint(w.buf[1:], uint64(len(data)))
+	if _, err := w.gz.Write(w.buf[:n+1]); err != nil {
+		return err
+	}
+	_, err = w.gz.Write(data)
+	return err
+}
+
+// Reader reads an archive written by Writer.
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader opens the archive in r.
+func NewReader(r io.Reader) (*Reader, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(gz)
+	header := make([]byte, len(magic))
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("failed to read archive header: %v", err)
+	}
+	if string(header) != magic {
+		return nil, fmt.Errorf("not a tree archive: bad header %q", header)
+	}
+	return &Reader{r: br}, nil
+}
+
+// ReadTree reads the tree record.
+func (r *Reader) ReadTree() (*trillian.Tree, error) {
+	tree := &trillian.Tree{}
+	if err := r.read(treeRecord, tree); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// ReadRoot reads the signed root record.
+func (r *Reader) ReadRoot() (*trillian.SignedLogRoot, error) {
+	root := &trillian.SignedLogRoot{}
+	if err := r.read(rootRecord, root); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// ReadLeaf reads the next leaf record. It returns io.EOF once all leaves have been read.
+func (r *Reader) ReadLeaf() (*trillian.LogLeaf, error) {
+	leaf := &trillian.LogLeaf{}
+	if err := r.read(leafRecord, leaf); err != nil {
+		return nil, err
+	}
+	return leaf, nil
+}
+
+func (r *Reader) read(want recordKind, msg proto.Message) error {
+	b, err := r.r.ReadByte()
+	if err != nil {
+		// A clean EOF between records is the end of the archive.
+		return err
+	}
+	if got := recordKind(b); got != want {
+		return fmt.Errorf("got %v record, want %v", got, want)
+	}
+	size, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return fmt.Errorf("failed to read %v record size: %v", want, noEOF(err))
+	}
+	if size > maxRecordSize {
+		return fmt.Errorf("%v record of %d bytes exceeds limit of %d", want, size, maxRecordSize)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return fmt.Errorf("failed to read %v record: %v", want, noEOF(err))
+	}
+	return proto.Unmarshal(data, msg)
+}
+
+// noEOF converts EOF in the middle of a record into ErrUnexpectedEOF, so it isn't
+// mistaken for the end of the archive.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,309 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/log"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+// Backup writes treeID, its latest signed root and all the leaves covered by that root
+// to w. Everything is read in a single snapshot so the archive is consistent. Leaves
+// are fetched from storage batchSize at a time.
+func Backup(ctx context.Context, registry extension.Registry, treeID int64, w io.Writer, batchSize int) error {
+	if batchSize <= 0 {
+		return fmt.Errorf("batch size %d, want > 0", batchSize)
+	}
+	tree, err := getTree(ctx, registry.AdminStorage, treeID)
+	if err != nil {
+		return err
+	}
+	if !isLog(tree) {
+		return fmt.Errorf("tree %d is a %v, only logs can be backed up", treeID, tree.TreeType)
+	}
+
+	tx, err := registry.LogStorage.SnapshotForTree(ctx, treeID)
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+
+	root, err := tx.LatestSignedLogRoot()
+	if err != nil {
+		return err
+	}
+
+	aw, err := NewWriter(w)
+	if err != nil {
+		return err
+	}
+	if err := aw.WriteTree(tree); err != nil {
+		return err
+	}
+	if err := aw.WriteRoot(&root); err != nil {
+		return err
+	}
+
+	for start := int64(0); start < root.TreeSize; start += int64(batchSize) {
+		end := start + int64(batchSize)
+		if end > root.TreeSize {
+			end = root.TreeSize
+		}
+		indices := make([]int64, 0, end-start)
+		for i := start; i < end; i++ {
+			indices = append(indices, i)
+		}
+		leaves, err := tx.GetLeavesByIndex(indices)
+		if err != nil {
+			return err
+		}
+		if got, want := len(leaves), len(indices); got != want {
+			return fmt.Errorf("got %d leaves from storage for [%d, %d), want %d", got, start, end, want)
+		}
+		for i, leaf := range leaves {
+			if want := start + int64(i); leaf.LeafIndex != want {
+				return fmt.Errorf("got leaf %d from storage, want %d", leaf.LeafIndex, want)
+			}
+			if err := aw.WriteLeaf(leaf); err != nil {
+				return err
+			}
+		}
+		glog.V(1).Infof("Backed up %d/%d leaves of tree %d", end, root.TreeSize, treeID)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return aw.Close()
+}
+
+// Restore creates a new tree from the archive in r and replays the archived leaves into
+// it through the sequencer, batchSize at a time. The restored tree gets a new tree ID
+// and, once all leaves are integrated, its root hash is checked against the archived
+// root. The restored tree is returned even if that check fails, so it can be inspected.
+func Restore(ctx context.Context, registry extension.Registry, r io.Reader, batchSize int) (*trillian.Tree, error) {
+	if batchSize <= 0 {
+		return nil, fmt.Errorf("batch size %d, want > 0", batchSize)
+	}
+	ar, err := NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	archived, err := ar.ReadTree()
+	if err != nil {
+		return nil, err
+	}
+	if !isLog(archived) {
+		return nil, fmt.Errorf("archived tree is a %v, only logs can be restored", archived.TreeType)
+	}
+	archivedRoot, err := ar.ReadRoot()
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := createTree(ctx, registry.AdminStorage, archived)
+	if err != nil {
+		return nil, err
+	}
+	glog.Infof("Restoring tree %d as tree %d", archived.TreeId, tree.TreeId)
+
+	seq, err := newSequencer(ctx, registry, tree)
+	if err != nil {
+		return tree, err
+	}
+	// SequenceBatch does nothing but sign an empty root for a log without one, so do that up front.
+	if err := seq.SignRoot(ctx, tree.TreeId); err != nil {
+		return tree, err
+	}
+
+	hasher, err := merkle.Factory(merkle.RFC6962SHA256Type)
+	if err != nil {
+		return tree, err
+	}
+
+	for next := int64(0); next < archivedRoot.TreeSize; {
+		leaves := make([]*trillian.LogLeaf, 0, batchSize)
+		for len(leaves) < batchSize && next+int64(len(leaves)) < archivedRoot.TreeSize {
+			leaf, err := ar.ReadLeaf()
+			if err == io.EOF {
+				return tree, fmt.Errorf("archive ends after %d leaves, want %d", next+int64(len(leaves)), archivedRoot.TreeSize)
+			}
+			if err != nil {
+				return tree, err
+			}
+			if want := next + int64(len(leaves)); leaf.LeafIndex != want {
+				return tree, fmt.Errorf("archive has leaf %d, want %d", leaf.LeafIndex, want)
+			}
+			if !bytes.Equal(leaf.MerkleLeafHash, hasher.HashLeaf(leaf.LeafValue)) {
+				return tree, fmt.Errorf("archived leaf %d has a Merkle leaf hash that doesn't match its value", leaf.LeafIndex)
+			}
+			leaves = append(leaves, leaf)
+		}
+
+		if err := addLeaves(ctx, registry.LogStorage, tree, leaves); err != nil {
+			return tree, err
+		}
+		count, err := seq.SequenceBatch(ctx, tree.TreeId, len(leaves))
+		if err != nil {
+			return tree, err
+		}
+		if count != len(leaves) {
+			return tree, fmt.Errorf("sequenced %d leaves at %d, want %d", count, next, len(leaves))
+		}
+		next += int64(count)
+		glog.V(1).Infof("Restored %d/%d leaves into tree %d", next, archivedRoot.TreeSize, tree.TreeId)
+	}
+
+	if _, err := ar.ReadLeaf(); err != io.EOF {
+		return tree, fmt.Errorf("archive has trailing data after %d leaves: %v", archivedRoot.TreeSize, err)
+	}
+
+	if err := verifyRoot(ctx, registry.LogStorage, tree.TreeId, archivedRoot); err != nil {
+		return tree, err
+	}
+
+	// Trees can only be created ACTIVE, so bring back the archived state (e.g. FROZEN) last.
+	if archived.TreeState != tree.TreeState {
+		tx, err := registry.AdminStorage.Begin(ctx)
+		if err != nil {
+			return tree, err
+		}
+		defer tx.Close()
+		updated, err := tx.UpdateTree(ctx, tree.TreeId, func(t *trillian.Tree) { t.TreeState = archived.TreeState })
+		if err != nil {
+			return tree, err
+		}
+		if err := tx.Commit(); err != nil {
+			return tree, err
+		}
+		return updated, nil
+	}
+	return tree, nil
+}
+
+func isLog(tree *trillian.Tree) bool {
+	return tree.TreeType == trillian.TreeType_LOG || tree.TreeType == trillian.TreeType_PREORDERED_LOG
+}
+
+func getTree(ctx context.Context, as storage.AdminStorage, treeID int64) (*trillian.Tree, error) {
+	tx, err := as.Snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	tree, err := tx.GetTree(ctx, treeID)
+	if err != nil {
+		return nil, err
+	}
+	return tree, tx.Commit()
+}
+
+func createTree(ctx context.Context, as storage.AdminStorage, archived *trillian.Tree) (*trillian.Tree, error) {
+	tree := proto.Clone(archived).(*trillian.Tree)
+	tree.TreeId = 0
+	tree.TreeState = trillian.TreeState_ACTIVE
+	tree.CreateTimeMillisSinceEpoch = 0
+	tree.UpdateTimeMillisSinceEpoch = 0
+
+	tx, err := as.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	created, err := tx.CreateTree(ctx, tree)
+	if err != nil {
+		return nil, err
+	}
+	return created, tx.Commit()
+}
+
+func newSequencer(ctx context.Context, registry extension.Registry, tree *trillian.Tree) (*log.Sequencer, error) {
+	if registry.SignerFactory == nil {
+		return nil, fmt.Errorf("no SignerFactory provided by registry")
+	}
+	signer, err := registry.SignerFactory.NewSigner(ctx, tree)
+	if err != nil {
+		return nil, err
+	}
+	hasher, err := merkle.Factory(merkle.RFC6962SHA256Type)
+	if err != nil {
+		return nil, err
+	}
+	seq := log.NewSequencer(hasher, util.SystemTimeSource{}, registry.LogStorage, crypto.NewSigner(signer))
+	seq.SetPreordered(tree.TreeType == trillian.TreeType_PREORDERED_LOG)
+	return seq, nil
+}
+
+// addLeaves makes leaves available to the sequencer. Pre-ordered trees take the leaves
+// at their archived indices. Other logs dequeue leaves in queue timestamp order, so each
+// leaf is queued with a timestamp derived from its index to preserve the archived order.
+func addLeaves(ctx context.Context, ls storage.LogStorage, tree *trillian.Tree, leaves []*trillian.LogLeaf) error {
+	tx, err := ls.BeginForTree(ctx, tree.TreeId)
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+
+	if tree.TreeType == trillian.TreeType_PREORDERED_LOG {
+		if err := tx.AddSequencedLeaves(leaves); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	for _, leaf := range leaves {
+		existing, err := tx.QueueLeaves([]*trillian.LogLeaf{leaf}, time.Unix(0, leaf.LeafIndex))
+		if err != nil {
+			return err
+		}
+		if len(existing) > 0 && existing[0] != nil {
+			return fmt.Errorf("archived leaf %d is a duplicate of leaf %d, which tree %d doesn't allow", leaf.LeafIndex, existing[0].LeafIndex, tree.TreeId)
+		}
+	}
+	return tx.Commit()
+}
+
+func verifyRoot(ctx context.Context, ls storage.LogStorage, treeID int64, want *trillian.SignedLogRoot) error {
+	tx, err := ls.SnapshotForTree(ctx, treeID)
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	root, err := tx.LatestSignedLogRoot()
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if root.TreeSize != want.TreeSize || !bytes.Equal(root.RootHash, want.RootHash) {
+		return fmt.Errorf("restored root (size %d, hash %x) does not match archived root (size %d, hash %x)", root.TreeSize, root.RootHash, want.TreeSize, want.RootHash)
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/testonly"
+	"golang.org/x/net/context"
+)
+
+func testLeaf(index int64) *trillian.LogLeaf {
+	value := []byte(fmt.Sprintf("leaf %d", index))
+	return &trillian.LogLeaf{
+		LeafIndex:        index,
+		LeafValue:        value,
+		MerkleLeafHash:   testonly.Hasher.HashLeaf(value),
+		LeafIdentityHash: testonly.Hasher.HashLeaf(value),
+	}
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	tree := &trillian.Tree{TreeId: 12, TreeType: trillian.TreeType_LOG, DisplayName: "llamas"}
+	root := &trillian.SignedLogRoot{TreeSize: 3, RootHash: []byte("root")}
+	leaves := []*trillian.LogLeaf{testLeaf(0), testLeaf(1), testLeaf(2)}
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf)
+	if err != nil {
+		t.Fatalf("NewWriter() = %v", err)
+	}
+	if err := w.WriteTree(tree); err != nil {
+		t.Fatalf("WriteTree() = %v", err)
+	}
+	if err := w.WriteRoot(root); err != nil {
+		t.Fatalf("WriteRoot() = %v", err)
+	}
+	for _, leaf := range leaves {
+		if err := w.WriteLeaf(leaf); err != nil {
+			t.Fatalf("WriteLeaf() = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("NewReader() = %v", err)
+	}
+	gotTree, err := r.ReadTree()
+	if err != nil {
+		t.Fatalf("ReadTree() = %v", err)
+	}
+	if !proto.Equal(gotTree, tree) {
+		t.Errorf("ReadTree() = %v, want %v", gotTree, tree)
+	}
+	gotRoot, err := r.ReadRoot()
+	if err != nil {
+		t.Fatalf("ReadRoot() = %v", err)
+	}
+	if !proto.Equal(gotRoot, root) {
+		t.Errorf("ReadRoot() = %v, want %v", gotRoot, root)
+	}
+	for _, want := range leaves {
+		got, err := r.ReadLeaf()
+		if err != nil {
+			t.Fatalf("ReadLeaf() = %v", err)
+		}
+		if !proto.Equal(got, want) {
+			t.Errorf("ReadLeaf() = %v, want %v", got, want)
+		}
+	}
+	if _, err := r.ReadLeaf(); err != io.EOF {
+		t.Errorf("ReadLeaf() at end = %v, want EOF", err)
+	}
+}
+
+func TestArchiveErrors(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf)
+	if err != nil {
+		t.Fatalf("NewWriter() = %v", err)
+	}
+	if err := w.WriteRoot(&trillian.SignedLogRoot{TreeSize: 1}); err != nil {
+		t.Fatalf("WriteRoot() = %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("NewReader() = %v", err)
+	}
+	if _, err := r.ReadTree(); err == nil || !strings.Contains(err.Error(), "got root record, want tree") {
+		t.Errorf("ReadTree() on a root record = %v, want record kind error", err)
+	}
+
+	if _, err := NewReader(strings.NewReader("not gzip")); err == nil {
+		t.Error("NewReader() on garbage = nil, want error")
+	}
+}
+
+func TestBackup(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	tree := &trillian.Tree{TreeId: 5, TreeType: trillian.TreeType_LOG}
+	root := trillian.SignedLogRoot{TreeSize: 5, RootHash: []byte("root")}
+
+	adminStorage := storage.NewMockAdminStorage(ctrl)
+	adminTX := storage.NewMockReadOnlyAdminTX(ctrl)
+	adminStorage.EXPECT().Snapshot(ctx).Return(adminTX, nil)
+	adminTX.EXPECT().GetTree(ctx, tree.TreeId).Return(tree, nil)
+	adminTX.EXPECT().Commit().Return(nil)
+	adminTX.EXPECT().Close().Return(nil)
+
+	logStorage := storage.NewMockLogStorage(ctrl)
+	logTX := storage.NewMockReadOnlyLogTreeTX(ctrl)
+	logStorage.EXPECT().SnapshotForTree(ctx, tree.TreeId).Return(logTX, nil)
+	logTX.EXPECT().LatestSignedLogRoot().Return(root, nil)
+	logTX.EXPECT().GetLeavesByIndex([]int64{0, 1}).Return([]*trillian.LogLeaf{testLeaf(0), testLeaf(1)}, nil)
+	logTX.EXPECT().GetLeavesByIndex([]int64{2, 3}).Return([]*trillian.LogLeaf{testLeaf(2), testLeaf(3)}, nil)
+	logTX.EXPECT().GetLeavesByIndex([]int64{4}).Return([]*trillian.LogLeaf{testLeaf(4)}, nil)
+	logTX.EXPECT().Commit().Return(nil)
+	logTX.EXPECT().Close().Return(nil)
+
+	registry := extension.Registry{AdminStorage: adminStorage, LogStorage: logStorage}
+	var buf bytes.Buffer
+	if err := Backup(ctx, registry, tree.TreeId, &buf, 2); err != nil {
+		t.Fatalf("Backup() = %v", err)
+	}
+
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatalf("NewReader() = %v", err)
+	}
+	if got, err := r.ReadTree(); err != nil || got.TreeId != tree.TreeId {
+		t.Errorf("ReadTree() = (%v, %v), want tree %d", got, err, tree.TreeId)
+	}
+	if got, err := r.ReadRoot(); err != nil || !proto.Equal(got, &root) {
+		t.Errorf("ReadRoot() = (%v, %v), want %v", got, err, root)
+	}
+	for i := int64(0); i < root.TreeSize; i++ {
+		if got, err := r.ReadLeaf(); err != nil || !proto.Equal(got, testLeaf(i)) {
+			t.Errorf("ReadLeaf() = (%v, %v), want %v", got, err, testLeaf(i))
+		}
+	}
+	if _, err := r.ReadLeaf(); err != io.EOF {
+		t.Errorf("ReadLeaf() at end = %v, want EOF", err)
+	}
+}
+
+func TestBackupMissingLeaves(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	tree := &trillian.Tree{TreeId: 5, TreeType: trillian.TreeType_LOG}
+
+	adminStorage := storage.NewMockAdminStorage(ctrl)
+	adminTX := storage.NewMockReadOnlyAdminTX(ctrl)
+	adminStorage.EXPECT().Snapshot(ctx).Return(adminTX, nil)
+	adminTX.EXPECT().GetTree(ctx, tree.TreeId).Return(tree, nil)
+	adminTX.EXPECT().Commit().Return(nil)
+	adminTX.EXPECT().Close().Return(nil)
+
+	logStorage := storage.NewMockLogStorage(ctrl)
+	logTX := storage.NewMockReadOnlyLogTreeTX(ctrl)
+	logStorage.EXPECT().SnapshotForTree(ctx, tree.TreeId).Return(logTX, nil)
+	logTX.EXPECT().LatestSignedLogRoot().Return(trillian.SignedLogRoot{TreeSize: 2}, nil)
+	logTX.EXPECT().GetLeavesByIndex([]int64{0, 1}).Return([]*trillian.LogLeaf{testLeaf(0)}, nil)
+	logTX.EXPECT().Close().Return(nil)
+
+	registry := extension.Registry{AdminStorage: adminStorage, LogStorage: logStorage}
+	var buf bytes.Buffer
+	if err := Backup(ctx, registry, tree.TreeId, &buf, 2); err == nil || !strings.Contains(err.Error(), "got 1 leaves") {
+		t.Errorf("Backup() = %v, want error about missing leaves", err)
+	}
+}
+
+func TestBackupRejectsMaps(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	tree := &trillian.Tree{TreeId: 5, TreeType: trillian.TreeType_MAP}
+
+	adminStorage := storage.NewMockAdminStorage(ctrl)
+	adminTX := storage.NewMockReadOnlyAdminTX(ctrl)
+	adminStorage.EXPECT().Snapshot(ctx).Return(adminTX, nil)
+	adminTX.EXPECT().GetTree(ctx, tree.TreeId).Return(tree, nil)
+	adminTX.EXPECT().Commit().Return(nil)
+	adminTX.EXPECT().Close().Return(nil)
+
+	registry := extension.Registry{AdminStorage: adminStorage}
+	var buf bytes.Buffer
+	if err := Backup(ctx, registry, tree.TreeId, &buf, 2); err == nil || !strings.Contains(err.Error(), "only logs") {
+		t.Errorf("Backup() = %v, want error about tree type", err)
+	}
+}
+
+func TestBatchSize(t *testing.T) {
+	ctx := context.Background()
+	for _, batchSize := range []int{0, -1} {
+		var buf bytes.Buffer
+		if err := Backup(ctx, extension.Registry{}, 5, &buf, batchSize); err == nil || !strings.Contains(err.Error(), "batch size") {
+			t.Errorf("Backup(batchSize=%d) = %v, want error about batch size", batchSize, err)
+		}
+		if _, err := Restore(ctx, extension.Registry{}, &buf, batchSize); err == nil || !strings.Contains(err.Error(), "batch size") {
+			t.Errorf("Restore(batchSize=%d) = %v, want error about batch size", batchSize, err)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigtable
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	bt "cloud.google.com/go/bigtable"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/errors"
+	"github.com/google/trillian/storage"
+)
+
+// NewAdminStorage returns a storage.AdminStorage keeping trees in table. Its
+// transactions write straight away, Commit and Rollback only end them.
+func NewAdminStorage(table *bt.Table) storage.AdminStorage {
+	return &adminStorage{table: table}
+}
+
+type adminStorage struct {
+	table *bt.Table
+}
+
+func (s *adminStorage) Snapshot(ctx context.Context) (storage.ReadOnlyAdminTX, error) {
+	return s.Begin(ctx)
+}
+
+func (s *adminStorage) Begin(ctx context.Context) (storage.AdminTX, error) {
+	return &adminTX{table: s.table}, nil
+}
+
+type adminTX struct {
+	table  *bt.Table
+	closed bool
+}
+
+func (t *adminTX) Commit() error {
+	t.closed = true
+	return nil
+}
+
+func (t *adminTX) Rollback() error {
+	t.closed = true
+	return nil
+}
+
+func (t *adminTX) IsClosed() bool {
+	return t.closed
+}
+
+func (t *adminTX) Close() error {
+	t.closed = true
+	return nil
+}
+
+// Atomic implements storage.AtomicityChecker, writes are made as they're requested so
+// are never rolled back.
+func (t *adminTX) Atomic() bool {
+	return false
+}
+
+func (t *adminTX) GetTree(ctx context.Context, treeID int64) (*trillian.Tree, error) {
+	return getTree(ctx, t.table, treeID)
+}
+
+// getTree reads the tree treeID from its treeRow.
+func getTree(ctx context.Context, table *bt.Table, treeID int64) (*trillian.Tree, error) {
+	row, err := table.ReadRow(ctx, treeRow(treeID), bt.RowFilter(bt.LatestNFilter(1)))
+	if err != nil {
+		return nil, err
+	}
+	if len(row) == 0 {
+		return nil, errors.Errorf(errors.NotFound, "tree %v not found", treeID)
+	}
+	return readTree(row)
+}
+
+func readTree(row bt.Row) (*trillian.Tree, error) {
+	item, ok := cell(row, familyTree, "tree")
+	if !ok {
+		return nil, fmt.Errorf("tree row %v has no tree", row.Key())
+	}
+	tree := &trillian.Tree{}
+	if err := proto.Unmarshal(item.Value, tree); err != nil {
+		return nil, fmt.Errorf("could not unmarshal Tree: %v", err)
+	}
+	return tree, nil
+}
+
+// listTrees reads every tree from the treeRows.
+func listTrees(ctx context.Context, table *bt.Table) ([]*trillian.Tree, error) {
+	trees := []*trillian.Tree{}
+	var readErr error
+	err := table.ReadRows(ctx, bt.PrefixRange("trees/"), func(row bt.Row) bool {
+		tree, err := readTree(row)
+		if err != nil {
+			readErr = err
+			return false
+		}
+		trees = append(trees, tree)
+		return true
+	}, bt.RowFilter(bt.LatestNFilter(1)))
+	if err != nil {
+		return nil, err
+	}
+	return trees, readErr
+}
+
+func (t *adminTX) ListTreeIDs(ctx context.Context) ([]int64, error) {
+	trees, err := listTrees(ctx, t.table)
+	if err != nil {
+		return nil, err
+	}
+	treeIDs := []int64{}
+	for _, tree := range trees {
+		treeIDs = append(treeIDs, tree.TreeId)
+	}
+	return treeIDs, nil
+}
+
+func (t *adminTX) ListTrees(ctx context.Context) ([]*trillian.Tree, error) {
+	return listTrees(ctx, t.table)
+}
+
+// CheckNewTree implements storage.NewTreeChecker.
+func (t *adminTX) CheckNewTree(ctx context.Context, tree *trillian.Tree) error {
+	if err := storage.ValidateTreeForCreation(tree); err != nil {
+		return err
+	}
+	if tree.TreeType != trillian.TreeType_LOG {
+		return errors.Errorf(errors.Unimplemented, "Bigtable storage doesn't support trees of type %v", tree.TreeType)
+	}
+
+	if tree.ShardSetId != 0 {
+		trees, err := listTrees(ctx, t.table)
+		if err != nil {
+			return err
+		}
+		for _, other := range trees {
+			if other.ShardSetId == tree.ShardSetId && other.ShardStartMillisSinceEpoch < tree.ShardEndMillisSinceEpoch && tree.ShardStartMillisSinceEpoch < other.ShardEndMillisSinceEpoch {
+				return errors.Errorf(errors.InvalidArgument, "shard window [%v, %v) overlaps another shard of set %v", tree.ShardStartMillisSinceEpoch, tree.ShardEndMillisSinceEpoch, tree.ShardSetId)
+			}
+		}
+	}
+	return nil
+}
+
+func (t *adminTX) CreateTree(ctx context.Context, tree *trillian.Tree) (*trillian.Tree, error) {
+	if err := t.CheckNewTree(ctx, tree); err != nil {
+		return nil, err
+	}
+
+	id, err := storage.NewTreeID()
+	if err != nil {
+		return nil, err
+	}
+	nowMillis := toMillisSinceEpoch(time.Now())
+
+	newTree := *tree
+	newTree.TreeId = id
+	newTree.CreateTimeMillisSinceEpoch = nowMillis
+	newTree.UpdateTimeMillisSinceEpoch = nowMillis
+
+	// The random ID is very unlikely to be taken, but make sure: the tree is only
+	// written if its row has no cells.
+	mut, err := treeMutation(&newTree)
+	if err != nil {
+		return nil, err
+	}
+	var exists bool
+	if err := t.table.Apply(ctx, treeRow(id), bt.NewCondMutation(bt.StripValueFilter(), nil, mut), bt.GetCondMutationResult(&exists)); err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, errors.Errorf(errors.AlreadyExists, "tree %v already exists", id)
+	}
+	return &newTree, nil
+}
+
+func (t *adminTX) UpdateTree(ctx context.Context, treeID int64, updateFunc func(*trillian.Tree)) (*trillian.Tree, error) {
+	tree, err := t.GetTree(ctx, treeID)
+	if err != nil {
+		return nil, err
+	}
+
+	beforeUpdate := *tree
+	updateFunc(tree)
+	// A log is only finalized while it's frozen, unfreezing or deleting it undoes that.
+	if tree.TreeState != trillian.TreeState_FROZEN {
+		tree.FinalizeTimeMillisSinceEpoch = 0
+		tree.FinalizedTreeSize = 0
+	}
+	if err := storage.ValidateTreeForUpdate(&beforeUpdate, tree); err != nil {
+		return nil, err
+	}
+
+	tree.UpdateTimeMillisSinceEpoch = toMillisSinceEpoch(time.Now())
+
+	// Without transactions, fail rather than lose a concurrent update: the tree is only
+	// written if its update time is still the one read.
+	mut, err := treeMutation(tree)
+	if err != nil {
+		return nil, err
+	}
+	unchanged := bt.ChainFilters(
+		bt.FamilyFilter(familyTree),
+		bt.ColumnFilter("update"),
+		bt.ValueFilter(regexp.QuoteMeta(strconv.FormatInt(beforeUpdate.UpdateTimeMillisSinceEpoch, 10))))
+	var matched bool
+	if err := t.table.Apply(ctx, treeRow(treeID), bt.NewCondMutation(unchanged, mut, nil), bt.GetCondMutationResult(&matched)); err != nil {
+		return nil, err
+	}
+	if !matched {
+		return nil, errors.Errorf(errors.Aborted, "tree %v was updated concurrently", treeID)
+	}
+	return tree, nil
+}
+
+// treeMutation returns the mutation writing tree to its treeRow. The cells have a fixed
+// timestamp, so each write replaces the last.
+func treeMutation(tree *trillian.Tree) (*bt.Mutation, error) {
+	data, err := proto.Marshal(tree)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal Tree: %v", err)
+	}
+	mut := bt.NewMutation()
+	mut.Set(familyTree, "tree", 0, data)
+	mut.Set(familyTree, "update", 0, []byte(strconv.FormatInt(tree.UpdateTimeMillisSinceEpoch, 10)))
+	return mut, nil
+}
+
+func toMillisSinceEpoch(t time.Time) int64 {
+	return t.UnixNano() / 1000000
+}
@@ -0,0 +1,229 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bigtable provides log and admin storage kept in a Google Cloud Bigtable
+// table, for logs with write rates beyond a single relational database.
+//
+// Everything versioned by tree revision is kept in wide rows, with the revision as
+// the cell timestamp: a row per subtree holds every revision of it, a row per block of
+// leavesPerRow sequenced leaves holds a column per leaf, and a row per tree holds its
+// roots. Reads at a revision only see the cells at or below it, so they're served from
+// single row reads without scanning history.
+//
+// The queue of leaves waiting to be sequenced is kept in a separate system, behind the
+// Queue interface, as Bigtable isn't suited to a queue: entries would be written and
+// deleted at the same end of the key space. NewRedisQueue keeps it in Redis.
+//
+// Bigtable only makes writes to a single row atomic, so the transactions of this
+// storage aren't isolated and their writes aren't atomic. A sequencing transaction
+// writes its leaves and subtrees at its write revision, which readers ignore until
+// the root at that revision is written last on Commit, with a condition that there's
+// no root at or above it yet. A sequencing pass that fails part way is redone by the
+// next one, at the same revision, which overwrites whatever it left behind. As with
+// the other storage, only one sequencer per log must run at once, as ensured by master
+// election, as concurrent sequencers can overwrite each other's leaves before one of
+// their roots is rejected.
+//
+// Queue entries are only removed once the root sequencing them is written, and before
+// the root a marker recording the revision and an ID of the sequencing pass is written
+// for each entry, so an entry whose removal failed is recognised as sequenced and
+// skipped. The root records the ID of the pass which wrote it, so entries marked by a
+// pass whose root was never written are sequenced again. For logs which don't
+// allow duplicates, the first queue entry of each leaf claims a row for the leaf
+// identity hash, and later entries of the same leaf are returned to QueueLeaves as
+// duplicates, or skipped when dequeued if the claim wasn't made in time.
+//
+// Reads are strongly consistent within a Bigtable cluster, so instances with several
+// clusters must route all requests to one cluster. Pre-ordered logs, maps, witness
+// signatures, observed roots, and leaf compression, encryption and offloading aren't
+// supported.
+package bigtable
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	bt "cloud.google.com/go/bigtable"
+	"github.com/golang/glog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// Column families of the table. All but familySequenced keep every version of a cell.
+const (
+	// familyTree holds the trillian.Tree of a tree, in treeRow.
+	familyTree = "t"
+	// familyRoot holds the signed roots of a log, and the IDs of the sequencing passes
+	// which wrote them, by revision, in headRow.
+	familyRoot = "h"
+	// familySubtree holds the revisions of a subtree, in subtreeRow.
+	familySubtree = "n"
+	// familyLeaf holds the sequenced leaves of a log by revision, in leafRow.
+	familyLeaf = "l"
+	// familyMerkle holds the indexes of the leaves with a Merkle leaf hash, in merkleRow.
+	familyMerkle = "m"
+	// familyLeafData holds the leaf claimed by each leaf identity hash of a log which
+	// doesn't allow duplicates, in leafDataRow.
+	familyLeafData = "d"
+	// familySequenced holds the markers of sequenced queue entries, in sequencedRow.
+	familySequenced = "q"
+
+	// leavesPerRow is the number of sequenced leaves kept in each leafRow.
+	leavesPerRow = 256
+
+	// sequencedMarkerMaxAge is how long the markers of sequenced queue entries are kept
+	// for, which is far longer than entries stay in a queue once they're sequenced.
+	sequencedMarkerMaxAge = 7 * 24 * time.Hour
+)
+
+var families = []string{familyTree, familyRoot, familySubtree, familyLeaf, familyMerkle, familyLeafData, familySequenced}
+
+// CreateTable creates the table used by the storage and its column families, if they
+// don't exist yet.
+func CreateTable(ctx context.Context, project, instance, table string) error {
+	admin, err := bt.NewAdminClient(ctx, project, instance)
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
+
+	if err := admin.CreateTable(ctx, table); err != nil && grpc.Code(err) != codes.AlreadyExists {
+		return fmt.Errorf("failed to create table %v: %v", table, err)
+	}
+	for _, family := range families {
+		if err := admin.CreateColumnFamily(ctx, table, family); err != nil && grpc.Code(err) != codes.AlreadyExists {
+			return fmt.Errorf("failed to create column family %v: %v", family, err)
+		}
+	}
+	if err := admin.SetGCPolicy(ctx, table, familySequenced, bt.MaxAgePolicy(sequencedMarkerMaxAge)); err != nil {
+		return fmt.Errorf("failed to set the GC policy of column family %v: %v", familySequenced, err)
+	}
+	glog.Infof("Bigtable table %v is ready", table)
+	return nil
+}
+
+// treeKey is the part of the row keys identifying a tree. It has a fixed length, so
+// the rows of one tree can't be mistaken for those of another.
+func treeKey(treeID int64) string {
+	return fmt.Sprintf("%016x", uint64(treeID))
+}
+
+func treeRow(treeID int64) string {
+	return "trees/" + treeKey(treeID)
+}
+
+func headRow(treeID int64) string {
+	return "heads/" + treeKey(treeID)
+}
+
+func subtreeRow(treeID int64, prefix []byte) string {
+	return "subtrees/" + treeKey(treeID) + "/" + hex.EncodeToString(prefix)
+}
+
+// leafRow returns the row holding the sequenced leaf at index, and its column.
+func leafRow(treeID, index int64) (string, string) {
+	return fmt.Sprintf("leaves/%s/%016x", treeKey(treeID), index/leavesPerRow), fmt.Sprintf("%02x", index%leavesPerRow)
+}
+
+// leafIndex returns the index of the leaf in column of the leafRow with key row.
+func leafIndex(row, column string) (int64, error) {
+	block, err := strconv.ParseInt(row[len(row)-16:], 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid leaf row %q: %v", row, err)
+	}
+	offset, err := strconv.ParseInt(column, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid leaf column %q: %v", column, err)
+	}
+	return block*leavesPerRow + offset, nil
+}
+
+// merkleRow returns the row holding the indexes of the leaves with merkleLeafHash, as
+// columns named by indexColumn.
+func merkleRow(treeID int64, merkleLeafHash []byte) string {
+	return "merkle/" + treeKey(treeID) + "/" + hex.EncodeToString(merkleLeafHash)
+}
+
+func indexColumn(index int64) string {
+	return fmt.Sprintf("%016x", index)
+}
+
+func leafDataRow(treeID int64, leafIdentityHash []byte) string {
+	return "leafdata/" + treeKey(treeID) + "/" + hex.EncodeToString(leafIdentityHash)
+}
+
+func sequencedRow(treeID int64, entryID string) string {
+	return "sequenced/" + treeKey(treeID) + "/" + entryID
+}
+
+// revisionTime returns the cell timestamp of the cells written at revision. Tables
+// have millisecond granularity, so timestamps must be multiples of 1000.
+func revisionTime(revision int64) bt.Timestamp {
+	return bt.Timestamp(revision * 1000)
+}
+
+// cellRevision returns the revision a cell with timestamp ts was written at.
+func cellRevision(ts bt.Timestamp) int64 {
+	return int64(ts) / 1000
+}
+
+// atRevision is a filter keeping the latest version, at or below revision, of each
+// column.
+func atRevision(revision int64) bt.Filter {
+	return bt.ChainFilters(bt.TimestampRangeFilterMicros(0, revisionTime(revision+1)), bt.LatestNFilter(1))
+}
+
+// cell returns the first cell read of column col in family of row, and whether there
+// is one. Read items name their column with the family as a prefix.
+func cell(row bt.Row, family, col string) (bt.ReadItem, bool) {
+	for _, item := range row[family] {
+		if item.Column == family+":"+col {
+			return item, true
+		}
+	}
+	return bt.ReadItem{}, false
+}
+
+// qualifier returns the column of item without its family.
+func qualifier(item bt.ReadItem) string {
+	return item.Column[strings.IndexByte(item.Column, ':')+1:]
+}
+
+// applyBulk applies muts to rows, and returns the first error of any of them.
+func applyBulk(ctx context.Context, table *bt.Table, rows []string, muts []*bt.Mutation) error {
+	errs, err := table.ApplyBulk(ctx, rows, muts)
+	if err != nil {
+		return err
+	}
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("failed to write row %v: %v", rows[i], err)
+		}
+	}
+	return nil
+}
+
+// OpenTable returns the table of a Bigtable instance, with a client that lives as long
+// as the process.
+func OpenTable(ctx context.Context, project, instance, table string) (*bt.Table, error) {
+	client, err := bt.NewClient(ctx, project, instance)
+	if err != nil {
+		return nil, err
+	}
+	return client.Open(table), nil
+}
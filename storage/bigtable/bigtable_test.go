@@ -0,0 +1,83 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigtable
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLeafRow(t *testing.T) {
+	for _, index := range []int64{0, 1, leavesPerRow - 1, leavesPerRow, 12345, 1<<62 + 7} {
+		row, col := leafRow(42, index)
+		got, err := leafIndex(row, col)
+		if err != nil {
+			t.Errorf("leafIndex(leafRow(42, %d)): %v", index, err)
+			continue
+		}
+		if got != index {
+			t.Errorf("leafIndex(leafRow(42, %d))=%d", index, got)
+		}
+	}
+
+	// Neighbouring leaves share a row, so they're read together.
+	first, _ := leafRow(42, 256)
+	last, _ := leafRow(42, 511)
+	before, _ := leafRow(42, 255)
+	if first != last {
+		t.Errorf("leafRow(42, 256)=%v, leafRow(42, 511)=%v, want the same row", first, last)
+	}
+	if before == first {
+		t.Errorf("leafRow(42, 255)=%v, want a different row to leafRow(42, 256)", before)
+	}
+}
+
+func TestTreeKey(t *testing.T) {
+	// Tree IDs are random, so negative ones must work, and no tree's rows may be a
+	// prefix of another's.
+	for _, id := range []int64{0, 1, -1, 1 << 62} {
+		if got := treeKey(id); len(got) != 16 {
+			t.Errorf("treeKey(%d)=%q, want 16 characters", id, got)
+		}
+	}
+	if a, b := subtreeRow(1, []byte{2}), subtreeRow(12, nil); strings.HasPrefix(a, b) || strings.HasPrefix(b, a) {
+		t.Errorf("subtreeRow(1, 02)=%v and subtreeRow(12, nil)=%v share a prefix", a, b)
+	}
+}
+
+func TestRevisionTime(t *testing.T) {
+	for _, rev := range []int64{0, 1, 1000, 1 << 40} {
+		ts := revisionTime(rev)
+		if ts != ts.TruncateToMilliseconds() {
+			t.Errorf("revisionTime(%d)=%v isn't a whole millisecond", rev, ts)
+		}
+		if got := cellRevision(ts); got != rev {
+			t.Errorf("cellRevision(revisionTime(%d))=%d", rev, got)
+		}
+	}
+}
+
+func TestQueueScore(t *testing.T) {
+	earlier := time.Unix(1500000000, 999)
+	later := time.Unix(1500000000, 1000)
+	if a, b := queueScore(earlier), queueScore(later); a >= b {
+		t.Errorf("queueScore(%v)=%d not below queueScore(%v)=%d", earlier, a, later, b)
+	}
+	// Scores are sent as floats, which must hold them exactly.
+	if s := queueScore(later); int64(float64(s)) != s {
+		t.Errorf("queueScore(%v)=%d isn't exact as a float64", later, s)
+	}
+}
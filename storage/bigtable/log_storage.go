@@ -0,0 +1,820 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigtable
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	bt "cloud.google.com/go/bigtable"
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/errors"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/monitoring/metric"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/cache"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+var (
+	defaultLogStrata = []int{8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8}
+
+	queuedCounter   = metric.NewCounter("bigtable_queued_leaves")
+	dequeuedCounter = metric.NewCounter("bigtable_dequeued_leaves")
+)
+
+type bigtableLogStorage struct {
+	table *bt.Table
+	queue Queue
+}
+
+// NewLogStorage returns a storage.LogStorage keeping logs in table, which CreateTable
+// creates, and their queued leaves in queue.
+func NewLogStorage(table *bt.Table, queue Queue) storage.LogStorage {
+	return &bigtableLogStorage{table: table, queue: queue}
+}
+
+func (m *bigtableLogStorage) CheckDatabaseAccessible(ctx context.Context) error {
+	_, err := m.table.ReadRow(ctx, "trees/", bt.RowFilter(bt.StripValueFilter()))
+	return err
+}
+
+// IsTransientError implements storage.TransientErrorChecker, treating unavailability
+// and exhausted quota as worth retrying.
+func (m *bigtableLogStorage) IsTransientError(err error) bool {
+	switch grpc.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+// readOnlyLogTX implements storage.ReadOnlyLogTX
+type readOnlyLogTX struct {
+	ctx context.Context
+	ls  *bigtableLogStorage
+}
+
+func (m *bigtableLogStorage) Snapshot(ctx context.Context) (storage.ReadOnlyLogTX, error) {
+	return &readOnlyLogTX{ctx: ctx, ls: m}, nil
+}
+
+func (t *readOnlyLogTX) Commit() error {
+	return nil
+}
+
+func (t *readOnlyLogTX) Rollback() error {
+	return nil
+}
+
+func (t *readOnlyLogTX) Close() error {
+	return nil
+}
+
+func (t *readOnlyLogTX) GetActiveLogIDs() ([]int64, error) {
+	return t.ls.getActiveLogIDs(t.ctx, false)
+}
+
+func (t *readOnlyLogTX) GetActiveLogIDsWithPendingWork() ([]int64, error) {
+	return t.ls.getActiveLogIDs(t.ctx, true)
+}
+
+// getActiveLogIDs returns the IDs of the logs, or if pendingWork is set, of the logs
+// with queued leaves.
+func (m *bigtableLogStorage) getActiveLogIDs(ctx context.Context, pendingWork bool) ([]int64, error) {
+	trees, err := listTrees(ctx, m.table)
+	if err != nil {
+		return nil, err
+	}
+	ids := []int64{}
+	for _, tree := range trees {
+		if tree.TreeType != trillian.TreeType_LOG {
+			continue
+		}
+		if pendingWork {
+			count, _, err := m.queue.Stats(ctx, tree.TreeId)
+			if err != nil {
+				return nil, err
+			}
+			if count == 0 {
+				continue
+			}
+		}
+		ids = append(ids, tree.TreeId)
+	}
+	return ids, nil
+}
+
+func (m *bigtableLogStorage) hasher(treeID int64) (merkle.TreeHasher, error) {
+	// TODO: read hash algorithm from storage.
+	return merkle.Factory(merkle.RFC6962SHA256Type)
+}
+
+func (m *bigtableLogStorage) beginInternal(ctx context.Context, treeID int64) (*logTreeTX, error) {
+	tree, err := getTree(ctx, m.table, treeID)
+	if errors.ErrorCode(err) == errors.NotFound {
+		return nil, storage.Error{ErrType: storage.TreeNotFound, Detail: fmt.Sprintf("tree %v not found", treeID), Cause: err}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get tree %v: %v", treeID, err)
+	}
+	if tree.TreeType != trillian.TreeType_LOG {
+		return nil, fmt.Errorf("tree %v is not a log: %v", treeID, tree.TreeType)
+	}
+
+	hasher, err := m.hasher(treeID)
+	if err != nil {
+		return nil, err
+	}
+
+	ltx := &logTreeTX{
+		ctx:           ctx,
+		ls:            m,
+		tree:          tree,
+		treeID:        treeID,
+		hashSizeBytes: hasher.Size(),
+		subtreeCache:  cache.NewSubtreeCache(defaultLogStrata, cache.PopulateLogSubtreeNodes(hasher), cache.PrepareLogSubtreeWrite()),
+	}
+	ltx.root, err = ltx.fetchLatestRoot()
+	if err != nil {
+		return nil, err
+	}
+	ltx.writeRevision = ltx.root.TreeRevision + 1
+
+	return ltx, nil
+}
+
+func (m *bigtableLogStorage) BeginForTree(ctx context.Context, treeID int64) (storage.LogTreeTX, error) {
+	return m.beginInternal(ctx, treeID)
+}
+
+func (m *bigtableLogStorage) SnapshotForTree(ctx context.Context, treeID int64) (storage.ReadOnlyLogTreeTX, error) {
+	return m.beginInternal(ctx, treeID)
+}
+
+// logTreeTX buffers the root, subtrees and dequeued entries until Commit, and writes
+// everything else straight away, see the package documentation.
+type logTreeTX struct {
+	// ctx is the context the tx was started with, used for all requests.
+	ctx           context.Context
+	ls            *bigtableLogStorage
+	tree          *trillian.Tree
+	treeID        int64
+	hashSizeBytes int
+	subtreeCache  cache.SubtreeCache
+	root          trillian.SignedLogRoot
+	writeRevision int64
+	// newRoot is the root stored by StoreSignedLogRoot, written on Commit.
+	newRoot *trillian.SignedLogRoot
+	// dequeued are the IDs of the queue entries returned by DequeueLeaves, and
+	// sequenced those of entries sequenced at earlier revisions, or which duplicate
+	// another entry. Both are removed from the queue once newRoot is written.
+	dequeued, sequenced []string
+	// rootPasses caches the pass IDs of roots read by sequencedBy, by revision.
+	rootPasses map[int64]string
+	closed     bool
+}
+
+// checkWritable returns an error unless leaves can be added to the tree, which is only
+// the case while it's ACTIVE, and never for verification-only trees, which can't be
+// sequenced.
+func (t *logTreeTX) checkWritable() error {
+	if t.tree.TreeState != trillian.TreeState_ACTIVE {
+		return storage.Error{ErrType: storage.TreeNotWritable, Detail: fmt.Sprintf("tree %v is %v, not ACTIVE", t.treeID, t.tree.TreeState)}
+	}
+	if t.tree.PrivateKey == nil {
+		return storage.Error{ErrType: storage.TreeNotWritable, Detail: fmt.Sprintf("tree %v is verification-only", t.treeID)}
+	}
+	return nil
+}
+
+func (t *logTreeTX) ReadRevision() int64 {
+	return t.root.TreeRevision
+}
+
+func (t *logTreeTX) WriteRevision() int64 {
+	return t.writeRevision
+}
+
+// QueueLeaves adds the leaves to the queue. Unless the log allows duplicates, each
+// entry then claims the leaf data row of its leaf, and the entries whose leaf was
+// already claimed are removed again, with the claimed leaves returned instead.
+func (t *logTreeTX) QueueLeaves(leaves []*trillian.LogLeaf, queueTimestamp time.Time) ([]*trillian.LogLeaf, error) {
+	if err := t.checkWritable(); err != nil {
+		return nil, err
+	}
+	if err := storage.SetLeafIdentityHashes(t.tree.LeafIdentityHashStrategy, t.tree.LeafIdentityHashKey, leaves); err != nil {
+		return nil, err
+	}
+	// Don't accept batches if any of the leaves are invalid.
+	for _, leaf := range leaves {
+		if len(leaf.LeafIdentityHash) != t.hashSizeBytes {
+			return nil, fmt.Errorf("queued leaf must have a leaf ID hash of length %d", t.hashSizeBytes)
+		}
+	}
+
+	// Queue first, so a claimed leaf is always queued. An entry whose claim isn't made
+	// below is claimed when it's dequeued.
+	entries, err := t.ls.queue.Enqueue(t.ctx, t.treeID, leaves, queueTimestamp)
+	if err != nil {
+		glog.Warningf("Error queueing leaves: %s", err)
+		return nil, err
+	}
+	queuedCounter.Add(int64(len(entries)))
+
+	existingLeaves := make([]*trillian.LogLeaf, len(leaves))
+	if t.tree.DuplicatePolicy == trillian.DuplicatePolicy_DUPLICATES_ALLOWED {
+		return existingLeaves, nil
+	}
+
+	var duplicates []string
+	for i, entry := range entries {
+		claimed, err := t.claimLeaf(entry)
+		if err != nil {
+			return nil, err
+		}
+		if claimed {
+			continue
+		}
+		duplicates = append(duplicates, entry.ID)
+		existing, err := t.readLeafData(entry.Leaf.LeafIdentityHash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve existing leaf: %v", err)
+		}
+		existingLeaves[i] = existing
+	}
+	if err := t.ls.queue.Remove(t.ctx, t.treeID, duplicates); err != nil {
+		// Duplicate entries are skipped when they're dequeued anyway.
+		glog.Warningf("Failed to remove duplicate leaves from the queue: %s", err)
+	}
+	return existingLeaves, nil
+}
+
+// claimLeaf writes the leaf data row of the leaf of entry, on condition that it has no
+// cells yet, and returns whether it did.
+func (t *logTreeTX) claimLeaf(entry QueuedLeaf) (bool, error) {
+	leafBytes, err := proto.Marshal(entry.Leaf)
+	if err != nil {
+		return false, err
+	}
+	mut := bt.NewMutation()
+	mut.Set(familyLeafData, "leaf", 0, leafBytes)
+	mut.Set(familyLeafData, "entry", 0, []byte(entry.ID))
+	var exists bool
+	row := leafDataRow(t.treeID, entry.Leaf.LeafIdentityHash)
+	if err := t.ls.table.Apply(t.ctx, row, bt.NewCondMutation(bt.StripValueFilter(), nil, mut), bt.GetCondMutationResult(&exists)); err != nil {
+		glog.Warningf("Error claiming leaf data: %s", err)
+		return false, err
+	}
+	return !exists, nil
+}
+
+// readLeafData returns the leaf claiming the leaf data row of leafIdentityHash.
+func (t *logTreeTX) readLeafData(leafIdentityHash []byte) (*trillian.LogLeaf, error) {
+	row, err := t.ls.table.ReadRow(t.ctx, leafDataRow(t.treeID, leafIdentityHash), bt.RowFilter(bt.ColumnFilter("leaf")))
+	if err != nil {
+		return nil, err
+	}
+	item, ok := cell(row, familyLeafData, "leaf")
+	if !ok {
+		return nil, fmt.Errorf("failed to find existing leaf for hash %x", leafIdentityHash)
+	}
+	var leaf trillian.LogLeaf
+	if err := proto.Unmarshal(item.Value, &leaf); err != nil {
+		return nil, err
+	}
+	return &leaf, nil
+}
+
+// AddSequencedLeaves isn't supported, as pre-ordered logs aren't.
+func (t *logTreeTX) AddSequencedLeaves(leaves []*trillian.LogLeaf) error {
+	return errors.Errorf(errors.Unimplemented, "Bigtable storage doesn't support pre-ordered logs")
+}
+
+// DequeueLeaves returns the oldest leaves queued before cutoffTime. Entries already
+// sequenced at a committed revision, or duplicating a leaf claimed by another entry,
+// are skipped and removed on Commit along with the entries returned.
+func (t *logTreeTX) DequeueLeaves(limit int, cutoffTime time.Time) ([]*trillian.LogLeaf, error) {
+	entries, err := t.ls.queue.Dequeue(t.ctx, t.treeID, limit, cutoffTime)
+	if err != nil {
+		glog.Warningf("Failed to select rows for work: %s", err)
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	sequencedAt, err := t.readSequencedMarkers(entries)
+	if err != nil {
+		return nil, err
+	}
+	var owners map[string]string
+	if t.tree.DuplicatePolicy != trillian.DuplicatePolicy_DUPLICATES_ALLOWED {
+		if owners, err = t.readLeafOwners(entries); err != nil {
+			return nil, err
+		}
+	}
+
+	leaves := make([]*trillian.LogLeaf, 0, len(entries))
+	for _, entry := range entries {
+		// Entries marked by a pass whose root was never written are still to be
+		// sequenced.
+		if marker, ok := sequencedAt[entry.ID]; ok && marker.rev <= t.root.TreeRevision {
+			sequenced, err := t.sequencedBy(marker)
+			if err != nil {
+				return nil, err
+			}
+			if sequenced {
+				t.sequenced = append(t.sequenced, entry.ID)
+				continue
+			}
+		}
+		if len(entry.Leaf.LeafIdentityHash) != t.hashSizeBytes {
+			return nil, fmt.Errorf("dequeued a leaf with incorrect hash size")
+		}
+		if owners != nil {
+			hash := string(entry.Leaf.LeafIdentityHash)
+			owner, ok := owners[hash]
+			if !ok {
+				claimed, err := t.claimLeaf(entry)
+				if err != nil {
+					return nil, err
+				}
+				if claimed {
+					owner = entry.ID
+				}
+				owners[hash] = owner
+			}
+			if owner != entry.ID {
+				t.sequenced = append(t.sequenced, entry.ID)
+				continue
+			}
+		}
+		leaves = append(leaves, entry.Leaf)
+		t.dequeued = append(t.dequeued, entry.ID)
+	}
+
+	dequeuedCounter.Add(int64(len(leaves)))
+	return leaves, nil
+}
+
+// sequencedMarker records that a queue entry was sequenced at revision rev by the
+// sequencing pass with ID pass.
+type sequencedMarker struct {
+	rev  int64
+	pass string
+}
+
+// readSequencedMarkers returns the markers of any of entries which were sequenced, by
+// entry ID.
+func (t *logTreeTX) readSequencedMarkers(entries []QueuedLeaf) (map[string]sequencedMarker, error) {
+	rows := make(bt.RowList, 0, len(entries))
+	for _, entry := range entries {
+		rows = append(rows, sequencedRow(t.treeID, entry.ID))
+	}
+	prefix := sequencedRow(t.treeID, "")
+	markers := make(map[string]sequencedMarker)
+	var readErr error
+	err := t.ls.table.ReadRows(t.ctx, rows, func(row bt.Row) bool {
+		item, ok := cell(row, familySequenced, "rev")
+		if !ok {
+			return true
+		}
+		rev, err := strconv.ParseInt(string(item.Value), 10, 64)
+		if err != nil {
+			readErr = fmt.Errorf("invalid sequenced marker %v: %v", row.Key(), err)
+			return false
+		}
+		marker := sequencedMarker{rev: rev}
+		if item, ok := cell(row, familySequenced, "pass"); ok {
+			marker.pass = string(item.Value)
+		}
+		markers[row.Key()[len(prefix):]] = marker
+		return true
+	}, bt.RowFilter(bt.LatestNFilter(1)))
+	if err != nil {
+		glog.Warningf("Failed to read sequenced markers: %s", err)
+		return nil, err
+	}
+	return markers, readErr
+}
+
+// sequencedBy returns whether the root at the revision of marker was written by the
+// sequencing pass which wrote marker. The IDs are cached for the transaction.
+func (t *logTreeTX) sequencedBy(marker sequencedMarker) (bool, error) {
+	pass, ok := t.rootPasses[marker.rev]
+	if !ok {
+		row, err := t.ls.table.ReadRow(t.ctx, headRow(t.treeID), bt.RowFilter(bt.ChainFilters(
+			bt.FamilyFilter(familyRoot),
+			bt.ColumnFilter("pass"),
+			bt.TimestampRangeFilterMicros(revisionTime(marker.rev), revisionTime(marker.rev+1)),
+		)))
+		if err != nil {
+			return false, err
+		}
+		// Roots written before passes had IDs match markers without one.
+		if item, ok := cell(row, familyRoot, "pass"); ok {
+			pass = string(item.Value)
+		}
+		if t.rootPasses == nil {
+			t.rootPasses = make(map[int64]string)
+		}
+		t.rootPasses[marker.rev] = pass
+	}
+	return pass == marker.pass, nil
+}
+
+// readLeafOwners returns the IDs of the entries which claimed the leaf data rows of
+// the leaves of entries, by leaf identity hash.
+func (t *logTreeTX) readLeafOwners(entries []QueuedLeaf) (map[string]string, error) {
+	rows := make(bt.RowList, 0, len(entries))
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		if row := leafDataRow(t.treeID, entry.Leaf.LeafIdentityHash); !seen[row] {
+			seen[row] = true
+			rows = append(rows, row)
+		}
+	}
+	owners := make(map[string]string)
+	err := t.ls.table.ReadRows(t.ctx, rows, func(row bt.Row) bool {
+		if item, ok := cell(row, familyLeafData, "entry"); ok {
+			owners[row.Key()] = string(item.Value)
+		}
+		return true
+	}, bt.RowFilter(bt.ColumnFilter("entry")))
+	if err != nil {
+		glog.Warningf("Failed to read leaf data: %s", err)
+		return nil, err
+	}
+	byHash := make(map[string]string)
+	for _, entry := range entries {
+		if owner, ok := owners[leafDataRow(t.treeID, entry.Leaf.LeafIdentityHash)]; ok {
+			byHash[string(entry.Leaf.LeafIdentityHash)] = owner
+		}
+	}
+	return byHash, nil
+}
+
+// UpdateSequencedLeaves writes the leaves at their indexes, and indexes them by Merkle
+// leaf hash, at the write revision. Readers ignore them until the root at the write
+// revision is written.
+func (t *logTreeTX) UpdateSequencedLeaves(leaves []*trillian.LogLeaf) error {
+	for _, leaf := range leaves {
+		// This should fail on insert but catch it early
+		if len(leaf.LeafIdentityHash) != t.hashSizeBytes {
+			return fmt.Errorf("sequenced leaf has incorrect hash size")
+		}
+	}
+
+	ts := revisionTime(t.writeRevision)
+	var rows []string
+	var muts []*bt.Mutation
+	byRow := make(map[string]*bt.Mutation)
+	mutation := func(row string) *bt.Mutation {
+		mut, ok := byRow[row]
+		if !ok {
+			mut = bt.NewMutation()
+			byRow[row] = mut
+			rows = append(rows, row)
+			muts = append(muts, mut)
+		}
+		return mut
+	}
+	for _, leaf := range leaves {
+		leafBytes, err := proto.Marshal(leaf)
+		if err != nil {
+			return err
+		}
+		row, col := leafRow(t.treeID, leaf.LeafIndex)
+		mutation(row).Set(familyLeaf, col, ts, leafBytes)
+		mutation(merkleRow(t.treeID, leaf.MerkleLeafHash)).Set(familyMerkle, indexColumn(leaf.LeafIndex), ts, nil)
+	}
+	if err := applyBulk(t.ctx, t.ls.table, rows, muts); err != nil {
+		glog.Warningf("Failed to update sequenced leaves: %s", err)
+		return err
+	}
+	return nil
+}
+
+// readLeaves returns the leaves at indexes as of the transaction's root.
+func (t *logTreeTX) readLeaves(indexes []int64) (map[int64]*trillian.LogLeaf, error) {
+	var rows bt.RowList
+	seen := make(map[string]bool)
+	for _, index := range indexes {
+		if row, _ := leafRow(t.treeID, index); !seen[row] {
+			seen[row] = true
+			rows = append(rows, row)
+		}
+	}
+	leaves := make(map[int64]*trillian.LogLeaf)
+	var readErr error
+	err := t.ls.table.ReadRows(t.ctx, rows, func(row bt.Row) bool {
+		for _, item := range row[familyLeaf] {
+			index, err := leafIndex(row.Key(), qualifier(item))
+			if err != nil {
+				readErr = err
+				return false
+			}
+			var leaf trillian.LogLeaf
+			if err := proto.Unmarshal(item.Value, &leaf); err != nil {
+				readErr = err
+				return false
+			}
+			leaves[index] = &leaf
+		}
+		return true
+	}, bt.RowFilter(bt.ChainFilters(bt.FamilyFilter(familyLeaf), atRevision(t.root.TreeRevision))))
+	if err != nil {
+		return nil, err
+	}
+	return leaves, readErr
+}
+
+func (t *logTreeTX) GetLeavesByIndex(leaves []int64) ([]*trillian.LogLeaf, error) {
+	for _, index := range leaves {
+		if index >= t.root.TreeSize {
+			return nil, storage.Error{ErrType: storage.OutOfRange, Detail: fmt.Sprintf("leaf index %d is beyond the tree size %d", index, t.root.TreeSize)}
+		}
+	}
+	byIndex, err := t.readLeaves(leaves)
+	if err != nil {
+		glog.Warningf("Failed to get leaves by idx: %s", err)
+		return nil, err
+	}
+
+	ret := make([]*trillian.LogLeaf, 0, len(leaves))
+	for _, index := range leaves {
+		leaf, ok := byIndex[index]
+		if !ok {
+			return nil, fmt.Errorf("leaf %d of tree %v is missing", index, t.treeID)
+		}
+		ret = append(ret, leaf)
+	}
+	return ret, nil
+}
+
+func (t *logTreeTX) GetLeavesByHash(leafHashes [][]byte, orderBySequence bool) ([]*trillian.LogLeaf, error) {
+	rows := make(bt.RowList, 0, len(leafHashes))
+	for _, hash := range leafHashes {
+		rows = append(rows, merkleRow(t.treeID, hash))
+	}
+	hashes := make(map[string]bool)
+	for _, hash := range leafHashes {
+		hashes[string(hash)] = true
+	}
+	var indexes []int64
+	var readErr error
+	err := t.ls.table.ReadRows(t.ctx, rows, func(row bt.Row) bool {
+		for _, item := range row[familyMerkle] {
+			index, err := strconv.ParseInt(qualifier(item), 16, 64)
+			if err != nil {
+				readErr = fmt.Errorf("invalid merkle index %q: %v", item.Column, err)
+				return false
+			}
+			// A leaf left beyond the tree size by a failed pass isn't in the tree.
+			if index < t.root.TreeSize {
+				indexes = append(indexes, index)
+			}
+		}
+		return true
+	}, bt.RowFilter(bt.ChainFilters(bt.FamilyFilter(familyMerkle), atRevision(t.root.TreeRevision))))
+	if err == nil {
+		err = readErr
+	}
+	if err != nil {
+		glog.Warningf("Failed to get leaves by merkle hash: %s", err)
+		return nil, err
+	}
+
+	ret := []*trillian.LogLeaf{}
+	if len(indexes) == 0 {
+		return ret, nil
+	}
+	byIndex, err := t.readLeaves(indexes)
+	if err != nil {
+		glog.Warningf("Failed to get leaves by merkle hash: %s", err)
+		return nil, err
+	}
+	for _, index := range indexes {
+		// A failed pass can also leave a leaf's index behind, which a later pass fills
+		// with another leaf.
+		if leaf, ok := byIndex[index]; ok && hashes[string(leaf.MerkleLeafHash)] {
+			ret = append(ret, leaf)
+		}
+	}
+	if orderBySequence {
+		sort.Slice(ret, func(i, j int) bool { return ret[i].LeafIndex < ret[j].LeafIndex })
+	}
+	return ret, nil
+}
+
+// GetSequencedLeafCount returns the size of the tree, as leaves are only sequenced
+// into it.
+func (t *logTreeTX) GetSequencedLeafCount() (int64, error) {
+	return t.root.TreeSize, nil
+}
+
+func (t *logTreeTX) GetUnsequencedStats() (int64, time.Time, error) {
+	count, oldest, err := t.ls.queue.Stats(t.ctx, t.treeID)
+	if err != nil {
+		glog.Warningf("Error getting unsequenced leaf stats: %s", err)
+		return 0, time.Time{}, err
+	}
+	return count, oldest, nil
+}
+
+func (t *logTreeTX) GetStorageUsage() (storage.StorageUsage, error) {
+	return storage.StorageUsage{}, errors.Errorf(errors.Unimplemented, "Bigtable storage doesn't account for storage usage")
+}
+
+func (t *logTreeTX) FinalizedTreeSize() (int64, bool, error) {
+	return t.tree.FinalizedTreeSize, t.tree.FinalizeTimeMillisSinceEpoch != 0, nil
+}
+
+func (t *logTreeTX) LatestSignedLogRoot() (trillian.SignedLogRoot, error) {
+	return t.root, nil
+}
+
+// fetchLatestRoot reads the latest SignedLogRoot, or returns a zero one if there are
+// no roots for the tree yet.
+func (t *logTreeTX) fetchLatestRoot() (trillian.SignedLogRoot, error) {
+	row, err := t.ls.table.ReadRow(t.ctx, headRow(t.treeID), bt.RowFilter(bt.LatestNFilter(1)))
+	if err != nil {
+		return trillian.SignedLogRoot{}, err
+	}
+	item, ok := cell(row, familyRoot, "root")
+	if !ok {
+		return trillian.SignedLogRoot{}, nil
+	}
+	return t.readRoot(item)
+}
+
+// SignedLogRootAtSize returns the latest SignedLogRoot of size treeSize which was
+// published by the time the transaction's root was, or a zero one if there is none.
+// The roots are versions of one cell, so they're read newest first until one matches.
+func (t *logTreeTX) SignedLogRootAtSize(treeSize int64) (trillian.SignedLogRoot, error) {
+	row, err := t.ls.table.ReadRow(t.ctx, headRow(t.treeID), bt.RowFilter(bt.ChainFilters(
+		bt.FamilyFilter(familyRoot),
+		bt.ColumnFilter("root"),
+		bt.TimestampRangeFilterMicros(0, revisionTime(t.root.TreeRevision+1)),
+	)))
+	if err != nil {
+		return trillian.SignedLogRoot{}, err
+	}
+	for _, item := range row[familyRoot] {
+		root, err := t.readRoot(item)
+		if err != nil {
+			return trillian.SignedLogRoot{}, err
+		}
+		if root.TreeSize == treeSize {
+			return root, nil
+		}
+	}
+	return trillian.SignedLogRoot{}, nil
+}
+
+// readRoot returns the SignedLogRoot held in a version of the root cell.
+func (t *logTreeTX) readRoot(item bt.ReadItem) (trillian.SignedLogRoot, error) {
+	var root trillian.SignedLogRoot
+	if err := proto.Unmarshal(item.Value, &root); err != nil {
+		glog.Warningf("Failed to unmarshal root: %v", err)
+		return trillian.SignedLogRoot{}, err
+	}
+	if rev := cellRevision(item.Timestamp); rev != root.TreeRevision {
+		return trillian.SignedLogRoot{}, fmt.Errorf("root of tree %v at revision %d stored at revision %d", t.treeID, root.TreeRevision, rev)
+	}
+	return root, nil
+}
+
+// StoreSignedLogRoot keeps root to be written on Commit.
+func (t *logTreeTX) StoreSignedLogRoot(root trillian.SignedLogRoot) error {
+	t.newRoot = &root
+	return nil
+}
+
+func (t *logTreeTX) StoreWitnessSignature(treeRevision int64, sig *trillian.WitnessSignature) error {
+	return errors.Errorf(errors.Unimplemented, "Bigtable storage doesn't support witness signatures")
+}
+
+func (t *logTreeTX) StoreObservedRoot(root trillian.SignedLogRoot, consistent bool, observedAt time.Time) error {
+	return errors.Errorf(errors.Unimplemented, "Bigtable storage doesn't support observed roots")
+}
+
+func (t *logTreeTX) LatestWitnessedSignedLogRoot(witnesses []string, quorum int) (trillian.SignedLogRoot, []*trillian.WitnessSignature, error) {
+	if len(witnesses) == 0 {
+		return trillian.SignedLogRoot{}, nil, nil
+	}
+	return trillian.SignedLogRoot{}, nil, errors.Errorf(errors.Unimplemented, "Bigtable storage doesn't support witness signatures")
+}
+
+func (t *logTreeTX) GetActiveLogIDs() ([]int64, error) {
+	return t.ls.getActiveLogIDs(t.ctx, false)
+}
+
+func (t *logTreeTX) GetActiveLogIDsWithPendingWork() ([]int64, error) {
+	return t.ls.getActiveLogIDs(t.ctx, true)
+}
+
+// Commit writes the subtrees, marks the dequeued entries as sequenced at the write
+// revision by this pass, then writes the root, if one was stored, with the pass's ID.
+// That makes the revision visible, so the dequeued entries can be removed from the
+// queue. Should that fail, the next transaction to dequeue them finds they've been
+// sequenced and removes them instead. If writing the root fails, a later pass at the
+// same revision may sequence other leaves, so markers only count if the root at their
+// revision has their pass's ID.
+func (t *logTreeTX) Commit() error {
+	t.closed = true
+	if err := t.subtreeCache.Flush(t.storeSubtrees); err != nil {
+		glog.Warningf("TX commit flush error: %v", err)
+		return err
+	}
+	if t.newRoot == nil {
+		// Entries which were skipped can go regardless, or they'd be dequeued forever
+		// by passes which find nothing else to sequence.
+		if err := t.ls.queue.Remove(t.ctx, t.treeID, t.sequenced); err != nil {
+			glog.Warningf("Failed to remove sequenced leaves from the queue: %s", err)
+		}
+		return nil
+	}
+
+	pass, err := newEntryID()
+	if err != nil {
+		return err
+	}
+	if len(t.dequeued) > 0 {
+		rev := []byte(strconv.FormatInt(t.writeRevision, 10))
+		now := bt.Now().TruncateToMilliseconds()
+		rows := make([]string, 0, len(t.dequeued))
+		muts := make([]*bt.Mutation, 0, len(t.dequeued))
+		for _, id := range t.dequeued {
+			mut := bt.NewMutation()
+			mut.Set(familySequenced, "rev", now, rev)
+			mut.Set(familySequenced, "pass", now, []byte(pass))
+			rows = append(rows, sequencedRow(t.treeID, id))
+			muts = append(muts, mut)
+		}
+		if err := applyBulk(t.ctx, t.ls.table, rows, muts); err != nil {
+			glog.Warningf("Failed to mark dequeued leaves: %s", err)
+			return err
+		}
+	}
+
+	rootBytes, err := proto.Marshal(t.newRoot)
+	if err != nil {
+		glog.Warningf("Failed to marshal root: %v %v", t.newRoot, err)
+		return err
+	}
+	mut := bt.NewMutation()
+	mut.Set(familyRoot, "root", revisionTime(t.newRoot.TreeRevision), rootBytes)
+	mut.Set(familyRoot, "pass", revisionTime(t.newRoot.TreeRevision), []byte(pass))
+	// The root is only written if there's none at or above its revision yet.
+	later := bt.ChainFilters(
+		bt.FamilyFilter(familyRoot),
+		bt.TimestampRangeFilterMicros(revisionTime(t.newRoot.TreeRevision), 0),
+		bt.StripValueFilter())
+	var exists bool
+	if err := t.ls.table.Apply(t.ctx, headRow(t.treeID), bt.NewCondMutation(later, nil, mut), bt.GetCondMutationResult(&exists)); err != nil {
+		glog.Warningf("Failed to store signed root: %s", err)
+		return err
+	}
+	if exists {
+		return errors.Errorf(errors.Aborted, "tree %v already has a root at revision %d", t.treeID, t.newRoot.TreeRevision)
+	}
+
+	if err := t.ls.queue.Remove(t.ctx, t.treeID, append(t.dequeued, t.sequenced...)); err != nil {
+		// The root is written, so the transaction succeeded regardless.
+		glog.Warningf("Failed to remove sequenced leaves from the queue: %s", err)
+	}
+	return nil
+}
+
+func (t *logTreeTX) Rollback() error {
+	t.closed = true
+	return nil
+}
+
+func (t *logTreeTX) Close() error {
+	t.closed = true
+	return nil
+}
+
+func (t *logTreeTX) IsOpen() bool {
+	return !t.closed
+}
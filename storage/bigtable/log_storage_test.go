@@ -0,0 +1,446 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigtable
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	bt "cloud.google.com/go/bigtable"
+	"cloud.google.com/go/bigtable/bttest"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	spb "github.com/google/trillian/crypto/sigpb"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/testonly"
+	"google.golang.org/api/option"
+	btpb "google.golang.org/genproto/googleapis/bigtable/v2"
+	"google.golang.org/grpc"
+)
+
+var errInjected = errors.New("injected failure")
+
+// memQueue is a Queue kept in memory, whose Remove can be made to fail.
+type memQueue struct {
+	mu         sync.Mutex
+	entries    map[int64][]QueuedLeaf
+	failRemove bool
+}
+
+func (q *memQueue) Enqueue(ctx context.Context, treeID int64, leaves []*trillian.LogLeaf, queueTime time.Time) ([]QueuedLeaf, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var entries []QueuedLeaf
+	for _, leaf := range leaves {
+		id, err := newEntryID()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, QueuedLeaf{ID: id, Leaf: leaf, QueueTime: queueTime})
+		q.entries[treeID] = append(q.entries[treeID], QueuedLeaf{ID: id, Leaf: proto.Clone(leaf).(*trillian.LogLeaf), QueueTime: queueTime})
+	}
+	return entries, nil
+}
+
+func (q *memQueue) Dequeue(ctx context.Context, treeID int64, limit int, cutoff time.Time) ([]QueuedLeaf, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var entries []QueuedLeaf
+	for _, entry := range q.entries[treeID] {
+		if len(entries) == limit || entry.QueueTime.After(cutoff) {
+			break
+		}
+		entries = append(entries, QueuedLeaf{ID: entry.ID, Leaf: proto.Clone(entry.Leaf).(*trillian.LogLeaf), QueueTime: entry.QueueTime})
+	}
+	return entries, nil
+}
+
+func (q *memQueue) Remove(ctx context.Context, treeID int64, ids []string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.failRemove {
+		return errInjected
+	}
+	removed := make(map[string]bool)
+	for _, id := range ids {
+		removed[id] = true
+	}
+	var kept []QueuedLeaf
+	for _, entry := range q.entries[treeID] {
+		if !removed[entry.ID] {
+			kept = append(kept, entry)
+		}
+	}
+	q.entries[treeID] = kept
+	return nil
+}
+
+func (q *memQueue) Stats(ctx context.Context, treeID int64) (int64, time.Time, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	entries := q.entries[treeID]
+	if len(entries) == 0 {
+		return 0, time.Time{}, nil
+	}
+	return int64(len(entries)), entries[0].QueueTime, nil
+}
+
+// testLog is a LOG tree in a table served by bttest.
+type testLog struct {
+	storage storage.LogStorage
+	queue   *memQueue
+	treeID  int64
+	// fail, if set, is called with each unary request to Bigtable, and an error it
+	// returns fails the request.
+	fail func(req interface{}) error
+}
+
+// failRow returns a function for testLog.fail failing conditional writes to the rows
+// whose keys start with prefix.
+func failRow(prefix string) func(req interface{}) error {
+	return func(req interface{}) error {
+		if req, ok := req.(*btpb.CheckAndMutateRowRequest); ok && strings.HasPrefix(string(req.RowKey), prefix) {
+			return errInjected
+		}
+		return nil
+	}
+}
+
+func newTestLog(t *testing.T) (*testLog, func()) {
+	ctx := context.Background()
+	srv, err := bttest.NewServer("localhost:0")
+	if err != nil {
+		t.Fatalf("bttest.NewServer()=%v", err)
+	}
+	l := &testLog{queue: &memQueue{entries: make(map[int64][]QueuedLeaf)}}
+	conn, err := grpc.Dial(srv.Addr, grpc.WithInsecure(), grpc.WithUnaryInterceptor(
+		func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			if l.fail != nil {
+				if err := l.fail(req); err != nil {
+					return err
+				}
+			}
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}))
+	if err != nil {
+		t.Fatalf("grpc.Dial()=%v", err)
+	}
+	cleanup := func() {
+		conn.Close()
+		srv.Close()
+	}
+
+	admin, err := bt.NewAdminClient(ctx, "project", "instance", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("NewAdminClient()=%v", err)
+	}
+	if err := admin.CreateTable(ctx, "trillian"); err != nil {
+		t.Fatalf("CreateTable()=%v", err)
+	}
+	for _, family := range families {
+		if err := admin.CreateColumnFamily(ctx, "trillian", family); err != nil {
+			t.Fatalf("CreateColumnFamily(%v)=%v", family, err)
+		}
+	}
+	client, err := bt.NewClient(ctx, "project", "instance", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("NewClient()=%v", err)
+	}
+	table := client.Open("trillian")
+
+	tx, err := NewAdminStorage(table).Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin()=%v", err)
+	}
+	defer tx.Close()
+	tree, err := tx.CreateTree(ctx, testonly.LogTree)
+	if err != nil {
+		t.Fatalf("CreateTree()=%v", err)
+	}
+	l.treeID = tree.TreeId
+	l.storage = NewLogStorage(table, l.queue)
+	return l, cleanup
+}
+
+func testLeaves(first, n int) []*trillian.LogLeaf {
+	leaves := make([]*trillian.LogLeaf, 0, n)
+	for i := first; i < first+n; i++ {
+		value := []byte(fmt.Sprintf("Leaf %d", i))
+		hash := sha256.Sum256(value)
+		leaves = append(leaves, &trillian.LogLeaf{LeafIdentityHash: hash[:], MerkleLeafHash: hash[:], LeafValue: value})
+	}
+	return leaves
+}
+
+func (l *testLog) queueLeaves(t *testing.T, leaves []*trillian.LogLeaf) ([]*trillian.LogLeaf, error) {
+	tx, err := l.storage.BeginForTree(context.Background(), l.treeID)
+	if err != nil {
+		t.Fatalf("BeginForTree()=%v", err)
+	}
+	defer tx.Close()
+	existing, err := tx.QueueLeaves(leaves, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return existing, tx.Commit()
+}
+
+// sequence runs a sequencing pass over at most limit queued leaves, as the sequencer
+// would, and returns the leaves and the error of Commit.
+func (l *testLog) sequence(t *testing.T, limit int) ([]*trillian.LogLeaf, error) {
+	tx, err := l.storage.BeginForTree(context.Background(), l.treeID)
+	if err != nil {
+		t.Fatalf("BeginForTree()=%v", err)
+	}
+	defer tx.Close()
+	root, err := tx.LatestSignedLogRoot()
+	if err != nil {
+		t.Fatalf("LatestSignedLogRoot()=%v", err)
+	}
+	leaves, err := tx.DequeueLeaves(limit, time.Now())
+	if err != nil {
+		t.Fatalf("DequeueLeaves()=%v", err)
+	}
+	for i, leaf := range leaves {
+		leaf.LeafIndex = root.TreeSize + int64(i)
+	}
+	if err := tx.UpdateSequencedLeaves(leaves); err != nil {
+		t.Fatalf("UpdateSequencedLeaves()=%v", err)
+	}
+	newRoot := trillian.SignedLogRoot{
+		LogId:          l.treeID,
+		TreeRevision:   tx.WriteRevision(),
+		TreeSize:       root.TreeSize + int64(len(leaves)),
+		TimestampNanos: time.Now().UnixNano(),
+		Signature:      &spb.DigitallySigned{},
+	}
+	if err := tx.StoreSignedLogRoot(newRoot); err != nil {
+		t.Fatalf("StoreSignedLogRoot()=%v", err)
+	}
+	return leaves, tx.Commit()
+}
+
+func (l *testLog) queued(t *testing.T) int64 {
+	count, _, err := l.queue.Stats(context.Background(), l.treeID)
+	if err != nil {
+		t.Fatalf("Stats()=%v", err)
+	}
+	return count
+}
+
+// latest returns the latest root, and the leaves in it.
+func (l *testLog) latest(t *testing.T) (trillian.SignedLogRoot, []*trillian.LogLeaf) {
+	tx, err := l.storage.SnapshotForTree(context.Background(), l.treeID)
+	if err != nil {
+		t.Fatalf("SnapshotForTree()=%v", err)
+	}
+	defer tx.Close()
+	root, err := tx.LatestSignedLogRoot()
+	if err != nil {
+		t.Fatalf("LatestSignedLogRoot()=%v", err)
+	}
+	indexes := make([]int64, 0, root.TreeSize)
+	for i := int64(0); i < root.TreeSize; i++ {
+		indexes = append(indexes, i)
+	}
+	leaves, err := tx.GetLeavesByIndex(indexes)
+	if err != nil {
+		t.Fatalf("GetLeavesByIndex()=%v", err)
+	}
+	return root, leaves
+}
+
+func leafValues(leaves []*trillian.LogLeaf) []string {
+	values := make([]string, 0, len(leaves))
+	for _, leaf := range leaves {
+		if leaf == nil {
+			values = append(values, "")
+			continue
+		}
+		values = append(values, string(leaf.LeafValue))
+	}
+	return values
+}
+
+func TestQueueLeavesDuplicates(t *testing.T) {
+	l, cleanup := newTestLog(t)
+	defer cleanup()
+
+	existing, err := l.queueLeaves(t, testLeaves(0, 3))
+	if err != nil {
+		t.Fatalf("QueueLeaves()=%v", err)
+	}
+	if got, want := fmt.Sprint(leafValues(existing)), "[  ]"; got != want {
+		t.Errorf("QueueLeaves() existing=%v, want %v", got, want)
+	}
+
+	// Duplicates are returned, whether still queued or sequenced.
+	if _, err := l.sequence(t, 1); err != nil {
+		t.Fatalf("sequence()=%v", err)
+	}
+	existing, err = l.queueLeaves(t, testLeaves(0, 4))
+	if err != nil {
+		t.Fatalf("QueueLeaves()=%v", err)
+	}
+	if got, want := fmt.Sprint(leafValues(existing)), "[Leaf 0 Leaf 1 Leaf 2 ]"; got != want {
+		t.Errorf("QueueLeaves() existing=%v, want %v", got, want)
+	}
+	if got, want := l.queued(t), int64(3); got != want {
+		t.Errorf("%d leaves queued, want %d", got, want)
+	}
+}
+
+func TestQueueLeavesRetriedAfterFailure(t *testing.T) {
+	l, cleanup := newTestLog(t)
+	defer cleanup()
+
+	// The leaf is queued, but not claimed.
+	l.fail = failRow("leafdata/")
+	if _, err := l.queueLeaves(t, testLeaves(0, 1)); err == nil {
+		t.Fatalf("QueueLeaves()=nil, want an error")
+	}
+	l.fail = nil
+
+	existing, err := l.queueLeaves(t, testLeaves(0, 1))
+	if err != nil {
+		t.Fatalf("QueueLeaves()=%v", err)
+	}
+	if existing[0] != nil {
+		t.Errorf("QueueLeaves() after a failure returned the leaf as a duplicate")
+	}
+	// Only one of the two entries is sequenced.
+	if _, err := l.sequence(t, 10); err != nil {
+		t.Fatalf("sequence()=%v", err)
+	}
+	root, leaves := l.latest(t)
+	if got, want := fmt.Sprint(leafValues(leaves)), "[Leaf 0]"; root.TreeSize != 1 || got != want {
+		t.Errorf("sequenced %v, want %v", got, want)
+	}
+	if got := l.queued(t); got != 0 {
+		t.Errorf("%d leaves left queued, want 0", got)
+	}
+}
+
+func TestSequenceInBatches(t *testing.T) {
+	l, cleanup := newTestLog(t)
+	defer cleanup()
+	if _, err := l.queueLeaves(t, testLeaves(0, 3)); err != nil {
+		t.Fatalf("QueueLeaves()=%v", err)
+	}
+
+	var sequenced []*trillian.LogLeaf
+	for _, limit := range []int{2, 2, 2} {
+		leaves, err := l.sequence(t, limit)
+		if err != nil {
+			t.Fatalf("sequence(%d)=%v", limit, err)
+		}
+		sequenced = append(sequenced, leaves...)
+	}
+	if got := l.queued(t); got != 0 {
+		t.Errorf("%d leaves left queued, want 0", got)
+	}
+
+	root, leaves := l.latest(t)
+	if root.TreeSize != 3 || root.TreeRevision != 3 {
+		t.Errorf("LatestSignedLogRoot()=size %d, revision %d, want 3, 3", root.TreeSize, root.TreeRevision)
+	}
+	if got, want := fmt.Sprint(leafValues(leaves)), fmt.Sprint(leafValues(sequenced)); got != want {
+		t.Errorf("GetLeavesByIndex()=%v, want %v", got, want)
+	}
+}
+
+func TestSequenceAfterRootFailure(t *testing.T) {
+	l, cleanup := newTestLog(t)
+	defer cleanup()
+	if _, err := l.queueLeaves(t, testLeaves(0, 4)); err != nil {
+		t.Fatalf("QueueLeaves()=%v", err)
+	}
+
+	// The first pass marks all the leaves, but fails to write its root.
+	l.fail = failRow("heads/")
+	if _, err := l.sequence(t, 4); err == nil {
+		t.Fatalf("sequence()=nil, want an error")
+	}
+	l.fail = nil
+
+	// A retry at the same revision takes fewer leaves, the others are still queued.
+	for _, limit := range []int{2, 10} {
+		if _, err := l.sequence(t, limit); err != nil {
+			t.Fatalf("sequence(%d)=%v", limit, err)
+		}
+	}
+	root, leaves := l.latest(t)
+	if root.TreeSize != 4 {
+		t.Errorf("sequenced %d leaves after a failed pass, want 4", root.TreeSize)
+	}
+	if got := l.queued(t); got != 0 {
+		t.Errorf("%d leaves left queued, want 0", got)
+	}
+
+	// The index by Merkle hash ignores what the failed pass left behind.
+	tx, err := l.storage.SnapshotForTree(context.Background(), l.treeID)
+	if err != nil {
+		t.Fatalf("SnapshotForTree()=%v", err)
+	}
+	defer tx.Close()
+	for _, leaf := range leaves {
+		got, err := tx.GetLeavesByHash([][]byte{leaf.MerkleLeafHash}, false)
+		if err != nil {
+			t.Fatalf("GetLeavesByHash()=%v", err)
+		}
+		if len(got) != 1 || got[0].LeafIndex != leaf.LeafIndex {
+			t.Errorf("GetLeavesByHash(%q)=%v, want the leaf at %d", leaf.LeafValue, got, leaf.LeafIndex)
+		}
+	}
+}
+
+func TestSequenceAfterRemoveFailure(t *testing.T) {
+	l, cleanup := newTestLog(t)
+	defer cleanup()
+	if _, err := l.queueLeaves(t, testLeaves(0, 2)); err != nil {
+		t.Fatalf("QueueLeaves()=%v", err)
+	}
+
+	// The root is written, but removing the queue entries fails.
+	l.queue.failRemove = true
+	if _, err := l.sequence(t, 10); err != nil {
+		t.Fatalf("sequence()=%v, want nil", err)
+	}
+	l.queue.failRemove = false
+	if got, want := l.queued(t), int64(2); got != want {
+		t.Fatalf("%d leaves queued, want %d", got, want)
+	}
+
+	// The next pass doesn't sequence them again, but removes them.
+	leaves, err := l.sequence(t, 10)
+	if err != nil {
+		t.Fatalf("sequence()=%v", err)
+	}
+	if len(leaves) != 0 {
+		t.Errorf("sequenced %d leaves again, want 0", len(leaves))
+	}
+	if got := l.queued(t); got != 0 {
+		t.Errorf("%d leaves left queued, want 0", got)
+	}
+	if root, _ := l.latest(t); root.TreeSize != 2 {
+		t.Errorf("tree size %d, want 2", root.TreeSize)
+	}
+}
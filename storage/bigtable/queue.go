@@ -0,0 +1,263 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigtable
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+)
+
+// QueuedLeaf is a leaf waiting in a Queue to be sequenced.
+type QueuedLeaf struct {
+	// ID identifies the queue entry, which is unique within its tree.
+	ID        string
+	Leaf      *trillian.LogLeaf
+	QueueTime time.Time
+}
+
+// Queue holds the leaves of each log waiting to be sequenced. Entries stay in the queue
+// until they're removed, so a leaf can be dequeued several times if sequencing it fails.
+type Queue interface {
+	// Enqueue adds leaves to the queue of a tree, and returns their entries in the
+	// same order.
+	Enqueue(ctx context.Context, treeID int64, leaves []*trillian.LogLeaf, queueTime time.Time) ([]QueuedLeaf, error)
+	// Dequeue returns up to limit of the oldest entries of a tree queued at or before
+	// cutoff, without removing them.
+	Dequeue(ctx context.Context, treeID int64, limit int, cutoff time.Time) ([]QueuedLeaf, error)
+	// Remove removes the entries with ids from the queue of a tree. Entries which
+	// aren't there are ignored.
+	Remove(ctx context.Context, treeID int64, ids []string) error
+	// Stats returns the number of entries queued for a tree, and the queue time of the
+	// oldest of them, which is zero if there are none.
+	Stats(ctx context.Context, treeID int64) (int64, time.Time, error)
+}
+
+// RedisQueue is a Queue kept in Redis. The entries of each tree are ordered by a sorted
+// set, scored by queue time, and their leaves are kept in a hash.
+type RedisQueue struct {
+	pool *redis.Pool
+}
+
+// NewRedisQueue returns a RedisQueue using connections from pool.
+func NewRedisQueue(pool *redis.Pool) *RedisQueue {
+	return &RedisQueue{pool: pool}
+}
+
+// NewRedisPool returns a pool of connections to the Redis server at addr. Commands
+// time out after redisTimeout, as they can't be cancelled.
+func NewRedisPool(addr string) *redis.Pool {
+	return &redis.Pool{
+		MaxIdle:     16,
+		IdleTimeout: 5 * time.Minute,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", addr,
+				redis.DialConnectTimeout(redisTimeout),
+				redis.DialReadTimeout(redisTimeout),
+				redis.DialWriteTimeout(redisTimeout))
+		},
+	}
+}
+
+// redisTimeout bounds each command of the connections made by NewRedisPool.
+const redisTimeout = 10 * time.Second
+
+// conn returns a connection from the pool, unless ctx is already done.
+func (q *RedisQueue) conn(ctx context.Context) (redis.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	conn := q.pool.Get()
+	if err := conn.Err(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// do runs a command on conn, unless ctx is done. Commands themselves can't be
+// interrupted, so ctx is checked before each round trip.
+func do(ctx context.Context, conn redis.Conn, cmd string, args ...interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return conn.Do(cmd, args...)
+}
+
+// queueKeys returns the keys of the sorted set and hash of a tree's queue. The braces
+// make a Redis cluster keep both in the same slot, as required by MULTI.
+func queueKeys(treeID int64) (string, string) {
+	tag := "trillian:{" + treeKey(treeID) + "}"
+	return tag + ":queue", tag + ":leaves"
+}
+
+// queueScore is the score of entries queued at t, in microseconds, which a float64
+// holds exactly for centuries.
+func queueScore(t time.Time) int64 {
+	return t.UnixNano() / 1000
+}
+
+func newEntryID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// Enqueue implements Queue.
+func (q *RedisQueue) Enqueue(ctx context.Context, treeID int64, leaves []*trillian.LogLeaf, queueTime time.Time) ([]QueuedLeaf, error) {
+	if len(leaves) == 0 {
+		return nil, nil
+	}
+	queue, data := queueKeys(treeID)
+	score := queueScore(queueTime)
+
+	entries := make([]QueuedLeaf, 0, len(leaves))
+	members := redis.Args{}.Add(queue)
+	fields := redis.Args{}.Add(data)
+	for _, leaf := range leaves {
+		id, err := newEntryID()
+		if err != nil {
+			return nil, err
+		}
+		leafBytes, err := proto.Marshal(leaf)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, QueuedLeaf{ID: id, Leaf: leaf, QueueTime: queueTime})
+		members = members.Add(score, id)
+		fields = fields.Add(id, leafBytes)
+	}
+
+	conn, err := q.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	// The leaves go in first, so an entry is never dequeued without its leaf.
+	conn.Send("MULTI")
+	conn.Send("HMSET", fields...)
+	conn.Send("ZADD", members...)
+	if _, err := do(ctx, conn, "EXEC"); err != nil {
+		return nil, fmt.Errorf("failed to queue leaves: %v", err)
+	}
+	return entries, nil
+}
+
+// Dequeue implements Queue.
+func (q *RedisQueue) Dequeue(ctx context.Context, treeID int64, limit int, cutoff time.Time) ([]QueuedLeaf, error) {
+	queue, data := queueKeys(treeID)
+	conn, err := q.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	scored, err := redis.Strings(do(ctx, conn, "ZRANGEBYSCORE", queue, "-inf", queueScore(cutoff), "WITHSCORES", "LIMIT", 0, limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queue: %v", err)
+	}
+	if len(scored) == 0 {
+		return nil, nil
+	}
+	ids := make([]string, 0, len(scored)/2)
+	scores := make([]int64, 0, len(scored)/2)
+	for i := 0; i+1 < len(scored); i += 2 {
+		score, err := strconv.ParseFloat(scored[i+1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid score %q of queue entry %v: %v", scored[i+1], scored[i], err)
+		}
+		ids = append(ids, scored[i])
+		scores = append(scores, int64(score))
+	}
+
+	values, err := redis.ByteSlices(do(ctx, conn, "HMGET", redis.Args{}.Add(data).AddFlat(ids)...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queued leaves: %v", err)
+	}
+	entries := make([]QueuedLeaf, 0, len(ids))
+	for i, id := range ids {
+		// The entry was removed since it was read.
+		if values[i] == nil {
+			continue
+		}
+		var leaf trillian.LogLeaf
+		if err := proto.Unmarshal(values[i], &leaf); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal queued leaf %v: %v", id, err)
+		}
+		entries = append(entries, QueuedLeaf{ID: id, Leaf: &leaf, QueueTime: time.Unix(0, scores[i]*1000)})
+	}
+	return entries, nil
+}
+
+// Remove implements Queue.
+func (q *RedisQueue) Remove(ctx context.Context, treeID int64, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	queue, data := queueKeys(treeID)
+	conn, err := q.conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	conn.Send("MULTI")
+	conn.Send("ZREM", redis.Args{}.Add(queue).AddFlat(ids)...)
+	conn.Send("HDEL", redis.Args{}.Add(data).AddFlat(ids)...)
+	if _, err := do(ctx, conn, "EXEC"); err != nil {
+		return fmt.Errorf("failed to remove queue entries: %v", err)
+	}
+	return nil
+}
+
+// Stats implements Queue.
+func (q *RedisQueue) Stats(ctx context.Context, treeID int64) (int64, time.Time, error) {
+	queue, _ := queueKeys(treeID)
+	conn, err := q.conn(ctx)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	defer conn.Close()
+
+	count, err := redis.Int64(do(ctx, conn, "ZCARD", queue))
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	if count == 0 {
+		return 0, time.Time{}, nil
+	}
+	oldest, err := redis.Strings(do(ctx, conn, "ZRANGE", queue, 0, 0, "WITHSCORES"))
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	if len(oldest) < 2 {
+		// The queue was emptied since it was counted.
+		return count, time.Time{}, nil
+	}
+	score, err := strconv.ParseFloat(oldest[1], 64)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("invalid score %q of queue entry %v: %v", oldest[1], oldest[0], err)
+	}
+	return count, time.Unix(0, int64(score)*1000), nil
+}
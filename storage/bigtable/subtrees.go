@@ -0,0 +1,134 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigtable
+
+import (
+	"fmt"
+
+	bt "cloud.google.com/go/bigtable"
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/storagepb"
+)
+
+func (t *logTreeTX) getSubtree(treeRevision int64, nodeID storage.NodeID) (*storagepb.SubtreeProto, error) {
+	s, err := t.getSubtrees(treeRevision, []storage.NodeID{nodeID})
+	if err != nil {
+		return nil, err
+	}
+	switch len(s) {
+	case 0:
+		return nil, nil
+	case 1:
+		return s[0], nil
+	default:
+		return nil, fmt.Errorf("got %d subtrees, but expected 1", len(s))
+	}
+}
+
+// getSubtrees returns the latest revision, at or below treeRevision, of each of the
+// subtrees with nodeIDs that exists, read with a single request.
+func (t *logTreeTX) getSubtrees(treeRevision int64, nodeIDs []storage.NodeID) ([]*storagepb.SubtreeProto, error) {
+	rows := make(bt.RowList, 0, len(nodeIDs))
+	for _, nodeID := range nodeIDs {
+		if nodeID.PrefixLenBits%8 != 0 {
+			return nil, fmt.Errorf("invalid subtree ID - not multiple of 8: %d", nodeID.PrefixLenBits)
+		}
+		rows = append(rows, subtreeRow(t.treeID, nodeID.Path[:nodeID.PrefixLenBits/8]))
+	}
+
+	ret := make([]*storagepb.SubtreeProto, 0, len(nodeIDs))
+	var readErr error
+	err := t.ls.table.ReadRows(t.ctx, rows, func(row bt.Row) bool {
+		item, ok := cell(row, familySubtree, "subtree")
+		if !ok {
+			return true
+		}
+		var subtree storagepb.SubtreeProto
+		if err := proto.Unmarshal(item.Value, &subtree); err != nil {
+			readErr = err
+			return false
+		}
+		if subtree.Prefix == nil {
+			subtree.Prefix = []byte{}
+		}
+		ret = append(ret, &subtree)
+		return true
+	}, bt.RowFilter(bt.ChainFilters(bt.FamilyFilter(familySubtree), atRevision(treeRevision))))
+	if err != nil {
+		glog.Warningf("Failed to get merkle subtrees: %s", err)
+		return nil, err
+	}
+	if readErr != nil {
+		glog.Warningf("Failed to unmarshal SubtreeProto: %s", readErr)
+		return nil, readErr
+	}
+
+	// The InternalNodes cache is possibly nil here, but the SubtreeCache (which called
+	// this method) will re-populate it.
+	return ret, nil
+}
+
+// storeSubtrees writes subtrees at the transaction's write revision. A subtree left by
+// a failed pass at the same revision is overwritten.
+func (t *logTreeTX) storeSubtrees(subtrees []*storagepb.SubtreeProto) error {
+	if len(subtrees) == 0 {
+		glog.Warning("attempted to store 0 subtrees...")
+		return nil
+	}
+
+	rows := make([]string, 0, len(subtrees))
+	muts := make([]*bt.Mutation, 0, len(subtrees))
+	for _, s := range subtrees {
+		if s.Prefix == nil {
+			panic(fmt.Errorf("nil prefix on %v", s))
+		}
+		subtreeBytes, err := proto.Marshal(s)
+		if err != nil {
+			return err
+		}
+		mut := bt.NewMutation()
+		mut.Set(familySubtree, "subtree", revisionTime(t.writeRevision), subtreeBytes)
+		rows = append(rows, subtreeRow(t.treeID, s.Prefix))
+		muts = append(muts, mut)
+	}
+
+	if err := applyBulk(t.ctx, t.ls.table, rows, muts); err != nil {
+		glog.Warningf("Failed to set merkle subtrees: %s", err)
+		return err
+	}
+	return nil
+}
+
+// GetMerkleNodes returns the requests nodes at (or below) the passed in treeRevision.
+func (t *logTreeTX) GetMerkleNodes(treeRevision int64, nodeIDs []storage.NodeID) ([]storage.Node, error) {
+	return t.subtreeCache.GetNodes(nodeIDs, func(ids []storage.NodeID) ([]*storagepb.SubtreeProto, error) {
+		return t.getSubtrees(treeRevision, ids)
+	})
+}
+
+func (t *logTreeTX) SetMerkleNodes(nodes []storage.Node) error {
+	for _, n := range nodes {
+		err := t.subtreeCache.SetNodeHash(n.NodeID, n.Hash,
+			func(nID storage.NodeID) (*storagepb.SubtreeProto, error) {
+				return t.getSubtree(t.writeRevision, nID)
+			})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package blob defines a store for leaf values too large to keep in the database.
+// Implementations live in the file, gcs and s3 subpackages.
+package blob
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotFound is returned by Store.Get when there's no blob with the requested key.
+var ErrNotFound = errors.New("blob not found")
+
+// Store keeps blobs under keys chosen by the caller. Keys are slash separated paths,
+// e.g. "123/abcdef". Blobs are immutable once written, so stores don't need to
+// provide any stronger consistency than read-after-write for new keys.
+type Store interface {
+	// Put stores data under key, replacing any blob already stored there.
+	Put(ctx context.Context, key string, data []byte) error
+	// Get returns the blob stored under key, or ErrNotFound.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Delete deletes the blob stored under key, if there is one.
+	Delete(ctx context.Context, key string) error
+}
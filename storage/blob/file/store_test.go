@@ -0,0 +1,74 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/google/trillian/storage/blob"
+)
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blobs")
+	if err != nil {
+		t.Fatalf("TempDir()=%v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore()=%v", err)
+	}
+	ctx := context.Background()
+
+	if _, err := s.Get(ctx, "1/abc"); err != blob.ErrNotFound {
+		t.Errorf("Get(missing)=(_, %v), want %v", err, blob.ErrNotFound)
+	}
+	for _, data := range [][]byte{[]byte("first"), []byte("second")} {
+		if err := s.Put(ctx, "1/abc", data); err != nil {
+			t.Fatalf("Put()=%v", err)
+		}
+		got, err := s.Get(ctx, "1/abc")
+		if err != nil {
+			t.Fatalf("Get()=%v", err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("Get()=%q, want %q", got, data)
+		}
+	}
+
+	// Deleting a blob twice succeeds.
+	for i := 0; i < 2; i++ {
+		if err := s.Delete(ctx, "1/abc"); err != nil {
+			t.Fatalf("Delete()=%v", err)
+		}
+	}
+	if _, err := s.Get(ctx, "1/abc"); err != blob.ErrNotFound {
+		t.Errorf("Get(deleted)=(_, %v), want %v", err, blob.ErrNotFound)
+	}
+
+	for _, key := range []string{"../escape", "1/../../escape"} {
+		if err := s.Put(ctx, key, []byte("data")); err == nil {
+			t.Errorf("Put(%q)=nil, want error", key)
+		}
+	}
+	if _, err := NewStore(dir + "/missing"); err == nil {
+		t.Error("NewStore(missing dir)=nil, want error")
+	}
+}
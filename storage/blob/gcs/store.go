@@ -0,0 +1,72 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gcs provides a blob.Store which keeps blobs in a Google Cloud Storage bucket.
+package gcs
+
+import (
+	"context"
+	"io/ioutil"
+	"path"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/trillian/storage/blob"
+)
+
+// Store keeps blobs as objects in a single bucket, named after their keys.
+type Store struct {
+	bucket *storage.BucketHandle
+	prefix string
+}
+
+// NewStore returns a Store keeping blobs in bucket, with object names starting with
+// prefix. Application default credentials are used.
+func NewStore(ctx context.Context, bucket, prefix string) (*Store, error) {
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{bucket: client.Bucket(bucket), prefix: prefix}, nil
+}
+
+// Put implements blob.Store.
+func (s *Store) Put(ctx context.Context, key string, data []byte) error {
+	w := s.bucket.Object(path.Join(s.prefix, key)).NewWriter(ctx)
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// Get implements blob.Store.
+func (s *Store) Get(ctx context.Context, key string) ([]byte, error) {
+	r, err := s.bucket.Object(path.Join(s.prefix, key)).NewReader(ctx)
+	if err == storage.ErrObjectNotExist {
+		return nil, blob.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// Delete implements blob.Store.
+func (s *Store) Delete(ctx context.Context, key string) error {
+	if err := s.bucket.Object(path.Join(s.prefix, key)).Delete(ctx); err != nil && err != storage.ErrObjectNotExist {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/errors"
+	"github.com/google/trillian/monitoring/metric"
+	"github.com/google/trillian/util"
+)
+
+// ErrCircuitOpen is returned instead of starting a transaction while a CircuitBreaker
+// is open.
+var ErrCircuitOpen = errors.New(errors.Unavailable, "storage is unavailable")
+
+// Breaker states, as exported by the storage_circuit_breaker_state metric.
+const (
+	breakerClosed = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+var (
+	breakerStateGauge = metric.NewGauge("storage_circuit_breaker_state")
+	breakerRejected   = metric.NewCounter("storage_circuit_breaker_rejected")
+)
+
+// BreakerOptions configures a CircuitBreaker.
+type BreakerOptions struct {
+	// Threshold is the number of consecutive failures to start a transaction which
+	// open the breaker.
+	Threshold int
+	// Cooldown is how long the breaker stays open before a single transaction is let
+	// through to probe whether storage has recovered.
+	Cooldown time.Duration
+	// TimeSource is used to time the cooldown, the system time if nil.
+	TimeSource util.TimeSource
+}
+
+// CircuitBreaker makes storage fail fast while it's unavailable, rather than have every
+// request wait for it until its deadline. After Threshold consecutive failures to start
+// a transaction the breaker opens and transactions fail with ErrCircuitOpen. Once the
+// Cooldown has passed one transaction is let through: if it starts the breaker closes
+// again, otherwise it stays open for another Cooldown.
+//
+// Errors which carry a Trillian error code, and cancellations, are the caller's doing
+// and don't count as failures.
+type CircuitBreaker struct {
+	opts BreakerOptions
+
+	mu       sync.Mutex
+	state    int
+	failures int
+	openedAt time.Time
+}
+
+// NewCircuitBreaker returns a closed CircuitBreaker. Storage is wrapped with its
+// LogStorage and AdminStorage methods, which may be used together so that failures of
+// either open the breaker for both.
+func NewCircuitBreaker(opts BreakerOptions) *CircuitBreaker {
+	if opts.TimeSource == nil {
+		opts.TimeSource = util.SystemTimeSource{}
+	}
+	breakerStateGauge.Set(breakerClosed)
+	return &CircuitBreaker{opts: opts}
+}
+
+// allow returns ErrCircuitOpen if a transaction can't be started at the moment.
+func (b *CircuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		if b.opts.TimeSource.Now().Sub(b.openedAt) >= b.opts.Cooldown {
+			b.setState(breakerHalfOpen)
+			return nil
+		}
+	case breakerHalfOpen:
+		// A probe is in flight.
+	default:
+		return nil
+	}
+	breakerRejected.Add(1)
+	return ErrCircuitOpen
+}
+
+// record updates the breaker with the outcome of starting a transaction.
+func (b *CircuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == context.Canceled {
+		if b.state == breakerHalfOpen {
+			// The probe told us nothing, let the next transaction try again.
+			b.setState(breakerOpen)
+		}
+		return
+	}
+	if err == nil || errors.ErrorCode(err) != errors.Unknown {
+		if b.state != breakerClosed {
+			glog.Info("Storage is available, closing circuit breaker")
+		}
+		b.failures = 0
+		b.setState(breakerClosed)
+		return
+	}
+
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.opts.Threshold {
+		if b.state == breakerClosed {
+			glog.Warningf("Opening circuit breaker after %d consecutive storage failures: %v", b.failures, err)
+		}
+		b.openedAt = b.opts.TimeSource.Now()
+		b.setState(breakerOpen)
+	}
+}
+
+func (b *CircuitBreaker) setState(state int) {
+	b.state = state
+	breakerStateGauge.Set(int64(state))
+}
+
+// LogStorage returns a LogStorage which starts transactions on s while the breaker
+// allows it.
+func (b *CircuitBreaker) LogStorage(s LogStorage) LogStorage {
+	return breakerLogStorage{s, b}
+}
+
+type breakerLogStorage struct {
+	LogStorage
+	b *CircuitBreaker
+}
+
+// IsTransientError passes through to the wrapped storage, so RunInLogTX still retries
+// transactions on it.
+func (s breakerLogStorage) IsTransientError(err error) bool {
+	checker, ok := s.LogStorage.(TransientErrorChecker)
+	return ok && checker.IsTransientError(err)
+}
+
+func (s breakerLogStorage) Snapshot(ctx context.Context) (ReadOnlyLogTX, error) {
+	if err := s.b.allow(); err != nil {
+		return nil, err
+	}
+	tx, err := s.LogStorage.Snapshot(ctx)
+	s.b.record(err)
+	return tx, err
+}
+
+func (s breakerLogStorage) SnapshotForTree(ctx context.Context, treeID int64) (ReadOnlyLogTreeTX, error) {
+	if err := s.b.allow(); err != nil {
+		return nil, err
+	}
+	tx, err := s.LogStorage.SnapshotForTree(ctx, treeID)
+	s.b.record(err)
+	return tx, err
+}
+
+func (s breakerLogStorage) BeginForTree(ctx context.Context, treeID int64) (LogTreeTX, error) {
+	if err := s.b.allow(); err != nil {
+		return nil, err
+	}
+	tx, err := s.LogStorage.BeginForTree(ctx, treeID)
+	s.b.record(err)
+	return tx, err
+}
+
+// AdminStorage returns an AdminStorage which starts transactions on s while the
+// breaker allows it.
+func (b *CircuitBreaker) AdminStorage(s AdminStorage) AdminStorage {
+	return breakerAdminStorage{s, b}
+}
+
+type breakerAdminStorage struct {
+	AdminStorage
+	b *CircuitBreaker
+}
+
+func (s breakerAdminStorage) Snapshot(ctx context.Context) (ReadOnlyAdminTX, error) {
+	if err := s.b.allow(); err != nil {
+		return nil, err
+	}
+	tx, err := s.AdminStorage.Snapshot(ctx)
+	s.b.record(err)
+	return tx, err
+}
+
+func (s breakerAdminStorage) Begin(ctx context.Context) (AdminTX, error) {
+	if err := s.b.allow(); err != nil {
+		return nil, err
+	}
+	tx, err := s.AdminStorage.Begin(ctx)
+	s.b.record(err)
+	return tx, err
+}
@@ -0,0 +1,96 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	te "github.com/google/trillian/errors"
+	"github.com/google/trillian/util"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	errDown := errors.New("connection refused")
+	errNotFound := te.New(te.NotFound, "no such tree")
+	timeSource := &util.FakeTimeSource{FakeTime: time.Unix(1000, 0)}
+	b := NewCircuitBreaker(BreakerOptions{Threshold: 2, Cooldown: time.Minute, TimeSource: timeSource})
+
+	mockLog := NewMockLogStorage(ctrl)
+	mockAdmin := NewMockAdminStorage(ctrl)
+	mockTx := NewMockLogTreeTX(ctrl)
+	mockAdminTx := NewMockReadOnlyAdminTX(ctrl)
+	logStorage := b.LogStorage(mockLog)
+	adminStorage := b.AdminStorage(mockAdmin)
+
+	// Request errors and a single failure leave the breaker closed.
+	mockLog.EXPECT().BeginForTree(ctx, int64(1)).Return(nil, errNotFound)
+	mockLog.EXPECT().BeginForTree(ctx, int64(1)).Return(nil, errDown)
+	mockLog.EXPECT().BeginForTree(ctx, int64(1)).Return(nil, errNotFound)
+	for _, want := range []error{errNotFound, errDown, errNotFound} {
+		if _, err := logStorage.BeginForTree(ctx, 1); err != want {
+			t.Fatalf("BeginForTree()=(_, %v), want (_, %v)", err, want)
+		}
+	}
+
+	// Failures of either storage count towards opening it.
+	mockLog.EXPECT().BeginForTree(ctx, int64(1)).Return(nil, errDown)
+	mockAdmin.EXPECT().Snapshot(ctx).Return(nil, errDown)
+	if _, err := logStorage.BeginForTree(ctx, 1); err != errDown {
+		t.Fatalf("BeginForTree()=(_, %v), want (_, %v)", err, errDown)
+	}
+	if _, err := adminStorage.Snapshot(ctx); err != errDown {
+		t.Fatalf("Snapshot()=(_, %v), want (_, %v)", err, errDown)
+	}
+
+	// While open nothing reaches storage.
+	if _, err := logStorage.SnapshotForTree(ctx, 1); err != ErrCircuitOpen {
+		t.Errorf("SnapshotForTree()=(_, %v), want (_, %v)", err, ErrCircuitOpen)
+	}
+	if _, err := adminStorage.Begin(ctx); err != ErrCircuitOpen {
+		t.Errorf("Begin()=(_, %v), want (_, %v)", err, ErrCircuitOpen)
+	}
+	if got, want := te.ErrorCode(ErrCircuitOpen), te.Unavailable; got != want {
+		t.Errorf("ErrorCode(ErrCircuitOpen)=%v, want %v", got, want)
+	}
+
+	// A failed probe after the cooldown opens it for another cooldown.
+	timeSource.FakeTime = timeSource.FakeTime.Add(time.Minute)
+	mockLog.EXPECT().BeginForTree(ctx, int64(1)).Return(nil, errDown)
+	if _, err := logStorage.BeginForTree(ctx, 1); err != errDown {
+		t.Fatalf("BeginForTree(probe)=(_, %v), want (_, %v)", err, errDown)
+	}
+	if _, err := logStorage.BeginForTree(ctx, 1); err != ErrCircuitOpen {
+		t.Errorf("BeginForTree()=(_, %v), want (_, %v)", err, ErrCircuitOpen)
+	}
+
+	// A successful probe closes it.
+	timeSource.FakeTime = timeSource.FakeTime.Add(time.Minute)
+	mockAdmin.EXPECT().Snapshot(ctx).Return(mockAdminTx, nil)
+	mockLog.EXPECT().BeginForTree(ctx, int64(1)).Return(mockTx, nil)
+	if _, err := adminStorage.Snapshot(ctx); err != nil {
+		t.Fatalf("Snapshot(probe)=(_, %v), want (_, nil)", err)
+	}
+	if tx, err := logStorage.BeginForTree(ctx, 1); tx != mockTx || err != nil {
+		t.Errorf("BeginForTree()=(%v, %v), want (%v, nil)", tx, err, mockTx)
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian/monitoring/metric"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/storagepb"
+)
+
+// Strategies for sharing subtrees between transactions, see SharedOptions.
+const (
+	// StrategyNone keeps subtrees only for the transaction which read them.
+	StrategyNone = "none"
+	// StrategyLRU also keeps the most recently read subtrees for later transactions.
+	StrategyLRU = "lru"
+)
+
+var (
+	sharedHits      = metric.NewCounter("shared_subtree_cache_hits")
+	sharedMisses    = metric.NewCounter("shared_subtree_cache_misses")
+	sharedEvictions = metric.NewCounter("shared_subtree_cache_evictions")
+)
+
+// SharedOptions configures a SharedSubtreeCache.
+type SharedOptions struct {
+	// Strategy is one of the Strategy constants.
+	Strategy string
+	// Size is the maximum number of subtrees kept by StrategyLRU.
+	Size int
+}
+
+type sharedKey struct {
+	treeID   int64
+	revision int64
+	prefix   string
+}
+
+type sharedEntry struct {
+	key     sharedKey
+	subtree *storagepb.SubtreeProto
+}
+
+// SharedSubtreeCache keeps subtrees read from storage so later transactions reading the
+// same tree at the same revision don't have to read them again. Unlike SubtreeCache it's
+// safe to share between transactions, and only ever holds committed subtrees: callers
+// mustn't pass it subtrees read at a revision which is still being written.
+type SharedSubtreeCache struct {
+	size int
+
+	mu      sync.Mutex
+	order   *list.List // of *sharedEntry, most recently used first
+	entries map[sharedKey]*list.Element
+}
+
+// NewSharedSubtreeCache returns a cache configured by opts, or nil for StrategyNone. A
+// nil *SharedSubtreeCache is valid and caches nothing.
+func NewSharedSubtreeCache(opts SharedOptions) (*SharedSubtreeCache, error) {
+	switch opts.Strategy {
+	case StrategyNone, "":
+		return nil, nil
+	case StrategyLRU:
+		if opts.Size <= 0 {
+			return nil, fmt.Errorf("subtree cache size must be positive, got %d", opts.Size)
+		}
+		return &SharedSubtreeCache{
+			size:    opts.Size,
+			order:   list.New(),
+			entries: make(map[sharedKey]*list.Element),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown subtree cache strategy %q", opts.Strategy)
+	}
+}
+
+// Wrap returns a GetSubtreesFunc which returns the subtrees of treeID at revision from
+// the cache, reading the rest with getSubtrees and adding them to the cache.
+func (c *SharedSubtreeCache) Wrap(treeID, revision int64, getSubtrees GetSubtreesFunc) GetSubtreesFunc {
+	if c == nil {
+		return getSubtrees
+	}
+	return func(ids []storage.NodeID) ([]*storagepb.SubtreeProto, error) {
+		ret := make([]*storagepb.SubtreeProto, 0, len(ids))
+		var missing []storage.NodeID
+		for _, id := range ids {
+			prefix := id.Path[:id.PrefixLenBits/8]
+			if s := c.get(sharedKey{treeID: treeID, revision: revision, prefix: string(prefix)}); s != nil {
+				ret = append(ret, s)
+				continue
+			}
+			missing = append(missing, id)
+		}
+		if len(missing) == 0 {
+			return ret, nil
+		}
+
+		subtrees, err := getSubtrees(missing)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range subtrees {
+			c.put(sharedKey{treeID: treeID, revision: revision, prefix: string(s.Prefix)}, s)
+		}
+		return append(ret, subtrees...), nil
+	}
+}
+
+// get returns a copy of the cached subtree, as the caller will populate and may modify it.
+func (c *SharedSubtreeCache) get(key sharedKey) *storagepb.SubtreeProto {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		sharedMisses.Add(1)
+		return nil
+	}
+	sharedHits.Add(1)
+	c.order.MoveToFront(e)
+	return proto.Clone(e.Value.(*sharedEntry).subtree).(*storagepb.SubtreeProto)
+}
+
+func (c *SharedSubtreeCache) put(key sharedKey, subtree *storagepb.SubtreeProto) {
+	subtree = proto.Clone(subtree).(*storagepb.SubtreeProto)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; ok {
+		return
+	}
+	c.entries[key] = c.order.PushFront(&sharedEntry{key: key, subtree: subtree})
+	for c.order.Len() > c.size {
+		e := c.order.Back()
+		c.order.Remove(e)
+		delete(c.entries, e.Value.(*sharedEntry).key)
+		sharedEvictions.Add(1)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/storagepb"
+)
+
+func TestNewSharedSubtreeCache(t *testing.T) {
+	for _, test := range []struct {
+		opts    SharedOptions
+		wantNil bool
+		wantErr bool
+	}{
+		{opts: SharedOptions{}, wantNil: true},
+		{opts: SharedOptions{Strategy: StrategyNone, Size: 10}, wantNil: true},
+		{opts: SharedOptions{Strategy: StrategyLRU, Size: 10}},
+		{opts: SharedOptions{Strategy: StrategyLRU}, wantErr: true},
+		{opts: SharedOptions{Strategy: "random", Size: 10}, wantErr: true},
+	} {
+		c, err := NewSharedSubtreeCache(test.opts)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("NewSharedSubtreeCache(%+v)=(_, %v), want err? %v", test.opts, err, test.wantErr)
+			continue
+		}
+		if gotNil := c == nil; err == nil && gotNil != test.wantNil {
+			t.Errorf("NewSharedSubtreeCache(%+v)=%v, want nil? %v", test.opts, c, test.wantNil)
+		}
+	}
+}
+
+func TestSharedSubtreeCacheWrap(t *testing.T) {
+	c, err := NewSharedSubtreeCache(SharedOptions{Strategy: StrategyLRU, Size: 2})
+	if err != nil {
+		t.Fatalf("NewSharedSubtreeCache()=%v", err)
+	}
+
+	var reads [][]byte
+	get := func(ids []storage.NodeID) ([]*storagepb.SubtreeProto, error) {
+		var ret []*storagepb.SubtreeProto
+		for _, id := range ids {
+			prefix := id.Path[:id.PrefixLenBits/8]
+			reads = append(reads, prefix)
+			ret = append(ret, &storagepb.SubtreeProto{Prefix: prefix, Depth: 8})
+		}
+		return ret, nil
+	}
+	id := func(prefix byte) storage.NodeID {
+		return storage.NodeID{Path: []byte{prefix, 0}, PrefixLenBits: 8}
+	}
+
+	for _, test := range []struct {
+		desc      string
+		revision  int64
+		ids       []storage.NodeID
+		wantReads [][]byte
+	}{
+		{desc: "empty", revision: 1, ids: []storage.NodeID{id(1), id(2)}, wantReads: [][]byte{{1}, {2}}},
+		{desc: "cached", revision: 1, ids: []storage.NodeID{id(2), id(1)}},
+		{desc: "otherRevision", revision: 2, ids: []storage.NodeID{id(1)}, wantReads: [][]byte{{1}}},
+		// Reading prefix 1 at revision 2 evicted prefix 2 at revision 1, the least recently used.
+		{desc: "evicted", revision: 1, ids: []storage.NodeID{id(1), id(2)}, wantReads: [][]byte{{2}}},
+	} {
+		reads = nil
+		got, err := c.Wrap(1, test.revision, get)(test.ids)
+		if err != nil {
+			t.Fatalf("%v: Wrap()()=%v", test.desc, err)
+		}
+		if len(got) != len(test.ids) {
+			t.Errorf("%v: Wrap()() returned %d subtrees, want %d", test.desc, len(got), len(test.ids))
+		}
+		if !reflect.DeepEqual(reads, test.wantReads) {
+			t.Errorf("%v: read %x from storage, want %x", test.desc, reads, test.wantReads)
+		}
+		// Callers populate the subtrees they get, which mustn't change the cached copies.
+		for _, s := range got {
+			s.InternalNodes = map[string][]byte{"": []byte("root")}
+		}
+	}
+
+	got, err := c.Wrap(1, 1, get)([]storage.NodeID{id(1)})
+	if err != nil {
+		t.Fatalf("Wrap()()=%v", err)
+	}
+	if len(got) != 1 || got[0].InternalNodes != nil {
+		t.Errorf("Wrap()()=%v, want unmodified cached subtree", got)
+	}
+}
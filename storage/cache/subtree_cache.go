@@ -23,6 +23,7 @@ import (
 
 	"github.com/golang/glog"
 	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/monitoring/metric"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/storage/storagepb"
 )
@@ -47,6 +48,10 @@ type stratumInfo struct {
 	depth int
 }
 
+// unchangedNodeWrites counts the node hashes set to the value they already had, which
+// don't cause their subtree to be written.
+var unchangedNodeWrites = metric.NewCounter("subtree_cache_unchanged_node_writes")
+
 const (
 	// maxSupportedTreeDepth is the maximum depth a tree can reach. Note that log trees are
 	// further limited to a depth of 63 by the use of signed 64 bit leaf indices. Map trees
@@ -71,7 +76,9 @@ type SubtreeCache struct {
 	// calls to SetNodeHash.
 	subtrees map[string]*storagepb.SubtreeProto
 	// dirtyPrefixes keeps track of all Subtrees which need to be written back
-	// to storage.
+	// to storage. Subtrees whose node hashes were only set to the values they
+	// already had aren't dirty, as storage already has them as of an earlier
+	// revision, which reads at later revisions fall back to.
 	dirtyPrefixes map[string]bool
 	// mutex guards access to the maps above.
 	mutex *sync.RWMutex
@@ -330,14 +337,19 @@ func (s *SubtreeCache) SetNodeHash(id storage.NodeID, h []byte, getSubtree GetSu
 	if c.Prefix == nil {
 		panic(fmt.Errorf("nil prefix for %v (key %v)", id.String(), prefixKey))
 	}
-	s.dirtyPrefixes[prefixKey] = true
 	// Determine whether we're being asked to store a leaf node, or an internal
 	// node, and store it accordingly.
+	nodes := c.InternalNodes
 	if int32(sx.bits) == c.Depth {
-		c.Leaves[sx.serialize()] = h
-	} else {
-		c.InternalNodes[sx.serialize()] = h
+		nodes = c.Leaves
 	}
+	sfxKey := sx.serialize()
+	if old, ok := nodes[sfxKey]; ok && bytes.Equal(old, h) {
+		unchangedNodeWrites.Add(1)
+		return nil
+	}
+	nodes[sfxKey] = h
+	s.dirtyPrefixes[prefixKey] = true
 	return nil
 }
 
@@ -239,6 +239,53 @@ func TestCacheFlush(t *testing.T) {
 	}
 }
 
+func TestCacheFlushSkipsUnchangedSubtrees(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	leafHash := testonly.Hasher.HashLeaf([]byte("leaf 0"))
+	sfx, err := makeSuffixKey(8, 0)
+	if err != nil {
+		t.Fatalf("failed to create suffix key: %v", err)
+	}
+	m := NewMockNodeStorage(mockCtrl)
+	m.EXPECT().GetSubtree(gomock.Any()).Return(&storagepb.SubtreeProto{
+		Prefix:        make([]byte, 7),
+		Depth:         8,
+		Leaves:        map[string][]byte{sfx: leafHash},
+		InternalNodes: make(map[string][]byte),
+	}, nil)
+
+	c := NewSubtreeCache(defaultLogStrata, PopulateLogSubtreeNodes(testonly.Hasher), PrepareLogSubtreeWrite())
+	leaf0, err := storage.NewNodeIDForTreeCoords(0, 0, 64)
+	if err != nil {
+		t.Fatalf("failed to create nodeID: %v", err)
+	}
+	if err := c.SetNodeHash(leaf0, leafHash, m.GetSubtree); err != nil {
+		t.Fatalf("failed to set node hash: %v", err)
+	}
+	// The subtree already holds the hash, so there's nothing to write.
+	if err := c.Flush(m.SetSubtrees); err != nil {
+		t.Fatalf("failed to flush cache: %v", err)
+	}
+
+	leaf1, err := storage.NewNodeIDForTreeCoords(0, 1, 64)
+	if err != nil {
+		t.Fatalf("failed to create nodeID: %v", err)
+	}
+	if err := c.SetNodeHash(leaf1, testonly.Hasher.HashLeaf([]byte("leaf 1")), noFetch); err != nil {
+		t.Fatalf("failed to set node hash: %v", err)
+	}
+	m.EXPECT().SetSubtrees(gomock.Any()).Do(func(trees []*storagepb.SubtreeProto) {
+		if len(trees) != 1 || len(trees[0].Leaves) != 2 {
+			t.Errorf("SetSubtrees(%v), want one subtree with 2 leaves", trees)
+		}
+	}).Return(nil)
+	if err := c.Flush(m.SetSubtrees); err != nil {
+		t.Fatalf("failed to flush cache: %v", err)
+	}
+}
+
 func TestSuffixSerializeFormat(t *testing.T) {
 	s := Suffix{5, []byte{0xae}}
 	if got, want := s.serialize(), "Ba4="; got != want {
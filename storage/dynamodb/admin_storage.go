@@ -0,0 +1,247 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	ddb "github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/errors"
+	"github.com/google/trillian/storage"
+)
+
+// NewAdminStorage returns a storage.AdminStorage keeping trees in DynamoDB. Its
+// transactions write straight away, Commit and Rollback only end them.
+func NewAdminStorage(client dynamodbiface.DynamoDBAPI, opts Options) storage.AdminStorage {
+	return &adminStorage{client: client, opts: opts}
+}
+
+type adminStorage struct {
+	client dynamodbiface.DynamoDBAPI
+	opts   Options
+}
+
+func (s *adminStorage) Snapshot(ctx context.Context) (storage.ReadOnlyAdminTX, error) {
+	return s.Begin(ctx)
+}
+
+func (s *adminStorage) Begin(ctx context.Context) (storage.AdminTX, error) {
+	return &adminTX{client: s.client, opts: s.opts}, nil
+}
+
+type adminTX struct {
+	client dynamodbiface.DynamoDBAPI
+	opts   Options
+	closed bool
+}
+
+func (t *adminTX) Commit() error {
+	t.closed = true
+	return nil
+}
+
+func (t *adminTX) Rollback() error {
+	t.closed = true
+	return nil
+}
+
+func (t *adminTX) IsClosed() bool {
+	return t.closed
+}
+
+func (t *adminTX) Close() error {
+	t.closed = true
+	return nil
+}
+
+// Atomic implements storage.AtomicityChecker, writes are made as they're requested so
+// are never rolled back.
+func (t *adminTX) Atomic() bool {
+	return false
+}
+
+func (t *adminTX) GetTree(ctx context.Context, treeID int64) (*trillian.Tree, error) {
+	return getTree(ctx, t.client, t.opts, treeID)
+}
+
+// getTree reads the tree treeID from treesTable.
+func getTree(ctx context.Context, client dynamodbiface.DynamoDBAPI, opts Options, treeID int64) (*trillian.Tree, error) {
+	out, err := client.GetItemWithContext(ctx, &ddb.GetItemInput{
+		TableName:      opts.table(treesTable),
+		Key:            treeKey(treeID),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(out.Item) == 0 {
+		return nil, errors.Errorf(errors.NotFound, "tree %v not found", treeID)
+	}
+	return readTree(out.Item)
+}
+
+func readTree(it item) (*trillian.Tree, error) {
+	tree := &trillian.Tree{}
+	if err := proto.Unmarshal(it.getBytes("Tree"), tree); err != nil {
+		return nil, fmt.Errorf("could not unmarshal Tree: %v", err)
+	}
+	return tree, nil
+}
+
+// listTrees reads every tree from treesTable.
+func listTrees(ctx context.Context, client dynamodbiface.DynamoDBAPI, opts Options) ([]*trillian.Tree, error) {
+	trees := []*trillian.Tree{}
+	var readErr error
+	err := client.ScanPagesWithContext(ctx, &ddb.ScanInput{
+		TableName:      opts.table(treesTable),
+		ConsistentRead: aws.Bool(true),
+	}, func(out *ddb.ScanOutput, last bool) bool {
+		for _, it := range out.Items {
+			tree, err := readTree(it)
+			if err != nil {
+				readErr = err
+				return false
+			}
+			trees = append(trees, tree)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return trees, readErr
+}
+
+func (t *adminTX) ListTreeIDs(ctx context.Context) ([]int64, error) {
+	trees, err := listTrees(ctx, t.client, t.opts)
+	if err != nil {
+		return nil, err
+	}
+	treeIDs := []int64{}
+	for _, tree := range trees {
+		treeIDs = append(treeIDs, tree.TreeId)
+	}
+	return treeIDs, nil
+}
+
+func (t *adminTX) ListTrees(ctx context.Context) ([]*trillian.Tree, error) {
+	return listTrees(ctx, t.client, t.opts)
+}
+
+// CheckNewTree implements storage.NewTreeChecker.
+func (t *adminTX) CheckNewTree(ctx context.Context, tree *trillian.Tree) error {
+	if err := storage.ValidateTreeForCreation(tree); err != nil {
+		return err
+	}
+
+	if tree.ShardSetId != 0 {
+		trees, err := listTrees(ctx, t.client, t.opts)
+		if err != nil {
+			return err
+		}
+		for _, other := range trees {
+			if other.ShardSetId == tree.ShardSetId && other.ShardStartMillisSinceEpoch < tree.ShardEndMillisSinceEpoch && tree.ShardStartMillisSinceEpoch < other.ShardEndMillisSinceEpoch {
+				return errors.Errorf(errors.InvalidArgument, "shard window [%v, %v) overlaps another shard of set %v", tree.ShardStartMillisSinceEpoch, tree.ShardEndMillisSinceEpoch, tree.ShardSetId)
+			}
+		}
+	}
+	return nil
+}
+
+func (t *adminTX) CreateTree(ctx context.Context, tree *trillian.Tree) (*trillian.Tree, error) {
+	if err := t.CheckNewTree(ctx, tree); err != nil {
+		return nil, err
+	}
+
+	id, err := storage.NewTreeID()
+	if err != nil {
+		return nil, err
+	}
+	nowMillis := toMillisSinceEpoch(time.Now())
+
+	newTree := *tree
+	newTree.TreeId = id
+	newTree.CreateTimeMillisSinceEpoch = nowMillis
+	newTree.UpdateTimeMillisSinceEpoch = nowMillis
+
+	// The random ID is very unlikely to be taken, but make sure.
+	if err := t.putTree(ctx, &newTree, "attribute_not_exists(TreeId)", nil); err != nil {
+		if isConditionFailed(err) {
+			return nil, errors.Errorf(errors.AlreadyExists, "tree %v already exists", id)
+		}
+		return nil, err
+	}
+	return &newTree, nil
+}
+
+func (t *adminTX) UpdateTree(ctx context.Context, treeID int64, updateFunc func(*trillian.Tree)) (*trillian.Tree, error) {
+	tree, err := t.GetTree(ctx, treeID)
+	if err != nil {
+		return nil, err
+	}
+
+	beforeUpdate := *tree
+	updateFunc(tree)
+	// A log is only finalized while it's frozen, unfreezing or deleting it undoes that.
+	if tree.TreeState != trillian.TreeState_FROZEN {
+		tree.FinalizeTimeMillisSinceEpoch = 0
+		tree.FinalizedTreeSize = 0
+	}
+	if err := storage.ValidateTreeForUpdate(&beforeUpdate, tree); err != nil {
+		return nil, err
+	}
+
+	tree.UpdateTimeMillisSinceEpoch = toMillisSinceEpoch(time.Now())
+
+	// Without transactions, fail rather than lose a concurrent update.
+	if err := t.putTree(ctx, tree, "UpdateTimeMillis = :before", item{":before": numAttr(beforeUpdate.UpdateTimeMillisSinceEpoch)}); err != nil {
+		if isConditionFailed(err) {
+			return nil, errors.Errorf(errors.Aborted, "tree %v was updated concurrently", treeID)
+		}
+		return nil, err
+	}
+	return tree, nil
+}
+
+// putTree writes tree to treesTable, if condition holds.
+func (t *adminTX) putTree(ctx context.Context, tree *trillian.Tree, condition string, values item) error {
+	data, err := proto.Marshal(tree)
+	if err != nil {
+		return fmt.Errorf("could not marshal Tree: %v", err)
+	}
+	it := treeKey(tree.TreeId)
+	it["Tree"] = bytesAttr(data)
+	it["UpdateTimeMillis"] = numAttr(tree.UpdateTimeMillisSinceEpoch)
+	input := &ddb.PutItemInput{
+		TableName:           t.opts.table(treesTable),
+		Item:                it,
+		ConditionExpression: aws.String(condition),
+	}
+	if len(values) > 0 {
+		input.ExpressionAttributeValues = values
+	}
+	_, err = t.client.PutItemWithContext(ctx, input)
+	return err
+}
+
+func toMillisSinceEpoch(t time.Time) int64 {
+	return t.UnixNano() / 1000000
+}
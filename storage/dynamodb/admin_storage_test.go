@@ -0,0 +1,97 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynamodb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/errors"
+	"github.com/google/trillian/storage/testonly"
+)
+
+func TestAdminTX(t *testing.T) {
+	ctx := context.Background()
+	s := NewAdminStorage(newFakeClient(), Options{})
+
+	tx, err := s.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin()=%v", err)
+	}
+	tree, err := tx.CreateTree(ctx, testonly.LogTree)
+	if err != nil {
+		t.Fatalf("CreateTree()=%v", err)
+	}
+	// Writes aren't transactional, so aren't rolled back.
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback()=%v", err)
+	}
+	if !tx.IsClosed() {
+		t.Errorf("IsClosed()=false after Rollback()")
+	}
+
+	tx, err = s.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin()=%v", err)
+	}
+	defer tx.Close()
+	got, err := tx.GetTree(ctx, tree.TreeId)
+	if err != nil {
+		t.Fatalf("GetTree()=%v", err)
+	}
+	if !proto.Equal(got, tree) {
+		t.Errorf("GetTree()=%v, want %v", got, tree)
+	}
+	if _, err := tx.GetTree(ctx, tree.TreeId+1); errors.ErrorCode(err) != errors.NotFound {
+		t.Errorf("GetTree() of a missing tree=%v, want NotFound", err)
+	}
+	ids, err := tx.ListTreeIDs(ctx)
+	if err != nil || len(ids) != 1 || ids[0] != tree.TreeId {
+		t.Errorf("ListTreeIDs()=%v, %v, want [%v], nil", ids, err, tree.TreeId)
+	}
+
+	updated, err := tx.UpdateTree(ctx, tree.TreeId, func(tree *trillian.Tree) {
+		tree.DisplayName = "Updated"
+		tree.FinalizeTimeMillisSinceEpoch = 1000
+	})
+	if err != nil {
+		t.Fatalf("UpdateTree()=%v", err)
+	}
+	if updated.DisplayName != "Updated" || updated.FinalizeTimeMillisSinceEpoch != 0 {
+		t.Errorf("UpdateTree()=%v, want the new name and an ACTIVE tree not finalized", updated)
+	}
+
+	// An update made between reading the tree and writing it back aborts the latter.
+	_, err = tx.UpdateTree(ctx, tree.TreeId, func(tree *trillian.Tree) {
+		time.Sleep(2 * time.Millisecond)
+		if _, err := tx.UpdateTree(ctx, tree.TreeId, func(tree *trillian.Tree) { tree.Description = "Concurrent" }); err != nil {
+			t.Fatalf("UpdateTree()=%v", err)
+		}
+		tree.DisplayName = "Lost"
+	})
+	if errors.ErrorCode(err) != errors.Aborted {
+		t.Errorf("UpdateTree() racing another update=%v, want Aborted", err)
+	}
+	got, err = tx.GetTree(ctx, tree.TreeId)
+	if err != nil {
+		t.Fatalf("GetTree()=%v", err)
+	}
+	if got.DisplayName != "Updated" || got.Description != "Concurrent" {
+		t.Errorf("GetTree()=%v, want only the concurrent update", got)
+	}
+}
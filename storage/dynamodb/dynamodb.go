@@ -0,0 +1,332 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dynamodb provides log and admin storage kept in Amazon DynamoDB tables, for
+// deployments on AWS without a relational database.
+//
+// DynamoDB has no transactions spanning several items, so the transactions of this
+// storage aren't isolated and their writes aren't atomic. Leaves are queued as soon as
+// QueueLeaves is called, and a sequencing transaction writes its sequenced leaves and
+// subtrees before its root, which is written last on Commit, with a condition that no
+// root exists at its revision yet. The new revision only becomes visible with the root,
+// so a sequencing pass that fails part way is simply redone by the next one, which
+// overwrites whatever it left behind at the same revision. The queue entries of the
+// sequenced leaves are marked with the revision and an ID of the pass before the root is
+// written with the same ID, and deleted after, so entries whose deletion failed are
+// recognised and skipped, while those marked by a pass whose root was never written are
+// sequenced again. Leaf data names the queue entry written after it, so data left by a
+// QueueLeaves call failing in between doesn't make the leaf a duplicate. Two sequencers
+// running against the same log at once can overwrite each other's leaves before one of
+// their roots is rejected, so as with the other storage, only one sequencer per log must
+// run, as ensured by master election.
+//
+// Reads of roots, subtrees and leaves by index are strongly consistent. Lookups of
+// leaves by Merkle hash use a global secondary index, which is only eventually
+// consistent, so a leaf can take a moment to be found after it's sequenced.
+//
+// Witness signatures, observed roots, leaf compression, encryption and offloading
+// aren't supported, and leaves are limited to DynamoDB's item size of 400KB.
+package dynamodb
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	ddb "github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/golang/glog"
+)
+
+// Names of the tables, which are prefixed with Options.TablePrefix.
+const (
+	// treesTable holds each tree's trillian.Tree, keyed by TreeId.
+	treesTable = "Trees"
+	// treeHeadsTable holds the signed roots of each log, keyed by TreeId and TreeRevision.
+	treeHeadsTable = "TreeHeads"
+	// subtreesTable holds every revision of each subtree, keyed by TreeId and SubtreeKey.
+	subtreesTable = "Subtrees"
+	// leafDataTable holds the data of queued and sequenced leaves, keyed by TreeId and
+	// LeafIdentityHash.
+	leafDataTable = "LeafData"
+	// sequencedLeavesTable holds the hashes of the leaves at each index of each log,
+	// keyed by TreeId and SequenceNumber.
+	sequencedLeavesTable = "SequencedLeaves"
+	// unsequencedTable is the queue of leaves waiting to be sequenced, keyed by TreeId
+	// and QueueKey, which orders them by queue time.
+	unsequencedTable = "Unsequenced"
+
+	// merkleHashIndex is the global secondary index of sequencedLeavesTable by
+	// MerkleKey, which is the TreeId followed by the MerkleLeafHash.
+	merkleHashIndex = "MerkleLeafHash"
+
+	// maxBatchGet and maxBatchWrite are DynamoDB's limits on the number of items read
+	// or written by a single batch request.
+	maxBatchGet   = 100
+	maxBatchWrite = 25
+)
+
+// Options configures the storage.
+type Options struct {
+	// TablePrefix is prepended to the table names, so several deployments can share
+	// an account and region.
+	TablePrefix string
+	// ReadCapacity and WriteCapacity are the provisioned throughput of the tables, and
+	// the index, created by CreateTables. They default to 5 units.
+	ReadCapacity, WriteCapacity int64
+}
+
+func (o Options) table(name string) *string {
+	return aws.String(o.TablePrefix + name)
+}
+
+// NewClient returns a DynamoDB client, with credentials and the region taken from the
+// environment as usual for AWS.
+func NewClient() (dynamodbiface.DynamoDBAPI, error) {
+	sess, err := session.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	return ddb.New(sess), nil
+}
+
+// tableSpec describes the keys of a table for CreateTables.
+type tableSpec struct {
+	name        string
+	hashKey     string
+	rangeKey    string
+	rangeKeyB   bool
+	extraAttrs  []*ddb.AttributeDefinition
+	secondaries []*ddb.GlobalSecondaryIndex
+}
+
+// CreateTables creates the tables used by the storage which don't exist yet, and waits
+// for them to become active.
+func CreateTables(ctx context.Context, client dynamodbiface.DynamoDBAPI, opts Options) error {
+	read, write := opts.ReadCapacity, opts.WriteCapacity
+	if read <= 0 {
+		read = 5
+	}
+	if write <= 0 {
+		write = 5
+	}
+	throughput := &ddb.ProvisionedThroughput{ReadCapacityUnits: aws.Int64(read), WriteCapacityUnits: aws.Int64(write)}
+
+	specs := []tableSpec{
+		{name: treesTable, hashKey: "TreeId"},
+		{name: treeHeadsTable, hashKey: "TreeId", rangeKey: "TreeRevision"},
+		{name: subtreesTable, hashKey: "TreeId", rangeKey: "SubtreeKey", rangeKeyB: true},
+		{name: leafDataTable, hashKey: "TreeId", rangeKey: "LeafIdentityHash", rangeKeyB: true},
+		{
+			name:       sequencedLeavesTable,
+			hashKey:    "TreeId",
+			rangeKey:   "SequenceNumber",
+			extraAttrs: []*ddb.AttributeDefinition{{AttributeName: aws.String("MerkleKey"), AttributeType: aws.String(ddb.ScalarAttributeTypeB)}},
+			secondaries: []*ddb.GlobalSecondaryIndex{{
+				IndexName: aws.String(merkleHashIndex),
+				KeySchema: []*ddb.KeySchemaElement{
+					{AttributeName: aws.String("MerkleKey"), KeyType: aws.String(ddb.KeyTypeHash)},
+					{AttributeName: aws.String("SequenceNumber"), KeyType: aws.String(ddb.KeyTypeRange)},
+				},
+				Projection:            &ddb.Projection{ProjectionType: aws.String(ddb.ProjectionTypeAll)},
+				ProvisionedThroughput: throughput,
+			}},
+		},
+		{name: unsequencedTable, hashKey: "TreeId", rangeKey: "QueueKey", rangeKeyB: true},
+	}
+
+	for _, spec := range specs {
+		input := &ddb.CreateTableInput{
+			TableName: opts.table(spec.name),
+			AttributeDefinitions: append([]*ddb.AttributeDefinition{
+				{AttributeName: aws.String(spec.hashKey), AttributeType: aws.String(ddb.ScalarAttributeTypeN)},
+			}, spec.extraAttrs...),
+			KeySchema: []*ddb.KeySchemaElement{
+				{AttributeName: aws.String(spec.hashKey), KeyType: aws.String(ddb.KeyTypeHash)},
+			},
+			GlobalSecondaryIndexes: spec.secondaries,
+			ProvisionedThroughput:  throughput,
+		}
+		if spec.rangeKey != "" {
+			attrType := ddb.ScalarAttributeTypeN
+			if spec.rangeKeyB {
+				attrType = ddb.ScalarAttributeTypeB
+			}
+			input.AttributeDefinitions = append(input.AttributeDefinitions, &ddb.AttributeDefinition{AttributeName: aws.String(spec.rangeKey), AttributeType: aws.String(attrType)})
+			input.KeySchema = append(input.KeySchema, &ddb.KeySchemaElement{AttributeName: aws.String(spec.rangeKey), KeyType: aws.String(ddb.KeyTypeRange)})
+		}
+		_, err := client.CreateTableWithContext(ctx, input)
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == ddb.ErrCodeResourceInUseException {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create table %v: %v", *input.TableName, err)
+		}
+		glog.Infof("Created DynamoDB table %v", *input.TableName)
+		if err := client.WaitUntilTableExistsWithContext(ctx, &ddb.DescribeTableInput{TableName: input.TableName}); err != nil {
+			return fmt.Errorf("table %v didn't become active: %v", *input.TableName, err)
+		}
+	}
+	return nil
+}
+
+// item is a DynamoDB item, or the key of one.
+type item map[string]*ddb.AttributeValue
+
+func numAttr(n int64) *ddb.AttributeValue {
+	return &ddb.AttributeValue{N: aws.String(strconv.FormatInt(n, 10))}
+}
+
+func bytesAttr(b []byte) *ddb.AttributeValue {
+	return &ddb.AttributeValue{B: b}
+}
+
+// setBytes sets the attribute name of it to b, unless b is empty, as DynamoDB doesn't
+// allow empty binary attributes.
+func (it item) setBytes(name string, b []byte) {
+	if len(b) > 0 {
+		it[name] = bytesAttr(b)
+	}
+}
+
+// getBytes returns the value of the binary attribute name, or nil if there isn't one.
+func (it item) getBytes(name string) []byte {
+	if v, ok := it[name]; ok {
+		return v.B
+	}
+	return nil
+}
+
+// getInt returns the value of the number attribute name.
+func (it item) getInt(name string) (int64, error) {
+	v, ok := it[name]
+	if !ok || v.N == nil {
+		return 0, fmt.Errorf("item has no %v attribute", name)
+	}
+	return strconv.ParseInt(*v.N, 10, 64)
+}
+
+// treeKey returns the key of a tree in treesTable.
+func treeKey(treeID int64) item {
+	return item{"TreeId": numAttr(treeID)}
+}
+
+// subtreeKey returns the SubtreeKey of the subtree with prefix at revision. The prefix
+// is preceded by its length, so the revisions of a subtree sort together, by revision,
+// apart from those of the subtrees it's a prefix of.
+func subtreeKey(prefix []byte, revision int64) []byte {
+	key := make([]byte, 0, 1+len(prefix)+8)
+	key = append(key, byte(len(prefix)))
+	key = append(key, prefix...)
+	return appendUint64(key, uint64(revision))
+}
+
+// queueKey returns the QueueKey of a leaf queued at queueTime, which sorts queued leaves
+// by time. The leaf's identity hash and suffix make it unique.
+func queueKey(queueTime time.Time, leafIdentityHash, suffix []byte) []byte {
+	key := make([]byte, 0, 8+len(leafIdentityHash)+len(suffix))
+	key = appendUint64(key, uint64(queueTime.UnixNano()))
+	key = append(key, leafIdentityHash...)
+	return append(key, suffix...)
+}
+
+// queueTimeKey returns the smallest QueueKey of the leaves queued at queueTime.
+func queueTimeKey(queueTime time.Time) []byte {
+	return appendUint64(nil, uint64(queueTime.UnixNano()))
+}
+
+// merkleKey returns the MerkleKey a sequenced leaf with merkleLeafHash is indexed by.
+func merkleKey(treeID int64, merkleLeafHash []byte) []byte {
+	return append(appendUint64(nil, uint64(treeID)), merkleLeafHash...)
+}
+
+func appendUint64(b []byte, n uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], n)
+	return append(b, buf[:]...)
+}
+
+// isConditionFailed returns whether err is the failure of a conditional write.
+func isConditionFailed(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == ddb.ErrCodeConditionalCheckFailedException
+}
+
+// batchGet reads the items with keys from table, which mustn't hold duplicates. The
+// items are returned in no particular order, without entries for missing ones.
+func batchGet(ctx context.Context, client dynamodbiface.DynamoDBAPI, table *string, keys []item) ([]item, error) {
+	var items []item
+	for start := 0; start < len(keys); start += maxBatchGet {
+		end := start + maxBatchGet
+		if end > len(keys) {
+			end = len(keys)
+		}
+		request := &ddb.KeysAndAttributes{ConsistentRead: aws.Bool(true)}
+		for _, key := range keys[start:end] {
+			request.Keys = append(request.Keys, key)
+		}
+		requests := map[string]*ddb.KeysAndAttributes{*table: request}
+		for attempt := 0; len(requests) > 0; attempt++ {
+			if attempt > 0 {
+				batchBackoff(attempt)
+			}
+			out, err := client.BatchGetItemWithContext(ctx, &ddb.BatchGetItemInput{RequestItems: requests})
+			if err != nil {
+				return nil, err
+			}
+			for _, it := range out.Responses[*table] {
+				items = append(items, it)
+			}
+			requests = out.UnprocessedKeys
+		}
+	}
+	return items, nil
+}
+
+// batchWrite applies writes to table.
+func batchWrite(ctx context.Context, client dynamodbiface.DynamoDBAPI, table *string, writes []*ddb.WriteRequest) error {
+	for start := 0; start < len(writes); start += maxBatchWrite {
+		end := start + maxBatchWrite
+		if end > len(writes) {
+			end = len(writes)
+		}
+		requests := map[string][]*ddb.WriteRequest{*table: writes[start:end]}
+		for attempt := 0; len(requests) > 0; attempt++ {
+			if attempt > 0 {
+				batchBackoff(attempt)
+			}
+			out, err := client.BatchWriteItemWithContext(ctx, &ddb.BatchWriteItemInput{RequestItems: requests})
+			if err != nil {
+				return err
+			}
+			requests = out.UnprocessedItems
+		}
+	}
+	return nil
+}
+
+// batchBackoff waits before retrying the unprocessed part of a batch, which DynamoDB
+// returns when it's short of capacity.
+func batchBackoff(attempt int) {
+	wait := 10 * time.Millisecond << uint(attempt)
+	if wait > time.Second {
+		wait = time.Second
+	}
+	time.Sleep(wait)
+}
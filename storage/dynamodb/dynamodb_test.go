@@ -0,0 +1,84 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynamodb
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestSubtreeKeyOrder(t *testing.T) {
+	// The revisions of a subtree must sort together, by revision, so a range query
+	// finds the latest one at or below a revision.
+	for _, test := range []struct {
+		desc       string
+		lower      []byte
+		lowerRev   int64
+		higher     []byte
+		higherRev  int64
+		wantBefore bool
+	}{
+		{desc: "revisions", lower: []byte{1}, lowerRev: 1, higher: []byte{1}, higherRev: 2, wantBefore: true},
+		{desc: "revisionsAcrossBytes", lower: []byte{1}, lowerRev: 255, higher: []byte{1}, higherRev: 256, wantBefore: true},
+		{desc: "rootBeforeChildren", lower: []byte{}, lowerRev: 5, higher: []byte{0}, higherRev: 0, wantBefore: true},
+		{desc: "shorterPrefixFirst", lower: []byte{0xff}, lowerRev: 1 << 40, higher: []byte{0, 0}, higherRev: 0, wantBefore: true},
+		{desc: "prefixes", lower: []byte{1, 2}, lowerRev: 3, higher: []byte{1, 3}, higherRev: 1, wantBefore: true},
+	} {
+		lower, higher := subtreeKey(test.lower, test.lowerRev), subtreeKey(test.higher, test.higherRev)
+		if got := bytes.Compare(lower, higher) < 0; got != test.wantBefore {
+			t.Errorf("%v: subtreeKey(%x, %d) < subtreeKey(%x, %d): %v, want %v", test.desc, test.lower, test.lowerRev, test.higher, test.higherRev, got, test.wantBefore)
+		}
+	}
+
+	// No revision of one subtree may fall between the revisions of another.
+	first, last := subtreeKey([]byte{1}, 0), subtreeKey([]byte{1}, 1<<62)
+	for _, other := range [][]byte{{}, {0}, {2}, {1, 0}, {1, 0xff}} {
+		key := subtreeKey(other, 7)
+		if bytes.Compare(key, first) >= 0 && bytes.Compare(key, last) <= 0 {
+			t.Errorf("subtreeKey(%x, 7)=%x sorts among the revisions of prefix 01", other, key)
+		}
+	}
+}
+
+func TestQueueKeyOrder(t *testing.T) {
+	hash := bytes.Repeat([]byte{0xff}, 32)
+	earlier := time.Unix(1000, 999)
+	later := time.Unix(1000, 1000)
+
+	if got := queueKey(earlier, hash, []byte{0xff}); bytes.Compare(got, queueKey(later, make([]byte, 32), nil)) >= 0 {
+		t.Errorf("queueKey(%v) doesn't sort before queueKey(%v)", earlier, later)
+	}
+	// DequeueLeaves reads the keys below the time key just after its cutoff.
+	if got, cutoff := queueKey(earlier, hash, nil), queueTimeKey(earlier.Add(time.Nanosecond)); bytes.Compare(got, cutoff) >= 0 {
+		t.Errorf("queueKey(%v)=%x doesn't sort before queueTimeKey(%v)=%x", earlier, got, earlier.Add(time.Nanosecond), cutoff)
+	}
+	if got, cutoff := queueKey(later, nil, nil), queueTimeKey(later); bytes.Compare(got, cutoff) < 0 {
+		t.Errorf("queueKey(%v)=%x sorts before queueTimeKey(%v)=%x", later, got, later, cutoff)
+	}
+	if a, b := queueKey(earlier, hash, []byte{1}), queueKey(earlier, hash, []byte{2}); bytes.Equal(a, b) {
+		t.Errorf("queueKey() with different suffixes returned the same key %x", a)
+	}
+}
+
+func TestMerkleKey(t *testing.T) {
+	hash := []byte{1, 2, 3}
+	if a, b := merkleKey(1, hash), merkleKey(2, hash); bytes.Equal(a, b) {
+		t.Errorf("merkleKey() of the same hash in different trees returned the same key %x", a)
+	}
+	if got, want := merkleKey(0x0102, hash), []byte{0, 0, 0, 0, 0, 0, 1, 2, 1, 2, 3}; !bytes.Equal(got, want) {
+		t.Errorf("merkleKey(0x0102, %x)=%x, want %x", hash, got, want)
+	}
+}
@@ -0,0 +1,321 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynamodb
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	ddb "github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// fakeKeys are the key attributes of the tables and index, as created by CreateTables.
+var fakeKeys = map[string][2]string{
+	treesTable:           {"TreeId", ""},
+	treeHeadsTable:       {"TreeId", "TreeRevision"},
+	subtreesTable:        {"TreeId", "SubtreeKey"},
+	leafDataTable:        {"TreeId", "LeafIdentityHash"},
+	sequencedLeavesTable: {"TreeId", "SequenceNumber"},
+	unsequencedTable:     {"TreeId", "QueueKey"},
+	merkleHashIndex:      {"MerkleKey", "SequenceNumber"},
+}
+
+// fakeClient is an in-memory DynamoDB, with no table prefix, implementing the requests
+// and the expressions the storage uses.
+type fakeClient struct {
+	dynamodbiface.DynamoDBAPI
+
+	mu     sync.Mutex
+	tables map[string]map[string]item
+	// fail, if set, is called with the operation and table of each request, and an
+	// error it returns fails the request.
+	fail func(op, table string) error
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{tables: make(map[string]map[string]item)}
+}
+
+func attrString(v *ddb.AttributeValue) string {
+	if v == nil {
+		return ""
+	}
+	if v.N != nil {
+		return "n" + *v.N
+	}
+	return fmt.Sprintf("b%x", v.B)
+}
+
+func compareAttrs(a, b *ddb.AttributeValue) int {
+	if a.N != nil && b.N != nil {
+		x, _ := strconv.ParseInt(*a.N, 10, 64)
+		y, _ := strconv.ParseInt(*b.N, 10, 64)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	return bytes.Compare(a.B, b.B)
+}
+
+func copyItem(it item) item {
+	c := make(item, len(it))
+	for k, v := range it {
+		c[k] = v
+	}
+	return c
+}
+
+func (f *fakeClient) key(table string, it item) string {
+	keys := fakeKeys[table]
+	return attrString(it[keys[0]]) + "/" + attrString(it[keys[1]])
+}
+
+func (f *fakeClient) check(op, table string) error {
+	if f.fail != nil {
+		return f.fail(op, table)
+	}
+	return nil
+}
+
+func (f *fakeClient) table(name string) map[string]item {
+	t, ok := f.tables[name]
+	if !ok {
+		t = make(map[string]item)
+		f.tables[name] = t
+	}
+	return t
+}
+
+func (f *fakeClient) put(table string, it item, condition *string, values map[string]*ddb.AttributeValue) error {
+	key := f.key(table, it)
+	existing, ok := f.table(table)[key]
+	if !ok {
+		existing = item{}
+	}
+	if condition != nil && !evaluate(*condition, existing, values) {
+		return awserr.New(ddb.ErrCodeConditionalCheckFailedException, "the conditional request failed", nil)
+	}
+	f.table(table)[key] = copyItem(it)
+	return nil
+}
+
+func (f *fakeClient) PutItemWithContext(ctx aws.Context, input *ddb.PutItemInput, opts ...request.Option) (*ddb.PutItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("PutItem", *input.TableName); err != nil {
+		return nil, err
+	}
+	return &ddb.PutItemOutput{}, f.put(*input.TableName, input.Item, input.ConditionExpression, input.ExpressionAttributeValues)
+}
+
+func (f *fakeClient) GetItemWithContext(ctx aws.Context, input *ddb.GetItemInput, opts ...request.Option) (*ddb.GetItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("GetItem", *input.TableName); err != nil {
+		return nil, err
+	}
+	out := &ddb.GetItemOutput{}
+	if it, ok := f.table(*input.TableName)[f.key(*input.TableName, input.Key)]; ok {
+		out.Item = copyItem(it)
+	}
+	return out, nil
+}
+
+func (f *fakeClient) query(input *ddb.QueryInput) []map[string]*ddb.AttributeValue {
+	table, keys := *input.TableName, fakeKeys[*input.TableName]
+	if input.IndexName != nil {
+		keys = fakeKeys[*input.IndexName]
+	}
+	var found []item
+	for _, it := range f.table(table) {
+		if it[keys[0]] != nil && evaluate(*input.KeyConditionExpression, it, input.ExpressionAttributeValues) {
+			found = append(found, it)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		c := compareAttrs(found[i][keys[1]], found[j][keys[1]])
+		if aws.BoolValue(input.ScanIndexForward) || input.ScanIndexForward == nil {
+			return c < 0
+		}
+		return c > 0
+	})
+	if input.Limit != nil && int64(len(found)) > *input.Limit {
+		found = found[:*input.Limit]
+	}
+	var items []map[string]*ddb.AttributeValue
+	for _, it := range found {
+		if input.FilterExpression == nil || evaluate(*input.FilterExpression, it, input.ExpressionAttributeValues) {
+			items = append(items, copyItem(it))
+		}
+	}
+	return items
+}
+
+func (f *fakeClient) QueryWithContext(ctx aws.Context, input *ddb.QueryInput, opts ...request.Option) (*ddb.QueryOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("Query", *input.TableName); err != nil {
+		return nil, err
+	}
+	items := f.query(input)
+	return &ddb.QueryOutput{Items: items, Count: aws.Int64(int64(len(items)))}, nil
+}
+
+func (f *fakeClient) QueryPagesWithContext(ctx aws.Context, input *ddb.QueryInput, fn func(*ddb.QueryOutput, bool) bool, opts ...request.Option) error {
+	out, err := f.QueryWithContext(ctx, input)
+	if err != nil {
+		return err
+	}
+	fn(out, true)
+	return nil
+}
+
+func (f *fakeClient) ScanPagesWithContext(ctx aws.Context, input *ddb.ScanInput, fn func(*ddb.ScanOutput, bool) bool, opts ...request.Option) error {
+	f.mu.Lock()
+	if err := f.check("Scan", *input.TableName); err != nil {
+		f.mu.Unlock()
+		return err
+	}
+	var items []map[string]*ddb.AttributeValue
+	for _, it := range f.table(*input.TableName) {
+		items = append(items, copyItem(it))
+	}
+	f.mu.Unlock()
+	fn(&ddb.ScanOutput{Items: items, Count: aws.Int64(int64(len(items)))}, true)
+	return nil
+}
+
+func (f *fakeClient) BatchGetItemWithContext(ctx aws.Context, input *ddb.BatchGetItemInput, opts ...request.Option) (*ddb.BatchGetItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := &ddb.BatchGetItemOutput{Responses: make(map[string][]map[string]*ddb.AttributeValue)}
+	for table, keys := range input.RequestItems {
+		if err := f.check("BatchGetItem", table); err != nil {
+			return nil, err
+		}
+		for _, key := range keys.Keys {
+			if it, ok := f.table(table)[f.key(table, key)]; ok {
+				out.Responses[table] = append(out.Responses[table], copyItem(it))
+			}
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeClient) BatchWriteItemWithContext(ctx aws.Context, input *ddb.BatchWriteItemInput, opts ...request.Option) (*ddb.BatchWriteItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for table, writes := range input.RequestItems {
+		if err := f.check("BatchWriteItem", table); err != nil {
+			return nil, err
+		}
+		for _, w := range writes {
+			if w.PutRequest != nil {
+				f.put(table, w.PutRequest.Item, nil, nil)
+			} else {
+				delete(f.table(table), f.key(table, w.DeleteRequest.Key))
+			}
+		}
+	}
+	return &ddb.BatchWriteItemOutput{}, nil
+}
+
+func (f *fakeClient) DescribeTableWithContext(ctx aws.Context, input *ddb.DescribeTableInput, opts ...request.Option) (*ddb.DescribeTableOutput, error) {
+	return &ddb.DescribeTableOutput{}, nil
+}
+
+// evaluate returns whether the condition or key condition expr holds for it. Only the
+// operators used by the storage are supported: OR, AND, attribute_not_exists,
+// comparisons and BETWEEN, all separated by spaces.
+func evaluate(expr string, it item, values map[string]*ddb.AttributeValue) bool {
+	e := &evaluator{tokens: strings.Fields(expr), it: it, values: values}
+	return e.or()
+}
+
+type evaluator struct {
+	tokens []string
+	it     item
+	values map[string]*ddb.AttributeValue
+}
+
+func (e *evaluator) next() string {
+	tok := e.tokens[0]
+	e.tokens = e.tokens[1:]
+	return tok
+}
+
+func (e *evaluator) or() bool {
+	v := e.and()
+	for len(e.tokens) > 0 && e.tokens[0] == "OR" {
+		e.next()
+		v = e.and() || v
+	}
+	return v
+}
+
+func (e *evaluator) and() bool {
+	v := e.atom()
+	for len(e.tokens) > 0 && e.tokens[0] == "AND" {
+		e.next()
+		v = e.atom() && v
+	}
+	return v
+}
+
+func (e *evaluator) atom() bool {
+	name := e.next()
+	if strings.HasPrefix(name, "attribute_not_exists(") {
+		_, ok := e.it[strings.TrimSuffix(strings.TrimPrefix(name, "attribute_not_exists("), ")")]
+		return !ok
+	}
+	op := e.next()
+	a := e.it[name]
+	if op == "BETWEEN" {
+		low := e.values[e.next()]
+		e.next()
+		high := e.values[e.next()]
+		return a != nil && compareAttrs(a, low) >= 0 && compareAttrs(a, high) <= 0
+	}
+	b := e.values[e.next()]
+	if a == nil {
+		return false
+	}
+	c := compareAttrs(a, b)
+	switch op {
+	case "=":
+		return c == 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	panic(fmt.Sprintf("unsupported operator %q", op))
+}
@@ -0,0 +1,976 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynamodb
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	ddb "github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	spb "github.com/google/trillian/crypto/sigpb"
+	"github.com/google/trillian/errors"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/monitoring/metric"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/cache"
+)
+
+var (
+	defaultLogStrata = []int{8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8}
+
+	queuedCounter   = metric.NewCounter("dynamodb_queued_leaves")
+	dequeuedCounter = metric.NewCounter("dynamodb_dequeued_leaves")
+)
+
+type dynamoDBLogStorage struct {
+	client dynamodbiface.DynamoDBAPI
+	opts   Options
+}
+
+// NewLogStorage returns a storage.LogStorage keeping logs in the DynamoDB tables
+// named by opts, which CreateTables creates.
+func NewLogStorage(client dynamodbiface.DynamoDBAPI, opts Options) storage.LogStorage {
+	return &dynamoDBLogStorage{client: client, opts: opts}
+}
+
+func (m *dynamoDBLogStorage) CheckDatabaseAccessible(ctx context.Context) error {
+	_, err := m.client.DescribeTableWithContext(ctx, &ddb.DescribeTableInput{TableName: m.opts.table(treesTable)})
+	return err
+}
+
+// IsTransientError implements storage.TransientErrorChecker, treating throttling and
+// internal errors as worth retrying.
+func (m *dynamoDBLogStorage) IsTransientError(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		switch aerr.Code() {
+		case ddb.ErrCodeProvisionedThroughputExceededException, ddb.ErrCodeRequestLimitExceeded, ddb.ErrCodeInternalServerError, "ThrottlingException":
+			return true
+		}
+	}
+	return false
+}
+
+// readOnlyLogTX implements storage.ReadOnlyLogTX
+type readOnlyLogTX struct {
+	ctx context.Context
+	ls  *dynamoDBLogStorage
+}
+
+func (m *dynamoDBLogStorage) Snapshot(ctx context.Context) (storage.ReadOnlyLogTX, error) {
+	return &readOnlyLogTX{ctx: ctx, ls: m}, nil
+}
+
+func (t *readOnlyLogTX) Commit() error {
+	return nil
+}
+
+func (t *readOnlyLogTX) Rollback() error {
+	return nil
+}
+
+func (t *readOnlyLogTX) Close() error {
+	return nil
+}
+
+func (t *readOnlyLogTX) GetActiveLogIDs() ([]int64, error) {
+	return t.ls.getActiveLogIDs(t.ctx, false)
+}
+
+func (t *readOnlyLogTX) GetActiveLogIDsWithPendingWork() ([]int64, error) {
+	return t.ls.getActiveLogIDs(t.ctx, true)
+}
+
+// getActiveLogIDs returns the IDs of the logs, or if pendingWork is set, of the LOG
+// trees with queued leaves.
+func (m *dynamoDBLogStorage) getActiveLogIDs(ctx context.Context, pendingWork bool) ([]int64, error) {
+	trees, err := listTrees(ctx, m.client, m.opts)
+	if err != nil {
+		return nil, err
+	}
+	ids := []int64{}
+	for _, tree := range trees {
+		if tree.TreeType != trillian.TreeType_LOG && (pendingWork || tree.TreeType != trillian.TreeType_PREORDERED_LOG) {
+			continue
+		}
+		if pendingWork {
+			out, err := m.client.QueryWithContext(ctx, &ddb.QueryInput{
+				TableName:                 m.opts.table(unsequencedTable),
+				KeyConditionExpression:    aws.String("TreeId = :t"),
+				ExpressionAttributeValues: item{":t": numAttr(tree.TreeId)},
+				ProjectionExpression:      aws.String("TreeId"),
+				Limit:                     aws.Int64(1),
+			})
+			if err != nil {
+				return nil, err
+			}
+			if len(out.Items) == 0 {
+				continue
+			}
+		}
+		ids = append(ids, tree.TreeId)
+	}
+	return ids, nil
+}
+
+func (m *dynamoDBLogStorage) hasher(treeID int64) (merkle.TreeHasher, error) {
+	// TODO: read hash algorithm from storage.
+	return merkle.Factory(merkle.RFC6962SHA256Type)
+}
+
+func (m *dynamoDBLogStorage) beginInternal(ctx context.Context, treeID int64) (*logTreeTX, error) {
+	tree, err := getTree(ctx, m.client, m.opts, treeID)
+	if errors.ErrorCode(err) == errors.NotFound {
+		return nil, storage.Error{ErrType: storage.TreeNotFound, Detail: fmt.Sprintf("tree %v not found", treeID), Cause: err}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get tree %v: %v", treeID, err)
+	}
+	if tree.TreeType != trillian.TreeType_LOG && tree.TreeType != trillian.TreeType_PREORDERED_LOG {
+		return nil, fmt.Errorf("tree %v is not a log: %v", treeID, tree.TreeType)
+	}
+
+	hasher, err := m.hasher(treeID)
+	if err != nil {
+		return nil, err
+	}
+
+	ltx := &logTreeTX{
+		ctx:           ctx,
+		ls:            m,
+		tree:          tree,
+		treeID:        treeID,
+		hashSizeBytes: hasher.Size(),
+		subtreeCache:  cache.NewSubtreeCache(defaultLogStrata, cache.PopulateLogSubtreeNodes(hasher), cache.PrepareLogSubtreeWrite()),
+	}
+	ltx.root, err = ltx.fetchLatestRoot()
+	if err != nil {
+		return nil, err
+	}
+	ltx.writeRevision = ltx.root.TreeRevision + 1
+
+	return ltx, nil
+}
+
+func (m *dynamoDBLogStorage) BeginForTree(ctx context.Context, treeID int64) (storage.LogTreeTX, error) {
+	return m.beginInternal(ctx, treeID)
+}
+
+func (m *dynamoDBLogStorage) SnapshotForTree(ctx context.Context, treeID int64) (storage.ReadOnlyLogTreeTX, error) {
+	return m.beginInternal(ctx, treeID)
+}
+
+// logTreeTX buffers the root, subtrees and dequeued leaves until Commit, and writes
+// everything else straight away, see the package documentation.
+type logTreeTX struct {
+	// ctx is the context the tx was started with, used for all requests.
+	ctx           context.Context
+	ls            *dynamoDBLogStorage
+	tree          *trillian.Tree
+	treeID        int64
+	hashSizeBytes int
+	subtreeCache  cache.SubtreeCache
+	root          trillian.SignedLogRoot
+	writeRevision int64
+	// newRoot is the root stored by StoreSignedLogRoot, written on Commit.
+	newRoot *trillian.SignedLogRoot
+	// dequeued are the queue entries of the leaves returned by DequeueLeaves, and
+	// sequenced the entries of leaves sequenced at earlier revisions, which were left
+	// in the queue. Both are deleted once newRoot is written.
+	dequeued, sequenced []item
+	// rootPasses caches the pass IDs of roots read by sequencedBy, by revision.
+	rootPasses map[int64][]byte
+	closed     bool
+}
+
+// checkWritable returns an error unless leaves can be added to the tree, which is only
+// the case while it's ACTIVE, and never for verification-only trees, which can't be
+// sequenced.
+func (t *logTreeTX) checkWritable() error {
+	if t.tree.TreeState != trillian.TreeState_ACTIVE {
+		return storage.Error{ErrType: storage.TreeNotWritable, Detail: fmt.Sprintf("tree %v is %v, not ACTIVE", t.treeID, t.tree.TreeState)}
+	}
+	if t.tree.PrivateKey == nil {
+		return storage.Error{ErrType: storage.TreeNotWritable, Detail: fmt.Sprintf("tree %v is verification-only", t.treeID)}
+	}
+	return nil
+}
+
+func (t *logTreeTX) ReadRevision() int64 {
+	return t.root.TreeRevision
+}
+
+func (t *logTreeTX) WriteRevision() int64 {
+	return t.writeRevision
+}
+
+// QueueLeaves writes the leaves' data and queue entries. Unless the log allows
+// duplicates, the data is written on condition that it isn't there yet, and the leaves
+// which were already there are returned instead of queued again. The data names the
+// queue entry it's written with, so data left by a call which failed before queueing
+// its leaf is recognised, and the leaf queued after all, see reclaimLeafData.
+func (t *logTreeTX) QueueLeaves(leaves []*trillian.LogLeaf, queueTimestamp time.Time) ([]*trillian.LogLeaf, error) {
+	if err := t.checkWritable(); err != nil {
+		return nil, err
+	}
+	if err := storage.SetLeafIdentityHashes(t.tree.LeafIdentityHashStrategy, t.tree.LeafIdentityHashKey, leaves); err != nil {
+		return nil, err
+	}
+	// Don't accept batches if any of the leaves are invalid.
+	for _, leaf := range leaves {
+		if len(leaf.LeafIdentityHash) != t.hashSizeBytes {
+			return nil, fmt.Errorf("queued leaf must have a leaf ID hash of length %d", t.hashSizeBytes)
+		}
+	}
+	allowDuplicates := t.tree.DuplicatePolicy == trillian.DuplicatePolicy_DUPLICATES_ALLOWED
+
+	existingLeaves := make([]*trillian.LogLeaf, len(leaves))
+	var existingKeys []item
+	seen := make(map[string]bool)
+	entries := make([]*ddb.WriteRequest, 0, len(leaves))
+	for i, leaf := range leaves {
+		// A random suffix keeps the entries of duplicates queued at the same time apart.
+		var suffix []byte
+		if allowDuplicates {
+			suffix = make([]byte, 8)
+			if _, err := rand.Read(suffix); err != nil {
+				return nil, err
+			}
+		}
+		key := queueKey(queueTimestamp, leaf.LeafIdentityHash, suffix)
+
+		data := t.leafDataItem(leaf)
+		data["QueueKey"] = bytesAttr(key)
+		input := &ddb.PutItemInput{
+			TableName: t.ls.opts.table(leafDataTable),
+			Item:      data,
+		}
+		if !allowDuplicates {
+			input.ConditionExpression = aws.String("attribute_not_exists(LeafIdentityHash)")
+		}
+		_, err := t.ls.client.PutItemWithContext(t.ctx, input)
+		if isConditionFailed(err) {
+			reclaimed, err := t.reclaimLeafData(leaf, data)
+			if err != nil {
+				return nil, err
+			}
+			if !reclaimed {
+				existingLeaves[i] = leaf
+				if hash := string(leaf.LeafIdentityHash); !seen[hash] {
+					seen[hash] = true
+					existingKeys = append(existingKeys, t.leafDataKey(leaf.LeafIdentityHash))
+				}
+				continue
+			}
+		} else if err != nil {
+			glog.Warningf("Error inserting into LeafData: %s", err)
+			return nil, err
+		}
+
+		entry := item{
+			"TreeId":           numAttr(t.treeID),
+			"QueueKey":         bytesAttr(key),
+			"LeafIdentityHash": bytesAttr(leaf.LeafIdentityHash),
+		}
+		entry.setBytes("MerkleLeafHash", leaf.MerkleLeafHash)
+		entries = append(entries, &ddb.WriteRequest{PutRequest: &ddb.PutRequest{Item: entry}})
+	}
+	if err := batchWrite(t.ctx, t.ls.client, t.ls.opts.table(unsequencedTable), entries); err != nil {
+		glog.Warningf("Error inserting into Unsequenced: %s", err)
+		return nil, err
+	}
+	queuedCounter.Add(int64(len(entries)))
+
+	if len(existingKeys) == 0 {
+		return existingLeaves, nil
+	}
+
+	// Replace the requested leaves with the stored ones, whose MerkleLeafHash and
+	// LeafIndex aren't set.
+	items, err := batchGet(t.ctx, t.ls.client, t.ls.opts.table(leafDataTable), existingKeys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve existing leaves: %v", err)
+	}
+	existing := make(map[string]*trillian.LogLeaf)
+	for _, it := range items {
+		existing[string(it.getBytes("LeafIdentityHash"))] = &trillian.LogLeaf{
+			LeafIdentityHash: it.getBytes("LeafIdentityHash"),
+			LeafValue:        it.getBytes("LeafValue"),
+			ExtraData:        it.getBytes("ExtraData"),
+		}
+	}
+	for i, requested := range existingLeaves {
+		if requested == nil {
+			continue
+		}
+		leaf, ok := existing[string(requested.LeafIdentityHash)]
+		if !ok {
+			return nil, fmt.Errorf("failed to find existing leaf for hash %x", requested.LeafIdentityHash)
+		}
+		existingLeaves[i] = leaf
+	}
+	return existingLeaves, nil
+}
+
+// reclaimLeafData replaces the stored data of leaf, which is already there, with data and
+// returns true if the stored data was left by a QueueLeaves call which failed before
+// queueing the leaf: the queue entry it names doesn't exist, and the leaf hasn't been
+// sequenced, which is what deletes queue entries. Otherwise the leaf is a duplicate.
+// Leaves are looked up by Merkle hash through the eventually consistent index, but their
+// queue entries are only deleted after their root is written, well after the leaves.
+func (t *logTreeTX) reclaimLeafData(leaf *trillian.LogLeaf, data item) (bool, error) {
+	out, err := t.ls.client.GetItemWithContext(t.ctx, &ddb.GetItemInput{
+		TableName:      t.ls.opts.table(leafDataTable),
+		Key:            t.leafDataKey(leaf.LeafIdentityHash),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return false, err
+	}
+	// Data written before queue keys were recorded can't be checked, so is taken to be
+	// a duplicate's.
+	storedKey := item(out.Item).getBytes("QueueKey")
+	if len(storedKey) == 0 {
+		return false, nil
+	}
+
+	entry, err := t.ls.client.GetItemWithContext(t.ctx, &ddb.GetItemInput{
+		TableName:      t.ls.opts.table(unsequencedTable),
+		Key:            item{"TreeId": numAttr(t.treeID), "QueueKey": bytesAttr(storedKey)},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return false, err
+	}
+	if len(entry.Item) > 0 {
+		return false, nil
+	}
+	sequenced, err := t.GetLeavesByHash([][]byte{leaf.MerkleLeafHash}, false)
+	if err != nil {
+		return false, err
+	}
+	for _, s := range sequenced {
+		if bytes.Equal(s.LeafIdentityHash, leaf.LeafIdentityHash) {
+			return false, nil
+		}
+	}
+
+	// Should another call reclaim the data first, the leaf is queued by that one.
+	_, err = t.ls.client.PutItemWithContext(t.ctx, &ddb.PutItemInput{
+		TableName:                 t.ls.opts.table(leafDataTable),
+		Item:                      data,
+		ConditionExpression:       aws.String("QueueKey = :old"),
+		ExpressionAttributeValues: item{":old": bytesAttr(storedKey)},
+	})
+	if isConditionFailed(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (t *logTreeTX) leafDataKey(leafIdentityHash []byte) item {
+	return item{"TreeId": numAttr(t.treeID), "LeafIdentityHash": bytesAttr(leafIdentityHash)}
+}
+
+func (t *logTreeTX) leafDataItem(leaf *trillian.LogLeaf) item {
+	it := t.leafDataKey(leaf.LeafIdentityHash)
+	it.setBytes("LeafValue", leaf.LeafValue)
+	it.setBytes("ExtraData", leaf.ExtraData)
+	return it
+}
+
+func (t *logTreeTX) sequencedLeafKey(index int64) item {
+	return item{"TreeId": numAttr(t.treeID), "SequenceNumber": numAttr(index)}
+}
+
+func (t *logTreeTX) sequencedLeafItem(leaf *trillian.LogLeaf) item {
+	it := t.sequencedLeafKey(leaf.LeafIndex)
+	it["LeafIdentityHash"] = bytesAttr(leaf.LeafIdentityHash)
+	it["MerkleKey"] = bytesAttr(merkleKey(t.treeID, leaf.MerkleLeafHash))
+	it.setBytes("MerkleLeafHash", leaf.MerkleLeafHash)
+	return it
+}
+
+// AddSequencedLeaves stores leaves that already carry their final LeafIndex.
+func (t *logTreeTX) AddSequencedLeaves(leaves []*trillian.LogLeaf) error {
+	if t.tree.TreeType != trillian.TreeType_PREORDERED_LOG {
+		return fmt.Errorf("AddSequencedLeaves called on tree %v of type %v, want %v", t.treeID, t.tree.TreeType, trillian.TreeType_PREORDERED_LOG)
+	}
+	if err := t.checkWritable(); err != nil {
+		return err
+	}
+	if err := storage.SetLeafIdentityHashes(t.tree.LeafIdentityHashStrategy, t.tree.LeafIdentityHashKey, leaves); err != nil {
+		return err
+	}
+	for _, leaf := range leaves {
+		if len(leaf.LeafIdentityHash) != t.hashSizeBytes {
+			return fmt.Errorf("sequenced leaf must have a leaf ID hash of length %d", t.hashSizeBytes)
+		}
+		if leaf.LeafIndex < t.root.TreeSize {
+			return storage.Error{ErrType: storage.OutOfRange, Detail: fmt.Sprintf("sequenced leaf index %d is below the current tree size %d", leaf.LeafIndex, t.root.TreeSize)}
+		}
+	}
+
+	// The order of the tree is decided by the submitter, who may well have legitimate
+	// duplicates, so the leaf data is shared.
+	data := make([]*ddb.WriteRequest, 0, len(leaves))
+	seen := make(map[string]bool)
+	for _, leaf := range leaves {
+		if hash := string(leaf.LeafIdentityHash); !seen[hash] {
+			seen[hash] = true
+			data = append(data, &ddb.WriteRequest{PutRequest: &ddb.PutRequest{Item: t.leafDataItem(leaf)}})
+		}
+	}
+	if err := batchWrite(t.ctx, t.ls.client, t.ls.opts.table(leafDataTable), data); err != nil {
+		glog.Warningf("Error inserting sequenced leaves into LeafData: %s", err)
+		return err
+	}
+	for _, leaf := range leaves {
+		_, err := t.ls.client.PutItemWithContext(t.ctx, &ddb.PutItemInput{
+			TableName:           t.ls.opts.table(sequencedLeavesTable),
+			Item:                t.sequencedLeafItem(leaf),
+			ConditionExpression: aws.String("attribute_not_exists(SequenceNumber)"),
+		})
+		if isConditionFailed(err) {
+			return storage.Error{ErrType: storage.DuplicateLeaf, Detail: fmt.Sprintf("a leaf already exists at index %d", leaf.LeafIndex)}
+		} else if err != nil {
+			glog.Warningf("Error inserting sequenced leaves into SequencedLeaves: %s", err)
+			return err
+		}
+	}
+
+	queuedCounter.Add(int64(len(leaves)))
+	return nil
+}
+
+// DequeueLeaves returns the oldest leaves queued before cutoffTime. Their queue entries
+// are deleted once the transaction's root is written, see Commit.
+func (t *logTreeTX) DequeueLeaves(limit int, cutoffTime time.Time) ([]*trillian.LogLeaf, error) {
+	if t.tree.TreeType == trillian.TreeType_PREORDERED_LOG {
+		return t.dequeuePreorderedLeaves(limit)
+	}
+
+	out, err := t.ls.client.QueryWithContext(t.ctx, &ddb.QueryInput{
+		TableName:              t.ls.opts.table(unsequencedTable),
+		KeyConditionExpression: aws.String("TreeId = :t AND QueueKey < :cutoff"),
+		ExpressionAttributeValues: item{
+			":t":      numAttr(t.treeID),
+			":cutoff": bytesAttr(queueTimeKey(cutoffTime.Add(time.Nanosecond))),
+		},
+		Limit:          aws.Int64(int64(limit)),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		glog.Warningf("Failed to select rows for work: %s", err)
+		return nil, err
+	}
+
+	leaves := make([]*trillian.LogLeaf, 0, len(out.Items))
+	for _, entry := range out.Items {
+		entry := item(entry)
+		// An entry marked as sequenced by the pass which wrote a committed root wasn't
+		// deleted after the root was, so just finish that off. Entries marked by a pass
+		// whose root was never written are still to be sequenced.
+		if _, ok := entry["SequencedRevision"]; ok {
+			rev, err := entry.getInt("SequencedRevision")
+			if err != nil {
+				return nil, err
+			}
+			if rev <= t.root.TreeRevision {
+				sequenced, err := t.sequencedBy(rev, entry.getBytes("SequencedPass"))
+				if err != nil {
+					return nil, err
+				}
+				if sequenced {
+					t.sequenced = append(t.sequenced, entry)
+					continue
+				}
+			}
+		}
+		leafIDHash := entry.getBytes("LeafIdentityHash")
+		if len(leafIDHash) != t.hashSizeBytes {
+			return nil, fmt.Errorf("dequeued a leaf with incorrect hash size")
+		}
+		// Note: the LeafData and ExtraData being nil here is OK as this is only used by
+		// the sequencer, see the MySQL storage.
+		leaves = append(leaves, &trillian.LogLeaf{
+			LeafIdentityHash: leafIDHash,
+			MerkleLeafHash:   entry.getBytes("MerkleLeafHash"),
+		})
+		t.dequeued = append(t.dequeued, entry)
+	}
+
+	dequeuedCounter.Add(int64(len(leaves)))
+	return leaves, nil
+}
+
+// sequencedBy returns whether the root at revision rev was written by the sequencing
+// pass with ID pass. The IDs are cached for the transaction.
+func (t *logTreeTX) sequencedBy(rev int64, pass []byte) (bool, error) {
+	rootPass, ok := t.rootPasses[rev]
+	if !ok {
+		out, err := t.ls.client.GetItemWithContext(t.ctx, &ddb.GetItemInput{
+			TableName:      t.ls.opts.table(treeHeadsTable),
+			Key:            item{"TreeId": numAttr(t.treeID), "TreeRevision": numAttr(rev)},
+			ConsistentRead: aws.Bool(true),
+		})
+		if err != nil {
+			return false, err
+		}
+		if len(out.Item) == 0 {
+			return false, nil
+		}
+		rootPass = item(out.Item).getBytes("PassId")
+		if t.rootPasses == nil {
+			t.rootPasses = make(map[int64][]byte)
+		}
+		t.rootPasses[rev] = rootPass
+	}
+	return bytes.Equal(rootPass, pass), nil
+}
+
+// dequeuePreorderedLeaves returns the contiguous run of leaves, stored by AddSequencedLeaves,
+// that starts at the current tree size.
+func (t *logTreeTX) dequeuePreorderedLeaves(limit int) ([]*trillian.LogLeaf, error) {
+	out, err := t.ls.client.QueryWithContext(t.ctx, &ddb.QueryInput{
+		TableName:              t.ls.opts.table(sequencedLeavesTable),
+		KeyConditionExpression: aws.String("TreeId = :t AND SequenceNumber >= :size"),
+		ExpressionAttributeValues: item{
+			":t":    numAttr(t.treeID),
+			":size": numAttr(t.root.TreeSize),
+		},
+		Limit:          aws.Int64(int64(limit)),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		glog.Warningf("Failed to select pre-ordered leaves: %s", err)
+		return nil, err
+	}
+
+	leaves := make([]*trillian.LogLeaf, 0, len(out.Items))
+	next := t.root.TreeSize
+	for _, it := range out.Items {
+		leaf, err := readSequencedLeaf(it)
+		if err != nil {
+			return nil, err
+		}
+		if leaf.LeafIndex != next {
+			break
+		}
+		leaves = append(leaves, leaf)
+		next++
+	}
+
+	dequeuedCounter.Add(int64(len(leaves)))
+	return leaves, nil
+}
+
+// UpdateSequencedLeaves writes the leaves at their indexes. Leaves left by a failed pass,
+// at the write revision or beyond the size of a committed one, are overwritten, any
+// other leaf already at an index means another sequencer is running, and aborts the
+// transaction.
+func (t *logTreeTX) UpdateSequencedLeaves(leaves []*trillian.LogLeaf) error {
+	for _, leaf := range leaves {
+		// This should fail on insert but catch it early
+		if len(leaf.LeafIdentityHash) != t.hashSizeBytes {
+			return fmt.Errorf("sequenced leaf has incorrect hash size")
+		}
+		if leaf.LeafIndex < t.root.TreeSize {
+			return fmt.Errorf("sequenced leaf index %d is below the tree size %d", leaf.LeafIndex, t.root.TreeSize)
+		}
+	}
+	for _, leaf := range leaves {
+		it := t.sequencedLeafItem(leaf)
+		it["TreeRevision"] = numAttr(t.writeRevision)
+		_, err := t.ls.client.PutItemWithContext(t.ctx, &ddb.PutItemInput{
+			TableName:                 t.ls.opts.table(sequencedLeavesTable),
+			Item:                      it,
+			ConditionExpression:       aws.String("attribute_not_exists(SequenceNumber) OR TreeRevision = :rev OR TreeRevision <= :read"),
+			ExpressionAttributeValues: item{":rev": numAttr(t.writeRevision), ":read": numAttr(t.root.TreeRevision)},
+		})
+		if isConditionFailed(err) {
+			return errors.Errorf(errors.Aborted, "leaf %d of tree %v was sequenced concurrently", leaf.LeafIndex, t.treeID)
+		} else if err != nil {
+			glog.Warningf("Failed to update sequenced leaves: %s", err)
+			return err
+		}
+	}
+	return nil
+}
+
+func readSequencedLeaf(it item) (*trillian.LogLeaf, error) {
+	index, err := it.getInt("SequenceNumber")
+	if err != nil {
+		return nil, err
+	}
+	return &trillian.LogLeaf{
+		LeafIndex:        index,
+		LeafIdentityHash: it.getBytes("LeafIdentityHash"),
+		MerkleLeafHash:   it.getBytes("MerkleLeafHash"),
+	}, nil
+}
+
+// addLeafData fills in the data of leaves, read by LeafIdentityHash.
+func (t *logTreeTX) addLeafData(leaves []*trillian.LogLeaf) error {
+	var keys []item
+	seen := make(map[string]bool)
+	for _, leaf := range leaves {
+		if hash := string(leaf.LeafIdentityHash); !seen[hash] {
+			seen[hash] = true
+			keys = append(keys, t.leafDataKey(leaf.LeafIdentityHash))
+		}
+	}
+	items, err := batchGet(t.ctx, t.ls.client, t.ls.opts.table(leafDataTable), keys)
+	if err != nil {
+		return err
+	}
+	data := make(map[string]item)
+	for _, it := range items {
+		data[string(it.getBytes("LeafIdentityHash"))] = it
+	}
+	for _, leaf := range leaves {
+		it, ok := data[string(leaf.LeafIdentityHash)]
+		if !ok {
+			return fmt.Errorf("no data for leaf %d", leaf.LeafIndex)
+		}
+		leaf.LeafValue = it.getBytes("LeafValue")
+		leaf.ExtraData = it.getBytes("ExtraData")
+	}
+	return nil
+}
+
+func (t *logTreeTX) GetLeavesByIndex(leaves []int64) ([]*trillian.LogLeaf, error) {
+	var keys []item
+	seen := make(map[int64]bool)
+	for _, index := range leaves {
+		if !seen[index] {
+			seen[index] = true
+			keys = append(keys, t.sequencedLeafKey(index))
+		}
+	}
+	items, err := batchGet(t.ctx, t.ls.client, t.ls.opts.table(sequencedLeavesTable), keys)
+	if err != nil {
+		glog.Warningf("Failed to get leaves by idx: %s", err)
+		return nil, err
+	}
+	byIndex := make(map[int64]item)
+	for _, it := range items {
+		index, err := it.getInt("SequenceNumber")
+		if err != nil {
+			return nil, err
+		}
+		byIndex[index] = it
+	}
+
+	ret := make([]*trillian.LogLeaf, 0, len(leaves))
+	for _, index := range leaves {
+		it, ok := byIndex[index]
+		if !ok {
+			if index >= t.root.TreeSize {
+				return nil, storage.Error{ErrType: storage.OutOfRange, Detail: fmt.Sprintf("leaf index %d is beyond the tree size %d", index, t.root.TreeSize)}
+			}
+			return nil, fmt.Errorf("leaf %d of tree %v is missing", index, t.treeID)
+		}
+		leaf, err := readSequencedLeaf(it)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, leaf)
+	}
+	if err := t.addLeafData(ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (t *logTreeTX) GetLeavesByHash(leafHashes [][]byte, orderBySequence bool) ([]*trillian.LogLeaf, error) {
+	var ret []*trillian.LogLeaf
+	for _, hash := range leafHashes {
+		err := t.ls.client.QueryPagesWithContext(t.ctx, &ddb.QueryInput{
+			TableName:                 t.ls.opts.table(sequencedLeavesTable),
+			IndexName:                 aws.String(merkleHashIndex),
+			KeyConditionExpression:    aws.String("MerkleKey = :k"),
+			ExpressionAttributeValues: item{":k": bytesAttr(merkleKey(t.treeID, hash))},
+		}, func(out *ddb.QueryOutput, last bool) bool {
+			for _, it := range out.Items {
+				leaf, err := readSequencedLeaf(it)
+				if err != nil {
+					glog.Warningf("Failed to read leaf by merkle hash: %s", err)
+					continue
+				}
+				ret = append(ret, leaf)
+			}
+			return true
+		})
+		if err != nil {
+			glog.Warningf("Failed to get leaves by merkle hash: %s", err)
+			return nil, err
+		}
+	}
+	if len(ret) == 0 {
+		return ret, nil
+	}
+	if err := t.addLeafData(ret); err != nil {
+		return nil, err
+	}
+	if orderBySequence {
+		sort.Slice(ret, func(i, j int) bool { return ret[i].LeafIndex < ret[j].LeafIndex })
+	}
+	return ret, nil
+}
+
+// count returns the number of items in table for the tree, and the first of them.
+func (t *logTreeTX) count(table string) (int64, item, error) {
+	var count int64
+	var first item
+	err := t.ls.client.QueryPagesWithContext(t.ctx, &ddb.QueryInput{
+		TableName:                 t.ls.opts.table(table),
+		KeyConditionExpression:    aws.String("TreeId = :t"),
+		ExpressionAttributeValues: item{":t": numAttr(t.treeID)},
+		ConsistentRead:            aws.Bool(true),
+	}, func(out *ddb.QueryOutput, last bool) bool {
+		if first == nil && len(out.Items) > 0 {
+			first = out.Items[0]
+		}
+		count += aws.Int64Value(out.Count)
+		return true
+	})
+	return count, first, err
+}
+
+func (t *logTreeTX) GetSequencedLeafCount() (int64, error) {
+	count, _, err := t.count(sequencedLeavesTable)
+	if err != nil {
+		glog.Warningf("Error getting sequenced leaf count: %s", err)
+	}
+	return count, err
+}
+
+func (t *logTreeTX) GetUnsequencedStats() (int64, time.Time, error) {
+	count, oldest, err := t.count(unsequencedTable)
+	if err != nil {
+		glog.Warningf("Error getting unsequenced leaf stats: %s", err)
+		return 0, time.Time{}, err
+	}
+	if key := oldest.getBytes("QueueKey"); len(key) >= 8 {
+		return count, time.Unix(0, int64(binary.BigEndian.Uint64(key))), nil
+	}
+	return count, time.Time{}, nil
+}
+
+func (t *logTreeTX) GetStorageUsage() (storage.StorageUsage, error) {
+	return storage.StorageUsage{}, errors.Errorf(errors.Unimplemented, "DynamoDB storage doesn't account for storage usage")
+}
+
+func (t *logTreeTX) FinalizedTreeSize() (int64, bool, error) {
+	return t.tree.FinalizedTreeSize, t.tree.FinalizeTimeMillisSinceEpoch != 0, nil
+}
+
+func (t *logTreeTX) LatestSignedLogRoot() (trillian.SignedLogRoot, error) {
+	return t.root, nil
+}
+
+// fetchLatestRoot reads the latest SignedLogRoot, or returns a zero one if there are
+// no roots for the tree yet.
+func (t *logTreeTX) fetchLatestRoot() (trillian.SignedLogRoot, error) {
+	out, err := t.ls.client.QueryWithContext(t.ctx, &ddb.QueryInput{
+		TableName:                 t.ls.opts.table(treeHeadsTable),
+		KeyConditionExpression:    aws.String("TreeId = :t"),
+		ExpressionAttributeValues: item{":t": numAttr(t.treeID)},
+		ScanIndexForward:          aws.Bool(false),
+		Limit:                     aws.Int64(1),
+		ConsistentRead:            aws.Bool(true),
+	})
+	if err != nil {
+		return trillian.SignedLogRoot{}, err
+	}
+	if len(out.Items) == 0 {
+		return trillian.SignedLogRoot{}, nil
+	}
+	return t.readRoot(out.Items[0])
+}
+
+// SignedLogRootAtSize returns the latest SignedLogRoot of size treeSize which was
+// published by the time the transaction's root was, or a zero one if there is none.
+func (t *logTreeTX) SignedLogRootAtSize(treeSize int64) (trillian.SignedLogRoot, error) {
+	var found item
+	err := t.ls.client.QueryPagesWithContext(t.ctx, &ddb.QueryInput{
+		TableName:                 t.ls.opts.table(treeHeadsTable),
+		KeyConditionExpression:    aws.String("TreeId = :t AND TreeRevision <= :r"),
+		FilterExpression:          aws.String("TreeSize = :s"),
+		ExpressionAttributeValues: item{":t": numAttr(t.treeID), ":r": numAttr(t.root.TreeRevision), ":s": numAttr(treeSize)},
+		ScanIndexForward:          aws.Bool(false),
+		ConsistentRead:            aws.Bool(true),
+	}, func(out *ddb.QueryOutput, last bool) bool {
+		if len(out.Items) > 0 {
+			found = out.Items[0]
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return trillian.SignedLogRoot{}, err
+	}
+	if found == nil {
+		return trillian.SignedLogRoot{}, nil
+	}
+	return t.readRoot(found)
+}
+
+// readRoot returns the SignedLogRoot held in a TreeHeads item.
+func (t *logTreeTX) readRoot(it item) (trillian.SignedLogRoot, error) {
+	var rootSignature spb.DigitallySigned
+	if err := proto.Unmarshal(it.getBytes("RootSignature"), &rootSignature); err != nil {
+		glog.Warningf("Failed to unmarshall root signature: %v", err)
+		return trillian.SignedLogRoot{}, err
+	}
+	root := trillian.SignedLogRoot{
+		RootHash:  it.getBytes("RootHash"),
+		Signature: &rootSignature,
+		LogId:     t.treeID,
+	}
+	for name, v := range map[string]*int64{"TimestampNanos": &root.TimestampNanos, "TreeSize": &root.TreeSize, "TreeRevision": &root.TreeRevision} {
+		if *v, err = it.getInt(name); err != nil {
+			return trillian.SignedLogRoot{}, err
+		}
+	}
+	return root, nil
+}
+
+// StoreSignedLogRoot keeps root to be written on Commit.
+func (t *logTreeTX) StoreSignedLogRoot(root trillian.SignedLogRoot) error {
+	t.newRoot = &root
+	return nil
+}
+
+func (t *logTreeTX) StoreWitnessSignature(treeRevision int64, sig *trillian.WitnessSignature) error {
+	return errors.Errorf(errors.Unimplemented, "DynamoDB storage doesn't support witness signatures")
+}
+
+func (t *logTreeTX) StoreObservedRoot(root trillian.SignedLogRoot, consistent bool, observedAt time.Time) error {
+	return errors.Errorf(errors.Unimplemented, "DynamoDB storage doesn't support observed roots")
+}
+
+func (t *logTreeTX) LatestWitnessedSignedLogRoot(witnesses []string, quorum int) (trillian.SignedLogRoot, []*trillian.WitnessSignature, error) {
+	if len(witnesses) == 0 {
+		return trillian.SignedLogRoot{}, nil, nil
+	}
+	return trillian.SignedLogRoot{}, nil, errors.Errorf(errors.Unimplemented, "DynamoDB storage doesn't support witness signatures")
+}
+
+func (t *logTreeTX) GetActiveLogIDs() ([]int64, error) {
+	return t.ls.getActiveLogIDs(t.ctx, false)
+}
+
+func (t *logTreeTX) GetActiveLogIDsWithPendingWork() ([]int64, error) {
+	return t.ls.getActiveLogIDs(t.ctx, true)
+}
+
+// Commit writes the subtrees, marks the dequeued entries as sequenced at the write
+// revision by this pass, then writes the root, if one was stored, with the pass's ID.
+// That makes the revision visible, so the dequeued entries can be deleted. Should that
+// fail, the next transaction to dequeue them finds they've been sequenced and deletes
+// them instead. If writing the root fails, a later pass at the same revision may
+// sequence other leaves, so marks only count if the root at their revision has their
+// pass's ID.
+func (t *logTreeTX) Commit() error {
+	t.closed = true
+	if err := t.subtreeCache.Flush(t.storeSubtrees); err != nil {
+		glog.Warningf("TX commit flush error: %v", err)
+		return err
+	}
+	if t.newRoot == nil {
+		return nil
+	}
+
+	pass := make([]byte, 8)
+	if _, err := rand.Read(pass); err != nil {
+		return err
+	}
+	if len(t.dequeued) > 0 {
+		marks := make([]*ddb.WriteRequest, 0, len(t.dequeued))
+		for _, entry := range t.dequeued {
+			entry["SequencedRevision"] = numAttr(t.writeRevision)
+			entry["SequencedPass"] = bytesAttr(pass)
+			marks = append(marks, &ddb.WriteRequest{PutRequest: &ddb.PutRequest{Item: entry}})
+		}
+		if err := batchWrite(t.ctx, t.ls.client, t.ls.opts.table(unsequencedTable), marks); err != nil {
+			glog.Warningf("Failed to mark dequeued leaves: %s", err)
+			return err
+		}
+	}
+
+	signatureBytes, err := proto.Marshal(t.newRoot.Signature)
+	if err != nil {
+		glog.Warningf("Failed to marshal root signature: %v %v", t.newRoot.Signature, err)
+		return err
+	}
+	root := item{
+		"TreeId":         numAttr(t.treeID),
+		"TreeRevision":   numAttr(t.newRoot.TreeRevision),
+		"TimestampNanos": numAttr(t.newRoot.TimestampNanos),
+		"TreeSize":       numAttr(t.newRoot.TreeSize),
+	}
+	root.setBytes("RootHash", t.newRoot.RootHash)
+	root.setBytes("RootSignature", signatureBytes)
+	root["PassId"] = bytesAttr(pass)
+	_, err = t.ls.client.PutItemWithContext(t.ctx, &ddb.PutItemInput{
+		TableName:           t.ls.opts.table(treeHeadsTable),
+		Item:                root,
+		ConditionExpression: aws.String("attribute_not_exists(TreeRevision)"),
+	})
+	if isConditionFailed(err) {
+		return errors.Errorf(errors.Aborted, "tree %v already has a root at revision %d", t.treeID, t.newRoot.TreeRevision)
+	} else if err != nil {
+		glog.Warningf("Failed to store signed root: %s", err)
+		return err
+	}
+
+	deletes := make([]*ddb.WriteRequest, 0, len(t.dequeued)+len(t.sequenced))
+	for _, entry := range append(t.dequeued, t.sequenced...) {
+		key := item{"TreeId": entry["TreeId"], "QueueKey": entry["QueueKey"]}
+		deletes = append(deletes, &ddb.WriteRequest{DeleteRequest: &ddb.DeleteRequest{Key: key}})
+	}
+	if err := batchWrite(t.ctx, t.ls.client, t.ls.opts.table(unsequencedTable), deletes); err != nil {
+		// The root is written, so the transaction succeeded regardless.
+		glog.Warningf("Failed to delete sequenced leaves from the queue: %s", err)
+	}
+	return nil
+}
+
+func (t *logTreeTX) Rollback() error {
+	t.closed = true
+	return nil
+}
+
+func (t *logTreeTX) Close() error {
+	t.closed = true
+	return nil
+}
+
+func (t *logTreeTX) IsOpen() bool {
+	return !t.closed
+}
@@ -0,0 +1,311 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynamodb
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	spb "github.com/google/trillian/crypto/sigpb"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/testonly"
+)
+
+var errInjected = errors.New("injected failure")
+
+// newTestLog returns log storage over client holding a new, empty LOG tree.
+func newTestLog(t *testing.T, client *fakeClient) (storage.LogStorage, int64) {
+	ctx := context.Background()
+	tx, err := NewAdminStorage(client, Options{}).Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin()=%v", err)
+	}
+	defer tx.Close()
+	tree, err := tx.CreateTree(ctx, testonly.LogTree)
+	if err != nil {
+		t.Fatalf("CreateTree()=%v", err)
+	}
+	return NewLogStorage(client, Options{}), tree.TreeId
+}
+
+func testLeaves(first, n int) []*trillian.LogLeaf {
+	leaves := make([]*trillian.LogLeaf, 0, n)
+	for i := first; i < first+n; i++ {
+		value := []byte(fmt.Sprintf("Leaf %d", i))
+		hash := sha256.Sum256(value)
+		leaves = append(leaves, &trillian.LogLeaf{LeafIdentityHash: hash[:], MerkleLeafHash: hash[:], LeafValue: value})
+	}
+	return leaves
+}
+
+func queueLeaves(t *testing.T, s storage.LogStorage, treeID int64, leaves []*trillian.LogLeaf) ([]*trillian.LogLeaf, error) {
+	tx, err := s.BeginForTree(context.Background(), treeID)
+	if err != nil {
+		t.Fatalf("BeginForTree()=%v", err)
+	}
+	defer tx.Close()
+	existing, err := tx.QueueLeaves(leaves, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return existing, tx.Commit()
+}
+
+// sequence runs a sequencing pass over at most limit queued leaves, as the sequencer
+// would, and returns the leaves and the error of Commit.
+func sequence(t *testing.T, s storage.LogStorage, treeID int64, limit int) ([]*trillian.LogLeaf, error) {
+	tx, err := s.BeginForTree(context.Background(), treeID)
+	if err != nil {
+		t.Fatalf("BeginForTree()=%v", err)
+	}
+	defer tx.Close()
+	root, err := tx.LatestSignedLogRoot()
+	if err != nil {
+		t.Fatalf("LatestSignedLogRoot()=%v", err)
+	}
+	leaves, err := tx.DequeueLeaves(limit, time.Now())
+	if err != nil {
+		t.Fatalf("DequeueLeaves()=%v", err)
+	}
+	for i, leaf := range leaves {
+		leaf.LeafIndex = root.TreeSize + int64(i)
+	}
+	if err := tx.UpdateSequencedLeaves(leaves); err != nil {
+		t.Fatalf("UpdateSequencedLeaves()=%v", err)
+	}
+	newRoot := trillian.SignedLogRoot{
+		TreeRevision:   tx.WriteRevision(),
+		TreeSize:       root.TreeSize + int64(len(leaves)),
+		TimestampNanos: time.Now().UnixNano(),
+		Signature:      &spb.DigitallySigned{},
+	}
+	if err := tx.StoreSignedLogRoot(newRoot); err != nil {
+		t.Fatalf("StoreSignedLogRoot()=%v", err)
+	}
+	return leaves, tx.Commit()
+}
+
+func queuedCount(t *testing.T, client *fakeClient) int {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return len(client.table(unsequencedTable))
+}
+
+func leafValues(leaves []*trillian.LogLeaf) []string {
+	values := make([]string, 0, len(leaves))
+	for _, leaf := range leaves {
+		if leaf == nil {
+			values = append(values, "")
+			continue
+		}
+		values = append(values, string(leaf.LeafValue))
+	}
+	return values
+}
+
+func TestQueueLeavesDuplicates(t *testing.T) {
+	client := newFakeClient()
+	s, treeID := newTestLog(t, client)
+
+	existing, err := queueLeaves(t, s, treeID, testLeaves(0, 3))
+	if err != nil {
+		t.Fatalf("QueueLeaves()=%v", err)
+	}
+	if got, want := fmt.Sprint(leafValues(existing)), "[  ]"; got != want {
+		t.Errorf("QueueLeaves() existing=%v, want %v", got, want)
+	}
+
+	// Duplicates are returned, whether still queued or sequenced.
+	if _, err := sequence(t, s, treeID, 1); err != nil {
+		t.Fatalf("sequence()=%v", err)
+	}
+	existing, err = queueLeaves(t, s, treeID, testLeaves(0, 4))
+	if err != nil {
+		t.Fatalf("QueueLeaves()=%v", err)
+	}
+	if got, want := fmt.Sprint(leafValues(existing)), "[Leaf 0 Leaf 1 Leaf 2 ]"; got != want {
+		t.Errorf("QueueLeaves() existing=%v, want %v", got, want)
+	}
+	if got, want := queuedCount(t, client), 3; got != want {
+		t.Errorf("%d leaves queued, want %d", got, want)
+	}
+}
+
+func TestQueueLeavesRetriedAfterFailure(t *testing.T) {
+	client := newFakeClient()
+	s, treeID := newTestLog(t, client)
+
+	// The leaf data is written, but not the queue entry.
+	client.fail = func(op, table string) error {
+		if op == "BatchWriteItem" && table == unsequencedTable {
+			return errInjected
+		}
+		return nil
+	}
+	if _, err := queueLeaves(t, s, treeID, testLeaves(0, 1)); err != errInjected {
+		t.Fatalf("QueueLeaves()=%v, want %v", err, errInjected)
+	}
+	client.fail = nil
+
+	existing, err := queueLeaves(t, s, treeID, testLeaves(0, 1))
+	if err != nil {
+		t.Fatalf("QueueLeaves()=%v", err)
+	}
+	if existing[0] != nil {
+		t.Errorf("QueueLeaves() after a failure returned the leaf as a duplicate")
+	}
+	leaves, err := sequence(t, s, treeID, 10)
+	if err != nil {
+		t.Fatalf("sequence()=%v", err)
+	}
+	if want := testLeaves(0, 1)[0].MerkleLeafHash; len(leaves) != 1 || !bytes.Equal(leaves[0].MerkleLeafHash, want) {
+		t.Errorf("sequenced %d leaves, want the one queued", len(leaves))
+	}
+
+	// Once sequenced, the leaf is a duplicate again.
+	existing, err = queueLeaves(t, s, treeID, testLeaves(0, 1))
+	if err != nil {
+		t.Fatalf("QueueLeaves()=%v", err)
+	}
+	if existing[0] == nil {
+		t.Errorf("QueueLeaves() of a sequenced leaf queued it again")
+	}
+}
+
+func TestSequenceInBatches(t *testing.T) {
+	client := newFakeClient()
+	s, treeID := newTestLog(t, client)
+	if _, err := queueLeaves(t, s, treeID, testLeaves(0, 3)); err != nil {
+		t.Fatalf("QueueLeaves()=%v", err)
+	}
+
+	var sequenced []*trillian.LogLeaf
+	for _, limit := range []int{2, 2, 2} {
+		leaves, err := sequence(t, s, treeID, limit)
+		if err != nil {
+			t.Fatalf("sequence(%d)=%v", limit, err)
+		}
+		sequenced = append(sequenced, leaves...)
+	}
+	if got, want := len(sequenced), 3; got != want {
+		t.Errorf("sequenced %d leaves, want %d", got, want)
+	}
+	if got := queuedCount(t, client); got != 0 {
+		t.Errorf("%d leaves left queued, want 0", got)
+	}
+
+	tx, err := s.SnapshotForTree(context.Background(), treeID)
+	if err != nil {
+		t.Fatalf("SnapshotForTree()=%v", err)
+	}
+	defer tx.Close()
+	root, err := tx.LatestSignedLogRoot()
+	if err != nil {
+		t.Fatalf("LatestSignedLogRoot()=%v", err)
+	}
+	if root.TreeSize != 3 || root.TreeRevision != 3 {
+		t.Errorf("LatestSignedLogRoot()=size %d, revision %d, want 3, 3", root.TreeSize, root.TreeRevision)
+	}
+	leaves, err := tx.GetLeavesByIndex([]int64{0, 1, 2})
+	if err != nil {
+		t.Fatalf("GetLeavesByIndex()=%v", err)
+	}
+	for i, leaf := range leaves {
+		if !bytes.Equal(leaf.MerkleLeafHash, sequenced[i].MerkleLeafHash) || len(leaf.LeafValue) == 0 {
+			t.Errorf("GetLeavesByIndex()[%d]=%x %q, want %x and its value", i, leaf.MerkleLeafHash, leaf.LeafValue, sequenced[i].MerkleLeafHash)
+		}
+	}
+}
+
+func TestSequenceAfterRootFailure(t *testing.T) {
+	client := newFakeClient()
+	s, treeID := newTestLog(t, client)
+	if _, err := queueLeaves(t, s, treeID, testLeaves(0, 4)); err != nil {
+		t.Fatalf("QueueLeaves()=%v", err)
+	}
+
+	// The first pass marks all the leaves, but fails to write its root.
+	client.fail = func(op, table string) error {
+		if op == "PutItem" && table == treeHeadsTable {
+			return errInjected
+		}
+		return nil
+	}
+	if _, err := sequence(t, s, treeID, 4); err != errInjected {
+		t.Fatalf("sequence()=%v, want %v", err, errInjected)
+	}
+	client.fail = nil
+
+	// A retry at the same revision takes fewer leaves, the others are still queued.
+	var sequenced []*trillian.LogLeaf
+	for _, limit := range []int{2, 10, 10} {
+		leaves, err := sequence(t, s, treeID, limit)
+		if err != nil {
+			t.Fatalf("sequence(%d)=%v", limit, err)
+		}
+		sequenced = append(sequenced, leaves...)
+	}
+	if got, want := len(sequenced), 4; got != want {
+		t.Errorf("sequenced %d leaves after a failed pass, want %d", got, want)
+	}
+	if got := queuedCount(t, client); got != 0 {
+		t.Errorf("%d leaves left queued, want 0", got)
+	}
+}
+
+func TestSequenceAfterDeleteFailure(t *testing.T) {
+	client := newFakeClient()
+	s, treeID := newTestLog(t, client)
+	if _, err := queueLeaves(t, s, treeID, testLeaves(0, 2)); err != nil {
+		t.Fatalf("QueueLeaves()=%v", err)
+	}
+
+	// The root is written, but deleting the queue entries, which follows marking them,
+	// fails.
+	writes := 0
+	client.fail = func(op, table string) error {
+		if op == "BatchWriteItem" && table == unsequencedTable {
+			if writes++; writes > 1 {
+				return errInjected
+			}
+		}
+		return nil
+	}
+	if _, err := sequence(t, s, treeID, 10); err != nil {
+		t.Fatalf("sequence()=%v, want nil", err)
+	}
+	client.fail = nil
+	if got, want := queuedCount(t, client), 2; got != want {
+		t.Fatalf("%d leaves queued, want %d", got, want)
+	}
+
+	// The next pass doesn't sequence them again, but deletes them.
+	leaves, err := sequence(t, s, treeID, 10)
+	if err != nil {
+		t.Fatalf("sequence()=%v", err)
+	}
+	if len(leaves) != 0 {
+		t.Errorf("sequenced %d leaves again, want 0", len(leaves))
+	}
+	if got := queuedCount(t, client); got != 0 {
+		t.Errorf("%d leaves left queued, want 0", got)
+	}
+}
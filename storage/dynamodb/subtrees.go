@@ -0,0 +1,139 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynamodb
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	ddb "github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/storagepb"
+)
+
+func (t *logTreeTX) getSubtree(treeRevision int64, nodeID storage.NodeID) (*storagepb.SubtreeProto, error) {
+	s, err := t.getSubtrees(treeRevision, []storage.NodeID{nodeID})
+	if err != nil {
+		return nil, err
+	}
+	switch len(s) {
+	case 0:
+		return nil, nil
+	case 1:
+		return s[0], nil
+	default:
+		return nil, fmt.Errorf("got %d subtrees, but expected 1", len(s))
+	}
+}
+
+// getSubtrees returns the latest revision, at or below treeRevision, of each of the
+// subtrees with nodeIDs that exists. Each subtree takes a query, as a batch get can't
+// find the latest revision of an item.
+func (t *logTreeTX) getSubtrees(treeRevision int64, nodeIDs []storage.NodeID) ([]*storagepb.SubtreeProto, error) {
+	ret := make([]*storagepb.SubtreeProto, 0, len(nodeIDs))
+	for _, nodeID := range nodeIDs {
+		if nodeID.PrefixLenBits%8 != 0 {
+			return nil, fmt.Errorf("invalid subtree ID - not multiple of 8: %d", nodeID.PrefixLenBits)
+		}
+		prefix := nodeID.Path[:nodeID.PrefixLenBits/8]
+
+		out, err := t.ls.client.QueryWithContext(t.ctx, &ddb.QueryInput{
+			TableName:              t.ls.opts.table(subtreesTable),
+			KeyConditionExpression: aws.String("TreeId = :t AND SubtreeKey BETWEEN :first AND :last"),
+			ExpressionAttributeValues: item{
+				":t":     numAttr(t.treeID),
+				":first": bytesAttr(subtreeKey(prefix, 0)),
+				":last":  bytesAttr(subtreeKey(prefix, treeRevision)),
+			},
+			ScanIndexForward: aws.Bool(false),
+			Limit:            aws.Int64(1),
+			ConsistentRead:   aws.Bool(true),
+		})
+		if err != nil {
+			glog.Warningf("Failed to get merkle subtree: %s", err)
+			return nil, err
+		}
+		if len(out.Items) == 0 {
+			continue
+		}
+
+		var subtree storagepb.SubtreeProto
+		if err := proto.Unmarshal(item(out.Items[0]).getBytes("Subtree"), &subtree); err != nil {
+			glog.Warningf("Failed to unmarshal SubtreeProto: %s", err)
+			return nil, err
+		}
+		if subtree.Prefix == nil {
+			subtree.Prefix = []byte{}
+		}
+		ret = append(ret, &subtree)
+	}
+
+	// The InternalNodes cache is possibly nil here, but the SubtreeCache (which called
+	// this method) will re-populate it.
+	return ret, nil
+}
+
+// storeSubtrees writes subtrees at the transaction's write revision. A subtree left by
+// a failed pass at the same revision is overwritten.
+func (t *logTreeTX) storeSubtrees(subtrees []*storagepb.SubtreeProto) error {
+	if len(subtrees) == 0 {
+		glog.Warning("attempted to store 0 subtrees...")
+		return nil
+	}
+
+	writes := make([]*ddb.WriteRequest, 0, len(subtrees))
+	for _, s := range subtrees {
+		if s.Prefix == nil {
+			panic(fmt.Errorf("nil prefix on %v", s))
+		}
+		subtreeBytes, err := proto.Marshal(s)
+		if err != nil {
+			return err
+		}
+		writes = append(writes, &ddb.WriteRequest{PutRequest: &ddb.PutRequest{Item: item{
+			"TreeId":     numAttr(t.treeID),
+			"SubtreeKey": bytesAttr(subtreeKey(s.Prefix, t.writeRevision)),
+			"Subtree":    bytesAttr(subtreeBytes),
+		}}})
+	}
+
+	if err := batchWrite(t.ctx, t.ls.client, t.ls.opts.table(subtreesTable), writes); err != nil {
+		glog.Warningf("Failed to set merkle subtrees: %s", err)
+		return err
+	}
+	return nil
+}
+
+// GetMerkleNodes returns the requests nodes at (or below) the passed in treeRevision.
+func (t *logTreeTX) GetMerkleNodes(treeRevision int64, nodeIDs []storage.NodeID) ([]storage.Node, error) {
+	return t.subtreeCache.GetNodes(nodeIDs, func(ids []storage.NodeID) ([]*storagepb.SubtreeProto, error) {
+		return t.getSubtrees(treeRevision, ids)
+	})
+}
+
+func (t *logTreeTX) SetMerkleNodes(nodes []storage.Node) error {
+	for _, n := range nodes {
+		err := t.subtreeCache.SetNodeHash(n.NodeID, n.Hash,
+			func(nID storage.NodeID) (*storagepb.SubtreeProto, error) {
+				return t.getSubtree(t.writeRevision, nID)
+			})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
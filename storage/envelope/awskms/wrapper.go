@@ -0,0 +1,62 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package awskms provides an envelope.KeyWrapper using an AWS KMS key.
+package awskms
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+)
+
+// KeyWrapper wraps data keys with a single KMS customer master key.
+type KeyWrapper struct {
+	client *kms.KMS
+	keyID  string
+}
+
+// NewKeyWrapper returns a KeyWrapper using the key with the given ID, ARN or alias.
+// Credentials and the region are taken from the environment as usual for AWS.
+func NewKeyWrapper(keyID string) (*KeyWrapper, error) {
+	sess, err := session.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	return &KeyWrapper{client: kms.New(sess), keyID: keyID}, nil
+}
+
+// WrapKey implements envelope.KeyWrapper.
+func (w *KeyWrapper) WrapKey(ctx context.Context, key []byte) ([]byte, error) {
+	out, err := w.client.EncryptWithContext(ctx, &kms.EncryptInput{
+		KeyId:     aws.String(w.keyID),
+		Plaintext: key,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.CiphertextBlob, nil
+}
+
+// UnwrapKey implements envelope.KeyWrapper. The ciphertext identifies the key it was
+// wrapped with, so KMS doesn't need to be told.
+func (w *KeyWrapper) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	out, err := w.client.DecryptWithContext(ctx, &kms.DecryptInput{CiphertextBlob: wrapped})
+	if err != nil {
+		return nil, err
+	}
+	return out.Plaintext, nil
+}
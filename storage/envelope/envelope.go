@@ -0,0 +1,110 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package envelope implements envelope encryption of data at rest: data is sealed with
+// a data key, which is itself kept wrapped (encrypted) by a key management service.
+// KeyWrappers for Cloud KMS and AWS KMS are in the gcpkms and awskms subpackages.
+package envelope
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// DataKeySize is the size in bytes of data keys, which are AES-256 keys.
+const DataKeySize = 32
+
+// KeyWrapper protects data keys with a key it doesn't reveal, usually held by a KMS.
+type KeyWrapper interface {
+	// WrapKey returns key encrypted with the wrapping key.
+	WrapKey(ctx context.Context, key []byte) ([]byte, error)
+	// UnwrapKey reverses WrapKey.
+	UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// NewDataKey returns a new random data key.
+func NewDataKey() ([]byte, error) {
+	key := make([]byte, DataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != DataKeySize {
+		return nil, fmt.Errorf("key is %d bytes, want %d", len(key), DataKeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Seal encrypts and authenticates plaintext and authenticates additionalData with
+// AES-GCM under key. The random nonce is prepended to the returned ciphertext.
+func Seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// Open reverses Seal, failing if the ciphertext or additionalData have been changed.
+func Open(key, ciphertext, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, additionalData)
+}
+
+// LocalKeyWrapper wraps data keys with a key held in memory. It's meant for tests and
+// deployments without a KMS, where the wrapping key is protected by other means.
+type LocalKeyWrapper struct {
+	key []byte
+}
+
+// NewLocalKeyWrapper returns a LocalKeyWrapper using key, which must be DataKeySize
+// bytes long.
+func NewLocalKeyWrapper(key []byte) (*LocalKeyWrapper, error) {
+	if len(key) != DataKeySize {
+		return nil, fmt.Errorf("wrapping key is %d bytes, want %d", len(key), DataKeySize)
+	}
+	return &LocalKeyWrapper{key: key}, nil
+}
+
+// WrapKey implements KeyWrapper.
+func (w *LocalKeyWrapper) WrapKey(ctx context.Context, key []byte) ([]byte, error) {
+	return Seal(w.key, key, nil)
+}
+
+// UnwrapKey implements KeyWrapper.
+func (w *LocalKeyWrapper) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	return Open(w.key, wrapped, nil)
+}
@@ -0,0 +1,106 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	key, err := NewDataKey()
+	if err != nil {
+		t.Fatalf("NewDataKey()=%v", err)
+	}
+	otherKey, err := NewDataKey()
+	if err != nil {
+		t.Fatalf("NewDataKey()=%v", err)
+	}
+	plaintext, ad := []byte("leaf value"), []byte("leaf id")
+
+	sealed, err := Seal(key, plaintext, ad)
+	if err != nil {
+		t.Fatalf("Seal()=%v", err)
+	}
+	if bytes.Contains(sealed, plaintext) {
+		t.Errorf("Seal()=%x, contains the plaintext", sealed)
+	}
+	again, err := Seal(key, plaintext, ad)
+	if err != nil {
+		t.Fatalf("Seal()=%v", err)
+	}
+	if bytes.Equal(sealed, again) {
+		t.Error("Seal() returned the same ciphertext twice, want a random nonce")
+	}
+
+	got, err := Open(key, sealed, ad)
+	if err != nil {
+		t.Fatalf("Open()=%v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("Open()=%q, want %q", got, plaintext)
+	}
+
+	tampered := append([]byte(nil), sealed...)
+	tampered[len(tampered)-1] ^= 1
+	for _, test := range []struct {
+		desc       string
+		key        []byte
+		ciphertext []byte
+		ad         []byte
+	}{
+		{desc: "wrongKey", key: otherKey, ciphertext: sealed, ad: ad},
+		{desc: "wrongAD", key: key, ciphertext: sealed, ad: []byte("other id")},
+		{desc: "tampered", key: key, ciphertext: tampered, ad: ad},
+		{desc: "short", key: key, ciphertext: sealed[:5], ad: ad},
+		{desc: "shortKey", key: key[:16], ciphertext: sealed, ad: ad},
+	} {
+		if _, err := Open(test.key, test.ciphertext, test.ad); err == nil {
+			t.Errorf("%v: Open()=nil, want error", test.desc)
+		}
+	}
+}
+
+func TestLocalKeyWrapper(t *testing.T) {
+	if _, err := NewLocalKeyWrapper([]byte("short")); err == nil {
+		t.Error("NewLocalKeyWrapper(short key)=nil, want error")
+	}
+	wrappingKey, err := NewDataKey()
+	if err != nil {
+		t.Fatalf("NewDataKey()=%v", err)
+	}
+	w, err := NewLocalKeyWrapper(wrappingKey)
+	if err != nil {
+		t.Fatalf("NewLocalKeyWrapper()=%v", err)
+	}
+	key, err := NewDataKey()
+	if err != nil {
+		t.Fatalf("NewDataKey()=%v", err)
+	}
+
+	ctx := context.Background()
+	wrapped, err := w.WrapKey(ctx, key)
+	if err != nil {
+		t.Fatalf("WrapKey()=%v", err)
+	}
+	got, err := w.UnwrapKey(ctx, wrapped)
+	if err != nil {
+		t.Fatalf("UnwrapKey()=%v", err)
+	}
+	if !bytes.Equal(got, key) {
+		t.Errorf("UnwrapKey()=%x, want %x", got, key)
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gcpkms provides an envelope.KeyWrapper using a Google Cloud KMS key.
+package gcpkms
+
+import (
+	"context"
+	"encoding/base64"
+
+	"golang.org/x/oauth2/google"
+	cloudkms "google.golang.org/api/cloudkms/v1"
+)
+
+// KeyWrapper wraps data keys with a single Cloud KMS CryptoKey.
+type KeyWrapper struct {
+	keys *cloudkms.ProjectsLocationsKeyRingsCryptoKeysService
+	name string
+}
+
+// NewKeyWrapper returns a KeyWrapper using the CryptoKey with the given resource name,
+// i.e. projects/*/locations/*/keyRings/*/cryptoKeys/*. Application default
+// credentials are used.
+func NewKeyWrapper(ctx context.Context, name string) (*KeyWrapper, error) {
+	client, err := google.DefaultClient(ctx, cloudkms.CloudPlatformScope)
+	if err != nil {
+		return nil, err
+	}
+	svc, err := cloudkms.New(client)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyWrapper{keys: svc.Projects.Locations.KeyRings.CryptoKeys, name: name}, nil
+}
+
+// WrapKey implements envelope.KeyWrapper.
+func (w *KeyWrapper) WrapKey(ctx context.Context, key []byte) ([]byte, error) {
+	resp, err := w.keys.Encrypt(w.name, &cloudkms.EncryptRequest{
+		Plaintext: base64.StdEncoding.EncodeToString(key),
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(resp.Ciphertext)
+}
+
+// UnwrapKey implements envelope.KeyWrapper.
+func (w *KeyWrapper) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	resp, err := w.keys.Decrypt(w.name, &cloudkms.DecryptRequest{
+		Ciphertext: base64.StdEncoding.EncodeToString(wrapped),
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(resp.Plaintext)
+}
@@ -0,0 +1,72 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/google/trillian"
+)
+
+// minLeafIdentityHashKeyBytes is the shortest key HMAC_SHA256_LEAF_VALUE may be keyed with.
+const minLeafIdentityHashKeyBytes = 32
+
+// SetLeafIdentityHashes overwrites the LeafIdentityHash of leaves with the one computed by
+// strategy, keyed by key for HMAC_SHA256_LEAF_VALUE. Leaves are left as they are if the
+// strategy is CLIENT_SUPPLIED.
+// Storage implementations call it before queueing or adding leaves, so the hashes leaves
+// are deduplicated by and returned with are the same whichever server wrote them.
+func SetLeafIdentityHashes(strategy trillian.LeafIdentityHashStrategy, key []byte, leaves []*trillian.LogLeaf) error {
+	if strategy == trillian.LeafIdentityHashStrategy_CLIENT_SUPPLIED {
+		return nil
+	}
+	for _, leaf := range leaves {
+		hash, err := leafIdentityHash(strategy, key, leaf)
+		if err != nil {
+			return err
+		}
+		leaf.LeafIdentityHash = hash
+	}
+	return nil
+}
+
+func leafIdentityHash(strategy trillian.LeafIdentityHashStrategy, key []byte, leaf *trillian.LogLeaf) ([]byte, error) {
+	switch strategy {
+	case trillian.LeafIdentityHashStrategy_SHA256_LEAF_VALUE:
+		hash := sha256.Sum256(leaf.LeafValue)
+		return hash[:], nil
+	case trillian.LeafIdentityHashStrategy_SHA256_LEAF_VALUE_AND_EXTRA_DATA:
+		// The value is length-prefixed, so moving bytes between it and the extra data
+		// changes the hash.
+		var length [8]byte
+		binary.BigEndian.PutUint64(length[:], uint64(len(leaf.LeafValue)))
+		h := sha256.New()
+		h.Write(length[:])
+		h.Write(leaf.LeafValue)
+		h.Write(leaf.ExtraData)
+		return h.Sum(nil), nil
+	case trillian.LeafIdentityHashStrategy_HMAC_SHA256_LEAF_VALUE:
+		if len(key) < minLeafIdentityHashKeyBytes {
+			return nil, fmt.Errorf("leaf identity hash key too short: %d bytes", len(key))
+		}
+		h := hmac.New(sha256.New, key)
+		h.Write(leaf.LeafValue)
+		return h.Sum(nil), nil
+	}
+	return nil, fmt.Errorf("unknown LeafIdentityHashStrategy: %v", strategy)
+}
@@ -0,0 +1,69 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/google/trillian"
+)
+
+func TestSetLeafIdentityHashes(t *testing.T) {
+	key1 := bytes.Repeat([]byte{1}, 32)
+	key2 := bytes.Repeat([]byte{2}, 32)
+	valueHash := sha256.Sum256([]byte("value"))
+
+	identityHash := func(strategy trillian.LeafIdentityHashStrategy, key []byte, value, extraData string) []byte {
+		leaf := &trillian.LogLeaf{LeafValue: []byte(value), ExtraData: []byte(extraData), LeafIdentityHash: []byte("client")}
+		if err := SetLeafIdentityHashes(strategy, key, []*trillian.LogLeaf{leaf}); err != nil {
+			t.Fatalf("SetLeafIdentityHashes(%v)=%v, want nil", strategy, err)
+		}
+		return leaf.LeafIdentityHash
+	}
+
+	if got, want := identityHash(trillian.LeafIdentityHashStrategy_CLIENT_SUPPLIED, nil, "value", "extra"), []byte("client"); !bytes.Equal(got, want) {
+		t.Errorf("CLIENT_SUPPLIED hash=%x, want %x", got, want)
+	}
+	if got, want := identityHash(trillian.LeafIdentityHashStrategy_SHA256_LEAF_VALUE, nil, "value", "extra"), valueHash[:]; !bytes.Equal(got, want) {
+		t.Errorf("SHA256_LEAF_VALUE hash=%x, want %x", got, want)
+	}
+
+	withExtra := trillian.LeafIdentityHashStrategy_SHA256_LEAF_VALUE_AND_EXTRA_DATA
+	if got := identityHash(withExtra, nil, "value", "extra"); bytes.Equal(got, identityHash(withExtra, nil, "value", "other")) {
+		t.Errorf("SHA256_LEAF_VALUE_AND_EXTRA_DATA hash doesn't depend on the extra data")
+	}
+	if got := identityHash(withExtra, nil, "value", "extra"); bytes.Equal(got, identityHash(withExtra, nil, "valueextra", "")) {
+		t.Errorf("SHA256_LEAF_VALUE_AND_EXTRA_DATA hash doesn't depend on where the value ends")
+	}
+
+	keyed := trillian.LeafIdentityHashStrategy_HMAC_SHA256_LEAF_VALUE
+	got := identityHash(keyed, key1, "value", "extra")
+	if want := identityHash(keyed, key1, "value", "other"); !bytes.Equal(got, want) {
+		t.Errorf("HMAC_SHA256_LEAF_VALUE hash=%x, want %x regardless of extra data", got, want)
+	}
+	if bytes.Equal(got, identityHash(keyed, key2, "value", "extra")) || bytes.Equal(got, valueHash[:]) {
+		t.Errorf("HMAC_SHA256_LEAF_VALUE hash doesn't depend on the key")
+	}
+
+	leaves := []*trillian.LogLeaf{{LeafValue: []byte("value")}}
+	if err := SetLeafIdentityHashes(keyed, key1[:16], leaves); err == nil {
+		t.Errorf("SetLeafIdentityHashes(HMAC_SHA256_LEAF_VALUE) with a short key=nil, want err")
+	}
+	if err := SetLeafIdentityHashes(trillian.LeafIdentityHashStrategy(-1), nil, leaves); err == nil {
+		t.Errorf("SetLeafIdentityHashes(-1)=nil, want err")
+	}
+}
@@ -42,7 +42,11 @@ type ReadOnlyLogTX interface {
 type ReadOnlyLogTreeTX interface {
 	ReadOnlyTreeTX
 	LeafReader
+	LeafQueueReader
 	LogRootReader
+	WitnessSignatureReader
+	LogFinalizationReader
+	StorageUsageReader
 }
 
 // LogTreeTX is the transactional interface for reading/updating a Log.
@@ -57,6 +61,9 @@ type LogTreeTX interface {
 	LeafReader
 	LeafQueuer
 	LeafDequeuer
+	SequencedLeafAdder
+	WitnessSignatureWriter
+	ObservedRootWriter
 	LogMetadata
 }
 
@@ -93,20 +100,60 @@ type LeafQueuer interface {
 	//  - the existing leaf entry if a duplicate has been submitted
 	//  - nil otherwise.
 	// Duplicates are only reported if the underlying tree does not permit duplicates, and are
-	// considered duplicate if their leaf.LeafIdentityHash matches.
+	// considered duplicate if their leaf.LeafIdentityHash matches. Unless the tree's
+	// LeafIdentityHashStrategy is CLIENT_SUPPLIED, the hashes of leaves are overwritten
+	// with the ones it computes first.
 	QueueLeaves(leaves []*trillian.LogLeaf, queueTimestamp time.Time) ([]*trillian.LogLeaf, error)
 }
 
+// SequencedLeafAdder provides a write path for leaves whose position in the tree has already
+// been decided, as is the case for PREORDERED_LOG trees.
+type SequencedLeafAdder interface {
+	// AddSequencedLeaves stores leaves at the LeafIndex they carry. The leaves are not
+	// integrated into the Merkle tree until the sequencer picks them up, which it does in
+	// index order as soon as there are no gaps after the current tree size.
+	// It's an error to call this on a tree that is not a PREORDERED_LOG, or to add a leaf at
+	// an index that is already occupied.
+	AddSequencedLeaves(leaves []*trillian.LogLeaf) error
+}
+
 // LeafDequeuer provides an interface for reading previously queued leaves for integration into the tree.
 type LeafDequeuer interface {
 	// DequeueLeaves will return between [0, limit] leaves from the queue.
 	// Leaves which have been dequeued within a Rolled-back Tx will become available for dequeing again.
 	// Leaves queued more recently than the cutoff time will not be returned. This allows for
 	// guard intervals to be configured.
+	// For PREORDERED_LOG trees the leaves returned are those previously stored by
+	// AddSequencedLeaves, starting at the current tree size and without gaps. They already
+	// have LeafIndex set and must not be passed to UpdateSequencedLeaves.
 	DequeueLeaves(limit int, cutoffTime time.Time) ([]*trillian.LogLeaf, error)
 	UpdateSequencedLeaves(leaves []*trillian.LogLeaf) error
 }
 
+// LeafQueueReader provides a read only view of the leaves waiting to be integrated into the tree.
+type LeafQueueReader interface {
+	// GetUnsequencedStats returns the number of leaves queued for integration and the queue
+	// timestamp of the oldest of them, which is the zero time if none are queued. Leaves added
+	// to PREORDERED_LOG trees with AddSequencedLeaves are not included.
+	GetUnsequencedStats() (int64, time.Time, error)
+}
+
+// StorageUsage is how many bytes of a tree's data are kept in storage.
+type StorageUsage struct {
+	// LeafBytes is the size of the leaf values and extra data.
+	LeafBytes int64
+	// NodeBytes is the size of the Merkle tree nodes.
+	NodeBytes int64
+}
+
+// StorageUsageReader provides a read only view of how much storage a log takes up.
+type StorageUsageReader interface {
+	// GetStorageUsage returns the bytes stored for the log. Storage may account for them
+	// as they're written and only recount them from time to time, so they can be briefly
+	// out of date.
+	GetStorageUsage() (StorageUsage, error)
+}
+
 // LeafReader provides a read only interface to stored tree leaves
 type LeafReader interface {
 	// GetSequencedLeafCount returns the total number of leaves that have been integrated into the
@@ -125,6 +172,9 @@ type LeafReader interface {
 type LogRootReader interface {
 	// LatestSignedLogRoot returns the most recent SignedLogRoot, if any.
 	LatestSignedLogRoot() (trillian.SignedLogRoot, error)
+	// SignedLogRootAtSize returns the most recent SignedLogRoot of size treeSize which is no
+	// newer than LatestSignedLogRoot, or a zero SignedLogRoot if there is none.
+	SignedLogRootAtSize(treeSize int64) (trillian.SignedLogRoot, error)
 }
 
 // LogRootWriter provides an interface for storing new SignedLogRoots.
@@ -133,6 +183,38 @@ type LogRootWriter interface {
 	StoreSignedLogRoot(root trillian.SignedLogRoot) error
 }
 
+// LogFinalizationReader provides an interface for checking whether a log has been finalized.
+type LogFinalizationReader interface {
+	// FinalizedTreeSize returns the size of the log's final root, and true, if the log has
+	// been frozen and finalized. It returns false otherwise.
+	FinalizedTreeSize() (int64, bool, error)
+}
+
+// WitnessSignatureReader provides an interface for reading the countersignatures witnesses
+// have made over SignedLogRoots.
+type WitnessSignatureReader interface {
+	// LatestWitnessedSignedLogRoot returns the most recent SignedLogRoot signed by at least
+	// quorum of the named witnesses, along with their signatures. A zero SignedLogRoot and
+	// no signatures are returned if there is no such root.
+	LatestWitnessedSignedLogRoot(witnesses []string, quorum int) (trillian.SignedLogRoot, []*trillian.WitnessSignature, error)
+}
+
+// WitnessSignatureWriter provides an interface for storing witness countersignatures.
+type WitnessSignatureWriter interface {
+	// StoreWitnessSignature stores sig over the SignedLogRoot at treeRevision, replacing any
+	// earlier signature by the same witness over that root.
+	StoreWitnessSignature(treeRevision int64, sig *trillian.WitnessSignature) error
+}
+
+// ObservedRootWriter provides an interface for recording roots of a log which clients
+// have seen elsewhere, e.g. through gossip.
+type ObservedRootWriter interface {
+	// StoreObservedRoot records that root was observed at observedAt, and whether it was
+	// consistent with the log's own history. Observing the same root again is not an error,
+	// the first observation is kept.
+	StoreObservedRoot(root trillian.SignedLogRoot, consistent bool, observedAt time.Time) error
+}
+
 // LogMetadata provides access to information about the logs in storage
 type LogMetadata interface {
 	// GetActiveLogs returns a list of the IDs of all the logs that are configured in storage
@@ -254,6 +254,16 @@ func (_m *MockLogTreeTX) EXPECT() *_MockLogTreeTXRecorder {
 	return _m.recorder
 }
 
+func (_m *MockLogTreeTX) AddSequencedLeaves(_param0 []*trillian.LogLeaf) error {
+	ret := _m.ctrl.Call(_m, "AddSequencedLeaves", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockLogTreeTXRecorder) AddSequencedLeaves(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "AddSequencedLeaves", arg0)
+}
+
 func (_m *MockLogTreeTX) Close() error {
 	ret := _m.ctrl.Call(_m, "Close")
 	ret0, _ := ret[0].(error)
@@ -285,6 +295,18 @@ func (_mr *_MockLogTreeTXRecorder) DequeueLeaves(arg0, arg1 interface{}) *gomock
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "DequeueLeaves", arg0, arg1)
 }
 
+func (_m *MockLogTreeTX) FinalizedTreeSize() (int64, bool, error) {
+	ret := _m.ctrl.Call(_m, "FinalizedTreeSize")
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+func (_mr *_MockLogTreeTXRecorder) FinalizedTreeSize() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "FinalizedTreeSize")
+}
+
 func (_m *MockLogTreeTX) GetActiveLogIDs() ([]int64, error) {
 	ret := _m.ctrl.Call(_m, "GetActiveLogIDs")
 	ret0, _ := ret[0].([]int64)
@@ -372,6 +394,18 @@ func (_mr *_MockLogTreeTXRecorder) LatestSignedLogRoot() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "LatestSignedLogRoot")
 }
 
+func (_m *MockLogTreeTX) LatestWitnessedSignedLogRoot(_param0 []string, _param1 int) (trillian.SignedLogRoot, []*trillian.WitnessSignature, error) {
+	ret := _m.ctrl.Call(_m, "LatestWitnessedSignedLogRoot", _param0, _param1)
+	ret0, _ := ret[0].(trillian.SignedLogRoot)
+	ret1, _ := ret[1].([]*trillian.WitnessSignature)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+func (_mr *_MockLogTreeTXRecorder) LatestWitnessedSignedLogRoot(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "LatestWitnessedSignedLogRoot", arg0, arg1)
+}
+
 func (_m *MockLogTreeTX) QueueLeaves(_param0 []*trillian.LogLeaf, _param1 time.Time) ([]*trillian.LogLeaf, error) {
 	ret := _m.ctrl.Call(_m, "QueueLeaves", _param0, _param1)
 	ret0, _ := ret[0].([]*trillian.LogLeaf)
@@ -413,6 +447,27 @@ func (_mr *_MockLogTreeTXRecorder) SetMerkleNodes(arg0 interface{}) *gomock.Call
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "SetMerkleNodes", arg0)
 }
 
+func (_m *MockLogTreeTX) SignedLogRootAtSize(_param0 int64) (trillian.SignedLogRoot, error) {
+	ret := _m.ctrl.Call(_m, "SignedLogRootAtSize", _param0)
+	ret0, _ := ret[0].(trillian.SignedLogRoot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockLogTreeTXRecorder) SignedLogRootAtSize(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SignedLogRootAtSize", arg0)
+}
+
+func (_m *MockLogTreeTX) StoreObservedRoot(_param0 trillian.SignedLogRoot, _param1 bool, _param2 time.Time) error {
+	ret := _m.ctrl.Call(_m, "StoreObservedRoot", _param0, _param1, _param2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockLogTreeTXRecorder) StoreObservedRoot(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "StoreObservedRoot", arg0, arg1, arg2)
+}
+
 func (_m *MockLogTreeTX) StoreSignedLogRoot(_param0 trillian.SignedLogRoot) error {
 	ret := _m.ctrl.Call(_m, "StoreSignedLogRoot", _param0)
 	ret0, _ := ret[0].(error)
@@ -423,6 +478,16 @@ func (_mr *_MockLogTreeTXRecorder) StoreSignedLogRoot(arg0 interface{}) *gomock.
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "StoreSignedLogRoot", arg0)
 }
 
+func (_m *MockLogTreeTX) StoreWitnessSignature(_param0 int64, _param1 *trillian.WitnessSignature) error {
+	ret := _m.ctrl.Call(_m, "StoreWitnessSignature", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockLogTreeTXRecorder) StoreWitnessSignature(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "StoreWitnessSignature", arg0, arg1)
+}
+
 func (_m *MockLogTreeTX) UpdateSequencedLeaves(_param0 []*trillian.LogLeaf) error {
 	ret := _m.ctrl.Call(_m, "UpdateSequencedLeaves", _param0)
 	ret0, _ := ret[0].(error)
@@ -859,6 +924,18 @@ func (_mr *_MockReadOnlyLogTreeTXRecorder) Commit() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "Commit")
 }
 
+func (_m *MockReadOnlyLogTreeTX) FinalizedTreeSize() (int64, bool, error) {
+	ret := _m.ctrl.Call(_m, "FinalizedTreeSize")
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+func (_mr *_MockReadOnlyLogTreeTXRecorder) FinalizedTreeSize() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "FinalizedTreeSize")
+}
+
 func (_m *MockReadOnlyLogTreeTX) GetLeavesByHash(_param0 [][]byte, _param1 bool) ([]*trillian.LogLeaf, error) {
 	ret := _m.ctrl.Call(_m, "GetLeavesByHash", _param0, _param1)
 	ret0, _ := ret[0].([]*trillian.LogLeaf)
@@ -903,6 +980,29 @@ func (_mr *_MockReadOnlyLogTreeTXRecorder) GetSequencedLeafCount() *gomock.Call
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSequencedLeafCount")
 }
 
+func (_m *MockReadOnlyLogTreeTX) GetStorageUsage() (StorageUsage, error) {
+	ret := _m.ctrl.Call(_m, "GetStorageUsage")
+	ret0, _ := ret[0].(StorageUsage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockReadOnlyLogTreeTXRecorder) GetStorageUsage() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetStorageUsage")
+}
+
+func (_m *MockReadOnlyLogTreeTX) GetUnsequencedStats() (int64, time.Time, error) {
+	ret := _m.ctrl.Call(_m, "GetUnsequencedStats")
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(time.Time)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+func (_mr *_MockReadOnlyLogTreeTXRecorder) GetUnsequencedStats() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetUnsequencedStats")
+}
+
 func (_m *MockReadOnlyLogTreeTX) IsOpen() bool {
 	ret := _m.ctrl.Call(_m, "IsOpen")
 	ret0, _ := ret[0].(bool)
@@ -924,6 +1024,18 @@ func (_mr *_MockReadOnlyLogTreeTXRecorder) LatestSignedLogRoot() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "LatestSignedLogRoot")
 }
 
+func (_m *MockReadOnlyLogTreeTX) LatestWitnessedSignedLogRoot(_param0 []string, _param1 int) (trillian.SignedLogRoot, []*trillian.WitnessSignature, error) {
+	ret := _m.ctrl.Call(_m, "LatestWitnessedSignedLogRoot", _param0, _param1)
+	ret0, _ := ret[0].(trillian.SignedLogRoot)
+	ret1, _ := ret[1].([]*trillian.WitnessSignature)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+func (_mr *_MockReadOnlyLogTreeTXRecorder) LatestWitnessedSignedLogRoot(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "LatestWitnessedSignedLogRoot", arg0, arg1)
+}
+
 func (_m *MockReadOnlyLogTreeTX) ReadRevision() int64 {
 	ret := _m.ctrl.Call(_m, "ReadRevision")
 	ret0, _ := ret[0].(int64)
@@ -944,6 +1056,17 @@ func (_mr *_MockReadOnlyLogTreeTXRecorder) Rollback() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "Rollback")
 }
 
+func (_m *MockReadOnlyLogTreeTX) SignedLogRootAtSize(_param0 int64) (trillian.SignedLogRoot, error) {
+	ret := _m.ctrl.Call(_m, "SignedLogRootAtSize", _param0)
+	ret0, _ := ret[0].(trillian.SignedLogRoot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockReadOnlyLogTreeTXRecorder) SignedLogRootAtSize(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SignedLogRootAtSize", arg0)
+}
+
 // Mock of ReadOnlyMapTreeTX interface
 type MockReadOnlyMapTreeTX struct {
 	ctrl     *gomock.Controller
@@ -18,6 +18,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -26,6 +27,7 @@ import (
 	"github.com/golang/protobuf/ptypes/any"
 	"github.com/google/trillian"
 	spb "github.com/google/trillian/crypto/sigpb"
+	"github.com/google/trillian/errors"
 	"github.com/google/trillian/storage"
 )
 
@@ -33,7 +35,7 @@ const (
 	defaultSequenceIntervalSeconds = 60
 	selectTrees                    = `
 		SELECT
-			TreeId,
+			Trees.TreeId,
 			TreeState,
 			TreeType,
 			HashStrategy,
@@ -44,9 +46,30 @@ const (
 			Description,
 			CreateTimeMillis,
 			UpdateTimeMillis,
-			PrivateKey
-		FROM Trees`
-	selectTreeByID = selectTrees + " WHERE TreeId = ?"
+			PrivateKey,
+			PublicKey,
+			ShardSetId,
+			ShardStartMillis,
+			ShardEndMillis,
+			LeafIdentityHashStrategy,
+			LeafIdentityHashKey,
+			AllowedWriters,
+			SequencingBatchSize,
+			SequencingIntervalSeconds,
+			SequencingGuardWindowSeconds,
+			LeafCompression,
+			LeafRetentionSeconds,
+			FinalizeTimeMillis,
+			FinalizedTreeSize,
+			SequencingPriority,
+			MaxMergeDelaySeconds
+		FROM Trees LEFT JOIN TreeControl ON Trees.TreeId = TreeControl.TreeId`
+	selectTreeByID = selectTrees + " WHERE Trees.TreeId = ?"
+	// selectOverlappingShards counts the live shards of a set whose window overlaps
+	// [start, end).
+	selectOverlappingShards = `
+		SELECT COUNT(*) FROM Trees
+		WHERE ShardSetId = ? AND ShardStartMillis < ? AND ShardEndMillis > ? AND TreeState IN ('ACTIVE', 'FROZEN')`
 )
 
 // duplicatePolicyMap maps storage enums to trillian.DuplicatePolicy enums,
@@ -58,12 +81,14 @@ var duplicatePolicyMap = map[string]trillian.DuplicatePolicy{
 
 // NewAdminStorage returns a MySQL storage.AdminStorage implementation backed by DB.
 func NewAdminStorage(db *sql.DB) storage.AdminStorage {
-	return &mysqlAdminStorage{db}
+	return &mysqlAdminStorage{db: db, newTreeID: storage.NewTreeID}
 }
 
 // mysqlAdminStorage implements storage.AdminStorage
 type mysqlAdminStorage struct {
 	db *sql.DB
+	// newTreeID generates the IDs of created trees.
+	newTreeID func() (int64, error)
 }
 
 func (s *mysqlAdminStorage) Snapshot(ctx context.Context) (storage.ReadOnlyAdminTX, error) {
@@ -71,15 +96,18 @@ func (s *mysqlAdminStorage) Snapshot(ctx context.Context) (storage.ReadOnlyAdmin
 }
 
 func (s *mysqlAdminStorage) Begin(ctx context.Context) (storage.AdminTX, error) {
-	tx, err := s.db.Begin()
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
-	return &adminTX{tx: tx}, nil
+	return &adminTX{tx: tx, ctx: ctx, newTreeID: s.newTreeID}, nil
 }
 
 type adminTX struct {
 	tx *sql.Tx
+	// ctx is the context tx was started with, tx is rolled back if it's done first.
+	ctx       context.Context
+	newTreeID func() (int64, error)
 
 	// mu guards *direct* reads/writes on closed, which happen only on
 	// Commit/Rollback/IsClosed/Close methods.
@@ -101,6 +129,7 @@ func (t *adminTX) Rollback() error {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 	t.closed = true
+	countIfAbandoned(t.ctx)
 	return t.tx.Rollback()
 }
 
@@ -127,12 +156,12 @@ func (t *adminTX) Close() error {
 }
 
 func (t *adminTX) GetTree(ctx context.Context, treeID int64) (*trillian.Tree, error) {
-	stmt, err := t.tx.Prepare(selectTreeByID)
+	stmt, err := t.tx.PrepareContext(ctx, selectTreeByID)
 	if err != nil {
 		return nil, err
 	}
 	defer stmt.Close()
-	return readTree(stmt.QueryRow(treeID))
+	return readTree(stmt.QueryRowContext(ctx, treeID))
 }
 
 // There's no common interface between sql.Row and sql.Rows(!), so we have to
@@ -145,10 +174,13 @@ func readTree(row row) (*trillian.Tree, error) {
 	tree := &trillian.Tree{}
 
 	// Enums and Datetimes need an extra conversion step
-	var treeState, treeType, hashStrategy, hashAlgorithm, signatureAlgorithm, duplicatePolicy string
+	var treeState, treeType, hashStrategy, hashAlgorithm, signatureAlgorithm, duplicatePolicy, identityHashStrategy string
 	var createMillis, updateMillis int64
-	var displayName, description sql.NullString
-	var privateKey []byte
+	var displayName, description, allowedWriters sql.NullString
+	var privateKey, publicKey []byte
+	// TreeControl is outer joined, so its columns may be NULL.
+	var batchSize, intervalSeconds, guardWindowSeconds, retentionSeconds, finalizeMillis, finalizedSize, priority, maxMergeDelay sql.NullInt64
+	var leafCompression sql.NullString
 	err := row.Scan(
 		&tree.TreeId,
 		&treeState,
@@ -162,6 +194,22 @@ func readTree(row row) (*trillian.Tree, error) {
 		&createMillis,
 		&updateMillis,
 		&privateKey,
+		&publicKey,
+		&tree.ShardSetId,
+		&tree.ShardStartMillisSinceEpoch,
+		&tree.ShardEndMillisSinceEpoch,
+		&identityHashStrategy,
+		&tree.LeafIdentityHashKey,
+		&allowedWriters,
+		&batchSize,
+		&intervalSeconds,
+		&guardWindowSeconds,
+		&leafCompression,
+		&retentionSeconds,
+		&finalizeMillis,
+		&finalizedSize,
+		&priority,
+		&maxMergeDelay,
 	)
 	if err != nil {
 		return nil, err
@@ -169,6 +217,24 @@ func readTree(row row) (*trillian.Tree, error) {
 
 	setNullStringIfValid(displayName, &tree.DisplayName)
 	setNullStringIfValid(description, &tree.Description)
+	if allowedWriters.Valid {
+		tree.AllowedWriters = strings.Split(allowedWriters.String, "\n")
+	}
+	tree.SequencingBatchSize = int32(batchSize.Int64)
+	tree.SequencingIntervalSeconds = int32(intervalSeconds.Int64)
+	tree.SequencingGuardWindowSeconds = int32(guardWindowSeconds.Int64)
+	tree.LeafRetentionSeconds = int32(retentionSeconds.Int64)
+	tree.FinalizeTimeMillisSinceEpoch = finalizeMillis.Int64
+	tree.FinalizedTreeSize = finalizedSize.Int64
+	tree.SequencingPriority = int32(priority.Int64)
+	tree.MaxMergeDelaySeconds = int32(maxMergeDelay.Int64)
+	if leafCompression.Valid {
+		lc, ok := trillian.LeafCompression_value[leafCompression.String]
+		if !ok {
+			return nil, fmt.Errorf("unknown LeafCompression: %v", leafCompression.String)
+		}
+		tree.LeafCompression = trillian.LeafCompression(lc)
+	}
 
 	// Convert all things!
 	if ts, ok := trillian.TreeState_value[treeState]; ok {
@@ -203,6 +269,11 @@ func readTree(row row) (*trillian.Tree, error) {
 	} else {
 		return nil, fmt.Errorf("unknown DuplicatePolicy: %v", duplicatePolicy)
 	}
+	if ihs, ok := trillian.LeafIdentityHashStrategy_value[identityHashStrategy]; ok {
+		tree.LeafIdentityHashStrategy = trillian.LeafIdentityHashStrategy(ihs)
+	} else {
+		return nil, fmt.Errorf("unknown LeafIdentityHashStrategy: %v", identityHashStrategy)
+	}
 
 	// Let's make sure we didn't mismatch any of the casts above
 	ok := tree.TreeState.String() == treeState
@@ -221,9 +292,15 @@ func readTree(row row) (*trillian.Tree, error) {
 	tree.CreateTimeMillisSinceEpoch = createMillis
 	tree.UpdateTimeMillisSinceEpoch = updateMillis
 
-	tree.PrivateKey = &any.Any{}
-	if err := proto.Unmarshal(privateKey, tree.PrivateKey); err != nil {
-		return nil, fmt.Errorf("could not unmarshal PrivateKey: %v", err)
+	// Verification-only trees are stored with an empty PrivateKey.
+	if len(privateKey) > 0 {
+		tree.PrivateKey = &any.Any{}
+		if err := proto.Unmarshal(privateKey, tree.PrivateKey); err != nil {
+			return nil, fmt.Errorf("could not unmarshal PrivateKey: %v", err)
+		}
+	}
+	if publicKey != nil {
+		tree.PublicKey = &trillian.PublicKey{Der: publicKey}
 	}
 
 	return tree, nil
@@ -237,13 +314,13 @@ func setNullStringIfValid(src sql.NullString, dest *string) {
 }
 
 func (t *adminTX) ListTreeIDs(ctx context.Context) ([]int64, error) {
-	stmt, err := t.tx.Prepare("SELECT TreeId FROM Trees")
+	stmt, err := t.tx.PrepareContext(ctx, "SELECT TreeId FROM Trees")
 	if err != nil {
 		return nil, err
 	}
 	defer stmt.Close()
 
-	rows, err := stmt.Query()
+	rows, err := stmt.QueryContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -261,12 +338,12 @@ func (t *adminTX) ListTreeIDs(ctx context.Context) ([]int64, error) {
 }
 
 func (t *adminTX) ListTrees(ctx context.Context) ([]*trillian.Tree, error) {
-	stmt, err := t.tx.Prepare(selectTrees)
+	stmt, err := t.tx.PrepareContext(ctx, selectTrees)
 	if err != nil {
 		return nil, err
 	}
 	defer stmt.Close()
-	rows, err := stmt.Query()
+	rows, err := stmt.QueryContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -282,12 +359,29 @@ func (t *adminTX) ListTrees(ctx context.Context) ([]*trillian.Tree, error) {
 	return trees, nil
 }
 
-func (t *adminTX) CreateTree(ctx context.Context, tree *trillian.Tree) (*trillian.Tree, error) {
+// CheckNewTree implements storage.NewTreeChecker.
+func (t *adminTX) CheckNewTree(ctx context.Context, tree *trillian.Tree) error {
 	if err := storage.ValidateTreeForCreation(tree); err != nil {
+		return err
+	}
+	if tree.ShardSetId != 0 {
+		var overlapping int
+		if err := t.tx.QueryRowContext(ctx, selectOverlappingShards, tree.ShardSetId, tree.ShardEndMillisSinceEpoch, tree.ShardStartMillisSinceEpoch).Scan(&overlapping); err != nil {
+			return err
+		}
+		if overlapping > 0 {
+			return errors.Errorf(errors.InvalidArgument, "shard window [%v, %v) overlaps another shard of set %v", tree.ShardStartMillisSinceEpoch, tree.ShardEndMillisSinceEpoch, tree.ShardSetId)
+		}
+	}
+	return nil
+}
+
+func (t *adminTX) CreateTree(ctx context.Context, tree *trillian.Tree) (*trillian.Tree, error) {
+	if err := t.CheckNewTree(ctx, tree); err != nil {
 		return nil, err
 	}
 
-	id, err := storage.NewTreeID()
+	id, err := t.newTreeID()
 	if err != nil {
 		return nil, err
 	}
@@ -299,7 +393,7 @@ func (t *adminTX) CreateTree(ctx context.Context, tree *trillian.Tree) (*trillia
 	newTree.CreateTimeMillisSinceEpoch = nowMillis
 	newTree.UpdateTimeMillisSinceEpoch = nowMillis
 
-	insertTreeStmt, err := t.tx.Prepare(`
+	insertTreeStmt, err := t.tx.PrepareContext(ctx, `
 		INSERT INTO Trees(
 			TreeId,
 			TreeState,
@@ -312,32 +406,37 @@ func (t *adminTX) CreateTree(ctx context.Context, tree *trillian.Tree) (*trillia
 			Description,
 			CreateTimeMillis,
 			UpdateTimeMillis,
-			PrivateKey)
-		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+			PrivateKey,
+			PublicKey,
+			ShardSetId,
+			ShardStartMillis,
+			ShardEndMillis,
+			LeafIdentityHashStrategy,
+			LeafIdentityHashKey,
+			AllowedWriters)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
 	if err != nil {
 		return nil, err
 	}
 	defer insertTreeStmt.Close()
 
-	// DuplicatePolicy doesn't map exactly to the enum, search in the map
-	// instead.
-	duplicatePolicy := ""
-	for k, v := range duplicatePolicyMap {
-		if v == newTree.DuplicatePolicy {
-			duplicatePolicy = k
-			break
-		}
-	}
-	if duplicatePolicy == "" {
-		return nil, fmt.Errorf("unexpected DuplicatePolicy value: %v", newTree.DuplicatePolicy)
+	duplicatePolicy, err := storedDuplicatePolicy(newTree.DuplicatePolicy)
+	if err != nil {
+		return nil, err
 	}
 
-	privateKey, err := proto.Marshal(newTree.PrivateKey)
-	if err != nil {
-		return nil, fmt.Errorf("could not marshal PrivateKey: %v", err)
+	privateKey := []byte{}
+	if newTree.PrivateKey != nil {
+		if privateKey, err = proto.Marshal(newTree.PrivateKey); err != nil {
+			return nil, fmt.Errorf("could not marshal PrivateKey: %v", err)
+		}
+	}
+	var publicKey []byte
+	if newTree.PublicKey != nil {
+		publicKey = newTree.PublicKey.GetDer()
 	}
 
-	_, err = insertTreeStmt.Exec(
+	_, err = insertTreeStmt.ExecContext(ctx,
 		newTree.TreeId,
 		newTree.TreeState.String(),
 		newTree.TreeType.String(),
@@ -350,6 +449,13 @@ func (t *adminTX) CreateTree(ctx context.Context, tree *trillian.Tree) (*trillia
 		newTree.CreateTimeMillisSinceEpoch,
 		newTree.UpdateTimeMillisSinceEpoch,
 		privateKey,
+		publicKey,
+		newTree.ShardSetId,
+		newTree.ShardStartMillisSinceEpoch,
+		newTree.ShardEndMillisSinceEpoch,
+		newTree.LeafIdentityHashStrategy.String(),
+		newTree.LeafIdentityHashKey,
+		storedAllowedWriters(newTree.AllowedWriters),
 	)
 	if err != nil {
 		return nil, err
@@ -365,22 +471,38 @@ func (t *adminTX) CreateTree(ctx context.Context, tree *trillian.Tree) (*trillia
 	}
 
 	// TODO(codingllama): There's a strong disconnect between trillian.Tree and TreeControl. Are we OK with that?
-	insertControlStmt, err := t.tx.Prepare(`
+	// Verification-only trees, without a private key, are neither signed nor sequenced.
+	canSign := newTree.PrivateKey != nil
+	insertControlStmt, err := t.tx.PrepareContext(ctx, `
 		INSERT INTO TreeControl(
 			TreeId,
 			SigningEnabled,
 			SequencingEnabled,
-			SequenceIntervalSeconds)
-		VALUES(?, ?, ?, ?)`)
+			SequenceIntervalSeconds,
+			SequencingBatchSize,
+			SequencingIntervalSeconds,
+			SequencingGuardWindowSeconds,
+			LeafCompression,
+			LeafRetentionSeconds,
+			SequencingPriority,
+			MaxMergeDelaySeconds)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
 	if err != nil {
 		return nil, err
 	}
 	defer insertControlStmt.Close()
-	_, err = insertControlStmt.Exec(
+	_, err = insertControlStmt.ExecContext(ctx,
 		newTree.TreeId,
-		true, /* SigningEnabled */
-		true, /* SequencingEnabled */
+		canSign, /* SigningEnabled */
+		canSign, /* SequencingEnabled */
 		defaultSequenceIntervalSeconds,
+		newTree.SequencingBatchSize,
+		newTree.SequencingIntervalSeconds,
+		newTree.SequencingGuardWindowSeconds,
+		newTree.LeafCompression.String(),
+		newTree.LeafRetentionSeconds,
+		newTree.SequencingPriority,
+		newTree.MaxMergeDelaySeconds,
 	)
 	if err != nil {
 		return nil, err
@@ -397,26 +519,74 @@ func (t *adminTX) UpdateTree(ctx context.Context, treeID int64, updateFunc func(
 
 	beforeUpdate := *tree
 	updateFunc(tree)
+	// A log is only finalized while it's frozen, unfreezing or deleting it undoes that.
+	if tree.TreeState != trillian.TreeState_FROZEN {
+		tree.FinalizeTimeMillisSinceEpoch = 0
+		tree.FinalizedTreeSize = 0
+	}
 	if err := storage.ValidateTreeForUpdate(&beforeUpdate, tree); err != nil {
 		return nil, err
 	}
 
+	// The update time always changes, so the update can only affect no rows if the
+	// tree has been deleted since it was read.
 	tree.UpdateTimeMillisSinceEpoch = toMillisSinceEpoch(time.Now())
+	if tree.UpdateTimeMillisSinceEpoch <= beforeUpdate.UpdateTimeMillisSinceEpoch {
+		tree.UpdateTimeMillisSinceEpoch = beforeUpdate.UpdateTimeMillisSinceEpoch + 1
+	}
 
-	stmt, err := t.tx.Prepare(`
+	duplicatePolicy, err := storedDuplicatePolicy(tree.DuplicatePolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt, err := t.tx.PrepareContext(ctx, `
 		UPDATE Trees
-		SET TreeState = ?, DisplayName = ?, Description = ?, UpdateTimeMillis = ?
+		SET TreeState = ?, DuplicatePolicy = ?, DisplayName = ?, Description = ?, UpdateTimeMillis = ?, AllowedWriters = ?
 		WHERE TreeId = ?`)
 	if err != nil {
 		return nil, err
 	}
 	defer stmt.Close()
 
-	if _, err = stmt.Exec(
+	res, err := stmt.ExecContext(ctx,
 		tree.TreeState.String(),
+		duplicatePolicy,
 		tree.DisplayName,
 		tree.Description,
 		tree.UpdateTimeMillisSinceEpoch,
+		storedAllowedWriters(tree.AllowedWriters),
+		tree.TreeId)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, errors.Errorf(errors.NotFound, "tree %v was deleted while being updated", treeID)
+	}
+
+	controlStmt, err := t.tx.PrepareContext(ctx, `
+		UPDATE TreeControl
+		SET SequencingBatchSize = ?, SequencingIntervalSeconds = ?, SequencingGuardWindowSeconds = ?, LeafCompression = ?,
+			LeafRetentionSeconds = ?, FinalizeTimeMillis = ?, FinalizedTreeSize = ?, SequencingPriority = ?,
+			MaxMergeDelaySeconds = ?
+		WHERE TreeId = ?`)
+	if err != nil {
+		return nil, err
+	}
+	defer controlStmt.Close()
+
+	if _, err = controlStmt.ExecContext(ctx,
+		tree.SequencingBatchSize,
+		tree.SequencingIntervalSeconds,
+		tree.SequencingGuardWindowSeconds,
+		tree.LeafCompression.String(),
+		tree.LeafRetentionSeconds,
+		tree.FinalizeTimeMillisSinceEpoch,
+		tree.FinalizedTreeSize,
+		tree.SequencingPriority,
+		tree.MaxMergeDelaySeconds,
 		tree.TreeId); err != nil {
 		return nil, err
 	}
@@ -424,6 +594,26 @@ func (t *adminTX) UpdateTree(ctx context.Context, treeID int64, updateFunc func(
 	return tree, nil
 }
 
+// storedDuplicatePolicy returns the storage enum of dp. DuplicatePolicy doesn't map
+// exactly to the enum, so it's searched for in duplicatePolicyMap instead.
+func storedDuplicatePolicy(dp trillian.DuplicatePolicy) (string, error) {
+	for k, v := range duplicatePolicyMap {
+		if v == dp {
+			return k, nil
+		}
+	}
+	return "", fmt.Errorf("unexpected DuplicatePolicy value: %v", dp)
+}
+
+// storedAllowedWriters returns the AllowedWriters column of writers, which are joined
+// by newlines, or NULL if there are none. SPIFFE IDs are URIs, so can't hold newlines.
+func storedAllowedWriters(writers []string) sql.NullString {
+	if len(writers) == 0 {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: strings.Join(writers, "\n"), Valid: true}
+}
+
 func toMillisSinceEpoch(t time.Time) int64 {
 	return t.UnixNano() / 1000000
 }
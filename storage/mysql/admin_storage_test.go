@@ -16,11 +16,17 @@ package mysql
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
 	"database/sql"
 	"fmt"
 	"testing"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/google/trillian"
+	"github.com/google/trillian/errors"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/storage/testonly"
 )
@@ -58,6 +64,54 @@ func TestAdminTX_CreateTree_InitializesStorageStructures(t *testing.T) {
 	}
 }
 
+func TestAdminTX_CreateTree_VerificationOnly(t *testing.T) {
+	cleanTestDB(DB)
+	s := NewAdminStorage(DB)
+	ctx := context.Background()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() = %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey() = %v", err)
+	}
+	verificationOnly := *testonly.LogTree
+	verificationOnly.PrivateKey = nil
+	verificationOnly.PublicKey = &trillian.PublicKey{Der: der}
+
+	tree, err := createTreeInternal(ctx, s, &verificationOnly)
+	if err != nil {
+		t.Fatalf("createTree() failed: %v", err)
+	}
+
+	tx, err := s.Snapshot(ctx)
+	if err != nil {
+		t.Fatalf("Snapshot() = %v", err)
+	}
+	defer tx.Close()
+	got, err := tx.GetTree(ctx, tree.TreeId)
+	if err != nil {
+		t.Fatalf("GetTree() = %v", err)
+	}
+	if got.PrivateKey != nil {
+		t.Errorf("GetTree().PrivateKey = %v, want nil", got.PrivateKey)
+	}
+	if !proto.Equal(got.PublicKey, verificationOnly.PublicKey) {
+		t.Errorf("GetTree().PublicKey = %v, want %v", got.PublicKey, verificationOnly.PublicKey)
+	}
+
+	var signingEnabled, sequencingEnabled bool
+	var sequenceIntervalSeconds int
+	if err := DB.QueryRow(selectTreeControlByID, tree.TreeId).Scan(&signingEnabled, &sequencingEnabled, &sequenceIntervalSeconds); err != nil {
+		t.Fatalf("Failed to read TreeControl: %v", err)
+	}
+	if signingEnabled || sequencingEnabled {
+		t.Errorf("SigningEnabled, SequencingEnabled = %v, %v, want false, false", signingEnabled, sequencingEnabled)
+	}
+}
+
 func TestAdminTX_TreeWithNulls(t *testing.T) {
 	cleanTestDB(DB)
 	s := NewAdminStorage(DB)
@@ -135,6 +189,32 @@ func TestAdminTX_TreeWithNulls(t *testing.T) {
 	}
 }
 
+func TestAdminTX_UpdateTree_Deleted(t *testing.T) {
+	cleanTestDB(DB)
+	s := NewAdminStorage(DB)
+	ctx := context.Background()
+
+	tree, err := createTreeInternal(ctx, s, testonly.LogTree)
+	if err != nil {
+		t.Fatalf("createTree() failed: %v", err)
+	}
+
+	tx, err := s.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin() = %v", err)
+	}
+	defer tx.Close()
+	// The tree is deleted by another client after UpdateTree has read it.
+	_, err = tx.UpdateTree(ctx, tree.TreeId, func(*trillian.Tree) {
+		if err := deleteTree(ctx, DB, tree.TreeId); err != nil {
+			t.Fatalf("deleteTree() = %v", err)
+		}
+	})
+	if got, want := errors.ErrorCode(err), errors.NotFound; got != want {
+		t.Errorf("UpdateTree() of a deleted tree = %v, want code %v", err, want)
+	}
+}
+
 func createTreeInternal(ctx context.Context, s storage.AdminStorage, tree *trillian.Tree) (*trillian.Tree, error) {
 	tx, err := s.Begin(ctx)
 	if err != nil {
@@ -0,0 +1,73 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/monitoring/metric"
+)
+
+var (
+	offloadedCounter = metric.NewCounter("mysql_offloaded_leaf_values")
+	fetchedCounter   = metric.NewCounter("mysql_fetched_leaf_values")
+)
+
+// blobKey returns the blob store key for value, as stored for the leaf with identity
+// hash id. The key includes the value's hash, so a duplicate of the leaf queued with a
+// different value can't overwrite the stored one.
+func blobKey(treeID int64, id, value []byte) string {
+	return fmt.Sprintf("%d/%x/%x", treeID, id, sha256.Sum256(value))
+}
+
+// offloadLeafValue writes value, as it will be stored for the leaf with identity hash
+// id, to the blob store if it's too large to keep in the database. It returns the
+// value and LeafValueLocator to store in the LeafData row.
+func (t *logTreeTX) offloadLeafValue(id, value []byte) ([]byte, interface{}, error) {
+	if t.ls.blobs == nil || len(value) <= t.ls.blobThreshold {
+		return value, nil, nil
+	}
+	// The blob is written before the row referencing it. If the transaction fails, or
+	// the leaf turns out to be a duplicate, the blob is orphaned, but never replaces the
+	// value of a leaf already stored.
+	key := blobKey(t.treeID, id, value)
+	if err := t.ls.blobs.Put(t.ctx, key, value); err != nil {
+		return nil, nil, fmt.Errorf("failed to write leaf value to blob store: %v", err)
+	}
+	offloadedCounter.Add(1)
+	return []byte{}, key, nil
+}
+
+// fetchLeafValue replaces the LeafValue of leaf with the one in the blob store, if the
+// locator read alongside it is set.
+func (t *logTreeTX) fetchLeafValue(leaf *trillian.LogLeaf, locator sql.NullString) error {
+	if !locator.Valid {
+		return nil
+	}
+	if t.ls.blobs == nil {
+		return errors.New("leaf value is in a blob store, but none is configured")
+	}
+	value, err := t.ls.blobs.Get(t.ctx, locator.String)
+	if err != nil {
+		return fmt.Errorf("failed to read leaf value %v from blob store: %v", locator.String, err)
+	}
+	fetchedCounter.Add(1)
+	leaf.LeafValue = value
+	return nil
+}
@@ -0,0 +1,91 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"fmt"
+
+	"github.com/golang/snappy"
+	"github.com/google/trillian"
+	"github.com/klauspost/compress/zstd"
+)
+
+// The zstd encoder and decoder are safe for concurrent use with EncodeAll and DecodeAll.
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// compressLeafData returns data compressed with c. Empty data is left as is, so NULL
+// columns stay NULL.
+func compressLeafData(c trillian.LeafCompression, data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return data, nil
+	}
+	switch c {
+	case trillian.LeafCompression_UNCOMPRESSED:
+		return data, nil
+	case trillian.LeafCompression_SNAPPY:
+		return snappy.Encode(nil, data), nil
+	case trillian.LeafCompression_ZSTD:
+		return zstdEncoder.EncodeAll(data, nil), nil
+	default:
+		return nil, fmt.Errorf("unknown leaf compression: %v", c)
+	}
+}
+
+// decompressLeafData reverses compressLeafData. compression is the name of the
+// trillian.LeafCompression recorded alongside data.
+func decompressLeafData(compression string, data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return data, nil
+	}
+	c, ok := trillian.LeafCompression_value[compression]
+	if !ok {
+		return nil, fmt.Errorf("unknown leaf compression: %v", compression)
+	}
+	switch trillian.LeafCompression(c) {
+	case trillian.LeafCompression_UNCOMPRESSED:
+		return data, nil
+	case trillian.LeafCompression_SNAPPY:
+		return snappy.Decode(nil, data)
+	case trillian.LeafCompression_ZSTD:
+		return zstdDecoder.DecodeAll(data, nil)
+	default:
+		return nil, fmt.Errorf("unsupported leaf compression: %v", compression)
+	}
+}
+
+// compressLeaf returns the LeafValue and ExtraData of leaf compressed with c.
+func compressLeaf(c trillian.LeafCompression, leaf *trillian.LogLeaf) (value, extraData []byte, err error) {
+	if value, err = compressLeafData(c, leaf.LeafValue); err != nil {
+		return nil, nil, err
+	}
+	if extraData, err = compressLeafData(c, leaf.ExtraData); err != nil {
+		return nil, nil, err
+	}
+	return value, extraData, nil
+}
+
+// decompressLeaf replaces the LeafValue and ExtraData of leaf, as read from storage, with
+// their decompressed form.
+func decompressLeaf(compression string, leaf *trillian.LogLeaf) error {
+	var err error
+	if leaf.LeafValue, err = decompressLeafData(compression, leaf.LeafValue); err != nil {
+		return err
+	}
+	leaf.ExtraData, err = decompressLeafData(compression, leaf.ExtraData)
+	return err
+}
@@ -3,11 +3,19 @@
 DROP TABLE IF EXISTS Unsequenced;
 DROP TABLE IF EXISTS Subtree;
 DROP TABLE IF EXISTS SequencedLeafData;
+DROP TABLE IF EXISTS WitnessSignature;
+DROP TABLE IF EXISTS ObservedTreeHead;
 DROP TABLE IF EXISTS TreeHead;
+DROP TABLE IF EXISTS LeafExpiry;
 DROP TABLE IF EXISTS LeafData;
 DROP TABLE IF EXISTS MapLeaf;
 DROP TABLE IF EXISTS MapHead;
 DROP TABLE IF EXISTS TreeControl;
+DROP TABLE IF EXISTS TreeDataKey;
 DROP TABLE IF EXISTS MapHead;
 DROP TABLE IF EXISTS MapLeaf;
+DROP TABLE IF EXISTS ReplicationHeartbeat;
+DROP TABLE IF EXISTS TreePlacement;
+DROP TABLE IF EXISTS TreeUsage;
 DROP TABLE IF EXISTS Trees;
+DROP TABLE IF EXISTS SchemaVersion;
@@ -0,0 +1,170 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/golang/glog"
+)
+
+// tlsConfigs numbers the TLS configs registered with the driver, which are referred to
+// by name in DSNs.
+var tlsConfigs int32
+
+// DBOptions configures the connection made by OpenDBWithOptions, on top of what's in
+// the connection URI.
+type DBOptions struct {
+	// TLSCAFile is a PEM file of the CA certificates the server's certificate must chain
+	// to. The connection uses TLS if any of the TLS files are set.
+	TLSCAFile string
+	// TLSCertFile and TLSKeyFile are PEM files of the client certificate and key to
+	// present to the server.
+	TLSCertFile, TLSKeyFile string
+	// TLSServerName, if set, is the name the server's certificate is checked for instead
+	// of the host in the URI.
+	TLSServerName string
+	// Timeout, ReadTimeout and WriteTimeout are the dial, read and write timeouts of
+	// each connection. Zero leaves the URI's setting.
+	Timeout, ReadTimeout, WriteTimeout time.Duration
+	// Collation, if set, is the collation of each connection, e.g. utf8mb4_general_ci.
+	Collation string
+	// Params are session variables set on each connection, e.g. time_zone='+00:00'.
+	Params map[string]string
+	// ConnectRetries is the number of times connecting to the database is retried if
+	// it isn't reachable, e.g. because it's still starting up.
+	ConnectRetries int
+	// ConnectTimeout, if greater than 0, bounds the total time spent retrying. If
+	// ConnectRetries is 0, connecting is retried until it runs out.
+	ConnectTimeout time.Duration
+	// ConnectBackoff is the time waited before the first retry, it doubles on each
+	// retry after that, up to maxConnectBackoff. Defaults to a second.
+	ConnectBackoff time.Duration
+}
+
+// maxConnectBackoff caps the time waited between connection attempts.
+const maxConnectBackoff = 30 * time.Second
+
+// OpenDBWithOptions opens a database like OpenDB, applying opts to the connection URI,
+// and retrying as configured by opts if the database can't be reached.
+func OpenDBWithOptions(dbURL string, opts DBOptions) (*sql.DB, error) {
+	dsn, err := applyDBOptions(dbURL, opts)
+	if err != nil {
+		return nil, err
+	}
+	return connectWithRetry(opts, func() (*sql.DB, error) { return OpenDB(dsn) })
+}
+
+// connectWithRetry calls open until it succeeds, or the retries or time allowed by
+// opts run out, and returns the result of the last call.
+func connectWithRetry(opts DBOptions, open func() (*sql.DB, error)) (*sql.DB, error) {
+	backoff := opts.ConnectBackoff
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	deadline := time.Now().Add(opts.ConnectTimeout)
+	for attempt := 0; ; attempt++ {
+		db, err := open()
+		if err == nil {
+			return db, nil
+		}
+		// With a timeout and no number of retries, the timeout is the only limit.
+		if attempt >= opts.ConnectRetries && (opts.ConnectRetries > 0 || opts.ConnectTimeout <= 0) {
+			return nil, err
+		}
+		if opts.ConnectTimeout > 0 && time.Now().Add(backoff).After(deadline) {
+			return nil, fmt.Errorf("gave up connecting to MySQL after %v: %v", opts.ConnectTimeout, err)
+		}
+		glog.Warningf("Failed to connect to MySQL, retrying in %v: %v", backoff, err)
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > maxConnectBackoff {
+			backoff = maxConnectBackoff
+		}
+	}
+}
+
+// applyDBOptions returns dbURL modified by opts, registering a TLS config with the driver
+// if opts asks for TLS.
+func applyDBOptions(dbURL string, opts DBOptions) (string, error) {
+	cfg, err := mysql.ParseDSN(dbURL)
+	if err != nil {
+		// Don't include dbURL in errors, it could contain credentials.
+		return "", fmt.Errorf("invalid MySQL URI: %v", err)
+	}
+
+	if opts.TLSCAFile != "" || opts.TLSCertFile != "" || opts.TLSKeyFile != "" {
+		tlsCfg, err := newTLSConfig(opts)
+		if err != nil {
+			return "", err
+		}
+		name := fmt.Sprintf("trillian%d", atomic.AddInt32(&tlsConfigs, 1))
+		if err := mysql.RegisterTLSConfig(name, tlsCfg); err != nil {
+			return "", err
+		}
+		cfg.TLSConfig = name
+	}
+	if opts.Timeout > 0 {
+		cfg.Timeout = opts.Timeout
+	}
+	if opts.ReadTimeout > 0 {
+		cfg.ReadTimeout = opts.ReadTimeout
+	}
+	if opts.WriteTimeout > 0 {
+		cfg.WriteTimeout = opts.WriteTimeout
+	}
+	if opts.Collation != "" {
+		cfg.Collation = opts.Collation
+	}
+	if len(opts.Params) > 0 && cfg.Params == nil {
+		cfg.Params = make(map[string]string)
+	}
+	for k, v := range opts.Params {
+		cfg.Params[k] = v
+	}
+	return cfg.FormatDSN(), nil
+}
+
+func newTLSConfig(opts DBOptions) (*tls.Config, error) {
+	cfg := &tls.Config{ServerName: opts.TLSServerName}
+	if opts.TLSCAFile != "" {
+		pem, err := ioutil.ReadFile(opts.TLSCAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %v", opts.TLSCAFile)
+		}
+	}
+	if opts.TLSCertFile != "" || opts.TLSKeyFile != "" {
+		if opts.TLSCertFile == "" || opts.TLSKeyFile == "" {
+			return nil, errors.New("a TLS client certificate needs both a certificate and key file")
+		}
+		cert, err := tls.LoadX509KeyPair(opts.TLSCertFile, opts.TLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
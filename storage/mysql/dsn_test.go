@@ -0,0 +1,131 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const testCAFile = "../../testdata/fake-ca.cert"
+
+func TestApplyDBOptions(t *testing.T) {
+	const uri = "test:zaphod@tcp(127.0.0.1:3306)/test"
+	for _, test := range []struct {
+		desc    string
+		opts    DBOptions
+		wantErr bool
+		check   func(*mysql.Config) bool
+	}{
+		{
+			desc:  "none",
+			check: func(c *mysql.Config) bool { return c.TLSConfig == "" && c.Timeout == 0 },
+		},
+		{
+			desc: "timeouts",
+			opts: DBOptions{Timeout: time.Second, ReadTimeout: 2 * time.Second, WriteTimeout: 3 * time.Second},
+			check: func(c *mysql.Config) bool {
+				return c.Timeout == time.Second && c.ReadTimeout == 2*time.Second && c.WriteTimeout == 3*time.Second
+			},
+		},
+		{
+			desc:  "collation",
+			opts:  DBOptions{Collation: "utf8mb4_general_ci"},
+			check: func(c *mysql.Config) bool { return c.Collation == "utf8mb4_general_ci" },
+		},
+		{
+			desc:  "params",
+			opts:  DBOptions{Params: map[string]string{"time_zone": "'+00:00'"}},
+			check: func(c *mysql.Config) bool { return c.Params["time_zone"] == "'+00:00'" },
+		},
+		{
+			desc:  "tls",
+			opts:  DBOptions{TLSCAFile: testCAFile},
+			check: func(c *mysql.Config) bool { return strings.HasPrefix(c.TLSConfig, "trillian") },
+		},
+		{
+			desc:    "missingCA",
+			opts:    DBOptions{TLSCAFile: "/not/a/file"},
+			wantErr: true,
+		},
+		{
+			desc:    "certWithoutKey",
+			opts:    DBOptions{TLSCAFile: testCAFile, TLSCertFile: testCAFile},
+			wantErr: true,
+		},
+	} {
+		dsn, err := applyDBOptions(uri, test.opts)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("%v: applyDBOptions()=(_, %v), want err=%v", test.desc, err, test.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		cfg, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			t.Errorf("%v: applyDBOptions() returned unparseable DSN: %v", test.desc, err)
+			continue
+		}
+		if cfg.User != "test" || cfg.DBName != "test" {
+			t.Errorf("%v: applyDBOptions() lost the URI's settings: %+v", test.desc, cfg)
+		}
+		if !test.check(cfg) {
+			t.Errorf("%v: applyDBOptions() config %+v doesn't have the options applied", test.desc, cfg)
+		}
+	}
+
+	if _, err := applyDBOptions("not a uri", DBOptions{}); err == nil {
+		t.Error("applyDBOptions(invalid URI)=nil, want error")
+	}
+}
+
+func TestConnectWithRetry(t *testing.T) {
+	for _, test := range []struct {
+		desc         string
+		opts         DBOptions
+		failures     int
+		wantAttempts int
+		wantErr      bool
+	}{
+		{desc: "connected", wantAttempts: 1},
+		{desc: "noRetries", failures: 1, wantAttempts: 1, wantErr: true},
+		{desc: "retried", opts: DBOptions{ConnectRetries: 3, ConnectBackoff: time.Millisecond}, failures: 2, wantAttempts: 3},
+		{desc: "retriesExhausted", opts: DBOptions{ConnectRetries: 2, ConnectBackoff: time.Millisecond}, failures: 5, wantAttempts: 3, wantErr: true},
+		{desc: "timedOut", opts: DBOptions{ConnectRetries: 5, ConnectBackoff: 10 * time.Millisecond, ConnectTimeout: 25 * time.Millisecond}, failures: 5, wantAttempts: 2, wantErr: true},
+		{desc: "timeoutOnly", opts: DBOptions{ConnectBackoff: time.Millisecond, ConnectTimeout: time.Minute}, failures: 3, wantAttempts: 4},
+		{desc: "timeoutOnlyTimedOut", opts: DBOptions{ConnectBackoff: 10 * time.Millisecond, ConnectTimeout: 25 * time.Millisecond}, failures: 5, wantAttempts: 2, wantErr: true},
+	} {
+		attempts := 0
+		db, err := connectWithRetry(test.opts, func() (*sql.DB, error) {
+			attempts++
+			if attempts <= test.failures {
+				return nil, errors.New("connection refused")
+			}
+			return &sql.DB{}, nil
+		})
+		if gotErr := err != nil; gotErr != test.wantErr || (db == nil) != test.wantErr {
+			t.Errorf("%v: connectWithRetry()=(%v, %v), want err=%v", test.desc, db, err, test.wantErr)
+		}
+		if attempts != test.wantAttempts {
+			t.Errorf("%v: connectWithRetry() made %d attempts, want %d", test.desc, attempts, test.wantAttempts)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage/envelope"
+)
+
+const (
+	selectTreeDataKeySQL = "SELECT WrappedKey FROM TreeDataKey WHERE TreeId=?"
+	insertTreeDataKeySQL = `INSERT INTO TreeDataKey(TreeId,WrappedKey)
+			VALUES(?,?) ON DUPLICATE KEY UPDATE TreeId=TreeId`
+)
+
+// dataKeys holds the unwrapped data keys of the trees leaf data has been encrypted or
+// decrypted for, so the KMS is only asked to unwrap each key once.
+type dataKeys struct {
+	db      *sql.DB
+	wrapper envelope.KeyWrapper
+
+	mu   sync.Mutex
+	keys map[int64][]byte
+}
+
+func newDataKeys(db *sql.DB, wrapper envelope.KeyWrapper) *dataKeys {
+	return &dataKeys{db: db, wrapper: wrapper, keys: make(map[int64][]byte)}
+}
+
+// get returns the data key of treeID. If create is set and the tree doesn't have one
+// yet it's created, otherwise that's an error.
+func (d *dataKeys) get(ctx context.Context, treeID int64, create bool) ([]byte, error) {
+	if d.wrapper == nil {
+		return nil, errors.New("leaf data encryption isn't configured")
+	}
+	d.mu.Lock()
+	key, ok := d.keys[treeID]
+	d.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	var wrapped []byte
+	err := d.db.QueryRowContext(ctx, selectTreeDataKeySQL, treeID).Scan(&wrapped)
+	if err == sql.ErrNoRows && create {
+		wrapped, err = d.create(ctx, treeID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data key: %v", err)
+	}
+	if key, err = d.wrapper.UnwrapKey(ctx, wrapped); err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %v", err)
+	}
+
+	d.mu.Lock()
+	d.keys[treeID] = key
+	d.mu.Unlock()
+	return key, nil
+}
+
+// create stores a new data key for treeID, unless another server got there first, and
+// returns the wrapped key the tree ends up with. The key is written outside of any
+// tree transaction so it's there for the leaves of every transaction that used it.
+func (d *dataKeys) create(ctx context.Context, treeID int64) ([]byte, error) {
+	key, err := envelope.NewDataKey()
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := d.wrapper.WrapKey(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %v", err)
+	}
+	if _, err := d.db.ExecContext(ctx, insertTreeDataKeySQL, treeID, wrapped); err != nil {
+		return nil, err
+	}
+	err = d.db.QueryRowContext(ctx, selectTreeDataKeySQL, treeID).Scan(&wrapped)
+	return wrapped, err
+}
+
+// leafAssociatedData binds an encrypted column to the leaf it belongs to, so it can't
+// be moved to another leaf or column without decryption failing.
+func leafAssociatedData(treeID int64, id []byte, column string) []byte {
+	return []byte(fmt.Sprintf("%d/%x/%s", treeID, id, column))
+}
+
+// encryptLeafData seals value and extraData, as they'll be stored for the leaf with
+// identity hash id, if encryption is configured. encrypted reports whether they were.
+// Empty data is left as is, like compressLeafData does.
+func (t *logTreeTX) encryptLeafData(id, value, extraData []byte) (_, _ []byte, encrypted bool, err error) {
+	if t.ls.dataKeys.wrapper == nil {
+		return value, extraData, false, nil
+	}
+	key, err := t.ls.dataKeys.get(t.ctx, t.treeID, true)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if len(value) > 0 {
+		if value, err = envelope.Seal(key, value, leafAssociatedData(t.treeID, id, "LeafValue")); err != nil {
+			return nil, nil, false, err
+		}
+	}
+	if len(extraData) > 0 {
+		if extraData, err = envelope.Seal(key, extraData, leafAssociatedData(t.treeID, id, "ExtraData")); err != nil {
+			return nil, nil, false, err
+		}
+	}
+	return value, extraData, true, nil
+}
+
+// decryptLeaf replaces the LeafValue and ExtraData of leaf, as read from storage, with
+// their plaintext if encrypted is set.
+func (t *logTreeTX) decryptLeaf(leaf *trillian.LogLeaf, encrypted bool) error {
+	if !encrypted {
+		return nil
+	}
+	key, err := t.ls.dataKeys.get(t.ctx, t.treeID, false)
+	if err != nil {
+		return err
+	}
+	if len(leaf.LeafValue) > 0 {
+		if leaf.LeafValue, err = envelope.Open(key, leaf.LeafValue, leafAssociatedData(t.treeID, leaf.LeafIdentityHash, "LeafValue")); err != nil {
+			return err
+		}
+	}
+	if len(leaf.ExtraData) > 0 {
+		if leaf.ExtraData, err = envelope.Open(key, leaf.ExtraData, leafAssociatedData(t.treeID, leaf.LeafIdentityHash, "ExtraData")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build ignore
+// +build ignore
+
+// gen_sql writes a Go file holding the contents of SQL files in the sqlFiles map, so
+// binaries can apply the schema without the files being deployed alongside them.
+// Arguments may be glob patterns, as go generate doesn't expand them.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+var out = flag.String("out", "", "Go file to write")
+
+func main() {
+	flag.Parse()
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by gen_sql.go. DO NOT EDIT.\n\npackage mysql\n\n")
+	fmt.Fprintf(&b, "// sqlFiles holds the contents of the package's SQL files, keyed by name.\n")
+	fmt.Fprintf(&b, "var sqlFiles = map[string]string{\n")
+	var names []string
+	for _, pattern := range flag.Args() {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			log.Fatal(err)
+		}
+		names = append(names, matches...)
+	}
+	for _, name := range names {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if s := string(data); strings.Contains(s, "`") {
+			fmt.Fprintf(&b, "%q: %q,\n", name, s)
+		} else {
+			fmt.Fprintf(&b, "%q: `%s`,\n", name, s)
+		}
+	}
+	fmt.Fprintf(&b, "}\n")
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(*out, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
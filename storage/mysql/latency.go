@@ -0,0 +1,64 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/monitoring/metric"
+)
+
+// Storage operations whose latency is measured, each has a mysql_<op>_latency_ms
+// histogram.
+const (
+	opQueueLeaves        = "queue_leaves"
+	opAddSequencedLeaves = "add_sequenced_leaves"
+	opDequeueLeaves      = "dequeue_leaves"
+	opUpdateSequenced    = "update_sequenced_leaves"
+	opReadSubtrees       = "read_subtrees"
+	opWriteSubtrees      = "write_subtrees"
+	opStoreSignedLogRoot = "store_signed_log_root"
+	opStoreSignedMapRoot = "store_signed_map_root"
+	opGetLeavesByIndex   = "get_leaves_by_index"
+	opGetLeavesByHash    = "get_leaves_by_hash"
+)
+
+var opLatencies = newOpLatencies(
+	opQueueLeaves, opAddSequencedLeaves, opDequeueLeaves, opUpdateSequenced,
+	opReadSubtrees, opWriteSubtrees, opStoreSignedLogRoot, opStoreSignedMapRoot,
+	opGetLeavesByIndex, opGetLeavesByHash,
+)
+
+func newOpLatencies(ops ...string) map[string]metric.Histogram {
+	m := make(map[string]metric.Histogram)
+	for _, op := range ops {
+		m[op] = metric.NewHistogram("mysql_"+op+"_latency_ms", metric.LatencyBuckets)
+	}
+	return m
+}
+
+// observe records the time since start as the latency of op on treeID, and logs it if
+// it took at least the storage's slow operation threshold. It's intended to be
+// deferred at the start of the operation:
+//
+//	defer t.ts.observe(opQueueLeaves, t.treeID, time.Now())
+func (m *mySQLTreeStorage) observe(op string, treeID int64, start time.Time) {
+	elapsed := time.Since(start)
+	opLatencies[op].Observe(float64(elapsed) / float64(time.Millisecond))
+	if m.slowOpThreshold > 0 && elapsed >= m.slowOpThreshold {
+		glog.Warningf("%v: slow storage operation %v took %v", treeID, op, elapsed)
+	}
+}
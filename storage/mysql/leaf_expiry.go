@@ -0,0 +1,200 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/monitoring/metric"
+	"github.com/google/trillian/storage/blob"
+	"github.com/google/trillian/util"
+)
+
+const (
+	selectRetainedLogsSQL = `SELECT t.TreeId,c.LeafRetentionSeconds
+			FROM Trees t JOIN TreeControl c ON t.TreeId=c.TreeId
+			WHERE c.LeafRetentionSeconds>0
+			AND t.TreeType IN ('LOG','PREORDERED_LOG')
+			AND t.TreeState IN ('ACTIVE','FROZEN')`
+	// The leaves below the size of the latest tree head signed before the cutoff were
+	// integrated before it.
+	selectExpiredTreeSizeSQL = `SELECT COALESCE(MAX(TreeSize),0) FROM TreeHead
+			WHERE TreeId=? AND TreeHeadTimestamp<?`
+	selectLeafExpirySQL = "SELECT ExpiredTreeSize FROM LeafExpiry WHERE TreeId=?"
+	// prunableLeafData joins the data of the leaves sequenced in [?, ?) which are to be
+	// pruned, those without another entry which is queued or at or above the expired
+	// tree size.
+	prunableLeafData = `LeafData l JOIN SequencedLeafData s
+			ON l.TreeId=s.TreeId AND l.LeafIdentityHash=s.LeafIdentityHash`
+	prunableLeafDataWhere = `WHERE s.TreeId=? AND s.SequenceNumber>=? AND s.SequenceNumber<? AND NOT l.Expired
+			AND NOT EXISTS(SELECT 1 FROM SequencedLeafData r
+				WHERE r.TreeId=s.TreeId AND r.LeafIdentityHash=s.LeafIdentityHash AND r.SequenceNumber>=?)
+			AND NOT EXISTS(SELECT 1 FROM Unsequenced u
+				WHERE u.TreeId=s.TreeId AND u.LeafIdentityHash=s.LeafIdentityHash)`
+	selectPrunedLocatorsSQL = "SELECT l.LeafValueLocator FROM " + prunableLeafData + " " + prunableLeafDataWhere +
+		" AND l.LeafValueLocator IS NOT NULL FOR UPDATE"
+	pruneLeafDataSQL = "UPDATE " + prunableLeafData +
+		" SET l.LeafValue='',l.ExtraData=NULL,l.Compression='UNCOMPRESSED',l.LeafValueLocator=NULL,l.Encrypted=FALSE,l.Expired=TRUE " +
+		prunableLeafDataWhere
+	updateLeafExpirySQL = `INSERT INTO LeafExpiry(TreeId,ExpiredTreeSize) VALUES(?,?)
+			ON DUPLICATE KEY UPDATE ExpiredTreeSize=VALUES(ExpiredTreeSize)`
+)
+
+var expiredCounter = metric.NewCounter("mysql_expired_leaf_values")
+
+// PruneExpiredLeafData prunes the values and extra data of the leaves of logs with a
+// LeafRetentionSeconds which were integrated longer ago than that, as of now, and marks
+// them Expired. Leaf hashes are kept, so proofs stay valid. A leaf counts as integrated
+// from the first tree head signed with it, and its data is kept while another entry of
+// it is queued or within the retention window. Offloaded values are deleted from blobs,
+// which must be set if any have been offloaded. Up to batchSize leaves are pruned per
+// transaction. It returns the number of leaves whose data was pruned.
+func PruneExpiredLeafData(ctx context.Context, db *sql.DB, blobs blob.Store, now time.Time, batchSize int64) (int64, error) {
+	rows, err := db.QueryContext(ctx, selectRetainedLogsSQL)
+	if err != nil {
+		return 0, err
+	}
+	retention := make(map[int64]time.Duration)
+	for rows.Next() {
+		var treeID, seconds int64
+		if err := rows.Scan(&treeID, &seconds); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		retention[treeID] = time.Duration(seconds) * time.Second
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return 0, err
+	}
+	rows.Close()
+
+	var pruned int64
+	for treeID, r := range retention {
+		n, err := pruneExpiredLeafData(ctx, db, blobs, treeID, now.Add(-r), batchSize)
+		pruned += n
+		if err != nil {
+			return pruned, err
+		}
+		if n > 0 {
+			glog.Infof("%v: pruned the data of %d leaves integrated before %v", treeID, n, now.Add(-r))
+		}
+	}
+	return pruned, nil
+}
+
+// pruneExpiredLeafData prunes the data of the leaves of treeID integrated before cutoff,
+// from where the previous pass got to.
+func pruneExpiredLeafData(ctx context.Context, db *sql.DB, blobs blob.Store, treeID int64, cutoff time.Time, batchSize int64) (int64, error) {
+	var expiredSize, from int64
+	if err := db.QueryRowContext(ctx, selectExpiredTreeSizeSQL, treeID, cutoff.UnixNano()).Scan(&expiredSize); err != nil {
+		return 0, err
+	}
+	if err := db.QueryRowContext(ctx, selectLeafExpirySQL, treeID).Scan(&from); err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	var pruned int64
+	for from < expiredSize {
+		to := from + batchSize
+		if to > expiredSize {
+			to = expiredSize
+		}
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return pruned, err
+		}
+		if err := deletePrunedBlobs(ctx, tx, blobs, treeID, from, to, expiredSize); err != nil {
+			tx.Rollback()
+			return pruned, err
+		}
+		res, err := tx.ExecContext(ctx, pruneLeafDataSQL, treeID, from, to, expiredSize)
+		if err != nil {
+			tx.Rollback()
+			return pruned, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			tx.Rollback()
+			return pruned, err
+		}
+		if _, err := tx.ExecContext(ctx, updateLeafExpirySQL, treeID, to); err != nil {
+			tx.Rollback()
+			return pruned, err
+		}
+		if err := tx.Commit(); err != nil {
+			return pruned, err
+		}
+		expiredCounter.Add(n)
+		pruned += n
+		from = to
+	}
+	return pruned, nil
+}
+
+// deletePrunedBlobs deletes the offloaded values of the leaves of treeID that
+// pruneLeafDataSQL is about to prune, before their locators are cleared. If tx then
+// fails to commit, the leaves can't be read until the next pass prunes them.
+func deletePrunedBlobs(ctx context.Context, tx *sql.Tx, blobs blob.Store, treeID, from, to, expiredSize int64) error {
+	rows, err := tx.QueryContext(ctx, selectPrunedLocatorsSQL, treeID, from, to, expiredSize)
+	if err != nil {
+		return err
+	}
+	var locators []string
+	for rows.Next() {
+		var locator string
+		if err := rows.Scan(&locator); err != nil {
+			rows.Close()
+			return err
+		}
+		locators = append(locators, locator)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return err
+	}
+	rows.Close()
+
+	if len(locators) > 0 && blobs == nil {
+		return fmt.Errorf("tree %v has leaf values in a blob store, but none is configured", treeID)
+	}
+	for _, locator := range locators {
+		if err := blobs.Delete(ctx, locator); err != nil {
+			return fmt.Errorf("failed to delete leaf value %v from blob store: %v", locator, err)
+		}
+	}
+	return nil
+}
+
+// PruneExpiredLeaves runs PruneExpiredLeafData against db every interval until ctx is
+// done. Running it in more than one process per database is safe, but wasted work.
+func PruneExpiredLeaves(ctx context.Context, db *sql.DB, blobs blob.Store, interval time.Duration, batchSize int64, timeSource util.TimeSource) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := PruneExpiredLeafData(ctx, db, blobs, timeSource.Now(), batchSize); err != nil {
+			glog.Warningf("Failed to prune expired leaf data: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
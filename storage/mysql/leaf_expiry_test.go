@@ -0,0 +1,110 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	storageto "github.com/google/trillian/storage/testonly"
+)
+
+func TestPruneExpiredLeafData(t *testing.T) {
+	cleanTestDB(DB)
+	tree := *storageto.LogTree
+	tree.LeafRetentionSeconds = 3600
+	newTree, err := createTree(DB, &tree)
+	if err != nil {
+		t.Fatalf("createTree()=%v", err)
+	}
+	logID := newTree.TreeId
+	unretainedID := createLogForTests(DB)
+	ctx := context.Background()
+	now := time.Unix(1500000000, 0)
+
+	// Leaves 0 to 2 are distinct, leaf 3 is a duplicate of leaf 1. Only the first two
+	// were integrated more than an hour ago.
+	var ids [][]byte
+	for i := int64(0); i < 3; i++ {
+		id := []byte(fmt.Sprintf("%032d", i))
+		ids = append(ids, id)
+		createFakeLeaf(DB, logID, id, id, []byte("value"), someExtraData, i, t)
+		createFakeLeaf(DB, unretainedID, id, id, []byte("value"), someExtraData, i, t)
+	}
+	if _, err := DB.Exec("INSERT INTO SequencedLeafData(TreeId, SequenceNumber, LeafIdentityHash, MerkleLeafHash) VALUES(?,?,?,?)", logID, 3, ids[1], ids[1]); err != nil {
+		t.Fatalf("Failed to create duplicate leaf: %v", err)
+	}
+	for _, head := range []struct {
+		logID, size, revision int64
+		signedAt              time.Time
+	}{
+		{logID: logID, size: 2, revision: 1, signedAt: now.Add(-2 * time.Hour)},
+		{logID: logID, size: 4, revision: 2, signedAt: now.Add(-time.Minute)},
+		{logID: unretainedID, size: 3, revision: 1, signedAt: now.Add(-2 * time.Hour)},
+	} {
+		if _, err := DB.Exec("INSERT INTO TreeHead(TreeId, TreeHeadTimestamp, TreeSize, RootHash, RootSignature, TreeRevision) VALUES(?,?,?,?,?,?)",
+			head.logID, head.signedAt.UnixNano(), head.size, dummyHash, []byte("sig"), head.revision); err != nil {
+			t.Fatalf("Failed to create tree head: %v", err)
+		}
+	}
+
+	// Leaf 0's value is offloaded, so it can't be pruned without the blob store.
+	blobs := memoryBlobStore{"blob0": []byte("value")}
+	if _, err := DB.Exec("UPDATE LeafData SET LeafValue='', LeafValueLocator='blob0' WHERE TreeId=? AND LeafIdentityHash=?", logID, ids[0]); err != nil {
+		t.Fatalf("Failed to offload leaf value: %v", err)
+	}
+	if got, err := PruneExpiredLeafData(ctx, DB, nil, now, 1); err == nil || got != 0 {
+		t.Fatalf("PruneExpiredLeafData() without a blob store=%d, %v, want 0, error", got, err)
+	}
+
+	if got, err := PruneExpiredLeafData(ctx, DB, blobs, now, 1); err != nil || got != 1 {
+		t.Fatalf("PruneExpiredLeafData()=%d, %v, want 1, nil", got, err)
+	}
+	if len(blobs) != 0 {
+		t.Errorf("blob store holds %v after pruning, want nothing", blobs)
+	}
+	// Pruning carries on from where it got to.
+	if got, err := PruneExpiredLeafData(ctx, DB, blobs, now, 1); err != nil || got != 0 {
+		t.Fatalf("PruneExpiredLeafData()=%d, %v, want 0, nil", got, err)
+	}
+
+	s := NewLogStorage(DB)
+	for _, test := range []struct {
+		logID       int64
+		wantExpired []bool
+	}{
+		// Leaf 1 is kept for its duplicate, which is within the retention window.
+		{logID: logID, wantExpired: []bool{true, false, false, false}},
+		{logID: unretainedID, wantExpired: []bool{false, false, false}},
+	} {
+		tx := beginLogTx(s, test.logID, t)
+		for i, want := range test.wantExpired {
+			leaves, err := tx.GetLeavesByIndex([]int64{int64(i)})
+			if err != nil {
+				t.Fatalf("GetLeavesByIndex(%d)=%v", i, err)
+			}
+			leaf := leaves[0]
+			if leaf.Expired != want || (len(leaf.LeafValue) == 0) != want || (len(leaf.ExtraData) == 0) != want {
+				t.Errorf("%d: GetLeavesByIndex(%d)=%v, want expired=%v", test.logID, i, leaf, want)
+			}
+			if len(leaf.MerkleLeafHash) == 0 || len(leaf.LeafIdentityHash) == 0 {
+				t.Errorf("%d: GetLeavesByIndex(%d)=%v, want hashes kept", test.logID, i, leaf)
+			}
+		}
+		commit(tx, t)
+	}
+}
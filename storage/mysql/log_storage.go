@@ -34,45 +34,90 @@ import (
 	"github.com/google/trillian/merkle"
 	"github.com/google/trillian/monitoring/metric"
 	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/blob"
 	"github.com/google/trillian/storage/cache"
+	"github.com/google/trillian/storage/envelope"
 )
 
 const (
-	getTreePropertiesSQL  = "SELECT DuplicatePolicy FROM Trees WHERE TreeId=?"
-	selectQueuedLeavesSQL = `SELECT LeafIdentityHash,MerkleLeafHash
+	getTreePropertiesSQL = `SELECT TreeState,TreeType,DuplicatePolicy,LeafIdentityHashStrategy,LeafIdentityHashKey,LeafCompression,LENGTH(PrivateKey)=0
+			FROM Trees LEFT JOIN TreeControl ON Trees.TreeId = TreeControl.TreeId
+			WHERE Trees.TreeId=?`
+	selectQueuedLeavesSQL = `SELECT LeafIdentityHash,MerkleLeafHash,MessageId
 			FROM Unsequenced
 			WHERE TreeID=?
 			AND QueueTimestampNanos<=?
 			ORDER BY QueueTimestampNanos,LeafIdentityHash ASC LIMIT ?`
-	insertUnsequencedLeafSQL = `INSERT INTO LeafData(TreeId,LeafIdentityHash,LeafValue,ExtraData)
-			VALUES(?,?,?,?) ON DUPLICATE KEY UPDATE LeafIdentityHash=LeafIdentityHash`
-	insertUnsequencedLeafSQLNoDuplicates = `INSERT INTO LeafData(TreeId,LeafIdentityHash,LeafValue,ExtraData)
-			VALUES(?,?,?,?)`
+	// The inserts are expanded to one row placeholder per row, see treeTX.insertRows.
+	// Queueing a duplicate of a leaf whose data has expired restores the data. The
+	// assignments are made in order, so Expired has to be the last.
+	insertUnsequencedLeafSQL = `INSERT INTO LeafData(TreeId,LeafIdentityHash,LeafValue,ExtraData,Compression,LeafValueLocator,Encrypted)
+			VALUES ` + placeholderSQL + ` ON DUPLICATE KEY UPDATE
+			LeafValue=IF(Expired,VALUES(LeafValue),LeafValue),
+			ExtraData=IF(Expired,VALUES(ExtraData),ExtraData),
+			Compression=IF(Expired,VALUES(Compression),Compression),
+			LeafValueLocator=IF(Expired,VALUES(LeafValueLocator),LeafValueLocator),
+			Encrypted=IF(Expired,VALUES(Encrypted),Encrypted),
+			Expired=FALSE`
+	insertUnsequencedLeafSQLNoDuplicates = `INSERT INTO LeafData(TreeId,LeafIdentityHash,LeafValue,ExtraData,Compression,LeafValueLocator,Encrypted)
+			VALUES ` + placeholderSQL
+	leafDataRowSQL            = "(?,?,?,?,?,?,?)"
 	insertUnsequencedEntrySQL = `INSERT INTO Unsequenced(TreeId,LeafIdentityHash,MerkleLeafHash,MessageId,QueueTimestampNanos)
-			VALUES(?,?,?,?,?)`
+			VALUES ` + placeholderSQL
+	unsequencedRowSQL      = "(?,?,?,?,?)"
 	insertSequencedLeafSQL = `INSERT INTO SequencedLeafData(TreeId,LeafIdentityHash,MerkleLeafHash,SequenceNumber)
-			VALUES(?,?,?,?)`
+			VALUES ` + placeholderSQL
+	sequencedLeafRowSQL = "(?,?,?,?)"
+	// Pre-ordered leaves are already in SequencedLeafData, so dequeueing them is a matter of
+	// reading the rows beyond the current tree size.
+	selectPreorderedLeavesSQL = `SELECT SequenceNumber,LeafIdentityHash,MerkleLeafHash
+			FROM SequencedLeafData
+			WHERE TreeId=?
+			AND SequenceNumber>=?
+			ORDER BY SequenceNumber ASC LIMIT ?`
 	selectSequencedLeafCountSQL  = "SELECT COUNT(*) FROM SequencedLeafData WHERE TreeId=?"
+	selectUnsequencedStatsSQL    = "SELECT COUNT(*),MIN(QueueTimestampNanos) FROM Unsequenced WHERE TreeId=?"
+	selectFinalizedTreeSizeSQL   = "SELECT FinalizeTimeMillis,FinalizedTreeSize FROM TreeControl WHERE TreeId=?"
 	selectLatestSignedLogRootSQL = `SELECT TreeHeadTimestamp,TreeSize,RootHash,TreeRevision,RootSignature
 			FROM TreeHead WHERE TreeId=?
 			ORDER BY TreeHeadTimestamp DESC LIMIT 1`
+	selectSignedLogRootAtRevisionSQL = `SELECT TreeHeadTimestamp,TreeSize,RootHash,TreeRevision,RootSignature
+			FROM TreeHead WHERE TreeId=? AND TreeRevision=?`
+	selectSignedLogRootAtSizeSQL = `SELECT TreeHeadTimestamp,TreeSize,RootHash,TreeRevision,RootSignature
+			FROM TreeHead WHERE TreeId=? AND TreeSize=? AND TreeRevision<=?
+			ORDER BY TreeRevision DESC LIMIT 1`
+	insertWitnessSignatureSQL = `INSERT INTO WitnessSignature(TreeId,TreeRevision,WitnessName,Signature)
+			VALUES(?,?,?,?) ON DUPLICATE KEY UPDATE Signature=VALUES(Signature)`
+	insertObservedTreeHeadSQL = `INSERT INTO ObservedTreeHead(TreeId,TreeHeadTimestamp,TreeSize,RootHash,RootSignature,Consistent,ObservedTimestampNanos)
+			VALUES(?,?,?,?,?,?,?) ON DUPLICATE KEY UPDATE TreeId=TreeId`
 
 	// These statements need to be expanded to provide the correct number of parameter placeholders.
-	deleteUnsequencedSQL   = "DELETE FROM Unsequenced WHERE LeafIdentityHash IN (<placeholder>) AND TreeId = ?"
-	selectLeavesByIndexSQL = `SELECT s.MerkleLeafHash,l.LeafIdentityHash,l.LeafValue,s.SequenceNumber,l.ExtraData
+	// Queue entries are deleted by MessageId as well as LeafIdentityHash, as a log which
+	// allows duplicates may hold several entries for a leaf, not all of them dequeued.
+	deleteUnsequencedSQL   = "DELETE FROM Unsequenced WHERE (LeafIdentityHash,MessageId) IN (<placeholder>) AND TreeId = ?"
+	selectLeavesByIndexSQL = `SELECT s.MerkleLeafHash,l.LeafIdentityHash,l.LeafValue,s.SequenceNumber,l.ExtraData,l.Compression,l.LeafValueLocator,l.Encrypted,l.Expired
 			FROM LeafData l,SequencedLeafData s
 			WHERE l.LeafIdentityHash = s.LeafIdentityHash
 			AND s.SequenceNumber IN (` + placeholderSQL + `) AND l.TreeId = ? AND s.TreeId = l.TreeId`
-	selectLeavesByMerkleHashSQL = `SELECT s.MerkleLeafHash,l.LeafIdentityHash,l.LeafValue,s.SequenceNumber,l.ExtraData
+	selectLeavesByMerkleHashSQL = `SELECT s.MerkleLeafHash,l.LeafIdentityHash,l.LeafValue,s.SequenceNumber,l.ExtraData,l.Compression,l.LeafValueLocator,l.Encrypted,l.Expired
 			FROM LeafData l,SequencedLeafData s
 			WHERE l.LeafIdentityHash = s.LeafIdentityHash
 			AND s.MerkleLeafHash IN (` + placeholderSQL + `) AND l.TreeId = ? AND s.TreeId = l.TreeId`
+	selectLatestWitnessedRevisionSQL = `SELECT TreeRevision
+			FROM WitnessSignature
+			WHERE TreeId=? AND WitnessName IN (` + placeholderSQL + `)
+			GROUP BY TreeRevision HAVING COUNT(*)>=?
+			ORDER BY TreeRevision DESC LIMIT 1`
+	selectWitnessSignaturesSQL = `SELECT WitnessName,Signature
+			FROM WitnessSignature
+			WHERE TreeId=? AND TreeRevision=? AND WitnessName IN (` + placeholderSQL + `)
+			ORDER BY WitnessName`
 	// TODO(drysdale): rework the code so the dummy hash isn't needed (e.g. this assumes hash size is 32)
 	dummyMerkleLeafHash = "00000000000000000000000000000000"
 	// This statement returns a dummy Merkle leaf hash value (which must be
 	// of the right size) so that its signature matches that of the other
 	// leaf-selection statements.
-	selectLeavesByLeafIdentityHashSQL = `SELECT '` + dummyMerkleLeafHash + `',l.LeafIdentityHash,l.LeafValue,-1,l.ExtraData
+	selectLeavesByLeafIdentityHashSQL = `SELECT '` + dummyMerkleLeafHash + `',l.LeafIdentityHash,l.LeafValue,-1,l.ExtraData,l.Compression,l.LeafValueLocator,l.Encrypted,l.Expired
 			FROM LeafData l
 			WHERE l.LeafIdentityHash IN (` + placeholderSQL + `) AND l.TreeId = ?`
 
@@ -82,6 +127,10 @@ const (
 
 	// Error code returned by driver when inserting a duplicate row
 	errNumDuplicate = 1062
+	// Error codes returned when a transaction is rolled back because of lock
+	// contention, it can be retried from the start.
+	errNumLockWaitTimeout = 1205
+	errNumDeadlock        = 1213
 )
 
 var (
@@ -93,12 +142,55 @@ var (
 
 type mySQLLogStorage struct {
 	*mySQLTreeStorage
+	blobs         blob.Store
+	blobThreshold int
+	dataKeys      *dataKeys
+}
+
+// StorageOptions configures optional behaviour of MySQL storage.
+type StorageOptions struct {
+	// SubtreeCache, if not nil, keeps the subtrees read by a transaction for later ones.
+	// It may be shared by storage for a database and its replicas.
+	SubtreeCache *cache.SharedSubtreeCache
+	// BlobStore, if not nil, keeps leaf values larger than BlobThreshold bytes, once
+	// compressed, out of the database. Storage reading leaves offloaded by another
+	// instance needs the same store.
+	BlobStore     blob.Store
+	BlobThreshold int
+	// KeyWrapper, if not nil, enables encryption of leaf data with per-tree data keys,
+	// which it wraps. Storage reading encrypted leaves needs the same KeyWrapper.
+	KeyWrapper envelope.KeyWrapper
+	// SlowOperationThreshold, if greater than 0, is how long a storage operation can
+	// take before it's logged as slow.
+	SlowOperationThreshold time.Duration
+	// StatementCacheSize is the most prepared statements kept, DefaultStatementCacheSize
+	// if 0 and unlimited if less than 0.
+	StatementCacheSize int
+	// TxTimeout, if greater than 0, is how long a transaction can be open before it's
+	// rolled back. Committing it afterwards fails with a DeadlineExceeded error.
+	TxTimeout time.Duration
 }
 
 // NewLogStorage creates a mySQLLogStorage instance for the specified MySQL URL.
 func NewLogStorage(db *sql.DB) storage.LogStorage {
+	return NewLogStorageWithOptions(db, StorageOptions{})
+}
+
+// NewLogStorageWithOptions creates a mySQLLogStorage instance configured by opts.
+func NewLogStorageWithOptions(db *sql.DB, opts StorageOptions) storage.LogStorage {
+	cacheSize := opts.StatementCacheSize
+	if cacheSize == 0 {
+		cacheSize = DefaultStatementCacheSize
+	}
+	ts := newTreeStorage(db, cacheSize)
+	ts.sharedCache = opts.SubtreeCache
+	ts.slowOpThreshold = opts.SlowOperationThreshold
+	ts.txTimeout = opts.TxTimeout
 	return &mySQLLogStorage{
-		mySQLTreeStorage: newTreeStorage(db),
+		mySQLTreeStorage: ts,
+		blobs:            opts.BlobStore,
+		blobThreshold:    opts.BlobThreshold,
+		dataKeys:         newDataKeys(db, opts.KeyWrapper),
 	}
 }
 
@@ -123,11 +215,19 @@ func (m *mySQLLogStorage) getLeavesByLeafIdentityHashStmt(num int) (*sql.Stmt, e
 }
 
 func (m *mySQLLogStorage) getDeleteUnsequencedStmt(num int) (*sql.Stmt, error) {
-	return m.getStmt(deleteUnsequencedSQL, num, "?", "?")
+	return m.getStmt(deleteUnsequencedSQL, num, "(?,?)", "(?,?)")
+}
+
+func (m *mySQLLogStorage) getLatestWitnessedRevisionStmt(num int) (*sql.Stmt, error) {
+	return m.getStmt(selectLatestWitnessedRevisionSQL, num, "?", "?")
 }
 
-func getActiveLogIDsInternal(tx *sql.Tx, sql string) ([]int64, error) {
-	rows, err := tx.Query(sql)
+func (m *mySQLLogStorage) getWitnessSignaturesStmt(num int) (*sql.Stmt, error) {
+	return m.getStmt(selectWitnessSignaturesSQL, num, "?", "?")
+}
+
+func getActiveLogIDsInternal(ctx context.Context, tx *sql.Tx, sql string) ([]int64, error) {
+	rows, err := tx.QueryContext(ctx, sql)
 	if err != nil {
 		return nil, err
 	}
@@ -149,26 +249,27 @@ func getActiveLogIDsInternal(tx *sql.Tx, sql string) ([]int64, error) {
 	return logIDs, nil
 }
 
-func getActiveLogIDs(tx *sql.Tx) ([]int64, error) {
-	return getActiveLogIDsInternal(tx, selectActiveLogsSQL)
+func getActiveLogIDs(ctx context.Context, tx *sql.Tx) ([]int64, error) {
+	return getActiveLogIDsInternal(ctx, tx, selectActiveLogsSQL)
 }
 
-func getActiveLogIDsWithPendingWork(tx *sql.Tx) ([]int64, error) {
-	return getActiveLogIDsInternal(tx, selectActiveLogsWithUnsequencedSQL)
+func getActiveLogIDsWithPendingWork(ctx context.Context, tx *sql.Tx) ([]int64, error) {
+	return getActiveLogIDsInternal(ctx, tx, selectActiveLogsWithUnsequencedSQL)
 }
 
 // readOnlyLogTX implements storage.ReadOnlyLogTX
 type readOnlyLogTX struct {
-	tx *sql.Tx
+	tx  *sql.Tx
+	ctx context.Context
 }
 
 func (m *mySQLLogStorage) Snapshot(ctx context.Context) (storage.ReadOnlyLogTX, error) {
-	tx, err := m.db.Begin()
+	tx, err := m.db.BeginTx(ctx, nil)
 	if err != nil {
 		glog.Warningf("Could not start ReadOnlyLogTX: %s", err)
 		return nil, err
 	}
-	return &readOnlyLogTX{tx}, nil
+	return &readOnlyLogTX{tx: tx, ctx: ctx}, nil
 }
 
 func (t *readOnlyLogTX) Commit() error {
@@ -176,6 +277,7 @@ func (t *readOnlyLogTX) Commit() error {
 }
 
 func (t *readOnlyLogTX) Rollback() error {
+	countIfAbandoned(t.ctx)
 	return t.tx.Rollback()
 }
 
@@ -188,11 +290,11 @@ func (t *readOnlyLogTX) Close() error {
 }
 
 func (t *readOnlyLogTX) GetActiveLogIDs() ([]int64, error) {
-	return getActiveLogIDs(t.tx)
+	return getActiveLogIDs(t.ctx, t.tx)
 }
 
 func (t *readOnlyLogTX) GetActiveLogIDsWithPendingWork() ([]int64, error) {
-	return getActiveLogIDsWithPendingWork(t.tx)
+	return getActiveLogIDsWithPendingWork(t.ctx, t.tx)
 }
 
 func (m *mySQLLogStorage) hasher(treeID int64) (merkle.TreeHasher, error) {
@@ -201,15 +303,43 @@ func (m *mySQLLogStorage) hasher(treeID int64) (merkle.TreeHasher, error) {
 }
 
 func (m *mySQLLogStorage) beginInternal(ctx context.Context, treeID int64) (storage.LogTreeTX, error) {
-	// TODO(codingllama): Validate treeType
-	var duplicatePolicy string
-	if err := m.db.QueryRow(getTreePropertiesSQL, treeID).Scan(&duplicatePolicy); err != nil {
+	var treeState, treeType, duplicatePolicy, identityHashStrategy string
+	var identityHashKey []byte
+	// TreeControl is outer joined, so its columns may be NULL.
+	var compression sql.NullString
+	var verificationOnly bool
+	if err := m.db.QueryRowContext(ctx, getTreePropertiesSQL, treeID).Scan(&treeState, &treeType, &duplicatePolicy, &identityHashStrategy, &identityHashKey, &compression, &verificationOnly); err == sql.ErrNoRows {
+		return nil, storage.Error{ErrType: storage.TreeNotFound, Detail: fmt.Sprintf("tree %v not found", treeID), Cause: err}
+	} else if err != nil {
 		return nil, fmt.Errorf("failed to get tree row for treeID %v: %s", treeID, err)
 	}
+	ts, ok := trillian.TreeState_value[treeState]
+	if !ok {
+		return nil, fmt.Errorf("unknown TreeState: %v", treeState)
+	}
+	tt, ok := trillian.TreeType_value[treeType]
+	if !ok {
+		return nil, fmt.Errorf("unknown TreeType: %v", treeType)
+	}
+	if tt := trillian.TreeType(tt); tt != trillian.TreeType_LOG && tt != trillian.TreeType_PREORDERED_LOG {
+		return nil, fmt.Errorf("tree %v is not a log: %v", treeID, tt)
+	}
 	policy, ok := duplicatePolicyMap[duplicatePolicy]
 	if !ok {
 		return nil, fmt.Errorf("unknown DuplicatePolicy: %v", duplicatePolicy)
 	}
+	ihs, ok := trillian.LeafIdentityHashStrategy_value[identityHashStrategy]
+	if !ok {
+		return nil, fmt.Errorf("unknown LeafIdentityHashStrategy: %v", identityHashStrategy)
+	}
+	var leafCompression trillian.LeafCompression
+	if compression.Valid {
+		lc, ok := trillian.LeafCompression_value[compression.String]
+		if !ok {
+			return nil, fmt.Errorf("unknown LeafCompression: %v", compression.String)
+		}
+		leafCompression = trillian.LeafCompression(lc)
+	}
 
 	hasher, err := m.hasher(treeID)
 	if err != nil {
@@ -222,9 +352,16 @@ func (m *mySQLLogStorage) beginInternal(ctx context.Context, treeID int64) (stor
 	}
 
 	ltx := &logTreeTX{
-		treeTX:          ttx,
-		ls:              m,
-		duplicatePolicy: policy,
+		treeTX:           ttx,
+		ctx:              ctx,
+		ls:               m,
+		treeState:        trillian.TreeState(ts),
+		treeType:         trillian.TreeType(tt),
+		duplicatePolicy:  policy,
+		identityHash:     trillian.LeafIdentityHashStrategy(ihs),
+		identityHashKey:  identityHashKey,
+		leafCompression:  leafCompression,
+		verificationOnly: verificationOnly,
 	}
 
 	ltx.root, err = ltx.fetchLatestRoot()
@@ -251,9 +388,35 @@ func (m *mySQLLogStorage) SnapshotForTree(ctx context.Context, treeID int64) (st
 
 type logTreeTX struct {
 	treeTX
+	// ctx is the context the tx was started with, used for blob store requests.
+	ctx             context.Context
 	ls              *mySQLLogStorage
 	root            trillian.SignedLogRoot
+	treeState       trillian.TreeState
+	treeType        trillian.TreeType
 	duplicatePolicy trillian.DuplicatePolicy
+	// identityHash is the strategy the identity hashes of leaves written by this tx are
+	// computed with, keyed by identityHashKey.
+	identityHash    trillian.LeafIdentityHashStrategy
+	identityHashKey []byte
+	// leafCompression is applied to the leaf data written by this tx.
+	leafCompression trillian.LeafCompression
+	// verificationOnly is set for trees with no private key, which can't be sequenced.
+	verificationOnly bool
+}
+
+// checkWritable returns an error unless leaves can be added to the tree, which is only
+// the case while it's ACTIVE. In particular, a frozen log is finalized once its queue
+// has been drained, so nothing may be queued to it. Nothing may be added to
+// verification-only trees either, as it would never be sequenced.
+func (t *logTreeTX) checkWritable() error {
+	if t.treeState != trillian.TreeState_ACTIVE {
+		return storage.Error{ErrType: storage.TreeNotWritable, Detail: fmt.Sprintf("tree %v is %v, not ACTIVE", t.treeID, t.treeState)}
+	}
+	if t.verificationOnly {
+		return storage.Error{ErrType: storage.TreeNotWritable, Detail: fmt.Sprintf("tree %v is verification-only", t.treeID)}
+	}
+	return nil
 }
 
 func (t *logTreeTX) ReadRevision() int64 {
@@ -265,15 +428,20 @@ func (t *logTreeTX) WriteRevision() int64 {
 }
 
 func (t *logTreeTX) DequeueLeaves(limit int, cutoffTime time.Time) ([]*trillian.LogLeaf, error) {
-	stx, err := t.tx.Prepare(selectQueuedLeavesSQL)
+	defer t.ts.observe(opDequeueLeaves, t.treeID, time.Now())
+	if t.treeType == trillian.TreeType_PREORDERED_LOG {
+		return t.dequeuePreorderedLeaves(limit)
+	}
+
+	stx, err := t.stmt(selectQueuedLeavesSQL)
 
 	if err != nil {
 		glog.Warningf("Failed to prepare dequeue select: %s", err)
 		return nil, err
 	}
 
-	leaves := make([]*trillian.LogLeaf, 0, limit)
-	rows, err := stx.Query(t.treeID, cutoffTime.UnixNano(), limit)
+	entries := make([]queuedEntry, 0, limit)
+	rows, err := stx.QueryContext(t.ctx, t.treeID, cutoffTime.UnixNano(), limit)
 
 	if err != nil {
 		glog.Warningf("Failed to select rows for work: %s", err)
@@ -285,8 +453,9 @@ func (t *logTreeTX) DequeueLeaves(limit int, cutoffTime time.Time) ([]*trillian.
 	for rows.Next() {
 		var leafIDHash []byte
 		var merkleHash []byte
+		var messageID []byte
 
-		err := rows.Scan(&leafIDHash, &merkleHash)
+		err := rows.Scan(&leafIDHash, &merkleHash, &messageID)
 
 		if err != nil {
 			glog.Warningf("Error scanning work rows: %s", err)
@@ -304,7 +473,7 @@ func (t *logTreeTX) DequeueLeaves(limit int, cutoffTime time.Time) ([]*trillian.
 			LeafIdentityHash: leafIDHash,
 			MerkleLeafHash:   merkleHash,
 		}
-		leaves = append(leaves, leaf)
+		entries = append(entries, queuedEntry{leaf: leaf, messageID: messageID})
 	}
 
 	if rows.Err() != nil {
@@ -313,20 +482,125 @@ func (t *logTreeTX) DequeueLeaves(limit int, cutoffTime time.Time) ([]*trillian.
 
 	// The convention is that if leaf processing succeeds (by committing this tx)
 	// then the unsequenced entries for them are removed
-	if len(leaves) > 0 {
-		err = t.removeSequencedLeaves(leaves)
+	if len(entries) > 0 {
+		err = t.removeSequencedLeaves(entries)
 	}
 
 	if err != nil {
 		return nil, err
 	}
 
+	leaves := make([]*trillian.LogLeaf, 0, len(entries))
+	for _, entry := range entries {
+		leaves = append(leaves, entry.leaf)
+	}
+
 	dequeuedCounter.Add(int64(len(leaves)))
 
 	return leaves, nil
 }
 
+// dequeuePreorderedLeaves returns the contiguous run of leaves, stored by AddSequencedLeaves,
+// that starts at the current tree size. A gap in the sequence numbers ends the run, the
+// leaves after it will be returned once the missing ones have been added.
+func (t *logTreeTX) dequeuePreorderedLeaves(limit int) ([]*trillian.LogLeaf, error) {
+	stx, err := t.stmt(selectPreorderedLeavesSQL)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := stx.QueryContext(t.ctx, t.treeID, t.root.TreeSize, limit)
+	if err != nil {
+		glog.Warningf("Failed to select pre-ordered leaves: %s", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	leaves := make([]*trillian.LogLeaf, 0, limit)
+	next := t.root.TreeSize
+	for rows.Next() {
+		leaf := &trillian.LogLeaf{}
+		if err := rows.Scan(&leaf.LeafIndex, &leaf.LeafIdentityHash, &leaf.MerkleLeafHash); err != nil {
+			glog.Warningf("Error scanning pre-ordered leaf rows: %s", err)
+			return nil, err
+		}
+		if leaf.LeafIndex != next {
+			break
+		}
+		leaves = append(leaves, leaf)
+		next++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	dequeuedCounter.Add(int64(len(leaves)))
+	return leaves, nil
+}
+
+// AddSequencedLeaves stores leaves that already carry their final LeafIndex.
+func (t *logTreeTX) AddSequencedLeaves(leaves []*trillian.LogLeaf) error {
+	defer t.ts.observe(opAddSequencedLeaves, t.treeID, time.Now())
+	if t.treeType != trillian.TreeType_PREORDERED_LOG {
+		return fmt.Errorf("AddSequencedLeaves called on tree %v of type %v, want %v", t.treeID, t.treeType, trillian.TreeType_PREORDERED_LOG)
+	}
+	if err := t.checkWritable(); err != nil {
+		return err
+	}
+	if err := storage.SetLeafIdentityHashes(t.identityHash, t.identityHashKey, leaves); err != nil {
+		return err
+	}
+	for _, leaf := range leaves {
+		if len(leaf.LeafIdentityHash) != t.hashSizeBytes {
+			return fmt.Errorf("sequenced leaf must have a leaf ID hash of length %d", t.hashSizeBytes)
+		}
+		if leaf.LeafIndex < t.root.TreeSize {
+			return storage.Error{ErrType: storage.OutOfRange, Detail: fmt.Sprintf("sequenced leaf index %d is below the current tree size %d", leaf.LeafIndex, t.root.TreeSize)}
+		}
+	}
+
+	dataRows := make([][]interface{}, 0, len(leaves))
+	sequencedRows := make([][]interface{}, 0, len(leaves))
+	for _, leaf := range leaves {
+		row, err := t.leafDataRow(leaf)
+		if err != nil {
+			return err
+		}
+		dataRows = append(dataRows, row)
+		sequencedRows = append(sequencedRows, []interface{}{t.treeID, leaf.LeafIdentityHash, leaf.MerkleLeafHash, leaf.LeafIndex})
+	}
+
+	// The order of the tree is decided by the submitter, who may well have legitimate
+	// duplicates (e.g. a mirrored log that allowed them), so the leaf data is shared.
+	if err := t.insertRows(insertUnsequencedLeafSQL, leafDataRowSQL, dataRows); err != nil {
+		glog.Warningf("Error inserting sequenced leaves into LeafData: %s", err)
+		return err
+	}
+	dups, err := t.insertRowsFindingDuplicates(insertSequencedLeafSQL, sequencedLeafRowSQL, sequencedRows)
+	if err != nil {
+		glog.Warningf("Error inserting sequenced leaves into SequencedLeafData: %s", err)
+		return err
+	}
+	for i, dup := range dups {
+		if dup {
+			return storage.Error{ErrType: storage.DuplicateLeaf, Detail: fmt.Sprintf("a leaf already exists at index %d", leaves[i].LeafIndex)}
+		}
+	}
+	for _, row := range dataRows {
+		t.usage.LeafBytes += leafRowBytes(row)
+	}
+
+	queuedCounter.Add(int64(len(leaves)))
+	return nil
+}
+
 func (t *logTreeTX) QueueLeaves(leaves []*trillian.LogLeaf, queueTimestamp time.Time) ([]*trillian.LogLeaf, error) {
+	defer t.ts.observe(opQueueLeaves, t.treeID, time.Now())
+	if err := t.checkWritable(); err != nil {
+		return nil, err
+	}
+	if err := storage.SetLeafIdentityHashes(t.identityHash, t.identityHashKey, leaves); err != nil {
+		return nil, err
+	}
 	// Don't accept batches if any of the leaves are invalid.
 	for _, leaf := range leaves {
 		if len(leaf.LeafIdentityHash) != t.hashSizeBytes {
@@ -354,54 +628,44 @@ func (t *logTreeTX) QueueLeaves(leaves []*trillian.LogLeaf, queueTimestamp time.
 	existingCount := 0
 	existingLeaves := make([]*trillian.LogLeaf, len(leaves))
 
+	// Create the unsequenced leaf data entries. We don't use INSERT IGNORE because this
+	// can suppress errors unrelated to key collisions. We don't use REPLACE because
+	// if there's ever a hash collision it will do the wrong thing and it also
+	// causes a DELETE / INSERT, which is undesirable.
+	dataRows := make([][]interface{}, 0, len(orderedLeaves))
+	for _, leafPos := range orderedLeaves {
+		row, err := t.leafDataRow(leafPos.leaf)
+		if err != nil {
+			return nil, err
+		}
+		dataRows = append(dataRows, row)
+	}
+	dups, err := t.insertRowsFindingDuplicates(insertSQL, leafDataRowSQL, dataRows)
+	if err != nil {
+		glog.Warningf("Error inserting into LeafData: %s", err)
+		return nil, err
+	}
+
+	// Create the work queue entries for the leaves which weren't already there.
+	queueRows := make([][]interface{}, 0, len(orderedLeaves))
 	for i, leafPos := range orderedLeaves {
 		leaf := leafPos.leaf
-		// Create the unsequenced leaf data entry. We don't use INSERT IGNORE because this
-		// can suppress errors unrelated to key collisions. We don't use REPLACE because
-		// if there's ever a hash collision it will do the wrong thing and it also
-		// causes a DELETE / INSERT, which is undesirable.
-		_, err := t.tx.Exec(insertSQL, t.treeID, leaf.LeafIdentityHash, leaf.LeafValue, leaf.ExtraData)
-		if isDuplicateErr(err) {
+		if dups[i] {
 			// Remember the duplicate leaf, using the requested leaf for now.
 			existingLeaves[leafPos.idx] = leaf
 			existingCount++
 			continue
 		}
+		t.usage.LeafBytes += leafRowBytes(dataRows[i])
+		messageID, err := t.messageID(leaf)
 		if err != nil {
-			glog.Warningf("Error inserting %d into LeafData: %s", i, err)
 			return nil, err
 		}
-
-		// Create the work queue entry
-		// Message ids only need to guard against duplicates for the time that entries are
-		// in the unsequenced queue, which should be short, but we'll still use a strong hash.
-		// TODO(alcutter): get this from somewhere else
-		hasher := sha256.New()
-
-		// We use a fixed zero message id if the log disallows duplicates otherwise a random one.
-		// the fixed id will collide if dups submitted when not allowed so the insert won't succeed
-		// and everything will get rolled back
-		messageIDBytes := make([]byte, 8)
-
-		if t.duplicatePolicy == trillian.DuplicatePolicy_DUPLICATES_ALLOWED {
-			_, err := rand.Read(messageIDBytes)
-			if err != nil {
-				glog.Warningf("Failed to get a random message id: %s", err)
-				return nil, err
-			}
-		}
-
-		hasher.Write(messageIDBytes)
-		binary.Write(hasher, binary.LittleEndian, t.treeID)
-		hasher.Write(leaf.LeafIdentityHash)
-		messageID := hasher.Sum(nil)
-
-		_, err = t.tx.Exec(insertUnsequencedEntrySQL,
-			t.treeID, leaf.LeafIdentityHash, leaf.MerkleLeafHash, messageID, queueTimestamp.UnixNano())
-		if err != nil {
-			glog.Warningf("Error inserting into Unsequenced: %s", err)
-			return nil, fmt.Errorf("Unsequenced: %v", err)
-		}
+		queueRows = append(queueRows, []interface{}{t.treeID, leaf.LeafIdentityHash, leaf.MerkleLeafHash, messageID, queueTimestamp.UnixNano()})
+	}
+	if err := t.insertRows(insertUnsequencedEntrySQL, unsequencedRowSQL, queueRows); err != nil {
+		glog.Warningf("Error inserting into Unsequenced: %s", err)
+		return nil, err
 	}
 
 	if existingCount == 0 {
@@ -445,10 +709,86 @@ func (t *logTreeTX) QueueLeaves(leaves []*trillian.LogLeaf, queueTimestamp time.
 	return existingLeaves, nil
 }
 
+// leafDataRow returns the arguments of the LeafData row holding leaf, compressed,
+// encrypted and offloaded as the tree is configured to.
+func (t *logTreeTX) leafDataRow(leaf *trillian.LogLeaf) ([]interface{}, error) {
+	value, extraData, err := compressLeaf(t.leafCompression, leaf)
+	if err != nil {
+		return nil, err
+	}
+	value, extraData, encrypted, err := t.encryptLeafData(leaf.LeafIdentityHash, value, extraData)
+	if err != nil {
+		return nil, err
+	}
+	value, locator, err := t.offloadLeafValue(leaf.LeafIdentityHash, value)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{t.treeID, leaf.LeafIdentityHash, value, extraData, t.leafCompression.String(), locator, encrypted}, nil
+}
+
+// messageID returns the MessageId of the work queue entry for leaf.
+func (t *logTreeTX) messageID(leaf *trillian.LogLeaf) ([]byte, error) {
+	// Message ids only need to guard against duplicates for the time that entries are
+	// in the unsequenced queue, which should be short, but we'll still use a strong hash.
+	// TODO(alcutter): get this from somewhere else
+	hasher := sha256.New()
+
+	// We use a fixed zero message id if the log disallows duplicates otherwise a random one.
+	// the fixed id will collide if dups submitted when not allowed so the insert won't succeed
+	// and everything will get rolled back
+	messageIDBytes := make([]byte, 8)
+
+	if t.duplicatePolicy == trillian.DuplicatePolicy_DUPLICATES_ALLOWED {
+		_, err := rand.Read(messageIDBytes)
+		if err != nil {
+			glog.Warningf("Failed to get a random message id: %s", err)
+			return nil, err
+		}
+	}
+
+	hasher.Write(messageIDBytes)
+	binary.Write(hasher, binary.LittleEndian, t.treeID)
+	hasher.Write(leaf.LeafIdentityHash)
+	return hasher.Sum(nil), nil
+}
+
+// insertRowsFindingDuplicates inserts rows like insertRows, but reports which of them
+// collided with an existing row instead of failing. Rows are inserted in batches, but a
+// batch with a duplicate is rolled back and retried one row at a time to find it.
+func (t *logTreeTX) insertRowsFindingDuplicates(statement, row string, rows [][]interface{}) ([]bool, error) {
+	dups := make([]bool, len(rows))
+	for start := 0; start < len(rows); start += maxBatchRows {
+		end := start + maxBatchRows
+		if end > len(rows) {
+			end = len(rows)
+		}
+		err := t.insertRows(statement, row, rows[start:end])
+		if !isDuplicateErr(err) {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		// A failed statement is rolled back by itself, the rest of the transaction stands.
+		for i := start; i < end; i++ {
+			err := t.insertRows(statement, row, rows[i:i+1])
+			if isDuplicateErr(err) {
+				dups[i] = true
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return dups, nil
+}
+
 func (t *logTreeTX) GetSequencedLeafCount() (int64, error) {
 	var sequencedLeafCount int64
 
-	err := t.tx.QueryRow(selectSequencedLeafCountSQL, t.treeID).Scan(&sequencedLeafCount)
+	err := t.tx.QueryRowContext(t.ctx, selectSequencedLeafCountSQL, t.treeID).Scan(&sequencedLeafCount)
 
 	if err != nil {
 		glog.Warningf("Error getting sequenced leaf count: %s", err)
@@ -457,18 +797,47 @@ func (t *logTreeTX) GetSequencedLeafCount() (int64, error) {
 	return sequencedLeafCount, err
 }
 
+func (t *logTreeTX) GetUnsequencedStats() (int64, time.Time, error) {
+	var count int64
+	// MIN() is NULL when there are no queued leaves.
+	var oldestNanos sql.NullInt64
+	if err := t.tx.QueryRowContext(t.ctx, selectUnsequencedStatsSQL, t.treeID).Scan(&count, &oldestNanos); err != nil {
+		glog.Warningf("Error getting unsequenced leaf stats: %s", err)
+		return 0, time.Time{}, err
+	}
+	if !oldestNanos.Valid {
+		return count, time.Time{}, nil
+	}
+	return count, time.Unix(0, oldestNanos.Int64), nil
+}
+
+func (t *logTreeTX) FinalizedTreeSize() (int64, bool, error) {
+	var finalizeMillis, size int64
+	// Trees may not have a TreeControl row, see readTree.
+	err := t.tx.QueryRowContext(t.ctx, selectFinalizedTreeSizeSQL, t.treeID).Scan(&finalizeMillis, &size)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		glog.Warningf("Error getting finalized tree size: %s", err)
+		return 0, false, err
+	}
+	return size, finalizeMillis != 0, nil
+}
+
 func (t *logTreeTX) GetLeavesByIndex(leaves []int64) ([]*trillian.LogLeaf, error) {
+	defer t.ts.observe(opGetLeavesByIndex, t.treeID, time.Now())
 	tmpl, err := t.ls.getLeavesByIndexStmt(len(leaves))
 	if err != nil {
 		return nil, err
 	}
-	stx := t.tx.Stmt(tmpl)
+	stx := t.tx.StmtContext(t.ctx, tmpl)
 	var args []interface{}
 	for _, nodeID := range leaves {
 		args = append(args, interface{}(int64(nodeID)))
 	}
 	args = append(args, interface{}(t.treeID))
-	rows, err := stx.Query(args...)
+	rows, err := stx.QueryContext(t.ctx, args...)
 	if err != nil {
 		glog.Warningf("Failed to get leaves by idx: %s", err)
 		return nil, err
@@ -478,25 +847,47 @@ func (t *logTreeTX) GetLeavesByIndex(leaves []int64) ([]*trillian.LogLeaf, error
 	defer rows.Close()
 	for rows.Next() {
 		leaf := &trillian.LogLeaf{}
+		var compression string
+		var locator sql.NullString
+		var encrypted bool
 		if err := rows.Scan(
 			&leaf.MerkleLeafHash,
 			&leaf.LeafIdentityHash,
 			&leaf.LeafValue,
 			&leaf.LeafIndex,
-			&leaf.ExtraData); err != nil {
+			&leaf.ExtraData,
+			&compression,
+			&locator,
+			&encrypted,
+			&leaf.Expired); err != nil {
 			glog.Warningf("Failed to scan merkle leaves: %s", err)
 			return nil, err
 		}
+		if err := t.fetchLeafValue(leaf, locator); err != nil {
+			return nil, fmt.Errorf("failed to fetch leaf %d: %v", leaf.LeafIndex, err)
+		}
+		if err := t.decryptLeaf(leaf, encrypted); err != nil {
+			return nil, fmt.Errorf("failed to decrypt leaf %d: %v", leaf.LeafIndex, err)
+		}
+		if err := decompressLeaf(compression, leaf); err != nil {
+			return nil, fmt.Errorf("failed to decompress leaf %d: %v", leaf.LeafIndex, err)
+		}
 		ret = append(ret, leaf)
 	}
 
 	if got, want := len(ret), len(leaves); got != want {
+		for _, index := range leaves {
+			if index >= t.root.TreeSize {
+				return nil, storage.Error{ErrType: storage.OutOfRange, Detail: fmt.Sprintf("leaf index %d is beyond the tree size %d", index, t.root.TreeSize)}
+			}
+		}
 		return nil, fmt.Errorf("len(ret): %d, want %d", got, want)
 	}
 	return ret, nil
 }
 
 func (t *logTreeTX) GetLeavesByHash(leafHashes [][]byte, orderBySequence bool) ([]*trillian.LogLeaf, error) {
+	defer t.ts.observe(opGetLeavesByHash, t.treeID, time.Now())
 	tmpl, err := t.ls.getLeavesByMerkleHashStmt(len(leafHashes), orderBySequence)
 	if err != nil {
 		return nil, err
@@ -522,22 +913,38 @@ func (t *logTreeTX) LatestSignedLogRoot() (trillian.SignedLogRoot, error) {
 
 // fetchLatestRoot reads the latest SignedLogRoot from the DB and returns it.
 func (t *logTreeTX) fetchLatestRoot() (trillian.SignedLogRoot, error) {
+	return t.fetchRoot(selectLatestSignedLogRootSQL, t.treeID)
+}
+
+// SignedLogRootAtSize returns the latest SignedLogRoot of size treeSize which was
+// published by the time the transaction's root was.
+func (t *logTreeTX) SignedLogRootAtSize(treeSize int64) (trillian.SignedLogRoot, error) {
+	return t.fetchRoot(selectSignedLogRootAtSizeSQL, t.treeID, treeSize, t.root.TreeRevision)
+}
+
+// fetchRoot reads the SignedLogRoot selected by query, which must return a single
+// TreeHead row, and returns it. A zero SignedLogRoot is returned if there is no such row.
+func (t *logTreeTX) fetchRoot(query string, args ...interface{}) (trillian.SignedLogRoot, error) {
 	var timestamp, treeSize, treeRevision int64
 	var rootHash, rootSignatureBytes []byte
 	var rootSignature spb.DigitallySigned
 
-	err := t.tx.QueryRow(
-		selectLatestSignedLogRootSQL, t.treeID).Scan(
+	stx, err := t.stmt(query)
+	if err != nil {
+		return trillian.SignedLogRoot{}, err
+	}
+	err = stx.QueryRowContext(t.ctx, args...).Scan(
 		&timestamp, &treeSize, &rootHash, &treeRevision, &rootSignatureBytes)
 
 	// It's possible there are no roots for this tree yet
 	if err == sql.ErrNoRows {
 		return trillian.SignedLogRoot{}, nil
 	}
-
-	err = proto.Unmarshal(rootSignatureBytes, &rootSignature)
-
 	if err != nil {
+		return trillian.SignedLogRoot{}, err
+	}
+
+	if err := proto.Unmarshal(rootSignatureBytes, &rootSignature); err != nil {
 		glog.Warningf("Failed to unmarshall root signature: %v", err)
 		return trillian.SignedLogRoot{}, err
 	}
@@ -553,6 +960,7 @@ func (t *logTreeTX) fetchLatestRoot() (trillian.SignedLogRoot, error) {
 }
 
 func (t *logTreeTX) StoreSignedLogRoot(root trillian.SignedLogRoot) error {
+	defer t.ts.observe(opStoreSignedLogRoot, t.treeID, time.Now())
 	signatureBytes, err := proto.Marshal(root.Signature)
 
 	if err != nil {
@@ -560,7 +968,11 @@ func (t *logTreeTX) StoreSignedLogRoot(root trillian.SignedLogRoot) error {
 		return err
 	}
 
-	res, err := t.tx.Exec(insertTreeHeadSQL, t.treeID, root.TimestampNanos, root.TreeSize,
+	stx, err := t.stmt(insertTreeHeadSQL)
+	if err != nil {
+		return err
+	}
+	res, err := stx.ExecContext(t.ctx, t.treeID, root.TimestampNanos, root.TreeSize,
 		root.RootHash, root.TreeRevision, signatureBytes)
 
 	if err != nil {
@@ -570,52 +982,143 @@ func (t *logTreeTX) StoreSignedLogRoot(root trillian.SignedLogRoot) error {
 	return checkResultOkAndRowCountIs(res, err, 1)
 }
 
+func (t *logTreeTX) StoreWitnessSignature(treeRevision int64, sig *trillian.WitnessSignature) error {
+	signatureBytes, err := proto.Marshal(sig.Signature)
+	if err != nil {
+		glog.Warningf("Failed to marshal witness signature: %v %v", sig.Signature, err)
+		return err
+	}
+
+	// A replaced signature counts as two affected rows, so only check for errors.
+	if _, err := t.tx.ExecContext(t.ctx, insertWitnessSignatureSQL, t.treeID, treeRevision, sig.WitnessName, signatureBytes); err != nil {
+		glog.Warningf("Failed to store witness signature: %s", err)
+		return err
+	}
+	return nil
+}
+
+func (t *logTreeTX) StoreObservedRoot(root trillian.SignedLogRoot, consistent bool, observedAt time.Time) error {
+	signatureBytes, err := proto.Marshal(root.Signature)
+	if err != nil {
+		glog.Warningf("Failed to marshal observed root signature: %v %v", root.Signature, err)
+		return err
+	}
+
+	// Repeat observations are ignored, so only check for errors.
+	if _, err := t.tx.ExecContext(t.ctx, insertObservedTreeHeadSQL, t.treeID, root.TimestampNanos, root.TreeSize,
+		root.RootHash, signatureBytes, consistent, observedAt.UnixNano()); err != nil {
+		glog.Warningf("Failed to store observed root: %s", err)
+		return err
+	}
+	return nil
+}
+
+func (t *logTreeTX) LatestWitnessedSignedLogRoot(witnesses []string, quorum int) (trillian.SignedLogRoot, []*trillian.WitnessSignature, error) {
+	if len(witnesses) == 0 {
+		return trillian.SignedLogRoot{}, nil, nil
+	}
+
+	tmpl, err := t.ls.getLatestWitnessedRevisionStmt(len(witnesses))
+	if err != nil {
+		return trillian.SignedLogRoot{}, nil, err
+	}
+	args := []interface{}{t.treeID}
+	for _, w := range witnesses {
+		args = append(args, w)
+	}
+	args = append(args, quorum)
+	var treeRevision int64
+	if err := t.tx.StmtContext(t.ctx, tmpl).QueryRowContext(t.ctx, args...).Scan(&treeRevision); err == sql.ErrNoRows {
+		return trillian.SignedLogRoot{}, nil, nil
+	} else if err != nil {
+		glog.Warningf("Failed to get latest witnessed revision: %s", err)
+		return trillian.SignedLogRoot{}, nil, err
+	}
+
+	root, err := t.fetchRoot(selectSignedLogRootAtRevisionSQL, t.treeID, treeRevision)
+	if err != nil {
+		return trillian.SignedLogRoot{}, nil, err
+	}
+
+	tmpl, err = t.ls.getWitnessSignaturesStmt(len(witnesses))
+	if err != nil {
+		return trillian.SignedLogRoot{}, nil, err
+	}
+	args = []interface{}{t.treeID, treeRevision}
+	for _, w := range witnesses {
+		args = append(args, w)
+	}
+	rows, err := t.tx.StmtContext(t.ctx, tmpl).QueryContext(t.ctx, args...)
+	if err != nil {
+		glog.Warningf("Failed to get witness signatures: %s", err)
+		return trillian.SignedLogRoot{}, nil, err
+	}
+	defer rows.Close()
+
+	var sigs []*trillian.WitnessSignature
+	for rows.Next() {
+		var name string
+		var signatureBytes []byte
+		if err := rows.Scan(&name, &signatureBytes); err != nil {
+			glog.Warningf("Failed to scan witness signature: %s", err)
+			return trillian.SignedLogRoot{}, nil, err
+		}
+		var signature spb.DigitallySigned
+		if err := proto.Unmarshal(signatureBytes, &signature); err != nil {
+			glog.Warningf("Failed to unmarshal witness signature: %v", err)
+			return trillian.SignedLogRoot{}, nil, err
+		}
+		sigs = append(sigs, &trillian.WitnessSignature{WitnessName: name, Signature: &signature})
+	}
+	if err := rows.Err(); err != nil {
+		return trillian.SignedLogRoot{}, nil, err
+	}
+	return root, sigs, nil
+}
+
 func (t *logTreeTX) UpdateSequencedLeaves(leaves []*trillian.LogLeaf) error {
-	// TODO: In theory we can do this with CASE / WHEN in one SQL statement but it's more fiddly
-	// and can be implemented later if necessary
+	defer t.ts.observe(opUpdateSequenced, t.treeID, time.Now())
+	rows := make([][]interface{}, 0, len(leaves))
 	for _, leaf := range leaves {
 		// This should fail on insert but catch it early
 		if len(leaf.LeafIdentityHash) != t.hashSizeBytes {
 			return errors.New("Sequenced leaf has incorrect hash size")
 		}
-
-		_, err := t.tx.Exec(insertSequencedLeafSQL, t.treeID, leaf.LeafIdentityHash, leaf.MerkleLeafHash,
-			leaf.LeafIndex)
-
-		if err != nil {
-			glog.Warningf("Failed to update sequenced leaves: %s", err)
-			return err
-		}
+		rows = append(rows, []interface{}{t.treeID, leaf.LeafIdentityHash, leaf.MerkleLeafHash, leaf.LeafIndex})
 	}
 
+	if err := t.insertRows(insertSequencedLeafSQL, sequencedLeafRowSQL, rows); err != nil {
+		glog.Warningf("Failed to update sequenced leaves: %s", err)
+		return err
+	}
 	return nil
 }
 
-// removeSequencedLeaves removes the passed in leaves slice (which may be
+// removeSequencedLeaves removes the passed in queue entries (which may be
 // modified as part of the operation).
-func (t *logTreeTX) removeSequencedLeaves(leaves []*trillian.LogLeaf) error {
+func (t *logTreeTX) removeSequencedLeaves(entries []queuedEntry) error {
 	// Delete in order of the hash values in the leaves.
-	sort.Sort(byLeafIdentityHash(leaves))
+	sort.Sort(byQueuedEntry(entries))
 
-	tmpl, err := t.ls.getDeleteUnsequencedStmt(len(leaves))
+	tmpl, err := t.ls.getDeleteUnsequencedStmt(len(entries))
 	if err != nil {
 		glog.Warningf("Failed to get delete statement for sequenced work: %s", err)
 		return err
 	}
-	stx := t.tx.Stmt(tmpl)
+	stx := t.tx.StmtContext(t.ctx, tmpl)
 	var args []interface{}
-	for _, leaf := range leaves {
-		args = append(args, interface{}(leaf.LeafIdentityHash))
+	for _, entry := range entries {
+		args = append(args, interface{}(entry.leaf.LeafIdentityHash), interface{}(entry.messageID))
 	}
 	args = append(args, interface{}(t.treeID))
-	result, err := stx.Exec(args...)
+	result, err := stx.ExecContext(t.ctx, args...)
 
 	if err != nil {
 		// Error is handled by checkResultOkAndRowCountIs() below
 		glog.Warningf("Failed to delete sequenced work: %s", err)
 	}
 
-	err = checkResultOkAndRowCountIs(result, err, int64(len(leaves)))
+	err = checkResultOkAndRowCountIs(result, err, int64(len(entries)))
 
 	if err != nil {
 		return err
@@ -625,13 +1128,13 @@ func (t *logTreeTX) removeSequencedLeaves(leaves []*trillian.LogLeaf) error {
 }
 
 func (t *logTreeTX) getLeavesByHashInternal(leafHashes [][]byte, tmpl *sql.Stmt, desc string) ([]*trillian.LogLeaf, error) {
-	stx := t.tx.Stmt(tmpl)
+	stx := t.tx.StmtContext(t.ctx, tmpl)
 	var args []interface{}
 	for _, hash := range leafHashes {
 		args = append(args, interface{}([]byte(hash)))
 	}
 	args = append(args, interface{}(t.treeID))
-	rows, err := stx.Query(args...)
+	rows, err := stx.QueryContext(t.ctx, args...)
 	if err != nil {
 		glog.Warningf("Query() %s hash = %v", desc, err)
 		return nil, err
@@ -643,11 +1146,23 @@ func (t *logTreeTX) getLeavesByHashInternal(leafHashes [][]byte, tmpl *sql.Stmt,
 	defer rows.Close()
 	for rows.Next() {
 		leaf := &trillian.LogLeaf{}
+		var compression string
+		var locator sql.NullString
+		var encrypted bool
 
-		if err := rows.Scan(&leaf.MerkleLeafHash, &leaf.LeafIdentityHash, &leaf.LeafValue, &leaf.LeafIndex, &leaf.ExtraData); err != nil {
+		if err := rows.Scan(&leaf.MerkleLeafHash, &leaf.LeafIdentityHash, &leaf.LeafValue, &leaf.LeafIndex, &leaf.ExtraData, &compression, &locator, &encrypted, &leaf.Expired); err != nil {
 			glog.Warningf("LogID: %d Scan() %s = %s", t.treeID, desc, err)
 			return nil, err
 		}
+		if err := t.fetchLeafValue(leaf, locator); err != nil {
+			return nil, fmt.Errorf("LogID: %d failed to fetch leaf %x: %v", t.treeID, leaf.LeafIdentityHash, err)
+		}
+		if err := t.decryptLeaf(leaf, encrypted); err != nil {
+			return nil, fmt.Errorf("LogID: %d failed to decrypt leaf %x: %v", t.treeID, leaf.LeafIdentityHash, err)
+		}
+		if err := decompressLeaf(compression, leaf); err != nil {
+			return nil, fmt.Errorf("LogID: %d failed to decompress leaf %x: %v", t.treeID, leaf.LeafIdentityHash, err)
+		}
 
 		if got, want := len(leaf.MerkleLeafHash), t.hashSizeBytes; got != want {
 			return nil, fmt.Errorf("LogID: %d Scanned leaf %s does not have hash length %d, got %d", t.treeID, desc, want, got)
@@ -661,13 +1176,13 @@ func (t *logTreeTX) getLeavesByHashInternal(leafHashes [][]byte, tmpl *sql.Stmt,
 
 // GetActiveLogIDs returns a list of the IDs of all configured logs
 func (t *logTreeTX) GetActiveLogIDs() ([]int64, error) {
-	return getActiveLogIDs(t.tx)
+	return getActiveLogIDs(t.ctx, t.tx)
 }
 
 // GetActiveLogIDsWithPendingWork returns a list of the IDs of all configured logs
 // that have queued unsequenced leaves that need to be integrated
 func (t *logTreeTX) GetActiveLogIDsWithPendingWork() ([]int64, error) {
-	return getActiveLogIDsWithPendingWork(t.tx)
+	return getActiveLogIDsWithPendingWork(t.ctx, t.tx)
 }
 
 // byLeafIdentityHash allows sorting of leaves by their identity hash, so DB
@@ -684,6 +1199,29 @@ func (l byLeafIdentityHash) Less(i, j int) bool {
 	return bytes.Compare(l[i].LeafIdentityHash, l[j].LeafIdentityHash) == -1
 }
 
+// queuedEntry is a dequeued leaf with the MessageId of its entry in the queue.
+type queuedEntry struct {
+	leaf      *trillian.LogLeaf
+	messageID []byte
+}
+
+// byQueuedEntry allows sorting of queue entries by their leaf identity hash and
+// message ID, so DB operations always happen in a consistent order.
+type byQueuedEntry []queuedEntry
+
+func (e byQueuedEntry) Len() int {
+	return len(e)
+}
+func (e byQueuedEntry) Swap(i, j int) {
+	e[i], e[j] = e[j], e[i]
+}
+func (e byQueuedEntry) Less(i, j int) bool {
+	if c := bytes.Compare(e[i].leaf.LeafIdentityHash, e[j].leaf.LeafIdentityHash); c != 0 {
+		return c == -1
+	}
+	return bytes.Compare(e[i].messageID, e[j].messageID) == -1
+}
+
 // leafAndPosition records original position before sort.
 type leafAndPosition struct {
 	leaf *trillian.LogLeaf
@@ -704,6 +1242,15 @@ func (l byLeafIdentityHashWithPosition) Less(i, j int) bool {
 	return bytes.Compare(l[i].leaf.LeafIdentityHash, l[j].leaf.LeafIdentityHash) == -1
 }
 
+// IsTransientError implements storage.TransientErrorChecker, treating deadlocks and
+// lock wait timeouts as worth retrying.
+func (m *mySQLLogStorage) IsTransientError(err error) bool {
+	if mysqlErr, ok := err.(*mysql.MySQLError); ok {
+		return mysqlErr.Number == errNumDeadlock || mysqlErr.Number == errNumLockWaitTimeout
+	}
+	return false
+}
+
 func isDuplicateErr(err error) bool {
 	if err != nil {
 		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == errNumDuplicate {
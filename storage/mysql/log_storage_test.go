@@ -30,10 +30,13 @@ import (
 	"github.com/golang/protobuf/proto"
 	"github.com/google/trillian"
 	spb "github.com/google/trillian/crypto/sigpb"
+	"github.com/google/trillian/errors"
 	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/blob"
+	"github.com/google/trillian/storage/envelope"
 )
 
-var allTables = []string{"Unsequenced", "TreeHead", "SequencedLeafData", "LeafData", "Subtree", "TreeControl", "Trees", "MapLeaf", "MapHead"}
+var allTables = []string{"Unsequenced", "WitnessSignature", "ObservedTreeHead", "TreeHead", "SequencedLeafData", "LeafExpiry", "LeafData", "Subtree", "TreeControl", "TreeDataKey", "TreeUsage", "Trees", "MapLeaf", "MapHead", "ReplicationHeartbeat", "TreePlacement"}
 
 // Must be 32 bytes to match sha256 length if it was a real hash
 var dummyHash = []byte("hashxxxxhashxxxxhashxxxxhashxxxx")
@@ -95,25 +98,17 @@ func checkLeafContents(leaf *trillian.LogLeaf, seq int64, rawHash, hash, data, e
 	}
 }
 
-// TODO(codingllama): Replace with a GetTree/UpdateTree sequence, when the latter is available.
 func updateDuplicatePolicy(db *sql.DB, treeID int64, duplicatePolicy trillian.DuplicatePolicy) error {
-	dbPolicy := ""
-	for k, v := range duplicatePolicyMap {
-		if v == duplicatePolicy {
-			dbPolicy = k
-			break
-		}
-	}
-	if dbPolicy == "" {
-		return fmt.Errorf("unknown DuplicatePolicy: %s", duplicatePolicy)
-	}
-	stmt, err := db.Prepare("UPDATE Trees SET DuplicatePolicy = ? WHERE TreeId = ?")
+	ctx := context.Background()
+	tx, err := NewAdminStorage(db).Begin(ctx)
 	if err != nil {
 		return err
 	}
-	defer stmt.Close()
-	_, err = stmt.Exec(dbPolicy, treeID)
-	return err
+	defer tx.Close()
+	if _, err := tx.UpdateTree(ctx, treeID, func(tree *trillian.Tree) { tree.DuplicatePolicy = duplicatePolicy }); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func TestMySQLLogStorage_CheckDatabaseAccessible(t *testing.T) {
@@ -128,6 +123,7 @@ func TestBegin(t *testing.T) {
 	cleanTestDB(DB)
 	logID1 := createLogForTests(DB)
 	logID2 := createLogForTests(DB)
+	mapID := createMapForTests(DB)
 	storage := NewLogStorage(DB)
 
 	tests := []struct {
@@ -136,7 +132,8 @@ func TestBegin(t *testing.T) {
 		duplicatePolicy trillian.DuplicatePolicy
 		writeRevision   int
 	}{
-		{logID: -1, err: "failed to get tree row"},
+		{logID: -1, err: "tree -1 not found"},
+		{logID: mapID, err: "is not a log"},
 		{logID: logID1, duplicatePolicy: trillian.DuplicatePolicy_DUPLICATES_ALLOWED},
 		{logID: logID2, duplicatePolicy: trillian.DuplicatePolicy_DUPLICATES_NOT_ALLOWED},
 	}
@@ -182,7 +179,7 @@ func TestSnapshot(t *testing.T) {
 		logID int64
 		err   string
 	}{
-		{logID: -1, err: "failed to get tree row"},
+		{logID: -1, err: "tree -1 not found"},
 		{logID: logID},
 	}
 
@@ -242,6 +239,42 @@ func TestIsOpenCommitRollbackClosed(t *testing.T) {
 	}
 }
 
+func TestTxTimeout(t *testing.T) {
+	cleanTestDB(DB)
+	logID := createLogForTests(DB)
+	s := NewLogStorageWithOptions(DB, StorageOptions{TxTimeout: 100 * time.Millisecond})
+
+	tx := beginLogTx(s, logID, t)
+	defer tx.Close()
+	time.Sleep(200 * time.Millisecond)
+	if _, err := tx.DequeueLeaves(99, fakeDequeueCutoffTime); errors.ErrorCode(err) != errors.DeadlineExceeded {
+		t.Errorf("DequeueLeaves() after timeout=(_, %v), want DeadlineExceeded", err)
+	}
+	if err := tx.Commit(); errors.ErrorCode(err) != errors.DeadlineExceeded {
+		t.Errorf("Commit() after timeout=%v, want DeadlineExceeded", err)
+	}
+}
+
+func TestTxCancelled(t *testing.T) {
+	cleanTestDB(DB)
+	logID := createLogForTests(DB)
+	s := NewLogStorage(DB)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tx, err := s.BeginForTree(ctx, logID)
+	if err != nil {
+		t.Fatalf("Failed to begin log tx: %v", err)
+	}
+	defer tx.Close()
+	cancel()
+	if _, err := tx.DequeueLeaves(99, fakeDequeueCutoffTime); errors.ErrorCode(err) != errors.Canceled {
+		t.Errorf("DequeueLeaves() after cancel=(_, %v), want Canceled", err)
+	}
+	if err := tx.Commit(); errors.ErrorCode(err) != errors.Canceled {
+		t.Errorf("Commit() after cancel=%v, want Canceled", err)
+	}
+}
+
 func TestQueueDuplicateLeaf(t *testing.T) {
 	cleanTestDB(DB)
 	logID := createLogForTests(DB)
@@ -303,6 +336,13 @@ func TestQueueDuplicateLeaf(t *testing.T) {
 	}
 }
 
+func TestQueueDuplicateLeafBatched(t *testing.T) {
+	// Spread the leaves over several multi-row inserts, some of which hold duplicates.
+	defer func(n int) { maxBatchRows = n }(maxBatchRows)
+	maxBatchRows = 4
+	TestQueueDuplicateLeaf(t)
+}
+
 func TestQueueLeaves(t *testing.T) {
 	cleanTestDB(DB)
 	logID := createLogForTests(DB)
@@ -336,6 +376,256 @@ func TestQueueLeaves(t *testing.T) {
 	}
 }
 
+func TestQueueLeavesCompressed(t *testing.T) {
+	for _, compression := range []trillian.LeafCompression{trillian.LeafCompression_SNAPPY, trillian.LeafCompression_ZSTD} {
+		cleanTestDB(DB)
+		tree := *storageto.LogTree
+		tree.LeafCompression = compression
+		newTree, err := createTree(DB, &tree)
+		if err != nil {
+			t.Fatalf("%v: createTree()=%v", compression, err)
+		}
+		logID := newTree.TreeId
+		s := NewLogStorage(DB)
+
+		leaves := createTestLeaves(leavesToInsert, 20)
+		for _, leaf := range leaves {
+			leaf.LeafValue = bytes.Repeat(leaf.LeafValue, 100)
+		}
+		tx := beginLogTx(s, logID, t)
+		if _, err := tx.QueueLeaves(leaves, fakeQueueTime); err != nil {
+			t.Fatalf("%v: Failed to queue leaves: %v", compression, err)
+		}
+		commit(tx, t)
+
+		var gotCompression string
+		var value []byte
+		if err := DB.QueryRow("SELECT Compression, LeafValue FROM LeafData WHERE TreeId=? AND LeafIdentityHash=?", logID, leaves[0].LeafIdentityHash).Scan(&gotCompression, &value); err != nil {
+			t.Fatalf("%v: Could not query leaf data: %v", compression, err)
+		}
+		if got, want := gotCompression, compression.String(); got != want {
+			t.Errorf("Compression=%v, want %v", got, want)
+		}
+		if len(value) >= len(leaves[0].LeafValue) {
+			t.Errorf("%v: stored %d bytes for a %d byte leaf, want fewer", compression, len(value), len(leaves[0].LeafValue))
+		}
+
+		tx = beginLogTx(s, logID, t)
+		got, err := tx.GetLeavesByHash([][]byte{leaves[0].MerkleLeafHash}, false)
+		if err != nil {
+			t.Fatalf("%v: GetLeavesByHash()=%v", compression, err)
+		}
+		commit(tx, t)
+		if len(got) != 1 {
+			t.Fatalf("%v: GetLeavesByHash() returned %d leaves, want 1", compression, len(got))
+		}
+		if !bytes.Equal(got[0].LeafValue, leaves[0].LeafValue) || !bytes.Equal(got[0].ExtraData, leaves[0].ExtraData) {
+			t.Errorf("%v: GetLeavesByHash()=%v, want leaf data %q", compression, got[0], leaves[0].ExtraData)
+		}
+	}
+}
+
+func TestQueueLeavesIdentityHash(t *testing.T) {
+	cleanTestDB(DB)
+	tree := *storageto.LogTree
+	tree.LeafIdentityHashStrategy = trillian.LeafIdentityHashStrategy_SHA256_LEAF_VALUE
+	newTree, err := createTree(DB, &tree)
+	if err != nil {
+		t.Fatalf("createTree()=%v", err)
+	}
+	logID := newTree.TreeId
+	s := NewLogStorage(DB)
+
+	// The client-supplied hashes differ, but the log hashes the leaf value alone.
+	leaf := &trillian.LogLeaf{LeafIdentityHash: dummyHash, MerkleLeafHash: dummyHash, LeafValue: []byte("value"), ExtraData: []byte("extra")}
+	dup := &trillian.LogLeaf{LeafIdentityHash: dummyHash2, MerkleLeafHash: dummyHash, LeafValue: []byte("value"), ExtraData: []byte("other")}
+	wantHash := sha256.Sum256(leaf.LeafValue)
+
+	tx := beginLogTx(s, logID, t)
+	existing, err := tx.QueueLeaves([]*trillian.LogLeaf{leaf}, fakeQueueTime)
+	if err != nil {
+		t.Fatalf("Failed to queue leaves: %v", err)
+	}
+	commit(tx, t)
+	if existing[0] != nil {
+		t.Fatalf("QueueLeaves() returned existing leaf %v, want nil", existing[0])
+	}
+	if got := leaf.LeafIdentityHash; !bytes.Equal(got, wantHash[:]) {
+		t.Errorf("LeafIdentityHash=%x, want %x", got, wantHash)
+	}
+
+	tx = beginLogTx(s, logID, t)
+	existing, err = tx.QueueLeaves([]*trillian.LogLeaf{dup}, fakeQueueTime)
+	if err != nil {
+		t.Fatalf("Failed to queue duplicate leaves: %v", err)
+	}
+	commit(tx, t)
+	if existing[0] == nil || !bytes.Equal(existing[0].ExtraData, leaf.ExtraData) {
+		t.Errorf("QueueLeaves(duplicate) returned existing leaf %v, want the first one", existing[0])
+	}
+}
+
+// memoryBlobStore is a blob.Store keeping blobs in a map.
+type memoryBlobStore map[string][]byte
+
+func (m memoryBlobStore) Put(ctx context.Context, key string, data []byte) error {
+	m[key] = data
+	return nil
+}
+
+func (m memoryBlobStore) Get(ctx context.Context, key string) ([]byte, error) {
+	data, ok := m[key]
+	if !ok {
+		return nil, blob.ErrNotFound
+	}
+	return data, nil
+}
+
+func (m memoryBlobStore) Delete(ctx context.Context, key string) error {
+	delete(m, key)
+	return nil
+}
+
+func TestQueueLeavesOffloaded(t *testing.T) {
+	cleanTestDB(DB)
+	logID := createLogForTests(DB)
+	blobs := make(memoryBlobStore)
+	s := NewLogStorageWithOptions(DB, StorageOptions{BlobStore: blobs, BlobThreshold: 100})
+
+	small := createTestLeaves(1, 20)[0]
+	large := createTestLeaves(1, 21)[0]
+	large.LeafValue = bytes.Repeat(large.LeafValue, 100)
+	tx := beginLogTx(s, logID, t)
+	if _, err := tx.QueueLeaves([]*trillian.LogLeaf{small, large}, fakeQueueTime); err != nil {
+		t.Fatalf("Failed to queue leaves: %v", err)
+	}
+	commit(tx, t)
+
+	if got, want := len(blobs), 1; got != want {
+		t.Fatalf("blob store holds %d blobs, want %d", got, want)
+	}
+	for _, test := range []struct {
+		desc        string
+		leaf        *trillian.LogLeaf
+		wantLocator bool
+	}{
+		{desc: "small", leaf: small},
+		{desc: "large", leaf: large, wantLocator: true},
+	} {
+		var value []byte
+		var locator sql.NullString
+		if err := DB.QueryRow("SELECT LeafValue, LeafValueLocator FROM LeafData WHERE TreeId=? AND LeafIdentityHash=?", logID, test.leaf.LeafIdentityHash).Scan(&value, &locator); err != nil {
+			t.Fatalf("Could not query leaf data: %v", err)
+		}
+		if locator.Valid != test.wantLocator {
+			t.Errorf("%v: LeafValueLocator=%v, want set=%v", test.desc, locator, test.wantLocator)
+		}
+		if test.wantLocator && len(value) != 0 {
+			t.Errorf("%v: LeafValue=%q, want empty", test.desc, value)
+		}
+
+		tx = beginLogTx(s, logID, t)
+		got, err := tx.GetLeavesByHash([][]byte{test.leaf.MerkleLeafHash}, false)
+		commit(tx, t)
+		if err != nil {
+			t.Fatalf("%v: GetLeavesByHash()=%v", test.desc, err)
+		}
+		if len(got) != 1 || !bytes.Equal(got[0].LeafValue, test.leaf.LeafValue) {
+			t.Errorf("%v: GetLeavesByHash()=%v, want leaf value %q", test.desc, got, test.leaf.LeafValue)
+		}
+	}
+
+	// Storage without the blob store can't read the large leaf.
+	tx = beginLogTx(NewLogStorage(DB), logID, t)
+	defer tx.Close()
+	if _, err := tx.GetLeavesByHash([][]byte{large.MerkleLeafHash}, false); err == nil {
+		t.Error("GetLeavesByHash() without blob store=nil, want error")
+	}
+}
+
+func TestQueueLeavesOffloadedDuplicate(t *testing.T) {
+	cleanTestDB(DB)
+	logID := createLogForTests(DB)
+	blobs := make(memoryBlobStore)
+	s := NewLogStorageWithOptions(DB, StorageOptions{BlobStore: blobs, BlobThreshold: 100})
+
+	leaf := createTestLeaves(1, 20)[0]
+	leaf.LeafValue = bytes.Repeat(leaf.LeafValue, 100)
+	tx := beginLogTx(s, logID, t)
+	if _, err := tx.QueueLeaves([]*trillian.LogLeaf{leaf}, fakeQueueTime); err != nil {
+		t.Fatalf("Failed to queue leaf: %v", err)
+	}
+	commit(tx, t)
+
+	// A duplicate with another value, as clients supplying identity hashes may queue.
+	dup := *leaf
+	dup.LeafValue = bytes.Repeat([]byte("other"), 100)
+	tx = beginLogTx(s, logID, t)
+	if _, err := tx.QueueLeaves([]*trillian.LogLeaf{&dup}, fakeQueueTime); err != nil {
+		t.Fatalf("Failed to queue duplicate leaf: %v", err)
+	}
+	commit(tx, t)
+
+	tx = beginLogTx(s, logID, t)
+	got, err := tx.GetLeavesByHash([][]byte{leaf.MerkleLeafHash}, false)
+	commit(tx, t)
+	if err != nil {
+		t.Fatalf("GetLeavesByHash()=%v", err)
+	}
+	if len(got) != 1 || !bytes.Equal(got[0].LeafValue, leaf.LeafValue) {
+		t.Errorf("GetLeavesByHash()=%v, want the first leaf's value", got)
+	}
+}
+
+func TestQueueLeavesEncrypted(t *testing.T) {
+	cleanTestDB(DB)
+	logID := createLogForTests(DB)
+	wrappingKey, err := envelope.NewDataKey()
+	if err != nil {
+		t.Fatalf("NewDataKey()=%v", err)
+	}
+	wrapper, err := envelope.NewLocalKeyWrapper(wrappingKey)
+	if err != nil {
+		t.Fatalf("NewLocalKeyWrapper()=%v", err)
+	}
+	s := NewLogStorageWithOptions(DB, StorageOptions{KeyWrapper: wrapper})
+
+	leaves := createTestLeaves(leavesToInsert, 20)
+	tx := beginLogTx(s, logID, t)
+	if _, err := tx.QueueLeaves(leaves, fakeQueueTime); err != nil {
+		t.Fatalf("Failed to queue leaves: %v", err)
+	}
+	commit(tx, t)
+
+	var value []byte
+	var encrypted bool
+	if err := DB.QueryRow("SELECT LeafValue, Encrypted FROM LeafData WHERE TreeId=? AND LeafIdentityHash=?", logID, leaves[0].LeafIdentityHash).Scan(&value, &encrypted); err != nil {
+		t.Fatalf("Could not query leaf data: %v", err)
+	}
+	if !encrypted || bytes.Contains(value, leaves[0].LeafValue) {
+		t.Errorf("stored LeafValue=%q, Encrypted=%v, want encrypted", value, encrypted)
+	}
+
+	// A new storage instance has to unwrap the stored key to read the leaves back.
+	s = NewLogStorageWithOptions(DB, StorageOptions{KeyWrapper: wrapper})
+	tx = beginLogTx(s, logID, t)
+	got, err := tx.GetLeavesByHash([][]byte{leaves[0].MerkleLeafHash}, false)
+	commit(tx, t)
+	if err != nil {
+		t.Fatalf("GetLeavesByHash()=%v", err)
+	}
+	if len(got) != 1 || !bytes.Equal(got[0].LeafValue, leaves[0].LeafValue) || !bytes.Equal(got[0].ExtraData, leaves[0].ExtraData) {
+		t.Errorf("GetLeavesByHash()=%v, want %v", got, leaves[0])
+	}
+
+	// Storage without the key wrapper can't read them.
+	tx = beginLogTx(NewLogStorage(DB), logID, t)
+	defer tx.Close()
+	if _, err := tx.GetLeavesByHash([][]byte{leaves[0].MerkleLeafHash}, false); err == nil {
+		t.Error("GetLeavesByHash() without key wrapper=nil, want error")
+	}
+}
+
 func TestDequeueLeavesNoneQueued(t *testing.T) {
 	cleanTestDB(DB)
 	logID := createLogForTests(DB)
@@ -399,6 +689,54 @@ func TestDequeueLeaves(t *testing.T) {
 	}
 }
 
+func TestDequeueDuplicateLeaves(t *testing.T) {
+	cleanTestDB(DB)
+	logID := createLogForTests(DB)
+	if err := updateDuplicatePolicy(DB, logID, trillian.DuplicatePolicy_DUPLICATES_ALLOWED); err != nil {
+		t.Fatalf("Failed to allow duplicates: %v", err)
+	}
+	s := NewLogStorage(DB)
+
+	// The same leaves are queued twice, the second time after the dequeue cutoff.
+	leaves := createTestLeaves(leavesToInsert, 20)
+	for _, queueTime := range []time.Time{fakeQueueTime, fakeDequeueCutoffTime.Add(time.Second)} {
+		tx := beginLogTx(s, logID, t)
+		existing, err := tx.QueueLeaves(leaves, queueTime)
+		if err != nil {
+			t.Fatalf("Failed to queue leaves: %v", err)
+		}
+		for i, leaf := range existing {
+			if leaf != nil {
+				t.Errorf("QueueLeaves()[%d]=%v, want nil as duplicates are allowed", i, leaf)
+			}
+		}
+		commit(tx, t)
+	}
+
+	// Each dequeue only removes the queue entries it returns, although the two
+	// entries of every leaf share its identity hash.
+	for _, cutoff := range []time.Time{fakeDequeueCutoffTime, fakeDequeueCutoffTime.Add(time.Minute)} {
+		tx := beginLogTx(s, logID, t)
+		dequeued, err := tx.DequeueLeaves(99, cutoff)
+		if err != nil {
+			t.Fatalf("DequeueLeaves(%v)=%v", cutoff, err)
+		}
+		commit(tx, t)
+		if got, want := len(dequeued), leavesToInsert; got != want {
+			t.Errorf("DequeueLeaves(%v) returned %d leaves, want %d", cutoff, got, want)
+		}
+		ensureAllLeavesDistinct(dequeued, t)
+	}
+
+	var unsequenced int
+	if err := DB.QueryRow("SELECT COUNT(*) FROM Unsequenced WHERE TreeId=?", logID).Scan(&unsequenced); err != nil {
+		t.Fatalf("Could not count Unsequenced rows: %v", err)
+	}
+	if unsequenced != 0 {
+		t.Errorf("Got %d Unsequenced rows after dequeuing, want 0", unsequenced)
+	}
+}
+
 func TestDequeueLeavesTwoBatches(t *testing.T) {
 	cleanTestDB(DB)
 	logID := createLogForTests(DB)
@@ -572,6 +910,72 @@ func TestDequeueLeavesTimeOrdering(t *testing.T) {
 	}
 }
 
+func TestAddSequencedLeavesNotPreordered(t *testing.T) {
+	cleanTestDB(DB)
+	logID := createLogForTests(DB)
+	s := NewLogStorage(DB)
+
+	tx := beginLogTx(s, logID, t)
+	defer tx.Close()
+	if err := tx.AddSequencedLeaves(createTestLeaves(leavesToInsert, 0)); err == nil {
+		t.Fatal("AddSequencedLeaves() on a LOG tree = nil, want error")
+	}
+}
+
+func TestAddSequencedLeavesAndDequeue(t *testing.T) {
+	cleanTestDB(DB)
+	logID := createPreorderedLogForTests(DB)
+	s := NewLogStorage(DB)
+
+	{
+		// Leave a gap at index leavesToInsert, which should stop the dequeue.
+		tx := beginLogTx(s, logID, t)
+		defer tx.Close()
+		leaves := append(createTestLeaves(leavesToInsert, 0), createTestLeaves(2, leavesToInsert+1)...)
+		if err := tx.AddSequencedLeaves(leaves); err != nil {
+			t.Fatalf("Failed to add sequenced leaves: %v", err)
+		}
+		commit(tx, t)
+	}
+
+	{
+		// Adding a leaf at an occupied index must fail.
+		tx := beginLogTx(s, logID, t)
+		defer tx.Close()
+		if err := tx.AddSequencedLeaves(createTestLeaves(1, 2)); err == nil {
+			t.Fatal("AddSequencedLeaves() at an occupied index = nil, want error")
+		}
+	}
+
+	{
+		// Only the second leaf is at an occupied index, which the error must name.
+		tx := beginLogTx(s, logID, t)
+		defer tx.Close()
+		err := tx.AddSequencedLeaves(createTestLeaves(3, leavesToInsert))
+		if want := fmt.Sprintf("index %d", leavesToInsert+1); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("AddSequencedLeaves() over an occupied index = %v, want error containing %q", err, want)
+		}
+	}
+
+	{
+		tx := beginLogTx(s, logID, t)
+		defer tx.Close()
+		leaves, err := tx.DequeueLeaves(99, fakeDequeueCutoffTime)
+		if err != nil {
+			t.Fatalf("Failed to dequeue leaves: %v", err)
+		}
+		if got, want := len(leaves), leavesToInsert; got != want {
+			t.Fatalf("Dequeued %d leaves, want %d", got, want)
+		}
+		for i, leaf := range leaves {
+			if got, want := leaf.LeafIndex, int64(i); got != want {
+				t.Errorf("leaves[%d].LeafIndex = %d, want %d", i, got, want)
+			}
+		}
+		commit(tx, t)
+	}
+}
+
 func TestGetLeavesByHashNotPresent(t *testing.T) {
 	cleanTestDB(DB)
 	logID := createLogForTests(DB)
@@ -762,6 +1166,74 @@ func TestLatestSignedLogRoot(t *testing.T) {
 	}
 }
 
+func TestSignedLogRootAtSize(t *testing.T) {
+	cleanTestDB(DB)
+	logID := createLogForTests(DB)
+	s := NewLogStorage(DB)
+
+	var roots []trillian.SignedLogRoot
+	for i, size := range []int64{16, 16, 20} {
+		root := trillian.SignedLogRoot{
+			LogId:          logID,
+			TimestampNanos: int64(98765 + i),
+			TreeSize:       size,
+			TreeRevision:   int64(5 + i),
+			RootHash:       []byte(dummyHash),
+			Signature:      &spb.DigitallySigned{Signature: []byte("notempty")},
+		}
+		tx := beginLogTx(s, logID, t)
+		if err := tx.StoreSignedLogRoot(root); err != nil {
+			t.Fatalf("Failed to store signed root: %v", err)
+		}
+		commit(tx, t)
+		tx.Close()
+		roots = append(roots, root)
+	}
+
+	tx := beginLogTx(s, logID, t)
+	defer tx.Close()
+	for _, test := range []struct {
+		size int64
+		want trillian.SignedLogRoot
+	}{
+		// The latest of the roots of a size is returned.
+		{size: 16, want: roots[1]},
+		{size: 20, want: roots[2]},
+		{size: 18, want: trillian.SignedLogRoot{}},
+	} {
+		got, err := tx.SignedLogRootAtSize(test.size)
+		if err != nil {
+			t.Fatalf("SignedLogRootAtSize(%d)=(_, %v)", test.size, err)
+		}
+		if !proto.Equal(&got, &test.want) {
+			t.Errorf("SignedLogRootAtSize(%d)=%v, want %v", test.size, got, test.want)
+		}
+	}
+	commit(tx, t)
+}
+
+func TestSignedLogRootCancelled(t *testing.T) {
+	cleanTestDB(DB)
+	logID := createLogForTests(DB)
+	s := NewLogStorage(DB)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tx, err := s.BeginForTree(ctx, logID)
+	if err != nil {
+		t.Fatalf("Failed to begin log tx: %v", err)
+	}
+	defer tx.Close()
+	cancel()
+
+	// A failed read mustn't look like a tree with no roots.
+	if root, err := tx.LatestSignedLogRoot(); err == nil {
+		t.Errorf("LatestSignedLogRoot() after cancel=(%v, nil), want error", root)
+	}
+	if root, err := tx.SignedLogRootAtSize(16); err == nil {
+		t.Errorf("SignedLogRootAtSize(16) after cancel=(%v, nil), want error", root)
+	}
+}
+
 func TestDuplicateSignedLogRoot(t *testing.T) {
 	cleanTestDB(DB)
 	logID := createLogForTests(DB)
@@ -832,6 +1304,126 @@ func TestLogRootUpdate(t *testing.T) {
 	commit(tx2, t)
 }
 
+func TestWitnessSignatures(t *testing.T) {
+	cleanTestDB(DB)
+	logID := createLogForTests(DB)
+	s := NewLogStorage(DB)
+
+	tx := beginLogTx(s, logID, t)
+	defer tx.Close()
+	var roots []trillian.SignedLogRoot
+	for rev := int64(0); rev < 3; rev++ {
+		root := trillian.SignedLogRoot{
+			LogId:          logID,
+			TimestampNanos: 98765 + rev,
+			TreeSize:       16 + rev,
+			TreeRevision:   rev,
+			RootHash:       []byte(dummyHash),
+			Signature:      &spb.DigitallySigned{Signature: []byte("notempty")},
+		}
+		if err := tx.StoreSignedLogRoot(root); err != nil {
+			t.Fatalf("Failed to store signed root: %v", err)
+		}
+		roots = append(roots, root)
+	}
+	sig := func(name string) *trillian.WitnessSignature {
+		return &trillian.WitnessSignature{WitnessName: name, Signature: &spb.DigitallySigned{Signature: []byte("sig-" + name)}}
+	}
+	// Revision 0 is signed by a and b, revision 1 by a and c (twice) and revision 2 by b only.
+	for _, w := range []struct {
+		rev int64
+		sig *trillian.WitnessSignature
+	}{
+		{0, sig("a")}, {0, sig("b")}, {1, sig("a")}, {1, sig("c")}, {1, sig("c")}, {2, sig("b")},
+	} {
+		if err := tx.StoreWitnessSignature(w.rev, w.sig); err != nil {
+			t.Fatalf("StoreWitnessSignature(%d, %v)=%v", w.rev, w.sig.WitnessName, err)
+		}
+	}
+	// Signatures can only be stored for existing roots.
+	if err := tx.StoreWitnessSignature(10, sig("a")); err == nil {
+		t.Error("StoreWitnessSignature(unknown revision)=nil, want error")
+	}
+	commit(tx, t)
+
+	tests := []struct {
+		witnesses []string
+		quorum    int
+		wantRoot  trillian.SignedLogRoot
+		wantSigs  []*trillian.WitnessSignature
+	}{
+		{witnesses: []string{"a", "b", "c"}, quorum: 1, wantRoot: roots[2], wantSigs: []*trillian.WitnessSignature{sig("b")}},
+		{witnesses: []string{"a", "b", "c"}, quorum: 2, wantRoot: roots[1], wantSigs: []*trillian.WitnessSignature{sig("a"), sig("c")}},
+		{witnesses: []string{"a", "b"}, quorum: 2, wantRoot: roots[0], wantSigs: []*trillian.WitnessSignature{sig("a"), sig("b")}},
+		{witnesses: []string{"a"}, quorum: 1, wantRoot: roots[1], wantSigs: []*trillian.WitnessSignature{sig("a")}},
+		{witnesses: []string{"a", "b", "c"}, quorum: 3},
+		{witnesses: []string{"d"}, quorum: 1},
+		{quorum: 1},
+	}
+	for _, test := range tests {
+		tx := beginLogTx(s, logID, t)
+		root, sigs, err := tx.LatestWitnessedSignedLogRoot(test.witnesses, test.quorum)
+		if err != nil {
+			t.Fatalf("LatestWitnessedSignedLogRoot(%v, %d)=%v", test.witnesses, test.quorum, err)
+		}
+		if !proto.Equal(&root, &test.wantRoot) {
+			t.Errorf("LatestWitnessedSignedLogRoot(%v, %d)=%v, want %v", test.witnesses, test.quorum, root, test.wantRoot)
+		}
+		if got, want := len(sigs), len(test.wantSigs); got != want {
+			t.Errorf("LatestWitnessedSignedLogRoot(%v, %d): %d signatures, want %d", test.witnesses, test.quorum, got, want)
+		} else {
+			for i := range sigs {
+				if !proto.Equal(sigs[i], test.wantSigs[i]) {
+					t.Errorf("LatestWitnessedSignedLogRoot(%v, %d): signature %v, want %v", test.witnesses, test.quorum, sigs[i], test.wantSigs[i])
+				}
+			}
+		}
+		commit(tx, t)
+		tx.Close()
+	}
+}
+
+func TestStoreObservedRoot(t *testing.T) {
+	cleanTestDB(DB)
+	logID := createLogForTests(DB)
+	s := NewLogStorage(DB)
+
+	tx := beginLogTx(s, logID, t)
+	defer tx.Close()
+	root := trillian.SignedLogRoot{
+		LogId:          logID,
+		TimestampNanos: 98765,
+		TreeSize:       16,
+		RootHash:       []byte(dummyHash),
+		Signature:      &spb.DigitallySigned{Signature: []byte("notempty")},
+	}
+	forked := root
+	forked.RootHash = []byte(dummyHash2)
+
+	for _, o := range []struct {
+		root       trillian.SignedLogRoot
+		consistent bool
+	}{
+		{root, true}, {root, true}, {forked, false},
+	} {
+		if err := tx.StoreObservedRoot(o.root, o.consistent, fakeQueueTime); err != nil {
+			t.Fatalf("StoreObservedRoot(%x)=%v", o.root.RootHash, err)
+		}
+	}
+	commit(tx, t)
+
+	var consistent, inconsistent int
+	if err := DB.QueryRow("SELECT COUNT(*) FROM ObservedTreeHead WHERE TreeId=? AND Consistent", logID).Scan(&consistent); err != nil {
+		t.Fatalf("Failed to count consistent roots: %v", err)
+	}
+	if err := DB.QueryRow("SELECT COUNT(*) FROM ObservedTreeHead WHERE TreeId=? AND NOT Consistent", logID).Scan(&inconsistent); err != nil {
+		t.Fatalf("Failed to count inconsistent roots: %v", err)
+	}
+	if consistent != 1 || inconsistent != 1 {
+		t.Errorf("Stored %d consistent and %d inconsistent roots, want 1 and 1", consistent, inconsistent)
+	}
+}
+
 // getActiveLogIDsFn creates a TX, calls the appropriate GetActiveLogIDs* function, commits the TX
 // and returns the results.
 type getActiveLogIDsFn func(storage.LogStorage, context.Context, int64) ([]int64, error)
@@ -897,6 +1489,10 @@ func runTestGetActiveLogIDsWithPendingWork(t *testing.T, test getActiveIDsTest)
 		}
 		commit(tx, t)
 	}
+	// Pre-ordered logs have pending work too.
+	if _, err := DB.Exec("UPDATE Trees SET TreeType='PREORDERED_LOG' WHERE TreeId=?", logID3); err != nil {
+		t.Fatalf("Failed to make log %v pre-ordered: %v", logID3, err)
+	}
 
 	wantIds := []int64{logID1, logID2, logID3}
 	runTestGetActiveLogIDsInternal(t, test, logID1, wantIds)
@@ -1067,6 +1663,106 @@ func TestGetSequencedLeafCount(t *testing.T) {
 	commit(tx, t)
 }
 
+func TestGetUnsequencedStats(t *testing.T) {
+	cleanTestDB(DB)
+	logID := createLogForTests(DB)
+	s := NewLogStorage(DB)
+
+	tx := beginLogTx(s, logID, t)
+	defer tx.Close()
+	count, oldest, err := tx.GetUnsequencedStats()
+	if err != nil {
+		t.Fatalf("GetUnsequencedStats() = (_, _, %v), want no error", err)
+	}
+	if count != 0 || !oldest.IsZero() {
+		t.Errorf("GetUnsequencedStats() = (%v, %v, _), want (0, zero time, _) for empty queue", count, oldest)
+	}
+	if _, err := tx.QueueLeaves(createTestLeaves(5, 0), fakeQueueTime); err != nil {
+		t.Fatalf("Failed to queue leaves: %v", err)
+	}
+	if _, err := tx.QueueLeaves(createTestLeaves(3, 10), fakeQueueTime.Add(time.Minute)); err != nil {
+		t.Fatalf("Failed to queue leaves: %v", err)
+	}
+	commit(tx, t)
+
+	snapshot, err := s.SnapshotForTree(context.Background(), logID)
+	if err != nil {
+		t.Fatalf("SnapshotForTree() = (_, %v), want no error", err)
+	}
+	defer snapshot.Close()
+	count, oldest, err = snapshot.GetUnsequencedStats()
+	if err != nil {
+		t.Fatalf("GetUnsequencedStats() = (_, _, %v), want no error", err)
+	}
+	if got, want := count, int64(8); got != want {
+		t.Errorf("GetUnsequencedStats() count = %v, want %v", got, want)
+	}
+	if got, want := oldest, fakeQueueTime; !got.Equal(want) {
+		t.Errorf("GetUnsequencedStats() oldest = %v, want %v", got, want)
+	}
+	commit(snapshot, t)
+}
+
+func TestFinalizedTreeSize(t *testing.T) {
+	cleanTestDB(DB)
+	logID := createLogForTests(DB)
+	s := NewLogStorage(DB)
+	ctx := context.Background()
+
+	for _, finalize := range []bool{false, true} {
+		if finalize {
+			atx, err := NewAdminStorage(DB).Begin(ctx)
+			if err != nil {
+				t.Fatalf("Begin() = %v", err)
+			}
+			if _, err := atx.UpdateTree(ctx, logID, func(tree *trillian.Tree) {
+				tree.TreeState = trillian.TreeState_FROZEN
+				tree.FinalizeTimeMillisSinceEpoch = toMillisSinceEpoch(fakeQueueTime)
+				tree.FinalizedTreeSize = 5
+			}); err != nil {
+				t.Fatalf("UpdateTree() = %v", err)
+			}
+			commit(atx, t)
+		}
+
+		snapshot, err := s.SnapshotForTree(ctx, logID)
+		if err != nil {
+			t.Fatalf("SnapshotForTree() = (_, %v), want no error", err)
+		}
+		size, finalized, err := snapshot.FinalizedTreeSize()
+		if err != nil || finalized != finalize || (finalize && size != 5) {
+			t.Errorf("FinalizedTreeSize() = (%v, %v, %v), want (5, %v, nil)", size, finalized, err, finalize)
+		}
+		commit(snapshot, t)
+		snapshot.Close()
+	}
+}
+
+func TestQueueLeavesFrozenLog(t *testing.T) {
+	cleanTestDB(DB)
+	logID := createLogForTests(DB)
+	s := NewLogStorage(DB)
+	ctx := context.Background()
+
+	atx, err := NewAdminStorage(DB).Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin() = %v", err)
+	}
+	if _, err := atx.UpdateTree(ctx, logID, func(tree *trillian.Tree) {
+		tree.TreeState = trillian.TreeState_FROZEN
+	}); err != nil {
+		t.Fatalf("UpdateTree() = %v", err)
+	}
+	commit(atx, t)
+
+	tx := beginLogTx(s, logID, t)
+	defer tx.Close()
+	_, err = tx.QueueLeaves(createTestLeaves(leavesToInsert, 20), fakeQueueTime)
+	if serr, ok := err.(storage.Error); !ok || serr.ErrType != storage.TreeNotWritable {
+		t.Errorf("QueueLeaves() = %v, want storage.TreeNotWritable", err)
+	}
+}
+
 func TestSortByLeafIdentityHash(t *testing.T) {
 	l := make([]*trillian.LogLeaf, 30)
 	for i := range l {
@@ -17,6 +17,7 @@ package mysql
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/golang/protobuf/proto"
@@ -59,7 +60,7 @@ type mySQLMapStorage struct {
 // NewMapStorage creates a mySQLMapStorage instance for the specified MySQL URL.
 func NewMapStorage(db *sql.DB) storage.MapStorage {
 	return &mySQLMapStorage{
-		mySQLTreeStorage: newTreeStorage(db),
+		mySQLTreeStorage: newTreeStorage(db, DefaultStatementCacheSize),
 	}
 }
 
@@ -68,15 +69,16 @@ func (m *mySQLMapStorage) CheckDatabaseAccessible(ctx context.Context) error {
 }
 
 type readOnlyMapTX struct {
-	tx *sql.Tx
+	tx  *sql.Tx
+	ctx context.Context
 }
 
 func (m *mySQLMapStorage) Snapshot(ctx context.Context) (storage.ReadOnlyMapTX, error) {
-	tx, err := m.db.Begin()
+	tx, err := m.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
-	return &readOnlyMapTX{tx}, nil
+	return &readOnlyMapTX{tx: tx, ctx: ctx}, nil
 }
 
 func (t *readOnlyMapTX) Commit() error {
@@ -84,6 +86,7 @@ func (t *readOnlyMapTX) Commit() error {
 }
 
 func (t *readOnlyMapTX) Rollback() error {
+	countIfAbandoned(t.ctx)
 	return t.tx.Rollback()
 }
 
@@ -158,13 +161,13 @@ func (m *mapTreeTX) Set(keyHash []byte, value trillian.MapLeaf) error {
 		return nil
 	}
 
-	stmt, err := m.tx.Prepare(insertMapLeafSQL)
+	stmt, err := m.stmt(insertMapLeafSQL)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(m.treeID, keyHash, m.writeRevision, flatValue)
+	_, err = stmt.ExecContext(m.ctx, m.treeID, keyHash, m.writeRevision, flatValue)
 	return err
 }
 
@@ -175,7 +178,7 @@ func (m *mapTreeTX) Get(revision int64, indexes [][]byte) ([]trillian.MapLeaf, e
 	if err != nil {
 		return nil, err
 	}
-	stx := m.tx.Stmt(stmt)
+	stx := m.tx.StmtContext(m.ctx, stmt)
 	defer stx.Close()
 
 	args := make([]interface{}, 0, len(indexes)+2)
@@ -187,7 +190,7 @@ func (m *mapTreeTX) Get(revision int64, indexes [][]byte) ([]trillian.MapLeaf, e
 
 	glog.Infof("args size %d", len(args))
 
-	rows, err := stx.Query(args...)
+	rows, err := stx.QueryContext(m.ctx, args...)
 	// It's possible there are no values for any of these keys yet
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -230,13 +233,13 @@ func (m *mapTreeTX) LatestSignedMapRoot() (trillian.SignedMapRoot, error) {
 	var mapperMetaBytes []byte
 	var mapperMeta *trillian.MapperMetadata
 
-	stmt, err := m.tx.Prepare(selectLatestSignedMapRootSQL)
+	stmt, err := m.stmt(selectLatestSignedMapRootSQL)
 	if err != nil {
 		return trillian.SignedMapRoot{}, err
 	}
 	defer stmt.Close()
 
-	err = stmt.QueryRow(m.treeID).Scan(
+	err = stmt.QueryRowContext(m.ctx, m.treeID).Scan(
 		&timestamp, &rootHash, &mapRevision, &rootSignatureBytes, &mapperMetaBytes)
 
 	// It's possible there are no roots for this tree yet
@@ -271,6 +274,7 @@ func (m *mapTreeTX) LatestSignedMapRoot() (trillian.SignedMapRoot, error) {
 }
 
 func (m *mapTreeTX) StoreSignedMapRoot(root trillian.SignedMapRoot) error {
+	defer m.ts.observe(opStoreSignedMapRoot, m.treeID, time.Now())
 	signatureBytes, err := proto.Marshal(root.Signature)
 	if err != nil {
 		glog.Warningf("Failed to marshal root signature: %v %v", root.Signature, err)
@@ -287,14 +291,14 @@ func (m *mapTreeTX) StoreSignedMapRoot(root trillian.SignedMapRoot) error {
 		}
 	}
 
-	stmt, err := m.tx.Prepare(insertMapHeadSQL)
+	stmt, err := m.stmt(insertMapHeadSQL)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
 	// TODO(al): store transactionLogHead too
-	res, err := stmt.Exec(m.treeID, root.TimestampNanos, root.RootHash, root.MapRevision, signatureBytes, mapperMetaBytes)
+	res, err := stmt.ExecContext(m.ctx, m.treeID, root.TimestampNanos, root.RootHash, root.MapRevision, signatureBytes, mapperMetaBytes)
 
 	if err != nil {
 		glog.Warningf("Failed to store signed map root: %s", err)
@@ -0,0 +1,207 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	migrationsDir = "migrations"
+	// migrationLock serializes migrations run by several servers starting at once.
+	migrationLock        = "trillian_schema_migration"
+	migrationLockTimeout = 60
+
+	createSchemaVersionSQL = `CREATE TABLE IF NOT EXISTS SchemaVersion(
+			Version INTEGER NOT NULL,
+			AppliedTimestampNanos BIGINT NOT NULL,
+			PRIMARY KEY(Version))`
+	selectSchemaVersionSQL = "SELECT COALESCE(MAX(Version), 0) FROM SchemaVersion"
+	insertSchemaVersionSQL = "INSERT INTO SchemaVersion(Version, AppliedTimestampNanos) VALUES(?, ?)"
+	selectColumnsSQL       = "SELECT TABLE_NAME, COLUMN_NAME FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE()"
+)
+
+// Migration is a numbered change to the storage schema, read from a file named
+// migrations/<version>_<name>.sql.
+type Migration struct {
+	Version int
+	Name    string
+	file    string
+}
+
+// Migrations returns the known migrations, in the order they're applied.
+func Migrations() []Migration {
+	var ms []Migration
+	for file := range sqlFiles {
+		dir, base := path.Split(file)
+		if dir != migrationsDir+"/" {
+			continue
+		}
+		parts := strings.SplitN(strings.TrimSuffix(base, ".sql"), "_", 2)
+		v, err := strconv.Atoi(parts[0])
+		if err != nil || len(parts) != 2 {
+			// Checked by tests, so can't happen in a released binary.
+			panic(fmt.Sprintf("malformed migration file name %q", file))
+		}
+		ms = append(ms, Migration{Version: v, Name: parts[1], file: file})
+	}
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Version < ms[j].Version })
+	return ms
+}
+
+// LatestSchemaVersion returns the version of the newest migration, which is the one
+// storage.sql corresponds to.
+func LatestSchemaVersion() int {
+	ms := Migrations()
+	return ms[len(ms)-1].Version
+}
+
+// SchemaVersion returns the version of the last migration applied to db, or 0 if none
+// have been.
+func SchemaVersion(ctx context.Context, db *sql.DB) (int, error) {
+	if _, err := db.ExecContext(ctx, createSchemaVersionSQL); err != nil {
+		return 0, err
+	}
+	var v int
+	err := db.QueryRowContext(ctx, selectSchemaVersionSQL).Scan(&v)
+	return v, err
+}
+
+// Migrate applies the migrations after db's current version, up to and including
+// target, or all of them if target is 0. It returns the migrations it applied.
+//
+// MySQL can't roll back schema changes, so a migration which fails part way through
+// has to be finished or undone by hand before Migrate is run again.
+func Migrate(ctx context.Context, db *sql.DB, target int) ([]Migration, error) {
+	if target == 0 {
+		target = LatestSchemaVersion()
+	}
+	if target > LatestSchemaVersion() {
+		return nil, fmt.Errorf("target version %d is newer than the latest migration, %d", target, LatestSchemaVersion())
+	}
+
+	// Locks are held by a session, so everything has to happen on one connection.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var locked sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", migrationLock, migrationLockTimeout).Scan(&locked); err != nil {
+		return nil, err
+	}
+	if locked.Int64 != 1 {
+		return nil, fmt.Errorf("timed out waiting for another migration to finish")
+	}
+	defer conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", migrationLock)
+
+	if _, err := conn.ExecContext(ctx, createSchemaVersionSQL); err != nil {
+		return nil, err
+	}
+	var current int
+	if err := conn.QueryRowContext(ctx, selectSchemaVersionSQL).Scan(&current); err != nil {
+		return nil, err
+	}
+	if current > target {
+		return nil, fmt.Errorf("database is at version %d, which is newer than %d; migrations can't be undone", current, target)
+	}
+
+	var applied []Migration
+	for _, m := range Migrations() {
+		if m.Version <= current || m.Version > target {
+			continue
+		}
+		for _, stmt := range sqlStatements(sqlFiles[m.file]) {
+			if _, err := conn.ExecContext(ctx, stmt); err != nil {
+				return applied, fmt.Errorf("migration %d (%s) failed: %v", m.Version, m.Name, err)
+			}
+		}
+		if _, err := conn.ExecContext(ctx, insertSchemaVersionSQL, m.Version, time.Now().UnixNano()); err != nil {
+			return applied, err
+		}
+		applied = append(applied, m)
+	}
+	return applied, nil
+}
+
+// VerifySchema checks that db has had all the migrations applied and that it has all
+// the tables and columns of storage.sql.
+func VerifySchema(ctx context.Context, db *sql.DB) error {
+	v, err := SchemaVersion(ctx, db)
+	if err != nil {
+		return err
+	}
+	if latest := LatestSchemaVersion(); v != latest {
+		return fmt.Errorf("database is at version %d, want %d", v, latest)
+	}
+
+	rows, err := db.QueryContext(ctx, selectColumnsSQL)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	have := make(map[string]bool)
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return err
+		}
+		have[table+"."+column] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, c := range schemaColumns(sqlFiles[schemaFile]) {
+		if !have[c] {
+			missing = append(missing, c)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("database is missing columns: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// schemaColumns returns the Table.Column names of the columns created by the CREATE
+// TABLE statements in schema.
+func schemaColumns(schema string) []string {
+	var cols []string
+	for _, stmt := range sqlStatements(schema) {
+		const prefix = "CREATE TABLE IF NOT EXISTS "
+		if !strings.HasPrefix(stmt, prefix) {
+			continue
+		}
+		lines := strings.Split(stmt, "\n")
+		table := strings.TrimSuffix(strings.TrimPrefix(lines[0], prefix), "(")
+		for _, line := range lines[1:] {
+			name := strings.Fields(line)[0]
+			switch strings.ToUpper(strings.TrimRight(name, "(")) {
+			case ")", "PRIMARY", "UNIQUE", "INDEX", "KEY", "FOREIGN":
+				continue
+			}
+			cols = append(cols, table+"."+name)
+		}
+	}
+	return cols
+}
@@ -0,0 +1,123 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMigrations(t *testing.T) {
+	ms := Migrations()
+	if len(ms) == 0 {
+		t.Fatal("Migrations() returned none")
+	}
+	for i, m := range ms {
+		if want := i + 1; m.Version != want {
+			t.Errorf("Migrations()[%d].Version=%d, want %d", i, m.Version, want)
+		}
+		if len(sqlStatements(sqlFiles[m.file])) == 0 {
+			t.Errorf("migration %d (%s) has no statements", m.Version, m.Name)
+		}
+	}
+
+	// New databases are created from storage.sql, so it must record the latest version.
+	want := fmt.Sprintf("INSERT IGNORE INTO SchemaVersion(Version, AppliedTimestampNanos) VALUES(%d, 0)", LatestSchemaVersion())
+	var found bool
+	for _, stmt := range sqlStatements(sqlFiles[schemaFile]) {
+		found = found || stmt == want
+	}
+	if !found {
+		t.Errorf("%v doesn't contain %q", schemaFile, want)
+	}
+}
+
+// TestMigrationsMatchSchema checks that the migrations, from the baseline on, create
+// the columns storage.sql does.
+func TestMigrationsMatchSchema(t *testing.T) {
+	have := make(map[string]bool)
+	for _, m := range Migrations() {
+		migration := sqlFiles[m.file]
+		for _, c := range schemaColumns(migration) {
+			have[c] = true
+		}
+		for _, stmt := range sqlStatements(migration) {
+			lines := strings.Split(stmt, "\n")
+			if !strings.HasPrefix(lines[0], "ALTER TABLE ") {
+				continue
+			}
+			table := strings.TrimPrefix(lines[0], "ALTER TABLE ")
+			for _, line := range lines[1:] {
+				if f := strings.Fields(line); len(f) > 2 && f[0] == "ADD" && f[1] == "COLUMN" {
+					have[table+"."+f[2]] = true
+				}
+			}
+		}
+	}
+	for _, c := range schemaColumns(sqlFiles[schemaFile]) {
+		// Migrate creates the SchemaVersion table itself.
+		if strings.HasPrefix(c, "SchemaVersion.") {
+			continue
+		}
+		if !have[c] {
+			t.Errorf("no migration creates %v", c)
+		}
+		delete(have, c)
+	}
+	for c := range have {
+		t.Errorf("migrations create %v, which %v doesn't", c, schemaFile)
+	}
+}
+
+func TestSchemaColumns(t *testing.T) {
+	schema := `SET GLOBAL sql_mode = 'STRICT_ALL_TABLES';
+CREATE TABLE IF NOT EXISTS A(
+  Id     INT NOT NULL, -- comment
+  Value  BLOB,
+  PRIMARY KEY(Id),
+  UNIQUE INDEX ValueIdx(Value),
+  FOREIGN KEY(Id) REFERENCES B(Id)
+);`
+	if got, want := schemaColumns(schema), []string{"A.Id", "A.Value"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("schemaColumns()=%v, want %v", got, want)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	// The test database is created from storage.sql, so it's already up to date.
+	applied, err := Migrate(ctx, DB, 0)
+	if err != nil {
+		t.Fatalf("Migrate()=%v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("Migrate() applied %v, want none", applied)
+	}
+	if err := VerifySchema(ctx, DB); err != nil {
+		t.Errorf("VerifySchema()=%v", err)
+	}
+
+	for _, target := range []int{LatestSchemaVersion() + 1, LatestSchemaVersion() - 1} {
+		if target < 1 {
+			continue
+		}
+		if _, err := Migrate(ctx, DB, target); err == nil || !strings.Contains(err.Error(), "version") {
+			t.Errorf("Migrate(%d)=%v, want version error", target, err)
+		}
+	}
+}
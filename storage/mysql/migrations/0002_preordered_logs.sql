@@ -0,0 +1,4 @@
+-- Adds PREORDERED_LOG trees, logs whose leaves are sequenced by their source rather
+-- than the signer, e.g. mirrors of another log.
+ALTER TABLE Trees
+  MODIFY COLUMN TreeType ENUM('LOG', 'MAP', 'PREORDERED_LOG') NOT NULL;
@@ -0,0 +1,5 @@
+-- The number of leaves the signer sequences in a pass over a log, and how often it
+-- runs them. Zero means the signer uses its own defaults.
+ALTER TABLE TreeControl
+  ADD COLUMN SequencingBatchSize INTEGER NOT NULL DEFAULT 0,
+  ADD COLUMN SequencingIntervalSeconds INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+-- How long leaves wait in a log's queue before the signer may sequence them. Zero
+-- means the signer uses its own default.
+ALTER TABLE TreeControl
+  ADD COLUMN SequencingGuardWindowSeconds INTEGER NOT NULL DEFAULT 0;
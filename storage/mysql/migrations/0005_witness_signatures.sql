@@ -0,0 +1,11 @@
+-- Countersignatures made by witnesses over a log's STHs. Each witness signs an
+-- STH at most once, a later signature replaces an earlier one.
+CREATE TABLE IF NOT EXISTS WitnessSignature(
+  TreeId               BIGINT NOT NULL,
+  TreeRevision         BIGINT NOT NULL,
+  WitnessName          VARCHAR(255) NOT NULL,
+  -- A serialized DigitallySigned over the same data as the STH's RootSignature.
+  Signature            VARBINARY(1024) NOT NULL,
+  PRIMARY KEY(TreeId, TreeRevision, WitnessName),
+  FOREIGN KEY(TreeId, TreeRevision) REFERENCES TreeHead(TreeId, TreeRevision) ON DELETE CASCADE
+);
@@ -0,0 +1,14 @@
+-- Roots of a log observed by clients, e.g. through gossip, and whether they were
+-- consistent with the log's own history. Inconsistent roots are evidence of a
+-- split view and must be kept.
+CREATE TABLE IF NOT EXISTS ObservedTreeHead(
+  TreeId               BIGINT NOT NULL,
+  TreeHeadTimestamp    BIGINT NOT NULL,
+  TreeSize             BIGINT NOT NULL,
+  RootHash             VARBINARY(255) NOT NULL,
+  RootSignature        VARBINARY(1024) NOT NULL,
+  Consistent           BOOLEAN NOT NULL,
+  ObservedTimestampNanos BIGINT NOT NULL,
+  PRIMARY KEY(TreeId, TreeHeadTimestamp, TreeSize, RootHash),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
@@ -0,0 +1,8 @@
+-- A single row holding the time it was last written on the primary, by
+-- WriteHeartbeats. Read replicas compare it to the current time to measure
+-- how far behind the primary they are.
+CREATE TABLE IF NOT EXISTS ReplicationHeartbeat(
+  Id                   INT NOT NULL,
+  TimestampNanos       BIGINT NOT NULL,
+  PRIMARY KEY(Id)
+);
@@ -0,0 +1,8 @@
+-- Adds per-tree compression of leaf data. A tree's LeafCompression applies to the
+-- LeafData rows written after it's set, each row records the compression it was
+-- stored with, so existing rows are left uncompressed.
+ALTER TABLE TreeControl
+  ADD COLUMN LeafCompression ENUM('UNCOMPRESSED', 'SNAPPY', 'ZSTD') NOT NULL DEFAULT 'UNCOMPRESSED';
+
+ALTER TABLE LeafData
+  ADD COLUMN Compression ENUM('UNCOMPRESSED', 'SNAPPY', 'ZSTD') NOT NULL DEFAULT 'UNCOMPRESSED';
@@ -0,0 +1,4 @@
+-- If set, LeafValue is empty and the (compressed) value is kept in the blob store
+-- under this key instead.
+ALTER TABLE LeafData
+  ADD COLUMN LeafValueLocator VARCHAR(255);
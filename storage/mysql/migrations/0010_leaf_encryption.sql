@@ -0,0 +1,12 @@
+-- Adds encryption of leaf data at rest. Each tree's data key is wrapped by a key
+-- management service and created when its first leaf is encrypted. Existing rows
+-- aren't encrypted.
+CREATE TABLE IF NOT EXISTS TreeDataKey(
+  TreeId                       BIGINT NOT NULL,
+  WrappedKey                   VARBINARY(1024) NOT NULL,
+  PRIMARY KEY(TreeId),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+ALTER TABLE LeafData
+  ADD COLUMN Encrypted BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,3 @@
+-- Indexes the queue timestamps of unsequenced leaves, so the number of leaves waiting
+-- in a log's queue and the age of the oldest can be read without scanning it.
+CREATE INDEX QueueTimestampIdx ON Unsequenced(TreeId, QueueTimestampNanos);
@@ -0,0 +1,7 @@
+-- Adds the shard set and validity window of logs which are time-based shards of a
+-- larger log. Unsharded trees have a ShardSetId of zero.
+ALTER TABLE Trees
+  ADD COLUMN ShardSetId BIGINT NOT NULL DEFAULT 0,
+  ADD COLUMN ShardStartMillis BIGINT NOT NULL DEFAULT 0,
+  ADD COLUMN ShardEndMillis BIGINT NOT NULL DEFAULT 0,
+  ADD INDEX ShardSetIdx(ShardSetId, ShardStartMillis);
@@ -0,0 +1,15 @@
+-- Adds per-tree leaf retention. The values and extra data of leaves integrated more
+-- than LeafRetentionSeconds ago are pruned by PruneExpiredLeafData, which marks the
+-- rows Expired and records how far into each log it has got in LeafExpiry.
+ALTER TABLE TreeControl
+  ADD COLUMN LeafRetentionSeconds INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE LeafData
+  ADD COLUMN Expired BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS LeafExpiry(
+  TreeId               BIGINT NOT NULL,
+  ExpiredTreeSize      BIGINT NOT NULL,
+  PRIMARY KEY(TreeId),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
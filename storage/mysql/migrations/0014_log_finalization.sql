@@ -0,0 +1,5 @@
+-- Records when a frozen log was finalized, after the signer drained its queue and
+-- signed its final root, and the size of that root. Zero if the log isn't finalized.
+ALTER TABLE TreeControl
+  ADD COLUMN FinalizeTimeMillis BIGINT NOT NULL DEFAULT 0,
+  ADD COLUMN FinalizedTreeSize BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,8 @@
+-- Records which database shard each tree is kept in, for servers spreading trees
+-- across several databases. Only the primary shard's table is used, trees without a
+-- row are kept in the primary shard.
+CREATE TABLE IF NOT EXISTS TreePlacement(
+  TreeId               BIGINT NOT NULL,
+  DatabaseName         VARCHAR(255) NOT NULL,
+  PRIMARY KEY(TreeId)
+);
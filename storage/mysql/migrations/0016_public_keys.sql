@@ -0,0 +1,4 @@
+-- The public key of a tree's signing key, a DER-encoded PKIX key. Verification-only
+-- trees have a public key but no private key, their PrivateKey is empty.
+ALTER TABLE Trees
+  ADD COLUMN PublicKey BLOB;
@@ -0,0 +1,4 @@
+-- The weight of a log when the signer shares its sequencing passes between logs by
+-- priority. Zero is treated as a priority of 1.
+ALTER TABLE TreeControl
+  ADD COLUMN SequencingPriority INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+-- The maximum merge delay of a log, the longest in seconds the server commits to
+-- taking to integrate a queued leaf. Zero if the log has none.
+ALTER TABLE TreeControl
+  ADD COLUMN MaxMergeDelaySeconds INTEGER NOT NULL DEFAULT 0;
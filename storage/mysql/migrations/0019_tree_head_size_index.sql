@@ -0,0 +1,3 @@
+-- Indexes the signed roots of each log by tree size, so the root a read pinned to a
+-- tree size is answered against can be found without scanning the log's roots.
+CREATE INDEX TreeSizeIdx ON TreeHead(TreeId, TreeSize);
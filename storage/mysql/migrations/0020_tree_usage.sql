@@ -0,0 +1,12 @@
+-- The bytes of leaf data and Merkle tree nodes stored for each tree. Writers add
+-- to one of several rows of the tree, picked at random, so they rarely wait for
+-- each other, and the tree's usage is the sum of its rows. ReconcileTreeUsage
+-- periodically recounts it, correcting for data deleted or pruned since.
+CREATE TABLE IF NOT EXISTS TreeUsage(
+  TreeId               BIGINT NOT NULL,
+  Shard                INTEGER NOT NULL,
+  LeafBytes            BIGINT NOT NULL DEFAULT 0,
+  NodeBytes            BIGINT NOT NULL DEFAULT 0,
+  PRIMARY KEY(TreeId, Shard),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
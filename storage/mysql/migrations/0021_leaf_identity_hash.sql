@@ -0,0 +1,5 @@
+-- How the identity hashes of a log's leaves are computed, which they're deduplicated
+-- by. Existing trees keep using the hashes supplied with their leaves.
+ALTER TABLE Trees
+  ADD COLUMN LeafIdentityHashStrategy ENUM('CLIENT_SUPPLIED', 'SHA256_LEAF_VALUE', 'SHA256_LEAF_VALUE_AND_EXTRA_DATA', 'HMAC_SHA256_LEAF_VALUE') NOT NULL DEFAULT 'CLIENT_SUPPLIED',
+  ADD COLUMN LeafIdentityHashKey VARBINARY(255);
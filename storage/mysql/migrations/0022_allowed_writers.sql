@@ -0,0 +1,4 @@
+-- SPIFFE IDs of the clients allowed to add leaves to a log, one per line. Existing
+-- trees may be written to by any client.
+ALTER TABLE Trees
+  ADD COLUMN AllowedWriters TEXT;
@@ -0,0 +1,191 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/errors"
+)
+
+const (
+	selectTreeStateSQL = `SELECT TreeState,TreeType,COALESCE(FinalizeTimeMillis, 0)
+			FROM Trees LEFT JOIN TreeControl ON Trees.TreeId = TreeControl.TreeId
+			WHERE Trees.TreeId = ?`
+	replaceTreePlacementSQL = "REPLACE INTO TreePlacement(TreeId, DatabaseName) VALUES(?, ?)"
+
+	// moveBatchRows is the most rows copied by each INSERT when moving a tree, rows of
+	// subtrees and leaves can be large.
+	moveBatchRows = 50
+)
+
+// treeTables are the tables holding the data of a tree, each after those it refers to.
+var treeTables = []string{
+	"Trees", "TreeControl", "TreeDataKey", "TreeUsage", "Subtree", "TreeHead", "WitnessSignature", "ObservedTreeHead",
+	"LeafData", "SequencedLeafData", "Unsequenced", "LeafExpiry", "MapLeaf", "MapHead",
+}
+
+// MoveTree moves treeID and all of its data from the shard it's placed in to the shard
+// named to, of db, the primary shard, and shards. The tree must be FROZEN, so it isn't
+// written while it's copied, and a log must also have been finalized, so the signer
+// isn't still draining its queue.
+// Storage for the same shards uses the new copy of the tree once its placement has been
+// updated, the old one is deleted afterwards. It returns the number of rows copied.
+func MoveTree(ctx context.Context, db *sql.DB, shards []DatabaseShard, treeID int64, to string) (int64, error) {
+	dbs, err := newShardedDBs(db, shards)
+	if err != nil {
+		return 0, err
+	}
+	from, err := dbs.forTree(ctx, treeID)
+	if err != nil {
+		return 0, err
+	}
+	dest, ok := dbs.byName(to)
+	if !ok {
+		return 0, errors.Errorf(errors.InvalidArgument, "unknown shard %q", to)
+	}
+	if dest == from {
+		return 0, errors.Errorf(errors.InvalidArgument, "tree %v is already in %v", treeID, dbs.name(from))
+	}
+	srcDB, destDB := dbs.dbs()[from], dbs.dbs()[dest]
+
+	copied, err := copyTree(ctx, srcDB, destDB, treeID)
+	if err != nil {
+		return 0, err
+	}
+	glog.Infof("%v: copied %d rows from %v to %v", treeID, copied, dbs.name(from), dbs.name(dest))
+
+	if _, err := db.ExecContext(ctx, replaceTreePlacementSQL, treeID, to); err != nil {
+		if derr := deleteTree(ctx, destDB, treeID); derr != nil {
+			glog.Errorf("%v: failed to delete copy from %v: %v", treeID, dbs.name(dest), derr)
+		}
+		return 0, fmt.Errorf("failed to update placement of tree %v: %v", treeID, err)
+	}
+	if err := deleteTree(ctx, srcDB, treeID); err != nil {
+		return copied, fmt.Errorf("tree %v moved, but failed to delete it from %v: %v", treeID, dbs.name(from), err)
+	}
+	return copied, nil
+}
+
+// copyTree copies the rows of treeID, which must be FROZEN, and finalized if it's a log,
+// from src to dest in a single transaction, and returns the number of rows copied.
+func copyTree(ctx context.Context, src, dest *sql.DB, treeID int64) (int64, error) {
+	srcTx, err := src.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer srcTx.Rollback()
+	var state, treeType string
+	var finalized int64
+	switch err := srcTx.QueryRowContext(ctx, selectTreeStateSQL, treeID).Scan(&state, &treeType, &finalized); {
+	case err == sql.ErrNoRows:
+		return 0, errors.Errorf(errors.NotFound, "tree %v not found", treeID)
+	case err != nil:
+		return 0, err
+	case state != trillian.TreeState_FROZEN.String():
+		return 0, errors.Errorf(errors.FailedPrecondition, "tree %v is %v, it must be FROZEN to be moved", treeID, state)
+	case treeType != trillian.TreeType_MAP.String() && finalized == 0:
+		return 0, errors.Errorf(errors.FailedPrecondition, "log %v isn't finalized yet, its queue may still be being drained", treeID)
+	}
+
+	destTx, err := dest.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer destTx.Rollback()
+	var copied int64
+	for _, table := range treeTables {
+		n, err := copyTreeRows(ctx, srcTx, destTx, table, treeID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to copy %v: %v", table, err)
+		}
+		copied += n
+	}
+	if err := destTx.Commit(); err != nil {
+		return 0, err
+	}
+	return copied, nil
+}
+
+// copyTreeRows copies the rows of treeID in table from src to dest, and returns how
+// many there were.
+func copyTreeRows(ctx context.Context, src, dest *sql.Tx, table string, treeID int64) (int64, error) {
+	rows, err := src.QueryContext(ctx, fmt.Sprintf("SELECT * FROM %s WHERE TreeId = ?", table), treeID)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	insert := fmt.Sprintf("INSERT INTO %s(%s) VALUES", table, strings.Join(cols, ","))
+	row := "(" + strings.TrimSuffix(strings.Repeat("?,", len(cols)), ",") + ")"
+
+	var copied int64
+	var args []interface{}
+	batch := 0
+	flush := func() error {
+		if batch == 0 {
+			return nil
+		}
+		if _, err := dest.ExecContext(ctx, insert+strings.TrimSuffix(strings.Repeat(row+",", batch), ","), args...); err != nil {
+			return err
+		}
+		copied += int64(batch)
+		args, batch = nil, 0
+		return nil
+	}
+	for rows.Next() {
+		vals := make([]interface{}, len(cols))
+		ptrs := make([]interface{}, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return copied, err
+		}
+		args = append(args, vals...)
+		if batch++; batch == moveBatchRows {
+			if err := flush(); err != nil {
+				return copied, err
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return copied, err
+	}
+	return copied, flush()
+}
+
+// deleteTree deletes all the rows of treeID from db in a single transaction.
+func deleteTree(ctx context.Context, db *sql.DB, treeID int64) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for i := len(treeTables) - 1; i >= 0; i-- {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE TreeId = ?", treeTables[i]), treeID); err != nil {
+			return fmt.Errorf("failed to delete from %v: %v", treeTables[i], err)
+		}
+	}
+	return tx.Commit()
+}
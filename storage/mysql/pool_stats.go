@@ -0,0 +1,68 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/trillian/monitoring/metric"
+)
+
+// poolGauges are the metrics a connection pool's statistics are exported as.
+type poolGauges struct {
+	maxOpen, open, inUse, idle, waitCount, waitMillis metric.Gauge
+}
+
+func newPoolGauges(name string) *poolGauges {
+	prefix := "mysql_" + name + "_pool_"
+	return &poolGauges{
+		maxOpen:    metric.NewGauge(prefix + "max_open_connections"),
+		open:       metric.NewGauge(prefix + "open_connections"),
+		inUse:      metric.NewGauge(prefix + "in_use_connections"),
+		idle:       metric.NewGauge(prefix + "idle_connections"),
+		waitCount:  metric.NewGauge(prefix + "wait_count"),
+		waitMillis: metric.NewGauge(prefix + "wait_duration_ms"),
+	}
+}
+
+func (g *poolGauges) record(s sql.DBStats) {
+	g.maxOpen.Set(int64(s.MaxOpenConnections))
+	g.open.Set(int64(s.OpenConnections))
+	g.inUse.Set(int64(s.InUse))
+	g.idle.Set(int64(s.Idle))
+	g.waitCount.Set(s.WaitCount)
+	g.waitMillis.Set(int64(s.WaitDuration / time.Millisecond))
+}
+
+// ExportPoolStats exports the connection pool statistics of db as metrics named
+// mysql_<name>_pool_*, refreshing them every interval until ctx is done. The wait
+// count and duration are totals since db was opened, so spikes in RPC latency caused
+// by the pool running out of connections show up as jumps in them.
+func ExportPoolStats(ctx context.Context, db *sql.DB, name string, interval time.Duration) {
+	g := newPoolGauges(name)
+	g.record(db.Stats())
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			g.record(db.Stats())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type fakeGauge struct{ value int64 }
+
+func (g *fakeGauge) Set(n int64) { g.value = n }
+
+func TestPoolGaugesRecord(t *testing.T) {
+	var maxOpen, open, inUse, idle, waitCount, waitMillis fakeGauge
+	g := &poolGauges{
+		maxOpen:    &maxOpen,
+		open:       &open,
+		inUse:      &inUse,
+		idle:       &idle,
+		waitCount:  &waitCount,
+		waitMillis: &waitMillis,
+	}
+	g.record(sql.DBStats{
+		MaxOpenConnections: 10,
+		OpenConnections:    7,
+		InUse:              5,
+		Idle:               2,
+		WaitCount:          3,
+		WaitDuration:       1500 * time.Millisecond,
+	})
+
+	got := []int64{maxOpen.value, open.value, inUse.value, idle.value, waitCount.value, waitMillis.value}
+	if want := []int64{10, 7, 5, 2, 3, 1500}; !reflect.DeepEqual(got, want) {
+		t.Errorf("record() set gauges to %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"expvar"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/util"
+)
+
+const (
+	writeHeartbeatSQL = "REPLACE INTO ReplicationHeartbeat(Id, TimestampNanos) VALUES(0, ?)"
+	readHeartbeatSQL  = "SELECT TimestampNanos FROM ReplicationHeartbeat WHERE Id = 0"
+)
+
+var replicaStats = expvar.NewMap("mysql-replica")
+
+// ReplicaOptions controls when snapshots are taken from the replica.
+type ReplicaOptions struct {
+	// MaxLag is how far the replica may be behind the primary before snapshots are
+	// taken from the primary instead. Lag is measured with the heartbeats written by
+	// WriteHeartbeats, so it should be comfortably more than their interval. Zero
+	// means the replica is always used.
+	MaxLag time.Duration
+	// CheckInterval is how long a lag measurement is reused for before the replica's
+	// heartbeat is read again.
+	CheckInterval time.Duration
+	// Storage configures the storage of both the primary and the replica.
+	Storage StorageOptions
+}
+
+// replicatedLogStorage sends read-write transactions to the primary database and
+// snapshots to a read replica of it, unless the replica has fallen too far behind.
+type replicatedLogStorage struct {
+	storage.LogStorage
+	replica    storage.LogStorage
+	replicaDB  *sql.DB
+	opts       ReplicaOptions
+	timeSource util.TimeSource
+
+	// mu guards the latest lag measurement, it isn't held while the replica is queried.
+	mu      sync.Mutex
+	checked time.Time
+	fresh   bool
+}
+
+// NewLogStorageWithReplica creates a LogStorage which begins read-write transactions on
+// db and snapshots on replica, a read replica of db. Snapshots may lag behind writes by
+// up to opts.MaxLag, if the replica is further behind they're taken from db.
+func NewLogStorageWithReplica(db, replica *sql.DB, opts ReplicaOptions) storage.LogStorage {
+	return &replicatedLogStorage{
+		LogStorage: NewLogStorageWithOptions(db, opts.Storage),
+		replica:    NewLogStorageWithOptions(replica, opts.Storage),
+		replicaDB:  replica,
+		opts:       opts,
+		timeSource: util.SystemTimeSource{},
+	}
+}
+
+func (m *replicatedLogStorage) CheckDatabaseAccessible(ctx context.Context) error {
+	if err := m.LogStorage.CheckDatabaseAccessible(ctx); err != nil {
+		return err
+	}
+	if err := m.replica.CheckDatabaseAccessible(ctx); err != nil {
+		return fmt.Errorf("replica: %v", err)
+	}
+	return nil
+}
+
+// IsTransientError implements storage.TransientErrorChecker, so read-write
+// transactions on the primary are still retried.
+func (m *replicatedLogStorage) IsTransientError(err error) bool {
+	return m.LogStorage.(storage.TransientErrorChecker).IsTransientError(err)
+}
+
+func (m *replicatedLogStorage) Snapshot(ctx context.Context) (storage.ReadOnlyLogTX, error) {
+	return m.snapshotStorage().Snapshot(ctx)
+}
+
+func (m *replicatedLogStorage) SnapshotForTree(ctx context.Context, treeID int64) (storage.ReadOnlyLogTreeTX, error) {
+	return m.snapshotStorage().SnapshotForTree(ctx, treeID)
+}
+
+// snapshotStorage returns the replica if it's within MaxLag of the primary, otherwise
+// the primary.
+func (m *replicatedLogStorage) snapshotStorage() storage.LogStorage {
+	if m.opts.MaxLag <= 0 {
+		return m.replica
+	}
+
+	now := m.timeSource.Now()
+	m.mu.Lock()
+	check := m.checked.IsZero() || now.Sub(m.checked) >= m.opts.CheckInterval
+	if check {
+		// Other snapshots use the previous measurement while this one is taken,
+		// rather than waiting for the replica.
+		m.checked = now
+	}
+	fresh := m.fresh
+	m.mu.Unlock()
+
+	if check {
+		fresh = m.replicaFresh(now)
+		m.mu.Lock()
+		m.fresh = fresh
+		m.mu.Unlock()
+	}
+
+	if !fresh {
+		replicaStats.Add("primary-snapshots", 1)
+		return m.LogStorage
+	}
+	replicaStats.Add("replica-snapshots", 1)
+	return m.replica
+}
+
+// replicaFresh returns whether the replica is within MaxLag of the primary as of now.
+func (m *replicatedLogStorage) replicaFresh(now time.Time) bool {
+	lag, err := replicationLag(m.replicaDB, now)
+	switch {
+	case err != nil:
+		glog.Warningf("Failed to read replica heartbeat, reading from primary: %v", err)
+		return false
+	case lag > m.opts.MaxLag:
+		glog.Warningf("Replica is %v behind, over the %v limit, reading from primary", lag, m.opts.MaxLag)
+		return false
+	}
+	return true
+}
+
+// replicationLag returns how long ago, as of now, the latest heartbeat visible on db was
+// written to the primary.
+func replicationLag(db *sql.DB, now time.Time) (time.Duration, error) {
+	var nanos int64
+	if err := db.QueryRow(readHeartbeatSQL).Scan(&nanos); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("no heartbeat found, is anything calling WriteHeartbeats?")
+		}
+		return 0, err
+	}
+	return now.Sub(time.Unix(0, nanos)), nil
+}
+
+// WriteHeartbeats writes the current time to db every interval until ctx is done. Read
+// replicas of db use the latest heartbeat they've received to measure how far behind
+// they are, so it should run against the primary wherever NewLogStorageWithReplica is
+// given a MaxLag. Lag measurements include any clock skew between the writer and readers.
+func WriteHeartbeats(ctx context.Context, db *sql.DB, interval time.Duration, timeSource util.TimeSource) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := db.ExecContext(ctx, writeHeartbeatSQL, timeSource.Now().UnixNano()); err != nil {
+			glog.Warningf("Failed to write replication heartbeat: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	spb "github.com/google/trillian/crypto/sigpb"
+	"github.com/google/trillian/util"
+)
+
+func TestLogStorageWithReplica(t *testing.T) {
+	cleanTestDB(DB)
+	logID := createLogForTests(DB)
+	// The test database stands in for its own replica, through a separate connection
+	// pool so statements prepared on one can't leak into the other.
+	replica := openTestDBOrDie()
+	defer replica.Close()
+	s := NewLogStorageWithReplica(DB, replica, ReplicaOptions{})
+	ctx := context.Background()
+
+	if err := s.CheckDatabaseAccessible(ctx); err != nil {
+		t.Fatalf("CheckDatabaseAccessible()=%v", err)
+	}
+
+	tx := beginLogTx(s, logID, t)
+	defer tx.Close()
+	root := trillian.SignedLogRoot{
+		LogId:          logID,
+		TimestampNanos: 98765,
+		TreeSize:       16,
+		TreeRevision:   5,
+		RootHash:       []byte(dummyHash),
+		Signature:      &spb.DigitallySigned{Signature: []byte("notempty")},
+	}
+	if err := tx.StoreSignedLogRoot(root); err != nil {
+		t.Fatalf("Failed to store signed root: %v", err)
+	}
+	commit(tx, t)
+
+	snapshot, err := s.SnapshotForTree(ctx, logID)
+	if err != nil {
+		t.Fatalf("SnapshotForTree()=%v", err)
+	}
+	defer snapshot.Close()
+	got, err := snapshot.LatestSignedLogRoot()
+	if err != nil {
+		t.Fatalf("LatestSignedLogRoot()=%v", err)
+	}
+	if !proto.Equal(&got, &root) {
+		t.Errorf("LatestSignedLogRoot()=%v, want %v", got, root)
+	}
+	commit(snapshot, t)
+}
+
+func TestLogStorageWithReplicaLag(t *testing.T) {
+	cleanTestDB(DB)
+	replica := openTestDBOrDie()
+	defer replica.Close()
+	s := NewLogStorageWithReplica(DB, replica, ReplicaOptions{MaxLag: 5 * time.Second, CheckInterval: time.Second}).(*replicatedLogStorage)
+	timeSource := &util.FakeTimeSource{FakeTime: time.Unix(1000, 0)}
+	s.timeSource = timeSource
+
+	// Without a heartbeat the replica's lag is unknown.
+	if got := s.snapshotStorage(); got != s.LogStorage {
+		t.Errorf("snapshotStorage() without heartbeat didn't use the primary")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	WriteHeartbeats(ctx, DB, time.Hour, timeSource)
+
+	for _, test := range []struct {
+		desc        string
+		advance     time.Duration
+		wantReplica bool
+	}{
+		// The failed check above is reused until CheckInterval has passed.
+		{desc: "cached", advance: 500 * time.Millisecond},
+		{desc: "fresh", advance: time.Second, wantReplica: true},
+		{desc: "withinLimit", advance: 3 * time.Second, wantReplica: true},
+		{desc: "stale", advance: 2 * time.Second},
+	} {
+		timeSource.FakeTime = timeSource.FakeTime.Add(test.advance)
+		want := s.LogStorage
+		if test.wantReplica {
+			want = s.replica
+		}
+		if got := s.snapshotStorage(); got != want {
+			t.Errorf("%v: snapshotStorage() used the wrong database, want replica=%v", test.desc, test.wantReplica)
+		}
+	}
+}
@@ -0,0 +1,392 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+)
+
+// dbRouter decides which of a number of databases each tree is kept in. The databases
+// are numbered from 0, each holds a full schema and all of the data of its trees.
+type dbRouter interface {
+	// dbs returns the databases, in order.
+	dbs() []*sql.DB
+	// name returns the name of database i, for messages.
+	name(i int) string
+	// forTree returns the number of the database treeID is kept in.
+	forTree(ctx context.Context, treeID int64) (int, error)
+	// forNewTree returns the number of the database a tree created by tx with ctx is
+	// kept in.
+	forNewTree(ctx context.Context, tx *routedAdminTX) (int, error)
+	// treeIDGenerator returns a function generating the IDs of trees created in
+	// database i.
+	treeIDGenerator(i int) func() (int64, error)
+	// placed records, as part of tx, that treeID was created in database i.
+	placed(ctx context.Context, tx *routedAdminTX, treeID int64, i int) error
+}
+
+// routedAdminStorage keeps each tree in the database chosen by its router.
+type routedAdminStorage struct {
+	router   dbRouter
+	storages []*mysqlAdminStorage
+}
+
+func newRoutedAdminStorage(router dbRouter) *routedAdminStorage {
+	s := &routedAdminStorage{router: router}
+	for i, db := range router.dbs() {
+		s.storages = append(s.storages, &mysqlAdminStorage{db: db, newTreeID: router.treeIDGenerator(i)})
+	}
+	return s
+}
+
+func (s *routedAdminStorage) Snapshot(ctx context.Context) (storage.ReadOnlyAdminTX, error) {
+	return s.Begin(ctx)
+}
+
+func (s *routedAdminStorage) Begin(ctx context.Context) (storage.AdminTX, error) {
+	return &routedAdminTX{
+		s:       s,
+		ctx:     ctx,
+		txs:     make([]*adminTX, len(s.storages)),
+		placed:  make(map[int64]int),
+		written: make(map[int]bool),
+	}, nil
+}
+
+// routedAdminTX is a transaction on each database it's used with, begun when it's first
+// needed. Committing it isn't atomic across databases, the transactions are committed
+// in the order of their databases.
+type routedAdminTX struct {
+	s   *routedAdminStorage
+	ctx context.Context
+
+	mu  sync.Mutex
+	txs []*adminTX
+	// placed holds the databases of the trees the transaction has looked up, so its
+	// router is asked once per tree, and of those it created, which its router might
+	// not know about until it's committed.
+	placed map[int64]int
+	// written holds the databases written to.
+	written map[int]bool
+	closed  bool
+}
+
+// tx returns the transaction on database i, beginning it if needed.
+func (t *routedAdminTX) tx(i int) (*adminTX, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil, sql.ErrTxDone
+	}
+	if t.txs[i] == nil {
+		tx, err := t.s.storages[i].Begin(t.ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%v database: %v", t.s.router.name(i), err)
+		}
+		t.txs[i] = tx.(*adminTX)
+	}
+	return t.txs[i], nil
+}
+
+// forTree returns the transaction on the database treeID is kept in, and its number.
+func (t *routedAdminTX) forTree(ctx context.Context, treeID int64) (*adminTX, int, error) {
+	t.mu.Lock()
+	i, ok := t.placed[treeID]
+	t.mu.Unlock()
+	if !ok {
+		var err error
+		if i, err = t.s.router.forTree(ctx, treeID); err != nil {
+			return nil, 0, err
+		}
+		t.mu.Lock()
+		t.placed[treeID] = i
+		t.mu.Unlock()
+	}
+	tx, err := t.tx(i)
+	return tx, i, err
+}
+
+// wrote records a write to database i.
+func (t *routedAdminTX) wrote(i int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.written[i] = true
+}
+
+// Atomic implements storage.AtomicityChecker, the writes are atomic while they're all
+// to one database.
+func (t *routedAdminTX) Atomic() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.written) <= 1
+}
+
+// finish commits or rolls back the transactions begun, returning the first error.
+func (t *routedAdminTX) finish(commit bool) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+	var firstErr error
+	for i, tx := range t.txs {
+		if tx == nil {
+			continue
+		}
+		var err error
+		if commit && firstErr == nil {
+			err = tx.Commit()
+		} else {
+			err = tx.Rollback()
+		}
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%v database: %v", t.s.router.name(i), err)
+		}
+	}
+	return firstErr
+}
+
+func (t *routedAdminTX) Commit() error {
+	return t.finish(true)
+}
+
+func (t *routedAdminTX) Rollback() error {
+	return t.finish(false)
+}
+
+func (t *routedAdminTX) IsClosed() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.closed
+}
+
+func (t *routedAdminTX) Close() error {
+	if t.IsClosed() {
+		return nil
+	}
+	err := t.Rollback()
+	if err != nil {
+		glog.Warningf("Rollback error on Close(): %v", err)
+	}
+	return err
+}
+
+func (t *routedAdminTX) GetTree(ctx context.Context, treeID int64) (*trillian.Tree, error) {
+	tx, _, err := t.forTree(ctx, treeID)
+	if err != nil {
+		return nil, err
+	}
+	return tx.GetTree(ctx, treeID)
+}
+
+func (t *routedAdminTX) ListTreeIDs(ctx context.Context) ([]int64, error) {
+	treeIDs := []int64{}
+	for i := range t.txs {
+		tx, err := t.tx(i)
+		if err != nil {
+			return nil, err
+		}
+		ids, err := tx.ListTreeIDs(ctx)
+		if err != nil {
+			return nil, err
+		}
+		treeIDs = append(treeIDs, ids...)
+	}
+	return treeIDs, nil
+}
+
+func (t *routedAdminTX) ListTrees(ctx context.Context) ([]*trillian.Tree, error) {
+	trees := []*trillian.Tree{}
+	for i := range t.txs {
+		tx, err := t.tx(i)
+		if err != nil {
+			return nil, err
+		}
+		ts, err := tx.ListTrees(ctx)
+		if err != nil {
+			return nil, err
+		}
+		trees = append(trees, ts...)
+	}
+	return trees, nil
+}
+
+// CheckNewTree implements storage.NewTreeChecker, with the checks of the database the
+// tree would be created in.
+func (t *routedAdminTX) CheckNewTree(ctx context.Context, tree *trillian.Tree) error {
+	i, err := t.s.router.forNewTree(ctx, t)
+	if err != nil {
+		return err
+	}
+	tx, err := t.tx(i)
+	if err != nil {
+		return err
+	}
+	return tx.CheckNewTree(ctx, tree)
+}
+
+func (t *routedAdminTX) CreateTree(ctx context.Context, tree *trillian.Tree) (*trillian.Tree, error) {
+	i, err := t.s.router.forNewTree(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := t.tx(i)
+	if err != nil {
+		return nil, err
+	}
+	t.wrote(i)
+	created, err := tx.CreateTree(ctx, tree)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.s.router.placed(ctx, t, created.TreeId, i); err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	t.placed[created.TreeId] = i
+	t.mu.Unlock()
+	return created, nil
+}
+
+func (t *routedAdminTX) UpdateTree(ctx context.Context, treeID int64, updateFunc func(*trillian.Tree)) (*trillian.Tree, error) {
+	tx, i, err := t.forTree(ctx, treeID)
+	if err != nil {
+		return nil, err
+	}
+	t.wrote(i)
+	return tx.UpdateTree(ctx, treeID, updateFunc)
+}
+
+// routedLogStorage keeps the data of each log in the database chosen by its router.
+type routedLogStorage struct {
+	router   dbRouter
+	storages []storage.LogStorage
+}
+
+func newRoutedLogStorage(router dbRouter, opts StorageOptions) *routedLogStorage {
+	s := &routedLogStorage{router: router}
+	for _, db := range router.dbs() {
+		s.storages = append(s.storages, NewLogStorageWithOptions(db, opts))
+	}
+	return s
+}
+
+func (s *routedLogStorage) CheckDatabaseAccessible(ctx context.Context) error {
+	for i, ls := range s.storages {
+		if err := ls.CheckDatabaseAccessible(ctx); err != nil {
+			return fmt.Errorf("%v database: %v", s.router.name(i), err)
+		}
+	}
+	return nil
+}
+
+// IsTransientError implements storage.TransientErrorChecker, all of the databases
+// are MySQL so any of them can tell.
+func (s *routedLogStorage) IsTransientError(err error) bool {
+	return s.storages[0].(storage.TransientErrorChecker).IsTransientError(err)
+}
+
+func (s *routedLogStorage) Snapshot(ctx context.Context) (storage.ReadOnlyLogTX, error) {
+	tx := &routedLogTX{}
+	for i, ls := range s.storages {
+		ltx, err := ls.Snapshot(ctx)
+		if err != nil {
+			tx.Close()
+			return nil, fmt.Errorf("%v database: %v", s.router.name(i), err)
+		}
+		tx.txs = append(tx.txs, ltx)
+	}
+	return tx, nil
+}
+
+func (s *routedLogStorage) SnapshotForTree(ctx context.Context, treeID int64) (storage.ReadOnlyLogTreeTX, error) {
+	i, err := s.router.forTree(ctx, treeID)
+	if err != nil {
+		return nil, err
+	}
+	return s.storages[i].SnapshotForTree(ctx, treeID)
+}
+
+func (s *routedLogStorage) BeginForTree(ctx context.Context, treeID int64) (storage.LogTreeTX, error) {
+	i, err := s.router.forTree(ctx, treeID)
+	if err != nil {
+		return nil, err
+	}
+	return s.storages[i].BeginForTree(ctx, treeID)
+}
+
+// routedLogTX reads the logs in every database.
+type routedLogTX struct {
+	txs []storage.ReadOnlyLogTX
+}
+
+func (t *routedLogTX) Commit() error {
+	var firstErr error
+	for _, tx := range t.txs {
+		if err := tx.Commit(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (t *routedLogTX) Rollback() error {
+	var firstErr error
+	for _, tx := range t.txs {
+		if err := tx.Rollback(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (t *routedLogTX) Close() error {
+	var firstErr error
+	for _, tx := range t.txs {
+		if err := tx.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (t *routedLogTX) GetActiveLogIDs() ([]int64, error) {
+	var logIDs []int64
+	for _, tx := range t.txs {
+		ids, err := tx.GetActiveLogIDs()
+		if err != nil {
+			return nil, err
+		}
+		logIDs = append(logIDs, ids...)
+	}
+	return logIDs, nil
+}
+
+func (t *routedLogTX) GetActiveLogIDsWithPendingWork() ([]int64, error) {
+	var logIDs []int64
+	for _, tx := range t.txs {
+		ids, err := tx.GetActiveLogIDsWithPendingWork()
+		if err != nil {
+			return nil, err
+		}
+		logIDs = append(logIDs, ids...)
+	}
+	return logIDs, nil
+}
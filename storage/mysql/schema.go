@@ -0,0 +1,62 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+// schemaFile is the SQL file holding the schema, in sqlFiles.
+const schemaFile = "storage.sql"
+
+// CreateSchema creates any of the storage tables missing from db and applies any pending
+// migrations, leaving existing data alone, so it's safe to call every time a server
+// starts. Settings such as the global sql_mode in storage.sql need privileges servers
+// shouldn't have, so they're left to the operator.
+func CreateSchema(db *sql.DB) error {
+	applied, err := Migrate(context.Background(), db, 0)
+	for _, m := range applied {
+		glog.Infof("Applied schema migration %d (%s)", m.Version, m.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to apply schema: %v", err)
+	}
+	return nil
+}
+
+// sqlStatements splits the contents of a SQL file into statements, dropping comments.
+func sqlStatements(file string) []string {
+	var stmts []string
+	var lines []string
+	for _, line := range strings.Split(file, "\n") {
+		if i := strings.Index(line, "--"); i >= 0 {
+			line = line[:i]
+		}
+		if line = strings.TrimSpace(line); line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+		if strings.HasSuffix(line, ";") {
+			stmts = append(stmts, strings.TrimSuffix(strings.Join(lines, "\n"), ";"))
+			lines = nil
+		}
+	}
+	return stmts
+}
@@ -0,0 +1,68 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestSQLFilesUpToDate(t *testing.T) {
+	for name, got := range sqlFiles {
+		want, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatalf("ReadFile(%v)=%v", name, err)
+		}
+		if got != string(want) {
+			t.Errorf("sqlFiles[%q] differs from the file, run go generate", name)
+		}
+	}
+}
+
+func TestSQLStatements(t *testing.T) {
+	file := `# A comment
+-- Another comment; with a semicolon
+SET GLOBAL sql_mode = 'STRICT_ALL_TABLES';
+
+CREATE TABLE IF NOT EXISTS A(
+  Id INT NOT NULL, -- trailing comment
+  PRIMARY KEY(Id)
+);
+`
+	want := []string{
+		"SET GLOBAL sql_mode = 'STRICT_ALL_TABLES'",
+		"CREATE TABLE IF NOT EXISTS A(\nId INT NOT NULL,\nPRIMARY KEY(Id)\n)",
+	}
+	if got := sqlStatements(file); !reflect.DeepEqual(got, want) {
+		t.Errorf("sqlStatements()=%q, want %q", got, want)
+	}
+}
+
+func TestCreateSchema(t *testing.T) {
+	// The test database already has the schema, so this checks it's left alone.
+	cleanTestDB(DB)
+	logID := createLogForTests(DB)
+	if err := CreateSchema(DB); err != nil {
+		t.Fatalf("CreateSchema()=%v", err)
+	}
+	var count int
+	if err := DB.QueryRow("SELECT COUNT(*) FROM Trees WHERE TreeId=?", logID).Scan(&count); err != nil {
+		t.Fatalf("Could not query trees: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("CreateSchema() left %d rows for tree %d, want 1", count, logID)
+	}
+}
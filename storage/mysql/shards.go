@@ -0,0 +1,234 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/google/trillian/storage"
+)
+
+const (
+	// PrimaryShardName is the name of the database holding TreePlacement, which also
+	// keeps the trees not placed in any other shard.
+	PrimaryShardName = "primary"
+
+	selectTreePlacementSQL = "SELECT DatabaseName FROM TreePlacement WHERE TreeId = ?"
+	insertTreePlacementSQL = "INSERT INTO TreePlacement(TreeId, DatabaseName) VALUES(?, ?)"
+	countTreesSQL          = "SELECT COUNT(*) FROM Trees"
+)
+
+// DatabaseShard is one of the databases trees are spread across.
+type DatabaseShard struct {
+	// Name identifies the shard in TreePlacement, so it mustn't change while any
+	// trees are placed in it.
+	Name string
+	// DB holds the trees placed in the shard, it must have the same schema as any
+	// other Trillian database.
+	DB *sql.DB
+}
+
+// shardConfig is a shard as configured, before its database is opened.
+type shardConfig struct {
+	name, uri string
+}
+
+// parseShards reads shards from r, one per line as their name and MySQL URI separated
+// by a space. Blank lines and lines starting with # are ignored.
+func parseShards(r io.Reader) ([]shardConfig, error) {
+	var configs []shardConfig
+	names := map[string]bool{PrimaryShardName: true}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: got %d fields, want name and MySQL URI", line, len(fields))
+		}
+		if names[fields[0]] {
+			return nil, fmt.Errorf("line %d: shard name %q is already used", line, fields[0])
+		}
+		names[fields[0]] = true
+		configs = append(configs, shardConfig{name: fields[0], uri: fields[1]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
+
+// OpenDatabaseShards opens the databases of the shards listed in the file at path,
+// applying opts to their URIs. Each line of the file holds a shard's name and MySQL URI
+// separated by a space, e.g. "shard1 user:pass@tcp(shard1-db:3306)/trillian". Blank
+// lines and lines starting with # are ignored.
+func OpenDatabaseShards(path string, opts DBOptions) ([]DatabaseShard, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	configs, err := parseShards(f)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+
+	var shards []DatabaseShard
+	for _, c := range configs {
+		db, err := OpenDBWithOptions(c.uri, opts)
+		if err != nil {
+			for _, s := range shards {
+				s.DB.Close()
+			}
+			// Don't include the URI in errors, it could contain credentials.
+			return nil, fmt.Errorf("failed to open database of shard %q: %v", c.name, err)
+		}
+		shards = append(shards, DatabaseShard{Name: c.name, DB: db})
+	}
+	return shards, nil
+}
+
+// NewShardedAdminStorage returns an AdminStorage spreading trees across db, the primary
+// shard, and shards. Each new tree is created in the shard holding the fewest trees,
+// and where it's kept is recorded in the primary shard. Trees not recorded there, e.g.
+// those created before sharding, are kept in the primary shard.
+func NewShardedAdminStorage(db *sql.DB, shards []DatabaseShard) (storage.AdminStorage, error) {
+	dbs, err := newShardedDBs(db, shards)
+	if err != nil {
+		return nil, err
+	}
+	return newRoutedAdminStorage(dbs), nil
+}
+
+// NewShardedLogStorage returns a LogStorage keeping the data of each log in the shard
+// it was placed in by storage from NewShardedAdminStorage for the same shards,
+// configured by opts.
+func NewShardedLogStorage(db *sql.DB, shards []DatabaseShard, opts StorageOptions) (storage.LogStorage, error) {
+	dbs, err := newShardedDBs(db, shards)
+	if err != nil {
+		return nil, err
+	}
+	return newRoutedLogStorage(dbs, opts), nil
+}
+
+// shardedDBs routes trees to the shard TreePlacement says they're in. The primary shard
+// is numbered 0, shard i is numbered i+1.
+type shardedDBs struct {
+	db     *sql.DB
+	shards []DatabaseShard
+}
+
+func newShardedDBs(db *sql.DB, shards []DatabaseShard) (*shardedDBs, error) {
+	names := map[string]bool{PrimaryShardName: true}
+	for _, s := range shards {
+		switch {
+		case s.Name == "":
+			return nil, fmt.Errorf("shard has no name")
+		case names[s.Name]:
+			return nil, fmt.Errorf("shard name %q is already used", s.Name)
+		case s.DB == nil:
+			return nil, fmt.Errorf("shard %q has no database", s.Name)
+		}
+		names[s.Name] = true
+	}
+	return &shardedDBs{db: db, shards: shards}, nil
+}
+
+func (d *shardedDBs) dbs() []*sql.DB {
+	dbs := []*sql.DB{d.db}
+	for _, s := range d.shards {
+		dbs = append(dbs, s.DB)
+	}
+	return dbs
+}
+
+func (d *shardedDBs) name(i int) string {
+	if i == 0 {
+		return fmt.Sprintf("shard %v", PrimaryShardName)
+	}
+	return fmt.Sprintf("shard %v", d.shards[i-1].Name)
+}
+
+// byName returns the number of the named shard.
+func (d *shardedDBs) byName(name string) (int, bool) {
+	if name == PrimaryShardName {
+		return 0, true
+	}
+	for i, s := range d.shards {
+		if s.Name == name {
+			return i + 1, true
+		}
+	}
+	return 0, false
+}
+
+func (d *shardedDBs) forTree(ctx context.Context, treeID int64) (int, error) {
+	var name string
+	switch err := d.db.QueryRowContext(ctx, selectTreePlacementSQL, treeID).Scan(&name); {
+	case err == sql.ErrNoRows:
+		return 0, nil
+	case err != nil:
+		return 0, fmt.Errorf("failed to read placement of tree %v: %v", treeID, err)
+	}
+	i, ok := d.byName(name)
+	if !ok {
+		return 0, fmt.Errorf("tree %v is placed in unknown shard %q", treeID, name)
+	}
+	return i, nil
+}
+
+// forNewTree returns the shard holding the fewest trees.
+func (d *shardedDBs) forNewTree(ctx context.Context, tx *routedAdminTX) (int, error) {
+	best, fewest := 0, -1
+	for i := range tx.txs {
+		atx, err := tx.tx(i)
+		if err != nil {
+			return 0, err
+		}
+		var trees int
+		if err := atx.tx.QueryRowContext(ctx, countTreesSQL).Scan(&trees); err != nil {
+			return 0, fmt.Errorf("failed to count trees in %v: %v", d.name(i), err)
+		}
+		if fewest < 0 || trees < fewest {
+			best, fewest = i, trees
+		}
+	}
+	return best, nil
+}
+
+// placed records the shard of trees created outside the primary shard.
+func (d *shardedDBs) placed(ctx context.Context, tx *routedAdminTX, treeID int64, i int) error {
+	if i == 0 {
+		return nil
+	}
+	atx, err := tx.tx(0)
+	if err != nil {
+		return err
+	}
+	tx.wrote(0)
+	_, err = atx.tx.ExecContext(ctx, insertTreePlacementSQL, treeID, d.shards[i-1].Name)
+	return err
+}
+
+func (d *shardedDBs) treeIDGenerator(i int) func() (int64, error) {
+	return storage.NewTreeID
+}
@@ -0,0 +1,88 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseShards(t *testing.T) {
+	for _, test := range []struct {
+		desc    string
+		file    string
+		want    []shardConfig
+		wantErr bool
+	}{
+		{desc: "empty", file: ""},
+		{
+			desc: "shards",
+			file: "# name uri\n\nshard1 user@tcp(shard1:3306)/trillian\nshard2 user@tcp(shard2:3306)/trillian\n",
+			want: []shardConfig{
+				{name: "shard1", uri: "user@tcp(shard1:3306)/trillian"},
+				{name: "shard2", uri: "user@tcp(shard2:3306)/trillian"},
+			},
+		},
+		{desc: "missingURI", file: "shard1\n", wantErr: true},
+		{desc: "extraField", file: "shard1 uri extra\n", wantErr: true},
+		{desc: "sameName", file: "shard1 uri1\nshard1 uri2\n", wantErr: true},
+		{desc: "primary", file: PrimaryShardName + " uri\n", wantErr: true},
+	} {
+		got, err := parseShards(strings.NewReader(test.file))
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("%v: parseShards()=(_, %v), want err? %v", test.desc, err, test.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: parseShards()=%+v, want %+v", test.desc, got, test.want)
+		}
+	}
+}
+
+func TestShardedDBs(t *testing.T) {
+	dbs, err := newShardedDBs(DB, []DatabaseShard{{Name: "a", DB: DB}, {Name: "b", DB: DB}})
+	if err != nil {
+		t.Fatalf("newShardedDBs()=%v", err)
+	}
+	for _, test := range []struct {
+		name   string
+		want   int
+		wantOK bool
+	}{
+		{name: PrimaryShardName, want: 0, wantOK: true},
+		{name: "a", want: 1, wantOK: true},
+		{name: "b", want: 2, wantOK: true},
+		{name: "c"},
+	} {
+		if got, ok := dbs.byName(test.name); got != test.want || ok != test.wantOK {
+			t.Errorf("byName(%q)=(%d, %v), want (%d, %v)", test.name, got, ok, test.want, test.wantOK)
+		}
+	}
+
+	for _, test := range []struct {
+		desc   string
+		shards []DatabaseShard
+	}{
+		{desc: "noName", shards: []DatabaseShard{{DB: DB}}},
+		{desc: "sameName", shards: []DatabaseShard{{Name: "a", DB: DB}, {Name: "a", DB: DB}}},
+		{desc: "primary", shards: []DatabaseShard{{Name: PrimaryShardName, DB: DB}}},
+		{desc: "noDB", shards: []DatabaseShard{{Name: "a"}}},
+	} {
+		if _, err := newShardedDBs(DB, test.shards); err == nil {
+			t.Errorf("%v: newShardedDBs() succeeded, want error", test.desc)
+		}
+	}
+}
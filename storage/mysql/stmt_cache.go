@@ -0,0 +1,101 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"container/list"
+	"database/sql"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/monitoring/metric"
+)
+
+// DefaultStatementCacheSize is the number of prepared statements kept by storage
+// unless StorageOptions says otherwise. Queries with a variable number of arguments
+// are prepared once for each number used, so it's comfortably more than the number
+// of distinct queries.
+const DefaultStatementCacheSize = 2000
+
+var (
+	preparedCounter = metric.NewCounter("mysql_prepared_statements")
+	evictedCounter  = metric.NewCounter("mysql_evicted_statements")
+)
+
+// stmtCache keeps statements prepared on a database keyed by their SQL, so each query
+// shape is prepared once rather than in every transaction using it. Once it holds more
+// than size statements the least recently used one is closed, transactions already
+// using it are unaffected. The cached statements are released along with the
+// database's connections when it's closed.
+type stmtCache struct {
+	db   *sql.DB
+	size int
+
+	mu sync.Mutex
+	// lru holds the cached *sql.Stmts' queries, most recently used at the front.
+	lru   *list.List
+	stmts map[string]*list.Element
+}
+
+type cachedStmt struct {
+	query string
+	stmt  *sql.Stmt
+}
+
+// newStmtCache returns a cache of at most size statements prepared on db, or of all
+// of them if size isn't greater than 0.
+func newStmtCache(db *sql.DB, size int) *stmtCache {
+	return &stmtCache{
+		db:    db,
+		size:  size,
+		lru:   list.New(),
+		stmts: make(map[string]*list.Element),
+	}
+}
+
+// get returns query prepared on the database, preparing it if it isn't cached. The
+// statement may be closed by a later call, so it should only be used through Tx.Stmt.
+func (c *stmtCache) get(query string) (*sql.Stmt, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.stmts[query]; ok {
+		c.lru.MoveToFront(e)
+		return e.Value.(*cachedStmt).stmt, nil
+	}
+
+	stmt, err := c.db.Prepare(query)
+	if err != nil {
+		glog.Warningf("Failed to prepare statement: %s", err)
+		return nil, err
+	}
+	preparedCounter.Add(1)
+	c.stmts[query] = c.lru.PushFront(&cachedStmt{query: query, stmt: stmt})
+
+	for c.size > 0 && c.lru.Len() > c.size {
+		oldest := c.lru.Remove(c.lru.Back()).(*cachedStmt)
+		delete(c.stmts, oldest.query)
+		// Statements derived from it by Tx.Stmt keep working until their transaction ends.
+		oldest.stmt.Close()
+		evictedCounter.Add(1)
+	}
+	return stmt, nil
+}
+
+// len returns the number of cached statements.
+func (c *stmtCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
@@ -0,0 +1,61 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"testing"
+)
+
+func TestStmtCacheEviction(t *testing.T) {
+	c := newStmtCache(DB, 2)
+
+	get := func(query string) {
+		if _, err := c.get(query); err != nil {
+			t.Fatalf("get(%q)=(_, %v), want (_, nil)", query, err)
+		}
+	}
+	get("SELECT 1")
+	get("SELECT 2")
+	s1, err := c.get("SELECT 1")
+	if err != nil {
+		t.Fatalf("get(SELECT 1)=(_, %v), want (_, nil)", err)
+	}
+
+	// A transaction using a statement isn't affected by its eviction.
+	tx, err := DB.Begin()
+	if err != nil {
+		t.Fatalf("Begin()=(_, %v)", err)
+	}
+	defer tx.Rollback()
+	txStmt := tx.Stmt(s1)
+	get("SELECT 3")
+	get("SELECT 4")
+	if got := c.len(); got != 2 {
+		t.Errorf("len()=%d, want 2", got)
+	}
+	var n int
+	if err := txStmt.QueryRow().Scan(&n); err != nil || n != 1 {
+		t.Errorf("QueryRow() of evicted statement scanned (%d, %v), want (1, nil)", n, err)
+	}
+
+	// The most recently used statement is kept.
+	s4, err := c.get("SELECT 4")
+	if err != nil {
+		t.Fatalf("get(SELECT 4)=(_, %v), want (_, nil)", err)
+	}
+	if again, _ := c.get("SELECT 4"); again != s4 {
+		t.Error("get(SELECT 4) prepared the statement again, want cached")
+	}
+}
@@ -9,12 +9,23 @@
 -- https://dev.mysql.com/doc/refman/5.7/en/sql-mode.html#sql-mode-strict
 SET GLOBAL sql_mode = 'STRICT_ALL_TABLES';
 
+-- The migrations (see the migrations directory) which have been applied. A new
+-- database created from this file has the schema of the latest one, so any change
+-- here needs a matching migration and the version below bumping.
+CREATE TABLE IF NOT EXISTS SchemaVersion(
+  Version                 INTEGER NOT NULL,
+  AppliedTimestampNanos   BIGINT NOT NULL,
+  PRIMARY KEY(Version)
+);
+
+INSERT IGNORE INTO SchemaVersion(Version, AppliedTimestampNanos) VALUES(22, 0);
+
 -- Tree parameters should not be changed after creation. Doing so can
 -- render the data in the tree unusable or inconsistent.
 CREATE TABLE IF NOT EXISTS Trees(
   TreeId                BIGINT NOT NULL,
   TreeState             ENUM('ACTIVE', 'FROZEN', 'SOFT_DELETED', 'HARD_DELETED') NOT NULL,
-  TreeType              ENUM('LOG', 'MAP') NOT NULL,
+  TreeType              ENUM('LOG', 'MAP', 'PREORDERED_LOG') NOT NULL,
   HashStrategy          ENUM('RFC_6962') NOT NULL,
   HashAlgorithm         ENUM('SHA256') NOT NULL,
   SignatureAlgorithm    ENUM('ECDSA', 'RSA') NOT NULL,
@@ -23,21 +34,62 @@ CREATE TABLE IF NOT EXISTS Trees(
   Description           VARCHAR(200),
   CreateTimeMillis      BIGINT NOT NULL,
   UpdateTimeMillis      BIGINT NOT NULL,
+  -- Empty for verification-only trees, which only have a PublicKey.
   PrivateKey            BLOB NOT NULL,
-  PRIMARY KEY(TreeId)
+  PublicKey             BLOB,
+  -- Logs which are time-based shards of a larger log share a ShardSetId, which is
+  -- zero for unsharded trees, and have non-overlapping validity windows.
+  ShardSetId            BIGINT NOT NULL DEFAULT 0,
+  ShardStartMillis      BIGINT NOT NULL DEFAULT 0,
+  ShardEndMillis        BIGINT NOT NULL DEFAULT 0,
+  -- How the identity hashes of a log's leaves are computed, LeafIdentityHashKey
+  -- keying HMAC_SHA256_LEAF_VALUE. NULL for the other strategies.
+  LeafIdentityHashStrategy ENUM('CLIENT_SUPPLIED', 'SHA256_LEAF_VALUE', 'SHA256_LEAF_VALUE_AND_EXTRA_DATA', 'HMAC_SHA256_LEAF_VALUE') NOT NULL DEFAULT 'CLIENT_SUPPLIED',
+  LeafIdentityHashKey   VARBINARY(255),
+  -- SPIFFE IDs of the clients allowed to add leaves to a log, one per line. NULL
+  -- if any client may.
+  AllowedWriters        TEXT,
+  PRIMARY KEY(TreeId),
+  INDEX ShardSetIdx(ShardSetId, ShardStartMillis)
 );
 
 -- This table contains tree parameters that can be changed at runtime such as for
 -- administrative purposes.
+-- SequencingBatchSize, SequencingIntervalSeconds and SequencingGuardWindowSeconds
+-- are honored by the log signer, zero meaning it uses its own defaults.
+-- LeafCompression applies to the LeafData rows written after it's set.
+-- LeafRetentionSeconds is how long leaf data is kept for after it's integrated,
+-- forever if zero, see PruneExpiredLeafData.
+-- SequencingPriority weighs the log when the signer shares passes by priority.
+-- MaxMergeDelaySeconds is the longest the log commits to taking to integrate a
+-- queued leaf, none if zero.
 CREATE TABLE IF NOT EXISTS TreeControl(
-  TreeId                  BIGINT NOT NULL,
-  SigningEnabled          BOOLEAN NOT NULL,
-  SequencingEnabled       BOOLEAN NOT NULL,
-  SequenceIntervalSeconds INTEGER NOT NULL,
+  TreeId                       BIGINT NOT NULL,
+  SigningEnabled               BOOLEAN NOT NULL,
+  SequencingEnabled            BOOLEAN NOT NULL,
+  SequenceIntervalSeconds      INTEGER NOT NULL,
+  SequencingBatchSize          INTEGER NOT NULL DEFAULT 0,
+  SequencingIntervalSeconds    INTEGER NOT NULL DEFAULT 0,
+  SequencingGuardWindowSeconds INTEGER NOT NULL DEFAULT 0,
+  LeafCompression              ENUM('UNCOMPRESSED', 'SNAPPY', 'ZSTD') NOT NULL DEFAULT 'UNCOMPRESSED',
+  LeafRetentionSeconds         INTEGER NOT NULL DEFAULT 0,
+  FinalizeTimeMillis           BIGINT NOT NULL DEFAULT 0,
+  FinalizedTreeSize            BIGINT NOT NULL DEFAULT 0,
+  SequencingPriority           INTEGER NOT NULL DEFAULT 0,
+  MaxMergeDelaySeconds         INTEGER NOT NULL DEFAULT 0,
   PRIMARY KEY(TreeId),
   FOREIGN KEY(TreeId) REFERENCES Trees(TreeId)
 );
 
+-- The key a tree's leaf data is encrypted with, wrapped by a key management service.
+-- It's created when the first leaf is encrypted.
+CREATE TABLE IF NOT EXISTS TreeDataKey(
+  TreeId                       BIGINT NOT NULL,
+  WrappedKey                   VARBINARY(1024) NOT NULL,
+  PRIMARY KEY(TreeId),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
 CREATE TABLE IF NOT EXISTS Subtree(
   TreeId               BIGINT NOT NULL,
   SubtreeId            VARBINARY(255) NOT NULL,
@@ -58,14 +110,73 @@ CREATE TABLE IF NOT EXISTS TreeHead(
   TreeRevision         BIGINT,
   PRIMARY KEY(TreeId, TreeHeadTimestamp),
   UNIQUE INDEX TreeRevisionIdx(TreeId, TreeRevision),
+  INDEX TreeSizeIdx(TreeId, TreeSize),
   FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
 );
 
 
+-- A single row holding the time it was last written on the primary, by
+-- WriteHeartbeats. Read replicas compare it to the current time to measure
+-- how far behind the primary they are.
+CREATE TABLE IF NOT EXISTS ReplicationHeartbeat(
+  Id                   INT NOT NULL,
+  TimestampNanos       BIGINT NOT NULL,
+  PRIMARY KEY(Id)
+);
+
+-- Records which database shard each tree is kept in, for servers spreading trees
+-- across several databases. Only the primary shard's table is used, trees without a
+-- row are kept in the primary shard.
+CREATE TABLE IF NOT EXISTS TreePlacement(
+  TreeId               BIGINT NOT NULL,
+  DatabaseName         VARCHAR(255) NOT NULL,
+  PRIMARY KEY(TreeId)
+);
+
+-- The bytes of leaf data and Merkle tree nodes stored for each tree. Writers add
+-- to one of several rows of the tree, picked at random, so they rarely wait for
+-- each other, and the tree's usage is the sum of its rows. ReconcileTreeUsage
+-- periodically recounts it, correcting for data deleted or pruned since.
+CREATE TABLE IF NOT EXISTS TreeUsage(
+  TreeId               BIGINT NOT NULL,
+  Shard                INTEGER NOT NULL,
+  LeafBytes            BIGINT NOT NULL DEFAULT 0,
+  NodeBytes            BIGINT NOT NULL DEFAULT 0,
+  PRIMARY KEY(TreeId, Shard),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
 -- ---------------------------------------------
 -- Log specific stuff here
 -- ---------------------------------------------
 
+-- Countersignatures made by witnesses over a log's STHs. Each witness signs an
+-- STH at most once, a later signature replaces an earlier one.
+CREATE TABLE IF NOT EXISTS WitnessSignature(
+  TreeId               BIGINT NOT NULL,
+  TreeRevision         BIGINT NOT NULL,
+  WitnessName          VARCHAR(255) NOT NULL,
+  -- A serialized DigitallySigned over the same data as the STH's RootSignature.
+  Signature            VARBINARY(1024) NOT NULL,
+  PRIMARY KEY(TreeId, TreeRevision, WitnessName),
+  FOREIGN KEY(TreeId, TreeRevision) REFERENCES TreeHead(TreeId, TreeRevision) ON DELETE CASCADE
+);
+
+-- Roots of a log observed by clients, e.g. through gossip, and whether they were
+-- consistent with the log's own history. Inconsistent roots are evidence of a
+-- split view and must be kept.
+CREATE TABLE IF NOT EXISTS ObservedTreeHead(
+  TreeId               BIGINT NOT NULL,
+  TreeHeadTimestamp    BIGINT NOT NULL,
+  TreeSize             BIGINT NOT NULL,
+  RootHash             VARBINARY(255) NOT NULL,
+  RootSignature        VARBINARY(1024) NOT NULL,
+  Consistent           BOOLEAN NOT NULL,
+  ObservedTimestampNanos BIGINT NOT NULL,
+  PRIMARY KEY(TreeId, TreeHeadTimestamp, TreeSize, RootHash),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
 -- Creating index at same time as table allows some storage engines to better
 -- optimize physical storage layout. Most engines allow multiple nulls in a
 -- unique index but some may not.
@@ -84,6 +195,16 @@ CREATE TABLE IF NOT EXISTS LeafData(
   -- This is extra data that the application can associate with the leaf should it wish to.
   -- This data is not included in signing and hashing.
   ExtraData            BLOB,
+  -- The compression LeafValue and ExtraData are stored with.
+  Compression          ENUM('UNCOMPRESSED', 'SNAPPY', 'ZSTD') NOT NULL DEFAULT 'UNCOMPRESSED',
+  -- If set, LeafValue is empty and the (compressed) value is kept in the blob store
+  -- under this key instead.
+  LeafValueLocator     VARCHAR(255),
+  -- Whether LeafValue and ExtraData are sealed with the tree's TreeDataKey.
+  Encrypted            BOOLEAN NOT NULL DEFAULT FALSE,
+  -- Whether LeafValue and ExtraData have been pruned because the leaf is older than
+  -- the tree's LeafRetentionSeconds.
+  Expired              BOOLEAN NOT NULL DEFAULT FALSE,
   PRIMARY KEY(TreeId, LeafIdentityHash),
   FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
 );
@@ -123,7 +244,18 @@ CREATE TABLE IF NOT EXISTS Unsequenced(
   -- we can try to stomp dupe submissions.
   MessageId            BINARY(32) NOT NULL,
   QueueTimestampNanos  BIGINT NOT NULL,
-  PRIMARY KEY (TreeId, LeafIdentityHash, MessageId)
+  PRIMARY KEY (TreeId, LeafIdentityHash, MessageId),
+  INDEX QueueTimestampIdx(TreeId, QueueTimestampNanos)
+);
+
+-- How far PruneExpiredLeafData has got into a log: the data of its leaves below
+-- ExpiredTreeSize has been pruned, unless another entry of the leaf was still
+-- within the retention window.
+CREATE TABLE IF NOT EXISTS LeafExpiry(
+  TreeId               BIGINT NOT NULL,
+  ExpiredTreeSize      BIGINT NOT NULL,
+  PRIMARY KEY(TreeId),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
 );
 
 
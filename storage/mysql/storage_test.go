@@ -245,6 +245,18 @@ func createLogForTests(db *sql.DB) int64 {
 	return tree.TreeId
 }
 
+// createPreorderedLogForTests creates a PREORDERED_LOG-type tree for tests. Returns the treeID
+// of the new tree.
+func createPreorderedLogForTests(db *sql.DB) int64 {
+	tree := *storageto.LogTree
+	tree.TreeType = trillian.TreeType_PREORDERED_LOG
+	newTree, err := createTree(db, &tree)
+	if err != nil {
+		panic(fmt.Sprintf("Error creating pre-ordered log: %v", err))
+	}
+	return newTree.TreeId
+}
+
 // createTree creates the specified tree using AdminStorage.
 func createTree(db *sql.DB, tree *trillian.Tree) (*trillian.Tree, error) {
 	s := NewAdminStorage(db)
@@ -0,0 +1,183 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"expvar"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/storage"
+)
+
+// usageShards is the number of TreeUsage rows each tree's usage is spread over, so
+// transactions writing to the same tree rarely wait for each other's increments.
+const usageShards = 16
+
+const (
+	incrementTreeUsageSQL = `INSERT INTO TreeUsage(TreeId,Shard,LeafBytes,NodeBytes) VALUES(?,?,?,?)
+			ON DUPLICATE KEY UPDATE LeafBytes=LeafBytes+VALUES(LeafBytes),NodeBytes=NodeBytes+VALUES(NodeBytes)`
+	selectTreeUsageSQL  = "SELECT COALESCE(SUM(LeafBytes),0),COALESCE(SUM(NodeBytes),0) FROM TreeUsage WHERE TreeId=?"
+	selectAllTreeIDsSQL = "SELECT TreeId FROM Trees"
+	// Values offloaded to the blob store aren't counted, only the bytes in the database.
+	countLeafBytesSQL  = "SELECT COALESCE(SUM(LENGTH(LeafValue)+COALESCE(LENGTH(ExtraData),0)),0) FROM LeafData WHERE TreeId=?"
+	countNodeBytesSQL  = "SELECT COALESCE(SUM(LENGTH(Nodes)),0) FROM Subtree WHERE TreeId=?"
+	deleteTreeUsageSQL = "DELETE FROM TreeUsage WHERE TreeId=?"
+	insertTreeUsageSQL = "INSERT INTO TreeUsage(TreeId,Shard,LeafBytes,NodeBytes) VALUES(?,0,?,?)"
+)
+
+// Per tree storage usage metrics, each map is keyed by tree ID.
+var (
+	// leafBytesWritten and nodeBytesWritten hold the bytes of leaf data and Merkle tree
+	// nodes written by the transactions committed since the server started.
+	leafBytesWritten = expvar.NewMap("mysql-tree-leaf-bytes-written")
+	nodeBytesWritten = expvar.NewMap("mysql-tree-node-bytes-written")
+	// leafBytesStored and nodeBytesStored hold the bytes stored for each tree as of its
+	// latest reconciliation.
+	leafBytesStored = expvar.NewMap("mysql-tree-leaf-bytes")
+	nodeBytesStored = expvar.NewMap("mysql-tree-node-bytes")
+)
+
+// addUsage adds the bytes of leaf data and Merkle tree nodes in usage to the tree's
+// TreeUsage, in a row picked at random. Leaves whose data was already stored, e.g.
+// duplicates in logs which allow them, may be counted again and deleted or pruned
+// data isn't subtracted, which ReconcileTreeUsage corrects.
+func (t *treeTX) addUsage(usage storage.StorageUsage) error {
+	if usage == (storage.StorageUsage{}) {
+		return nil
+	}
+	_, err := t.tx.ExecContext(t.ctx, incrementTreeUsageSQL, t.treeID, rand.Intn(usageShards), usage.LeafBytes, usage.NodeBytes)
+	return err
+}
+
+// recordUsageWritten updates the metrics for the usage of a committed transaction.
+func recordUsageWritten(treeID int64, usage storage.StorageUsage) {
+	key := strconv.FormatInt(treeID, 10)
+	if usage.LeafBytes != 0 {
+		leafBytesWritten.Add(key, usage.LeafBytes)
+	}
+	if usage.NodeBytes != 0 {
+		nodeBytesWritten.Add(key, usage.NodeBytes)
+	}
+}
+
+// recordUsageStored updates the metrics for the reconciled usage of treeID.
+func recordUsageStored(treeID int64, usage storage.StorageUsage) {
+	key := strconv.FormatInt(treeID, 10)
+	leaf, node := new(expvar.Int), new(expvar.Int)
+	leaf.Set(usage.LeafBytes)
+	node.Set(usage.NodeBytes)
+	leafBytesStored.Set(key, leaf)
+	nodeBytesStored.Set(key, node)
+}
+
+// leafRowBytes returns the bytes of leaf data held by a LeafData row, see leafDataRow.
+func leafRowBytes(row []interface{}) int64 {
+	value, _ := row[2].([]byte)
+	extraData, _ := row[3].([]byte)
+	return int64(len(value) + len(extraData))
+}
+
+func (t *logTreeTX) GetStorageUsage() (storage.StorageUsage, error) {
+	var usage storage.StorageUsage
+	if err := t.tx.QueryRowContext(t.ctx, selectTreeUsageSQL, t.treeID).Scan(&usage.LeafBytes, &usage.NodeBytes); err != nil {
+		glog.Warningf("Error getting storage usage: %s", err)
+		return storage.StorageUsage{}, err
+	}
+	return usage, nil
+}
+
+// ReconcileTreeUsage recounts the bytes of leaf data and Merkle tree nodes stored for
+// treeID and replaces its accounted usage with them. The bytes of leaf values offloaded
+// to a blob store aren't included.
+func ReconcileTreeUsage(ctx context.Context, db *sql.DB, treeID int64) (storage.StorageUsage, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return storage.StorageUsage{}, err
+	}
+	defer tx.Rollback()
+
+	// The rows are deleted first, which locks them, so increments made while the
+	// data is being counted wait and are added to the new count rather than lost.
+	if _, err := tx.ExecContext(ctx, deleteTreeUsageSQL, treeID); err != nil {
+		return storage.StorageUsage{}, err
+	}
+	var usage storage.StorageUsage
+	if err := tx.QueryRowContext(ctx, countLeafBytesSQL, treeID).Scan(&usage.LeafBytes); err != nil {
+		return storage.StorageUsage{}, err
+	}
+	if err := tx.QueryRowContext(ctx, countNodeBytesSQL, treeID).Scan(&usage.NodeBytes); err != nil {
+		return storage.StorageUsage{}, err
+	}
+	if _, err := tx.ExecContext(ctx, insertTreeUsageSQL, treeID, usage.LeafBytes, usage.NodeBytes); err != nil {
+		return storage.StorageUsage{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return storage.StorageUsage{}, err
+	}
+	recordUsageStored(treeID, usage)
+	return usage, nil
+}
+
+// ReconcileAllTreeUsage runs ReconcileTreeUsage for every tree in db. Trees whose
+// usage can't be recounted are skipped.
+func ReconcileAllTreeUsage(ctx context.Context, db *sql.DB) error {
+	rows, err := db.QueryContext(ctx, selectAllTreeIDsSQL)
+	if err != nil {
+		return err
+	}
+	var treeIDs []int64
+	for rows.Next() {
+		var treeID int64
+		if err := rows.Scan(&treeID); err != nil {
+			rows.Close()
+			return err
+		}
+		treeIDs = append(treeIDs, treeID)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return err
+	}
+	rows.Close()
+
+	for _, treeID := range treeIDs {
+		if _, err := ReconcileTreeUsage(ctx, db, treeID); err != nil {
+			glog.Warningf("%v: failed to reconcile storage usage: %v", treeID, err)
+		}
+	}
+	return nil
+}
+
+// ReconcileStorageUsage runs ReconcileAllTreeUsage against db every interval until ctx
+// is done.
+func ReconcileStorageUsage(ctx context.Context, db *sql.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := ReconcileAllTreeUsage(ctx, db); err != nil {
+			glog.Warningf("Failed to reconcile storage usage: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/trillian/storage"
+)
+
+func TestStorageUsage(t *testing.T) {
+	cleanTestDB(DB)
+	logID := createLogForTests(DB)
+	s := NewLogStorage(DB)
+	ctx := context.Background()
+
+	var want storage.StorageUsage
+	for i := int64(0); i < 3; i++ {
+		leaves := createTestLeaves(leavesToInsert, i*leavesToInsert)
+		for _, leaf := range leaves {
+			want.LeafBytes += int64(len(leaf.LeafValue) + len(leaf.ExtraData))
+		}
+		tx := beginLogTx(s, logID, t)
+		if _, err := tx.QueueLeaves(leaves, fakeQueueTime); err != nil {
+			t.Fatalf("Failed to queue leaves: %v", err)
+		}
+		commit(tx, t)
+	}
+	// A duplicate isn't counted again.
+	tx := beginLogTx(s, logID, t)
+	if _, err := tx.QueueLeaves(createTestLeaves(1, 0), fakeQueueTime); err != nil {
+		t.Fatalf("Failed to queue duplicate leaf: %v", err)
+	}
+	commit(tx, t)
+
+	checkUsage := func(desc string) {
+		tx, err := s.SnapshotForTree(ctx, logID)
+		if err != nil {
+			t.Fatalf("SnapshotForTree()=%v", err)
+		}
+		defer tx.Close()
+		got, err := tx.GetStorageUsage()
+		if err != nil {
+			t.Fatalf("%v: GetStorageUsage()=(_, %v), want (_, nil)", desc, err)
+		}
+		commit(tx, t)
+		if got != want {
+			t.Errorf("%v: GetStorageUsage()=%+v, want %+v", desc, got, want)
+		}
+	}
+	checkUsage("accounted")
+
+	// Reconciliation recounts the usage from the data, e.g. after it was pruned.
+	if _, err := DB.Exec("UPDATE LeafData SET LeafValue='',ExtraData=NULL WHERE TreeId=? LIMIT 1", logID); err != nil {
+		t.Fatalf("Failed to prune leaf: %v", err)
+	}
+	if err := DB.QueryRow("SELECT SUM(LENGTH(LeafValue)+COALESCE(LENGTH(ExtraData),0)) FROM LeafData WHERE TreeId=?", logID).Scan(&want.LeafBytes); err != nil {
+		t.Fatalf("Failed to count leaf bytes: %v", err)
+	}
+	got, err := ReconcileTreeUsage(ctx, DB, logID)
+	if err != nil || got != want {
+		t.Fatalf("ReconcileTreeUsage()=(%+v, %v), want (%+v, nil)", got, err, want)
+	}
+	checkUsage("reconciled")
+}
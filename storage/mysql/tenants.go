@@ -0,0 +1,263 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/trillian/errors"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/util"
+)
+
+// maxTreeIDAttempts is how many random IDs are tried when creating a tree which
+// belongs to no tenant, before giving up on finding one outside the tenants' ranges.
+const maxTreeIDAttempts = 100
+
+// Tenant is a range of tree IDs whose trees, and all of their data, are kept in a
+// database of their own.
+type Tenant struct {
+	// Name identifies the tenant, trees are created for it by RPCs naming it.
+	Name string
+	// FirstTreeID and LastTreeID are the inclusive bounds of the tenant's tree IDs.
+	FirstTreeID, LastTreeID int64
+	// DB holds the tenant's trees, it must have the same schema as any other
+	// Trillian database.
+	DB *sql.DB
+}
+
+// validateTenants checks that tenants have distinct names and disjoint, valid ranges.
+func validateTenants(tenants []Tenant) error {
+	sorted := append([]Tenant(nil), tenants...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].FirstTreeID < sorted[j].FirstTreeID })
+	names := make(map[string]bool)
+	for i, t := range sorted {
+		switch {
+		case t.Name == "":
+			return fmt.Errorf("tenant with tree IDs [%d, %d] has no name", t.FirstTreeID, t.LastTreeID)
+		case names[t.Name]:
+			return fmt.Errorf("tenant %q is configured more than once", t.Name)
+		case t.FirstTreeID <= 0 || t.LastTreeID < t.FirstTreeID:
+			return fmt.Errorf("tenant %q has invalid tree IDs [%d, %d]", t.Name, t.FirstTreeID, t.LastTreeID)
+		case i > 0 && t.FirstTreeID <= sorted[i-1].LastTreeID:
+			return fmt.Errorf("tenants %q and %q have overlapping tree IDs", sorted[i-1].Name, t.Name)
+		}
+		names[t.Name] = true
+	}
+	return nil
+}
+
+// tenantConfig is a tenant as configured, before its database is opened.
+type tenantConfig struct {
+	Tenant
+	uri string
+}
+
+// parseTenants reads tenants from r, one per line as their name, first and last tree
+// IDs and MySQL URI separated by spaces. Blank lines and lines starting with # are
+// ignored.
+func parseTenants(r io.Reader) ([]tenantConfig, error) {
+	var configs []tenantConfig
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("line %d: got %d fields, want name, first and last tree ID and MySQL URI", line, len(fields))
+		}
+		first, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid first tree ID: %v", line, err)
+		}
+		last, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid last tree ID: %v", line, err)
+		}
+		configs = append(configs, tenantConfig{
+			Tenant: Tenant{Name: fields[0], FirstTreeID: first, LastTreeID: last},
+			uri:    fields[3],
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
+
+// OpenTenants opens the databases of the tenants listed in the file at path, applying
+// opts to their URIs. Each line of the file holds a tenant's name, first and last tree
+// IDs and MySQL URI separated by spaces, e.g.
+// "example 1 1000000 user:pass@tcp(example-db:3306)/trillian". Blank lines and lines
+// starting with # are ignored.
+func OpenTenants(path string, opts DBOptions) ([]Tenant, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	configs, err := parseTenants(f)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	tenants := make([]Tenant, 0, len(configs))
+	for _, c := range configs {
+		tenants = append(tenants, c.Tenant)
+	}
+	if err := validateTenants(tenants); err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+
+	for i, c := range configs {
+		db, err := OpenDBWithOptions(c.uri, opts)
+		if err != nil {
+			for _, t := range tenants[:i] {
+				t.DB.Close()
+			}
+			// Don't include the URI in errors, it could contain credentials.
+			return nil, fmt.Errorf("failed to open database of tenant %q: %v", c.Name, err)
+		}
+		tenants[i].DB = db
+	}
+	return tenants, nil
+}
+
+// NewTenantAdminStorage returns an AdminStorage keeping the trees of each of tenants in
+// the tenant's database, and any others in db. Trees are created in the database of the
+// tenant named by the context they're created with, see util.NewTenantContext, and are
+// given an ID in its range.
+func NewTenantAdminStorage(db *sql.DB, tenants []Tenant) (storage.AdminStorage, error) {
+	dbs, err := newTenantDBs(db, tenants)
+	if err != nil {
+		return nil, err
+	}
+	return newRoutedAdminStorage(dbs), nil
+}
+
+// NewTenantLogStorage returns a LogStorage keeping the data of the logs of each of
+// tenants in the tenant's database, and that of any others in db, configured by opts.
+// It must be used with storage from NewTenantAdminStorage for the same tenants.
+func NewTenantLogStorage(db *sql.DB, tenants []Tenant, opts StorageOptions) (storage.LogStorage, error) {
+	dbs, err := newTenantDBs(db, tenants)
+	if err != nil {
+		return nil, err
+	}
+	return newRoutedLogStorage(dbs, opts), nil
+}
+
+// tenantDBs routes trees to the database of the tenant their ID belongs to, or to a
+// default database if it belongs to none. The default database is numbered 0, tenant
+// i's database i+1.
+type tenantDBs struct {
+	db      *sql.DB
+	tenants []Tenant
+}
+
+func newTenantDBs(db *sql.DB, tenants []Tenant) (*tenantDBs, error) {
+	if err := validateTenants(tenants); err != nil {
+		return nil, err
+	}
+	for _, t := range tenants {
+		if t.DB == nil {
+			return nil, fmt.Errorf("tenant %q has no database", t.Name)
+		}
+	}
+	return &tenantDBs{db: db, tenants: tenants}, nil
+}
+
+func (d *tenantDBs) dbs() []*sql.DB {
+	dbs := []*sql.DB{d.db}
+	for _, t := range d.tenants {
+		dbs = append(dbs, t.DB)
+	}
+	return dbs
+}
+
+func (d *tenantDBs) name(i int) string {
+	if i == 0 {
+		return "default"
+	}
+	return fmt.Sprintf("tenant %v", d.tenants[i-1].Name)
+}
+
+func (d *tenantDBs) forTree(ctx context.Context, treeID int64) (int, error) {
+	return d.index(treeID), nil
+}
+
+// index returns the number of the database treeID is kept in.
+func (d *tenantDBs) index(treeID int64) int {
+	for i, t := range d.tenants {
+		if treeID >= t.FirstTreeID && treeID <= t.LastTreeID {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// forNewTree returns the database of the tenant named by ctx, the default database if
+// it names none.
+func (d *tenantDBs) forNewTree(ctx context.Context, tx *routedAdminTX) (int, error) {
+	name := util.Tenant(ctx)
+	if name == "" {
+		return 0, nil
+	}
+	for i, t := range d.tenants {
+		if t.Name == name {
+			return i + 1, nil
+		}
+	}
+	return 0, errors.Errorf(errors.InvalidArgument, "unknown tenant %q", name)
+}
+
+// placed does nothing, where trees are kept follows from their IDs.
+func (d *tenantDBs) placed(ctx context.Context, tx *routedAdminTX, treeID int64, i int) error {
+	return nil
+}
+
+func (d *tenantDBs) treeIDGenerator(i int) func() (int64, error) {
+	if i > 0 {
+		t := d.tenants[i-1]
+		return func() (int64, error) {
+			n, err := rand.Int(rand.Reader, big.NewInt(t.LastTreeID-t.FirstTreeID+1))
+			if err != nil {
+				return 0, err
+			}
+			return t.FirstTreeID + n.Int64(), nil
+		}
+	}
+	return func() (int64, error) {
+		for i := 0; i < maxTreeIDAttempts; i++ {
+			id, err := storage.NewTreeID()
+			if err != nil {
+				return 0, err
+			}
+			if d.index(id) == 0 {
+				return id, nil
+			}
+		}
+		return 0, fmt.Errorf("no tree ID outside the tenants' ranges found in %d attempts", maxTreeIDAttempts)
+	}
+}
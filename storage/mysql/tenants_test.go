@@ -0,0 +1,166 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/trillian/storage"
+	storageto "github.com/google/trillian/storage/testonly"
+	"github.com/google/trillian/util"
+)
+
+func TestParseTenants(t *testing.T) {
+	for _, test := range []struct {
+		desc    string
+		file    string
+		want    []tenantConfig
+		wantErr bool
+	}{
+		{desc: "empty", file: ""},
+		{
+			desc: "tenants",
+			file: "# name first last uri\n\nacme 1 100 user@tcp(acme:3306)/trillian\nother 101 200 user@tcp(other:3306)/trillian\n",
+			want: []tenantConfig{
+				{Tenant: Tenant{Name: "acme", FirstTreeID: 1, LastTreeID: 100}, uri: "user@tcp(acme:3306)/trillian"},
+				{Tenant: Tenant{Name: "other", FirstTreeID: 101, LastTreeID: 200}, uri: "user@tcp(other:3306)/trillian"},
+			},
+		},
+		{desc: "missingURI", file: "acme 1 100\n", wantErr: true},
+		{desc: "badFirst", file: "acme one 100 uri\n", wantErr: true},
+		{desc: "badLast", file: "acme 1 100x uri\n", wantErr: true},
+	} {
+		got, err := parseTenants(strings.NewReader(test.file))
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("%v: parseTenants()=(_, %v), want err? %v", test.desc, err, test.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: parseTenants()=%+v, want %+v", test.desc, got, test.want)
+		}
+	}
+}
+
+func TestValidateTenants(t *testing.T) {
+	for _, test := range []struct {
+		desc    string
+		tenants []Tenant
+		wantErr bool
+	}{
+		{desc: "none"},
+		{desc: "disjoint", tenants: []Tenant{{Name: "b", FirstTreeID: 11, LastTreeID: 20}, {Name: "a", FirstTreeID: 1, LastTreeID: 10}}},
+		{desc: "single", tenants: []Tenant{{Name: "a", FirstTreeID: 5, LastTreeID: 5}}},
+		{desc: "noName", tenants: []Tenant{{FirstTreeID: 1, LastTreeID: 10}}, wantErr: true},
+		{desc: "sameName", tenants: []Tenant{{Name: "a", FirstTreeID: 1, LastTreeID: 10}, {Name: "a", FirstTreeID: 11, LastTreeID: 20}}, wantErr: true},
+		{desc: "zero", tenants: []Tenant{{Name: "a", FirstTreeID: 0, LastTreeID: 10}}, wantErr: true},
+		{desc: "reversed", tenants: []Tenant{{Name: "a", FirstTreeID: 10, LastTreeID: 1}}, wantErr: true},
+		{desc: "overlapping", tenants: []Tenant{{Name: "b", FirstTreeID: 10, LastTreeID: 20}, {Name: "a", FirstTreeID: 1, LastTreeID: 10}}, wantErr: true},
+	} {
+		err := validateTenants(test.tenants)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("%v: validateTenants()=%v, want err? %v", test.desc, err, test.wantErr)
+		}
+	}
+}
+
+func TestTenantDBs(t *testing.T) {
+	dbs, err := newTenantDBs(DB, []Tenant{
+		{Name: "a", FirstTreeID: 1, LastTreeID: 10, DB: DB},
+		{Name: "b", FirstTreeID: 100, LastTreeID: 100, DB: DB},
+	})
+	if err != nil {
+		t.Fatalf("newTenantDBs()=%v", err)
+	}
+	ctx := context.Background()
+
+	for _, test := range []struct {
+		treeID int64
+		want   int
+	}{
+		{treeID: 1, want: 1},
+		{treeID: 10, want: 1},
+		{treeID: 11, want: 0},
+		{treeID: 100, want: 2},
+		{treeID: 101, want: 0},
+	} {
+		if got, err := dbs.forTree(ctx, test.treeID); err != nil || got != test.want {
+			t.Errorf("forTree(%d)=(%d, %v), want (%d, nil)", test.treeID, got, err, test.want)
+		}
+	}
+	for _, test := range []struct {
+		tenant  string
+		want    int
+		wantErr bool
+	}{
+		{tenant: "", want: 0},
+		{tenant: "a", want: 1},
+		{tenant: "b", want: 2},
+		{tenant: "c", wantErr: true},
+	} {
+		got, err := dbs.forNewTree(util.NewTenantContext(ctx, test.tenant), nil)
+		if gotErr := err != nil; gotErr != test.wantErr || got != test.want {
+			t.Errorf("forNewTree(%q)=(%d, %v), want (%d, err? %v)", test.tenant, got, err, test.want, test.wantErr)
+		}
+	}
+
+	// Generated tree IDs are kept in the database they're generated for.
+	for i := range dbs.dbs() {
+		newTreeID := dbs.treeIDGenerator(i)
+		for j := 0; j < 100; j++ {
+			id, err := newTreeID()
+			if err != nil {
+				t.Fatalf("%v: newTreeID()=%v", dbs.name(i), err)
+			}
+			if got := dbs.index(id); got != i {
+				t.Errorf("%v: newTreeID()=%d, which is kept in database %d", dbs.name(i), id, got)
+			}
+		}
+	}
+
+	if _, err := newTenantDBs(DB, []Tenant{{Name: "a", FirstTreeID: 1, LastTreeID: 10}}); err == nil {
+		t.Error("newTenantDBs() without a database succeeded, want error")
+	}
+}
+
+func TestTenantAdminTXAtomic(t *testing.T) {
+	s, err := NewTenantAdminStorage(DB, []Tenant{{Name: "a", FirstTreeID: 1, LastTreeID: 1000, DB: DB}})
+	if err != nil {
+		t.Fatalf("NewTenantAdminStorage()=%v", err)
+	}
+	ctx := context.Background()
+	tx, err := s.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin()=%v", err)
+	}
+	defer tx.Close()
+	checker := tx.(storage.AtomicityChecker)
+
+	// Writes are atomic until they're to a second database.
+	if _, err := tx.CreateTree(util.NewTenantContext(ctx, "a"), storageto.LogTree); err != nil {
+		t.Fatalf("CreateTree()=%v", err)
+	}
+	if !checker.Atomic() {
+		t.Error("Atomic()=false after writing to one database")
+	}
+	if _, err := tx.CreateTree(ctx, storageto.LogTree); err != nil {
+		t.Fatalf("CreateTree()=%v", err)
+	}
+	if checker.Atomic() {
+		t.Error("Atomic()=true after writing to two databases")
+	}
+}
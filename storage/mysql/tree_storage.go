@@ -19,10 +19,12 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
-	"sync"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian/errors"
+	"github.com/google/trillian/monitoring/metric"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/storage/cache"
 	"github.com/google/trillian/storage/storagepb"
@@ -30,12 +32,13 @@ import (
 
 // These statements are fixed
 const (
-	insertSubtreeMultiSQL = `INSERT INTO Subtree(TreeId, SubtreeId, Nodes, SubtreeRevision) ` + placeholderSQL
+	insertSubtreeMultiSQL = `INSERT INTO Subtree(TreeId, SubtreeId, Nodes, SubtreeRevision) VALUES ` + placeholderSQL
+	subtreeRowSQL         = "(?, ?, ?, ?)"
 	insertTreeHeadSQL     = `INSERT INTO TreeHead(TreeId,TreeHeadTimestamp,TreeSize,RootHash,TreeRevision,RootSignature)
 		 VALUES(?,?,?,?,?,?)`
 	selectTreeRevisionAtSizeOrLargerSQL = "SELECT TreeRevision,TreeSize FROM TreeHead WHERE TreeId=? AND TreeSize>=? ORDER BY TreeRevision LIMIT 1"
-	selectActiveLogsSQL                 = "SELECT TreeId from Trees where TreeType='LOG'"
-	selectActiveLogsWithUnsequencedSQL  = "SELECT DISTINCT t.TreeId from Trees t INNER JOIN Unsequenced u WHERE TreeType='LOG' AND t.TreeId=u.TreeId"
+	selectActiveLogsSQL                 = "SELECT TreeId from Trees where TreeType IN('LOG','PREORDERED_LOG')"
+	selectActiveLogsWithUnsequencedSQL  = "SELECT DISTINCT t.TreeId from Trees t INNER JOIN Unsequenced u WHERE TreeType IN('LOG','PREORDERED_LOG') AND t.TreeId=u.TreeId"
 
 	selectSubtreeSQL = `
  SELECT x.SubtreeId, x.MaxRevision, Subtree.Nodes
@@ -53,17 +56,27 @@ const (
 	placeholderSQL = "<placeholder>"
 )
 
+// maxBatchRows is the most rows written by one multi-row INSERT. It bounds the size of
+// the statements, and the number prepared for each, as one is kept per row count.
+var maxBatchRows = 500
+
 // mySQLTreeStorage is shared between the mySQLLog- and (forthcoming) mySQLMap-
 // Storage implementations, and contains functionality which is common to both,
 type mySQLTreeStorage struct {
 	db *sql.DB
 
-	// Must hold the mutex before manipulating the statement map. Sharing a lock because
-	// it only needs to be held while the statements are built, not while they execute and
-	// this will be a short time. These maps are from the number of placeholder '?'
-	// in the query to the statement that should be used.
-	statementMutex sync.Mutex
-	statements     map[string]map[int]*sql.Stmt
+	// stmts holds the statements prepared on db, keyed by their SQL once any
+	// placeholders have been expanded.
+	stmts *stmtCache
+
+	// sharedCache holds subtrees read by earlier transactions, it's nil if they aren't kept.
+	sharedCache *cache.SharedSubtreeCache
+	// slowOpThreshold is how long an operation can take before it's logged, 0 if
+	// they never are.
+	slowOpThreshold time.Duration
+	// txTimeout is how long a transaction can be open before it's rolled back, 0 if
+	// it can stay open for as long as its context allows.
+	txTimeout time.Duration
 }
 
 // OpenDB opens a database connection for all MySQL-based storage implementations.
@@ -77,16 +90,17 @@ func OpenDB(dbURL string) (*sql.DB, error) {
 
 	if _, err := db.Exec("SET sql_mode = 'STRICT_ALL_TABLES'"); err != nil {
 		glog.Warningf("Failed to set strict mode on mysql db: %s", err)
+		db.Close()
 		return nil, err
 	}
 
 	return db, nil
 }
 
-func newTreeStorage(db *sql.DB) *mySQLTreeStorage {
+func newTreeStorage(db *sql.DB, stmtCacheSize int) *mySQLTreeStorage {
 	return &mySQLTreeStorage{
-		db:         db,
-		statements: make(map[string]map[int]*sql.Stmt),
+		db:    db,
+		stmts: newStmtCache(db, stmtCacheSize),
 	}
 }
 
@@ -102,53 +116,32 @@ func expandPlaceholderSQL(sql string, num int, first, rest string) string {
 	return strings.Replace(sql, placeholderSQL, parameters, 1)
 }
 
-// getStmt creates and caches sql.Stmt structs based on the passed in statement
-// and number of bound arguments.
-// TODO(al,martin): consider pulling this all out as a separate unit for reuse
-// elsewhere.
+// getStmt returns the cached sql.Stmt for the passed in statement expanded for the
+// number of bound arguments, preparing it if needed.
 func (m *mySQLTreeStorage) getStmt(statement string, num int, first, rest string) (*sql.Stmt, error) {
-	m.statementMutex.Lock()
-	defer m.statementMutex.Unlock()
-
-	if m.statements[statement] != nil {
-		if m.statements[statement][num] != nil {
-			// TODO(al,martin): we'll possibly need to expire Stmts from the cache,
-			// e.g. when DB connections break etc.
-			return m.statements[statement][num], nil
-		}
-	} else {
-		m.statements[statement] = make(map[int]*sql.Stmt)
-	}
-
-	s, err := m.db.Prepare(expandPlaceholderSQL(statement, num, first, rest))
-
-	if err != nil {
-		glog.Warningf("Failed to prepare statement %d: %s", num, err)
-		return nil, err
-	}
-
-	m.statements[statement][num] = s
-
-	return s, nil
+	return m.stmts.get(expandPlaceholderSQL(statement, num, first, rest))
 }
 
 func (m *mySQLTreeStorage) getSubtreeStmt(num int) (*sql.Stmt, error) {
 	return m.getStmt(selectSubtreeSQL, num, "?", "?")
 }
 
-func (m *mySQLTreeStorage) setSubtreeStmt(num int) (*sql.Stmt, error) {
-	return m.getStmt(insertSubtreeMultiSQL, num, "VALUES(?, ?, ?, ?)", "(?, ?, ?, ?)")
-}
-
 func (m *mySQLTreeStorage) beginTreeTx(ctx context.Context, treeID int64, hashSizeBytes int, strataDepths []int, populate storage.PopulateSubtreeFunc, prepare storage.PrepareSubtreeWriteFunc) (treeTX, error) {
-	// TODO(alcutter): use BeginTX(ctx) when we move to Go 1.8
-	t, err := m.db.Begin()
+	cancel := context.CancelFunc(func() {})
+	if m.txTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, m.txTimeout)
+	}
+	// The transaction is rolled back if ctx is done before it's committed.
+	t, err := m.db.BeginTx(ctx, nil)
 	if err != nil {
+		cancel()
 		glog.Warningf("Could not start tree TX: %s", err)
 		return treeTX{}, err
 	}
 	return treeTX{
 		tx:            t,
+		ctx:           ctx,
+		cancel:        cancel,
 		ts:            m,
 		treeID:        treeID,
 		hashSizeBytes: hashSizeBytes,
@@ -157,14 +150,37 @@ func (m *mySQLTreeStorage) beginTreeTx(ctx context.Context, treeID int64, hashSi
 	}, nil
 }
 
+// abandonedTXs counts the transactions rolled back because the context they were
+// started with was cancelled, usually because the client gave up on the RPC. Their
+// queries are interrupted and their work discarded rather than run to completion.
+var abandonedTXs = metric.NewCounter("mysql_abandoned_transactions")
+
 type treeTX struct {
-	closed        bool
-	tx            *sql.Tx
+	closed bool
+	tx     *sql.Tx
+	// ctx is the context tx was started with, cancel releases it once tx is done.
+	ctx           context.Context
+	cancel        context.CancelFunc
 	ts            *mySQLTreeStorage
 	treeID        int64
 	hashSizeBytes int
 	subtreeCache  cache.SubtreeCache
 	writeRevision int64
+	// usage is the bytes written by the transaction, added to the tree's TreeUsage
+	// when it's committed.
+	usage storage.StorageUsage
+}
+
+// stmt returns query, which mustn't need expanding, prepared for use in the transaction.
+func (t *treeTX) stmt(query string) (*sql.Stmt, error) {
+	if err := t.ctx.Err(); err != nil {
+		return nil, t.ctxError(err)
+	}
+	s, err := t.ts.stmts.get(query)
+	if err != nil {
+		return nil, err
+	}
+	return t.tx.StmtContext(t.ctx, s), nil
 }
 
 func (t *treeTX) getSubtree(treeRevision int64, nodeID storage.NodeID) (*storagepb.SubtreeProto, error) {
@@ -183,6 +199,7 @@ func (t *treeTX) getSubtree(treeRevision int64, nodeID storage.NodeID) (*storage
 }
 
 func (t *treeTX) getSubtrees(treeRevision int64, nodeIDs []storage.NodeID) ([]*storagepb.SubtreeProto, error) {
+	defer t.ts.observe(opReadSubtrees, t.treeID, time.Now())
 	if len(nodeIDs) == 0 {
 		return nil, nil
 	}
@@ -191,7 +208,7 @@ func (t *treeTX) getSubtrees(treeRevision int64, nodeIDs []storage.NodeID) ([]*s
 	if err != nil {
 		return nil, err
 	}
-	stx := t.tx.Stmt(tmpl)
+	stx := t.tx.StmtContext(t.ctx, tmpl)
 	defer stx.Close()
 
 	args := make([]interface{}, 0, len(nodeIDs)+3)
@@ -211,7 +228,7 @@ func (t *treeTX) getSubtrees(treeRevision int64, nodeIDs []storage.NodeID) ([]*s
 	args = append(args, interface{}(treeRevision))
 	args = append(args, interface{}(t.treeID))
 
-	rows, err := stx.Query(args...)
+	rows, err := stx.QueryContext(t.ctx, args...)
 	if err != nil {
 		glog.Warningf("Failed to get merkle subtrees: %s", err)
 		return nil, err
@@ -252,15 +269,13 @@ func (t *treeTX) getSubtrees(treeRevision int64, nodeIDs []storage.NodeID) ([]*s
 }
 
 func (t *treeTX) storeSubtrees(subtrees []*storagepb.SubtreeProto) error {
+	defer t.ts.observe(opWriteSubtrees, t.treeID, time.Now())
 	if len(subtrees) == 0 {
 		glog.Warning("attempted to store 0 subtrees...")
 		return nil
 	}
 
-	// TODO(al): probably need to be able to batch this in the case where we have
-	// a really large number of subtrees to store.
-	args := make([]interface{}, 0, len(subtrees))
-
+	rows := make([][]interface{}, 0, len(subtrees))
 	for _, s := range subtrees {
 		s := s
 		if s.Prefix == nil {
@@ -270,25 +285,42 @@ func (t *treeTX) storeSubtrees(subtrees []*storagepb.SubtreeProto) error {
 		if err != nil {
 			return err
 		}
-		args = append(args, t.treeID)
-		args = append(args, s.Prefix)
-		args = append(args, subtreeBytes)
-		args = append(args, t.writeRevision)
+		rows = append(rows, []interface{}{t.treeID, s.Prefix, subtreeBytes, t.writeRevision})
+		t.usage.NodeBytes += int64(len(subtreeBytes))
 	}
 
-	tmpl, err := t.ts.setSubtreeStmt(len(subtrees))
-	if err != nil {
+	if err := t.insertRows(insertSubtreeMultiSQL, subtreeRowSQL, rows); err != nil {
+		glog.Warningf("Failed to set merkle subtrees: %s", err)
 		return err
 	}
-	stx := t.tx.Stmt(tmpl)
-	defer stx.Close()
+	return nil
+}
 
-	r, err := stx.Exec(args...)
-	if err != nil {
-		glog.Warningf("Failed to set merkle subtrees: %s", err)
-		return err
+// insertRows inserts rows, each holding the arguments for one row of statement, with
+// multi-row INSERTs of at most maxBatchRows rows. statement's VALUES list must be a
+// placeholderSQL, which is expanded to one row placeholder per row.
+func (t *treeTX) insertRows(statement, row string, rows [][]interface{}) error {
+	for len(rows) > 0 {
+		n := len(rows)
+		if n > maxBatchRows {
+			n = maxBatchRows
+		}
+		tmpl, err := t.ts.getStmt(statement, n, row, row)
+		if err != nil {
+			return err
+		}
+		args := make([]interface{}, 0, n*len(rows[0]))
+		for _, r := range rows[:n] {
+			args = append(args, r...)
+		}
+		stx := t.tx.StmtContext(t.ctx, tmpl)
+		_, err = stx.ExecContext(t.ctx, args...)
+		stx.Close()
+		if err != nil {
+			return err
+		}
+		rows = rows[n:]
 	}
-	_, _ = r.RowsAffected()
 	return nil
 }
 
@@ -324,16 +356,25 @@ func (t *treeTX) GetTreeRevisionIncludingSize(treeSize int64) (int64, int64, err
 	}
 
 	var treeRevision, actualTreeSize int64
-	err := t.tx.QueryRow(selectTreeRevisionAtSizeOrLargerSQL, t.treeID, treeSize).Scan(&treeRevision, &actualTreeSize)
+	stx, err := t.stmt(selectTreeRevisionAtSizeOrLargerSQL)
+	if err != nil {
+		return 0, 0, err
+	}
+	err = stx.QueryRowContext(t.ctx, t.treeID, treeSize).Scan(&treeRevision, &actualTreeSize)
 
 	return treeRevision, actualTreeSize, err
 }
 
 // getSubtreesAtRev returns a GetSubtreesFunc which reads at the passed in rev.
 func (t *treeTX) getSubtreesAtRev(rev int64) cache.GetSubtreesFunc {
-	return func(ids []storage.NodeID) ([]*storagepb.SubtreeProto, error) {
+	get := func(ids []storage.NodeID) ([]*storagepb.SubtreeProto, error) {
 		return t.getSubtrees(rev, ids)
 	}
+	// Subtrees at the revision this tx is writing aren't committed, so can't be shared.
+	if t.writeRevision >= 0 && rev >= t.writeRevision {
+		return get
+	}
+	return t.ts.sharedCache.Wrap(t.treeID, rev, get)
 }
 
 // GetMerkleNodes returns the requests nodes at (or below) the passed in treeRevision.
@@ -360,19 +401,48 @@ func (t *treeTX) Commit() error {
 			return t.storeSubtrees(st)
 		}); err != nil {
 			glog.Warningf("TX commit flush error: %v", err)
-			return err
+			return t.ctxError(err)
 		}
 	}
+	if err := t.addUsage(t.usage); err != nil {
+		glog.Warningf("TX commit usage error: %v", err)
+		return t.ctxError(err)
+	}
 	t.closed = true
+	defer t.cancel()
 	if err := t.tx.Commit(); err != nil {
 		glog.Warningf("TX commit error: %s", err)
-		return err
+		return t.ctxError(err)
 	}
+	recordUsageWritten(t.treeID, t.usage)
 	return nil
 }
 
+// ctxError returns a DeadlineExceeded or Canceled error in place of err if the
+// transaction failed because its context was done, e.g. after the storage's
+// transaction timeout or the client's cancellation of the RPC rolled it back.
+func (t *treeTX) ctxError(err error) error {
+	switch t.ctx.Err() {
+	case context.DeadlineExceeded:
+		return errors.Errorf(errors.DeadlineExceeded, "storage transaction exceeded its deadline: %v", err)
+	case context.Canceled:
+		return errors.Errorf(errors.Canceled, "storage transaction was cancelled: %v", err)
+	}
+	return err
+}
+
+// countIfAbandoned counts a transaction being rolled back as abandoned if ctx, the
+// context it was started with, was cancelled.
+func countIfAbandoned(ctx context.Context) {
+	if ctx.Err() == context.Canceled {
+		abandonedTXs.Add(1)
+	}
+}
+
 func (t *treeTX) Rollback() error {
 	t.closed = true
+	countIfAbandoned(t.ctx)
+	defer t.cancel()
 	if err := t.tx.Rollback(); err != nil {
 		glog.Warningf("TX rollback error: %s", err)
 		return err
@@ -396,14 +466,12 @@ func (t *treeTX) IsOpen() bool {
 }
 
 func checkDatabaseAccessible(ctx context.Context, db *sql.DB) error {
-	_ = ctx
-
-	stmt, err := db.Prepare("SELECT TreeId FROM Trees LIMIT 1")
+	stmt, err := db.PrepareContext(ctx, "SELECT TreeId FROM Trees LIMIT 1")
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec()
+	_, err = stmt.ExecContext(ctx)
 	return err
 }
@@ -0,0 +1,168 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/monitoring/metric"
+	"github.com/google/trillian/util"
+)
+
+const (
+	// A FROZEN log is still sequenced until it's finalized, so its queue is only
+	// deleted from then on.
+	selectInactiveLogsSQL = `SELECT Trees.TreeId
+			FROM Trees LEFT JOIN TreeControl ON Trees.TreeId = TreeControl.TreeId
+			WHERE Trees.TreeState<>'ACTIVE'
+			AND (Trees.TreeState<>'FROZEN' OR COALESCE(TreeControl.FinalizeTimeMillis, 0)>0)
+			AND Trees.TreeType IN ('LOG','PREORDERED_LOG')
+			AND Trees.UpdateTimeMillis<?`
+	selectOrphanedUnsequencedSQL = "SELECT LeafIdentityHash FROM Unsequenced WHERE TreeId=? LIMIT ?"
+	// These statements need to be expanded to provide the correct number of parameter placeholders.
+	deleteOrphanedUnsequencedSQL = "DELETE FROM Unsequenced WHERE TreeId=? AND LeafIdentityHash IN (" + placeholderSQL + ")"
+	// The data of leaves which were never sequenced is deleted with their queue entries,
+	// or a log which doesn't allow duplicates would refuse them if it was reactivated.
+	deleteOrphanedLeafDataSQL = `DELETE FROM LeafData
+			WHERE TreeId=? AND LeafIdentityHash IN (` + placeholderSQL + `)
+			AND NOT EXISTS(SELECT 1 FROM SequencedLeafData s
+				WHERE s.TreeId=LeafData.TreeId AND s.LeafIdentityHash=LeafData.LeafIdentityHash)`
+)
+
+var (
+	reclaimedUnsequencedCounter = metric.NewCounter("mysql_reclaimed_unsequenced_rows")
+	reclaimedLeafDataCounter    = metric.NewCounter("mysql_reclaimed_leaf_data_rows")
+)
+
+// DeleteOrphanedUnsequenced deletes the queued leaves of logs which have been finalized
+// or deleted, and haven't been updated since, for at least gracePeriod as of now. The
+// signer doesn't sequence such logs, so their queues would otherwise be kept forever.
+// Up to batchSize leaves are deleted per transaction. It returns the number of
+// Unsequenced rows deleted.
+func DeleteOrphanedUnsequenced(ctx context.Context, db *sql.DB, now time.Time, gracePeriod time.Duration, batchSize int) (int64, error) {
+	rows, err := db.QueryContext(ctx, selectInactiveLogsSQL, toMillisSinceEpoch(now.Add(-gracePeriod)))
+	if err != nil {
+		return 0, err
+	}
+	var treeIDs []int64
+	for rows.Next() {
+		var treeID int64
+		if err := rows.Scan(&treeID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		treeIDs = append(treeIDs, treeID)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return 0, err
+	}
+	rows.Close()
+
+	var deleted int64
+	for _, treeID := range treeIDs {
+		var n int64
+		for {
+			batch, err := deleteOrphanedUnsequencedBatch(ctx, db, treeID, batchSize)
+			n += batch
+			if err != nil {
+				return deleted + n, err
+			}
+			if batch == 0 {
+				break
+			}
+		}
+		if n > 0 {
+			glog.Infof("%v: deleted %d queued leaves of inactive log", treeID, n)
+		}
+		deleted += n
+	}
+	return deleted, nil
+}
+
+// deleteOrphanedUnsequencedBatch deletes up to batchSize of the queued leaves of treeID,
+// and returns the number of Unsequenced rows deleted.
+func deleteOrphanedUnsequencedBatch(ctx context.Context, db *sql.DB, treeID int64, batchSize int) (int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, selectOrphanedUnsequencedSQL, treeID, batchSize)
+	if err != nil {
+		return 0, err
+	}
+	args := []interface{}{treeID}
+	for rows.Next() {
+		var id []byte
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		args = append(args, id)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return 0, err
+	}
+	rows.Close()
+	if len(args) == 1 {
+		return 0, nil
+	}
+
+	res, err := tx.ExecContext(ctx, expandPlaceholderSQL(deleteOrphanedUnsequencedSQL, len(args)-1, "?", "?"), args...)
+	if err != nil {
+		return 0, err
+	}
+	unsequenced, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	res, err = tx.ExecContext(ctx, expandPlaceholderSQL(deleteOrphanedLeafDataSQL, len(args)-1, "?", "?"), args...)
+	if err != nil {
+		return 0, err
+	}
+	leafData, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	reclaimedUnsequencedCounter.Add(unsequenced)
+	reclaimedLeafDataCounter.Add(leafData)
+	return unsequenced, nil
+}
+
+// DeleteOrphanedUnsequencedLeaves runs DeleteOrphanedUnsequenced against db every
+// interval until ctx is done.
+func DeleteOrphanedUnsequencedLeaves(ctx context.Context, db *sql.DB, interval, gracePeriod time.Duration, batchSize int, timeSource util.TimeSource) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := DeleteOrphanedUnsequenced(ctx, db, timeSource.Now(), gracePeriod, batchSize); err != nil {
+			glog.Warningf("Failed to delete queued leaves of inactive logs: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+)
+
+func TestDeleteOrphanedUnsequenced(t *testing.T) {
+	cleanTestDB(DB)
+	activeID := createLogForTests(DB)
+	frozenID := createLogForTests(DB)
+	finalizedID := createLogForTests(DB)
+	s := NewLogStorage(DB)
+	ctx := context.Background()
+
+	for _, logID := range []int64{activeID, frozenID, finalizedID} {
+		tx := beginLogTx(s, logID, t)
+		if _, err := tx.QueueLeaves(createTestLeaves(leavesToInsert, 0), fakeQueueTime); err != nil {
+			t.Fatalf("Failed to queue leaves: %v", err)
+		}
+		commit(tx, t)
+	}
+	// One of the finalized log's queued leaves was also sequenced, e.g. as a duplicate.
+	sequenced := createTestLeaves(1, 0)[0]
+	if _, err := DB.Exec("INSERT INTO SequencedLeafData(TreeId, SequenceNumber, LeafIdentityHash, MerkleLeafHash) VALUES(?,?,?,?)", finalizedID, 0, sequenced.LeafIdentityHash, sequenced.MerkleLeafHash); err != nil {
+		t.Fatalf("Failed to sequence leaf: %v", err)
+	}
+
+	as := NewAdminStorage(DB)
+	atx, err := as.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin()=%v", err)
+	}
+	if _, err := atx.UpdateTree(ctx, frozenID, func(tree *trillian.Tree) { tree.TreeState = trillian.TreeState_FROZEN }); err != nil {
+		t.Fatalf("UpdateTree()=%v", err)
+	}
+	if _, err := atx.UpdateTree(ctx, finalizedID, func(tree *trillian.Tree) {
+		tree.TreeState = trillian.TreeState_FROZEN
+		tree.FinalizeTimeMillisSinceEpoch = toMillisSinceEpoch(fakeQueueTime)
+	}); err != nil {
+		t.Fatalf("UpdateTree()=%v", err)
+	}
+	commit(atx, t)
+
+	// Nothing is deleted within the grace period, nor ever from the frozen log, which is
+	// still sequenced until it's finalized.
+	if got, err := DeleteOrphanedUnsequenced(ctx, DB, time.Now(), time.Hour, 2); err != nil || got != 0 {
+		t.Fatalf("DeleteOrphanedUnsequenced()=%d, %v, want 0, nil", got, err)
+	}
+	if got, err := DeleteOrphanedUnsequenced(ctx, DB, time.Now().Add(2*time.Hour), time.Hour, 2); err != nil || got != leavesToInsert {
+		t.Fatalf("DeleteOrphanedUnsequenced()=%d, %v, want %d, nil", got, err, leavesToInsert)
+	}
+
+	for _, test := range []struct {
+		logID                       int64
+		wantUnsequenced, wantLeaves int
+	}{
+		{logID: activeID, wantUnsequenced: leavesToInsert, wantLeaves: leavesToInsert},
+		{logID: frozenID, wantUnsequenced: leavesToInsert, wantLeaves: leavesToInsert},
+		{logID: finalizedID, wantUnsequenced: 0, wantLeaves: 1},
+	} {
+		var unsequenced, leaves int
+		if err := DB.QueryRow("SELECT COUNT(*) FROM Unsequenced WHERE TreeId=?", test.logID).Scan(&unsequenced); err != nil {
+			t.Fatalf("Could not count Unsequenced rows: %v", err)
+		}
+		if err := DB.QueryRow("SELECT COUNT(*) FROM LeafData WHERE TreeId=?", test.logID).Scan(&leaves); err != nil {
+			t.Fatalf("Could not count LeafData rows: %v", err)
+		}
+		if unsequenced != test.wantUnsequenced || leaves != test.wantLeaves {
+			t.Errorf("%d: got %d Unsequenced and %d LeafData rows, want %d and %d", test.logID, unsequenced, leaves, test.wantUnsequenced, test.wantLeaves)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"github.com/google/trillian"
+	"github.com/google/trillian/errors"
+)
+
+// NewPinnedLogTreeTX returns a view of tx as of the log's root of size treeSize, so reads
+// made in separate transactions are answered against the same tree while the log grows.
+// LatestSignedLogRoot returns that root, ReadRevision its revision and GetSequencedLeafCount
+// its size. Leaves beyond it are treated as not yet integrated: GetLeavesByHash leaves them
+// out, and GetLeavesByIndex fails with OutOfRange if asked for one.
+// It fails with NotFound if the log has no root of size treeSize.
+func NewPinnedLogTreeTX(tx ReadOnlyLogTreeTX, treeSize int64) (ReadOnlyLogTreeTX, error) {
+	root, err := tx.SignedLogRootAtSize(treeSize)
+	if err != nil {
+		return nil, err
+	}
+	if len(root.RootHash) == 0 {
+		return nil, errors.Errorf(errors.NotFound, "log has no root of tree size %d", treeSize)
+	}
+	return &pinnedLogTreeTX{ReadOnlyLogTreeTX: tx, root: root}, nil
+}
+
+type pinnedLogTreeTX struct {
+	ReadOnlyLogTreeTX
+	root trillian.SignedLogRoot
+}
+
+func (p *pinnedLogTreeTX) ReadRevision() int64 {
+	return p.root.TreeRevision
+}
+
+func (p *pinnedLogTreeTX) LatestSignedLogRoot() (trillian.SignedLogRoot, error) {
+	return p.root, nil
+}
+
+func (p *pinnedLogTreeTX) GetSequencedLeafCount() (int64, error) {
+	return p.root.TreeSize, nil
+}
+
+func (p *pinnedLogTreeTX) GetLeavesByIndex(leaves []int64) ([]*trillian.LogLeaf, error) {
+	for _, index := range leaves {
+		if index >= p.root.TreeSize {
+			return nil, errors.Errorf(errors.OutOfRange, "leaf index %d is beyond the pinned tree size %d", index, p.root.TreeSize)
+		}
+	}
+	return p.ReadOnlyLogTreeTX.GetLeavesByIndex(leaves)
+}
+
+func (p *pinnedLogTreeTX) GetLeavesByHash(leafHashes [][]byte, orderBySequence bool) ([]*trillian.LogLeaf, error) {
+	leaves, err := p.ReadOnlyLogTreeTX.GetLeavesByHash(leafHashes, orderBySequence)
+	if err != nil {
+		return nil, err
+	}
+	pinned := leaves[:0]
+	for _, leaf := range leaves {
+		if leaf.LeafIndex < p.root.TreeSize {
+			pinned = append(pinned, leaf)
+		}
+	}
+	return pinned, nil
+}
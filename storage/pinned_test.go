@@ -0,0 +1,71 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/errors"
+)
+
+func TestPinnedLogTreeTX(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	root := trillian.SignedLogRoot{TreeSize: 3, TreeRevision: 5, RootHash: []byte("root")}
+	leaves := []*trillian.LogLeaf{{LeafIndex: 1}, {LeafIndex: 3}, {LeafIndex: 2}, {LeafIndex: 7}}
+	mockTx := NewMockReadOnlyLogTreeTX(ctrl)
+	mockTx.EXPECT().SignedLogRootAtSize(int64(3)).Return(root, nil)
+	mockTx.EXPECT().GetLeavesByHash([][]byte{[]byte("hash")}, false).Return(leaves, nil)
+	mockTx.EXPECT().GetLeavesByIndex([]int64{0, 2}).Return(leaves[:1], nil)
+
+	tx, err := NewPinnedLogTreeTX(mockTx, 3)
+	if err != nil {
+		t.Fatalf("NewPinnedLogTreeTX()=(_, %v), want (_, nil)", err)
+	}
+	if got := tx.ReadRevision(); got != 5 {
+		t.Errorf("ReadRevision()=%d, want 5", got)
+	}
+	if got, err := tx.LatestSignedLogRoot(); err != nil || !reflect.DeepEqual(got, root) {
+		t.Errorf("LatestSignedLogRoot()=(%v, %v), want (%v, nil)", got, err, root)
+	}
+	if got, err := tx.GetSequencedLeafCount(); got != 3 || err != nil {
+		t.Errorf("GetSequencedLeafCount()=(%d, %v), want (3, nil)", got, err)
+	}
+	got, err := tx.GetLeavesByHash([][]byte{[]byte("hash")}, false)
+	if want := []*trillian.LogLeaf{{LeafIndex: 1}, {LeafIndex: 2}}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("GetLeavesByHash()=(%v, %v), want (%v, nil)", got, err, want)
+	}
+	if _, err := tx.GetLeavesByIndex([]int64{0, 2}); err != nil {
+		t.Errorf("GetLeavesByIndex([0 2])=(_, %v), want (_, nil)", err)
+	}
+	if _, err := tx.GetLeavesByIndex([]int64{0, 3}); errors.ErrorCode(err) != errors.OutOfRange {
+		t.Errorf("GetLeavesByIndex([0 3])=(_, %v), want OutOfRange", err)
+	}
+}
+
+func TestPinnedLogTreeTXNoRoot(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockTx := NewMockReadOnlyLogTreeTX(ctrl)
+	mockTx.EXPECT().SignedLogRootAtSize(int64(4)).Return(trillian.SignedLogRoot{}, nil)
+	if _, err := NewPinnedLogTreeTX(mockTx, 4); errors.ErrorCode(err) != errors.NotFound {
+		t.Errorf("NewPinnedLogTreeTX()=(_, %v), want NotFound", err)
+	}
+}
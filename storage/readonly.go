@@ -0,0 +1,54 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+
+	"github.com/google/trillian/errors"
+)
+
+// ErrReadOnly is returned when a read-write transaction is requested from storage
+// wrapped by NewReadOnlyLogStorage or NewReadOnlyAdminStorage.
+var ErrReadOnly = errors.New(errors.PermissionDenied, "storage is read-only")
+
+// NewReadOnlyLogStorage returns a LogStorage which passes snapshots through to s, but
+// fails to begin read-write transactions with ErrReadOnly. It's intended for servers
+// backed by a read replica.
+func NewReadOnlyLogStorage(s LogStorage) LogStorage {
+	return readOnlyLogStorage{s}
+}
+
+type readOnlyLogStorage struct {
+	LogStorage
+}
+
+func (readOnlyLogStorage) BeginForTree(ctx context.Context, treeID int64) (LogTreeTX, error) {
+	return nil, ErrReadOnly
+}
+
+// NewReadOnlyAdminStorage returns an AdminStorage which passes snapshots through to s,
+// but fails to begin read-write transactions with ErrReadOnly.
+func NewReadOnlyAdminStorage(s AdminStorage) AdminStorage {
+	return readOnlyAdminStorage{s}
+}
+
+type readOnlyAdminStorage struct {
+	AdminStorage
+}
+
+func (readOnlyAdminStorage) Begin(ctx context.Context) (AdminTX, error) {
+	return nil, ErrReadOnly
+}
@@ -0,0 +1,58 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+)
+
+func TestReadOnlyLogStorage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	mockStorage := NewMockLogStorage(ctrl)
+	mockTx := NewMockReadOnlyLogTreeTX(ctrl)
+	mockStorage.EXPECT().SnapshotForTree(ctx, int64(1)).Return(mockTx, nil)
+	s := NewReadOnlyLogStorage(mockStorage)
+
+	if tx, err := s.SnapshotForTree(ctx, 1); tx != mockTx || err != nil {
+		t.Errorf("SnapshotForTree()=(%v, %v), want (%v, nil)", tx, err, mockTx)
+	}
+	if _, err := s.BeginForTree(ctx, 1); err != ErrReadOnly {
+		t.Errorf("BeginForTree()=(_, %v), want (_, %v)", err, ErrReadOnly)
+	}
+}
+
+func TestReadOnlyAdminStorage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	mockStorage := NewMockAdminStorage(ctrl)
+	mockTx := NewMockReadOnlyAdminTX(ctrl)
+	mockStorage.EXPECT().Snapshot(ctx).Return(mockTx, nil)
+	s := NewReadOnlyAdminStorage(mockStorage)
+
+	if tx, err := s.Snapshot(ctx); tx != mockTx || err != nil {
+		t.Errorf("Snapshot()=(%v, %v), want (%v, nil)", tx, err, mockTx)
+	}
+	if _, err := s.Begin(ctx); err != ErrReadOnly {
+		t.Errorf("Begin()=(_, %v), want (_, %v)", err, ErrReadOnly)
+	}
+}
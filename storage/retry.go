@@ -0,0 +1,88 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/client/backoff"
+	"github.com/google/trillian/monitoring/metric"
+)
+
+// MaxTxAttempts is the number of times RunInLogTX tries a transaction which keeps
+// failing with transient errors before giving up.
+const MaxTxAttempts = 5
+
+var (
+	txRetries = metric.NewCounter("storage_tx_retries")
+
+	// txBackoff is the wait between attempts, variable for tests.
+	txBackoff = backoff.Backoff{
+		Min:    10 * time.Millisecond,
+		Max:    time.Second,
+		Factor: 2,
+		Jitter: true,
+	}
+)
+
+// TransientErrorChecker is implemented by storage which can tell when a failed
+// transaction is worth retrying from the start, e.g. because it was chosen as the
+// victim of a deadlock.
+type TransientErrorChecker interface {
+	IsTransientError(err error) bool
+}
+
+// RunInLogTX begins a transaction on treeID, runs f in it and commits it. If any of
+// those steps fails with an error s reports as transient the transaction is rolled
+// back and the whole sequence retried after a backoff, up to MaxTxAttempts times in
+// total, after which an Error of type TransientFailure is returned. f must therefore
+// be safe to run more than once.
+func RunInLogTX(ctx context.Context, s LogStorage, treeID int64, f func(tx LogTreeTX) error) error {
+	checker, _ := s.(TransientErrorChecker)
+	b := txBackoff
+	for attempt := 1; ; attempt++ {
+		err := runInLogTX(ctx, s, treeID, f)
+		if err == nil || checker == nil || !checker.IsTransientError(err) {
+			return err
+		}
+		if attempt == MaxTxAttempts {
+			return Error{ErrType: TransientFailure, Detail: fmt.Sprintf("transaction on tree %v failed %d times", treeID, attempt), Cause: err}
+		}
+
+		txRetries.Add(1)
+		glog.Warningf("%v: retrying transaction after transient error (attempt %d): %v", treeID, attempt, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(b.Duration()):
+		}
+	}
+}
+
+func runInLogTX(ctx context.Context, s LogStorage, treeID int64, f func(tx LogTreeTX) error) error {
+	tx, err := s.BeginForTree(ctx, treeID)
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+
+	if err := f(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
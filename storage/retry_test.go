@@ -0,0 +1,101 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+)
+
+var (
+	errTransient = errors.New("deadlock")
+	errPermanent = errors.New("broken")
+)
+
+// transientLogStorage reports errTransient as a transient error.
+type transientLogStorage struct {
+	LogStorage
+}
+
+func (transientLogStorage) IsTransientError(err error) bool {
+	return err == errTransient
+}
+
+func TestRunInLogTX(t *testing.T) {
+	txBackoff.Min, txBackoff.Max, txBackoff.Jitter = time.Millisecond, time.Millisecond, false
+	ctx := context.Background()
+
+	tests := []struct {
+		desc      string
+		checker   bool
+		errs      []error // returned by f on successive attempts
+		commitErr error
+		wantRuns  int
+		wantErr   error
+	}{
+		{desc: "ok", checker: true, errs: []error{nil}, wantRuns: 1},
+		{desc: "retried", checker: true, errs: []error{errTransient, errTransient, nil}, wantRuns: 3},
+		{desc: "permanent", checker: true, errs: []error{errTransient, errPermanent}, wantRuns: 2, wantErr: errPermanent},
+		{desc: "exhausted", checker: true, errs: []error{errTransient, errTransient, errTransient, errTransient, errTransient}, wantRuns: MaxTxAttempts, wantErr: Error{ErrType: TransientFailure, Detail: "transaction on tree 1 failed 5 times", Cause: errTransient}},
+		{desc: "noChecker", errs: []error{errTransient}, wantRuns: 1, wantErr: errTransient},
+		{desc: "commitRetried", checker: true, errs: []error{nil, nil}, commitErr: errTransient, wantRuns: 2},
+	}
+
+	for _, test := range tests {
+		func() {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockStorage := NewMockLogStorage(ctrl)
+			mockTx := NewMockLogTreeTX(ctrl)
+			mockStorage.EXPECT().BeginForTree(ctx, int64(1)).Return(mockTx, nil).Times(test.wantRuns)
+			mockTx.EXPECT().Close().Return(nil).Times(test.wantRuns)
+			commits := 0
+			for _, err := range test.errs {
+				if err == nil {
+					commits++
+				}
+			}
+			if test.commitErr != nil {
+				mockTx.EXPECT().Commit().Return(test.commitErr)
+				commits--
+			}
+			if commits > 0 {
+				mockTx.EXPECT().Commit().Return(nil).Times(commits)
+			}
+
+			var s LogStorage = mockStorage
+			if test.checker {
+				s = transientLogStorage{mockStorage}
+			}
+			runs := 0
+			err := RunInLogTX(ctx, s, 1, func(tx LogTreeTX) error {
+				err := test.errs[runs]
+				runs++
+				return err
+			})
+			if err != test.wantErr {
+				t.Errorf("%v: RunInLogTX()=%v, want %v", test.desc, err, test.wantErr)
+			}
+			if runs != test.wantRuns {
+				t.Errorf("%v: RunInLogTX() ran f %d times, want %d", test.desc, runs, test.wantRuns)
+			}
+		}()
+	}
+}
@@ -16,6 +16,7 @@ package testonly
 
 import (
 	"context"
+	"math/rand"
 	"reflect"
 	"testing"
 
@@ -101,6 +102,22 @@ func (tester *AdminStorageTester) TestCreateTree(t *testing.T) {
 	validTreeWithoutOptionals.DisplayName = ""
 	validTreeWithoutOptionals.Description = ""
 
+	// The shard set is random so earlier runs against the same database don't overlap.
+	shard := *LogTree
+	shard.ShardSetId = rand.Int63()
+	shard.ShardStartMillisSinceEpoch = 1000
+	shard.ShardEndMillisSinceEpoch = 2000
+	overlappingShard := shard
+	overlappingShard.ShardStartMillisSinceEpoch = 1500
+	overlappingShard.ShardEndMillisSinceEpoch = 2500
+
+	keyedIdentityHash := *LogTree
+	keyedIdentityHash.LeafIdentityHashStrategy = trillian.LeafIdentityHashStrategy_HMAC_SHA256_LEAF_VALUE
+	keyedIdentityHash.LeafIdentityHashKey = []byte("0123456789abcdef0123456789abcdef")
+
+	allowedWriters := *LogTree
+	allowedWriters.AllowedWriters = []string{"spiffe://example.org/ct/"}
+
 	tests := []struct {
 		desc    string
 		tree    *trillian.Tree
@@ -123,6 +140,23 @@ func (tester *AdminStorageTester) TestCreateTree(t *testing.T) {
 			desc: "validTreeWithoutOptionals",
 			tree: &validTreeWithoutOptionals,
 		},
+		{
+			desc: "shard",
+			tree: &shard,
+		},
+		{
+			desc:    "overlappingShard",
+			tree:    &overlappingShard,
+			wantErr: true,
+		},
+		{
+			desc: "keyedIdentityHash",
+			tree: &keyedIdentityHash,
+		},
+		{
+			desc: "allowedWriters",
+			tree: &allowedWriters,
+		},
 	}
 
 	ctx := context.Background()
@@ -196,10 +230,32 @@ func (tester *AdminStorageTester) TestUpdateTree(t *testing.T) {
 	validLog.TreeState = trillian.TreeState_FROZEN
 	validLog.DisplayName = "Frozen Tree"
 	validLog.Description = "A Frozen Tree"
+	validLog.SequencingBatchSize = 500
+	validLog.SequencingIntervalSeconds = 30
+	validLog.SequencingGuardWindowSeconds = 10
+	validLog.LeafCompression = trillian.LeafCompression_ZSTD
+	validLog.LeafRetentionSeconds = 3600
+	validLog.FinalizeTimeMillisSinceEpoch = 1000
+	validLog.FinalizedTreeSize = 10
+	validLog.AllowedWriters = []string{"spiffe://example.org/ct/", "spiffe://example.org/mirror"}
 	validLogFunc := func(t *trillian.Tree) {
 		t.TreeState = validLog.TreeState
 		t.DisplayName = validLog.DisplayName
 		t.Description = validLog.Description
+		t.SequencingBatchSize = validLog.SequencingBatchSize
+		t.SequencingIntervalSeconds = validLog.SequencingIntervalSeconds
+		t.SequencingGuardWindowSeconds = validLog.SequencingGuardWindowSeconds
+		t.LeafCompression = validLog.LeafCompression
+		t.LeafRetentionSeconds = validLog.LeafRetentionSeconds
+		t.FinalizeTimeMillisSinceEpoch = validLog.FinalizeTimeMillisSinceEpoch
+		t.FinalizedTreeSize = validLog.FinalizedTreeSize
+		t.AllowedWriters = validLog.AllowedWriters
+	}
+
+	// Only FROZEN logs stay finalized, storage clears it for others.
+	activeFinalizedFunc := func(t *trillian.Tree) {
+		t.FinalizeTimeMillisSinceEpoch = 1000
+		t.FinalizedTreeSize = 10
 	}
 
 	validLogWithoutOptionalsFunc := func(t *trillian.Tree) {
@@ -247,6 +303,12 @@ func (tester *AdminStorageTester) TestUpdateTree(t *testing.T) {
 			updateFunc: validLogWithoutOptionalsFunc,
 			want:       &validLogWithoutOptionals,
 		},
+		{
+			desc:       "activeFinalized",
+			create:     &referenceLog,
+			updateFunc: activeFinalizedFunc,
+			want:       &referenceLog,
+		},
 		{
 			desc:       "invalidLog",
 			create:     &referenceLog,
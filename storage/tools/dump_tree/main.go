@@ -0,0 +1,273 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The dump_tree tool prints the stored Merkle nodes and leaves of a log tree, for
+// debugging sequencing problems. Output can be JSON, protobuf text format or a DOT
+// graph for rendering with Graphviz, e.g.:
+//
+//   dump_tree --treeid=3 --format=dot --max_level=3 | dot -Tsvg > tree.svg
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	_ "github.com/go-sql-driver/mysql" // Load MySQL driver
+
+	log "github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/mysql"
+)
+
+// maxTreeDepth matches the depth the log sequencer uses when storing nodes.
+const maxTreeDepth = 64
+
+var (
+	mySQLURI     = flag.String("mysql_uri", "test:zaphod@tcp(127.0.0.1:3306)/test", "Connection URI for MySQL database")
+	treeIDFlag   = flag.Int64("treeid", 3, "The tree id to use")
+	formatFlag   = flag.String("format", "json", "Output format: json, proto (protobuf text format) or dot")
+	revisionFlag = flag.Int64("revision", -1, "Tree revision to read nodes at, -1 for the revision of the latest signed root")
+	treeSizeFlag = flag.Int64("tree_size", 0, "Only dump nodes and leaves covering the first tree_size leaves, 0 for the size of the latest signed root")
+	minLevelFlag = flag.Int("min_level", 0, "Lowest node level to dump, leaf hashes are level 0")
+	maxLevelFlag = flag.Int("max_level", maxTreeDepth, "Highest node level to dump")
+	leavesFlag   = flag.Bool("leaves", true, "Whether to dump leaf data as well as nodes")
+)
+
+// treeDump is everything read from storage for the tree being dumped.
+type treeDump struct {
+	Root   trillian.SignedLogRoot
+	Nodes  []storage.Node
+	Leaves []*trillian.LogLeaf
+	// coords holds the level and index of each entry in Nodes.
+	coords [][2]int64
+}
+
+// readTree reads the nodes at levels [minLevel, maxLevel] of a tree with size leaves, at
+// the given revision, and optionally the leaves themselves.
+func readTree(tx storage.ReadOnlyLogTreeTX, root trillian.SignedLogRoot, size, revision int64, minLevel, maxLevel int, withLeaves bool) (*treeDump, error) {
+	d := &treeDump{Root: root}
+	for level := minLevel; level <= maxLevel && level < maxTreeDepth; level++ {
+		// Nodes at this level which cover at least one of the leaves. The last of them may
+		// be on the right edge of the tree, and only exist if the tree is not perfect.
+		count := (size + (int64(1) << uint(level)) - 1) >> uint(level)
+		if count == 0 || (level > 0 && size <= int64(1)<<uint(level-1)) {
+			break
+		}
+		ids := make([]storage.NodeID, 0, count)
+		for index := int64(0); index < count; index++ {
+			id, err := storage.NewNodeIDForTreeCoords(int64(level), index, maxTreeDepth)
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, id)
+		}
+		nodes, err := tx.GetMerkleNodes(revision, ids)
+		if err != nil {
+			return nil, err
+		}
+		// GetMerkleNodes skips nodes which don't exist, so match them back to their index.
+		byID := make(map[string]storage.Node)
+		for _, n := range nodes {
+			byID[n.NodeID.String()] = n
+		}
+		for index, id := range ids {
+			if n, ok := byID[id.String()]; ok {
+				d.Nodes = append(d.Nodes, n)
+				d.coords = append(d.coords, [2]int64{int64(level), int64(index)})
+			}
+		}
+	}
+
+	if withLeaves && size > 0 {
+		indices := make([]int64, 0, size)
+		for i := int64(0); i < size; i++ {
+			indices = append(indices, i)
+		}
+		leaves, err := tx.GetLeavesByIndex(indices)
+		if err != nil {
+			return nil, err
+		}
+		d.Leaves = leaves
+	}
+	return d, nil
+}
+
+type jsonNode struct {
+	Level    int64  `json:"level"`
+	Index    int64  `json:"index"`
+	Revision int64  `json:"revision"`
+	Hash     string `json:"hash"`
+}
+
+type jsonLeaf struct {
+	Index            int64  `json:"index"`
+	MerkleLeafHash   string `json:"merkle_leaf_hash"`
+	LeafIdentityHash string `json:"leaf_identity_hash"`
+	LeafValue        []byte `json:"leaf_value"`
+	ExtraData        []byte `json:"extra_data,omitempty"`
+}
+
+func writeJSON(w io.Writer, d *treeDump) error {
+	out := struct {
+		TreeSize     int64      `json:"tree_size"`
+		TreeRevision int64      `json:"tree_revision"`
+		RootHash     string     `json:"root_hash"`
+		Nodes        []jsonNode `json:"nodes"`
+		Leaves       []jsonLeaf `json:"leaves,omitempty"`
+	}{
+		TreeSize:     d.Root.TreeSize,
+		TreeRevision: d.Root.TreeRevision,
+		RootHash:     hex.EncodeToString(d.Root.RootHash),
+	}
+	for i, n := range d.Nodes {
+		out.Nodes = append(out.Nodes, jsonNode{
+			Level:    d.coords[i][0],
+			Index:    d.coords[i][1],
+			Revision: n.NodeRevision,
+			Hash:     hex.EncodeToString(n.Hash),
+		})
+	}
+	for _, l := range d.Leaves {
+		out.Leaves = append(out.Leaves, jsonLeaf{
+			Index:            l.LeafIndex,
+			MerkleLeafHash:   hex.EncodeToString(l.MerkleLeafHash),
+			LeafIdentityHash: hex.EncodeToString(l.LeafIdentityHash),
+			LeafValue:        l.LeafValue,
+			ExtraData:        l.ExtraData,
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// writeProto writes the root, nodes and leaves as trillian.SignedLogRoot, trillian.Node
+// and trillian.LogLeaf messages in protobuf text format.
+func writeProto(w io.Writer, d *treeDump) error {
+	if _, err := fmt.Fprintf(w, "# SignedLogRoot\n%s\n", proto.MarshalTextString(&d.Root)); err != nil {
+		return err
+	}
+	for i, n := range d.Nodes {
+		node := &trillian.Node{NodeId: n.NodeID.Path, NodeHash: n.Hash, NodeRevision: n.NodeRevision}
+		if _, err := fmt.Fprintf(w, "# Node level=%d index=%d\n%s\n", d.coords[i][0], d.coords[i][1], proto.MarshalTextString(node)); err != nil {
+			return err
+		}
+	}
+	for _, l := range d.Leaves {
+		if _, err := fmt.Fprintf(w, "# LogLeaf\n%s\n", proto.MarshalTextString(l)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeDOT writes the nodes as a Graphviz digraph, with edges from each node to its
+// children and from leaf hashes to the leaves they were computed from.
+func writeDOT(w io.Writer, d *treeDump) error {
+	dotID := func(level, index int64) string { return fmt.Sprintf("n%d_%d", level, index) }
+
+	fmt.Fprintf(w, "digraph tree {\n")
+	fmt.Fprintf(w, "  label=\"size %d, revision %d, root %x\";\n", d.Root.TreeSize, d.Root.TreeRevision, d.Root.RootHash)
+	present := make(map[string]bool)
+	for i := range d.Nodes {
+		present[dotID(d.coords[i][0], d.coords[i][1])] = true
+	}
+	for i, n := range d.Nodes {
+		level, index := d.coords[i][0], d.coords[i][1]
+		fmt.Fprintf(w, "  %s [label=\"%d.%d@%d\\n%s\"];\n", dotID(level, index), level, index, n.NodeRevision, shortHash(n.Hash))
+		if level == 0 {
+			continue
+		}
+		for _, child := range []int64{index * 2, index*2 + 1} {
+			if id := dotID(level-1, child); present[id] {
+				fmt.Fprintf(w, "  %s -> %s;\n", dotID(level, index), id)
+			}
+		}
+	}
+	for _, l := range d.Leaves {
+		fmt.Fprintf(w, "  leaf%d [shape=box,label=\"leaf %d\\n%s\"];\n", l.LeafIndex, l.LeafIndex, shortHash(l.MerkleLeafHash))
+		if id := dotID(0, l.LeafIndex); present[id] {
+			fmt.Fprintf(w, "  %s -> leaf%d;\n", id, l.LeafIndex)
+		}
+	}
+	_, err := fmt.Fprintf(w, "}\n")
+	return err
+}
+
+func shortHash(h []byte) string {
+	if len(h) > 4 {
+		h = h[:4]
+	}
+	return hex.EncodeToString(h)
+}
+
+func main() {
+	flag.Parse()
+
+	writers := map[string]func(io.Writer, *treeDump) error{
+		"json":  writeJSON,
+		"proto": writeProto,
+		"dot":   writeDOT,
+	}
+	write, ok := writers[*formatFlag]
+	if !ok {
+		log.Exitf("Unknown --format %q, want one of json, proto or dot", *formatFlag)
+	}
+	if *minLevelFlag < 0 || *maxLevelFlag < *minLevelFlag {
+		log.Exitf("Invalid level range [%d, %d]", *minLevelFlag, *maxLevelFlag)
+	}
+
+	db, err := mysql.OpenDB(*mySQLURI)
+	if err != nil {
+		log.Exitf("Failed to open MySQL database: %v", err)
+	}
+	defer db.Close()
+
+	tx, err := mysql.NewLogStorage(db).SnapshotForTree(context.Background(), *treeIDFlag)
+	if err != nil {
+		log.Exitf("Failed to start transaction: %v", err)
+	}
+	defer tx.Close()
+
+	root, err := tx.LatestSignedLogRoot()
+	if err != nil {
+		log.Exitf("Failed to read latest signed root: %v", err)
+	}
+	size, revision := *treeSizeFlag, *revisionFlag
+	if size <= 0 || size > root.TreeSize {
+		size = root.TreeSize
+	}
+	if revision < 0 {
+		revision = root.TreeRevision
+	}
+
+	d, err := readTree(tx, root, size, revision, *minLevelFlag, *maxLevelFlag, *leavesFlag)
+	if err != nil {
+		log.Exitf("Failed to read tree %d: %v", *treeIDFlag, err)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Exitf("Failed to commit transaction: %v", err)
+	}
+
+	if err := write(os.Stdout, d); err != nil {
+		log.Exitf("Failed to write output: %v", err)
+	}
+}
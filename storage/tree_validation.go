@@ -15,6 +15,12 @@
 package storage
 
 import (
+	"bytes"
+	"crypto/x509"
+	"net/url"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
 	"github.com/google/trillian"
 	"github.com/google/trillian/crypto/sigpb"
@@ -44,18 +50,37 @@ func ValidateTreeForCreation(tree *trillian.Tree) error {
 		return errors.Errorf(errors.InvalidArgument, "invalid hash_algorithm: %s", tree.HashAlgorithm)
 	case tree.SignatureAlgorithm == sigpb.DigitallySigned_ANONYMOUS:
 		return errors.Errorf(errors.InvalidArgument, "invalid signature_algorithm: %s", tree.SignatureAlgorithm)
-	case tree.DuplicatePolicy == trillian.DuplicatePolicy_UNKNOWN_DUPLICATE_POLICY:
-		return errors.Errorf(errors.InvalidArgument, "invalid duplicate_policy: %s", tree.DuplicatePolicy)
-	case tree.PrivateKey == nil:
-		return errors.New(errors.InvalidArgument, "a private_key is required")
+	case tree.PrivateKey == nil && tree.PublicKey == nil:
+		return errors.New(errors.InvalidArgument, "a private_key or public_key is required")
+	case tree.ShardSetId != 0 && tree.TreeType != trillian.TreeType_LOG:
+		return errors.Errorf(errors.InvalidArgument, "only LOG trees can be sharded, not %s", tree.TreeType)
+	case tree.ShardSetId != 0 && tree.ShardStartMillisSinceEpoch >= tree.ShardEndMillisSinceEpoch:
+		return errors.Errorf(errors.InvalidArgument, "invalid shard window: [%v, %v)", tree.ShardStartMillisSinceEpoch, tree.ShardEndMillisSinceEpoch)
+	case tree.ShardSetId == 0 && (tree.ShardStartMillisSinceEpoch != 0 || tree.ShardEndMillisSinceEpoch != 0):
+		return errors.New(errors.InvalidArgument, "a shard window requires a shard_set_id")
+	case trillian.LeafIdentityHashStrategy_name[int32(tree.LeafIdentityHashStrategy)] == "":
+		return errors.Errorf(errors.InvalidArgument, "invalid leaf_identity_hash_strategy: %v", tree.LeafIdentityHashStrategy)
+	case tree.LeafIdentityHashStrategy != trillian.LeafIdentityHashStrategy_CLIENT_SUPPLIED && tree.TreeType == trillian.TreeType_MAP:
+		return errors.New(errors.InvalidArgument, "only logs have a leaf_identity_hash_strategy")
+	case tree.LeafIdentityHashStrategy == trillian.LeafIdentityHashStrategy_HMAC_SHA256_LEAF_VALUE && len(tree.LeafIdentityHashKey) < minLeafIdentityHashKeyBytes:
+		return errors.Errorf(errors.InvalidArgument, "leaf_identity_hash_key too short, min length is %v", minLeafIdentityHashKeyBytes)
+	case tree.LeafIdentityHashStrategy != trillian.LeafIdentityHashStrategy_HMAC_SHA256_LEAF_VALUE && len(tree.LeafIdentityHashKey) > 0:
+		return errors.Errorf(errors.InvalidArgument, "leaf_identity_hash_key not allowed for %s", tree.LeafIdentityHashStrategy)
 	}
 
 	// Check that the private_key proto contains a valid serialized proto.
 	// TODO(robpercival): Could we attempt to produce an STH at this point,
 	// to verify that the key works?
-	var privateKey ptypes.DynamicAny
-	if err := ptypes.UnmarshalAny(tree.PrivateKey, &privateKey); err != nil {
-		return errors.Errorf(errors.InvalidArgument, "invalid private_key: %v", err)
+	if tree.PrivateKey != nil {
+		var privateKey ptypes.DynamicAny
+		if err := ptypes.UnmarshalAny(tree.PrivateKey, &privateKey); err != nil {
+			return errors.Errorf(errors.InvalidArgument, "invalid private_key: %v", err)
+		}
+	}
+	if tree.PublicKey != nil {
+		if _, err := x509.ParsePKIXPublicKey(tree.PublicKey.GetDer()); err != nil {
+			return errors.Errorf(errors.InvalidArgument, "invalid public_key: %v", err)
+		}
 	}
 
 	return validateMutableTreeFields(tree)
@@ -81,14 +106,24 @@ func ValidateTreeForUpdate(storedTree, newTree *trillian.Tree) error {
 		return errors.New(errors.InvalidArgument, "readonly field changed: hash_algorithm")
 	case storedTree.SignatureAlgorithm != newTree.SignatureAlgorithm:
 		return errors.New(errors.InvalidArgument, "readonly field changed: signature_algorithm")
-	case storedTree.DuplicatePolicy != newTree.DuplicatePolicy:
-		return errors.New(errors.InvalidArgument, "readonly field changed: duplicate_policy")
 	case storedTree.CreateTimeMillisSinceEpoch != newTree.CreateTimeMillisSinceEpoch:
 		return errors.New(errors.InvalidArgument, "readonly field changed: create_time")
 	case storedTree.UpdateTimeMillisSinceEpoch != newTree.UpdateTimeMillisSinceEpoch:
 		return errors.New(errors.InvalidArgument, "readonly field changed: update_time")
 	case storedTree.PrivateKey != newTree.PrivateKey:
 		return errors.New(errors.InvalidArgument, "readonly field changed: private_key")
+	case !proto.Equal(storedTree.PublicKey, newTree.PublicKey):
+		return errors.New(errors.InvalidArgument, "readonly field changed: public_key")
+	case storedTree.ShardSetId != newTree.ShardSetId:
+		return errors.New(errors.InvalidArgument, "readonly field changed: shard_set_id")
+	case storedTree.ShardStartMillisSinceEpoch != newTree.ShardStartMillisSinceEpoch:
+		return errors.New(errors.InvalidArgument, "readonly field changed: shard_start")
+	case storedTree.ShardEndMillisSinceEpoch != newTree.ShardEndMillisSinceEpoch:
+		return errors.New(errors.InvalidArgument, "readonly field changed: shard_end")
+	case storedTree.LeafIdentityHashStrategy != newTree.LeafIdentityHashStrategy:
+		return errors.New(errors.InvalidArgument, "readonly field changed: leaf_identity_hash_strategy")
+	case !bytes.Equal(storedTree.LeafIdentityHashKey, newTree.LeafIdentityHashKey):
+		return errors.New(errors.InvalidArgument, "readonly field changed: leaf_identity_hash_key")
 	}
 	return validateMutableTreeFields(newTree)
 }
@@ -97,10 +132,44 @@ func validateMutableTreeFields(tree *trillian.Tree) error {
 	switch {
 	case tree.TreeState == trillian.TreeState_UNKNOWN_TREE_STATE:
 		return errors.Errorf(errors.InvalidArgument, "invalid tree_state: %v", tree.TreeState)
+	case tree.DuplicatePolicy == trillian.DuplicatePolicy_UNKNOWN_DUPLICATE_POLICY || trillian.DuplicatePolicy_name[int32(tree.DuplicatePolicy)] == "":
+		return errors.Errorf(errors.InvalidArgument, "invalid duplicate_policy: %v", tree.DuplicatePolicy)
 	case len(tree.DisplayName) > maxDisplayNameLength:
 		return errors.Errorf(errors.InvalidArgument, "display_name too big, max length is %v: %v", maxDisplayNameLength, tree.DisplayName)
 	case len(tree.Description) > maxDescriptionLength:
 		return errors.Errorf(errors.InvalidArgument, "description too big, max length is %v: %v", maxDescriptionLength, tree.Description)
+	case tree.SequencingBatchSize < 0:
+		return errors.Errorf(errors.InvalidArgument, "invalid sequencing_batch_size: %v", tree.SequencingBatchSize)
+	case tree.SequencingIntervalSeconds < 0:
+		return errors.Errorf(errors.InvalidArgument, "invalid sequencing_interval_seconds: %v", tree.SequencingIntervalSeconds)
+	case tree.SequencingGuardWindowSeconds < 0:
+		return errors.Errorf(errors.InvalidArgument, "invalid sequencing_guard_window_seconds: %v", tree.SequencingGuardWindowSeconds)
+	case tree.SequencingPriority < 0:
+		return errors.Errorf(errors.InvalidArgument, "invalid sequencing_priority: %v", tree.SequencingPriority)
+	case tree.MaxMergeDelaySeconds < 0:
+		return errors.Errorf(errors.InvalidArgument, "invalid max_merge_delay_seconds: %v", tree.MaxMergeDelaySeconds)
+	case tree.MaxMergeDelaySeconds > 0 && tree.SequencingGuardWindowSeconds >= tree.MaxMergeDelaySeconds:
+		// Leaves within the guard window aren't sequenced, so they'd always miss it.
+		return errors.Errorf(errors.InvalidArgument, "max_merge_delay_seconds (%v) must be greater than sequencing_guard_window_seconds (%v)", tree.MaxMergeDelaySeconds, tree.SequencingGuardWindowSeconds)
+	case trillian.LeafCompression_name[int32(tree.LeafCompression)] == "":
+		return errors.Errorf(errors.InvalidArgument, "invalid leaf_compression: %v", tree.LeafCompression)
+	case tree.LeafRetentionSeconds < 0:
+		return errors.Errorf(errors.InvalidArgument, "invalid leaf_retention_seconds: %v", tree.LeafRetentionSeconds)
+	case tree.LeafRetentionSeconds > 0 && tree.TreeType == trillian.TreeType_MAP:
+		return errors.New(errors.InvalidArgument, "only logs have leaf_retention_seconds")
+	case tree.FinalizeTimeMillisSinceEpoch != 0 && tree.TreeType == trillian.TreeType_MAP:
+		return errors.New(errors.InvalidArgument, "only logs can be finalized")
+	case tree.FinalizeTimeMillisSinceEpoch != 0 && tree.TreeState != trillian.TreeState_FROZEN:
+		return errors.Errorf(errors.InvalidArgument, "only FROZEN logs can be finalized, not %s ones", tree.TreeState)
+	case tree.FinalizedTreeSize < 0:
+		return errors.Errorf(errors.InvalidArgument, "invalid finalized_tree_size: %v", tree.FinalizedTreeSize)
+	case len(tree.AllowedWriters) > 0 && tree.TreeType == trillian.TreeType_MAP:
+		return errors.New(errors.InvalidArgument, "only logs have allowed_writers")
+	}
+	for _, id := range tree.AllowedWriters {
+		if u, err := url.Parse(id); err != nil || !strings.EqualFold(u.Scheme, "spiffe") || u.Host == "" {
+			return errors.Errorf(errors.InvalidArgument, "invalid allowed_writers, want SPIFFE IDs: %q", id)
+		}
 	}
 	return nil
 }
@@ -15,6 +15,10 @@
 package storage
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
 	"testing"
 
 	"github.com/golang/protobuf/ptypes"
@@ -63,6 +67,49 @@ func TestValidateTreeForCreation(t *testing.T) {
 		A Very Long Description That Clearly Won't Fit, Also Mentions Llamas, For Some Reason Has Only Capitalized Words And Keeps Repeating Itself.
 		`
 
+	invalidBatchSize := newTree()
+	invalidBatchSize.SequencingBatchSize = -1
+
+	invalidInterval := newTree()
+	invalidInterval.SequencingIntervalSeconds = -1
+
+	invalidGuardWindow := newTree()
+	invalidGuardWindow.SequencingGuardWindowSeconds = -1
+
+	invalidPriority := newTree()
+	invalidPriority.SequencingPriority = -1
+
+	mergeDelay := newTree()
+	mergeDelay.SequencingGuardWindowSeconds = 10
+	mergeDelay.MaxMergeDelaySeconds = 60
+
+	invalidMergeDelay := newTree()
+	invalidMergeDelay.MaxMergeDelaySeconds = -1
+
+	mergeDelayInGuardWindow := newTree()
+	mergeDelayInGuardWindow.SequencingGuardWindowSeconds = 60
+	mergeDelayInGuardWindow.MaxMergeDelaySeconds = 60
+
+	invalidCompression := newTree()
+	invalidCompression.LeafCompression = trillian.LeafCompression(-1)
+
+	invalidRetention := newTree()
+	invalidRetention.LeafRetentionSeconds = -1
+
+	mapRetention := newTree()
+	mapRetention.TreeType = trillian.TreeType_MAP
+	mapRetention.LeafRetentionSeconds = 3600
+
+	allowedWriters := newTree()
+	allowedWriters.AllowedWriters = []string{"spiffe://example.org/ct/", "spiffe://example.org/mirror"}
+
+	invalidWriter := newTree()
+	invalidWriter.AllowedWriters = []string{"https://example.org/ct/"}
+
+	mapWriters := newTree()
+	mapWriters.TreeType = trillian.TreeType_MAP
+	mapWriters.AllowedWriters = []string{"spiffe://example.org/ct/"}
+
 	unsupportedKey := newTree()
 	unsupportedKey.PrivateKey.TypeUrl = "urn://unknown-type"
 
@@ -72,6 +119,57 @@ func TestValidateTreeForCreation(t *testing.T) {
 	nilKey := newTree()
 	nilKey.PrivateKey = nil
 
+	verificationOnly := newTree()
+	verificationOnly.PrivateKey = nil
+	verificationOnly.PublicKey = newPublicKey()
+
+	withPublicKey := newTree()
+	withPublicKey.PublicKey = newPublicKey()
+
+	invalidPublicKey := newTree()
+	invalidPublicKey.PrivateKey = nil
+	invalidPublicKey.PublicKey = &trillian.PublicKey{Der: []byte("foobar")}
+
+	shard := newTree()
+	shard.ShardSetId = 1
+	shard.ShardStartMillisSinceEpoch = 1000
+	shard.ShardEndMillisSinceEpoch = 2000
+
+	invalidShardType := newTree()
+	invalidShardType.TreeType = trillian.TreeType_PREORDERED_LOG
+	invalidShardType.ShardSetId = 1
+	invalidShardType.ShardEndMillisSinceEpoch = 2000
+
+	invalidShardWindow := newTree()
+	invalidShardWindow.ShardSetId = 1
+	invalidShardWindow.ShardStartMillisSinceEpoch = 2000
+	invalidShardWindow.ShardEndMillisSinceEpoch = 2000
+
+	windowWithoutShardSet := newTree()
+	windowWithoutShardSet.ShardEndMillisSinceEpoch = 2000
+
+	identityHash := newTree()
+	identityHash.LeafIdentityHashStrategy = trillian.LeafIdentityHashStrategy_SHA256_LEAF_VALUE_AND_EXTRA_DATA
+
+	keyedIdentityHash := newTree()
+	keyedIdentityHash.LeafIdentityHashStrategy = trillian.LeafIdentityHashStrategy_HMAC_SHA256_LEAF_VALUE
+	keyedIdentityHash.LeafIdentityHashKey = make([]byte, 32)
+
+	invalidIdentityHash := newTree()
+	invalidIdentityHash.LeafIdentityHashStrategy = trillian.LeafIdentityHashStrategy(-1)
+
+	mapIdentityHash := newTree()
+	mapIdentityHash.TreeType = trillian.TreeType_MAP
+	mapIdentityHash.LeafIdentityHashStrategy = trillian.LeafIdentityHashStrategy_SHA256_LEAF_VALUE
+
+	shortIdentityHashKey := newTree()
+	shortIdentityHashKey.LeafIdentityHashStrategy = trillian.LeafIdentityHashStrategy_HMAC_SHA256_LEAF_VALUE
+	shortIdentityHashKey.LeafIdentityHashKey = make([]byte, 16)
+
+	unkeyedIdentityHashKey := newTree()
+	unkeyedIdentityHashKey.LeafIdentityHashStrategy = trillian.LeafIdentityHashStrategy_SHA256_LEAF_VALUE
+	unkeyedIdentityHashKey.LeafIdentityHashKey = make([]byte, 32)
+
 	tests := []struct {
 		desc    string
 		tree    *trillian.Tree
@@ -145,6 +243,69 @@ func TestValidateTreeForCreation(t *testing.T) {
 			tree:    invalidDescription,
 			wantErr: true,
 		},
+		{
+			desc:    "invalidBatchSize",
+			tree:    invalidBatchSize,
+			wantErr: true,
+		},
+		{
+			desc:    "invalidInterval",
+			tree:    invalidInterval,
+			wantErr: true,
+		},
+		{
+			desc:    "invalidGuardWindow",
+			tree:    invalidGuardWindow,
+			wantErr: true,
+		},
+		{
+			desc:    "invalidPriority",
+			tree:    invalidPriority,
+			wantErr: true,
+		},
+		{
+			desc: "mergeDelay",
+			tree: mergeDelay,
+		},
+		{
+			desc:    "invalidMergeDelay",
+			tree:    invalidMergeDelay,
+			wantErr: true,
+		},
+		{
+			desc:    "mergeDelayInGuardWindow",
+			tree:    mergeDelayInGuardWindow,
+			wantErr: true,
+		},
+		{
+			desc:    "invalidCompression",
+			tree:    invalidCompression,
+			wantErr: true,
+		},
+		{
+			desc:    "invalidRetention",
+			tree:    invalidRetention,
+			wantErr: true,
+		},
+		{
+			desc:    "mapRetention",
+			tree:    mapRetention,
+			wantErr: true,
+		},
+		{
+			desc: "allowedWriters",
+			tree: allowedWriters,
+		},
+		{
+			desc:    "invalidWriter",
+			tree:    invalidWriter,
+			wantErr: true,
+		},
+		{
+			desc:    "mapWriters",
+			tree:    mapWriters,
+			wantErr: true,
+		},
 		{
 			desc:    "unsupportedKey",
 			tree:    unsupportedKey,
@@ -160,6 +321,66 @@ func TestValidateTreeForCreation(t *testing.T) {
 			tree:    nilKey,
 			wantErr: true,
 		},
+		{
+			desc: "verificationOnly",
+			tree: verificationOnly,
+		},
+		{
+			desc: "withPublicKey",
+			tree: withPublicKey,
+		},
+		{
+			desc:    "invalidPublicKey",
+			tree:    invalidPublicKey,
+			wantErr: true,
+		},
+		{
+			desc: "shard",
+			tree: shard,
+		},
+		{
+			desc:    "invalidShardType",
+			tree:    invalidShardType,
+			wantErr: true,
+		},
+		{
+			desc:    "invalidShardWindow",
+			tree:    invalidShardWindow,
+			wantErr: true,
+		},
+		{
+			desc:    "windowWithoutShardSet",
+			tree:    windowWithoutShardSet,
+			wantErr: true,
+		},
+		{
+			desc: "identityHash",
+			tree: identityHash,
+		},
+		{
+			desc: "keyedIdentityHash",
+			tree: keyedIdentityHash,
+		},
+		{
+			desc:    "invalidIdentityHash",
+			tree:    invalidIdentityHash,
+			wantErr: true,
+		},
+		{
+			desc:    "mapIdentityHash",
+			tree:    mapIdentityHash,
+			wantErr: true,
+		},
+		{
+			desc:    "shortIdentityHashKey",
+			tree:    shortIdentityHashKey,
+			wantErr: true,
+		},
+		{
+			desc:    "unkeyedIdentityHashKey",
+			tree:    unkeyedIdentityHashKey,
+			wantErr: true,
+		},
 	}
 	for i, test := range tests {
 		err := ValidateTreeForCreation(test.tree)
@@ -186,6 +407,56 @@ func TestValidateTreeForUpdate(t *testing.T) {
 				tree.Description = "A Frozen Tree"
 			},
 		},
+		{
+			desc: "retention",
+			updatefn: func(tree *trillian.Tree) {
+				tree.LeafRetentionSeconds = 30 * 24 * 3600
+			},
+		},
+		{
+			desc: "allowedWriters",
+			updatefn: func(tree *trillian.Tree) {
+				tree.AllowedWriters = []string{"spiffe://example.org/ct/"}
+			},
+		},
+		{
+			desc: "duplicatePolicy",
+			updatefn: func(tree *trillian.Tree) {
+				tree.DuplicatePolicy = trillian.DuplicatePolicy_DUPLICATES_ALLOWED
+			},
+		},
+		{
+			desc: "unknownDuplicatePolicy",
+			updatefn: func(tree *trillian.Tree) {
+				tree.DuplicatePolicy = trillian.DuplicatePolicy_UNKNOWN_DUPLICATE_POLICY
+			},
+			wantErr: true,
+		},
+		{
+			desc: "finalized",
+			updatefn: func(tree *trillian.Tree) {
+				tree.TreeState = trillian.TreeState_FROZEN
+				tree.FinalizeTimeMillisSinceEpoch = 1000
+				tree.FinalizedTreeSize = 10
+			},
+		},
+		{
+			desc: "activeFinalized",
+			updatefn: func(tree *trillian.Tree) {
+				tree.FinalizeTimeMillisSinceEpoch = 1000
+				tree.FinalizedTreeSize = 10
+			},
+			wantErr: true,
+		},
+		{
+			desc: "invalidFinalizedSize",
+			updatefn: func(tree *trillian.Tree) {
+				tree.TreeState = trillian.TreeState_FROZEN
+				tree.FinalizeTimeMillisSinceEpoch = 1000
+				tree.FinalizedTreeSize = -1
+			},
+			wantErr: true,
+		},
 		{
 			desc:     "noop",
 			updatefn: func(tree *trillian.Tree) {},
@@ -226,13 +497,6 @@ func TestValidateTreeForUpdate(t *testing.T) {
 			},
 			wantErr: true,
 		},
-		{
-			desc: "DuplicatePolicy",
-			updatefn: func(tree *trillian.Tree) {
-				tree.DuplicatePolicy = trillian.DuplicatePolicy_DUPLICATES_ALLOWED
-			},
-			wantErr: true,
-		},
 		{
 			desc: "CreateTime",
 			updatefn: func(tree *trillian.Tree) {
@@ -260,6 +524,41 @@ func TestValidateTreeForUpdate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			desc: "PublicKey",
+			updatefn: func(tree *trillian.Tree) {
+				tree.PublicKey = newPublicKey()
+			},
+			wantErr: true,
+		},
+		{
+			desc: "ShardSetId",
+			updatefn: func(tree *trillian.Tree) {
+				tree.ShardSetId = 1
+			},
+			wantErr: true,
+		},
+		{
+			desc: "ShardEnd",
+			updatefn: func(tree *trillian.Tree) {
+				tree.ShardEndMillisSinceEpoch++
+			},
+			wantErr: true,
+		},
+		{
+			desc: "LeafIdentityHashStrategy",
+			updatefn: func(tree *trillian.Tree) {
+				tree.LeafIdentityHashStrategy = trillian.LeafIdentityHashStrategy_SHA256_LEAF_VALUE
+			},
+			wantErr: true,
+		},
+		{
+			desc: "LeafIdentityHashKey",
+			updatefn: func(tree *trillian.Tree) {
+				tree.LeafIdentityHashKey = []byte("key")
+			},
+			wantErr: true,
+		},
 	}
 	for _, test := range tests {
 		tree := newTree()
@@ -298,3 +597,16 @@ func newTree() *trillian.Tree {
 		PrivateKey:         privateKey,
 	}
 }
+
+// newPublicKey returns a valid public key for tests.
+func newPublicKey() *trillian.PublicKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		panic(err)
+	}
+	return &trillian.PublicKey{Der: der}
+}
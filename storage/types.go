@@ -24,6 +24,16 @@ import (
 // Integer types to distinguish storage errors that might need to be mapped at a higher level.
 const (
 	DuplicateLeaf = iota
+	// TreeNotFound means the tree doesn't exist.
+	TreeNotFound
+	// OutOfRange means a leaf index or tree size is beyond what the tree holds.
+	OutOfRange
+	// TransientFailure means the operation failed in a way which may not happen if it's
+	// retried, e.g. it kept losing deadlocks.
+	TransientFailure
+	// TreeNotWritable means leaves can't be added to the tree because it isn't ACTIVE,
+	// e.g. it has been frozen, or because it's verification-only.
+	TreeNotWritable
 )
 
 // Error is a typed error that the storage layer can return to give callers information
@@ -89,7 +89,7 @@ func NewLogEnv(ctx context.Context, numSequencers int, testID string) (*LogEnv,
 
 	registry := extension.Registry{
 		AdminStorage:  mysql.NewAdminStorage(db),
-		SignerFactory: keys.PEMSignerFactory{},
+		SignerFactory: keys.ProtoSignerFactory{},
 		LogStorage:    mysql.NewLogStorage(db),
 	}
 
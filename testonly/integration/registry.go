@@ -30,7 +30,7 @@ func NewRegistryForTests(testID string) (extension.Registry, error) {
 
 	return extension.Registry{
 		AdminStorage:  mysql.NewAdminStorage(db),
-		SignerFactory: keys.PEMSignerFactory{},
+		SignerFactory: keys.ProtoSignerFactory{},
 		LogStorage:    mysql.NewLogStorage(db),
 		MapStorage:    mysql.NewMapStorage(db),
 	}, nil
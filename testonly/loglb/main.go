@@ -17,6 +17,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"math/rand"
 	"net"
 	"net/http"
@@ -92,6 +93,24 @@ func (lb *randomLoadBalancer) QueueLeaves(ctx context.Context, req *trillian.Que
 	return bc.client.QueueLeaves(ctx, req)
 }
 
+func (lb *randomLoadBalancer) AddSequencedLeaves(ctx context.Context, req *trillian.AddSequencedLeavesRequest) (*trillian.AddSequencedLeavesResponse, error) {
+	bc := lb.pick()
+	glog.V(3).Infof("forward AddSequencedLeaves request to backend %s", bc.server)
+	return bc.client.AddSequencedLeaves(ctx, req)
+}
+
+func (lb *randomLoadBalancer) AddWitnessSignature(ctx context.Context, req *trillian.AddWitnessSignatureRequest) (*trillian.AddWitnessSignatureResponse, error) {
+	bc := lb.pick()
+	glog.V(3).Infof("forward AddWitnessSignature request to backend %s", bc.server)
+	return bc.client.AddWitnessSignature(ctx, req)
+}
+
+func (lb *randomLoadBalancer) AddObservedRoot(ctx context.Context, req *trillian.AddObservedRootRequest) (*trillian.AddObservedRootResponse, error) {
+	bc := lb.pick()
+	glog.V(3).Infof("forward AddObservedRoot request to backend %s", bc.server)
+	return bc.client.AddObservedRoot(ctx, req)
+}
+
 func (lb *randomLoadBalancer) GetInclusionProof(ctx context.Context, req *trillian.GetInclusionProofRequest) (*trillian.GetInclusionProofResponse, error) {
 	bc := lb.pick()
 	glog.V(3).Infof("forward GetInclusionProof request to backend %s", bc.server)
@@ -110,6 +129,27 @@ func (lb *randomLoadBalancer) GetConsistencyProof(ctx context.Context, req *tril
 	return bc.client.GetConsistencyProof(ctx, req)
 }
 
+func (lb *randomLoadBalancer) GetConsistencyProofHistory(req *trillian.GetConsistencyProofHistoryRequest, stream trillian.TrillianLog_GetConsistencyProofHistoryServer) error {
+	bc := lb.pick()
+	glog.V(3).Infof("forward GetConsistencyProofHistory request to backend %s", bc.server)
+	c, err := bc.client.GetConsistencyProofHistory(stream.Context(), req)
+	if err != nil {
+		return err
+	}
+	for {
+		rsp, err := c.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(rsp); err != nil {
+			return err
+		}
+	}
+}
+
 func (lb *randomLoadBalancer) GetLatestSignedLogRoot(ctx context.Context, req *trillian.GetLatestSignedLogRootRequest) (*trillian.GetLatestSignedLogRootResponse, error) {
 	bc := lb.pick()
 	glog.V(3).Infof("forward GetLatestSignedLogRoot request to backend %s", bc.server)
@@ -128,6 +168,27 @@ func (lb *randomLoadBalancer) GetLeavesByIndex(ctx context.Context, req *trillia
 	return bc.client.GetLeavesByIndex(ctx, req)
 }
 
+func (lb *randomLoadBalancer) GetLeavesByIndexStream(req *trillian.GetLeavesByIndexRequest, stream trillian.TrillianLog_GetLeavesByIndexStreamServer) error {
+	bc := lb.pick()
+	glog.V(3).Infof("forward GetLeavesByIndexStream request to backend %s", bc.server)
+	c, err := bc.client.GetLeavesByIndexStream(stream.Context(), req)
+	if err != nil {
+		return err
+	}
+	for {
+		rsp, err := c.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(rsp); err != nil {
+			return err
+		}
+	}
+}
+
 func (lb *randomLoadBalancer) GetLeavesByHash(ctx context.Context, req *trillian.GetLeavesByHashRequest) (*trillian.GetLeavesByHashResponse, error) {
 	bc := lb.pick()
 	glog.V(3).Infof("forward GetLeavesByHash request to backend %s", bc.server)
@@ -96,17 +96,22 @@ const (
 	TreeType_LOG TreeType = 1
 	// Tree represents a verifiable map.
 	TreeType_MAP TreeType = 2
+	// Tree represents a verifiable log whose leaf indices are assigned by the
+	// submitter rather than by Trillian, e.g. a mirror of another log.
+	TreeType_PREORDERED_LOG TreeType = 3
 )
 
 var TreeType_name = map[int32]string{
 	0: "UNKNOWN_TREE_TYPE",
 	1: "LOG",
 	2: "MAP",
+	3: "PREORDERED_LOG",
 }
 var TreeType_value = map[string]int32{
 	"UNKNOWN_TREE_TYPE": 0,
 	"LOG":               1,
 	"MAP":               2,
+	"PREORDERED_LOG":    3,
 }
 
 func (x TreeType) String() string {
@@ -123,7 +128,9 @@ const (
 	DuplicatePolicy_UNKNOWN_DUPLICATE_POLICY DuplicatePolicy = 0
 	// Duplicates are not allowed in the tree and will cause errors on insertion.
 	DuplicatePolicy_DUPLICATES_NOT_ALLOWED DuplicatePolicy = 1
-	// Duplicates are allowed in the tree.
+	// Duplicates are allowed in the tree: a leaf is sequenced every time it's
+	// queued, e.g. to record each time an entry was seen. Duplicates share the
+	// value and extra data stored for the leaf when it was first queued.
 	DuplicatePolicy_DUPLICATES_ALLOWED DuplicatePolicy = 2
 )
 
@@ -143,6 +150,71 @@ func (x DuplicatePolicy) String() string {
 }
 func (DuplicatePolicy) EnumDescriptor() ([]byte, []int) { return fileDescriptor3, []int{3} }
 
+// Compression of the leaf data stored by a log.
+type LeafCompression int32
+
+const (
+	// Leaf data is stored as submitted.
+	LeafCompression_UNCOMPRESSED LeafCompression = 0
+	// Leaf data is compressed with Snappy.
+	LeafCompression_SNAPPY LeafCompression = 1
+	// Leaf data is compressed with Zstandard.
+	LeafCompression_ZSTD LeafCompression = 2
+)
+
+var LeafCompression_name = map[int32]string{
+	0: "UNCOMPRESSED",
+	1: "SNAPPY",
+	2: "ZSTD",
+}
+var LeafCompression_value = map[string]int32{
+	"UNCOMPRESSED": 0,
+	"SNAPPY":       1,
+	"ZSTD":         2,
+}
+
+func (x LeafCompression) String() string {
+	return proto.EnumName(LeafCompression_name, int32(x))
+}
+func (LeafCompression) EnumDescriptor() ([]byte, []int) { return fileDescriptor3, []int{4} }
+
+// How a log computes the identity hash of its leaves, which duplicate leaves are
+// detected by.
+type LeafIdentityHashStrategy int32
+
+const (
+	// The leaf_identity_hash supplied with each leaf is used as is.
+	LeafIdentityHashStrategy_CLIENT_SUPPLIED LeafIdentityHashStrategy = 0
+	// SHA-256 of the leaf_value.
+	LeafIdentityHashStrategy_SHA256_LEAF_VALUE LeafIdentityHashStrategy = 1
+	// SHA-256 of the leaf_value, as a big-endian 64-bit length followed by the
+	// value, and the extra_data, so leaves which only differ in their extra_data
+	// aren't duplicates.
+	LeafIdentityHashStrategy_SHA256_LEAF_VALUE_AND_EXTRA_DATA LeafIdentityHashStrategy = 2
+	// HMAC-SHA256 of the leaf_value, keyed by the tree's leaf_identity_hash_key,
+	// so the identity hashes of a log can't be correlated with those of logs
+	// holding the same entries.
+	LeafIdentityHashStrategy_HMAC_SHA256_LEAF_VALUE LeafIdentityHashStrategy = 3
+)
+
+var LeafIdentityHashStrategy_name = map[int32]string{
+	0: "CLIENT_SUPPLIED",
+	1: "SHA256_LEAF_VALUE",
+	2: "SHA256_LEAF_VALUE_AND_EXTRA_DATA",
+	3: "HMAC_SHA256_LEAF_VALUE",
+}
+var LeafIdentityHashStrategy_value = map[string]int32{
+	"CLIENT_SUPPLIED":                  0,
+	"SHA256_LEAF_VALUE":                1,
+	"SHA256_LEAF_VALUE_AND_EXTRA_DATA": 2,
+	"HMAC_SHA256_LEAF_VALUE":           3,
+}
+
+func (x LeafIdentityHashStrategy) String() string {
+	return proto.EnumName(LeafIdentityHashStrategy_name, int32(x))
+}
+func (LeafIdentityHashStrategy) EnumDescriptor() ([]byte, []int) { return fileDescriptor3, []int{5} }
+
 // Represents a tree, which may be either a verifiable log or map.
 // Readonly attributes are assigned at tree creation, after which they may not
 // be modified.
@@ -168,8 +240,8 @@ type Tree struct {
 	// Signature algorithm to be used by the tree.
 	// Readonly.
 	SignatureAlgorithm sigpb.DigitallySigned_SignatureAlgorithm `protobuf:"varint,6,opt,name=signature_algorithm,json=signatureAlgorithm,enum=sigpb.DigitallySigned_SignatureAlgorithm" json:"signature_algorithm,omitempty"`
-	// Duplicate policy to be used by the tree.
-	// Readonly.
+	// Duplicate policy to be used by the tree. Changing it only affects leaves
+	// queued afterwards.
 	DuplicatePolicy DuplicatePolicy `protobuf:"varint,7,opt,name=duplicate_policy,json=duplicatePolicy,enum=trillian.DuplicatePolicy" json:"duplicate_policy,omitempty"`
 	// Display name of the tree.
 	// Optional.
@@ -193,6 +265,95 @@ type Tree struct {
 	// mutable. It should be mutable in the sense that the key can be migrated to
 	// a different key management system, but the key itself should never change.
 	PrivateKey *google_protobuf.Any `protobuf:"bytes,12,opt,name=private_key,json=privateKey" json:"private_key,omitempty"`
+	// Maximum number of leaves the signer sequences per pass for the tree.
+	// Optional, the signer's --batch_size is used if zero.
+	SequencingBatchSize int32 `protobuf:"varint,13,opt,name=sequencing_batch_size,json=sequencingBatchSize" json:"sequencing_batch_size,omitempty"`
+	// Minimum time, in seconds, between sequencing passes for the tree. Trees
+	// with a backlog of queued leaves are sequenced on every signer pass
+	// regardless.
+	// Optional, every signer pass sequences the tree if zero.
+	SequencingIntervalSeconds int32 `protobuf:"varint,14,opt,name=sequencing_interval_seconds,json=sequencingIntervalSeconds" json:"sequencing_interval_seconds,omitempty"`
+	// Minimum time, in seconds, leaves have to be queued for before the signer
+	// integrates them into the tree, e.g. to allow for deduplication upstream.
+	// Optional, the signer's --sequencer_guard_window is used if zero.
+	SequencingGuardWindowSeconds int32 `protobuf:"varint,15,opt,name=sequencing_guard_window_seconds,json=sequencingGuardWindowSeconds" json:"sequencing_guard_window_seconds,omitempty"`
+	// Compression applied to the leaf values and extra data stored by a log.
+	// Changing it only affects leaves added afterwards. Leaves are always
+	// returned uncompressed.
+	// Optional, leaf data is stored uncompressed by default.
+	LeafCompression LeafCompression `protobuf:"varint,16,opt,name=leaf_compression,json=leafCompression,enum=trillian.LeafCompression" json:"leaf_compression,omitempty"`
+	// Identifies the shard set a log belongs to, if it's one of the time-based
+	// shards of a larger log. The shards of a set have validity windows which
+	// don't overlap: leaves queued to any of them go to the shard whose window
+	// contains the current time, leaf hash lookups search all of them, and each
+	// shard is frozen once its window has ended and its queue is empty.
+	// Optional, zero means the log isn't sharded. Only LOG trees can be sharded.
+	// Readonly.
+	ShardSetId int64 `protobuf:"varint,17,opt,name=shard_set_id,json=shardSetId" json:"shard_set_id,omitempty"`
+	// Start of the shard's validity window, inclusive.
+	// Required for shards, readonly.
+	ShardStartMillisSinceEpoch int64 `protobuf:"varint,18,opt,name=shard_start_millis_since_epoch,json=shardStartMillisSinceEpoch" json:"shard_start_millis_since_epoch,omitempty"`
+	// End of the shard's validity window, exclusive.
+	// Required for shards, readonly.
+	ShardEndMillisSinceEpoch int64 `protobuf:"varint,19,opt,name=shard_end_millis_since_epoch,json=shardEndMillisSinceEpoch" json:"shard_end_millis_since_epoch,omitempty"`
+	// How long, in seconds, the values and extra data of a log's leaves are kept
+	// for after they're integrated into the tree. Once expired, leaves are
+	// returned with empty leaf_value and extra_data and expired set, while their
+	// hashes and index are kept, so inclusion and consistency proofs stay valid.
+	// Leaf data is pruned periodically by the signer, so it may be returned for a
+	// while after it has expired, also from log server caches. Data is kept
+	// while an entry of the same leaf is queued or still within the window.
+	// Optional, leaves are kept forever if zero. Only logs have leaf retention.
+	LeafRetentionSeconds int32 `protobuf:"varint,20,opt,name=leaf_retention_seconds,json=leafRetentionSeconds" json:"leaf_retention_seconds,omitempty"`
+	// Time at which a FROZEN log was finalized: once its queue has been drained,
+	// the signer signs one final root, of size finalized_tree_size, so readers
+	// can tell the log won't grow any further. Setting the tree_state of a log
+	// back to ACTIVE, or deleting it, clears it.
+	// Zero if the log hasn't been finalized. Set by the signer, readonly.
+	FinalizeTimeMillisSinceEpoch int64 `protobuf:"varint,21,opt,name=finalize_time_millis_since_epoch,json=finalizeTimeMillisSinceEpoch" json:"finalize_time_millis_since_epoch,omitempty"`
+	// Size of the final root of a finalized log.
+	// Set by the signer, readonly.
+	FinalizedTreeSize int64 `protobuf:"varint,22,opt,name=finalized_tree_size,json=finalizedTreeSize" json:"finalized_tree_size,omitempty"`
+	// Public key of the tree's signing key. Trees with a public key but no
+	// private_key are verification-only: they can't be sequenced or signed for,
+	// but keep the roots of a log run elsewhere, verified with their public key
+	// and imported with AddObservedRoot, and are served as usual.
+	// Required if private_key isn't set, readonly.
+	PublicKey *PublicKey `protobuf:"bytes,23,opt,name=public_key,json=publicKey" json:"public_key,omitempty"`
+	// Weight of the tree when the signer shares each sequencing pass between
+	// trees with --sequencer_fairness=weighted: trees with a higher priority are
+	// sequenced first, and may sequence proportionally more leaves per pass.
+	// Optional, zero is treated as a priority of 1.
+	SequencingPriority int32 `protobuf:"varint,24,opt,name=sequencing_priority,json=sequencingPriority" json:"sequencing_priority,omitempty"`
+	// Maximum merge delay of a log: the longest, in seconds, the server commits to
+	// taking to integrate a queued leaf, so personalities can promise (e.g. in an
+	// SCT) that it'll be in the tree by its queue timestamp plus this. It's returned
+	// in QueueLeaves responses, and the signer sequences logs at risk of missing it
+	// straight away, and counts the passes they miss it in.
+	// Optional, the log has no maximum merge delay if zero. Must be greater than
+	// sequencing_guard_window_seconds if both are set.
+	MaxMergeDelaySeconds int32 `protobuf:"varint,25,opt,name=max_merge_delay_seconds,json=maxMergeDelaySeconds" json:"max_merge_delay_seconds,omitempty"`
+	// Strategy the identity hashes of a log's leaves are computed with. Unless
+	// it's CLIENT_SUPPLIED, the leaf_identity_hash of queued and added leaves is
+	// overwritten with the one computed by the log, which is what they're
+	// deduplicated by and returned with.
+	// Optional, leaf identity hashes are CLIENT_SUPPLIED by default. Only logs
+	// have a leaf identity hash strategy. Readonly.
+	LeafIdentityHashStrategy LeafIdentityHashStrategy `protobuf:"varint,26,opt,name=leaf_identity_hash_strategy,json=leafIdentityHashStrategy,enum=trillian.LeafIdentityHashStrategy" json:"leaf_identity_hash_strategy,omitempty"`
+	// Key of the HMAC_SHA256_LEAF_VALUE leaf identity hash strategy, at least 32
+	// bytes long.
+	// Required for that strategy and not allowed for others. Keys are
+	// write-only: they're never returned by RPCs. Readonly.
+	LeafIdentityHashKey []byte `protobuf:"bytes,27,opt,name=leaf_identity_hash_key,json=leafIdentityHashKey,proto3" json:"leaf_identity_hash_key,omitempty"`
+	// SPIFFE IDs of the clients allowed to queue and add leaves to a log, when
+	// the log server authenticates clients with SPIFFE. An ID ending in "/"
+	// allows every ID it's a prefix of. Any authorized client may still read the
+	// log.
+	// Leaves queued to any shard of a set go to its active shard, so with
+	// sharding enabled it's the active shard's writers that apply.
+	// Optional, any authorized client may write to the log if empty. Only logs
+	// have allowed writers.
+	AllowedWriters []string `protobuf:"bytes,28,rep,name=allowed_writers,json=allowedWriters" json:"allowed_writers,omitempty"`
 }
 
 func (m *Tree) Reset()                    { *m = Tree{} }
@@ -284,6 +445,118 @@ func (m *Tree) GetPrivateKey() *google_protobuf.Any {
 	return nil
 }
 
+func (m *Tree) GetSequencingBatchSize() int32 {
+	if m != nil {
+		return m.SequencingBatchSize
+	}
+	return 0
+}
+
+func (m *Tree) GetSequencingIntervalSeconds() int32 {
+	if m != nil {
+		return m.SequencingIntervalSeconds
+	}
+	return 0
+}
+
+func (m *Tree) GetSequencingGuardWindowSeconds() int32 {
+	if m != nil {
+		return m.SequencingGuardWindowSeconds
+	}
+	return 0
+}
+
+func (m *Tree) GetLeafCompression() LeafCompression {
+	if m != nil {
+		return m.LeafCompression
+	}
+	return LeafCompression_UNCOMPRESSED
+}
+
+func (m *Tree) GetShardSetId() int64 {
+	if m != nil {
+		return m.ShardSetId
+	}
+	return 0
+}
+
+func (m *Tree) GetShardStartMillisSinceEpoch() int64 {
+	if m != nil {
+		return m.ShardStartMillisSinceEpoch
+	}
+	return 0
+}
+
+func (m *Tree) GetShardEndMillisSinceEpoch() int64 {
+	if m != nil {
+		return m.ShardEndMillisSinceEpoch
+	}
+	return 0
+}
+
+func (m *Tree) GetLeafRetentionSeconds() int32 {
+	if m != nil {
+		return m.LeafRetentionSeconds
+	}
+	return 0
+}
+
+func (m *Tree) GetFinalizeTimeMillisSinceEpoch() int64 {
+	if m != nil {
+		return m.FinalizeTimeMillisSinceEpoch
+	}
+	return 0
+}
+
+func (m *Tree) GetFinalizedTreeSize() int64 {
+	if m != nil {
+		return m.FinalizedTreeSize
+	}
+	return 0
+}
+
+func (m *Tree) GetPublicKey() *PublicKey {
+	if m != nil {
+		return m.PublicKey
+	}
+	return nil
+}
+
+func (m *Tree) GetSequencingPriority() int32 {
+	if m != nil {
+		return m.SequencingPriority
+	}
+	return 0
+}
+
+func (m *Tree) GetMaxMergeDelaySeconds() int32 {
+	if m != nil {
+		return m.MaxMergeDelaySeconds
+	}
+	return 0
+}
+
+func (m *Tree) GetLeafIdentityHashStrategy() LeafIdentityHashStrategy {
+	if m != nil {
+		return m.LeafIdentityHashStrategy
+	}
+	return LeafIdentityHashStrategy_CLIENT_SUPPLIED
+}
+
+func (m *Tree) GetLeafIdentityHashKey() []byte {
+	if m != nil {
+		return m.LeafIdentityHashKey
+	}
+	return nil
+}
+
+func (m *Tree) GetAllowedWriters() []string {
+	if m != nil {
+		return m.AllowedWriters
+	}
+	return nil
+}
+
 type SignedEntryTimestamp struct {
 	TimestampNanos int64                  `protobuf:"varint,1,opt,name=timestamp_nanos,json=timestampNanos" json:"timestamp_nanos,omitempty"`
 	LogId          int64                  `protobuf:"varint,2,opt,name=log_id,json=logId" json:"log_id,omitempty"`
@@ -494,6 +767,42 @@ func (m *PEMKeyFile) GetPassword() string {
 	return ""
 }
 
+// PrivateKey holds a DER-encoded private key, in PKCS#1, PKCS#8 or SEC1 form.
+// The key is kept in storage with the rest of the tree, so this is only suitable
+// where storage is protected as well as the key needs to be.
+type PrivateKey struct {
+	Der []byte `protobuf:"bytes,1,opt,name=der,proto3" json:"der,omitempty"`
+}
+
+func (m *PrivateKey) Reset()                    { *m = PrivateKey{} }
+func (m *PrivateKey) String() string            { return proto.CompactTextString(m) }
+func (*PrivateKey) ProtoMessage()               {}
+func (*PrivateKey) Descriptor() ([]byte, []int) { return fileDescriptor3, []int{6} }
+
+func (m *PrivateKey) GetDer() []byte {
+	if m != nil {
+		return m.Der
+	}
+	return nil
+}
+
+// PublicKey holds a DER-encoded PKIX public key.
+type PublicKey struct {
+	Der []byte `protobuf:"bytes,1,opt,name=der,proto3" json:"der,omitempty"`
+}
+
+func (m *PublicKey) Reset()                    { *m = PublicKey{} }
+func (m *PublicKey) String() string            { return proto.CompactTextString(m) }
+func (*PublicKey) ProtoMessage()               {}
+func (*PublicKey) Descriptor() ([]byte, []int) { return fileDescriptor3, []int{7} }
+
+func (m *PublicKey) GetDer() []byte {
+	if m != nil {
+		return m.Der
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*Tree)(nil), "trillian.Tree")
 	proto.RegisterType((*SignedEntryTimestamp)(nil), "trillian.SignedEntryTimestamp")
@@ -501,75 +810,103 @@ func init() {
 	proto.RegisterType((*MapperMetadata)(nil), "trillian.MapperMetadata")
 	proto.RegisterType((*SignedMapRoot)(nil), "trillian.SignedMapRoot")
 	proto.RegisterType((*PEMKeyFile)(nil), "trillian.PEMKeyFile")
+	proto.RegisterType((*PrivateKey)(nil), "trillian.PrivateKey")
+	proto.RegisterType((*PublicKey)(nil), "trillian.PublicKey")
 	proto.RegisterEnum("trillian.HashStrategy", HashStrategy_name, HashStrategy_value)
 	proto.RegisterEnum("trillian.TreeState", TreeState_name, TreeState_value)
 	proto.RegisterEnum("trillian.TreeType", TreeType_name, TreeType_value)
 	proto.RegisterEnum("trillian.DuplicatePolicy", DuplicatePolicy_name, DuplicatePolicy_value)
+	proto.RegisterEnum("trillian.LeafCompression", LeafCompression_name, LeafCompression_value)
+	proto.RegisterEnum("trillian.LeafIdentityHashStrategy", LeafIdentityHashStrategy_name, LeafIdentityHashStrategy_value)
 }
 
 func init() { proto.RegisterFile("trillian.proto", fileDescriptor3) }
 
 var fileDescriptor3 = []byte{
-	// 963 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x55, 0xcb, 0x6e, 0xdb, 0x46,
-	0x17, 0x0e, 0x7d, 0x91, 0xa5, 0x23, 0xd9, 0xe6, 0x3f, 0x89, 0xfd, 0xd3, 0x4e, 0xd0, 0xba, 0x6a,
-	0x81, 0xba, 0x5e, 0x48, 0x80, 0x9d, 0xa6, 0x28, 0xda, 0x2e, 0x54, 0x8b, 0x8e, 0x05, 0xeb, 0x06,
-	0x92, 0x69, 0x90, 0x6c, 0x06, 0x63, 0x72, 0x42, 0x0d, 0x4a, 0x72, 0x26, 0xe4, 0x28, 0x05, 0xf3,
-	0x0c, 0x7d, 0x9c, 0xae, 0xfa, 0x3c, 0x7d, 0x8b, 0x6e, 0x8a, 0x19, 0x5e, 0x24, 0x27, 0x69, 0x11,
-	0x14, 0xdd, 0x08, 0x73, 0xbe, 0xf3, 0x9d, 0x8f, 0xe7, 0x36, 0x23, 0xd8, 0x93, 0x29, 0x8b, 0x22,
-	0x46, 0x92, 0x9e, 0x48, 0xb9, 0xe4, 0xa8, 0x59, 0xd9, 0xc7, 0x17, 0x21, 0x93, 0x8b, 0xe5, 0x6d,
-	0xcf, 0xe7, 0x71, 0x3f, 0xe4, 0x3c, 0x8c, 0x68, 0xbf, 0xf2, 0xf5, 0xfd, 0x34, 0x17, 0x92, 0xf7,
-	0x33, 0x16, 0x8a, 0xdb, 0xe2, 0xb7, 0x08, 0x3f, 0x3e, 0x2a, 0x99, 0xda, 0xba, 0x5d, 0xbe, 0xea,
-	0x93, 0x24, 0x2f, 0x5c, 0xdd, 0xdf, 0xb6, 0x61, 0xcb, 0x4b, 0x29, 0x45, 0xff, 0x87, 0x1d, 0x99,
-	0x52, 0x8a, 0x59, 0x60, 0x19, 0x27, 0xc6, 0xe9, 0xa6, 0xd3, 0x50, 0xe6, 0x28, 0x40, 0xe7, 0x00,
-	0xda, 0x91, 0x49, 0x22, 0xa9, 0xb5, 0x71, 0x62, 0x9c, 0xee, 0x9d, 0xdf, 0xef, 0xd5, 0x09, 0xaa,
-	0x60, 0x57, 0xb9, 0x9c, 0x96, 0xac, 0x8e, 0xa8, 0x0f, 0xda, 0xc0, 0x32, 0x17, 0xd4, 0xda, 0xd4,
-	0x21, 0xe8, 0x6e, 0x88, 0x97, 0x0b, 0xea, 0x34, 0x65, 0x79, 0x42, 0xdf, 0xc1, 0xee, 0x82, 0x64,
-	0x0b, 0x9c, 0xc9, 0x94, 0x48, 0x1a, 0xe6, 0xd6, 0x96, 0x0e, 0x3a, 0x5c, 0x05, 0x5d, 0x93, 0x6c,
-	0xe1, 0x96, 0x5e, 0xa7, 0xb3, 0x58, 0xb3, 0xd0, 0x0d, 0xec, 0xe9, 0x60, 0x12, 0x85, 0x3c, 0x65,
-	0x72, 0x11, 0x5b, 0xdb, 0x3a, 0xfa, 0x8b, 0x5e, 0xd1, 0x84, 0x21, 0x0b, 0x99, 0x24, 0x51, 0x94,
-	0xbb, 0x2c, 0x4c, 0x68, 0xa0, 0xa5, 0x06, 0x15, 0xd7, 0xd1, 0x1f, 0xae, 0x4d, 0xf4, 0x12, 0xee,
-	0x67, 0x2c, 0x4c, 0x88, 0x5c, 0xa6, 0x74, 0x4d, 0xb1, 0xa1, 0x15, 0xbf, 0xfa, 0x1b, 0x45, 0xb7,
-	0x8a, 0x58, 0xc9, 0xa2, 0xec, 0x3d, 0x0c, 0x0d, 0xc1, 0x0c, 0x96, 0x22, 0x62, 0x3e, 0x91, 0x14,
-	0x0b, 0x1e, 0x31, 0x3f, 0xb7, 0x76, 0xb4, 0xf0, 0xd1, 0xaa, 0xd0, 0x61, 0xc5, 0x98, 0x6b, 0x82,
-	0xb3, 0x1f, 0xdc, 0x05, 0xd0, 0x67, 0xd0, 0x09, 0x58, 0x26, 0x22, 0x92, 0xe3, 0x84, 0xc4, 0xd4,
-	0x6a, 0x9e, 0x18, 0xa7, 0x2d, 0xa7, 0x5d, 0x62, 0x53, 0x12, 0x53, 0x74, 0x02, 0xed, 0x80, 0x66,
-	0x7e, 0xca, 0x84, 0x64, 0x3c, 0xb1, 0x5a, 0x25, 0x63, 0x05, 0xa1, 0x1f, 0xe1, 0x13, 0x3f, 0xa5,
-	0x2a, 0x0f, 0xc9, 0x62, 0x8a, 0x63, 0xf5, 0xf1, 0x0c, 0x67, 0x2c, 0xf1, 0x29, 0xa6, 0x82, 0xfb,
-	0x0b, 0x0b, 0xf4, 0x16, 0x1c, 0x17, 0x2c, 0x8f, 0xc5, 0x74, 0xa2, 0x39, 0xae, 0xa2, 0xd8, 0x8a,
-	0xa1, 0x34, 0x96, 0x22, 0xf8, 0x27, 0x8d, 0x76, 0xa1, 0x51, 0xb0, 0x3e, 0xa8, 0xf1, 0x35, 0xb4,
-	0x45, 0xca, 0xde, 0x28, 0x91, 0x9f, 0x69, 0x6e, 0x75, 0x4e, 0x8c, 0xd3, 0xf6, 0xf9, 0x83, 0x5e,
-	0xb1, 0xb0, 0xbd, 0x6a, 0x61, 0x7b, 0x83, 0x24, 0x77, 0xa0, 0x24, 0xde, 0xd0, 0xbc, 0xfb, 0xab,
-	0x01, 0x0f, 0x8a, 0xde, 0xdb, 0x89, 0x4c, 0x73, 0x25, 0x9d, 0x49, 0x12, 0x0b, 0xf4, 0x25, 0xec,
-	0xcb, 0xca, 0xc0, 0x09, 0x49, 0x78, 0x56, 0xae, 0xf3, 0x5e, 0x0d, 0x4f, 0x15, 0x8a, 0x0e, 0xa0,
-	0x11, 0xf1, 0x50, 0xad, 0xfb, 0x86, 0xf6, 0x6f, 0x47, 0x3c, 0x1c, 0x05, 0xe8, 0x31, 0xb4, 0xea,
-	0xc1, 0xe9, 0xcd, 0x6d, 0x9f, 0x1f, 0x7e, 0x78, 0xe8, 0xce, 0x8a, 0xd8, 0xfd, 0xc3, 0x80, 0xdd,
-	0x02, 0x1d, 0xf3, 0xd0, 0xe1, 0x5c, 0x7e, 0x7c, 0x1e, 0x0f, 0xa1, 0x95, 0x72, 0x2e, 0xb1, 0xda,
-	0x42, 0x9d, 0x4a, 0xc7, 0x69, 0x2a, 0x40, 0x2d, 0xa9, 0x72, 0x16, 0x77, 0x8f, 0xbd, 0x2d, 0xb2,
-	0xd9, 0x2c, 0xee, 0x8c, 0xcb, 0xde, 0xd2, 0xbb, 0xa9, 0x6e, 0x7d, 0x64, 0xaa, 0x6b, 0x75, 0x6f,
-	0xaf, 0xd7, 0xfd, 0x39, 0xec, 0xea, 0x2f, 0xa5, 0xf4, 0x0d, 0xcb, 0xd4, 0xce, 0x34, 0xb4, 0xb7,
-	0xa3, 0x40, 0xa7, 0xc4, 0xba, 0xbf, 0x1b, 0xb0, 0x37, 0x21, 0x42, 0xd0, 0x74, 0x42, 0x25, 0x09,
-	0x88, 0x24, 0xa8, 0x0b, 0xbb, 0x19, 0x5f, 0xa6, 0x3e, 0xc5, 0xa5, 0xaa, 0xa1, 0x4b, 0x68, 0x17,
-	0xe0, 0x58, 0x6b, 0xff, 0x00, 0x0f, 0x17, 0x2c, 0x5c, 0xd0, 0x4c, 0xe2, 0x57, 0xcb, 0x28, 0xca,
-	0xb1, 0xcf, 0x63, 0x11, 0x51, 0x49, 0x03, 0x9c, 0xd1, 0xd7, 0x65, 0xff, 0xad, 0x92, 0x72, 0xa5,
-	0x18, 0x97, 0x15, 0xc1, 0xa5, 0xaf, 0x91, 0x0d, 0x9f, 0x56, 0xe1, 0x82, 0xa4, 0x92, 0x91, 0xf7,
-	0x25, 0x8a, 0xd6, 0x3c, 0x2a, 0x69, 0xf3, 0x8a, 0xb5, 0x2e, 0xd3, 0xfd, 0xb3, 0x9e, 0xd1, 0x84,
-	0x88, 0xff, 0x70, 0x46, 0x8f, 0xa1, 0x19, 0x97, 0xdd, 0x28, 0x17, 0xc6, 0x5a, 0x5d, 0xe6, 0xbb,
-	0xdd, 0x72, 0x6a, 0xe6, 0xbf, 0x1f, 0x5e, 0x4c, 0xc4, 0xda, 0xf0, 0x62, 0x22, 0x46, 0x81, 0x7a,
-	0x11, 0x14, 0xfc, 0xce, 0xec, 0xda, 0x31, 0x11, 0xf5, 0xe8, 0xbe, 0x07, 0x98, 0xdb, 0x93, 0x1b,
-	0x9a, 0x5f, 0xb1, 0x88, 0x22, 0x04, 0x5b, 0x82, 0xc8, 0x85, 0x2e, 0xb7, 0xe5, 0xe8, 0x33, 0x3a,
-	0x86, 0xa6, 0x20, 0x59, 0xf6, 0x0b, 0x4f, 0x8b, 0x2b, 0xd1, 0x72, 0x6a, 0xfb, 0xec, 0x1b, 0xe8,
-	0xac, 0xbf, 0xbf, 0xe8, 0x08, 0x0e, 0x9e, 0x4d, 0x6f, 0xa6, 0xb3, 0xe7, 0x53, 0x7c, 0x3d, 0x70,
-	0xaf, 0xb1, 0xeb, 0x39, 0x03, 0xcf, 0x7e, 0xfa, 0xc2, 0xbc, 0x87, 0x3a, 0xd0, 0x74, 0xae, 0x2e,
-	0xf1, 0x93, 0x6f, 0x9f, 0x9c, 0x9b, 0xc6, 0x19, 0x86, 0x56, 0xfd, 0x07, 0x81, 0x0e, 0x01, 0x55,
-	0x51, 0x9e, 0x63, 0xdb, 0xd8, 0xf5, 0x06, 0x9e, 0x6d, 0xde, 0x43, 0x00, 0x8d, 0xc1, 0xa5, 0x37,
-	0xfa, 0xc9, 0x36, 0x0d, 0x75, 0xbe, 0x72, 0x66, 0x2f, 0xed, 0xa9, 0xb9, 0x81, 0x4c, 0xe8, 0xb8,
-	0xb3, 0x2b, 0x0f, 0x0f, 0xed, 0xb1, 0xed, 0xd9, 0x43, 0x73, 0x53, 0x21, 0xd7, 0x03, 0x67, 0x58,
-	0x23, 0x5b, 0x67, 0x17, 0xd0, 0xac, 0xfe, 0x4e, 0xd0, 0x01, 0xfc, 0xef, 0x8e, 0xbe, 0xf7, 0x62,
-	0xae, 0xe4, 0x77, 0x60, 0x73, 0x3c, 0x7b, 0x6a, 0x1a, 0xea, 0x30, 0x19, 0xcc, 0xcd, 0x8d, 0x33,
-	0x1f, 0xf6, 0xdf, 0x79, 0x65, 0xd1, 0x23, 0xb0, 0xaa, 0xd8, 0xe1, 0xb3, 0xf9, 0x78, 0x74, 0x39,
-	0xf0, 0x6c, 0x3c, 0x9f, 0x8d, 0x47, 0x97, 0xaa, 0xa8, 0x63, 0x38, 0xac, 0x51, 0x17, 0x4f, 0x67,
-	0x1e, 0x1e, 0x8c, 0xc7, 0xb3, 0xe7, 0xf6, 0xd0, 0x34, 0x54, 0x55, 0x6b, 0xbe, 0x0a, 0xdf, 0xb8,
-	0x6d, 0xe8, 0xc7, 0xeb, 0xe2, 0xaf, 0x00, 0x00, 0x00, 0xff, 0xff, 0x4b, 0x08, 0x07, 0x62, 0xcc,
-	0x07, 0x00, 0x00,
+	// 1357 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xad, 0x56, 0xdb, 0x6e, 0xdb, 0x46,
+	0x10, 0xad, 0x2c, 0xdb, 0x91, 0x46, 0xb2, 0xa4, 0xac, 0x6c, 0x97, 0xbe, 0xa0, 0x75, 0xd5, 0x00,
+	0x6d, 0xfd, 0x20, 0x03, 0xce, 0xa5, 0x08, 0xda, 0x06, 0x60, 0x24, 0xfa, 0x82, 0xe8, 0x86, 0x25,
+	0x1d, 0x37, 0x79, 0x59, 0xd0, 0xe2, 0x5a, 0x22, 0x4a, 0x91, 0x0c, 0x49, 0xc5, 0x50, 0xbf, 0xa0,
+	0x0f, 0xfd, 0xa2, 0x7e, 0x42, 0xbf, 0xa3, 0x7f, 0xd1, 0x97, 0xce, 0x2e, 0x2f, 0x92, 0x7c, 0x29,
+	0x82, 0xa2, 0x2f, 0xf6, 0xee, 0xcc, 0x39, 0x67, 0x67, 0x67, 0x66, 0x47, 0x84, 0x4a, 0x14, 0xd8,
+	0x8e, 0x63, 0x9b, 0x6e, 0xd3, 0x0f, 0xbc, 0xc8, 0x23, 0x85, 0x74, 0xbf, 0xfb, 0x74, 0x64, 0x47,
+	0xe3, 0xe9, 0x55, 0x73, 0xe8, 0x4d, 0x8e, 0x46, 0x9e, 0x37, 0x72, 0xf8, 0x51, 0xea, 0x3b, 0x1a,
+	0x06, 0x33, 0x3f, 0xf2, 0x8e, 0x42, 0x7b, 0xe4, 0x5f, 0xc5, 0x7f, 0x63, 0xfa, 0xee, 0x4e, 0x82,
+	0x94, 0xbb, 0xab, 0xe9, 0xf5, 0x91, 0xe9, 0xce, 0x62, 0x57, 0xe3, 0xcf, 0x12, 0xac, 0x1a, 0x01,
+	0xe7, 0xe4, 0x73, 0x78, 0x14, 0xe1, 0x7f, 0x66, 0x5b, 0x4a, 0xee, 0x20, 0xf7, 0x6d, 0x9e, 0xae,
+	0x8b, 0xed, 0xb9, 0x45, 0x8e, 0x01, 0xa4, 0x23, 0x8c, 0xcc, 0x88, 0x2b, 0x2b, 0xe8, 0xab, 0x1c,
+	0xd7, 0x9b, 0x59, 0x80, 0x82, 0xac, 0x0b, 0x17, 0x2d, 0x46, 0xe9, 0x92, 0x1c, 0x81, 0xdc, 0xb0,
+	0x68, 0xe6, 0x73, 0x25, 0x2f, 0x29, 0x64, 0x99, 0x62, 0xa0, 0x87, 0x16, 0xa2, 0x64, 0x45, 0x7e,
+	0x80, 0x8d, 0xb1, 0x19, 0x8e, 0xf1, 0x90, 0x00, 0xf9, 0xa3, 0x99, 0xb2, 0x2a, 0x49, 0xdb, 0x73,
+	0xd2, 0x19, 0xba, 0xf5, 0xc4, 0x4b, 0xcb, 0xe3, 0x85, 0x1d, 0x79, 0x03, 0x15, 0x49, 0x36, 0x9d,
+	0x91, 0x17, 0x60, 0x7a, 0x26, 0xca, 0x9a, 0x64, 0x3f, 0x69, 0xc6, 0x49, 0x68, 0xdb, 0x98, 0x34,
+	0xd3, 0x71, 0x66, 0xba, 0x3d, 0x72, 0xb9, 0x25, 0xa5, 0xd4, 0x14, 0x4b, 0xe5, 0xc1, 0xd9, 0x96,
+	0xbc, 0x87, 0x3a, 0xb2, 0x5c, 0x33, 0x9a, 0x06, 0x7c, 0x41, 0x71, 0x5d, 0x2a, 0x7e, 0xf7, 0x80,
+	0xa2, 0x9e, 0x32, 0xe6, 0xb2, 0x24, 0xbc, 0x63, 0x23, 0x6d, 0xa8, 0x59, 0x53, 0xdf, 0xb1, 0x87,
+	0x18, 0x37, 0xf3, 0x3d, 0x5c, 0xcc, 0x94, 0x47, 0x52, 0x78, 0x67, 0x7e, 0xd1, 0x76, 0x8a, 0x18,
+	0x48, 0x00, 0xad, 0x5a, 0xcb, 0x06, 0xf2, 0x15, 0x94, 0x2d, 0x3b, 0xf4, 0x1d, 0x73, 0xc6, 0x5c,
+	0x73, 0xc2, 0x95, 0x02, 0x2a, 0x14, 0x69, 0x29, 0xb1, 0xf5, 0xd0, 0x44, 0x0e, 0xa0, 0x64, 0xf1,
+	0x70, 0x18, 0xd8, 0x7e, 0x64, 0x7b, 0xae, 0x52, 0x4c, 0x10, 0x73, 0x13, 0x79, 0x0d, 0x5f, 0x0c,
+	0x03, 0x2e, 0xe2, 0x88, 0xec, 0x09, 0x67, 0x13, 0x71, 0x78, 0xc8, 0x42, 0xdb, 0x1d, 0x72, 0xc6,
+	0x7d, 0x6f, 0x38, 0x56, 0x40, 0x76, 0xc1, 0x6e, 0x8c, 0x32, 0x10, 0xd4, 0x95, 0x18, 0x5d, 0x40,
+	0x34, 0x81, 0x10, 0x1a, 0x53, 0xdf, 0xfa, 0x37, 0x8d, 0x52, 0xac, 0x11, 0xa3, 0xee, 0xd5, 0x78,
+	0x0e, 0x25, 0x3f, 0xb0, 0x3f, 0x0a, 0x91, 0x5f, 0xf8, 0x4c, 0x29, 0x23, 0xa1, 0x74, 0xbc, 0xd9,
+	0x8c, 0x1b, 0xb6, 0x99, 0x36, 0x6c, 0x53, 0x75, 0x67, 0x14, 0x12, 0xe0, 0x1b, 0x3e, 0xc3, 0xa6,
+	0xdc, 0x0a, 0xf9, 0x87, 0x29, 0x77, 0x87, 0xb6, 0x3b, 0x62, 0x57, 0x66, 0x34, 0xc4, 0xde, 0xb1,
+	0x7f, 0xe5, 0xca, 0x06, 0x0a, 0xac, 0xd1, 0xfa, 0xdc, 0xf9, 0x5a, 0xf8, 0x74, 0x74, 0x91, 0x57,
+	0xb0, 0xb7, 0xc0, 0xb1, 0xdd, 0x88, 0x07, 0x1f, 0x4d, 0x87, 0x85, 0x7c, 0xe8, 0xb9, 0x56, 0xa8,
+	0x54, 0x24, 0x73, 0x67, 0x0e, 0x39, 0x4f, 0x10, 0x7a, 0x0c, 0x20, 0x1a, 0x7c, 0xb9, 0xc0, 0x1f,
+	0x4d, 0xcd, 0xc0, 0x62, 0x37, 0xb6, 0x6b, 0x79, 0x37, 0x99, 0x46, 0x55, 0x6a, 0xec, 0xcf, 0x61,
+	0xa7, 0x02, 0x75, 0x29, 0x41, 0xa9, 0x0c, 0x36, 0x81, 0xc3, 0xcd, 0x6b, 0x86, 0x2f, 0xd8, 0x0f,
+	0x78, 0x18, 0x8a, 0x02, 0xd5, 0x6e, 0x37, 0x41, 0x07, 0x11, 0xad, 0x39, 0x80, 0x56, 0x9d, 0x65,
+	0x03, 0x56, 0xb8, 0x1c, 0x8e, 0x45, 0x04, 0x21, 0x8f, 0xc4, 0x9b, 0x7d, 0x2c, 0x33, 0x0d, 0xd2,
+	0xa6, 0xf3, 0x08, 0xdf, 0x2d, 0x56, 0x27, 0x41, 0x44, 0x66, 0x10, 0xdd, 0x57, 0x1d, 0x12, 0x57,
+	0x27, 0xe6, 0x08, 0xd0, 0x9d, 0xea, 0xbc, 0x82, 0xfd, 0x58, 0x83, 0xbb, 0xd6, 0x7d, 0x0a, 0x75,
+	0xa9, 0xa0, 0x48, 0x8c, 0xe6, 0x5a, 0x77, 0xf8, 0xcf, 0x60, 0x5b, 0xde, 0x35, 0xe0, 0x11, 0x77,
+	0x45, 0xdf, 0x65, 0x99, 0xda, 0x94, 0x99, 0xda, 0x14, 0x5e, 0x9a, 0x3a, 0xd3, 0x0c, 0x9d, 0xc0,
+	0xc1, 0xb5, 0xed, 0x9a, 0x0e, 0x16, 0xed, 0xc1, 0xce, 0xda, 0x92, 0x27, 0xef, 0xa7, 0xb8, 0x7b,
+	0x7b, 0xab, 0x09, 0xf5, 0xd4, 0x6f, 0xb1, 0x78, 0x86, 0x89, 0x16, 0xd9, 0x96, 0xd4, 0xc7, 0x99,
+	0x4b, 0x4e, 0x30, 0xd1, 0x20, 0x26, 0xec, 0xc9, 0x68, 0x6d, 0x4b, 0xc4, 0x13, 0xcd, 0xd8, 0xf2,
+	0x48, 0xda, 0x95, 0x45, 0x6a, 0x2c, 0x17, 0xe9, 0x3c, 0xc1, 0x2e, 0x8d, 0x27, 0xc5, 0x79, 0xc0,
+	0x43, 0x9e, 0x26, 0x09, 0x59, 0x3e, 0x42, 0x74, 0xfe, 0x1e, 0xaa, 0x97, 0x69, 0xfd, 0x36, 0x53,
+	0x34, 0xfb, 0x37, 0x50, 0xc5, 0x59, 0xe3, 0xdd, 0xe0, 0x2d, 0x6e, 0x70, 0x90, 0xf0, 0x20, 0x54,
+	0xf6, 0x0f, 0xf2, 0xf8, 0xa2, 0x2b, 0x89, 0xf9, 0x32, 0xb6, 0x36, 0x7e, 0xcf, 0xc1, 0x66, 0x3c,
+	0x91, 0x34, 0x37, 0x0a, 0x66, 0x22, 0x29, 0x58, 0xfc, 0x89, 0x2f, 0x14, 0xa2, 0x74, 0x83, 0x43,
+	0xc3, 0xf5, 0xc2, 0x64, 0xc8, 0x57, 0x32, 0x73, 0x4f, 0x58, 0xc9, 0x16, 0xac, 0x3b, 0xde, 0x48,
+	0x34, 0xd4, 0x8a, 0xf4, 0xaf, 0xe1, 0x0e, 0x7b, 0xe9, 0x19, 0x14, 0xb3, 0x71, 0x26, 0xe7, 0x79,
+	0x09, 0x47, 0xf3, 0xbd, 0xa3, 0x90, 0xce, 0x81, 0x8d, 0xbf, 0x72, 0xb0, 0x11, 0x5b, 0x3b, 0xde,
+	0x88, 0x7a, 0x5e, 0xf4, 0xe9, 0x71, 0xec, 0x41, 0x31, 0x40, 0x82, 0x4c, 0x8f, 0x0c, 0xa5, 0x4c,
+	0x0b, 0xc2, 0x20, 0x52, 0x22, 0x9c, 0xf3, 0x6a, 0xe6, 0x25, 0x5f, 0xfe, 0x92, 0xc8, 0x22, 0x2e,
+	0x85, 0xba, 0xfa, 0x89, 0xa1, 0x2e, 0xdc, 0x7b, 0x6d, 0xf1, 0xde, 0x5f, 0xc3, 0x86, 0x3c, 0x29,
+	0xe0, 0x1f, 0x6d, 0xf9, 0x50, 0xd7, 0xa5, 0xb7, 0x2c, 0x8c, 0x34, 0xb1, 0x35, 0xfe, 0xc8, 0x41,
+	0xa5, 0x6b, 0xfa, 0x3e, 0x0f, 0xba, 0x3c, 0x32, 0x71, 0xd2, 0x99, 0xa4, 0x01, 0x1b, 0xa1, 0x37,
+	0x0d, 0xb0, 0x5b, 0x13, 0xd5, 0x9c, 0xbc, 0x42, 0x29, 0x36, 0x76, 0xa4, 0xf6, 0x4f, 0xb0, 0x37,
+	0xb6, 0x47, 0x63, 0xbc, 0x35, 0xbb, 0x9e, 0x62, 0x50, 0x72, 0x20, 0x38, 0xf8, 0x16, 0xc4, 0x9b,
+	0xfe, 0x90, 0xe4, 0x5f, 0x49, 0x20, 0x27, 0x02, 0xd1, 0x4a, 0x01, 0x3a, 0xff, 0x20, 0xa6, 0x51,
+	0x4a, 0xf7, 0xf1, 0xe9, 0xda, 0xe6, 0x5d, 0x89, 0x38, 0x35, 0xfb, 0x09, 0x6c, 0x90, 0xa2, 0x16,
+	0x65, 0x1a, 0x7f, 0x67, 0x35, 0xc2, 0x2b, 0xfc, 0x8f, 0x35, 0x7a, 0x06, 0x85, 0x49, 0x92, 0x8d,
+	0xa4, 0x61, 0x94, 0xf9, 0xc3, 0x59, 0xce, 0x16, 0xcd, 0x90, 0xff, 0xbd, 0x78, 0x13, 0xd3, 0x5f,
+	0x28, 0x1e, 0xee, 0x30, 0xc1, 0xf8, 0x3b, 0x29, 0xcc, 0xb7, 0x6a, 0x57, 0x42, 0x5b, 0x56, 0xba,
+	0x1f, 0x01, 0x06, 0x5a, 0x17, 0xdf, 0xd8, 0x89, 0xed, 0x70, 0x42, 0x60, 0xd5, 0x37, 0xa3, 0xb1,
+	0xbc, 0x6e, 0x91, 0xca, 0x35, 0xd9, 0x85, 0x82, 0x6f, 0x86, 0xe1, 0x8d, 0x17, 0xc4, 0x4f, 0xa2,
+	0x48, 0xb3, 0xfd, 0xe1, 0xf7, 0x50, 0x5e, 0x7a, 0xdc, 0x3b, 0xb0, 0x75, 0xd1, 0x7b, 0xd3, 0xeb,
+	0x5f, 0xf6, 0xd8, 0x99, 0xaa, 0x9f, 0x31, 0xdd, 0xa0, 0xaa, 0xa1, 0x9d, 0xbe, 0xab, 0x7d, 0x46,
+	0xca, 0x50, 0xa0, 0x27, 0x2d, 0xf6, 0xe2, 0xe5, 0x8b, 0xe3, 0x5a, 0xee, 0x90, 0x41, 0x31, 0xfb,
+	0x6c, 0x22, 0xdb, 0x40, 0x52, 0x96, 0x41, 0x35, 0x0d, 0x59, 0x48, 0x42, 0x0a, 0xc0, 0xba, 0xda,
+	0x32, 0xce, 0xdf, 0x6a, 0xb5, 0x9c, 0x58, 0x9f, 0xd0, 0xfe, 0x7b, 0xad, 0x57, 0x5b, 0x21, 0x35,
+	0x28, 0xeb, 0xfd, 0x13, 0x83, 0xb5, 0xb5, 0x8e, 0x66, 0x68, 0xed, 0x5a, 0x5e, 0x58, 0xce, 0x54,
+	0xda, 0xce, 0x2c, 0xab, 0x87, 0xa7, 0x50, 0x48, 0x3f, 0xb2, 0x30, 0x3b, 0x8f, 0x97, 0xf4, 0x8d,
+	0x77, 0x03, 0x21, 0xff, 0x08, 0xf2, 0x9d, 0xfe, 0x29, 0x6a, 0xe3, 0xa2, 0xab, 0x0e, 0x50, 0x98,
+	0x40, 0x65, 0x40, 0xb5, 0x3e, 0x6d, 0x6b, 0x54, 0x6b, 0x33, 0xe1, 0xcc, 0x1f, 0x0e, 0xa1, 0x7a,
+	0xeb, 0x7b, 0x84, 0xec, 0x83, 0x92, 0xea, 0xb5, 0x2f, 0x06, 0x9d, 0xf3, 0x16, 0x86, 0xcb, 0x06,
+	0x7d, 0x5c, 0x88, 0x8b, 0xee, 0xc2, 0x76, 0x66, 0xd5, 0x59, 0xaf, 0x6f, 0x30, 0xb5, 0xd3, 0xe9,
+	0x5f, 0x62, 0x54, 0x39, 0x71, 0xd3, 0x05, 0x5f, 0x6a, 0x5f, 0x39, 0x7c, 0x09, 0xd5, 0x5b, 0xbf,
+	0x77, 0xe2, 0x4a, 0x17, 0xbd, 0x56, 0xbf, 0x8b, 0x01, 0xe9, 0x3a, 0x82, 0x64, 0x3a, 0xf4, 0x9e,
+	0x3a, 0x18, 0xbc, 0x43, 0xa1, 0x02, 0xac, 0xbe, 0xd7, 0x0d, 0x41, 0xfd, 0x2d, 0x07, 0xca, 0x43,
+	0x63, 0x98, 0xd4, 0xa1, 0xda, 0xea, 0x9c, 0x6b, 0x3d, 0x83, 0xe9, 0x17, 0x03, 0x3c, 0x57, 0xea,
+	0x60, 0x3a, 0xf4, 0x33, 0xf5, 0xf8, 0xf9, 0x0b, 0xd6, 0xd1, 0xd4, 0x13, 0xf6, 0x56, 0xed, 0x5c,
+	0x88, 0x0c, 0x3f, 0x81, 0x83, 0x3b, 0x66, 0xa6, 0xf6, 0xda, 0x4c, 0xfb, 0x19, 0xab, 0xc8, 0xda,
+	0xaa, 0xa1, 0x62, 0x8a, 0xf0, 0x76, 0x67, 0x5d, 0xb5, 0xc5, 0xee, 0x2a, 0xe4, 0xaf, 0xd6, 0xe5,
+	0xc7, 0xca, 0xd3, 0x7f, 0x00, 0xe9, 0x50, 0xc3, 0xc0, 0xbc, 0x0b, 0x00, 0x00,
 }
@@ -9,6 +9,7 @@ import fmt "fmt"
 import math "math"
 import google_protobuf1 "google.golang.org/genproto/protobuf/field_mask"
 import google_protobuf2 "github.com/golang/protobuf/ptypes/empty"
+import google_rpc "google.golang.org/genproto/googleapis/rpc/status"
 
 import (
 	context "golang.org/x/net/context"
@@ -72,6 +73,10 @@ func (m *GetTreeRequest) GetTreeId() int64 {
 type CreateTreeRequest struct {
 	// Tree to be created. See Tree and CreateTree for more details.
 	Tree *Tree `protobuf:"bytes,1,opt,name=tree" json:"tree,omitempty"`
+	// If true the tree is validated, with the same checks as creating it,
+	// including loading its private_key and storage's, but not created. The tree
+	// it would be created as is returned. Items of a batch may be validate_only.
+	ValidateOnly bool `protobuf:"varint,2,opt,name=validate_only,json=validateOnly" json:"validate_only,omitempty"`
 }
 
 func (m *CreateTreeRequest) Reset()                    { *m = CreateTreeRequest{} }
@@ -86,6 +91,13 @@ func (m *CreateTreeRequest) GetTree() *Tree {
 	return nil
 }
 
+func (m *CreateTreeRequest) GetValidateOnly() bool {
+	if m != nil {
+		return m.ValidateOnly
+	}
+	return false
+}
+
 // UpdateTree request.
 type UpdateTreeRequest struct {
 	// Tree to be updated.
@@ -93,6 +105,10 @@ type UpdateTreeRequest struct {
 	// Fields modified by the update request.
 	// For example: "tree_state", "display_name", "description".
 	UpdateMask *google_protobuf1.FieldMask `protobuf:"bytes,2,opt,name=update_mask,json=updateMask" json:"update_mask,omitempty"`
+	// If true the updated tree is validated, with the same checks as updating
+	// it, including loading its private_key, but not written. The tree it would
+	// be updated to is returned. Items of a batch may be validate_only.
+	ValidateOnly bool `protobuf:"varint,3,opt,name=validate_only,json=validateOnly" json:"validate_only,omitempty"`
 }
 
 func (m *UpdateTreeRequest) Reset()                    { *m = UpdateTreeRequest{} }
@@ -114,6 +130,13 @@ func (m *UpdateTreeRequest) GetUpdateMask() *google_protobuf1.FieldMask {
 	return nil
 }
 
+func (m *UpdateTreeRequest) GetValidateOnly() bool {
+	if m != nil {
+		return m.ValidateOnly
+	}
+	return false
+}
+
 // DeleteTree request.
 type DeleteTreeRequest struct {
 	// ID of the tree to delete.
@@ -132,6 +155,235 @@ func (m *DeleteTreeRequest) GetTreeId() int64 {
 	return 0
 }
 
+// SequenceLog request.
+type SequenceLogRequest struct {
+	// ID of the log to sequence.
+	LogId int64 `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
+}
+
+func (m *SequenceLogRequest) Reset()                    { *m = SequenceLogRequest{} }
+func (m *SequenceLogRequest) String() string            { return proto.CompactTextString(m) }
+func (*SequenceLogRequest) ProtoMessage()               {}
+func (*SequenceLogRequest) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{6} }
+
+func (m *SequenceLogRequest) GetLogId() int64 {
+	if m != nil {
+		return m.LogId
+	}
+	return 0
+}
+
+// SequenceLog response.
+type SequenceLogResponse struct {
+	// Number of leaves integrated into the log.
+	LeavesSequenced int64 `protobuf:"varint,1,opt,name=leaves_sequenced,json=leavesSequenced" json:"leaves_sequenced,omitempty"`
+	// The log's latest root, once its queued leaves have been integrated.
+	SignedLogRoot *SignedLogRoot `protobuf:"bytes,2,opt,name=signed_log_root,json=signedLogRoot" json:"signed_log_root,omitempty"`
+}
+
+func (m *SequenceLogResponse) Reset()                    { *m = SequenceLogResponse{} }
+func (m *SequenceLogResponse) String() string            { return proto.CompactTextString(m) }
+func (*SequenceLogResponse) ProtoMessage()               {}
+func (*SequenceLogResponse) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{7} }
+
+func (m *SequenceLogResponse) GetLeavesSequenced() int64 {
+	if m != nil {
+		return m.LeavesSequenced
+	}
+	return 0
+}
+
+func (m *SequenceLogResponse) GetSignedLogRoot() *SignedLogRoot {
+	if m != nil {
+		return m.SignedLogRoot
+	}
+	return nil
+}
+
+// GetTreeStats request.
+type GetTreeStatsRequest struct {
+	// ID of the log to report on.
+	TreeId int64 `protobuf:"varint,1,opt,name=tree_id,json=treeId" json:"tree_id,omitempty"`
+}
+
+func (m *GetTreeStatsRequest) Reset()                    { *m = GetTreeStatsRequest{} }
+func (m *GetTreeStatsRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetTreeStatsRequest) ProtoMessage()               {}
+func (*GetTreeStatsRequest) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{8} }
+
+func (m *GetTreeStatsRequest) GetTreeId() int64 {
+	if m != nil {
+		return m.TreeId
+	}
+	return 0
+}
+
+// GetTreeStats response.
+type GetTreeStatsResponse struct {
+	// Size of the log's latest signed root.
+	TreeSize int64 `protobuf:"varint,1,opt,name=tree_size,json=treeSize" json:"tree_size,omitempty"`
+	// Number of leaves queued and not yet sequenced.
+	UnsequencedLeaves int64 `protobuf:"varint,2,opt,name=unsequenced_leaves,json=unsequencedLeaves" json:"unsequenced_leaves,omitempty"`
+	// How long the oldest queued leaf has been waiting, in nanoseconds, or zero if
+	// none are queued.
+	OldestUnsequencedAgeNanos int64 `protobuf:"varint,3,opt,name=oldest_unsequenced_age_nanos,json=oldestUnsequencedAgeNanos" json:"oldest_unsequenced_age_nanos,omitempty"`
+	// Timestamp of the log's latest signed root, in nanoseconds since the epoch.
+	RootTimestampNanos int64 `protobuf:"varint,4,opt,name=root_timestamp_nanos,json=rootTimestampNanos" json:"root_timestamp_nanos,omitempty"`
+	// Bytes of leaf data stored for the log, as accounted by storage. Storage adds
+	// to it as leaves are written and periodically recounts it, so it may be
+	// briefly out of date. Zero if the storage doesn't account for usage.
+	LeafBytes int64 `protobuf:"varint,5,opt,name=leaf_bytes,json=leafBytes" json:"leaf_bytes,omitempty"`
+	// Bytes of Merkle tree nodes stored for the log, accounted like leaf_bytes.
+	NodeBytes int64 `protobuf:"varint,6,opt,name=node_bytes,json=nodeBytes" json:"node_bytes,omitempty"`
+}
+
+func (m *GetTreeStatsResponse) Reset()                    { *m = GetTreeStatsResponse{} }
+func (m *GetTreeStatsResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetTreeStatsResponse) ProtoMessage()               {}
+func (*GetTreeStatsResponse) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{9} }
+
+func (m *GetTreeStatsResponse) GetTreeSize() int64 {
+	if m != nil {
+		return m.TreeSize
+	}
+	return 0
+}
+
+func (m *GetTreeStatsResponse) GetUnsequencedLeaves() int64 {
+	if m != nil {
+		return m.UnsequencedLeaves
+	}
+	return 0
+}
+
+func (m *GetTreeStatsResponse) GetOldestUnsequencedAgeNanos() int64 {
+	if m != nil {
+		return m.OldestUnsequencedAgeNanos
+	}
+	return 0
+}
+
+func (m *GetTreeStatsResponse) GetRootTimestampNanos() int64 {
+	if m != nil {
+		return m.RootTimestampNanos
+	}
+	return 0
+}
+
+func (m *GetTreeStatsResponse) GetLeafBytes() int64 {
+	if m != nil {
+		return m.LeafBytes
+	}
+	return 0
+}
+
+func (m *GetTreeStatsResponse) GetNodeBytes() int64 {
+	if m != nil {
+		return m.NodeBytes
+	}
+	return 0
+}
+
+// Result of one item of a BatchCreateTrees or BatchUpdateTrees request.
+type BatchTreeResult struct {
+	// The created or updated tree, set if the batch was applied.
+	Tree *Tree `protobuf:"bytes,1,opt,name=tree" json:"tree,omitempty"`
+	// OK if the batch was applied, the item's error if it failed, or ABORTED if
+	// another item of the batch failed.
+	Status *google_rpc.Status `protobuf:"bytes,2,opt,name=status" json:"status,omitempty"`
+}
+
+func (m *BatchTreeResult) Reset()                    { *m = BatchTreeResult{} }
+func (m *BatchTreeResult) String() string            { return proto.CompactTextString(m) }
+func (*BatchTreeResult) ProtoMessage()               {}
+func (*BatchTreeResult) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{10} }
+
+func (m *BatchTreeResult) GetTree() *Tree {
+	if m != nil {
+		return m.Tree
+	}
+	return nil
+}
+
+func (m *BatchTreeResult) GetStatus() *google_rpc.Status {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+// BatchCreateTrees request.
+type BatchCreateTreesRequest struct {
+	// Trees to be created. See CreateTree for more details.
+	Requests []*CreateTreeRequest `protobuf:"bytes,1,rep,name=requests" json:"requests,omitempty"`
+}
+
+func (m *BatchCreateTreesRequest) Reset()                    { *m = BatchCreateTreesRequest{} }
+func (m *BatchCreateTreesRequest) String() string            { return proto.CompactTextString(m) }
+func (*BatchCreateTreesRequest) ProtoMessage()               {}
+func (*BatchCreateTreesRequest) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{11} }
+
+func (m *BatchCreateTreesRequest) GetRequests() []*CreateTreeRequest {
+	if m != nil {
+		return m.Requests
+	}
+	return nil
+}
+
+// BatchCreateTrees response.
+type BatchCreateTreesResponse struct {
+	// Same number and order as the requests.
+	Results []*BatchTreeResult `protobuf:"bytes,1,rep,name=results" json:"results,omitempty"`
+}
+
+func (m *BatchCreateTreesResponse) Reset()                    { *m = BatchCreateTreesResponse{} }
+func (m *BatchCreateTreesResponse) String() string            { return proto.CompactTextString(m) }
+func (*BatchCreateTreesResponse) ProtoMessage()               {}
+func (*BatchCreateTreesResponse) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{12} }
+
+func (m *BatchCreateTreesResponse) GetResults() []*BatchTreeResult {
+	if m != nil {
+		return m.Results
+	}
+	return nil
+}
+
+// BatchUpdateTrees request.
+type BatchUpdateTreesRequest struct {
+	// Trees to be updated. See UpdateTree for more details.
+	Requests []*UpdateTreeRequest `protobuf:"bytes,1,rep,name=requests" json:"requests,omitempty"`
+}
+
+func (m *BatchUpdateTreesRequest) Reset()                    { *m = BatchUpdateTreesRequest{} }
+func (m *BatchUpdateTreesRequest) String() string            { return proto.CompactTextString(m) }
+func (*BatchUpdateTreesRequest) ProtoMessage()               {}
+func (*BatchUpdateTreesRequest) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{13} }
+
+func (m *BatchUpdateTreesRequest) GetRequests() []*UpdateTreeRequest {
+	if m != nil {
+		return m.Requests
+	}
+	return nil
+}
+
+// BatchUpdateTrees response.
+type BatchUpdateTreesResponse struct {
+	// Same number and order as the requests.
+	Results []*BatchTreeResult `protobuf:"bytes,1,rep,name=results" json:"results,omitempty"`
+}
+
+func (m *BatchUpdateTreesResponse) Reset()                    { *m = BatchUpdateTreesResponse{} }
+func (m *BatchUpdateTreesResponse) String() string            { return proto.CompactTextString(m) }
+func (*BatchUpdateTreesResponse) ProtoMessage()               {}
+func (*BatchUpdateTreesResponse) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{14} }
+
+func (m *BatchUpdateTreesResponse) GetResults() []*BatchTreeResult {
+	if m != nil {
+		return m.Results
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*ListTreesRequest)(nil), "trillian.ListTreesRequest")
 	proto.RegisterType((*ListTreesResponse)(nil), "trillian.ListTreesResponse")
@@ -139,6 +391,15 @@ func init() {
 	proto.RegisterType((*CreateTreeRequest)(nil), "trillian.CreateTreeRequest")
 	proto.RegisterType((*UpdateTreeRequest)(nil), "trillian.UpdateTreeRequest")
 	proto.RegisterType((*DeleteTreeRequest)(nil), "trillian.DeleteTreeRequest")
+	proto.RegisterType((*SequenceLogRequest)(nil), "trillian.SequenceLogRequest")
+	proto.RegisterType((*SequenceLogResponse)(nil), "trillian.SequenceLogResponse")
+	proto.RegisterType((*GetTreeStatsRequest)(nil), "trillian.GetTreeStatsRequest")
+	proto.RegisterType((*GetTreeStatsResponse)(nil), "trillian.GetTreeStatsResponse")
+	proto.RegisterType((*BatchTreeResult)(nil), "trillian.BatchTreeResult")
+	proto.RegisterType((*BatchCreateTreesRequest)(nil), "trillian.BatchCreateTreesRequest")
+	proto.RegisterType((*BatchCreateTreesResponse)(nil), "trillian.BatchCreateTreesResponse")
+	proto.RegisterType((*BatchUpdateTreesRequest)(nil), "trillian.BatchUpdateTreesRequest")
+	proto.RegisterType((*BatchUpdateTreesResponse)(nil), "trillian.BatchUpdateTreesResponse")
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -169,6 +430,27 @@ type TrillianAdminClient interface {
 	// it'll be permanently deleted.
 	// TODO(codingllama): Provide an undelete RPC.
 	DeleteTree(ctx context.Context, in *DeleteTreeRequest, opts ...grpc.CallOption) (*google_protobuf2.Empty, error)
+	// Sequences and signs a log's queued leaves now, rather than waiting for the
+	// signer's next pass, e.g. after a bulk import. Returns the resulting root.
+	// A FROZEN log may be sequenced until it has been finalized, which happens
+	// once its queue has been drained.
+	// Requires a signer to be reachable from the server.
+	SequenceLog(ctx context.Context, in *SequenceLogRequest, opts ...grpc.CallOption) (*SequenceLogResponse, error)
+	// Returns the size of a log, its queue of unsequenced leaves and the time its
+	// latest root was signed, so operators can check that it's keeping up.
+	GetTreeStats(ctx context.Context, in *GetTreeStatsRequest, opts ...grpc.CallOption) (*GetTreeStatsResponse, error)
+	// Creates up to 1000 trees in one admin storage transaction, e.g. when
+	// provisioning a new tenant. If any of them can't be created none is, and
+	// the result of each tree says which failed and why. The RPC itself only
+	// fails if the batch is too big or the transaction can't be committed.
+	// Batches fail with FAILED_PRECONDITION on storage which can't apply them
+	// atomically: the DynamoDB and Bigtable storage, whose transactions write
+	// straight away, and MySQL storage split across databases if a batch spans
+	// more than one of them.
+	BatchCreateTrees(ctx context.Context, in *BatchCreateTreesRequest, opts ...grpc.CallOption) (*BatchCreateTreesResponse, error)
+	// Updates up to 1000 trees in one admin storage transaction, with the same
+	// all-or-nothing semantics as BatchCreateTrees.
+	BatchUpdateTrees(ctx context.Context, in *BatchUpdateTreesRequest, opts ...grpc.CallOption) (*BatchUpdateTreesResponse, error)
 }
 
 type trillianAdminClient struct {
@@ -224,6 +506,42 @@ func (c *trillianAdminClient) DeleteTree(ctx context.Context, in *DeleteTreeRequ
 	return out, nil
 }
 
+func (c *trillianAdminClient) SequenceLog(ctx context.Context, in *SequenceLogRequest, opts ...grpc.CallOption) (*SequenceLogResponse, error) {
+	out := new(SequenceLogResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianAdmin/SequenceLog", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianAdminClient) GetTreeStats(ctx context.Context, in *GetTreeStatsRequest, opts ...grpc.CallOption) (*GetTreeStatsResponse, error) {
+	out := new(GetTreeStatsResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianAdmin/GetTreeStats", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianAdminClient) BatchCreateTrees(ctx context.Context, in *BatchCreateTreesRequest, opts ...grpc.CallOption) (*BatchCreateTreesResponse, error) {
+	out := new(BatchCreateTreesResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianAdmin/BatchCreateTrees", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianAdminClient) BatchUpdateTrees(ctx context.Context, in *BatchUpdateTreesRequest, opts ...grpc.CallOption) (*BatchUpdateTreesResponse, error) {
+	out := new(BatchUpdateTreesResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianAdmin/BatchUpdateTrees", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for TrillianAdmin service
 
 type TrillianAdminServer interface {
@@ -244,6 +562,27 @@ type TrillianAdminServer interface {
 	// it'll be permanently deleted.
 	// TODO(codingllama): Provide an undelete RPC.
 	DeleteTree(context.Context, *DeleteTreeRequest) (*google_protobuf2.Empty, error)
+	// Sequences and signs a log's queued leaves now, rather than waiting for the
+	// signer's next pass, e.g. after a bulk import. Returns the resulting root.
+	// A FROZEN log may be sequenced until it has been finalized, which happens
+	// once its queue has been drained.
+	// Requires a signer to be reachable from the server.
+	SequenceLog(context.Context, *SequenceLogRequest) (*SequenceLogResponse, error)
+	// Returns the size of a log, its queue of unsequenced leaves and the time its
+	// latest root was signed, so operators can check that it's keeping up.
+	GetTreeStats(context.Context, *GetTreeStatsRequest) (*GetTreeStatsResponse, error)
+	// Creates up to 1000 trees in one admin storage transaction, e.g. when
+	// provisioning a new tenant. If any of them can't be created none is, and
+	// the result of each tree says which failed and why. The RPC itself only
+	// fails if the batch is too big or the transaction can't be committed.
+	// Batches fail with FAILED_PRECONDITION on storage which can't apply them
+	// atomically: the DynamoDB and Bigtable storage, whose transactions write
+	// straight away, and MySQL storage split across databases if a batch spans
+	// more than one of them.
+	BatchCreateTrees(context.Context, *BatchCreateTreesRequest) (*BatchCreateTreesResponse, error)
+	// Updates up to 1000 trees in one admin storage transaction, with the same
+	// all-or-nothing semantics as BatchCreateTrees.
+	BatchUpdateTrees(context.Context, *BatchUpdateTreesRequest) (*BatchUpdateTreesResponse, error)
 }
 
 func RegisterTrillianAdminServer(s *grpc.Server, srv TrillianAdminServer) {
@@ -340,6 +679,78 @@ func _TrillianAdmin_DeleteTree_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _TrillianAdmin_SequenceLog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SequenceLogRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianAdminServer).SequenceLog(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianAdmin/SequenceLog",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianAdminServer).SequenceLog(ctx, req.(*SequenceLogRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianAdmin_GetTreeStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTreeStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianAdminServer).GetTreeStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianAdmin/GetTreeStats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianAdminServer).GetTreeStats(ctx, req.(*GetTreeStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianAdmin_BatchCreateTrees_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchCreateTreesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianAdminServer).BatchCreateTrees(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianAdmin/BatchCreateTrees",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianAdminServer).BatchCreateTrees(ctx, req.(*BatchCreateTreesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianAdmin_BatchUpdateTrees_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchUpdateTreesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianAdminServer).BatchUpdateTrees(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianAdmin/BatchUpdateTrees",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianAdminServer).BatchUpdateTrees(ctx, req.(*BatchUpdateTreesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _TrillianAdmin_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "trillian.TrillianAdmin",
 	HandlerType: (*TrillianAdminServer)(nil),
@@ -364,6 +775,22 @@ var _TrillianAdmin_serviceDesc = grpc.ServiceDesc{
 			MethodName: "DeleteTree",
 			Handler:    _TrillianAdmin_DeleteTree_Handler,
 		},
+		{
+			MethodName: "SequenceLog",
+			Handler:    _TrillianAdmin_SequenceLog_Handler,
+		},
+		{
+			MethodName: "GetTreeStats",
+			Handler:    _TrillianAdmin_GetTreeStats_Handler,
+		},
+		{
+			MethodName: "BatchCreateTrees",
+			Handler:    _TrillianAdmin_BatchCreateTrees_Handler,
+		},
+		{
+			MethodName: "BatchUpdateTrees",
+			Handler:    _TrillianAdmin_BatchUpdateTrees_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "trillian_admin_api.proto",
@@ -372,28 +799,54 @@ var _TrillianAdmin_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("trillian_admin_api.proto", fileDescriptor2) }
 
 var fileDescriptor2 = []byte{
-	// 362 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x93, 0xc1, 0x4f, 0xc2, 0x30,
-	0x14, 0xc6, 0x19, 0x18, 0xd0, 0x47, 0x24, 0xd0, 0x83, 0xce, 0x72, 0x21, 0x3d, 0x61, 0x62, 0x46,
-	0x82, 0x31, 0x1e, 0x38, 0x09, 0x8a, 0x31, 0xd1, 0x84, 0x2c, 0x78, 0x5e, 0x86, 0x7b, 0x90, 0x86,
-	0x8d, 0xcd, 0xb5, 0x1c, 0xfc, 0x23, 0xfd, 0x9f, 0x4c, 0xbb, 0x8d, 0x0d, 0x06, 0x07, 0x6f, 0x6d,
-	0xbf, 0xef, 0xdb, 0x7b, 0xfd, 0xbd, 0x15, 0x4c, 0x19, 0x73, 0xdf, 0xe7, 0xee, 0xc6, 0x71, 0xbd,
-	0x80, 0x6f, 0x1c, 0x37, 0xe2, 0x56, 0x14, 0x87, 0x32, 0x24, 0xe7, 0x99, 0x42, 0x5b, 0xd9, 0x2a,
-	0x51, 0x68, 0x6f, 0x15, 0x86, 0x2b, 0x1f, 0x07, 0x7a, 0xb7, 0xd8, 0x2e, 0x07, 0x4b, 0x8e, 0xbe,
-	0xe7, 0x04, 0xae, 0x58, 0xa7, 0x8e, 0xee, 0xa1, 0x03, 0x83, 0x48, 0xfe, 0x24, 0x22, 0x23, 0xd0,
-	0x7e, 0xe7, 0x42, 0xce, 0x63, 0x44, 0x61, 0xe3, 0xf7, 0x16, 0x85, 0x64, 0x8f, 0xd0, 0x29, 0x9c,
-	0x89, 0x28, 0xdc, 0x08, 0x24, 0x0c, 0xce, 0x64, 0x8c, 0x68, 0x1a, 0xbd, 0x5a, 0xbf, 0x39, 0x6c,
-	0x59, 0xbb, 0x36, 0x94, 0xcd, 0xd6, 0x1a, 0xbb, 0x85, 0xd6, 0x2b, 0xea, 0x5c, 0xfa, 0x29, 0x72,
-	0x0d, 0x0d, 0xa5, 0x38, 0xdc, 0x33, 0x8d, 0x9e, 0xd1, 0xaf, 0xd9, 0x75, 0xb5, 0x7d, 0xf3, 0x54,
-	0x8d, 0x49, 0x8c, 0xae, 0xc4, 0xa2, 0x3b, 0xaf, 0x61, 0x9c, 0xac, 0x21, 0xa1, 0xf3, 0x19, 0x79,
-	0xff, 0x0f, 0x92, 0x11, 0x34, 0xb7, 0x3a, 0xa8, 0xd9, 0x98, 0x55, 0x6d, 0xa5, 0x56, 0x02, 0xc7,
-	0xca, 0xe0, 0x58, 0x53, 0x85, 0xef, 0xc3, 0x15, 0x6b, 0x1b, 0x12, 0xbb, 0x5a, 0xb3, 0x3b, 0xe8,
-	0x3c, 0xa3, 0x8f, 0xfb, 0x55, 0x4f, 0x5d, 0x6e, 0xf8, 0x5b, 0x85, 0xcb, 0x79, 0xda, 0xc2, 0x93,
-	0x9a, 0x24, 0x99, 0xc2, 0xc5, 0x0e, 0x29, 0xa1, 0x79, 0x7f, 0x87, 0xec, 0x69, 0xf7, 0xa8, 0x96,
-	0xcc, 0x80, 0x55, 0xc8, 0x03, 0x34, 0x52, 0xc2, 0xc4, 0xcc, 0x9d, 0xfb, 0xd0, 0xe9, 0xc1, 0xfd,
-	0x59, 0x85, 0x8c, 0x00, 0x72, 0xda, 0xa4, 0x50, 0xa3, 0x34, 0x83, 0xe3, 0xe1, 0x9c, 0x78, 0x31,
-	0x5c, 0x9a, 0xc3, 0x91, 0xf0, 0x04, 0x20, 0x07, 0x57, 0x0c, 0x97, 0x70, 0xd2, 0xab, 0xd2, 0x2c,
-	0x5e, 0xd4, 0x8f, 0xca, 0x2a, 0xe3, 0x01, 0xdc, 0x7c, 0x85, 0x41, 0x26, 0xef, 0x3f, 0x80, 0x71,
-	0x7b, 0x47, 0x3a, 0xe2, 0x33, 0x75, 0x32, 0x33, 0x16, 0x75, 0x2d, 0xdd, 0xff, 0x05, 0x00, 0x00,
-	0xff, 0xff, 0x31, 0x03, 0x1d, 0xc3, 0x51, 0x03, 0x00, 0x00,
+	// 783 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa5, 0x56, 0xcb, 0x4e, 0xdb, 0x40,
+	0x14, 0x25, 0x04, 0x42, 0x72, 0xc3, 0x23, 0x19, 0x68, 0x13, 0x1c, 0xa8, 0xa8, 0xbb, 0x81, 0x3e,
+	0x9c, 0x2a, 0xa8, 0x62, 0xc1, 0xa2, 0x22, 0xb4, 0x54, 0x95, 0xd2, 0x82, 0x9c, 0xb0, 0x6a, 0x25,
+	0xcb, 0xc4, 0x83, 0x6b, 0xd5, 0xf1, 0xb8, 0x1e, 0x07, 0x89, 0xee, 0xfa, 0x0d, 0x5d, 0xf5, 0x07,
+	0xfa, 0x9d, 0x9d, 0x19, 0x8f, 0x1f, 0x89, 0x13, 0x8a, 0xda, 0x0d, 0xf2, 0xdc, 0x73, 0xce, 0x9d,
+	0x3b, 0xf7, 0x15, 0xa0, 0x19, 0x06, 0x8e, 0xeb, 0x3a, 0xa6, 0x67, 0x98, 0xd6, 0xc8, 0x61, 0x7f,
+	0x7d, 0x47, 0xf3, 0x03, 0x12, 0x12, 0x54, 0x8e, 0x11, 0x65, 0x3d, 0xfe, 0x8a, 0x10, 0x65, 0xcf,
+	0x26, 0xc4, 0x76, 0x71, 0x5b, 0x9c, 0xae, 0xc6, 0xd7, 0xed, 0x6b, 0x07, 0xbb, 0x96, 0x31, 0x32,
+	0xe9, 0x57, 0xc9, 0x68, 0x4d, 0x33, 0xf0, 0xc8, 0x0f, 0x6f, 0x25, 0xd8, 0x90, 0x60, 0xe0, 0x0f,
+	0xdb, 0x34, 0x34, 0xc3, 0x31, 0x8d, 0x00, 0x15, 0x41, 0xad, 0xe7, 0xd0, 0x70, 0x10, 0x60, 0x4c,
+	0x75, 0xfc, 0x6d, 0x8c, 0x69, 0xa8, 0x1e, 0x41, 0x3d, 0x63, 0xa3, 0x3e, 0xf1, 0x28, 0x46, 0x2a,
+	0x2c, 0x85, 0xcc, 0xd0, 0x2c, 0xec, 0x15, 0xf7, 0xab, 0x9d, 0x75, 0x2d, 0x89, 0x8f, 0xd3, 0x74,
+	0x81, 0xa9, 0x07, 0xb0, 0xfe, 0x0e, 0x0b, 0x9d, 0x74, 0x85, 0x1a, 0xb0, 0xc2, 0x11, 0xc3, 0xb1,
+	0x98, 0xb0, 0xb0, 0x5f, 0xd4, 0x4b, 0xfc, 0xf8, 0xde, 0x52, 0x3f, 0x43, 0xfd, 0x34, 0xc0, 0x66,
+	0x88, 0xb3, 0xec, 0xf4, 0x8e, 0xc2, 0xbc, 0x3b, 0xd0, 0x13, 0x58, 0xbb, 0x31, 0x5d, 0xc7, 0x62,
+	0x52, 0x83, 0x78, 0xee, 0x6d, 0x73, 0x91, 0x91, 0xcb, 0xfa, 0x6a, 0x6c, 0x3c, 0x67, 0x36, 0xf5,
+	0x57, 0x01, 0xea, 0x97, 0xbe, 0xf5, 0x0f, 0xee, 0x8f, 0xa1, 0x3a, 0x16, 0x42, 0x91, 0x5a, 0xe1,
+	0xbc, 0xda, 0x51, 0xb4, 0x28, 0x7d, 0x5a, 0x9c, 0x5b, 0xed, 0x8c, 0x67, 0xff, 0x03, 0x63, 0xe8,
+	0x10, 0xd1, 0xf9, 0x77, 0x3e, 0xb6, 0xe2, 0x8c, 0xd8, 0x9e, 0x43, 0xfd, 0x0d, 0x76, 0xf1, 0x64,
+	0x68, 0x73, 0xf3, 0xf4, 0x0c, 0x50, 0x9f, 0x73, 0xbc, 0x21, 0xee, 0x11, 0x3b, 0xa6, 0x3f, 0x80,
+	0x92, 0x4b, 0xec, 0x94, 0xbd, 0xcc, 0x4e, 0x8c, 0xfc, 0xa3, 0x00, 0x9b, 0x13, 0x6c, 0x59, 0xbb,
+	0x03, 0xa8, 0xb9, 0xd8, 0xbc, 0xc1, 0xd4, 0xa0, 0x12, 0x8d, 0x85, 0x1b, 0x91, 0x3d, 0x16, 0x59,
+	0xe8, 0x35, 0x6c, 0x50, 0xc7, 0xf6, 0xb0, 0x65, 0xf0, 0x0b, 0x02, 0x42, 0x42, 0x99, 0x83, 0x46,
+	0x9a, 0xae, 0xbe, 0x20, 0xf0, 0x0b, 0x18, 0xac, 0xaf, 0xd1, 0xec, 0x51, 0xd5, 0x60, 0x53, 0xf6,
+	0x40, 0x9f, 0xf5, 0x19, 0xfd, 0xeb, 0x03, 0x7f, 0x2e, 0xc2, 0xd6, 0xa4, 0x40, 0x06, 0xdd, 0x82,
+	0x8a, 0x50, 0x50, 0xe7, 0x3b, 0x96, 0x9a, 0x32, 0x37, 0xf4, 0xd9, 0x19, 0xbd, 0x00, 0x34, 0xf6,
+	0x92, 0xc7, 0x18, 0xd1, 0x2b, 0x44, 0xa4, 0x45, 0xbd, 0x9e, 0x41, 0x7a, 0x02, 0x60, 0xaf, 0xda,
+	0x21, 0xae, 0xc5, 0xe2, 0x30, 0xb2, 0x2a, 0xd3, 0xc6, 0x86, 0x67, 0x7a, 0x84, 0x8a, 0x3a, 0x15,
+	0xf5, 0xed, 0x88, 0x73, 0x99, 0x52, 0x4e, 0x6c, 0xfc, 0x91, 0x13, 0xd0, 0x4b, 0xd8, 0xe2, 0xb9,
+	0x30, 0x42, 0x67, 0xc4, 0x18, 0xe6, 0xc8, 0x97, 0xc2, 0x25, 0x21, 0x44, 0x1c, 0x1b, 0xc4, 0x50,
+	0xa4, 0xd8, 0x05, 0x60, 0x51, 0x5d, 0x1b, 0x57, 0xb7, 0x21, 0x8b, 0x6c, 0x59, 0xf0, 0x2a, 0xdc,
+	0xd2, 0xe5, 0x06, 0x0e, 0x7b, 0xc4, 0xc2, 0x12, 0x2e, 0x45, 0x30, 0xb7, 0x08, 0x58, 0x35, 0x61,
+	0xa3, 0x6b, 0x86, 0xc3, 0x2f, 0x51, 0x8f, 0xd0, 0xb1, 0x7b, 0xbf, 0xee, 0x7d, 0x0a, 0xa5, 0x68,
+	0xba, 0x65, 0xd1, 0x50, 0xdc, 0xb8, 0x6c, 0xee, 0xb5, 0xbe, 0x40, 0x74, 0xc9, 0x50, 0x75, 0x68,
+	0x88, 0x2b, 0xd2, 0x31, 0x4c, 0x8a, 0x75, 0x04, 0xe5, 0x20, 0xfa, 0xa4, 0x72, 0xde, 0x5b, 0xe9,
+	0x75, 0xb9, 0xb1, 0xd5, 0x13, 0xb2, 0x7a, 0x0e, 0xcd, 0xbc, 0x4f, 0x59, 0xcf, 0x43, 0x58, 0x09,
+	0xc4, 0x4b, 0x62, 0x9f, 0xdb, 0xa9, 0xcf, 0xa9, 0xb7, 0xea, 0x31, 0x33, 0x09, 0x32, 0x1d, 0xe6,
+	0xfb, 0x05, 0x99, 0x1b, 0xfe, 0x19, 0x41, 0x4e, 0xf8, 0xfc, 0x8f, 0x20, 0x3b, 0xbf, 0x97, 0x61,
+	0x6d, 0x20, 0x59, 0x27, 0x7c, 0xa3, 0xa3, 0x33, 0xa8, 0x24, 0x1b, 0x14, 0x29, 0xa9, 0x8b, 0xe9,
+	0x55, 0xab, 0xb4, 0x66, 0x62, 0x51, 0x30, 0xea, 0x02, 0x7a, 0x05, 0x2b, 0x72, 0x36, 0x50, 0x33,
+	0x65, 0x4e, 0xee, 0x58, 0x65, 0xaa, 0x15, 0x98, 0xec, 0x18, 0x20, 0xad, 0x00, 0xba, 0xab, 0x76,
+	0xb3, 0xc5, 0x69, 0x66, 0xd0, 0x5d, 0x39, 0x9d, 0x21, 0x3e, 0x05, 0x48, 0x97, 0x5b, 0x56, 0x9c,
+	0x5b, 0x79, 0xca, 0xc3, 0xdc, 0x52, 0x7d, 0xcb, 0x7f, 0xb0, 0x98, 0x93, 0x1e, 0x54, 0x33, 0x5b,
+	0x0c, 0xed, 0x64, 0x36, 0x4f, 0x6e, 0x15, 0x2a, 0xbb, 0x73, 0xd0, 0x24, 0x87, 0xe7, 0xb0, 0x9a,
+	0xdd, 0x2f, 0x68, 0x37, 0x97, 0xc8, 0xec, 0xa2, 0x52, 0x1e, 0xcd, 0x83, 0x13, 0x87, 0x9f, 0xa0,
+	0x36, 0xdd, 0xe4, 0xe8, 0xf1, 0x54, 0x9b, 0xe4, 0x87, 0x4a, 0x51, 0xef, 0xa2, 0xe4, 0x9c, 0x67,
+	0x9a, 0x33, 0xe7, 0x3c, 0x3f, 0x0c, 0x39, 0xe7, 0x33, 0x7a, 0x5b, 0x5d, 0xe8, 0xb6, 0x61, 0x7b,
+	0x48, 0x46, 0x71, 0xde, 0x27, 0xff, 0xc3, 0xe8, 0xd6, 0x92, 0x16, 0xf6, 0x9d, 0x0b, 0x6e, 0xb9,
+	0x28, 0x5c, 0x95, 0x04, 0x74, 0xf8, 0x07, 0xae, 0x8c, 0xd4, 0x2d, 0xb2, 0x08, 0x00, 0x00,
 }
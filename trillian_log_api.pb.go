@@ -36,6 +36,15 @@ It has these top-level messages:
 	GetLatestSignedLogRootResponse
 	GetEntryAndProofRequest
 	GetEntryAndProofResponse
+	AddSequencedLeavesRequest
+	AddSequencedLeavesResponse
+	WitnessSignature
+	AddWitnessSignatureRequest
+	AddWitnessSignatureResponse
+	AddObservedRootRequest
+	AddObservedRootResponse
+	GetConsistencyProofHistoryRequest
+	GetConsistencyProofHistoryResponse
 	MapLeaf
 	MapLeafInclusion
 	GetMapLeavesRequest
@@ -56,6 +65,8 @@ It has these top-level messages:
 	MapperMetadata
 	SignedMapRoot
 	PEMKeyFile
+	PrivateKey
+	PublicKey
 */
 package trillian
 
@@ -63,6 +74,7 @@ import proto "github.com/golang/protobuf/proto"
 import fmt "fmt"
 import math "math"
 import google_rpc "google.golang.org/genproto/googleapis/rpc/status"
+import sigpb "github.com/google/trillian/crypto/sigpb"
 
 import (
 	context "golang.org/x/net/context"
@@ -107,6 +119,10 @@ type LogLeaf struct {
 	// personality which fetches and submits the entries might set
 	// leaf_identity_hash to H(seq||certdata).
 	LeafIdentityHash []byte `protobuf:"bytes,5,opt,name=leaf_identity_hash,json=leafIdentityHash,proto3" json:"leaf_identity_hash,omitempty"`
+	// expired is set when leaf_value and extra_data have been pruned because the
+	// leaf is older than the log's leaf_retention_seconds. The hashes and index
+	// of expired leaves are kept.
+	Expired bool `protobuf:"varint,6,opt,name=expired" json:"expired,omitempty"`
 }
 
 func (m *LogLeaf) Reset()                    { *m = LogLeaf{} }
@@ -149,6 +165,13 @@ func (m *LogLeaf) GetLeafIdentityHash() []byte {
 	return nil
 }
 
+func (m *LogLeaf) GetExpired() bool {
+	if m != nil {
+		return m.Expired
+	}
+	return false
+}
+
 type Node struct {
 	// TODO(Martin2112): remove node_id and node_revision
 	NodeId       []byte `protobuf:"bytes,1,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
@@ -285,6 +308,12 @@ func (m *QueueLeafRequest) GetLeaf() *LogLeaf {
 
 type QueueLeafResponse struct {
 	QueuedLeaf *QueuedLogLeaf `protobuf:"bytes,2,opt,name=queued_leaf,json=queuedLeaf" json:"queued_leaf,omitempty"`
+	// log_id is the log the leaf was queued to, which is the active shard when
+	// the requested log is sharded (see Tree.shard_set_id).
+	LogId int64 `protobuf:"varint,3,opt,name=log_id,json=logId" json:"log_id,omitempty"`
+	// See QueueLeavesResponse.
+	QueueTimestampNanos  int64 `protobuf:"varint,4,opt,name=queue_timestamp_nanos,json=queueTimestampNanos" json:"queue_timestamp_nanos,omitempty"`
+	MaxMergeDelaySeconds int32 `protobuf:"varint,5,opt,name=max_merge_delay_seconds,json=maxMergeDelaySeconds" json:"max_merge_delay_seconds,omitempty"`
 }
 
 func (m *QueueLeafResponse) Reset()                    { *m = QueueLeafResponse{} }
@@ -299,9 +328,39 @@ func (m *QueueLeafResponse) GetQueuedLeaf() *QueuedLogLeaf {
 	return nil
 }
 
+func (m *QueueLeafResponse) GetLogId() int64 {
+	if m != nil {
+		return m.LogId
+	}
+	return 0
+}
+
+func (m *QueueLeafResponse) GetQueueTimestampNanos() int64 {
+	if m != nil {
+		return m.QueueTimestampNanos
+	}
+	return 0
+}
+
+func (m *QueueLeafResponse) GetMaxMergeDelaySeconds() int32 {
+	if m != nil {
+		return m.MaxMergeDelaySeconds
+	}
+	return 0
+}
+
 type QueueLeavesResponse struct {
 	// Same number and order as in the corresponding request.
 	QueuedLeaves []*QueuedLogLeaf `protobuf:"bytes,2,rep,name=queued_leaves,json=queuedLeaves" json:"queued_leaves,omitempty"`
+	// log_id is the log the leaves were queued to, which is the active shard when
+	// the requested log is sharded (see Tree.shard_set_id).
+	LogId int64 `protobuf:"varint,3,opt,name=log_id,json=logId" json:"log_id,omitempty"`
+	// queue_timestamp_nanos is the time the server queued the new leaves at.
+	QueueTimestampNanos int64 `protobuf:"varint,4,opt,name=queue_timestamp_nanos,json=queueTimestampNanos" json:"queue_timestamp_nanos,omitempty"`
+	// max_merge_delay_seconds is the log's Tree.max_merge_delay_seconds: new leaves
+	// are integrated by queue_timestamp_nanos plus this. Zero if the log has no
+	// maximum merge delay, or the server doesn't report it.
+	MaxMergeDelaySeconds int32 `protobuf:"varint,5,opt,name=max_merge_delay_seconds,json=maxMergeDelaySeconds" json:"max_merge_delay_seconds,omitempty"`
 }
 
 func (m *QueueLeavesResponse) Reset()                    { *m = QueueLeavesResponse{} }
@@ -316,6 +375,27 @@ func (m *QueueLeavesResponse) GetQueuedLeaves() []*QueuedLogLeaf {
 	return nil
 }
 
+func (m *QueueLeavesResponse) GetLogId() int64 {
+	if m != nil {
+		return m.LogId
+	}
+	return 0
+}
+
+func (m *QueueLeavesResponse) GetQueueTimestampNanos() int64 {
+	if m != nil {
+		return m.QueueTimestampNanos
+	}
+	return 0
+}
+
+func (m *QueueLeavesResponse) GetMaxMergeDelaySeconds() int32 {
+	if m != nil {
+		return m.MaxMergeDelaySeconds
+	}
+	return 0
+}
+
 type GetInclusionProofRequest struct {
 	LogId     int64 `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
 	LeafIndex int64 `protobuf:"varint,2,opt,name=leaf_index,json=leafIndex" json:"leaf_index,omitempty"`
@@ -474,9 +554,17 @@ func (m *GetConsistencyProofResponse) GetProof() *Proof {
 }
 
 type GetLeavesByHashRequest struct {
-	LogId           int64    `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
-	LeafHash        [][]byte `protobuf:"bytes,2,rep,name=leaf_hash,json=leafHash,proto3" json:"leaf_hash,omitempty"`
-	OrderBySequence bool     `protobuf:"varint,3,opt,name=order_by_sequence,json=orderBySequence" json:"order_by_sequence,omitempty"`
+	LogId    int64    `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
+	LeafHash [][]byte `protobuf:"bytes,2,rep,name=leaf_hash,json=leafHash,proto3" json:"leaf_hash,omitempty"`
+	// order_by_sequence returns the leaves in increasing leaf index order, rather
+	// than in whatever order storage finds them.
+	OrderBySequence bool `protobuf:"varint,3,opt,name=order_by_sequence,json=orderBySequence" json:"order_by_sequence,omitempty"`
+	// earliest_only returns just the leaf with the lowest index for each hash,
+	// rather than every leaf with it, for logs which allow duplicate leaves.
+	EarliestOnly bool `protobuf:"varint,4,opt,name=earliest_only,json=earliestOnly" json:"earliest_only,omitempty"`
+	// tree_size pins the read to the log's root of that size: leaves integrated
+	// after it aren't returned. Zero reads from the latest root.
+	TreeSize int64 `protobuf:"varint,5,opt,name=tree_size,json=treeSize" json:"tree_size,omitempty"`
 }
 
 func (m *GetLeavesByHashRequest) Reset()                    { *m = GetLeavesByHashRequest{} }
@@ -505,9 +593,30 @@ func (m *GetLeavesByHashRequest) GetOrderBySequence() bool {
 	return false
 }
 
+func (m *GetLeavesByHashRequest) GetEarliestOnly() bool {
+	if m != nil {
+		return m.EarliestOnly
+	}
+	return false
+}
+
+func (m *GetLeavesByHashRequest) GetTreeSize() int64 {
+	if m != nil {
+		return m.TreeSize
+	}
+	return 0
+}
+
 type GetLeavesByHashResponse struct {
 	// TODO(gbelvin) reply with error codes.
 	Leaves []*LogLeaf `protobuf:"bytes,2,rep,name=leaves" json:"leaves,omitempty"`
+	// log_id is the log the leaves were found in. When the requested log is
+	// sharded, its shards are searched newest first, each for the hashes not
+	// found in a newer one, and log_id is the newest holding any of the leaves.
+	LogId int64 `protobuf:"varint,3,opt,name=log_id,json=logId" json:"log_id,omitempty"`
+	// leaf_log_ids holds the log each of leaves was found in, in the same order,
+	// if they weren't all found in log_id.
+	LeafLogIds []int64 `protobuf:"varint,4,rep,packed,name=leaf_log_ids,json=leafLogIds" json:"leaf_log_ids,omitempty"`
 }
 
 func (m *GetLeavesByHashResponse) Reset()                    { *m = GetLeavesByHashResponse{} }
@@ -522,9 +631,27 @@ func (m *GetLeavesByHashResponse) GetLeaves() []*LogLeaf {
 	return nil
 }
 
+func (m *GetLeavesByHashResponse) GetLogId() int64 {
+	if m != nil {
+		return m.LogId
+	}
+	return 0
+}
+
+func (m *GetLeavesByHashResponse) GetLeafLogIds() []int64 {
+	if m != nil {
+		return m.LeafLogIds
+	}
+	return nil
+}
+
 type GetLeavesByIndexRequest struct {
 	LogId     int64   `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
 	LeafIndex []int64 `protobuf:"varint,2,rep,packed,name=leaf_index,json=leafIndex" json:"leaf_index,omitempty"`
+	// tree_size pins the read to the log's root of that size, so pages of leaves
+	// read in separate requests come from the same tree. Asking for a leaf beyond
+	// it fails with OUT_OF_RANGE. Zero reads from the latest root.
+	TreeSize int64 `protobuf:"varint,3,opt,name=tree_size,json=treeSize" json:"tree_size,omitempty"`
 }
 
 func (m *GetLeavesByIndexRequest) Reset()                    { *m = GetLeavesByIndexRequest{} }
@@ -546,6 +673,13 @@ func (m *GetLeavesByIndexRequest) GetLeafIndex() []int64 {
 	return nil
 }
 
+func (m *GetLeavesByIndexRequest) GetTreeSize() int64 {
+	if m != nil {
+		return m.TreeSize
+	}
+	return 0
+}
+
 type GetLeavesByIndexResponse struct {
 	// TODO(gbelvin) reply with error codes.
 	Leaves []*LogLeaf `protobuf:"bytes,2,rep,name=leaves" json:"leaves,omitempty"`
@@ -597,6 +731,13 @@ func (m *GetSequencedLeafCountResponse) GetLeafCount() int64 {
 
 type GetLatestSignedLogRootRequest struct {
 	LogId int64 `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
+	// If witnessed is set, the latest root cosigned by a quorum of witnesses is
+	// returned instead, which may be older than the latest root.
+	Witnessed bool `protobuf:"varint,2,opt,name=witnessed" json:"witnessed,omitempty"`
+	// tree_size asks for the log's root of that size instead of the latest, e.g.
+	// the root other reads pinned to that size are answered against. It can't be
+	// combined with witnessed.
+	TreeSize int64 `protobuf:"varint,3,opt,name=tree_size,json=treeSize" json:"tree_size,omitempty"`
 }
 
 func (m *GetLatestSignedLogRootRequest) Reset()                    { *m = GetLatestSignedLogRootRequest{} }
@@ -611,8 +752,29 @@ func (m *GetLatestSignedLogRootRequest) GetLogId() int64 {
 	return 0
 }
 
+func (m *GetLatestSignedLogRootRequest) GetWitnessed() bool {
+	if m != nil {
+		return m.Witnessed
+	}
+	return false
+}
+
+func (m *GetLatestSignedLogRootRequest) GetTreeSize() int64 {
+	if m != nil {
+		return m.TreeSize
+	}
+	return 0
+}
+
 type GetLatestSignedLogRootResponse struct {
 	SignedLogRoot *SignedLogRoot `protobuf:"bytes,2,opt,name=signed_log_root,json=signedLogRoot" json:"signed_log_root,omitempty"`
+	// witness_signatures holds the witnesses' signatures over signed_log_root.
+	// It's only set for witnessed requests.
+	WitnessSignatures []*WitnessSignature `protobuf:"bytes,3,rep,name=witness_signatures,json=witnessSignatures" json:"witness_signatures,omitempty"`
+	// finalized is set if the log has been finalized after being frozen, and
+	// signed_log_root is its final root: no more leaves will be added to it.
+	// It's only set for requests which aren't witnessed.
+	Finalized bool `protobuf:"varint,4,opt,name=finalized" json:"finalized,omitempty"`
 }
 
 func (m *GetLatestSignedLogRootResponse) Reset()                    { *m = GetLatestSignedLogRootResponse{} }
@@ -627,6 +789,20 @@ func (m *GetLatestSignedLogRootResponse) GetSignedLogRoot() *SignedLogRoot {
 	return nil
 }
 
+func (m *GetLatestSignedLogRootResponse) GetWitnessSignatures() []*WitnessSignature {
+	if m != nil {
+		return m.WitnessSignatures
+	}
+	return nil
+}
+
+func (m *GetLatestSignedLogRootResponse) GetFinalized() bool {
+	if m != nil {
+		return m.Finalized
+	}
+	return false
+}
+
 type GetEntryAndProofRequest struct {
 	LogId     int64 `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
 	LeafIndex int64 `protobuf:"varint,2,opt,name=leaf_index,json=leafIndex" json:"leaf_index,omitempty"`
@@ -683,6 +859,232 @@ func (m *GetEntryAndProofResponse) GetLeaf() *LogLeaf {
 	return nil
 }
 
+type AddSequencedLeavesRequest struct {
+	LogId int64 `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
+	// Each leaf must have its leaf_index set. Together the leaves must cover a
+	// contiguous range of indices.
+	Leaves []*LogLeaf `protobuf:"bytes,2,rep,name=leaves" json:"leaves,omitempty"`
+}
+
+func (m *AddSequencedLeavesRequest) Reset()                    { *m = AddSequencedLeavesRequest{} }
+func (m *AddSequencedLeavesRequest) String() string            { return proto.CompactTextString(m) }
+func (*AddSequencedLeavesRequest) ProtoMessage()               {}
+func (*AddSequencedLeavesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{24} }
+
+func (m *AddSequencedLeavesRequest) GetLogId() int64 {
+	if m != nil {
+		return m.LogId
+	}
+	return 0
+}
+
+func (m *AddSequencedLeavesRequest) GetLeaves() []*LogLeaf {
+	if m != nil {
+		return m.Leaves
+	}
+	return nil
+}
+
+type AddSequencedLeavesResponse struct {
+}
+
+func (m *AddSequencedLeavesResponse) Reset()                    { *m = AddSequencedLeavesResponse{} }
+func (m *AddSequencedLeavesResponse) String() string            { return proto.CompactTextString(m) }
+func (*AddSequencedLeavesResponse) ProtoMessage()               {}
+func (*AddSequencedLeavesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{25} }
+
+// WitnessSignature is a countersignature made by a witness over a log root.
+type WitnessSignature struct {
+	// witness_name identifies the witness, as registered with the log server.
+	WitnessName string `protobuf:"bytes,1,opt,name=witness_name,json=witnessName" json:"witness_name,omitempty"`
+	// signature is over the same data as the log's own signature on the root.
+	Signature *sigpb.DigitallySigned `protobuf:"bytes,2,opt,name=signature" json:"signature,omitempty"`
+}
+
+func (m *WitnessSignature) Reset()                    { *m = WitnessSignature{} }
+func (m *WitnessSignature) String() string            { return proto.CompactTextString(m) }
+func (*WitnessSignature) ProtoMessage()               {}
+func (*WitnessSignature) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{26} }
+
+func (m *WitnessSignature) GetWitnessName() string {
+	if m != nil {
+		return m.WitnessName
+	}
+	return ""
+}
+
+func (m *WitnessSignature) GetSignature() *sigpb.DigitallySigned {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+type AddWitnessSignatureRequest struct {
+	LogId int64 `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
+	// tree_size and root_hash identify the root being cosigned, which must be
+	// the log's latest root.
+	TreeSize         int64             `protobuf:"varint,2,opt,name=tree_size,json=treeSize" json:"tree_size,omitempty"`
+	RootHash         []byte            `protobuf:"bytes,3,opt,name=root_hash,json=rootHash,proto3" json:"root_hash,omitempty"`
+	WitnessSignature *WitnessSignature `protobuf:"bytes,4,opt,name=witness_signature,json=witnessSignature" json:"witness_signature,omitempty"`
+}
+
+func (m *AddWitnessSignatureRequest) Reset()                    { *m = AddWitnessSignatureRequest{} }
+func (m *AddWitnessSignatureRequest) String() string            { return proto.CompactTextString(m) }
+func (*AddWitnessSignatureRequest) ProtoMessage()               {}
+func (*AddWitnessSignatureRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{27} }
+
+func (m *AddWitnessSignatureRequest) GetLogId() int64 {
+	if m != nil {
+		return m.LogId
+	}
+	return 0
+}
+
+func (m *AddWitnessSignatureRequest) GetTreeSize() int64 {
+	if m != nil {
+		return m.TreeSize
+	}
+	return 0
+}
+
+func (m *AddWitnessSignatureRequest) GetRootHash() []byte {
+	if m != nil {
+		return m.RootHash
+	}
+	return nil
+}
+
+func (m *AddWitnessSignatureRequest) GetWitnessSignature() *WitnessSignature {
+	if m != nil {
+		return m.WitnessSignature
+	}
+	return nil
+}
+
+type AddWitnessSignatureResponse struct {
+}
+
+func (m *AddWitnessSignatureResponse) Reset()                    { *m = AddWitnessSignatureResponse{} }
+func (m *AddWitnessSignatureResponse) String() string            { return proto.CompactTextString(m) }
+func (*AddWitnessSignatureResponse) ProtoMessage()               {}
+func (*AddWitnessSignatureResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{28} }
+
+type AddObservedRootRequest struct {
+	LogId int64 `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
+	// signed_log_root is a root of the log seen by the client, e.g. from another
+	// server or via gossip with other clients.
+	SignedLogRoot *SignedLogRoot `protobuf:"bytes,2,opt,name=signed_log_root,json=signedLogRoot" json:"signed_log_root,omitempty"`
+	// consistency_proof proves, for a verification-only log, that
+	// signed_log_root is consistent with the latest root imported to the log,
+	// from the smaller of the two roots to the larger. It's ignored for other
+	// logs, which prove consistency with their own history.
+	ConsistencyProof [][]byte `protobuf:"bytes,3,rep,name=consistency_proof,json=consistencyProof,proto3" json:"consistency_proof,omitempty"`
+}
+
+func (m *AddObservedRootRequest) Reset()                    { *m = AddObservedRootRequest{} }
+func (m *AddObservedRootRequest) String() string            { return proto.CompactTextString(m) }
+func (*AddObservedRootRequest) ProtoMessage()               {}
+func (*AddObservedRootRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{29} }
+
+func (m *AddObservedRootRequest) GetLogId() int64 {
+	if m != nil {
+		return m.LogId
+	}
+	return 0
+}
+
+func (m *AddObservedRootRequest) GetSignedLogRoot() *SignedLogRoot {
+	if m != nil {
+		return m.SignedLogRoot
+	}
+	return nil
+}
+
+func (m *AddObservedRootRequest) GetConsistencyProof() [][]byte {
+	if m != nil {
+		return m.ConsistencyProof
+	}
+	return nil
+}
+
+type AddObservedRootResponse struct {
+	// consistent is false if the root conflicts with the log's own history, which
+	// is evidence that the log has presented a split view.
+	Consistent bool `protobuf:"varint,1,opt,name=consistent" json:"consistent,omitempty"`
+}
+
+func (m *AddObservedRootResponse) Reset()                    { *m = AddObservedRootResponse{} }
+func (m *AddObservedRootResponse) String() string            { return proto.CompactTextString(m) }
+func (*AddObservedRootResponse) ProtoMessage()               {}
+func (*AddObservedRootResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{30} }
+
+func (m *AddObservedRootResponse) GetConsistent() bool {
+	if m != nil {
+		return m.Consistent
+	}
+	return false
+}
+
+type GetConsistencyProofHistoryRequest struct {
+	LogId int64 `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
+	// tree_sizes are the sizes to prove consistency between, in increasing order.
+	TreeSizes []int64 `protobuf:"varint,2,rep,packed,name=tree_sizes,json=treeSizes" json:"tree_sizes,omitempty"`
+}
+
+func (m *GetConsistencyProofHistoryRequest) Reset()                    { *m = GetConsistencyProofHistoryRequest{} }
+func (m *GetConsistencyProofHistoryRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetConsistencyProofHistoryRequest) ProtoMessage()               {}
+func (*GetConsistencyProofHistoryRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{31} }
+
+func (m *GetConsistencyProofHistoryRequest) GetLogId() int64 {
+	if m != nil {
+		return m.LogId
+	}
+	return 0
+}
+
+func (m *GetConsistencyProofHistoryRequest) GetTreeSizes() []int64 {
+	if m != nil {
+		return m.TreeSizes
+	}
+	return nil
+}
+
+type GetConsistencyProofHistoryResponse struct {
+	// proof is the consistency proof from first_tree_size to second_tree_size, which
+	// are neighbouring sizes of the request.
+	FirstTreeSize  int64  `protobuf:"varint,1,opt,name=first_tree_size,json=firstTreeSize" json:"first_tree_size,omitempty"`
+	SecondTreeSize int64  `protobuf:"varint,2,opt,name=second_tree_size,json=secondTreeSize" json:"second_tree_size,omitempty"`
+	Proof          *Proof `protobuf:"bytes,3,opt,name=proof" json:"proof,omitempty"`
+}
+
+func (m *GetConsistencyProofHistoryResponse) Reset()                    { *m = GetConsistencyProofHistoryResponse{} }
+func (m *GetConsistencyProofHistoryResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetConsistencyProofHistoryResponse) ProtoMessage()               {}
+func (*GetConsistencyProofHistoryResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{32} }
+
+func (m *GetConsistencyProofHistoryResponse) GetFirstTreeSize() int64 {
+	if m != nil {
+		return m.FirstTreeSize
+	}
+	return 0
+}
+
+func (m *GetConsistencyProofHistoryResponse) GetSecondTreeSize() int64 {
+	if m != nil {
+		return m.SecondTreeSize
+	}
+	return 0
+}
+
+func (m *GetConsistencyProofHistoryResponse) GetProof() *Proof {
+	if m != nil {
+		return m.Proof
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*LogLeaf)(nil), "trillian.LogLeaf")
 	proto.RegisterType((*Node)(nil), "trillian.Node")
@@ -708,6 +1110,15 @@ func init() {
 	proto.RegisterType((*GetLatestSignedLogRootResponse)(nil), "trillian.GetLatestSignedLogRootResponse")
 	proto.RegisterType((*GetEntryAndProofRequest)(nil), "trillian.GetEntryAndProofRequest")
 	proto.RegisterType((*GetEntryAndProofResponse)(nil), "trillian.GetEntryAndProofResponse")
+	proto.RegisterType((*AddSequencedLeavesRequest)(nil), "trillian.AddSequencedLeavesRequest")
+	proto.RegisterType((*AddSequencedLeavesResponse)(nil), "trillian.AddSequencedLeavesResponse")
+	proto.RegisterType((*WitnessSignature)(nil), "trillian.WitnessSignature")
+	proto.RegisterType((*AddWitnessSignatureRequest)(nil), "trillian.AddWitnessSignatureRequest")
+	proto.RegisterType((*AddWitnessSignatureResponse)(nil), "trillian.AddWitnessSignatureResponse")
+	proto.RegisterType((*AddObservedRootRequest)(nil), "trillian.AddObservedRootRequest")
+	proto.RegisterType((*AddObservedRootResponse)(nil), "trillian.AddObservedRootResponse")
+	proto.RegisterType((*GetConsistencyProofHistoryRequest)(nil), "trillian.GetConsistencyProofHistoryRequest")
+	proto.RegisterType((*GetConsistencyProofHistoryResponse)(nil), "trillian.GetConsistencyProofHistoryResponse")
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -736,6 +1147,28 @@ type TrillianLogClient interface {
 	GetLeavesByIndex(ctx context.Context, in *GetLeavesByIndexRequest, opts ...grpc.CallOption) (*GetLeavesByIndexResponse, error)
 	GetLeavesByHash(ctx context.Context, in *GetLeavesByHashRequest, opts ...grpc.CallOption) (*GetLeavesByHashResponse, error)
 	GetEntryAndProof(ctx context.Context, in *GetEntryAndProofRequest, opts ...grpc.CallOption) (*GetEntryAndProofResponse, error)
+	// AddSequencedLeaves adds leaves whose indices were assigned by the caller to a
+	// PREORDERED_LOG tree. Corresponds to the SequencedLeafAdder API.
+	AddSequencedLeaves(ctx context.Context, in *AddSequencedLeavesRequest, opts ...grpc.CallOption) (*AddSequencedLeavesResponse, error)
+	// AddWitnessSignature stores a registered witness's countersignature over the
+	// log's latest root. Once a quorum of witnesses has cosigned a root it's
+	// returned by witnessed GetLatestSignedLogRoot requests.
+	AddWitnessSignature(ctx context.Context, in *AddWitnessSignatureRequest, opts ...grpc.CallOption) (*AddWitnessSignatureResponse, error)
+	// AddObservedRoot checks a root observed by a client against the log's own
+	// history and records it. Roots which don't match the history are kept as
+	// evidence of a split view.
+	// Verification-only logs have no history of their own, roots signed with
+	// their public key are imported instead: a root proven consistent with the
+	// latest imported root, and newer than it, becomes the log's latest root.
+	AddObservedRoot(ctx context.Context, in *AddObservedRootRequest, opts ...grpc.CallOption) (*AddObservedRootResponse, error)
+	// GetConsistencyProofHistory streams the consistency proofs between each
+	// neighbouring pair of a list of tree sizes, all read from the same snapshot of
+	// the log.
+	GetConsistencyProofHistory(ctx context.Context, in *GetConsistencyProofHistoryRequest, opts ...grpc.CallOption) (TrillianLog_GetConsistencyProofHistoryClient, error)
+	// GetLeavesByIndexStream returns the same leaves as GetLeavesByIndex, split between
+	// as many responses as it takes to keep each within the server's response size
+	// limit. The leaves are all read from the same snapshot of the log.
+	GetLeavesByIndexStream(ctx context.Context, in *GetLeavesByIndexRequest, opts ...grpc.CallOption) (TrillianLog_GetLeavesByIndexStreamClient, error)
 }
 
 type trillianLogClient struct {
@@ -836,6 +1269,97 @@ func (c *trillianLogClient) GetEntryAndProof(ctx context.Context, in *GetEntryAn
 	return out, nil
 }
 
+func (c *trillianLogClient) AddSequencedLeaves(ctx context.Context, in *AddSequencedLeavesRequest, opts ...grpc.CallOption) (*AddSequencedLeavesResponse, error) {
+	out := new(AddSequencedLeavesResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLog/AddSequencedLeaves", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) AddWitnessSignature(ctx context.Context, in *AddWitnessSignatureRequest, opts ...grpc.CallOption) (*AddWitnessSignatureResponse, error) {
+	out := new(AddWitnessSignatureResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLog/AddWitnessSignature", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) AddObservedRoot(ctx context.Context, in *AddObservedRootRequest, opts ...grpc.CallOption) (*AddObservedRootResponse, error) {
+	out := new(AddObservedRootResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLog/AddObservedRoot", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) GetConsistencyProofHistory(ctx context.Context, in *GetConsistencyProofHistoryRequest, opts ...grpc.CallOption) (TrillianLog_GetConsistencyProofHistoryClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_TrillianLog_serviceDesc.Streams[0], c.cc, "/trillian.TrillianLog/GetConsistencyProofHistory", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &trillianLogGetConsistencyProofHistoryClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TrillianLog_GetConsistencyProofHistoryClient interface {
+	Recv() (*GetConsistencyProofHistoryResponse, error)
+	grpc.ClientStream
+}
+
+type trillianLogGetConsistencyProofHistoryClient struct {
+	grpc.ClientStream
+}
+
+func (x *trillianLogGetConsistencyProofHistoryClient) Recv() (*GetConsistencyProofHistoryResponse, error) {
+	m := new(GetConsistencyProofHistoryResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *trillianLogClient) GetLeavesByIndexStream(ctx context.Context, in *GetLeavesByIndexRequest, opts ...grpc.CallOption) (TrillianLog_GetLeavesByIndexStreamClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_TrillianLog_serviceDesc.Streams[1], c.cc, "/trillian.TrillianLog/GetLeavesByIndexStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &trillianLogGetLeavesByIndexStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TrillianLog_GetLeavesByIndexStreamClient interface {
+	Recv() (*GetLeavesByIndexResponse, error)
+	grpc.ClientStream
+}
+
+type trillianLogGetLeavesByIndexStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *trillianLogGetLeavesByIndexStreamClient) Recv() (*GetLeavesByIndexResponse, error) {
+	m := new(GetLeavesByIndexResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // Server API for TrillianLog service
 
 type TrillianLogServer interface {
@@ -854,6 +1378,28 @@ type TrillianLogServer interface {
 	GetLeavesByIndex(context.Context, *GetLeavesByIndexRequest) (*GetLeavesByIndexResponse, error)
 	GetLeavesByHash(context.Context, *GetLeavesByHashRequest) (*GetLeavesByHashResponse, error)
 	GetEntryAndProof(context.Context, *GetEntryAndProofRequest) (*GetEntryAndProofResponse, error)
+	// AddSequencedLeaves adds leaves whose indices were assigned by the caller to a
+	// PREORDERED_LOG tree. Corresponds to the SequencedLeafAdder API.
+	AddSequencedLeaves(context.Context, *AddSequencedLeavesRequest) (*AddSequencedLeavesResponse, error)
+	// AddWitnessSignature stores a registered witness's countersignature over the
+	// log's latest root. Once a quorum of witnesses has cosigned a root it's
+	// returned by witnessed GetLatestSignedLogRoot requests.
+	AddWitnessSignature(context.Context, *AddWitnessSignatureRequest) (*AddWitnessSignatureResponse, error)
+	// AddObservedRoot checks a root observed by a client against the log's own
+	// history and records it. Roots which don't match the history are kept as
+	// evidence of a split view.
+	// Verification-only logs have no history of their own, roots signed with
+	// their public key are imported instead: a root proven consistent with the
+	// latest imported root, and newer than it, becomes the log's latest root.
+	AddObservedRoot(context.Context, *AddObservedRootRequest) (*AddObservedRootResponse, error)
+	// GetConsistencyProofHistory streams the consistency proofs between each
+	// neighbouring pair of a list of tree sizes, all read from the same snapshot of
+	// the log.
+	GetConsistencyProofHistory(*GetConsistencyProofHistoryRequest, TrillianLog_GetConsistencyProofHistoryServer) error
+	// GetLeavesByIndexStream returns the same leaves as GetLeavesByIndex, split between
+	// as many responses as it takes to keep each within the server's response size
+	// limit. The leaves are all read from the same snapshot of the log.
+	GetLeavesByIndexStream(*GetLeavesByIndexRequest, TrillianLog_GetLeavesByIndexStreamServer) error
 }
 
 func RegisterTrillianLogServer(s *grpc.Server, srv TrillianLogServer) {
@@ -1040,6 +1586,102 @@ func _TrillianLog_GetEntryAndProof_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _TrillianLog_AddSequencedLeaves_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddSequencedLeavesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).AddSequencedLeaves(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/AddSequencedLeaves",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).AddSequencedLeaves(ctx, req.(*AddSequencedLeavesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_AddWitnessSignature_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddWitnessSignatureRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).AddWitnessSignature(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/AddWitnessSignature",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).AddWitnessSignature(ctx, req.(*AddWitnessSignatureRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_AddObservedRoot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddObservedRootRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).AddObservedRoot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/AddObservedRoot",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).AddObservedRoot(ctx, req.(*AddObservedRootRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_GetConsistencyProofHistory_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetConsistencyProofHistoryRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TrillianLogServer).GetConsistencyProofHistory(m, &trillianLogGetConsistencyProofHistoryServer{stream})
+}
+
+type TrillianLog_GetConsistencyProofHistoryServer interface {
+	Send(*GetConsistencyProofHistoryResponse) error
+	grpc.ServerStream
+}
+
+type trillianLogGetConsistencyProofHistoryServer struct {
+	grpc.ServerStream
+}
+
+func (x *trillianLogGetConsistencyProofHistoryServer) Send(m *GetConsistencyProofHistoryResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _TrillianLog_GetLeavesByIndexStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetLeavesByIndexRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TrillianLogServer).GetLeavesByIndexStream(m, &trillianLogGetLeavesByIndexStreamServer{stream})
+}
+
+type TrillianLog_GetLeavesByIndexStreamServer interface {
+	Send(*GetLeavesByIndexResponse) error
+	grpc.ServerStream
+}
+
+type trillianLogGetLeavesByIndexStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *trillianLogGetLeavesByIndexStreamServer) Send(m *GetLeavesByIndexResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 var _TrillianLog_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "trillian.TrillianLog",
 	HandlerType: (*TrillianLogServer)(nil),
@@ -1084,77 +1726,132 @@ var _TrillianLog_serviceDesc = grpc.ServiceDesc{
 			MethodName: "GetEntryAndProof",
 			Handler:    _TrillianLog_GetEntryAndProof_Handler,
 		},
+		{
+			MethodName: "AddSequencedLeaves",
+			Handler:    _TrillianLog_AddSequencedLeaves_Handler,
+		},
+		{
+			MethodName: "AddWitnessSignature",
+			Handler:    _TrillianLog_AddWitnessSignature_Handler,
+		},
+		{
+			MethodName: "AddObservedRoot",
+			Handler:    _TrillianLog_AddObservedRoot_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "GetConsistencyProofHistory",
+			Handler:       _TrillianLog_GetConsistencyProofHistory_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "GetLeavesByIndexStream",
+			Handler:       _TrillianLog_GetLeavesByIndexStream_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "trillian_log_api.proto",
 }
 
 func init() { proto.RegisterFile("trillian_log_api.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 1013 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x57, 0xef, 0x6e, 0x1b, 0x45,
-	0x10, 0xaf, 0x73, 0xf9, 0xe7, 0x71, 0x1c, 0x3b, 0x5b, 0xb5, 0x71, 0xcf, 0x0d, 0xa4, 0x5b, 0xd2,
-	0xba, 0x15, 0x38, 0x52, 0x10, 0x88, 0x0f, 0x08, 0x54, 0x37, 0x25, 0x8d, 0x64, 0x20, 0x9c, 0x4b,
-	0x85, 0x84, 0xc4, 0xe9, 0xe2, 0x5b, 0x3b, 0x07, 0x97, 0x5b, 0xf7, 0x6e, 0x1d, 0xc5, 0xfd, 0xce,
-	0x63, 0xf0, 0x2a, 0x3c, 0x00, 0x4f, 0x85, 0x76, 0x76, 0xef, 0xec, 0x3b, 0x9f, 0xcf, 0x35, 0x12,
-	0xdf, 0x2e, 0x33, 0xbf, 0xf9, 0xcd, 0x6f, 0x76, 0x76, 0x66, 0x1d, 0xb8, 0x2f, 0x42, 0xcf, 0xf7,
-	0x3d, 0x27, 0xb0, 0x7d, 0x3e, 0xb4, 0x9d, 0x91, 0xd7, 0x1e, 0x85, 0x5c, 0x70, 0xb2, 0x1d, 0xdb,
-	0xcd, 0xdd, 0xf8, 0x4b, 0x79, 0xcc, 0xfd, 0x21, 0xe7, 0x43, 0x9f, 0x1d, 0x87, 0xa3, 0xfe, 0x71,
-	0x24, 0x1c, 0x31, 0x8e, 0x94, 0x83, 0xfe, 0x5d, 0x82, 0xad, 0x2e, 0x1f, 0x76, 0x99, 0x33, 0x20,
-	0x2d, 0xa8, 0x5f, 0xb3, 0xf0, 0x0f, 0x9f, 0xd9, 0x3e, 0x73, 0x06, 0xf6, 0x95, 0x13, 0x5d, 0x35,
-	0x4a, 0x87, 0xa5, 0xd6, 0x8e, 0xb5, 0xab, 0xec, 0x12, 0xf5, 0xda, 0x89, 0xae, 0xc8, 0x01, 0x00,
-	0x42, 0x6e, 0x1c, 0x7f, 0xcc, 0x1a, 0x6b, 0x88, 0x29, 0x4b, 0xcb, 0x5b, 0x69, 0x90, 0x6e, 0x76,
-	0x2b, 0x42, 0xc7, 0x76, 0x1d, 0xe1, 0x34, 0x0c, 0xe5, 0x46, 0xcb, 0xa9, 0x23, 0x9c, 0x24, 0xda,
-	0x0b, 0x5c, 0x76, 0xdb, 0x58, 0x3f, 0x2c, 0xb5, 0x0c, 0x15, 0x7d, 0x2e, 0x0d, 0xe4, 0x53, 0x20,
-	0xca, 0xed, 0xb2, 0x40, 0x78, 0x62, 0xa2, 0x84, 0x6c, 0x20, 0x4b, 0x1d, 0x61, 0xda, 0x21, 0xa5,
-	0x50, 0x07, 0xd6, 0x7f, 0xe0, 0x2e, 0x23, 0xfb, 0xb0, 0x15, 0x70, 0x97, 0xd9, 0x9e, 0xab, 0x35,
-	0x6f, 0xca, 0x3f, 0xcf, 0x5d, 0xd2, 0x84, 0x32, 0x3a, 0x90, 0x45, 0x49, 0xdd, 0x96, 0x06, 0x2c,
-	0xe4, 0x31, 0x54, 0xd1, 0x19, 0xb2, 0x1b, 0x2f, 0xf2, 0x78, 0x80, 0x62, 0x0d, 0x6b, 0x47, 0x1a,
-	0x2d, 0x6d, 0xa3, 0x3f, 0xc3, 0xc6, 0x45, 0xc8, 0xf9, 0x20, 0x23, 0xbc, 0x94, 0x15, 0xfe, 0x19,
-	0xc0, 0x48, 0xe2, 0x6c, 0x19, 0xdd, 0x58, 0x3b, 0x34, 0x5a, 0x95, 0x93, 0xdd, 0x76, 0xd2, 0x09,
-	0x29, 0xd3, 0x2a, 0x23, 0x42, 0x7e, 0xd2, 0x4b, 0xa8, 0xfe, 0x34, 0x66, 0x63, 0xe6, 0xc6, 0xe7,
-	0x7f, 0x04, 0xeb, 0x92, 0x0c, 0x89, 0x2b, 0x27, 0x7b, 0xd3, 0x48, 0x0d, 0xb0, 0xd0, 0x4d, 0x9e,
-	0xc3, 0xa6, 0x6a, 0x21, 0x56, 0x53, 0x39, 0x21, 0x6d, 0xd5, 0xdc, 0x76, 0x38, 0xea, 0xb7, 0x7b,
-	0xe8, 0xb1, 0x34, 0x82, 0xbe, 0x05, 0x82, 0x39, 0xba, 0xcc, 0xb9, 0x61, 0x91, 0xc5, 0xde, 0x8d,
-	0x59, 0x24, 0xc8, 0x3d, 0xd8, 0x94, 0x17, 0x47, 0x1f, 0x95, 0x61, 0x6d, 0xf8, 0x7c, 0x78, 0xee,
-	0x92, 0x67, 0xb0, 0xe9, 0x23, 0x4e, 0x6b, 0xcf, 0x51, 0xa0, 0x01, 0xf4, 0x02, 0xea, 0x31, 0xef,
-	0x60, 0x09, 0x6b, 0x5c, 0xd5, 0x5a, 0x61, 0x55, 0xf4, 0x7b, 0xd8, 0x9b, 0x61, 0x8c, 0x46, 0x3c,
-	0x88, 0x18, 0xf9, 0x0a, 0x2a, 0xef, 0xf0, 0x88, 0xec, 0x19, 0x8a, 0xfd, 0x29, 0x45, 0xea, 0xfc,
-	0x2c, 0x50, 0x58, 0xf9, 0x4d, 0x7b, 0x70, 0x37, 0x55, 0xb8, 0x26, 0xfc, 0x1a, 0xaa, 0x53, 0xc2,
-	0x69, 0xa5, 0x0b, 0x29, 0x77, 0x12, 0x4a, 0x59, 0xf5, 0x35, 0x34, 0xce, 0x98, 0x38, 0x0f, 0xfa,
-	0xfe, 0x58, 0x5e, 0x0c, 0xbc, 0x14, 0x4b, 0xaa, 0x4f, 0x5f, 0x99, 0xb5, 0xec, 0x95, 0x69, 0x42,
-	0x59, 0x84, 0x8c, 0xd9, 0x91, 0xf7, 0x9e, 0xe9, 0xbb, 0xb7, 0x2d, 0x0d, 0x3d, 0xef, 0x3d, 0xa3,
-	0x1d, 0x78, 0x90, 0x93, 0x4e, 0x57, 0x72, 0x04, 0x1b, 0x78, 0x95, 0xf4, 0xa1, 0xd4, 0xa6, 0x15,
-	0x28, 0x9c, 0xf2, 0xd2, 0xbf, 0x4a, 0xf0, 0xd1, 0x1c, 0x49, 0x07, 0x47, 0x67, 0x89, 0xf2, 0x26,
-	0x94, 0xa7, 0x6b, 0x40, 0xcf, 0x8d, 0x1f, 0x2f, 0x80, 0x22, 0xdd, 0xe4, 0x39, 0xec, 0xf1, 0xd0,
-	0x65, 0xa1, 0x7d, 0x39, 0xb1, 0x23, 0x99, 0x24, 0xe8, 0x33, 0x1c, 0xf3, 0x6d, 0xab, 0x86, 0x8e,
-	0xce, 0xa4, 0xa7, 0xcd, 0xf4, 0x35, 0x7c, 0xbc, 0x50, 0xde, 0x7c, 0xa5, 0x46, 0x41, 0xa5, 0x7f,
-	0x96, 0xc0, 0x3c, 0x63, 0xe2, 0x25, 0x0f, 0x22, 0x2f, 0x12, 0x2c, 0xe8, 0x4f, 0x3e, 0xa4, 0x3f,
-	0x4f, 0xa0, 0x36, 0xf0, 0xc2, 0x48, 0xd8, 0xd3, 0x72, 0x54, 0x93, 0xaa, 0x68, 0x7e, 0x13, 0xd7,
-	0xd4, 0x82, 0x7a, 0xc4, 0xfa, 0x3c, 0x70, 0xed, 0x6c, 0xdd, 0xbb, 0xca, 0x1e, 0x23, 0xe9, 0x29,
-	0x34, 0x73, 0x65, 0xac, 0xd6, 0xb7, 0x5b, 0xb8, 0x7f, 0xc6, 0x84, 0xba, 0x77, 0xff, 0xa5, 0x5d,
-	0x46, 0xaa, 0x5d, 0xb9, 0x1d, 0x31, 0xf2, 0x3b, 0x72, 0x0a, 0xfb, 0x73, 0x99, 0xb5, 0xf6, 0x15,
-	0x16, 0xc4, 0x8f, 0x29, 0x16, 0xbc, 0xec, 0x2b, 0x4e, 0x8a, 0x91, 0x9a, 0x14, 0xfa, 0x0a, 0x67,
-	0x2f, 0x43, 0xb8, 0xba, 0xae, 0x2f, 0xe0, 0xe1, 0x19, 0x13, 0x71, 0xb1, 0xb8, 0x2b, 0x5e, 0xf2,
-	0x71, 0x20, 0x8a, 0xc5, 0xd1, 0x6f, 0xe0, 0x60, 0x41, 0x98, 0x96, 0x10, 0xab, 0xef, 0x4b, 0xeb,
-	0xec, 0x9c, 0x23, 0x8c, 0x7e, 0x89, 0xf1, 0x5d, 0x47, 0xb0, 0x48, 0xf4, 0xbc, 0x61, 0x80, 0x1b,
-	0xc6, 0xe2, 0x7c, 0x59, 0x5e, 0x07, 0xa7, 0x37, 0x37, 0x4e, 0x27, 0xfe, 0x16, 0x6a, 0x11, 0x3a,
-	0xf0, 0xb7, 0x40, 0xc8, 0xb9, 0x98, 0x5f, 0x93, 0xe9, 0xc8, 0x6a, 0x34, 0xfb, 0x27, 0xf5, 0xb1,
-	0x53, 0xaf, 0x02, 0x11, 0x4e, 0x5e, 0x04, 0xee, 0xff, 0xbd, 0xd3, 0xae, 0xb0, 0x8d, 0x99, 0x6c,
-	0x2b, 0x8d, 0x46, 0xf2, 0xa0, 0x18, 0x85, 0x0f, 0xca, 0xc9, 0x3f, 0x5b, 0x50, 0x79, 0xa3, 0x5d,
-	0x5d, 0x3e, 0x24, 0xdf, 0x41, 0x39, 0x79, 0x60, 0x88, 0x99, 0x59, 0xf8, 0x33, 0xef, 0x98, 0xd9,
-	0xcc, 0xf5, 0x29, 0x8d, 0xf4, 0x0e, 0xe9, 0x42, 0x65, 0xe6, 0x65, 0x21, 0x0f, 0xe7, 0xd1, 0xd3,
-	0x97, 0xd6, 0x3c, 0x58, 0xe0, 0x4d, 0xd8, 0x7e, 0x83, 0xbd, 0xb9, 0xfd, 0x47, 0xe8, 0x34, 0x6a,
-	0xd1, 0x7b, 0x63, 0x3e, 0x2e, 0xc4, 0x24, 0xfc, 0x23, 0xec, 0x6e, 0xde, 0x7e, 0x25, 0xad, 0x02,
-	0x86, 0xd4, 0xca, 0x31, 0x9f, 0x7d, 0x00, 0x32, 0xc9, 0xe8, 0xc2, 0xdd, 0x9c, 0xfd, 0x47, 0x3e,
-	0x49, 0x71, 0x2c, 0xd8, 0xd2, 0xe6, 0xd1, 0x12, 0x54, 0x92, 0xe5, 0x5a, 0xed, 0xc7, 0xf9, 0xc1,
-	0x20, 0x4f, 0x53, 0x14, 0x8b, 0x47, 0xce, 0x6c, 0x2d, 0x07, 0x26, 0xe9, 0x7e, 0x87, 0x7b, 0xb9,
-	0xf3, 0x4f, 0x9e, 0xa4, 0x48, 0x16, 0xee, 0x15, 0xf3, 0xe9, 0x52, 0x5c, 0x92, 0xeb, 0x57, 0xa8,
-	0x67, 0x37, 0x1d, 0x79, 0x94, 0xd6, 0x9a, 0xb3, 0x56, 0x4d, 0x5a, 0x04, 0x49, 0xc8, 0x7f, 0x81,
-	0x5a, 0x66, 0xbb, 0x93, 0xc3, 0xdc, 0xc0, 0xd9, 0xfe, 0x3f, 0x2a, 0x40, 0x64, 0x64, 0xa7, 0x26,
-	0x3b, 0x23, 0x3b, 0x6f, 0xc7, 0x64, 0x64, 0xe7, 0x2e, 0x06, 0x7a, 0xa7, 0x73, 0x0c, 0x0f, 0xfa,
-	0xfc, 0x3a, 0xfe, 0xa1, 0x9b, 0xfe, 0xe7, 0xa6, 0x53, 0x8f, 0xc7, 0xfc, 0xc5, 0xc8, 0xbb, 0x90,
-	0x96, 0x8b, 0xd2, 0xe5, 0x26, 0xba, 0x3e, 0xff, 0x37, 0x00, 0x00, 0xff, 0xff, 0xa1, 0x13, 0xac,
-	0x08, 0x2b, 0x0d, 0x00, 0x00,
+	// 1523 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xcd, 0x58, 0x5b, 0x6f, 0xdc, 0x44,
+	0x14, 0x66, 0xe3, 0x26, 0xdd, 0x3d, 0x9b, 0xcb, 0xee, 0x94, 0x26, 0x5b, 0x27, 0x81, 0xc6, 0xbd,
+	0xa5, 0xb4, 0x6c, 0xaa, 0x94, 0x4a, 0x20, 0x21, 0x50, 0xd3, 0x94, 0x36, 0x52, 0x68, 0x83, 0x53,
+	0x0a, 0x08, 0x81, 0xe5, 0xac, 0x27, 0x1b, 0x53, 0xaf, 0xbd, 0xb5, 0xbd, 0x69, 0x16, 0xc4, 0x23,
+	0x3f, 0x83, 0xbf, 0xc1, 0x03, 0x3f, 0x81, 0x97, 0x3e, 0x21, 0xf1, 0x73, 0x98, 0x39, 0x33, 0xbe,
+	0xae, 0xd7, 0x9b, 0x40, 0x91, 0x78, 0x49, 0x32, 0xe7, 0x7c, 0x73, 0xe6, 0xdc, 0xcf, 0x71, 0x60,
+	0x31, 0xf4, 0x6d, 0xc7, 0xb1, 0x4d, 0xd7, 0x70, 0xbc, 0xae, 0x61, 0xf6, 0xed, 0x76, 0xdf, 0xf7,
+	0x42, 0x8f, 0x54, 0x23, 0xba, 0x3a, 0x1f, 0xfd, 0x25, 0x38, 0xea, 0x52, 0xd7, 0xf3, 0xba, 0x0e,
+	0xdd, 0xf0, 0xfb, 0x9d, 0x8d, 0x20, 0x34, 0xc3, 0x41, 0x20, 0x19, 0x77, 0xbb, 0x76, 0x78, 0x34,
+	0x38, 0x68, 0x77, 0xbc, 0xde, 0x86, 0xc4, 0x44, 0x57, 0x37, 0x3a, 0xfe, 0xb0, 0x1f, 0x7a, 0x1b,
+	0x81, 0xdd, 0xed, 0x1f, 0x88, 0x9f, 0xe2, 0x92, 0xf6, 0x57, 0x05, 0xce, 0xef, 0x7a, 0xdd, 0x5d,
+	0x6a, 0x1e, 0x92, 0x75, 0x68, 0xf4, 0xa8, 0xff, 0xc2, 0xa1, 0x86, 0xc3, 0x8e, 0xc6, 0x91, 0x19,
+	0x1c, 0xb5, 0x2a, 0x97, 0x2b, 0xeb, 0xb3, 0xfa, 0xbc, 0xa0, 0x73, 0xd4, 0x63, 0x46, 0x25, 0xab,
+	0x00, 0x08, 0x39, 0x36, 0x9d, 0x01, 0x6d, 0x4d, 0x21, 0xa6, 0xc6, 0x29, 0xcf, 0x39, 0x81, 0xb3,
+	0xe9, 0x49, 0xe8, 0x9b, 0x86, 0x65, 0x86, 0x66, 0x4b, 0x11, 0x6c, 0xa4, 0x6c, 0x33, 0x42, 0x7c,
+	0xdb, 0x76, 0x2d, 0x7a, 0xd2, 0x3a, 0xc7, 0xd8, 0x8a, 0xb8, 0xbd, 0xc3, 0x09, 0xe4, 0x36, 0x10,
+	0xc1, 0xb6, 0xa8, 0x1b, 0xda, 0xe1, 0x50, 0x28, 0x32, 0x8d, 0x52, 0x1a, 0x08, 0x93, 0x0c, 0x54,
+	0xa5, 0x05, 0xe7, 0xe9, 0x49, 0xdf, 0xf6, 0xa9, 0xd5, 0x9a, 0x61, 0x90, 0xaa, 0x1e, 0x1d, 0x35,
+	0x13, 0xce, 0x3d, 0xf1, 0x2c, 0x4a, 0x96, 0xe0, 0xbc, 0xcb, 0x7e, 0x33, 0x79, 0xd2, 0x9a, 0x19,
+	0x7e, 0xdc, 0xb1, 0xc8, 0x32, 0xd4, 0x90, 0x81, 0xf2, 0x85, 0x11, 0x55, 0x4e, 0x40, 0xb9, 0x57,
+	0x60, 0x0e, 0x99, 0x3e, 0x3d, 0xb6, 0x03, 0xdb, 0x73, 0xd1, 0x0c, 0x45, 0x9f, 0xe5, 0x44, 0x5d,
+	0xd2, 0xb4, 0x2f, 0x61, 0x7a, 0xcf, 0xf7, 0xbc, 0xc3, 0x9c, 0x49, 0x95, 0xbc, 0x49, 0xef, 0x03,
+	0xf4, 0x39, 0xce, 0xe0, 0xb7, 0xd9, 0x53, 0xca, 0x7a, 0x7d, 0x73, 0xbe, 0x1d, 0x07, 0x96, 0xab,
+	0xa9, 0xd7, 0x10, 0xc1, 0xff, 0xd4, 0x0e, 0x60, 0xee, 0x8b, 0x01, 0x1d, 0x50, 0x2b, 0x8a, 0xcc,
+	0x35, 0x38, 0xc7, 0x85, 0xa1, 0xe0, 0xfa, 0x66, 0x33, 0xb9, 0x29, 0x01, 0x3a, 0xb2, 0xc9, 0x7b,
+	0x30, 0x23, 0x32, 0x02, 0xad, 0xa9, 0x6f, 0x92, 0xb6, 0xc8, 0x83, 0x36, 0xcb, 0x95, 0xf6, 0x3e,
+	0x72, 0x74, 0x89, 0xd0, 0x9e, 0x03, 0xc1, 0x37, 0xd8, 0xf5, 0x63, 0x1a, 0xe8, 0xf4, 0xe5, 0x80,
+	0x06, 0x21, 0xb9, 0x08, 0x33, 0x3c, 0x0f, 0xa5, 0xab, 0x14, 0x7d, 0x9a, 0x9d, 0x98, 0xa7, 0x6e,
+	0x32, 0x32, 0xe2, 0xa4, 0xee, 0x05, 0x1a, 0x48, 0x80, 0xb6, 0x07, 0x8d, 0x48, 0xee, 0xe1, 0x04,
+	0xa9, 0x91, 0x55, 0x53, 0xa5, 0x56, 0x69, 0xaf, 0x2b, 0xd0, 0x4c, 0x89, 0x0c, 0xfa, 0x9e, 0x1b,
+	0x50, 0xf2, 0x21, 0xd4, 0x5f, 0xa2, 0x8f, 0x8c, 0x94, 0x8c, 0xa5, 0x44, 0x46, 0xc6, 0x81, 0x3a,
+	0x08, 0x2c, 0x3a, 0x33, 0xd1, 0x46, 0x49, 0x6b, 0xb3, 0x09, 0x17, 0x11, 0x64, 0x84, 0x76, 0x8f,
+	0x29, 0x6d, 0xf6, 0xfa, 0x86, 0x6b, 0xba, 0x5e, 0x20, 0x13, 0xf4, 0x02, 0x32, 0x9f, 0x45, 0xbc,
+	0x27, 0x9c, 0x45, 0xee, 0xc1, 0x52, 0xcf, 0x3c, 0x31, 0x58, 0x75, 0x74, 0xa9, 0x61, 0x51, 0xc7,
+	0x1c, 0x1a, 0x01, 0xed, 0x78, 0xae, 0x15, 0x60, 0xbe, 0x4e, 0xeb, 0x6f, 0x33, 0xf6, 0xe7, 0x9c,
+	0xbb, 0xcd, 0x99, 0xfb, 0x82, 0xa7, 0xfd, 0x59, 0x81, 0x0b, 0x19, 0xe7, 0x4b, 0x9b, 0x3e, 0x86,
+	0xb9, 0xc4, 0xa6, 0xc4, 0xdb, 0x63, 0xad, 0x9a, 0x8d, 0xad, 0x62, 0xe0, 0xff, 0x81, 0x5d, 0x3d,
+	0x68, 0x3d, 0xa2, 0xe1, 0x8e, 0xdb, 0x71, 0x06, 0xbc, 0x3c, 0xb0, 0x34, 0x26, 0xe4, 0x40, 0xb6,
+	0x70, 0xa6, 0xf2, 0x85, 0xc3, 0x4a, 0x34, 0xf4, 0x29, 0x35, 0x02, 0xfb, 0x47, 0x2a, 0xcd, 0xaa,
+	0x72, 0xc2, 0x3e, 0x3b, 0x6b, 0x5b, 0x70, 0xa9, 0xe0, 0x39, 0xe9, 0xcb, 0x6b, 0x30, 0x8d, 0x05,
+	0x25, 0x33, 0x63, 0x21, 0xf1, 0xa1, 0xc0, 0x09, 0xae, 0xf6, 0x6b, 0x05, 0xde, 0x19, 0x11, 0xb2,
+	0x85, 0xad, 0x65, 0x82, 0xe6, 0x4c, 0xb5, 0xa4, 0x4d, 0xca, 0xee, 0xe1, 0x44, 0x0d, 0xb2, 0x4c,
+	0x6f, 0x56, 0xa6, 0x4d, 0xcf, 0xb7, 0xa8, 0x6f, 0x1c, 0x70, 0xb7, 0xb2, 0x47, 0xdc, 0x0e, 0xc5,
+	0x68, 0x54, 0xf5, 0x05, 0x64, 0x6c, 0x31, 0x8f, 0x0a, 0xb2, 0xf6, 0x18, 0xde, 0x1d, 0xab, 0xde,
+	0xa8, 0xa5, 0x4a, 0x89, 0xa5, 0xbf, 0x54, 0x40, 0x65, 0xa2, 0x1e, 0xb0, 0x3b, 0x76, 0x10, 0x32,
+	0xe1, 0xc3, 0xd3, 0xc4, 0xe7, 0x3a, 0x2c, 0x1c, 0xda, 0x7e, 0x10, 0x1a, 0x89, 0x39, 0x22, 0x48,
+	0x73, 0x48, 0x7e, 0x16, 0xd9, 0xc4, 0x66, 0x87, 0xc8, 0x10, 0x23, 0x6f, 0xf7, 0xbc, 0xa0, 0x47,
+	0x48, 0x6d, 0x1b, 0x96, 0x0b, 0xd5, 0x38, 0x5b, 0xdc, 0x7e, 0xaf, 0xc0, 0x22, 0x13, 0x23, 0x52,
+	0xff, 0x9f, 0xc4, 0x4b, 0xc9, 0xc4, 0xab, 0x30, 0x24, 0x4a, 0x61, 0x48, 0xf8, 0x64, 0xa0, 0xa6,
+	0xef, 0xd8, 0xec, 0x2d, 0xc3, 0x73, 0x9d, 0xa1, 0x0c, 0xdd, 0x6c, 0x44, 0x7c, 0xca, 0x68, 0xd9,
+	0x04, 0x98, 0xce, 0x25, 0xee, 0xcf, 0xb0, 0x34, 0xa2, 0xbb, 0x34, 0xff, 0xf4, 0x9d, 0x76, 0x5c,
+	0xbd, 0x5f, 0x86, 0x59, 0xb4, 0x53, 0xf0, 0x78, 0x99, 0x2b, 0x8c, 0x89, 0x55, 0xb6, 0xcb, 0x01,
+	0x81, 0xe6, 0x64, 0x9e, 0xc7, 0x42, 0x3b, 0x63, 0x95, 0x2a, 0x67, 0xa8, 0xd2, 0x87, 0xd8, 0x14,
+	0x72, 0xaf, 0x9d, 0xd9, 0x5a, 0xed, 0x1e, 0xac, 0x30, 0x31, 0x51, 0x10, 0xb0, 0x93, 0x3f, 0xf0,
+	0x06, 0x6e, 0x58, 0xae, 0xb9, 0xf6, 0x09, 0xac, 0x8e, 0xb9, 0x26, 0x55, 0x88, 0x4c, 0xeb, 0x70,
+	0x6a, 0xba, 0x01, 0x21, 0x4c, 0x7b, 0x89, 0xf7, 0x77, 0xcd, 0x90, 0xbd, 0xb1, 0x6f, 0x77, 0x5d,
+	0x6c, 0xbe, 0xba, 0xe7, 0x4d, 0x78, 0x97, 0xac, 0x40, 0xed, 0x95, 0x1d, 0xba, 0x34, 0x08, 0xd8,
+	0x62, 0x32, 0x85, 0x09, 0x92, 0x10, 0xca, 0x1d, 0xf6, 0x87, 0x68, 0x49, 0x85, 0x6f, 0x4a, 0xa5,
+	0x3f, 0x85, 0x85, 0x00, 0x19, 0x18, 0x65, 0x56, 0x10, 0xe1, 0xe8, 0x00, 0xcc, 0xde, 0x9c, 0x0b,
+	0xd2, 0x47, 0xb2, 0x03, 0x44, 0x6a, 0x63, 0x70, 0x06, 0xdb, 0x08, 0x7c, 0x16, 0x04, 0x05, 0x83,
+	0xa0, 0x26, 0x32, 0xbe, 0x12, 0x98, 0xfd, 0x08, 0xa2, 0x37, 0x5f, 0xe5, 0x28, 0x01, 0xb7, 0xf4,
+	0xd0, 0x76, 0x4d, 0x87, 0xa9, 0x6e, 0xc9, 0x52, 0x48, 0x08, 0x32, 0xd7, 0x1e, 0xba, 0xa1, 0x3f,
+	0xbc, 0xef, 0x5a, 0xff, 0xf5, 0x44, 0x38, 0xc2, 0x5c, 0xcb, 0xbd, 0x76, 0xa6, 0xc6, 0x12, 0x2f,
+	0x25, 0x4a, 0xf9, 0x52, 0xf2, 0x1d, 0x5c, 0xba, 0x6f, 0x59, 0xe9, 0xbc, 0x7a, 0xa3, 0x5b, 0xd4,
+	0x0a, 0xa8, 0x45, 0xe2, 0x85, 0x29, 0xda, 0x0b, 0x68, 0xe4, 0x23, 0x43, 0xd6, 0x60, 0x36, 0x8a,
+	0xa8, 0x6b, 0xf6, 0x28, 0xbe, 0x5c, 0xd3, 0xeb, 0x92, 0xf6, 0x84, 0x91, 0xc8, 0x07, 0x50, 0x8b,
+	0x83, 0x2d, 0xbd, 0xb0, 0xd8, 0x16, 0x1f, 0x03, 0xdb, 0x36, 0xfb, 0x78, 0x30, 0x1d, 0x67, 0x28,
+	0xb2, 0x46, 0x4f, 0x80, 0xda, 0x6f, 0x15, 0xd4, 0x65, 0x24, 0x15, 0x26, 0x76, 0xdb, 0xfc, 0xc4,
+	0x48, 0x06, 0x20, 0x63, 0xf2, 0x9c, 0x15, 0xad, 0x58, 0x7c, 0x1e, 0x54, 0x39, 0x01, 0x5b, 0xf1,
+	0x23, 0x68, 0x8e, 0xa4, 0x26, 0xe6, 0x55, 0x79, 0x66, 0x36, 0xf2, 0x99, 0xa9, 0xad, 0xc2, 0x72,
+	0xa1, 0xde, 0xd2, 0x89, 0x6c, 0xf2, 0x2f, 0x32, 0xfe, 0xd3, 0x83, 0x80, 0xfa, 0xc7, 0xcc, 0xe4,
+	0xc9, 0x35, 0xfd, 0xaf, 0xab, 0xee, 0x16, 0x34, 0x3b, 0xc9, 0xdc, 0x33, 0x44, 0x3a, 0x2a, 0x38,
+	0x8a, 0x1a, 0x9d, 0xdc, 0x40, 0xd4, 0x3e, 0x82, 0xa5, 0x11, 0xf5, 0x64, 0x2a, 0xbf, 0x03, 0x10,
+	0xc3, 0x43, 0xd4, 0xb1, 0xaa, 0xa7, 0x28, 0xda, 0x37, 0xb0, 0x56, 0x30, 0x62, 0x1f, 0xb3, 0x83,
+	0xe7, 0x0f, 0x27, 0x97, 0x5f, 0x1c, 0xb8, 0x20, 0x6a, 0xf5, 0x51, 0xe4, 0x02, 0xee, 0x35, 0xad,
+	0x4c, 0xb6, 0xd4, 0xb0, 0x60, 0x6d, 0xa8, 0x9c, 0x76, 0x6d, 0x98, 0x2a, 0x5a, 0x1b, 0x92, 0xf2,
+	0x55, 0xca, 0xca, 0x77, 0xf3, 0x75, 0x1d, 0xea, 0xcf, 0x24, 0x87, 0xb9, 0x9d, 0x7c, 0x06, 0xb5,
+	0xf8, 0xdb, 0x81, 0xa8, 0xb9, 0x45, 0x3a, 0xf5, 0x8d, 0xa2, 0x2e, 0x17, 0xf2, 0x64, 0xae, 0xbc,
+	0x45, 0x76, 0xa1, 0x9e, 0xda, 0xd8, 0xc9, 0xca, 0x28, 0x3a, 0xa9, 0x7f, 0x75, 0x75, 0x0c, 0x37,
+	0x96, 0xf6, 0x3d, 0x34, 0x47, 0xb6, 0x3a, 0xa2, 0x25, 0xb7, 0xc6, 0x6d, 0xd1, 0xea, 0x95, 0x52,
+	0x4c, 0x2c, 0xbf, 0x8f, 0x5d, 0xb7, 0x68, 0x6b, 0x24, 0xeb, 0x25, 0x12, 0x32, 0x7b, 0x94, 0x7a,
+	0xf3, 0x14, 0xc8, 0xf8, 0x45, 0x0b, 0x2e, 0x14, 0xa4, 0x05, 0xb9, 0x9a, 0x91, 0x31, 0x66, 0xf7,
+	0x54, 0xaf, 0x4d, 0x40, 0xc5, 0xaf, 0xf4, 0xc4, 0xd2, 0x37, 0x3a, 0x19, 0xc9, 0x8d, 0x8c, 0x88,
+	0xf1, 0xf3, 0x5a, 0x5d, 0x9f, 0x0c, 0x8c, 0x9f, 0xfb, 0x01, 0x2e, 0x16, 0x2e, 0x0f, 0xe4, 0x7a,
+	0x46, 0xc8, 0xd8, 0xa5, 0x44, 0xbd, 0x31, 0x11, 0x17, 0xbf, 0xf5, 0x2d, 0x34, 0xf2, 0x6b, 0x12,
+	0x59, 0xcb, 0xea, 0x5a, 0xb0, 0xb0, 0xa9, 0x5a, 0x19, 0x24, 0x16, 0xfe, 0x35, 0x2c, 0xe4, 0x16,
+	0x4e, 0x72, 0xb9, 0xf0, 0x62, 0x3a, 0xfe, 0x6b, 0x25, 0x88, 0x9c, 0xda, 0x99, 0x89, 0x9b, 0x53,
+	0xbb, 0x68, 0xf6, 0xe7, 0xd4, 0x2e, 0x1c, 0xd8, 0x4c, 0xb8, 0x09, 0x64, 0x74, 0x0a, 0x92, 0x54,
+	0x0d, 0x8c, 0x1d, 0xc1, 0xea, 0xd5, 0x72, 0x50, 0x3a, 0x6f, 0x0b, 0x86, 0x04, 0xc9, 0x5e, 0x1f,
+	0x33, 0xfb, 0xd2, 0x79, 0x5b, 0x36, 0x69, 0xd0, 0xff, 0xb9, 0x5e, 0x9e, 0xf6, 0x7f, 0xf1, 0x14,
+	0x4a, 0xfb, 0x7f, 0xcc, 0x20, 0x60, 0x92, 0x7f, 0x2a, 0xfc, 0xa8, 0x93, 0xed, 0x98, 0xdc, 0x2a,
+	0x2d, 0xac, 0xec, 0x40, 0x50, 0x6f, 0x9f, 0x0e, 0x1c, 0x3d, 0x7d, 0xa7, 0x42, 0x3a, 0x99, 0x6f,
+	0x30, 0x4c, 0xba, 0x7d, 0xd6, 0xc7, 0xcd, 0xde, 0x1b, 0xcb, 0xdc, 0x3b, 0x95, 0xad, 0x0d, 0xb8,
+	0xd4, 0xf1, 0x7a, 0xd1, 0x7f, 0xb2, 0xb2, 0xff, 0x0c, 0xdd, 0x6a, 0x44, 0xbd, 0xfe, 0x7e, 0xdf,
+	0xde, 0xe3, 0x94, 0xbd, 0xca, 0xc1, 0x0c, 0xb2, 0xee, 0xfe, 0x0d, 0xca, 0xf6, 0xeb, 0x10, 0x5b,
+	0x15, 0x00, 0x00,
 }
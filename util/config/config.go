@@ -0,0 +1,198 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package config sets a binary's flags from a config file, so a fleet can share one
+// file rather than long command lines.
+//
+// The file is a flat YAML mapping of flag names to values, e.g.
+//
+//	# Log server settings.
+//	mysql_uri: "test:zaphod@tcp(127.0.0.1:3306)/test"
+//	port: 8090
+//	storage_breaker_threshold: 5
+//
+// Values are given as they would be on the command line: lists are comma separated
+// strings and booleans are true or false. Flags set on the command line take
+// precedence over the file.
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/golang/glog"
+)
+
+// File is a config file whose values have been applied to a FlagSet.
+type File struct {
+	path string
+	fs   *flag.FlagSet
+	// cmdLine holds the names of the flags set on the command line.
+	cmdLine map[string]bool
+	// values holds the values last read from the file.
+	values map[string]string
+}
+
+// Load sets the flags in fs named in the config file at path to the values it gives
+// them, except those already set, e.g. on the command line. It must be called after
+// fs is parsed.
+func Load(fs *flag.FlagSet, path string) (*File, error) {
+	f := &File{path: path, fs: fs, cmdLine: make(map[string]bool)}
+	fs.Visit(func(fl *flag.Flag) { f.cmdLine[fl.Name] = true })
+
+	values, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range values {
+		if f.cmdLine[name] {
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			return nil, fmt.Errorf("%s: invalid value %q for %v: %v", path, value, name, err)
+		}
+	}
+	f.values = values
+	return f, nil
+}
+
+// read parses the file, checking each key names a flag.
+func (f *File) read() (map[string]string, error) {
+	data, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+	values, err := parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", f.path, err)
+	}
+	for name := range values {
+		if f.fs.Lookup(name) == nil {
+			return nil, fmt.Errorf("%s: unknown flag %q", f.path, name)
+		}
+	}
+	return values, nil
+}
+
+// parse parses a flat YAML mapping of strings.
+func parse(data []byte) (map[string]string, error) {
+	values := make(map[string]string)
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") || line == "---" {
+			continue
+		}
+		i := strings.Index(line, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("line %d: want name: value, got %q", n, line)
+		}
+		name, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		if _, ok := values[name]; ok {
+			return nil, fmt.Errorf("line %d: %v is set more than once", n, name)
+		}
+		switch {
+		case len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0]:
+			value = value[1 : len(value)-1]
+		case strings.Contains(value, " #"):
+			value = strings.TrimSpace(value[:strings.Index(value, " #")])
+		}
+		values[name] = value
+	}
+	return values, s.Err()
+}
+
+// Reload re-reads the file and applies the values of the live flags which changed,
+// unless they're set on the command line. Other flags only take effect when the binary
+// restarts, changes to them are logged. It returns the names of the flags it set.
+func (f *File) Reload(live []string) ([]string, error) {
+	values, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	isLive := make(map[string]bool)
+	for _, name := range live {
+		isLive[name] = true
+	}
+
+	var changed []string
+	for name, value := range values {
+		if old, ok := f.values[name]; ok && old == value {
+			continue
+		}
+		changed = append(changed, name)
+	}
+	for name := range f.values {
+		if _, ok := values[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+
+	var set []string
+	for _, name := range changed {
+		value, ok := values[name]
+		switch {
+		case f.cmdLine[name]:
+			continue
+		case !isLive[name]:
+			glog.Warningf("%s: %v changed, it takes effect after a restart", f.path, name)
+			continue
+		case !ok:
+			// Removed from the file, so back to its default.
+			value = f.fs.Lookup(name).DefValue
+		}
+		if err := f.fs.Set(name, value); err != nil {
+			return set, fmt.Errorf("%s: invalid value %q for %v: %v", f.path, value, name, err)
+		}
+		set = append(set, name)
+	}
+	f.values = values
+	return set, nil
+}
+
+// ReloadOnSIGHUP reloads the live flags whenever the process receives SIGHUP, until
+// ctx is done, calling onReload after each reload which set any. It's intended to be run
+// in its own goroutine.
+func (f *File) ReloadOnSIGHUP(ctx context.Context, live []string, onReload func()) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sighup:
+		}
+		set, err := f.Reload(live)
+		if err != nil {
+			glog.Errorf("Failed to reload config: %v", err)
+		}
+		if len(set) > 0 {
+			glog.Infof("Reloaded config file %s, set %v", f.path, strings.Join(set, ", "))
+			if onReload != nil {
+				onReload()
+			}
+		}
+	}
+}
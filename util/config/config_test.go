@@ -0,0 +1,138 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("mysql_uri", "default", "")
+	fs.Int("port", 8090, "")
+	fs.Bool("log_rpcs", false, "")
+	fs.Float64("log_rpc_payload_sample_rate", 0, "")
+	return fs
+}
+
+func writeFile(t *testing.T, path, content string) {
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("WriteFile(%v)=%v", path, err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatalf("TempDir()=%v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+
+	for _, test := range []struct {
+		desc    string
+		content string
+		args    []string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			desc:    "values",
+			content: "---\n# Comment.\nmysql_uri: \"user:pass#1@tcp(db:3306)/test\"\nport: 9000 # RPC port\n\nlog_rpcs: true\n",
+			want:    map[string]string{"mysql_uri": "user:pass#1@tcp(db:3306)/test", "port": "9000", "log_rpcs": "true"},
+		},
+		{
+			desc:    "command line wins",
+			content: "port: 9000\nlog_rpcs: true\n",
+			args:    []string{"--port=9001"},
+			want:    map[string]string{"mysql_uri": "default", "port": "9001", "log_rpcs": "true"},
+		},
+		{desc: "unknown flag", content: "prot: 9000\n", wantErr: "unknown flag"},
+		{desc: "invalid value", content: "port: many\n", wantErr: "invalid value"},
+		{desc: "repeated", content: "port: 1\nport: 2\n", wantErr: "more than once"},
+		{desc: "not a mapping", content: "- port\n", wantErr: "line 1"},
+	} {
+		writeFile(t, path, test.content)
+		fs := newFlagSet()
+		if err := fs.Parse(test.args); err != nil {
+			t.Fatalf("%v: Parse(%v)=%v", test.desc, test.args, err)
+		}
+		_, err := Load(fs, path)
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%v: Load()=(_, %v), want error containing %q", test.desc, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: Load()=(_, %v), want (_, nil)", test.desc, err)
+			continue
+		}
+		for name, want := range test.want {
+			if got := fs.Lookup(name).Value.String(); got != want {
+				t.Errorf("%v: --%v=%q, want %q", test.desc, name, got, want)
+			}
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatalf("TempDir()=%v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+
+	writeFile(t, path, "port: 9000\nlog_rpcs: true\nlog_rpc_payload_sample_rate: 0.5\n")
+	fs := newFlagSet()
+	if err := fs.Parse([]string{"--log_rpc_payload_sample_rate=0.1"}); err != nil {
+		t.Fatalf("Parse()=%v", err)
+	}
+	f, err := Load(fs, path)
+	if err != nil {
+		t.Fatalf("Load()=(_, %v), want (_, nil)", err)
+	}
+
+	// The port isn't live and the sample rate is set on the command line, so only
+	// log_rpcs changes, back to its default as it's been removed.
+	writeFile(t, path, "port: 9001\nlog_rpc_payload_sample_rate: 0.9\n")
+	set, err := f.Reload([]string{"log_rpcs", "log_rpc_payload_sample_rate"})
+	if err != nil {
+		t.Fatalf("Reload()=(_, %v), want (_, nil)", err)
+	}
+	if want := []string{"log_rpcs"}; !reflect.DeepEqual(set, want) {
+		t.Errorf("Reload()=(%v, nil), want (%v, nil)", set, want)
+	}
+	for name, want := range map[string]string{"port": "9000", "log_rpcs": "false", "log_rpc_payload_sample_rate": "0.1"} {
+		if got := fs.Lookup(name).Value.String(); got != want {
+			t.Errorf("--%v=%q after Reload(), want %q", name, got, want)
+		}
+	}
+
+	writeFile(t, path, "port: 9001\nbogus: 1\n")
+	if _, err := f.Reload([]string{"log_rpcs"}); err == nil {
+		t.Error("Reload() of a file with an unknown flag=(_, nil), want error")
+	}
+	if got := fs.Lookup("log_rpcs").Value.String(); got != "false" {
+		t.Errorf("--log_rpcs=%q after failed Reload(), want false", got)
+	}
+}
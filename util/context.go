@@ -27,6 +27,12 @@ const (
 
 	// mapIDKey is the key used when storing a MapID in a context.Context.
 	mapIDKey contextKey = iota
+
+	// requestIDKey is the key used when storing a request ID in a context.Context.
+	requestIDKey contextKey = iota
+
+	// tenantKey is the key used when storing a tenant name in a context.Context.
+	tenantKey contextKey = iota
 )
 
 // NewLogContext returns a new context instance that is scoped to a particular Log.
@@ -39,22 +45,67 @@ func NewMapContext(ctx context.Context, mapID int64) context.Context {
 	return context.WithValue(ctx, mapIDKey, mapID)
 }
 
+// NewRequestIDContext returns a new context instance that is scoped to the RPC with
+// the given request ID. The ID is included in the prefixes returned by LogIDPrefix and
+// MapIDPrefix.
+func NewRequestIDContext(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestID returns the ID of the RPC associated with ctx, or "" if there isn't one.
+func RequestID(ctx context.Context) string {
+	v, _ := ctx.Value(requestIDKey).(string)
+	return v
+}
+
+// NewTenantContext returns a new context instance that is scoped to the named tenant.
+// Storage which keeps tenants' trees apart creates trees in the tenant's storage.
+func NewTenantContext(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey, tenant)
+}
+
+// Tenant returns the name of the tenant associated with ctx, or "" if there isn't one.
+func Tenant(ctx context.Context) string {
+	v, _ := ctx.Value(tenantKey).(string)
+	return v
+}
+
+// TreeID returns the ID of the log or map associated with ctx, if there is one.
+func TreeID(ctx context.Context) (int64, bool) {
+	if v, ok := ctx.Value(logIDKey).(int64); ok {
+		return v, true
+	}
+	v, ok := ctx.Value(mapIDKey).(int64)
+	return v, ok
+}
+
 // LogIDPrefix returns an identifier for the log associated with ctx in a form
 // suitable for use as a diagnostic prefix.
 func LogIDPrefix(ctx context.Context) string {
 	v, ok := ctx.Value(logIDKey).(int64)
-	if !ok {
-		return "{unknown}"
-	}
-	return fmt.Sprintf("{%d}", v)
+	return idPrefix(ctx, v, ok)
 }
 
 // MapIDPrefix returns an identifier for the log associated with ctx in a form
 // suitable for use as a diagnostic prefix.
 func MapIDPrefix(ctx context.Context) string {
 	v, ok := ctx.Value(mapIDKey).(int64)
-	if !ok {
-		return "{unknown}"
+	return idPrefix(ctx, v, ok)
+}
+
+// TreeIDPrefix is LogIDPrefix or MapIDPrefix, whichever tree is associated with ctx.
+func TreeIDPrefix(ctx context.Context) string {
+	v, ok := TreeID(ctx)
+	return idPrefix(ctx, v, ok)
+}
+
+func idPrefix(ctx context.Context, treeID int64, ok bool) string {
+	id := "unknown"
+	if ok {
+		id = fmt.Sprint(treeID)
+	}
+	if requestID := RequestID(ctx); requestID != "" {
+		return fmt.Sprintf("{%s request=%s}", id, requestID)
 	}
-	return fmt.Sprintf("{%d}", v)
+	return fmt.Sprintf("{%s}", id)
 }
@@ -54,3 +54,36 @@ func TestMapContext(t *testing.T) {
 		}
 	}
 }
+
+func TestRequestIDContext(t *testing.T) {
+	ctx := context.Background()
+	if got := RequestID(ctx); got != "" {
+		t.Errorf("RequestID(ctx)=%q; want \"\"", got)
+	}
+	ctx = NewRequestIDContext(ctx, "abc")
+	if got, want := RequestID(ctx), "abc"; got != want {
+		t.Errorf("RequestID(ctx)=%q; want %q", got, want)
+	}
+	if got, want := LogIDPrefix(ctx), "{unknown request=abc}"; got != want {
+		t.Errorf("LogID(ctx)=%q; want %q", got, want)
+	}
+	if got, want := MapIDPrefix(NewMapContext(ctx, 3)), "{3 request=abc}"; got != want {
+		t.Errorf("MapID(ctx)=%q; want %q", got, want)
+	}
+}
+
+func TestTreeID(t *testing.T) {
+	ctx := context.Background()
+	if _, ok := TreeID(ctx); ok {
+		t.Error("TreeID(ctx) found an ID in an empty context")
+	}
+	if id, ok := TreeID(NewLogContext(ctx, 3)); !ok || id != 3 {
+		t.Errorf("TreeID(log ctx)=(%v, %v); want (3, true)", id, ok)
+	}
+	if id, ok := TreeID(NewMapContext(ctx, 5)); !ok || id != 5 {
+		t.Errorf("TreeID(map ctx)=(%v, %v); want (5, true)", id, ok)
+	}
+	if got, want := TreeIDPrefix(NewMapContext(ctx, 5)), "{5}"; got != want {
+		t.Errorf("TreeIDPrefix(map ctx)=%q; want %q", got, want)
+	}
+}
@@ -15,30 +15,82 @@
 package util
 
 import (
+	_ "expvar" // Serve /debug/vars
 	"fmt"
 	"net"
 	"net/http"
+	"net/http/pprof"
 	"os"
 	"os/signal"
+	rpprof "runtime/pprof"
+	"strings"
 	"syscall"
 
 	"github.com/golang/glog"
 )
 
+// HTTPOptions configures StartHTTPServerWithOptions.
+type HTTPOptions struct {
+	// Debug serves pprof profiles under /debug/pprof/ and a dump of all goroutines'
+	// stacks at /debug/goroutines, e.g. to diagnose latency in production.
+	Debug bool
+}
+
 // StartHTTPServer starts an HTTP server on the given port.
 func StartHTTPServer(port int) error {
+	return StartHTTPServerWithOptions(port, HTTPOptions{})
+}
+
+// StartHTTPServerWithOptions starts an HTTP server on the given port, serving the
+// handlers registered with http.DefaultServeMux, including expvar's /debug/vars.
+func StartHTTPServerWithOptions(port int, opts HTTPOptions) error {
 	sock, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", port))
 	if err != nil {
 		return err
 	}
+	handler := NewHTTPHandler(opts)
 	go func() {
 		glog.Info("HTTP server starting")
-		http.Serve(sock, nil)
+		http.Serve(sock, handler)
 	}()
 
 	return nil
 }
 
+// NewHTTPHandler returns the handler StartHTTPServerWithOptions serves, for servers
+// which serve it on a listener of their own, e.g. one shared with gRPC.
+func NewHTTPHandler(opts HTTPOptions) http.Handler {
+	handler := http.Handler(http.DefaultServeMux)
+	if opts.Debug {
+		mux := http.NewServeMux()
+		mux.Handle("/", http.DefaultServeMux)
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		mux.HandleFunc("/debug/goroutines", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			rpprof.Lookup("goroutine").WriteTo(w, 2)
+		})
+		handler = mux
+	} else {
+		// Importing net/http/pprof registers its handlers on the default mux.
+		handler = withoutPprof(handler)
+	}
+	return handler
+}
+
+func withoutPprof(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/debug/pprof/") {
+			http.NotFound(w, r)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
 // AwaitSignal waits for standard termination signals, then runs the given
 // function; it should be run as a separate goroutine.
 func AwaitSignal(doneFn func()) {
@@ -16,6 +16,8 @@
 package proxy
 
 import (
+	"io"
+
 	"github.com/google/trillian"
 	"golang.org/x/net/context"
 )
@@ -43,6 +45,21 @@ func (p *Log) QueueLeaves(ctx context.Context, in *trillian.QueueLeavesRequest)
 	return p.c.QueueLeaves(ctx, in)
 }
 
+// AddSequencedLeaves forwards the RPC.
+func (p *Log) AddSequencedLeaves(ctx context.Context, in *trillian.AddSequencedLeavesRequest) (*trillian.AddSequencedLeavesResponse, error) {
+	return p.c.AddSequencedLeaves(ctx, in)
+}
+
+// AddWitnessSignature forwards the RPC.
+func (p *Log) AddWitnessSignature(ctx context.Context, in *trillian.AddWitnessSignatureRequest) (*trillian.AddWitnessSignatureResponse, error) {
+	return p.c.AddWitnessSignature(ctx, in)
+}
+
+// AddObservedRoot forwards the RPC.
+func (p *Log) AddObservedRoot(ctx context.Context, in *trillian.AddObservedRootRequest) (*trillian.AddObservedRootResponse, error) {
+	return p.c.AddObservedRoot(ctx, in)
+}
+
 // GetInclusionProof forwards the RPC.
 func (p *Log) GetInclusionProof(ctx context.Context, in *trillian.GetInclusionProofRequest) (*trillian.GetInclusionProofResponse, error) {
 	return p.c.GetInclusionProof(ctx, in)
@@ -58,6 +75,26 @@ func (p *Log) GetConsistencyProof(ctx context.Context, in *trillian.GetConsisten
 	return p.c.GetConsistencyProof(ctx, in)
 }
 
+// GetConsistencyProofHistory forwards the RPC, relaying each response in the stream.
+func (p *Log) GetConsistencyProofHistory(in *trillian.GetConsistencyProofHistoryRequest, stream trillian.TrillianLog_GetConsistencyProofHistoryServer) error {
+	c, err := p.c.GetConsistencyProofHistory(stream.Context(), in)
+	if err != nil {
+		return err
+	}
+	for {
+		rsp, err := c.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(rsp); err != nil {
+			return err
+		}
+	}
+}
+
 // GetLatestSignedLogRoot forwards the RPC.
 func (p *Log) GetLatestSignedLogRoot(ctx context.Context, in *trillian.GetLatestSignedLogRootRequest) (*trillian.GetLatestSignedLogRootResponse, error) {
 	return p.c.GetLatestSignedLogRoot(ctx, in)
@@ -73,6 +110,26 @@ func (p *Log) GetLeavesByIndex(ctx context.Context, in *trillian.GetLeavesByInde
 	return p.c.GetLeavesByIndex(ctx, in)
 }
 
+// GetLeavesByIndexStream forwards the RPC, relaying each response in the stream.
+func (p *Log) GetLeavesByIndexStream(in *trillian.GetLeavesByIndexRequest, stream trillian.TrillianLog_GetLeavesByIndexStreamServer) error {
+	c, err := p.c.GetLeavesByIndexStream(stream.Context(), in)
+	if err != nil {
+		return err
+	}
+	for {
+		rsp, err := c.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(rsp); err != nil {
+			return err
+		}
+	}
+}
+
 // GetLeavesByHash forwards the RPC.
 func (p *Log) GetLeavesByHash(ctx context.Context, in *trillian.GetLeavesByHashRequest) (*trillian.GetLeavesByHashResponse, error) {
 	return p.c.GetLeavesByHash(ctx, in)
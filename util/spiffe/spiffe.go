@@ -0,0 +1,52 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package spiffe provides mutual TLS credentials for RPCs from a SPIFFE Workload API,
+// e.g. a SPIRE agent, so servers and clients are identified by SPIFFE IDs rather than
+// static certificates. Certificates are rotated by the Workload API without restarts.
+package spiffe
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	"github.com/spiffe/go-spiffe/v2/spiffetls/tlsconfig"
+	"github.com/spiffe/go-spiffe/v2/workloadapi"
+	"google.golang.org/grpc/credentials"
+)
+
+// NewSource returns a source of this workload's X.509 SVIDs and trust bundles from the
+// Workload API at addr, e.g. unix:///run/spire/sockets/agent.sock. It blocks until the
+// first SVID is received or ctx is done. The source must be closed when no longer used.
+func NewSource(ctx context.Context, addr string) (*workloadapi.X509Source, error) {
+	return workloadapi.NewX509Source(ctx, workloadapi.WithClientOptions(workloadapi.WithAddr(addr)))
+}
+
+// ServerCredentials returns credentials for a gRPC server presenting the source's SVID
+// and accepting clients with an SVID from a trusted domain. Which clients may call which
+// methods is left to interceptor.Authorize.
+func ServerCredentials(source *workloadapi.X509Source) credentials.TransportCredentials {
+	return credentials.NewTLS(tlsconfig.MTLSServerConfig(source, source, tlsconfig.AuthorizeAny()))
+}
+
+// ClientCredentials returns credentials for a gRPC client presenting the source's SVID
+// and only accepting a server with the SPIFFE ID serverID.
+func ClientCredentials(source *workloadapi.X509Source, serverID string) (credentials.TransportCredentials, error) {
+	id, err := spiffeid.FromString(serverID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server SPIFFE ID %q: %v", serverID, err)
+	}
+	return credentials.NewTLS(tlsconfig.MTLSClientConfig(source, source, tlsconfig.AuthorizeID(id))), nil
+}